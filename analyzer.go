@@ -13,7 +13,12 @@ import (
 )
 
 // Analyzer implements gps.ProjectAnalyzer.
-type Analyzer struct{}
+type Analyzer struct {
+	// HintNestedVendor has the projects vendored by the dependencies returned
+	// as their lock, at the revisions their Gopkg.lock records, for the solver
+	// to prefer; see NestedVendorHint.
+	HintNestedVendor bool
+}
 
 // HasDepMetadata determines if a dep manifest exists at the specified path.
 func (a Analyzer) HasDepMetadata(path string) bool {
@@ -23,7 +28,9 @@ func (a Analyzer) HasDepMetadata(path string) bool {
 }
 
 // DeriveManifestAndLock reads and returns the manifest at path/ManifestName or nil if one is not found.
-// The Lock is always nil for now.
+// If the manifest lists workspace members, their manifests are merged with it.
+// The Lock is nil, unless HintNestedVendor is set and the project vendors
+// projects locked by its Gopkg.lock.
 func (a Analyzer) DeriveManifestAndLock(path string, n gps.ProjectRoot) (gps.Manifest, gps.Lock, error) {
 	if !a.HasDepMetadata(path) {
 		return nil, nil, nil
@@ -41,11 +48,38 @@ func (a Analyzer) DeriveManifestAndLock(path string, n gps.ProjectRoot) (gps.Man
 		return nil, nil, err
 	}
 
-	return m, nil, nil
+	var l gps.Lock
+	if a.HintNestedVendor {
+		hints, err := nestedVendorHints(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if hints != nil {
+			l = hints
+		}
+	}
+
+	if len(m.Workspace) > 0 {
+		members, _, err := loadWorkspaceMembers(path, ManifestName, LockName, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		return workspaceManifest{root: m, members: members}, l, nil
+	}
+
+	return m, l, nil
 }
 
 // Info returns Analyzer's name and version info.
 func (a Analyzer) Info() gps.ProjectAnalyzerInfo {
+	// The locks returned with hints differ, and mustn't be mixed up with the
+	// others in the cache.
+	if a.HintNestedVendor {
+		return gps.ProjectAnalyzerInfo{
+			Name:    "dep-nested-vendor-hints",
+			Version: 1,
+		}
+	}
 	return gps.ProjectAnalyzerInfo{
 		Name:    "dep",
 		Version: 1,
@@ -102,6 +102,56 @@ func TestAnalyzerDeriveManifestAndLockInvalidManifest(t *testing.T) {
 	}
 }
 
+func TestAnalyzerHintNestedVendor(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("dep/"+ManifestName, "")
+	h.TempFile("dep/"+LockName, `
+[[projects]]
+  name = "github.com/b/lib"
+  packages = ["."]
+  revision = "bbb"
+  version = "v1.0.0"
+
+[[projects]]
+  name = "github.com/c/log"
+  packages = ["."]
+  revision = "ccc"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  inputs-digest = ""
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`)
+	h.TempFile("dep/vendor/github.com/b/lib/lib.go", "package lib\n")
+
+	_, l, err := Analyzer{}.DeriveManifestAndLock(h.Path("dep"), "github.com/a/app")
+	if err != nil || l != nil {
+		t.Fatalf("expected no lock without hints, got %v (%v)", l, err)
+	}
+
+	// Only the projects found in vendor/ are hinted.
+	a := Analyzer{HintNestedVendor: true}
+	_, l, err = a.DeriveManifestAndLock(h.Path("dep"), "github.com/a/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l == nil || len(l.Projects()) != 1 {
+		t.Fatalf("expected a lock with one project, got %v", l)
+	}
+	lp := l.Projects()[0]
+	if lp.Ident().ProjectRoot != "github.com/b/lib" || lp.Version().String() != "v1.0.0" {
+		t.Errorf("unexpected hint %s@%s", lp.Ident().ProjectRoot, lp.Version())
+	}
+
+	if a.Info() == (Analyzer{}).Info() {
+		t.Errorf("expected the hinting analyzer to tell itself apart, got %v", a.Info())
+	}
+}
+
 func TestAnalyzerInfo(t *testing.T) {
 	a := Analyzer{}
 
@@ -0,0 +1,201 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package api lets programs such as editor plugins and build tools run dep
+// without shelling out to it and parsing its output.
+//
+// It wraps the loading of a project, the solve and the writing of vendor/
+// which dep ensure goes through, with types of its own, which are kept
+// compatible across versions of dep:
+//
+//	p, err := api.Open(api.Options{WorkingDir: dir})
+//	if err != nil {
+//		// dir is not in a dep project.
+//	}
+//	res, err := p.Ensure(api.EnsureOptions{})
+//	if serr, ok := err.(*api.SolveError); ok {
+//		// No solution; serr.Explanation tells why.
+//	}
+//	for _, c := range res.Changes {
+//		fmt.Println(c)
+//	}
+//
+// Unlike the dep command, the package reads none of the DEP* environment
+// variables: the settings they hold are the fields of Options.
+package api
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// Options configures how a project is opened and worked on.
+type Options struct {
+	// WorkingDir is the root of the project, or any directory below it.
+	WorkingDir string
+	// GOPATHs holds the Go paths, one of which contains the project. The
+	// GOPATH environment variable, or the default GOPATH, is used if empty.
+	GOPATHs []string
+
+	// CacheDir is where to keep the cache, if not in GOPATH/pkg/dep, and
+	// SourcesDir where to keep the sources of the cache, if not in CacheDir.
+	CacheDir   string
+	SourcesDir string
+	// Offline forbids all network access, serving sources from the cache
+	// only.
+	Offline bool
+
+	// Log receives the warnings and progress dep reports, which are
+	// discarded if nil. Verbose has it report as much as dep -v does.
+	Log     io.Writer
+	Verbose bool
+}
+
+// Project is a dep project: a directory with a manifest, and usually a lock.
+type Project struct {
+	// Root is the absolute path of the project, and ImportPath its import
+	// path.
+	Root       string
+	ImportPath string
+
+	ctx *dep.Ctx
+	p   *dep.Project
+}
+
+// Open loads the project containing opts.WorkingDir.
+func Open(opts Options) (*Project, error) {
+	if opts.WorkingDir == "" {
+		return nil, errors.New("no working directory to open the project of")
+	}
+	w := opts.Log
+	if w == nil {
+		w = ioutil.Discard
+	}
+	ctx := &dep.Ctx{
+		Out:        log.New(w, "", 0),
+		Err:        log.New(w, "", 0),
+		Verbose:    opts.Verbose,
+		CacheDir:   opts.CacheDir,
+		SourcesDir: opts.SourcesDir,
+		Offline:    opts.Offline,
+	}
+	if err := ctx.SetPaths(opts.WorkingDir, opts.GOPATHs...); err != nil {
+		return nil, err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return nil, err
+	}
+	return &Project{
+		Root:       p.AbsRoot,
+		ImportPath: string(p.ImportRoot),
+		ctx:        ctx,
+		p:          p,
+	}, nil
+}
+
+// Constraint is a rule of the manifest on a dependency.
+type Constraint struct {
+	ProjectRoot string
+	// Source is where to fetch the project from, if not its root.
+	Source string `json:",omitempty"`
+	// Constraint is the versions allowed, as dep status prints them, such as
+	// ^1.2.0, master or *.
+	Constraint string
+	// Override is set for the overrides, which apply to the dependencies of
+	// the dependencies as well.
+	Override bool `json:",omitempty"`
+}
+
+// Constraints returns the constraints and overrides of the manifest of p,
+// sorted by project root.
+func (p *Project) Constraints() []Constraint {
+	var cs []Constraint
+	add := func(pcs gps.ProjectConstraints, override bool) {
+		for pr, pp := range pcs {
+			c := Constraint{
+				ProjectRoot: string(pr),
+				Source:      pp.Source,
+				Constraint:  gps.Any().String(),
+				Override:    override,
+			}
+			if pp.Constraint != nil {
+				c.Constraint = pp.Constraint.String()
+			}
+			cs = append(cs, c)
+		}
+	}
+	add(p.p.Manifest.Constraints, false)
+	add(p.p.Manifest.Ovr, true)
+	sort.Sort(sortedConstraints(cs))
+	return cs
+}
+
+type sortedConstraints []Constraint
+
+func (s sortedConstraints) Len() int      { return len(s) }
+func (s sortedConstraints) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortedConstraints) Less(i, j int) bool {
+	if s[i].ProjectRoot != s[j].ProjectRoot {
+		return s[i].ProjectRoot < s[j].ProjectRoot
+	}
+	return !s[i].Override && s[j].Override
+}
+
+// Dependency is a project of the lock, at the version it's locked at.
+type Dependency struct {
+	ProjectRoot string
+	Source      string `json:",omitempty"`
+	// Version is the tag the project is locked at, and Branch the branch,
+	// if any. Revision is always set.
+	Version  string `json:",omitempty"`
+	Branch   string `json:",omitempty"`
+	Revision string
+	// Packages holds the packages of the project used, relative to its
+	// root.
+	Packages []string
+	// Dev is set for the projects only imported by the tests of the root
+	// project, when the manifest sets exclude-test-deps.
+	Dev bool `json:",omitempty"`
+}
+
+// Dependencies returns the projects of the lock of p, sorted by project root,
+// or nil if p has no lock.
+func (p *Project) Dependencies() []Dependency {
+	return dependencies(p.p.Lock)
+}
+
+// dependencies returns the projects of l, sorted by project root.
+func dependencies(l *dep.Lock) []Dependency {
+	if l == nil {
+		return nil
+	}
+	deps := make([]Dependency, 0, len(l.P))
+	for _, lp := range l.P {
+		id := lp.Ident()
+		d := Dependency{
+			ProjectRoot: string(id.ProjectRoot),
+			Source:      id.Source,
+			Packages:    lp.Packages(),
+			Dev:         l.Dev[id.ProjectRoot],
+		}
+		d.Revision, d.Branch, d.Version = gps.VersionComponentStrings(lp.Version())
+		deps = append(deps, d)
+	}
+	sort.Sort(sortedDependencies(deps))
+	return deps
+}
+
+type sortedDependencies []Dependency
+
+func (s sortedDependencies) Len() int           { return len(s) }
+func (s sortedDependencies) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortedDependencies) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
@@ -0,0 +1,155 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testManifest = `[[constraint]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/foo/baz"
+  source = "https://example.com/baz.git"
+  branch = "master"
+`
+
+const testLock = `[[projects]]
+  name = "github.com/foo/bar"
+  packages = [".", "sub"]
+  revision = "d05d5aca9f895d19e9265839bffeadd74a2d2ecb"
+  version = "v1.0.0"
+`
+
+// setupProject writes a project importing nothing outside the standard
+// library to a new GOPATH, and returns the GOPATH and the root of the project.
+func setupProject(t *testing.T, files map[string]string) (string, string) {
+	gopath, err := ioutil.TempDir("", "depapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(gopath, "src", "example.com", "proj")
+	files["main.go"] = "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n"
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gopath, root
+}
+
+func TestOpen(t *testing.T) {
+	gopath, root := setupProject(t, map[string]string{"Gopkg.toml": testManifest, "Gopkg.lock": testLock})
+	defer os.RemoveAll(gopath)
+
+	p, err := Open(Options{WorkingDir: root, GOPATHs: []string{gopath}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Root != root || p.ImportPath != "example.com/proj" {
+		t.Errorf("unexpected project %s, at %s", p.ImportPath, p.Root)
+	}
+
+	wantConstraints := []Constraint{
+		{ProjectRoot: "github.com/foo/bar", Constraint: "^1.0.0"},
+		{ProjectRoot: "github.com/foo/baz", Source: "https://example.com/baz.git", Constraint: "master", Override: true},
+	}
+	if got := p.Constraints(); !reflect.DeepEqual(got, wantConstraints) {
+		t.Errorf("unexpected constraints:\n\t(GOT): %+v\n\t(WNT): %+v", got, wantConstraints)
+	}
+
+	wantDeps := []Dependency{{
+		ProjectRoot: "github.com/foo/bar",
+		Version:     "v1.0.0",
+		Revision:    "d05d5aca9f895d19e9265839bffeadd74a2d2ecb",
+		Packages:    []string{".", "sub"},
+	}}
+	if got := p.Dependencies(); !reflect.DeepEqual(got, wantDeps) {
+		t.Errorf("unexpected dependencies:\n\t(GOT): %+v\n\t(WNT): %+v", got, wantDeps)
+	}
+
+	if _, err := Open(Options{WorkingDir: gopath, GOPATHs: []string{gopath}}); err == nil {
+		t.Error("expected an error opening a directory outside of any project")
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	gopath, root := setupProject(t, map[string]string{"Gopkg.toml": "", "Gopkg.lock": testLock})
+	defer os.RemoveAll(gopath)
+
+	p, err := Open(Options{WorkingDir: root, GOPATHs: []string{gopath}, CacheDir: filepath.Join(gopath, "cache")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The project imports nothing, so the locked project goes away.
+	res, err := p.Ensure(EnsureOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Dependencies) != 0 || len(res.Changes) != 1 || res.Changes[0].String() != "remove github.com/foo/bar" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(p.Dependencies()) != 1 {
+		t.Error("expected a dry run to leave the lock alone")
+	}
+
+	if _, err := p.Ensure(EnsureOptions{NoVendor: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Dependencies()) != 0 {
+		t.Errorf("expected the lock to be emptied, got %+v", p.Dependencies())
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor")); !os.IsNotExist(err) {
+		t.Errorf("expected no vendor/ to be written, got %v", err)
+	}
+
+	// Now in sync, the lock is kept.
+	res, err = p.Ensure(EnsureOptions{NoVendor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 0 {
+		t.Errorf("expected no changes, got %v", res.Changes)
+	}
+
+	if _, err := p.Ensure(EnsureOptions{Update: []string{"github.com/foo/bar"}}); err == nil {
+		t.Error("expected an error updating a project which isn't locked")
+	}
+}
+
+func TestDiffDependencies(t *testing.T) {
+	bar := Dependency{ProjectRoot: "github.com/foo/bar", Version: "v1.0.0", Revision: "d05d5aca9f895d19e9265839bffeadd74a2d2ecb"}
+	bar2 := Dependency{ProjectRoot: "github.com/foo/bar", Version: "v1.1.0", Revision: "f5c5aca9f895d19e9265839bffeadd74a2d2ecb"}
+	baz := Dependency{ProjectRoot: "github.com/foo/baz", Branch: "master", Revision: "a7c4aca9f895d19e9265839bffeadd74a2d2ecb"}
+	qux := Dependency{ProjectRoot: "github.com/foo/qux", Revision: "0bd1aca9f895d19e9265839bffeadd74a2d2ecb"}
+
+	changes := diffDependencies([]Dependency{bar, baz}, []Dependency{bar2, qux})
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"update github.com/foo/bar from v1.0.0 to v1.1.0",
+		"remove github.com/foo/baz",
+		"add github.com/foo/qux at 0bd1aca",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected changes:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	if changes := diffDependencies([]Dependency{bar, baz}, []Dependency{bar, baz}); len(changes) != 0 {
+		t.Errorf("expected no changes between the same dependencies, got %v", changes)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// EnsureOptions configures Ensure, as the flags of dep ensure do.
+type EnsureOptions struct {
+	// Update holds the roots of the projects of the lock which may change
+	// versions, as with dep ensure -update. UpdateAll lets all of them
+	// change.
+	Update    []string
+	UpdateAll bool
+
+	// NoVendor leaves vendor/ alone, only writing the lock.
+	NoVendor bool
+	// Dev writes the dev projects to vendor/ as well.
+	Dev bool
+	// Verify fails without writing anything if a project written to vendor/
+	// differs from its digest in the lock, as with dep ensure -verify.
+	Verify bool
+	// DryRun only reports the changes, writing nothing.
+	DryRun bool
+}
+
+// Result is the outcome of Ensure.
+type Result struct {
+	// Dependencies holds the projects of the lock, as ensured.
+	Dependencies []Dependency
+	// Changes holds the changes to the lock, sorted by project root.
+	Changes []Change
+	// VendorWritten is set when vendor/ was written, or would have been with
+	// DryRun.
+	VendorWritten bool
+}
+
+// Change is a change to a project of the lock.
+type Change struct {
+	ProjectRoot string
+	// From is the project before the change, or nil if it was added, and To
+	// the project after it, or nil if it was removed.
+	From, To *Dependency
+}
+
+func (c Change) String() string {
+	switch {
+	case c.From == nil:
+		return fmt.Sprintf("add %s at %s", c.ProjectRoot, versionString(*c.To))
+	case c.To == nil:
+		return fmt.Sprintf("remove %s", c.ProjectRoot)
+	}
+	return fmt.Sprintf("update %s from %s to %s", c.ProjectRoot, versionString(*c.From), versionString(*c.To))
+}
+
+// versionString returns the version d is locked at, as dep status prints it.
+func versionString(d Dependency) string {
+	switch {
+	case d.Version != "":
+		return d.Version
+	case d.Branch != "":
+		return "branch " + d.Branch
+	case len(d.Revision) > 7:
+		return d.Revision[:7]
+	}
+	return d.Revision
+}
+
+// SolveError is returned by Ensure when no version of some project satisfies
+// all the constraints on it.
+type SolveError struct {
+	// Project is the project no version could be selected for, if known.
+	Project string
+	// Explanation tells which constraints excluded each version tried, as dep
+	// ensure prints it, if known.
+	Explanation string
+
+	err error
+}
+
+func (e *SolveError) Error() string { return e.err.Error() }
+
+// Cause returns the error of the solver.
+func (e *SolveError) Cause() error { return e.err }
+
+func newSolveError(err error) *SolveError {
+	serr := &SolveError{err: err}
+	if sf := gps.NewSolveFailure(err); sf != nil {
+		serr.Project = string(sf.Project)
+		var buf bytes.Buffer
+		if sf.WriteTree(&buf) == nil {
+			serr.Explanation = buf.String()
+		}
+	}
+	return serr
+}
+
+// Ensure brings the lock and vendor/ of p in sync with its manifest and
+// imports, as dep ensure does, solving only when they are out of sync or
+// opts updates some projects. It returns how the lock changed. Unlike dep
+// ensure, it doesn't run the hooks of the manifest; those are left to the
+// caller.
+func (p *Project) Ensure(opts EnsureOptions) (*Result, error) {
+	update := opts.UpdateAll || len(opts.Update) > 0
+	if opts.UpdateAll && len(opts.Update) > 0 {
+		return nil, errors.New("cannot update both all the projects and some of them")
+	}
+
+	if err := p.p.CheckGoVersion(); err != nil {
+		return nil, err
+	}
+
+	params := p.p.MakeParams()
+	if p.ctx.Verbose {
+		params.TraceLogger = p.ctx.Err
+	}
+	params.TraceEvents = p.ctx.TraceEvents
+	var err error
+	params.RootPackageTree, err = pkgtree.ListPackages(p.p.ResolvedAbsRoot, string(p.p.ImportRoot))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the packages of the project")
+	}
+	if err := checkPackages(params.RootPackageTree); err != nil {
+		return nil, err
+	}
+
+	sm, err := p.ctx.SourceManager()
+	if err != nil {
+		return nil, err
+	}
+	defer sm.Release()
+
+	if err := p.ctx.ValidateParams(sm, params); err != nil {
+		return nil, err
+	}
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare solver")
+	}
+	inSync := p.p.Lock != nil && bytes.Equal(p.p.Lock.InputHash(), solver.HashInputs())
+
+	if update {
+		if p.p.Lock == nil {
+			return nil, errors.Errorf("updating works on the versions recorded in %s, but it does not exist", p.p.LockName)
+		}
+		if !inSync {
+			return nil, errors.Errorf("%s and %s are out of sync; ensure them without updating first", p.p.ManifestName, p.p.LockName)
+		}
+		params.ChangeAll = opts.UpdateAll
+		for _, root := range opts.Update {
+			if !p.p.Lock.HasProjectWithRoot(gps.ProjectRoot(root)) {
+				return nil, errors.Errorf("%s is not in %s, cannot update it", root, p.p.LockName)
+			}
+			params.ToChange = append(params.ToChange, gps.ProjectRoot(root))
+		}
+		if solver, err = gps.Prepare(params, sm); err != nil {
+			return nil, errors.Wrap(err, "prepare solver")
+		}
+	}
+
+	// As with dep ensure, a lock in sync is kept, but vendor/ is written
+	// from it anew.
+	newLock, vendor := p.p.Lock, dep.VendorAlways
+	if !inSync || update {
+		solution, err := solver.Solve()
+		if err != nil {
+			return nil, newSolveError(err)
+		}
+		newLock, vendor = dep.LockFromSolution(solution), dep.VendorOnChanged
+	}
+	if opts.NoVendor {
+		vendor = dep.VendorNever
+	}
+
+	nl := *newLock
+	nl.Dev = nil
+	if p.p.Manifest.ExcludeTestDeps {
+		dev, err := dep.TestOnlyProjects(params.RootPackageTree, p.p.Manifest, newLock, sm)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not determine dev dependencies")
+		}
+		if len(dev) > 0 {
+			nl.Dev = dev
+		}
+	}
+	newLock = &nl
+
+	sw, err := dep.NewSafeWriter(nil, p.p.Lock, newLock, vendor)
+	if err != nil {
+		return nil, err
+	}
+	sw.UseFileNames(p.p.ManifestName, p.p.LockName)
+	sw.UseVendorDir(p.p.Manifest.VendorDir)
+	sw.ConfigureVendor(p.p.Manifest, newLock, opts.Dev, opts.Verify)
+	sw.ExportWorkers(p.ctx.Workers)
+
+	res := &Result{
+		Dependencies:  dependencies(newLock),
+		Changes:       diffDependencies(p.Dependencies(), dependencies(newLock)),
+		VendorWritten: sw.HasVendor(),
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	if err := sw.Write(p.p.AbsRoot, sm, false, p.ctx.Err); err != nil {
+		return nil, errors.Wrap(err, "grouped write of lock and vendor")
+	}
+
+	// The lock as written holds the digests of vendor/ and the generation.
+	f, err := os.Open(p.p.LockPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if p.p.Lock, err = dep.ReadLock(f); err != nil {
+		return nil, errors.Wrapf(err, "error while parsing %s", p.p.LockPath())
+	}
+	return res, nil
+}
+
+// checkPackages checks that the packages of the root project can be read.
+func checkPackages(ptree pkgtree.PackageTree) error {
+	var errs []string
+	noGo := 0
+	for _, poe := range ptree.Packages {
+		if poe.Err == nil {
+			continue
+		}
+		if _, ok := poe.Err.(*build.NoGoError); ok {
+			noGo++
+		} else {
+			errs = append(errs, poe.Err.Error())
+		}
+	}
+	if len(ptree.Packages) == noGo {
+		return errors.New("all dirs lacked any go code")
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.Errorf("found %d errors in the packages of the project:\n\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// diffDependencies returns the changes from the dependencies in before to
+// those in after, both sorted by project root.
+func diffDependencies(before, after []Dependency) []Change {
+	var changes []Change
+	for len(before) > 0 || len(after) > 0 {
+		switch {
+		case len(after) == 0 || len(before) > 0 && before[0].ProjectRoot < after[0].ProjectRoot:
+			changes = append(changes, Change{ProjectRoot: before[0].ProjectRoot, From: &before[0]})
+			before = before[1:]
+		case len(before) == 0 || after[0].ProjectRoot < before[0].ProjectRoot:
+			changes = append(changes, Change{ProjectRoot: after[0].ProjectRoot, To: &after[0]})
+			after = after[1:]
+		default:
+			if !sameDependency(before[0], after[0]) {
+				changes = append(changes, Change{ProjectRoot: after[0].ProjectRoot, From: &before[0], To: &after[0]})
+			}
+			before, after = before[1:], after[1:]
+		}
+	}
+	return changes
+}
+
+func sameDependency(a, b Dependency) bool {
+	if a.Source != b.Source || a.Version != b.Version || a.Branch != b.Branch || a.Revision != b.Revision || a.Dev != b.Dev {
+		return false
+	}
+	return strings.Join(a.Packages, "\n") == strings.Join(b.Packages, "\n")
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// tokenUsernames are the usernames the hosts expect along with an API token,
+// for git over HTTPS.
+var tokenUsernames = map[string]string{
+	"github.com":    "x-access-token",
+	"gitlab.com":    "oauth2",
+	"bitbucket.org": "x-token-auth",
+}
+
+type rawAuthFile struct {
+	Sources []rawAuthSource `toml:"source"`
+}
+
+type rawAuthSource struct {
+	Host     string `toml:"host"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	Token    string `toml:"token"`
+	SSHKey   string `toml:"ssh-key"`
+}
+
+// ReadAuthFile reads the credentials of the auth file at path, which holds a
+// [[source]] table per host:
+//
+//	[[source]]
+//	  host = "github.com"
+//	  token = "..."
+//	  ssh-key = "~/.ssh/id_work"
+//
+// A token is used as the password, along with the username the host expects,
+// if known. A leading ~ of the SSH key stands for the home directory.
+func ReadAuthFile(path string) ([]gps.Credential, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the auth file")
+	}
+	defer f.Close()
+	creds, err := readAuthFile(f)
+	return creds, errors.Wrapf(err, "invalid auth file %s", path)
+}
+
+func readAuthFile(r io.Reader) ([]gps.Credential, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	var raw rawAuthFile
+	if err := toml.Unmarshal(buf.Bytes(), &raw); err != nil {
+		return nil, err
+	}
+
+	creds := make([]gps.Credential, 0, len(raw.Sources))
+	for _, s := range raw.Sources {
+		if s.Host == "" {
+			return nil, errors.New("a source has no host")
+		}
+		cred := gps.Credential{
+			Host:     s.Host,
+			Username: s.Username,
+			Password: s.Password,
+			SSHKey:   expandHome(s.SSHKey),
+		}
+		if s.Token != "" {
+			if s.Password != "" {
+				return nil, errors.Errorf("both a password and a token are set for %s", s.Host)
+			}
+			tc := TokenCredential(s.Host, s.Token)
+			cred.Password = tc.Password
+			if cred.Username == "" {
+				cred.Username = tc.Username
+			}
+		}
+		if cred.Password != "" && cred.Username == "" {
+			return nil, errors.Errorf("no username is set for %s", s.Host)
+		}
+		creds = append(creds, cred)
+	}
+	return creds, nil
+}
+
+// TokenCredential returns the credential authenticating to host with the given
+// API token, along with the username the host expects.
+func TokenCredential(host, token string) gps.Credential {
+	username, ok := tokenUsernames[strings.ToLower(host)]
+	if !ok {
+		username = "token"
+	}
+	return gps.Credential{Host: host, Username: username, Password: token}
+}
+
+// ReadNetrc reads the credentials of the machines of the netrc file at path,
+// as curl and the go tool do. A missing file holds no credentials.
+func ReadNetrc(path string) ([]gps.Credential, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read netrc")
+	}
+	defer f.Close()
+	return readNetrc(f)
+}
+
+func readNetrc(r io.Reader) ([]gps.Credential, error) {
+	var creds []gps.Credential
+	var cur *gps.Credential
+	sc := bufio.NewScanner(r)
+	sc.Split(bufio.ScanWords)
+	for sc.Scan() {
+		switch word := sc.Text(); word {
+		case "machine":
+			if !sc.Scan() {
+				return creds, nil
+			}
+			creds = append(creds, gps.Credential{Host: sc.Text()})
+			cur = &creds[len(creds)-1]
+		case "default":
+			// The default entry holds for all other hosts, which aren't
+			// given credentials blindly.
+			cur = nil
+		case "login", "password", "account":
+			if !sc.Scan() {
+				return creds, nil
+			}
+			if cur == nil {
+				continue
+			}
+			if word == "login" {
+				cur.Username = sc.Text()
+			} else if word == "password" {
+				cur.Password = sc.Text()
+			}
+		case "macdef":
+			// Macros run up to the next empty line, which the scanner
+			// doesn't see, so the rest of the file is ignored.
+			return creds, sc.Err()
+		}
+	}
+	return creds, errors.Wrap(sc.Err(), "failed to read netrc")
+}
+
+// MergeCredentials merges the lists of credentials, the first one given for a
+// host taking precedence over the others.
+func MergeCredentials(lists ...[]gps.Credential) []gps.Credential {
+	var merged []gps.Credential
+	seen := make(map[string]bool)
+	for _, l := range lists {
+		for _, cred := range l {
+			host := strings.ToLower(cred.Host)
+			if seen[host] {
+				continue
+			}
+			seen[host] = true
+			merged = append(merged, cred)
+		}
+	}
+	return merged
+}
+
+// expandHome replaces the leading ~ of path with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.Getenv("USERPROFILE")
+	}
+	if home == "" {
+		return path
+	}
+	return filepath.Join(home, filepath.FromSlash(strings.TrimPrefix(path, "~")))
+}
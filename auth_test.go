@@ -0,0 +1,105 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestReadAuthFile(t *testing.T) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		t.Skip("HOME is not set")
+	}
+
+	const file = `
+[[source]]
+  host = "github.com"
+  token = "ghtoken"
+
+[[source]]
+  host = "git.example.com"
+  username = "me"
+  password = "pass"
+  ssh-key = "~/.ssh/id_example"
+`
+	got, err := readAuthFile(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gps.Credential{
+		{Host: "github.com", Username: "x-access-token", Password: "ghtoken"},
+		{Host: "git.example.com", Username: "me", Password: "pass", SSHKey: filepath.Join(home, ".ssh", "id_example")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected credentials:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
+func TestReadAuthFileInvalid(t *testing.T) {
+	cases := map[string]string{
+		"no host":            "[[source]]\n  token = \"t\"\n",
+		"password and token": "[[source]]\n  host = \"github.com\"\n  password = \"p\"\n  token = \"t\"\n",
+		"no username":        "[[source]]\n  host = \"git.example.com\"\n  password = \"p\"\n",
+		"not toml":           "[[source\n",
+	}
+	for name, file := range cases {
+		if _, err := readAuthFile(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadNetrc(t *testing.T) {
+	const netrc = `machine github.com login me password ghpass
+machine git.example.com
+  login other
+  account ignored
+  password "quoted"
+default login anonymous password guest
+machine gitlab.com login you password glpass
+`
+	got, err := readNetrc(strings.NewReader(netrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gps.Credential{
+		{Host: "github.com", Username: "me", Password: "ghpass"},
+		{Host: "git.example.com", Username: "other", Password: `"quoted"`},
+		{Host: "gitlab.com", Username: "you", Password: "glpass"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected credentials:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
+
+func TestReadNetrcMissing(t *testing.T) {
+	creds, err := ReadNetrc(filepath.Join(os.TempDir(), "dep-no-such-netrc"))
+	if err != nil || creds != nil {
+		t.Fatalf("expected no credentials nor error, got %v, %v", creds, err)
+	}
+}
+
+func TestMergeCredentials(t *testing.T) {
+	got := MergeCredentials(
+		[]gps.Credential{{Host: "github.com", Password: "file"}},
+		[]gps.Credential{TokenCredential("GitHub.com", "env"), TokenCredential("gitlab.com", "env")},
+		[]gps.Credential{{Host: "gitlab.com", Password: "netrc"}, {Host: "example.com", Password: "netrc"}},
+	)
+	want := []gps.Credential{
+		{Host: "github.com", Password: "file"},
+		{Host: "gitlab.com", Username: "oauth2", Password: "env"},
+		{Host: "example.com", Password: "netrc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected credentials:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
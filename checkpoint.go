@@ -0,0 +1,122 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// solveCheckpointPath returns the path of the checkpoint of a solve with the
+// given inputs digest. Checkpoints are kept in the cache directory, as they
+// save fetching sources again.
+func (c *Ctx) solveCheckpointPath(digest []byte) string {
+	return filepath.Join(c.CachePath(), "checkpoints", hex.EncodeToString(digest)+".lock")
+}
+
+// SaveSolveCheckpoint records the projects selected by a failed solve with the
+// given inputs digest, as returned by gps.Solver.Checkpoint(). Nothing is
+// recorded if no project was selected.
+func (c *Ctx) SaveSolveCheckpoint(digest []byte, lps []gps.LockedProject) error {
+	if len(lps) == 0 {
+		return nil
+	}
+
+	l := &Lock{
+		SolveMeta: SolveMeta{InputsDigest: digest},
+		P:         lps,
+	}
+	b, err := l.MarshalTOML()
+	if err != nil {
+		return err
+	}
+
+	p := c.solveCheckpointPath(digest)
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return errors.Wrap(err, "failed to save solve checkpoint")
+	}
+	return errors.Wrap(ioutil.WriteFile(p, b, 0666), "failed to save solve checkpoint")
+}
+
+// LoadSolveCheckpoint returns the projects recorded by SaveSolveCheckpoint for
+// a solve with the given inputs digest, or nil if there are none.
+func (c *Ctx) LoadSolveCheckpoint(digest []byte) ([]gps.LockedProject, error) {
+	f, err := os.Open(c.solveCheckpointPath(digest))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load solve checkpoint")
+	}
+	defer f.Close()
+
+	l, err := readLock(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load solve checkpoint")
+	}
+	if !bytes.Equal(l.SolveMeta.InputsDigest, digest) {
+		return nil, nil
+	}
+	return l.P, nil
+}
+
+// ClearSolveCheckpoint removes the checkpoint of a solve with the given inputs
+// digest, if any.
+func (c *Ctx) ClearSolveCheckpoint(digest []byte) error {
+	err := os.Remove(c.solveCheckpointPath(digest))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove solve checkpoint")
+	}
+	return nil
+}
+
+// ResumeFromCheckpoint sets up params for the solver to start from the
+// projects of a checkpoint: they take precedence over those of params.Lock,
+// and are excluded from the projects allowed to change, so that their
+// checkpointed versions are tried first.
+func ResumeFromCheckpoint(params *gps.SolveParameters, cp []gps.LockedProject) {
+	if len(cp) == 0 {
+		return
+	}
+
+	inCheckpoint := make(map[gps.ProjectRoot]bool, len(cp))
+	lps := make([]gps.LockedProject, 0, len(cp))
+	for _, lp := range cp {
+		inCheckpoint[lp.Ident().ProjectRoot] = true
+		lps = append(lps, lp)
+	}
+	if params.Lock != nil {
+		for _, lp := range params.Lock.Projects() {
+			if !inCheckpoint[lp.Ident().ProjectRoot] {
+				lps = append(lps, lp)
+			}
+		}
+	}
+
+	// ChangeAll ignores the lock altogether, so spell out the projects it
+	// covers instead.
+	toChange := params.ToChange
+	if params.ChangeAll {
+		params.ChangeAll = false
+		toChange = nil
+		for _, lp := range lps {
+			toChange = append(toChange, lp.Ident().ProjectRoot)
+		}
+	}
+
+	params.ToChange = nil
+	for _, pr := range toChange {
+		if !inCheckpoint[pr] {
+			params.ToChange = append(params.ToChange, pr)
+		}
+	}
+	params.Lock = gps.SimpleLock(lps)
+}
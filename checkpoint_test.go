@@ -0,0 +1,90 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSolveCheckpoint(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("gopath")
+	ctx := &Ctx{GOPATH: h.Path("gopath")}
+	digest := []byte{0xca, 0xfe}
+
+	cp, err := ctx.LoadSolveCheckpoint(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp != nil {
+		t.Fatalf("expected no checkpoint, got %v", cp)
+	}
+
+	want := []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc123"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewBranch("master").Pair("def456"), []string{"qux"}),
+	}
+	if err := ctx.SaveSolveCheckpoint(digest, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if cp, err = ctx.LoadSolveCheckpoint(digest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cp, want) {
+		t.Errorf("unexpected checkpoint:\n\t(GOT): %v\n\t(WNT): %v", cp, want)
+	}
+	if cp, _ = ctx.LoadSolveCheckpoint([]byte{0xbe, 0xef}); cp != nil {
+		t.Errorf("expected no checkpoint for other inputs, got %v", cp)
+	}
+
+	if err := ctx.ClearSolveCheckpoint(digest); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ = ctx.LoadSolveCheckpoint(digest); cp != nil {
+		t.Errorf("expected checkpoint to be cleared, got %v", cp)
+	}
+	if err := ctx.ClearSolveCheckpoint(digest); err != nil {
+		t.Errorf("expected clearing a missing checkpoint to succeed, got %s", err)
+	}
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	lp := func(root, version string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, gps.NewVersion(version).Pair(gps.Revision(root+version)), []string{"."})
+	}
+	cp := []gps.LockedProject{lp("a", "v2.0.0"), lp("c", "v1.0.0")}
+
+	params := gps.SolveParameters{
+		Lock:      gps.SimpleLock{lp("a", "v1.0.0"), lp("b", "v1.0.0")},
+		ChangeAll: true,
+	}
+	ResumeFromCheckpoint(&params, cp)
+
+	wantLock := gps.SimpleLock{lp("a", "v2.0.0"), lp("c", "v1.0.0"), lp("b", "v1.0.0")}
+	if !reflect.DeepEqual(params.Lock, wantLock) {
+		t.Errorf("unexpected lock:\n\t(GOT): %v\n\t(WNT): %v", params.Lock, wantLock)
+	}
+	if params.ChangeAll {
+		t.Error("expected ChangeAll to be turned into an explicit list")
+	}
+	if want := []gps.ProjectRoot{"b"}; !reflect.DeepEqual(params.ToChange, want) {
+		t.Errorf("expected %v to be allowed to change, got %v", want, params.ToChange)
+	}
+
+	params = gps.SolveParameters{
+		ToChange: []gps.ProjectRoot{"a", "b"},
+	}
+	ResumeFromCheckpoint(&params, cp)
+	if want := []gps.ProjectRoot{"b"}; !reflect.DeepEqual(params.ToChange, want) {
+		t.Errorf("expected %v to be allowed to change, got %v", want, params.ToChange)
+	}
+}
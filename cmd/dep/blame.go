@@ -0,0 +1,162 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+// Attribution tells who last changed an entry of the manifest or lock, and in
+// which commit.
+type Attribution struct {
+	Commit string
+	Author string
+	Time   time.Time
+}
+
+// uncommitted reports whether the change is yet to be committed.
+func (a *Attribution) uncommitted() bool {
+	return strings.Trim(a.Commit, "0") == ""
+}
+
+func (a *Attribution) String() string {
+	if a == nil {
+		return ""
+	}
+	if a.uncommitted() {
+		return "uncommitted"
+	}
+	commit := a.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return commit + " " + a.Author
+}
+
+// blamedLine is a line of a file, along with the last change to it.
+type blamedLine struct {
+	Attribution
+	Text string
+}
+
+// entryBlame holds the last changes to the entries of the manifest and lock of
+// a project, by project root.
+type entryBlame struct {
+	constraints map[string]*Attribution
+	overrides   map[string]*Attribution
+	projects    map[string]*Attribution
+}
+
+// blameEntries runs git blame over the manifest and lock of p, to tell who last
+// changed each of their entries.
+func blameEntries(p *dep.Project) (*entryBlame, error) {
+	ml, err := gitBlame(p.AbsRoot, filepath.Base(p.ManifestPath()))
+	if err != nil {
+		return nil, err
+	}
+	ll, err := gitBlame(p.AbsRoot, filepath.Base(p.LockPath()))
+	if err != nil {
+		return nil, err
+	}
+	return &entryBlame{
+		constraints: blameTable(ml, "constraint"),
+		overrides:   blameTable(ml, "override"),
+		projects:    blameTable(ll, "projects"),
+	}, nil
+}
+
+// gitBlame returns the lines of the named file of the git repository at dir,
+// along with the last change to each of them.
+func gitBlame(dir, name string) ([]blamedLine, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "blame", "--line-porcelain", "--", name)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("git blame of %s failed: %s", name, msg)
+		}
+		return nil, errors.Wrapf(err, "git blame of %s failed", name)
+	}
+	lines, err := parseBlame(&stdout)
+	return lines, errors.Wrapf(err, "could not parse the git blame of %s", name)
+}
+
+// parseBlame parses the output of git blame --line-porcelain, in which each
+// line of the file follows a header naming the commit which last changed it,
+// and lines giving the details of the commit.
+func parseBlame(r io.Reader) ([]blamedLine, error) {
+	var lines []blamedLine
+	var cur blamedLine
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			cur.Text = line[1:]
+			lines = append(lines, cur)
+			cur = blamedLine{}
+		case cur.Commit == "":
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, errors.Errorf("unexpected header %q", line)
+			}
+			cur.Commit = fields[0]
+		case strings.HasPrefix(line, "author "):
+			cur.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			sec, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("unexpected author time %q", line)
+			}
+			cur.Time = time.Unix(sec, 0)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// blameTable returns the last change to each entry of the array of tables of
+// the given name, by the name of the entry: that of the most recently changed
+// line of the entry, including its subtables.
+func blameTable(lines []blamedLine, table string) map[string]*Attribution {
+	entries := make(map[string]*Attribution)
+	header := "[[" + table + "]]"
+	var in bool
+	var name string
+	var last *Attribution
+	flush := func() {
+		if in && name != "" && last != nil {
+			entries[name] = last
+		}
+	}
+	for i := range lines {
+		text := strings.TrimSpace(lines[i].Text)
+		if strings.HasPrefix(text, "[") && !(in && strings.HasPrefix(strings.TrimLeft(text, "["), table+".")) {
+			flush()
+			in, name, last = strings.HasPrefix(text, header), "", nil
+		}
+		if !in || text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if eq := strings.Index(text, "="); name == "" && eq > 0 && strings.TrimSpace(text[:eq]) == "name" {
+			name, _ = strconv.Unquote(strings.TrimSpace(text[eq+1:]))
+		}
+		if last == nil || lines[i].Time.After(last.Time) {
+			last = &lines[i].Attribution
+		}
+	}
+	flush()
+	return entries
+}
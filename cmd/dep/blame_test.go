@@ -0,0 +1,118 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBlame = `1111111111111111111111111111111111111111 1 1 4
+author Alice
+author-mail <alice@example.com>
+author-time 1500000000
+author-tz +0000
+summary Add foo
+filename Gopkg.toml
+	[[constraint]]
+1111111111111111111111111111111111111111 2 2
+author Alice
+author-mail <alice@example.com>
+author-time 1500000000
+author-tz +0000
+summary Add foo
+filename Gopkg.toml
+	  name = "github.com/foo/foo"
+2222222222222222222222222222222222222222 3 3 1
+author Bob
+author-mail <bob@example.com>
+author-time 1510000000
+author-tz +0000
+summary Bump foo
+filename Gopkg.toml
+	  version = "1.2.0"
+1111111111111111111111111111111111111111 4 4
+author Alice
+author-mail <alice@example.com>
+author-time 1500000000
+author-tz +0000
+summary Add foo
+filename Gopkg.toml
+	
+0000000000000000000000000000000000000000 5 5 4
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1520000000
+author-tz +0000
+summary Version of Gopkg.toml from Gopkg.toml
+filename Gopkg.toml
+	[[override]]
+0000000000000000000000000000000000000000 6 6
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1520000000
+author-tz +0000
+summary Version of Gopkg.toml from Gopkg.toml
+filename Gopkg.toml
+	  name = "github.com/foo/bar"
+0000000000000000000000000000000000000000 7 7
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1520000000
+author-tz +0000
+summary Version of Gopkg.toml from Gopkg.toml
+filename Gopkg.toml
+	  branch = "master"
+1111111111111111111111111111111111111111 8 8
+author Alice
+author-mail <alice@example.com>
+author-time 1500000000
+author-tz +0000
+summary Add foo
+filename Gopkg.toml
+	[prune]
+`
+
+func TestParseBlame(t *testing.T) {
+	lines, err := parseBlame(strings.NewReader(testBlame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 lines, got %d", len(lines))
+	}
+	l := lines[2]
+	if l.Commit != "2222222222222222222222222222222222222222" || l.Author != "Bob" || !l.Time.Equal(time.Unix(1510000000, 0)) || l.Text != `  version = "1.2.0"` {
+		t.Errorf("unexpected line: %+v", l)
+	}
+}
+
+func TestBlameTable(t *testing.T) {
+	lines, err := parseBlame(strings.NewReader(testBlame))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraints := blameTable(lines, "constraint")
+	if len(constraints) != 1 {
+		t.Fatalf("expected a single constraint, got %v", constraints)
+	}
+	if got := constraints["github.com/foo/foo"].String(); got != "2222222 Bob" {
+		t.Errorf("expected the constraint to be last changed by Bob, got %q", got)
+	}
+
+	overrides := blameTable(lines, "override")
+	if len(overrides) != 1 {
+		t.Fatalf("expected a single override, got %v", overrides)
+	}
+	if got := overrides["github.com/foo/bar"].String(); got != "uncommitted" {
+		t.Errorf("expected the override to be uncommitted, got %q", got)
+	}
+
+	if got := (*Attribution)(nil).String(); got != "" {
+		t.Errorf("expected no attribution to be blank, got %q", got)
+	}
+}
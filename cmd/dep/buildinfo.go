@@ -0,0 +1,157 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const buildinfoShortHelp = `Embed the versions of Gopkg.lock into built binaries`
+const buildinfoLongHelp = `
+Print the linker flags, or write a Go package, recording the versions and
+revisions of the projects of Gopkg.lock, so that binaries can report exactly
+which dependencies they were built with.
+
+  dep buildinfo -ldflags [-var <package path>.<name>]
+  dep buildinfo [-package <name>] [-o <file>]
+
+With -ldflags, the -X flag setting the given string variable, main.dependencies
+by default, to the projects of the lock is printed, for go build:
+
+  go build -ldflags "$(dep buildinfo -ldflags)"
+
+The projects are sorted by name and separated by spaces, each as
+name@version:revision, or name@revision when locked to a bare revision. The
+version is that of the branch for projects locked to a branch.
+
+Otherwise, the source of a package declaring the projects of the lock as
+Dependencies is printed, or written to the file given with -o. It's in package
+main, unless another name is given with -package.
+`
+
+func (cmd *buildinfoCommand) Name() string { return "buildinfo" }
+func (cmd *buildinfoCommand) Args() string {
+	return "-ldflags [-var <name>] | [-package <name>] [-o <file>]"
+}
+func (cmd *buildinfoCommand) ShortHelp() string { return buildinfoShortHelp }
+func (cmd *buildinfoCommand) LongHelp() string  { return buildinfoLongHelp }
+func (cmd *buildinfoCommand) Hidden() bool      { return false }
+
+func (cmd *buildinfoCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.ldflags, "ldflags", false, "print the linker flags setting a variable to the versions of the dependencies")
+	fs.StringVar(&cmd.variable, "var", "main.dependencies", "with -ldflags, the string variable to set")
+	fs.StringVar(&cmd.pkg, "package", "main", "the name of the package to generate")
+	fs.StringVar(&cmd.output, "o", "", "write the package to the given file")
+}
+
+type buildinfoCommand struct {
+	ldflags  bool
+	variable string
+	pkg      string
+	output   string
+}
+
+func (cmd *buildinfoCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep buildinfo takes no arguments")
+	}
+	if cmd.ldflags && cmd.output != "" {
+		return errors.New("-ldflags prints the linker flags; cannot pass it together with -o")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to record the versions of", dep.LockName)
+	}
+
+	if cmd.ldflags {
+		if !strings.Contains(cmd.variable, ".") {
+			return errors.Errorf("invalid variable %q, it must be qualified by the path of its package, as in main.dependencies", cmd.variable)
+		}
+		ctx.Out.Println(buildinfoLdflags(cmd.variable, p.Lock))
+		return nil
+	}
+
+	out, err := buildinfoPackage(cmd.pkg, p.Lock)
+	if err != nil {
+		return err
+	}
+
+	if cmd.output == "" {
+		ctx.Out.Print(string(out))
+		return nil
+	}
+	return errors.Wrapf(ioutil.WriteFile(cmd.output, out, 0666), "failed to write %s", cmd.output)
+}
+
+// buildinfoLdflags returns the -X linker flag setting the string variable v to
+// the versions of the projects of l.
+func buildinfoLdflags(v string, l *dep.Lock) string {
+	projects := generateProjects(l)
+	entries := make([]string, 0, len(projects))
+	for _, gp := range projects {
+		version := gp.Version
+		if version == "" {
+			version = gp.Branch
+		}
+		entry := gp.Name + "@"
+		if version != "" {
+			entry += version + ":"
+		}
+		entries = append(entries, entry+gp.Revision)
+	}
+	// go build splits the linker flags on spaces, unless quoted.
+	return fmt.Sprintf("-X '%s=%s'", v, strings.Join(entries, " "))
+}
+
+// buildinfoPackage returns the source of the package of the given name
+// declaring the projects of l.
+func buildinfoPackage(name string, l *dep.Lock) ([]byte, error) {
+	var buf bytes.Buffer
+	data := buildinfoData{Package: name, Projects: generateProjects(l)}
+	if err := buildinfoTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "failed to execute the template")
+	}
+	out, err := format.Source(buf.Bytes())
+	return out, errors.Wrapf(err, "invalid package name %q", name)
+}
+
+// buildinfoData is the data the package of dep buildinfo is rendered with.
+type buildinfoData struct {
+	Package  string
+	Projects []generateProject
+}
+
+var buildinfoTemplate = template.Must(template.New("buildinfo").Parse(`// Code generated by dep buildinfo; DO NOT EDIT.
+
+package {{.Package}}
+
+// Dependency is a project the program was built with, as locked in Gopkg.lock.
+type Dependency struct {
+	Name     string
+	Version  string
+	Branch   string
+	Revision string
+}
+
+// Dependencies are the projects the program was built with, sorted by name.
+var Dependencies = []Dependency{
+{{- range .Projects}}
+	{Name: {{printf "%q" .Name}}, Version: {{printf "%q" .Version}}, Branch: {{printf "%q" .Branch}}, Revision: {{printf "%q" .Revision}}},
+{{- end}}
+}
+`))
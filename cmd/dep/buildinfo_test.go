@@ -0,0 +1,63 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+var buildinfoLock = &dep.Lock{
+	P: []gps.LockedProject{
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+			gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+			[]string{"."},
+		),
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "github.com/Masterminds/semver"},
+			gps.NewBranch("2.x").Pair("94ad6eaf8457cf85a68c9b53fa42e9b1b8683783"),
+			[]string{"."},
+		),
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "github.com/Masterminds/vcs"},
+			gps.Revision("3084677c2c188840777bff30054f2b553729d329"),
+			[]string{"."},
+		),
+	},
+}
+
+func TestBuildinfoLdflags(t *testing.T) {
+	want := "-X 'main.dependencies=" +
+		"github.com/Masterminds/semver@2.x:94ad6eaf8457cf85a68c9b53fa42e9b1b8683783 " +
+		"github.com/Masterminds/vcs@3084677c2c188840777bff30054f2b553729d329 " +
+		"github.com/pkg/errors@v0.8.0:645ef00459ed84a119197bfb8d8205042c6df63d'"
+	if got := buildinfoLdflags("main.dependencies", buildinfoLock); got != want {
+		t.Errorf("unexpected flags:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+}
+
+func TestBuildinfoPackage(t *testing.T) {
+	out, err := buildinfoPackage("version", buildinfoLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package version\n",
+		`{Name: "github.com/Masterminds/semver", Version: "", Branch: "2.x", Revision: "94ad6eaf8457cf85a68c9b53fa42e9b1b8683783"},`,
+		`{Name: "github.com/pkg/errors", Version: "v0.8.0", Branch: "", Revision: "645ef00459ed84a119197bfb8d8205042c6df63d"},`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected the package to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if _, err := buildinfoPackage("not a name", buildinfoLock); err == nil {
+		t.Error("expected an invalid package name to be refused")
+	}
+}
@@ -0,0 +1,516 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheShortHelp = `Inspect, clean and repair the cache of sources`
+const cacheLongHelp = `
+Inspect the cache directory where dep keeps the sources it fetches, which is
+shared by all the dep processes of the machine, or of several machines when
+DEPSOURCESDIR points to a network filesystem.
+
+  dep cache list [-json]
+  dep cache clean [-unused <duration>] [-dry-run] [source...]
+  dep cache verify [-repair]
+  dep cache path [-sources]
+  dep cache locks [-clean]
+
+The list subcommand shows the sources of the cache, with the space each one
+takes on disk, when it was last fetched, and when a lock last pinned a project
+to it. Locks are recorded by dep init and dep ensure, in the cache itself, so
+that sources shared by several projects are accounted for. With -json, the
+sources are written as JSON instead.
+
+The clean subcommand removes the given sources from the cache, named by their
+URL, their import path, or their name in the list. With -unused, it removes
+the sources neither fetched nor locked within the given duration, such as 720h,
+instead. The removed sources are fetched anew when next needed. With -dry-run,
+the sources are only listed. A source in use by another process is removed
+once that process is done with it.
+
+The path subcommand prints the path of the cache, or with -sources, that of
+the directory holding the sources, which DEPSOURCESDIR may move elsewhere.
+
+Each source of the cache is locked while a process works on it, so that
+processes working on different sources don't wait for each other. The locks
+subcommand lists the locks currently held or waited for, with the process
+holding each one and the processes waiting for it, which helps telling why a
+run seems stuck. Holders running on other machines are shown with their host.
+
+A lock whose holder died without releasing it is reported as stale, and is
+taken over by the next process needing the source. With -clean, stale locks
+are removed right away instead. So is one whose holder died and whose pid
+went to another process since, as often happens in containers, on systems
+where the start times of processes can be told.
+
+Processes waiting for a lock tell which process holds it after a second, and
+keep waiting for as long as it takes. Set DEPLOCKTIMEOUT to a duration, such
+as 10m, to have them give up after waiting for that long instead.
+
+The verify subcommand checks the sources of the cache for corruption, as left
+behind by interrupted clones and downloads, which otherwise shows as baffling
+solve errors. Git sources are checked with git fsck, hg ones with hg verify,
+and bzr ones with bzr check. With -repair, the corrupted sources are removed,
+and fetched anew when their upstream can be told.
+`
+
+const cacheUsage = "list [-json] | clean [-unused <duration>] [-dry-run] [source...] | verify [-repair] | path [-sources] | locks [-clean]"
+
+func (cmd *cacheCommand) Name() string      { return "cache" }
+func (cmd *cacheCommand) Args() string      { return cacheUsage }
+func (cmd *cacheCommand) ShortHelp() string { return cacheShortHelp }
+func (cmd *cacheCommand) LongHelp() string  { return cacheLongHelp }
+func (cmd *cacheCommand) Hidden() bool      { return false }
+
+func (cmd *cacheCommand) Register(fs *flag.FlagSet) {
+	cmd.registerList(fs)
+	cmd.registerClean(fs)
+	cmd.registerVerify(fs)
+	cmd.registerPath(fs)
+	cmd.registerLocks(fs)
+}
+
+func (cmd *cacheCommand) registerList(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "write the sources as JSON")
+}
+
+func (cmd *cacheCommand) registerClean(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.unused, "unused", "", "remove the sources neither fetched nor locked within this duration")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only list the sources which would be removed")
+}
+
+func (cmd *cacheCommand) registerPath(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.sourcesOnly, "sources", false, "print the path of the directory holding the sources")
+}
+
+func (cmd *cacheCommand) registerLocks(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.clean, "clean", false, "remove the locks left behind by processes which died")
+}
+
+func (cmd *cacheCommand) registerVerify(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.repair, "repair", false, "remove the corrupted sources, and fetch them anew")
+}
+
+type cacheCommand struct {
+	json        bool
+	unused      string
+	dryRun      bool
+	sources     []string
+	sourcesOnly bool
+	clean       bool
+	repair      bool
+}
+
+func (cmd *cacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dep cache " + cacheUsage)
+	}
+
+	// Outside of projects, the cache is that of the first GOPATH.
+	if ctx.GOPATH == "" {
+		if _, err := ctx.LoadProject(); err != nil && len(ctx.GOPATHs) > 0 {
+			ctx.GOPATH = ctx.GOPATHs[0]
+		}
+	}
+
+	// The flags of the subcommands follow them.
+	fs := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	var run func(*dep.Ctx) error
+	switch args[0] {
+	case "list":
+		cmd.registerList(fs)
+		run = cmd.runList
+	case "clean":
+		cmd.registerClean(fs)
+		run = cmd.runClean
+	case "path":
+		cmd.registerPath(fs)
+		run = cmd.runPath
+	case "locks":
+		cmd.registerLocks(fs)
+		run = cmd.runLocks
+	case "verify":
+		cmd.registerVerify(fs)
+		run = cmd.runVerify
+	default:
+		return errors.Errorf("unknown subcommand %q: usage: dep cache %s", args[0], cacheUsage)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	// Only clean takes arguments, the sources to remove.
+	cmd.sources = fs.Args()
+	if fs.NArg() > 0 && args[0] != "clean" {
+		return errors.Errorf("dep cache %s takes no arguments", args[0])
+	}
+	return run(ctx)
+}
+
+func (cmd *cacheCommand) runList(ctx *dep.Ctx) error {
+	srcs, err := listCachedSources(ctx)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if cmd.json {
+		if err := writeCachedSourcesJSON(&buf, srcs); err != nil {
+			return err
+		}
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+	if len(srcs) == 0 {
+		ctx.Out.Println("No source is cached.")
+		return nil
+	}
+
+	writeCachedSources(&buf, srcs, time.Now())
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+func (cmd *cacheCommand) runClean(ctx *dep.Ctx) error {
+	if (len(cmd.sources) == 0) == (cmd.unused == "") {
+		return errors.New("dep cache clean takes either the sources to remove or -unused <duration>")
+	}
+	var unused time.Duration
+	if cmd.unused != "" {
+		var err error
+		if unused, err = time.ParseDuration(cmd.unused); err != nil || unused <= 0 {
+			return errors.Errorf("invalid -unused value %q: expected a positive duration, such as 720h", cmd.unused)
+		}
+	}
+
+	srcs, err := listCachedSources(ctx)
+	if err != nil {
+		return err
+	}
+	var remove []cachedSource
+	if unused != 0 {
+		remove = unusedCachedSources(srcs, time.Now().Add(-unused))
+	} else if remove, err = matchCachedSources(srcs, cmd.sources); err != nil {
+		return err
+	}
+	if len(remove) == 0 {
+		ctx.Out.Println("No source to remove.")
+		return nil
+	}
+
+	var freed int64
+	for _, cs := range remove {
+		if cmd.dryRun {
+			ctx.Out.Printf("Would remove %s (%s)\n", cs.label(), formatBytes(cs.Size))
+			freed += cs.Size
+			continue
+		}
+		if err := gps.RemoveCachedSource(context.Background(), ctx.SourcesPath(), cs.Name); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", cs.label())
+		}
+		ctx.Out.Printf("Removed %s (%s)\n", cs.label(), formatBytes(cs.Size))
+		freed += cs.Size
+	}
+	if cmd.dryRun {
+		ctx.Out.Printf("Would free %s\n", formatBytes(freed))
+	} else {
+		ctx.Out.Printf("Freed %s\n", formatBytes(freed))
+	}
+	return nil
+}
+
+func (cmd *cacheCommand) runPath(ctx *dep.Ctx) error {
+	if cmd.sourcesOnly {
+		ctx.Out.Println(ctx.SourcesPath())
+	} else {
+		ctx.Out.Println(ctx.CachePath())
+	}
+	return nil
+}
+
+func (cmd *cacheCommand) runLocks(ctx *dep.Ctx) error {
+	dir := ctx.SourcesPath()
+	if cmd.clean {
+		removed, err := gps.RemoveStaleCacheLocks(dir)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove stale locks")
+		}
+		for _, name := range removed {
+			ctx.Err.Printf("Removed the stale lock of %s", name)
+		}
+	}
+
+	locks, err := gps.CacheLocks(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the locks")
+	}
+	if len(locks) == 0 {
+		ctx.Out.Println("No source is locked.")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	writeCacheLocks(&buf, locks, time.Now())
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+func (cmd *cacheCommand) runVerify(ctx *dep.Ctx) error {
+	problems, err := gps.VerifyCache(context.Background(), ctx.CachePath(), ctx.SourcesPath(), cmd.repair)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify the cache")
+	}
+	if len(problems) == 0 {
+		ctx.Out.Println("No corruption found in the cache.")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	writeCacheProblems(&buf, problems)
+	ctx.Out.Print(buf.String())
+	if !cmd.repair {
+		return errors.Errorf("found %d corrupted entries in the cache, run dep cache verify -repair to remove them", len(problems))
+	}
+
+	// Fetch the removed sources anew, rather than leaving it to the next run
+	// needing them.
+	var sources []string
+	for _, p := range problems {
+		if p.Removed && p.Source != "" {
+			sources = append(sources, p.Source)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+	for _, source := range sources {
+		if err := sm.SyncSourceFor(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(source), Source: source}); err != nil {
+			ctx.Err.Printf("Warning: failed to fetch %s anew: %s", source, err)
+			continue
+		}
+		ctx.Err.Printf("Fetched %s anew", source)
+	}
+	return nil
+}
+
+// cachedSource is a source of the cache, as listed by dep cache list.
+type cachedSource struct {
+	Name   string
+	Source string `json:",omitempty"`
+	Size   int64
+	// Updated is the last time the source was fetched.
+	Updated time.Time
+	// Locked is the last time a lock was recorded pinning a project to the
+	// source, if ever.
+	Locked *time.Time `json:",omitempty"`
+}
+
+// label returns the URL of the source, or its name if it can't be told.
+func (cs cachedSource) label() string {
+	if cs.Source != "" {
+		return cs.Source
+	}
+	return cs.Name
+}
+
+// listCachedSources returns the sources of the cache, along with the last
+// time each one was locked.
+func listCachedSources(ctx *dep.Ctx) ([]cachedSource, error) {
+	gsrcs, err := gps.ListCachedSources(context.Background(), ctx.SourcesPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the cached sources")
+	}
+	history, err := ctx.LockSourcesHistory(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[string]time.Time)
+	for _, ls := range history {
+		for _, name := range ls.Sources {
+			if ls.Time.After(locked[name]) {
+				locked[name] = ls.Time
+			}
+		}
+	}
+
+	srcs := make([]cachedSource, len(gsrcs))
+	for i, gs := range gsrcs {
+		srcs[i] = cachedSource{Name: gs.Name, Source: gs.Source, Size: gs.Size, Updated: gs.Updated}
+		if t, has := locked[gs.Name]; has {
+			srcs[i].Locked = &t
+		}
+	}
+	return srcs, nil
+}
+
+// unusedCachedSources returns the sources neither fetched nor locked since
+// cutoff.
+func unusedCachedSources(srcs []cachedSource, cutoff time.Time) []cachedSource {
+	var unused []cachedSource
+	for _, cs := range srcs {
+		if cs.Updated.After(cutoff) || (cs.Locked != nil && cs.Locked.After(cutoff)) {
+			continue
+		}
+		unused = append(unused, cs)
+	}
+	return unused
+}
+
+// matchCachedSources returns the sources named by args, by their URL, their
+// import path or their name in the cache. Each argument must name a source.
+func matchCachedSources(srcs []cachedSource, args []string) ([]cachedSource, error) {
+	var matched []cachedSource
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		found := false
+		for _, cs := range srcs {
+			if arg != cs.Name && arg != cs.Source {
+				if ip, err := sourceImportPath(cs.Source); err != nil || strings.TrimSuffix(arg, "/") != ip {
+					continue
+				}
+			}
+			found = true
+			if !seen[cs.Name] {
+				seen[cs.Name] = true
+				matched = append(matched, cs)
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("no cached source matches %s; run dep cache list to see them", arg)
+		}
+	}
+	return matched, nil
+}
+
+func writeCachedSources(w io.Writer, srcs []cachedSource, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSIZE\tFETCHED\tLOCKED\t")
+	var total int64
+	for _, cs := range srcs {
+		locked := "never"
+		if cs.Locked != nil {
+			locked = formatAge(now.Sub(*cs.Locked))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", cs.label(), formatBytes(cs.Size), formatAge(now.Sub(cs.Updated)), locked)
+		total += cs.Size
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d source(s), %s in total\n", len(srcs), formatBytes(total))
+}
+
+func writeCachedSourcesJSON(w io.Writer, srcs []cachedSource) error {
+	if srcs == nil {
+		srcs = []cachedSource{}
+	}
+	b, err := json.MarshalIndent(srcs, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the cached sources")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// formatAge formats the time elapsed since an event, in the largest unit it
+// spans.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dd ago", d/(24*time.Hour))
+	}
+}
+
+// recordLockSources records the sources the projects of the lock of p are
+// fetched from, as known to sm, for dep cache to tell the sources still in
+// use. Failing to do so only warrants a warning.
+func recordLockSources(ctx *dep.Ctx, p *dep.Project, sm *gps.SourceMgr) {
+	f, err := os.Open(p.LockPath())
+	if err != nil {
+		return
+	}
+	l, err := dep.ReadLock(f)
+	f.Close()
+	if err != nil {
+		return
+	}
+
+	ids := make([]gps.ProjectIdentifier, len(l.P))
+	for i, lp := range l.P {
+		ids[i] = lp.Ident()
+	}
+	if err := ctx.RecordLockSources(p.LockPath(), l, sm.LocalSourceNames(ids)); err != nil {
+		ctx.Err.Printf("Warning: %s", err)
+	}
+}
+
+func writeCacheLocks(w io.Writer, locks []gps.CacheLock, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tHOLDER\tHELD FOR\tWAITERS\t")
+	for _, cl := range locks {
+		holder, held := "-", "-"
+		if cl.Holder != 0 {
+			holder = strconv.Itoa(cl.Holder)
+			if cl.Host != "" {
+				holder += "@" + cl.Host
+			}
+			if cl.Stale {
+				holder += " (died)"
+			}
+			d := now.Sub(cl.Since)
+			held = (d - d%time.Second).String()
+		}
+		waiters := make([]string, len(cl.Waiters))
+		for i, pid := range cl.Waiters {
+			waiters[i] = strconv.Itoa(pid)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", cl.Name, holder, held, strings.Join(waiters, ", "))
+	}
+	tw.Flush()
+}
+
+func writeCacheProblems(w io.Writer, problems []gps.CacheProblem) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTRY\tSOURCE\tPROBLEM\t")
+	for _, p := range problems {
+		entry, source := filepath.ToSlash(p.Path), p.Source
+		if p.Removed {
+			entry += " (removed)"
+		}
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", entry, source, p.Err)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheServerShortHelp = `Serve dependency sources to other dep instances`
+const cacheServerLongHelp = `
+Serve the version lists and source trees of dependencies over HTTP, so that a
+fleet of CI jobs or a team can share a single copy of the upstream repositories
+instead of each cloning them.
+
+Point dep at the server by setting DEPCACHESERVER to its URL:
+
+  DEPCACHESERVER=http://depcache.example.com:8080 dep ensure
+
+Sources are fetched from upstream on first request, and kept in the server's
+own cache ($GOPATH/pkg/dep) afterwards. Clients still fall back to the upstream
+repositories whenever the server fails them.
+
+The server clones whatever source it is asked for, and has no authentication
+of its own; only run it on a trusted network.
+`
+
+type cacheServerCommand struct {
+	addr string
+}
+
+func (cmd *cacheServerCommand) Name() string      { return "cache-server" }
+func (cmd *cacheServerCommand) Args() string      { return "[-addr address]" }
+func (cmd *cacheServerCommand) ShortHelp() string { return cacheServerShortHelp }
+func (cmd *cacheServerCommand) LongHelp() string  { return cacheServerLongHelp }
+func (cmd *cacheServerCommand) Hidden() bool      { return false }
+
+func (cmd *cacheServerCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.addr, "addr", ":8080", "address to listen on")
+}
+
+func (cmd *cacheServerCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep cache-server takes no arguments")
+	}
+	if ctx.CacheServer != "" {
+		return errors.New("DEPCACHESERVER must not be set when running a cache server")
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	defer sm.Release()
+
+	ln, err := net.Listen("tcp", cmd.addr)
+	if err != nil {
+		return errors.Wrap(err, "cache server failed")
+	}
+
+	// Closing the listener on interrupt makes http.Serve return, so that the
+	// source manager gets released.
+	interrupted := make(chan struct{})
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt)
+	defer signal.Stop(sigch)
+	go func() {
+		<-sigch
+		close(interrupted)
+		ln.Close()
+	}()
+
+	ctx.Err.Printf("Serving sources on %s", ln.Addr())
+	err = http.Serve(ln, gps.NewCacheServer(sm, ctx.Err))
+	select {
+	case <-interrupted:
+		return nil
+	default:
+		return errors.Wrap(err, "cache server failed")
+	}
+}
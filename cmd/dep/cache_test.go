@@ -0,0 +1,106 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+func TestWriteCacheLocks(t *testing.T) {
+	now := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	locks := []gps.CacheLock{
+		{Name: "https---github.com-foo-bar", Holder: 42, Since: now.Add(-90 * time.Second), Waiters: []int{43, 44}},
+		{Name: "https---github.com-foo-baz", Holder: 45, Since: now.Add(-time.Hour), Stale: true},
+		{Name: "https---github.com-foo-qux", Waiters: []int{46}},
+		{Name: "https---github.com-foo-zap", Holder: 47, Since: now.Add(-time.Minute), Host: "ci-2"},
+	}
+
+	var buf bytes.Buffer
+	writeCacheLocks(&buf, locks, now)
+	want := `SOURCE                      HOLDER     HELD FOR  WAITERS  
+https---github.com-foo-bar  42         1m30s     43, 44   
+https---github.com-foo-baz  45 (died)  1h0m0s             
+https---github.com-foo-qux  -          -         46       
+https---github.com-foo-zap  47@ci-2    1m0s               
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, strings.TrimSpace(want))
+	}
+}
+
+func TestWriteCacheProblems(t *testing.T) {
+	problems := []gps.CacheProblem{
+		{Path: filepath.Join("sources", "https---github.com-foo-bar"), Source: "https://github.com/foo/bar", Err: errors.New("git fsck failed: missing blob"), Removed: true},
+		{Path: filepath.Join("trees", "abc", ".download123"), Err: errors.New("download from a cache server interrupted")},
+	}
+
+	var buf bytes.Buffer
+	writeCacheProblems(&buf, problems)
+	want := `ENTRY                                         SOURCE                      PROBLEM                                   
+sources/https---github.com-foo-bar (removed)  https://github.com/foo/bar  git fsck failed: missing blob             
+trees/abc/.download123                        -                           download from a cache server interrupted  
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, strings.TrimSpace(want))
+	}
+}
+
+func TestCachedSourcesSelection(t *testing.T) {
+	now := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	srcs := []cachedSource{
+		{Name: "https---github.com-foo-bar", Source: "https://github.com/foo/bar", Updated: now.Add(-1000 * time.Hour)},
+		{Name: "https---github.com-foo-baz", Source: "git@github.com:foo/baz.git", Updated: now.Add(-1000 * time.Hour), Locked: &recent},
+		{Name: "https---github.com-foo-qux", Source: "https://github.com/foo/qux", Updated: recent},
+		{Name: "junk", Updated: now.Add(-1000 * time.Hour)},
+	}
+
+	unused := unusedCachedSources(srcs, now.Add(-720*time.Hour))
+	if len(unused) != 2 || unused[0].Name != "https---github.com-foo-bar" || unused[1].Name != "junk" {
+		t.Errorf("unexpected unused sources %v", unused)
+	}
+
+	matched, err := matchCachedSources(srcs, []string{"github.com/foo/baz", "https://github.com/foo/bar", "junk", "https---github.com-foo-bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cs := range matched {
+		names = append(names, cs.Name)
+	}
+	if want := "https---github.com-foo-baz https---github.com-foo-bar junk"; strings.Join(names, " ") != want {
+		t.Errorf("unexpected matched sources:\n\t(GOT): %v\n\t(WNT): %v", names, want)
+	}
+	if _, err := matchCachedSources(srcs, []string{"github.com/foo/zap"}); err == nil {
+		t.Error("expected an error for a source not in the cache")
+	}
+}
+
+func TestWriteCachedSources(t *testing.T) {
+	now := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	locked := now.Add(-3 * time.Hour)
+	srcs := []cachedSource{
+		{Name: "https---github.com-foo-bar", Source: "https://github.com/foo/bar", Size: 2048, Updated: now.Add(-5 * time.Minute), Locked: &locked},
+		{Name: "junk", Size: 10, Updated: now.Add(-100 * time.Hour)},
+	}
+
+	var buf bytes.Buffer
+	writeCachedSources(&buf, srcs, now)
+	want := `SOURCE                      SIZE     FETCHED  LOCKED  
+https://github.com/foo/bar  2.0 KiB  5m ago   3h ago  
+junk                        10 B     4d ago   never   
+2 source(s), 2.0 KiB in total
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, strings.TrimSpace(want))
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// changelogNames are the file names, compared case-insensitively, under which
+// projects conventionally keep their release notes, in order of preference.
+var changelogNames = []string{
+	"CHANGELOG.md",
+	"CHANGELOG",
+	"CHANGELOG.txt",
+	"CHANGES.md",
+	"CHANGES",
+	"HISTORY.md",
+	"NEWS.md",
+	"RELEASES.md",
+}
+
+// collectChangelog summarizes what changed in a project between the current
+// and latest versions.
+//
+// If the project keeps a changelog file, the entries added to it between the
+// two versions are returned. Otherwise, the descriptions attached to the
+// annotated tags released between the two versions are returned instead.
+func collectChangelog(sm gps.SourceManager, id gps.ProjectIdentifier, current, latest gps.Version) (string, error) {
+	td, err := ioutil.TempDir("", "dep-changelog")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	oldDir, newDir := filepath.Join(td, "current"), filepath.Join(td, "latest")
+	if err := sm.ExportProject(id, current, oldDir); err != nil {
+		return "", errors.Wrapf(err, "failed to export %s@%s", id, formatVersion(current))
+	}
+	if err := sm.ExportProject(id, latest, newDir); err != nil {
+		return "", errors.Wrapf(err, "failed to export %s@%s", id, formatVersion(latest))
+	}
+
+	if name := findChangelog(newDir); name != "" {
+		newLog, err := ioutil.ReadFile(filepath.Join(newDir, name))
+		if err != nil {
+			return "", err
+		}
+		// The changelog may not have existed at the current version, in which
+		// case all of it is new.
+		oldLog, _ := ioutil.ReadFile(filepath.Join(oldDir, name))
+		return addedLines(string(oldLog), string(newLog)), nil
+	}
+
+	return releaseDescriptions(sm, id, current, latest)
+}
+
+// findChangelog returns the name of the changelog file in dir, or the empty
+// string if there is none.
+func findChangelog(dir string) string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, want := range changelogNames {
+		for _, fi := range fis {
+			if fi.Mode().IsRegular() && strings.EqualFold(fi.Name(), want) {
+				return fi.Name()
+			}
+		}
+	}
+
+	return ""
+}
+
+// addedLines returns the lines of newText which do not appear in oldText,
+// preserving their order. Since changelogs are almost always amended by adding
+// new entries rather than rewriting existing ones, this yields the entries for
+// the releases in between.
+func addedLines(oldText, newText string) string {
+	seen := make(map[string]int)
+	for _, line := range strings.Split(oldText, "\n") {
+		seen[strings.TrimRight(line, " \t\r")]++
+	}
+
+	var added []string
+	for _, line := range strings.Split(newText, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		// Blank lines only separate entries, so keep them, but never more
+		// than one in a row.
+		if line == "" {
+			if len(added) > 0 && added[len(added)-1] != "" {
+				added = append(added, line)
+			}
+			continue
+		}
+		if seen[line] > 0 {
+			seen[line]--
+			continue
+		}
+		added = append(added, line)
+	}
+
+	return strings.TrimSpace(strings.Join(added, "\n"))
+}
+
+// releaseDescriptions returns the annotations of the tags released after
+// current, up to and including latest, newest first. current need not be
+// listed anymore: the tags sorting below it for upgrades are left out.
+func releaseDescriptions(sm gps.SourceManager, id gps.ProjectIdentifier, current, latest gps.Version) (string, error) {
+	// Branches have no releases in between, and neither does moving from a
+	// branch or revision onto a tag.
+	pc, ok := current.(gps.PairedVersion)
+	if !ok || current.Type() != latest.Type() || latest.Type() == gps.IsBranch {
+		return "", nil
+	}
+	pl, ok := latest.(gps.PairedVersion)
+	if !ok {
+		return "", nil
+	}
+
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list versions for %s", id)
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	var notes []string
+	for _, v := range vl {
+		if v.Type() != latest.Type() {
+			continue
+		}
+		if !gps.UpgradeLess(v, pc) {
+			break
+		}
+		if gps.UpgradeLess(v, pl) {
+			continue
+		}
+
+		vi, err := sm.VersionInfo(id, v)
+		if err != nil {
+			continue
+		}
+		if annotation := strings.TrimSpace(vi.Annotation); annotation != "" {
+			notes = append(notes, fmt.Sprintf("%s:\n%s", v, annotation))
+		}
+	}
+
+	return strings.Join(notes, "\n\n"), nil
+}
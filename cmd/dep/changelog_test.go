@@ -0,0 +1,111 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestAddedLines(t *testing.T) {
+	oldLog := "# Changelog\n\n## v1.0.0\n\n- Initial release\n"
+	newLog := "# Changelog\n\n## v1.1.0\n\n- Add frobnication\n\n## v1.0.0\n\n- Initial release\n"
+
+	want := "## v1.1.0\n\n- Add frobnication"
+	if got := addedLines(oldLog, newLog); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if got := addedLines(newLog, newLog); got != "" {
+		t.Fatalf("expected no added lines for identical changelogs, got %q", got)
+	}
+
+	if got := addedLines("", oldLog); got != "# Changelog\n\n## v1.0.0\n\n- Initial release" {
+		t.Fatalf("expected the whole changelog to be new, got %q", got)
+	}
+}
+
+func TestFindChangelog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-changelog-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if got := findChangelog(dir); got != "" {
+		t.Fatalf("expected no changelog in empty dir, got %q", got)
+	}
+
+	for _, name := range []string{"News.md", "Changelog.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "CHANGES"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := findChangelog(dir); got != "Changelog.md" {
+		t.Fatalf("expected Changelog.md to be preferred, got %q", got)
+	}
+}
+
+type releaseTestSM struct {
+	gps.SourceManager
+	versions    []gps.PairedVersion
+	annotations map[string]string
+}
+
+func (sm releaseTestSM) ListVersions(gps.ProjectIdentifier) ([]gps.PairedVersion, error) {
+	return sm.versions, nil
+}
+
+func (sm releaseTestSM) VersionInfo(id gps.ProjectIdentifier, v gps.Version) (gps.VersionInfo, error) {
+	return gps.VersionInfo{Annotation: sm.annotations[v.String()]}, nil
+}
+
+func TestReleaseDescriptions(t *testing.T) {
+	id := gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/foo"}
+	sm := releaseTestSM{
+		versions: []gps.PairedVersion{
+			gps.NewVersion("v1.0.0").Pair("aaa"),
+			gps.NewVersion("v1.2.0").Pair("bbb"),
+			gps.NewVersion("v1.3.0").Pair("ccc"),
+			gps.NewVersion("v1.4.0").Pair("ddd"),
+		},
+		annotations: map[string]string{
+			"v1.0.0": "first",
+			"v1.2.0": "second",
+			"v1.3.0": "third",
+			"v1.4.0": "fourth",
+		},
+	}
+
+	tests := []struct {
+		current, latest string
+		want            string
+	}{
+		{"v1.0.0", "v1.3.0", "v1.3.0:\nthird\n\nv1.2.0:\nsecond"},
+		// v1.1.0 is no longer listed, which doesn't extend the range down to
+		// the releases before it.
+		{"v1.1.0", "v1.3.0", "v1.3.0:\nthird\n\nv1.2.0:\nsecond"},
+		{"v1.3.0", "v1.3.0", ""},
+	}
+	for _, tt := range tests {
+		current := gps.NewVersion(tt.current).Pair("000")
+		latest := gps.NewVersion(tt.latest).Pair("fff")
+		got, err := releaseDescriptions(sm, id, current, latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s to %s: unexpected descriptions:\n\t(GOT): %q\n\t(WNT): %q", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
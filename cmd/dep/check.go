@@ -0,0 +1,140 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const checkShortHelp = `Check that vendor/ matches Gopkg.lock and hasn't been modified`
+const checkLongHelp = `
+Check that the directory of every project in vendor/ matches the digest
+recorded for it in Gopkg.lock when dep ensure last wrote vendor/, and report
+the projects which drifted from the lock or are missing from vendor/, and the
+directories of vendor/ which belong to no project of the lock.
+
+Then check that every file in vendor/ matches the checksum recorded for it,
+and report the files which were modified, removed or added since.
+
+Digests and checksums are only recorded when vendor-checksums is set in
+Gopkg.toml:
+
+  vendor-checksums = true
+
+The checksums are kept in vendor/.dep-checksums, which is meant to be
+committed along with the rest of vendor/.
+
+Neither check solves the dependencies again, which makes dep check a fast way
+for CI to prove that vendor/ is what dep ensure would write from Gopkg.lock.
+`
+
+type checkCommand struct{}
+
+func (cmd *checkCommand) Name() string      { return "check" }
+func (cmd *checkCommand) Args() string      { return "" }
+func (cmd *checkCommand) ShortHelp() string { return checkShortHelp }
+func (cmd *checkCommand) LongHelp() string  { return checkLongHelp }
+func (cmd *checkCommand) Hidden() bool      { return false }
+
+func (cmd *checkCommand) Register(fs *flag.FlagSet) {}
+
+func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep check takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	vendorDir := p.VendorDir()
+	_, err = os.Stat(filepath.Join(vendorDir, dep.VendorChecksumsName))
+	hasChecksums := err == nil
+	hasDigests := p.Lock != nil && len(p.Lock.Digests) > 0
+	if !hasChecksums && !hasDigests {
+		if !p.Manifest.VendorChecksums {
+			return errors.Errorf("no checksums recorded for vendor/; set vendor-checksums = true in %s and run dep ensure -vendor-only", dep.ManifestName)
+		}
+		return errors.New("no checksums recorded for vendor/; run dep ensure -vendor-only to record them")
+	}
+
+	var buf bytes.Buffer
+	var failed bool
+	if hasDigests {
+		d, err := dep.VerifyVendorDigests(vendorDir, p.Lock)
+		if err != nil {
+			return err
+		}
+		writeDigestDiff(&buf, d)
+		failed = !d.Empty()
+	}
+	if hasChecksums {
+		d, err := dep.VerifyVendorChecksums(vendorDir)
+		if err != nil {
+			return err
+		}
+		writeChecksumDiff(&buf, d)
+		failed = failed || !d.Empty()
+	}
+	ctx.Out.Print(buf.String())
+
+	if failed {
+		return errors.New("vendor/ does not match the recorded checksums")
+	}
+	return nil
+}
+
+func writeDigestDiff(w io.Writer, d dep.VendorDigestDiff) {
+	if d.Empty() {
+		fmt.Fprintf(w, "vendor/ matches the digests of %s.\n", dep.LockName)
+		return
+	}
+
+	for _, s := range []struct {
+		title string
+		roots []gps.ProjectRoot
+	}{
+		{"Drifted", d.Drifted},
+		{"Missing", d.Missing},
+		{"No digest", d.Unrecorded},
+	} {
+		for _, pr := range s.roots {
+			fmt.Fprintf(w, "%s: vendor/%s\n", s.title, pr)
+		}
+	}
+	for _, p := range d.Extraneous {
+		fmt.Fprintf(w, "Extraneous: vendor/%s\n", p)
+	}
+}
+
+func writeChecksumDiff(w io.Writer, d dep.VendorChecksumDiff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "vendor/ matches the recorded checksums.")
+		return
+	}
+
+	for _, s := range []struct {
+		title string
+		paths []string
+	}{
+		{"Modified", d.Modified},
+		{"Missing", d.Missing},
+		{"Added", d.Added},
+	} {
+		for _, p := range s.paths {
+			fmt.Fprintf(w, "%s: vendor/%s\n", s.title, p)
+		}
+	}
+}
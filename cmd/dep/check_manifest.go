@@ -0,0 +1,268 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/paths"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const checkManifestShortHelp = `Report problems in Gopkg.toml the solver would trip over`
+const checkManifestLongHelp = `
+Check Gopkg.toml against the imports of the project, without solving, and
+report:
+
+  * fields and keys dep doesn't know, and values it would question
+  * constraints and overrides naming a package rather than the root of its
+    project
+  * constraints on projects the project doesn't import directly, which the
+    solver ignores, or which nothing in Gopkg.lock depends on
+  * constraints shadowed by an override on the same project
+  * overrides on projects nothing in Gopkg.lock depends on
+  * packages which are both required and ignored
+
+Each problem is printed on its own line, starting with its kind in brackets,
+along with a way to fix it. With -json, the problems are printed as a JSON
+array of objects with Kind, Name and Message fields instead.
+
+Overrides are only checked against Gopkg.lock, if any, as the projects nothing
+imports directly can only be told apart from the unused ones by solving.
+
+dep check-manifest exits with a non-zero status when it finds problems.
+`
+
+type checkManifestCommand struct {
+	json bool
+}
+
+func (cmd *checkManifestCommand) Name() string      { return "check-manifest" }
+func (cmd *checkManifestCommand) Args() string      { return "[-json]" }
+func (cmd *checkManifestCommand) ShortHelp() string { return checkManifestShortHelp }
+func (cmd *checkManifestCommand) LongHelp() string  { return checkManifestLongHelp }
+func (cmd *checkManifestCommand) Hidden() bool      { return false }
+
+func (cmd *checkManifestCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+func (cmd *checkManifestCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep check-manifest takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	// The warnings of the manifest were printed while loading the project,
+	// but are reported along with the other problems too.
+	mf, err := os.Open(p.ManifestPath())
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", p.ManifestPath())
+	}
+	defer mf.Close()
+	_, warns, err := dep.ReadManifest(mf)
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return errors.Wrap(err, "analysis of local packages failed")
+	}
+
+	problems := checkManifest(p.Manifest, p.Lock, warns, directImports(ptree, p.Manifest, p.ImportRoot), sm)
+
+	var buf bytes.Buffer
+	if cmd.json {
+		if err := writeManifestProblemsJSON(&buf, problems); err != nil {
+			return err
+		}
+	} else if len(problems) == 0 {
+		fmt.Fprintf(&buf, "No problems found in %s.\n", filepath.Base(p.ManifestPath()))
+	} else {
+		writeManifestProblems(&buf, problems)
+	}
+	ctx.Out.Print(buf.String())
+
+	if len(problems) > 0 {
+		return errors.Errorf("found %d problem(s) in %s", len(problems), filepath.Base(p.ManifestPath()))
+	}
+	return nil
+}
+
+// manifestProblem is a problem found in a manifest.
+type manifestProblem struct {
+	// Kind is one of "field", "package-constraint", "package-override",
+	// "transitive-constraint", "unused-constraint", "shadowed-constraint",
+	// "unreachable-override" or "ignored-required".
+	Kind string
+	// Name is the project or package the problem is about, if any.
+	Name    string `json:",omitempty"`
+	Message string
+}
+
+type byProblemName []manifestProblem
+
+func (s byProblemName) Len() int      { return len(s) }
+func (s byProblemName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byProblemName) Less(i, j int) bool {
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	return s[i].Kind < s[j].Kind
+}
+
+// directImports returns the packages the root project imports from other
+// projects, or requires.
+func directImports(ptree pkgtree.PackageTree, m *dep.Manifest, root gps.ProjectRoot) []string {
+	rm, _ := ptree.ToReachMap(true, true, false, m.IgnoredPackages())
+	var imports []string
+	for _, pkg := range rm.FlattenFn(paths.IsStandardImportPath) {
+		if !isPathPrefixOrEqual(string(root), pkg) {
+			imports = append(imports, pkg)
+		}
+	}
+	return append(imports, m.Required...)
+}
+
+// checkManifest returns the problems of m, given the validation warnings
+// found while reading it, and the packages the root project imports directly.
+// l may be nil.
+func checkManifest(m *dep.Manifest, l *dep.Lock, warns []error, imports []string, sm gps.SourceManager) []manifestProblem {
+	var problems []manifestProblem
+	for _, warn := range warns {
+		problems = append(problems, manifestProblem{Kind: "field", Message: warn.Error()})
+	}
+
+	imported := func(pr gps.ProjectRoot) bool {
+		for _, pkg := range imports {
+			if isPathPrefixOrEqual(string(pr), pkg) {
+				return true
+			}
+		}
+		return false
+	}
+	overridden := func(pr gps.ProjectRoot) bool {
+		_, has := m.Ovr[pr]
+		return has
+	}
+	locked := make(map[gps.ProjectRoot]bool)
+	if l != nil {
+		for _, lp := range l.P {
+			locked[lp.Ident().ProjectRoot] = true
+		}
+	}
+
+	// notRoot checks whether pr is a package below the root of its project,
+	// reporting it if so.
+	notRoot := func(kind, what string, pr gps.ProjectRoot) bool {
+		root, err := sm.DeduceProjectRoot(string(pr))
+		if err != nil || root == pr {
+			return false
+		}
+		problems = append(problems, manifestProblem{
+			Kind:    kind,
+			Name:    string(pr),
+			Message: fmt.Sprintf("%s is a package of %s; %ss apply to whole projects, so name %s instead", pr, root, what, root),
+		})
+		return true
+	}
+
+	for pr := range m.Constraints {
+		switch {
+		case notRoot("package-constraint", "constraint", pr):
+		case overridden(pr):
+			problems = append(problems, manifestProblem{
+				Kind:    "shadowed-constraint",
+				Name:    string(pr),
+				Message: fmt.Sprintf("the override on %s takes precedence over its constraint; remove the constraint", pr),
+			})
+		case imported(pr):
+		case l != nil && !locked[pr]:
+			problems = append(problems, manifestProblem{
+				Kind:    "unused-constraint",
+				Name:    string(pr),
+				Message: fmt.Sprintf("nothing depends on %s; remove the constraint, or require a package of it", pr),
+			})
+		default:
+			problems = append(problems, manifestProblem{
+				Kind:    "transitive-constraint",
+				Name:    string(pr),
+				Message: fmt.Sprintf("the project doesn't import %s directly, and constraints only apply to direct dependencies; turn the constraint into an override, or remove it", pr),
+			})
+		}
+	}
+
+	for pr := range m.Ovr {
+		if notRoot("package-override", "override", pr) || l == nil || locked[pr] || imported(pr) {
+			continue
+		}
+		problems = append(problems, manifestProblem{
+			Kind:    "unreachable-override",
+			Name:    string(pr),
+			Message: fmt.Sprintf("nothing depends on %s, so the override has no effect; remove it", pr),
+		})
+	}
+
+	ignored := pkgtree.NewIgnoreRules(m.IgnoredPackages())
+	for _, pkg := range m.Required {
+		if ignored.IsIgnored(pkg) {
+			problems = append(problems, manifestProblem{
+				Kind:    "ignored-required",
+				Name:    pkg,
+				Message: fmt.Sprintf("%s is both required and ignored; remove it from one of them", pkg),
+			})
+		}
+	}
+
+	sort.Stable(byProblemName(problems))
+	return problems
+}
+
+// isPathPrefixOrEqual checks whether p is the slash-separated path pre, or
+// lies beneath it.
+func isPathPrefixOrEqual(pre, p string) bool {
+	return p == pre || strings.HasPrefix(p, pre+"/")
+}
+
+func writeManifestProblems(w io.Writer, problems []manifestProblem) {
+	for _, pb := range problems {
+		fmt.Fprintf(w, "[%s] %s\n", pb.Kind, pb.Message)
+	}
+}
+
+func writeManifestProblemsJSON(w io.Writer, problems []manifestProblem) error {
+	if problems == nil {
+		problems = []manifestProblem{}
+	}
+	b, err := json.MarshalIndent(problems, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest problems")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
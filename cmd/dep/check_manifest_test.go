@@ -0,0 +1,107 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestCheckManifest(t *testing.T) {
+	m, warns, err := dep.ReadManifest(strings.NewReader(`
+required = ["github.com/a/tool/cmd/tool", "github.com/b/b/gen"]
+ignored = ["github.com/b/b/gen"]
+vendr-checksums = true
+
+[[constraint]]
+  name = "github.com/a/direct"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/a/direct/sub"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/a/transitive"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/a/unused"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/a/shadowed"
+  version = "1.0.0"
+
+[[override]]
+  name = "github.com/a/shadowed"
+  version = "2.0.0"
+
+[[override]]
+  name = "github.com/a/unreachable"
+  version = "1.0.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/direct"}, gps.NewVersion("v1.0.0").Pair("abc"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/transitive"}, gps.NewVersion("v1.0.0").Pair("def"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/shadowed"}, gps.NewVersion("v2.0.0").Pair("fed"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/tool"}, gps.NewVersion("v1.0.0").Pair("cba"), []string{"cmd/tool"}),
+	}}
+	imports := []string{"github.com/a/direct/pkg", "github.com/a/shadowed", "github.com/a/tool/cmd/tool"}
+
+	got := checkManifest(m, l, warns, imports, gomodTestSM{})
+	want := []manifestProblem{
+		{Kind: "field", Message: `Unknown field in manifest: vendr-checksums`},
+		{Kind: "package-constraint", Name: "github.com/a/direct/sub"},
+		{Kind: "shadowed-constraint", Name: "github.com/a/shadowed"},
+		{Kind: "transitive-constraint", Name: "github.com/a/transitive"},
+		{Kind: "unreachable-override", Name: "github.com/a/unreachable"},
+		{Kind: "unused-constraint", Name: "github.com/a/unused"},
+		{Kind: "ignored-required", Name: "github.com/b/b/gen"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected problems: %+v", got)
+	}
+	for i, pb := range got {
+		if pb.Kind != want[i].Kind || pb.Name != want[i].Name || pb.Message == "" {
+			t.Errorf("unexpected problem %d:\n\t(GOT): %+v\n\t(WNT): %+v", i, pb, want[i])
+		}
+	}
+	if got[0].Message != want[0].Message {
+		t.Errorf("unexpected message for the unknown field: %q", got[0].Message)
+	}
+
+	var buf bytes.Buffer
+	if err := writeManifestProblemsJSON(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	var raw []manifestProblem
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(raw, got) {
+		t.Errorf("unexpected JSON problems: %s", buf.String())
+	}
+
+	// Without a lock, constraints can't be told unused.
+	got = checkManifest(m, nil, nil, imports, gomodTestSM{})
+	for _, pb := range got {
+		if pb.Name == "github.com/a/unused" && pb.Kind != "transitive-constraint" {
+			t.Errorf("expected github.com/a/unused to be reported as a transitive constraint without a lock, got %+v", pb)
+		}
+		if pb.Kind == "unreachable-override" {
+			t.Errorf("expected no override to be reported without a lock, got %+v", pb)
+		}
+	}
+}
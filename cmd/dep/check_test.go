@@ -0,0 +1,58 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestWriteChecksumDiff(t *testing.T) {
+	var buf bytes.Buffer
+	writeChecksumDiff(&buf, dep.VendorChecksumDiff{})
+	if got, want := buf.String(), "vendor/ matches the recorded checksums.\n"; got != want {
+		t.Errorf("Unexpected output for an empty diff:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	buf.Reset()
+	writeChecksumDiff(&buf, dep.VendorChecksumDiff{
+		Modified: []string{"github.com/foo/bar/bar.go"},
+		Missing:  []string{"github.com/foo/bar/LICENSE"},
+		Added:    []string{"github.com/foo/bar/extra.go", "github.com/foo/baz/baz.go"},
+	})
+	want := "Modified: vendor/github.com/foo/bar/bar.go\n" +
+		"Missing: vendor/github.com/foo/bar/LICENSE\n" +
+		"Added: vendor/github.com/foo/bar/extra.go\n" +
+		"Added: vendor/github.com/foo/baz/baz.go\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected output:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
+
+func TestWriteDigestDiff(t *testing.T) {
+	var buf bytes.Buffer
+	writeDigestDiff(&buf, dep.VendorDigestDiff{})
+	if got, want := buf.String(), "vendor/ matches the digests of Gopkg.lock.\n"; got != want {
+		t.Errorf("Unexpected output for an empty diff:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	buf.Reset()
+	writeDigestDiff(&buf, dep.VendorDigestDiff{
+		Drifted:    []gps.ProjectRoot{"github.com/foo/bar"},
+		Missing:    []gps.ProjectRoot{"github.com/foo/gone"},
+		Unrecorded: []gps.ProjectRoot{"github.com/foo/new"},
+		Extraneous: []string{"golang.org"},
+	})
+	want := "Drifted: vendor/github.com/foo/bar\n" +
+		"Missing: vendor/github.com/foo/gone\n" +
+		"No digest: vendor/github.com/foo/new\n" +
+		"Extraneous: vendor/golang.org\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected output:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// conflictFix is a change to the manifest of the root project which relaxes
+// the constraints excluding a version in a solve failure.
+type conflictFix struct {
+	Project gps.ProjectRoot
+	// Override is set for the fixes which add or change an override, instead
+	// of changing the constraint of the root project.
+	Override   bool
+	Constraint gps.Constraint
+}
+
+func (f conflictFix) describe(m *dep.Manifest) string {
+	if !f.Override {
+		return fmt.Sprintf("widen the constraint on %s to %s", f.Project, f.Constraint)
+	}
+	if _, has := m.Ovr[f.Project]; has {
+		return fmt.Sprintf("change the override on %s to %s", f.Project, f.Constraint)
+	}
+	return fmt.Sprintf("add an override on %s to %s", f.Project, f.Constraint)
+}
+
+// apply makes the change to m.
+func (f conflictFix) apply(m *dep.Manifest) {
+	pcs := m.Constraints
+	if f.Override {
+		if m.Ovr == nil {
+			m.Ovr = make(gps.ProjectConstraints)
+		}
+		pcs = m.Ovr
+	}
+
+	pp, has := pcs[f.Project]
+	if !has {
+		pp.Source = m.Constraints[f.Project].Source
+	}
+	pp.Constraint = f.Constraint
+	pcs[f.Project] = pp
+}
+
+// suggestConflictFixes returns the changes to m which would each let in a
+// version excluded in the solve failure sf, in the order the versions were
+// tried. A version only excluded by the constraint of the root project gets
+// that constraint widened, and one excluded by those of dependencies gets an
+// override. A constraint of a version which conflicts with those of others
+// gets an override on the project it constrains for each of them.
+func suggestConflictFixes(sf *gps.SolveFailure, m *dep.Manifest) []conflictFix {
+	var fixes []conflictFix
+	seen := make(map[string]bool)
+	add := func(pr gps.ProjectRoot, override bool, c gps.Constraint) {
+		if c == nil {
+			return
+		}
+		if _, has := m.Ovr[pr]; has {
+			override = true
+		}
+		key := fmt.Sprintf("%s %t %s", pr, override, c)
+		if !seen[key] {
+			seen[key] = true
+			fixes = append(fixes, conflictFix{Project: pr, Override: override, Constraint: c})
+		}
+	}
+
+	for _, vf := range sf.Versions {
+		switch vf.Reason {
+		case "not-allowed":
+			override := false
+			for _, ec := range vf.Constraints {
+				if ec.From != "(root)" {
+					override = true
+				}
+			}
+			add(sf.Project, override, fixConstraint(vf.Type, vf.Version, true))
+		case "disjoint-constraint":
+			add(vf.Goal.On, true, fixConstraint(vf.Goal.Type, vf.Goal.Constraint, false))
+			for _, ec := range vf.Constraints {
+				add(ec.On, true, fixConstraint(ec.Type, ec.Constraint, false))
+			}
+		case "constraint-not-allowed":
+			add(vf.Goal.On, true, fixConstraint(vf.Goal.Type, vf.Goal.Constraint, false))
+		}
+	}
+	return fixes
+}
+
+// fixConstraint returns the constraint of the given type and body of a
+// gps.SolveFailure, or nil if it can't be written to a manifest. With caret, a
+// semver version is turned into the caret constraint on it.
+func fixConstraint(typ, body string, caret bool) gps.Constraint {
+	switch typ {
+	case "semver":
+		var c gps.Constraint
+		var err error
+		if caret {
+			c, err = gps.NewSemverConstraintIC(body)
+		} else {
+			c, err = gps.NewSemverConstraint(body)
+		}
+		if err != nil {
+			return nil
+		}
+		return c
+	case "version":
+		return gps.NewVersion(body)
+	case "branch":
+		return gps.NewBranch(body)
+	case "revision":
+		return gps.Revision(body)
+	}
+	return nil
+}
+
+// suggestFixes prints the fixes for the solve failure err when -suggest is
+// passed, and applies the one chosen with -apply-fix to Gopkg.toml.
+func (cmd *ensureCommand) suggestFixes(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, err error) error {
+	if !cmd.suggest {
+		return nil
+	}
+
+	sf := gps.NewSolveFailure(err)
+	var fixes []conflictFix
+	if sf != nil {
+		fixes = suggestConflictFixes(sf, p.Manifest)
+	}
+	if len(fixes) == 0 {
+		ctx.Err.Println("No fix to suggest for this failure.")
+		return nil
+	}
+
+	if cmd.applyFix == 0 {
+		var buf bytes.Buffer
+		buf.WriteString("Suggested fixes:\n")
+		for i, f := range fixes {
+			fmt.Fprintf(&buf, "  %d. %s\n", i+1, f.describe(p.Manifest))
+		}
+		fmt.Fprintf(&buf, "Pass -apply-fix <n> to make one of them in %s.", dep.ManifestName)
+		ctx.Err.Println(buf.String())
+		return nil
+	}
+
+	if cmd.applyFix > len(fixes) {
+		return errors.Errorf("there are only %d suggested fixes, cannot apply fix %d", len(fixes), cmd.applyFix)
+	}
+	f := fixes[cmd.applyFix-1]
+	desc := f.describe(p.Manifest)
+	f.apply(p.Manifest)
+
+	sw, werr := dep.NewSafeWriter(p.Manifest, nil, nil, dep.VendorNever)
+	if werr != nil {
+		return werr
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	if werr := sw.Write(p.AbsRoot, sm, false, logger); werr != nil {
+		return errors.Wrapf(werr, "failed to write %s", dep.ManifestName)
+	}
+	ctx.Err.Printf("Applied fix: %s. Run dep ensure again to solve with it.", desc)
+	return nil
+}
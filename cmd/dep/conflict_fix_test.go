@@ -0,0 +1,95 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestSuggestConflictFixes(t *testing.T) {
+	m := &dep.Manifest{
+		Constraints: gps.ProjectConstraints{
+			"github.com/a/direct": gps.ProjectProperties{Source: "https://example.com/direct", Constraint: gps.NewBranch("master")},
+		},
+		Ovr: gps.ProjectConstraints{
+			"github.com/a/overridden": gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+		},
+	}
+	sf := &gps.SolveFailure{
+		Project: "github.com/a/direct",
+		Versions: []gps.VersionFailure{
+			{
+				Version:     "v2.1.0",
+				Type:        "semver",
+				Reason:      "not-allowed",
+				Constraints: []gps.ExcludingConstraint{{From: "(root)", On: "github.com/a/direct", Constraint: "^1.0.0", Type: "semver"}},
+			},
+			{
+				Version: "v1.0.0",
+				Type:    "semver",
+				Reason:  "not-allowed",
+				Constraints: []gps.ExcludingConstraint{
+					{From: "(root)", On: "github.com/a/direct", Constraint: "^1.0.0", Type: "semver"},
+					{From: "github.com/b/b@v1.0.0", On: "github.com/a/direct", Constraint: "^2.0.0", Type: "semver"},
+				},
+			},
+			{
+				Version: "develop",
+				Type:    "branch",
+				Reason:  "disjoint-constraint",
+				Goal:    &gps.ExcludingConstraint{From: "github.com/a/direct@develop", On: "github.com/shared/s", Constraint: ">=2.0.0, <3.0.0", Type: "semver"},
+				Constraints: []gps.ExcludingConstraint{
+					{From: "github.com/b/b@v1.0.0", On: "github.com/shared/s", Constraint: "^1.0.0", Type: "semver"},
+					{From: "github.com/c/c@v1.0.0", On: "github.com/shared/s", Constraint: "https://example.com/s"},
+				},
+			},
+			{
+				Version: "v0.9.0",
+				Type:    "semver",
+				Reason:  "constraint-not-allowed",
+				Goal:    &gps.ExcludingConstraint{From: "github.com/a/direct@v0.9.0", On: "github.com/a/overridden", Constraint: "release", Type: "branch"},
+			},
+			{
+				Version: "v0.8.0",
+				Type:    "semver",
+				Reason:  "missing-revision",
+				Goal:    &gps.ExcludingConstraint{From: "github.com/a/direct@v0.8.0", On: "github.com/d/d", Constraint: "abc", Type: "revision"},
+			},
+		},
+	}
+
+	fixes := suggestConflictFixes(sf, m)
+	want := []string{
+		"widen the constraint on github.com/a/direct to ^2.1.0",
+		"add an override on github.com/a/direct to ^1.0.0",
+		"add an override on github.com/shared/s to ^2.0.0",
+		"add an override on github.com/shared/s to ^1.0.0",
+		"change the override on github.com/a/overridden to release",
+	}
+	if len(fixes) != len(want) {
+		t.Fatalf("expected %d fixes, got %+v", len(want), fixes)
+	}
+	for i, f := range fixes {
+		if got := f.describe(m); got != want[i] {
+			t.Errorf("unexpected fix %d:\n\t(GOT): %s\n\t(WNT): %s", i+1, got, want[i])
+		}
+	}
+
+	fixes[0].apply(m)
+	if pp := m.Constraints["github.com/a/direct"]; pp.Constraint.String() != "^2.1.0" || pp.Source != "https://example.com/direct" {
+		t.Errorf("unexpected constraint after the fix: %+v", pp)
+	}
+	fixes[1].apply(m)
+	if pp := m.Ovr["github.com/a/direct"]; pp.Constraint.String() != "^1.0.0" || pp.Source != "https://example.com/direct" {
+		t.Errorf("unexpected override after the fix: %+v", pp)
+	}
+	fixes[4].apply(m)
+	if pp := m.Ovr["github.com/a/overridden"]; pp.Constraint.String() != "release" {
+		t.Errorf("unexpected override after the fix: %+v", pp)
+	}
+}
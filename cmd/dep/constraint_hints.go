@@ -0,0 +1,160 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+// constraintHint is a constraint the manifest of a dependency declares on
+// another project of the lock: a version the dependency was built against.
+type constraintHint struct {
+	From       gps.ProjectRoot
+	Constraint gps.Constraint
+}
+
+// MarshalJSON marshals the constraint as its string.
+func (h constraintHint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		From       gps.ProjectRoot
+		Constraint string
+	}{h.From, h.Constraint.String()})
+}
+
+type byHintSource []constraintHint
+
+func (s byHintSource) Len() int           { return len(s) }
+func (s byHintSource) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byHintSource) Less(i, j int) bool { return s[i].From < s[j].From }
+
+// collectConstraintHints returns the constraints the manifests of the projects
+// in l declare on the other projects in l, keyed by the root of the
+// constrained project and sorted by declaring project. Projects whose
+// manifest can't be retrieved are skipped.
+func collectConstraintHints(ctx *dep.Ctx, l gps.Lock, sm gps.SourceManager) map[gps.ProjectRoot][]constraintHint {
+	hints := make(map[gps.ProjectRoot][]constraintHint)
+	if l == nil {
+		return hints
+	}
+
+	locked := make(map[gps.ProjectRoot]bool)
+	for _, lp := range l.Projects() {
+		locked[lp.Ident().ProjectRoot] = true
+	}
+
+	for _, lp := range l.Projects() {
+		m, _, err := sm.GetManifestAndLock(lp.Ident(), lp.Version(), dep.Analyzer{})
+		if err != nil {
+			if ctx.Verbose {
+				ctx.Err.Printf("Unable to read the manifest of %s: %s", lp.Ident(), err)
+			}
+			continue
+		}
+		if m == nil {
+			continue
+		}
+
+		for pr, pp := range m.DependencyConstraints() {
+			if !locked[pr] || pr == lp.Ident().ProjectRoot || pp.Constraint == nil || gps.IsAny(pp.Constraint) {
+				continue
+			}
+			hints[pr] = append(hints[pr], constraintHint{From: lp.Ident().ProjectRoot, Constraint: pp.Constraint})
+		}
+	}
+
+	for _, h := range hints {
+		sort.Sort(byHintSource(h))
+	}
+	return hints
+}
+
+// hintConstraints returns a manifest holding, for each of the given project
+// roots which has no rules in m, the intersection of the hints on it. Projects
+// on which the hints have no common versions are left out.
+func hintConstraints(m *dep.Manifest, roots map[gps.ProjectRoot]bool, hints map[gps.ProjectRoot][]constraintHint, l gps.Lock) *dep.Manifest {
+	appender := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if !roots[pr] || m.HasConstraintsOn(pr) || len(hints[pr]) == 0 {
+			continue
+		}
+
+		c := gps.Any()
+		for _, h := range hints[pr] {
+			c = c.Intersect(h.Constraint)
+		}
+		// A constraint admitting no version matches nothing.
+		if !c.MatchesAny(gps.Any()) {
+			continue
+		}
+		appender.Constraints[pr] = gps.ProjectProperties{
+			Source:     lp.Ident().Source,
+			Constraint: c,
+		}
+	}
+	return appender
+}
+
+// projectHints is the outcome of dep status -hints for a project.
+type projectHints struct {
+	ProjectRoot gps.ProjectRoot
+	// Constraint is the constraint of the root project, if any.
+	Constraint string
+	Hints      []constraintHint
+}
+
+// statusHints returns the hints on the projects in l with some, along with
+// the constraints m declares on them, sorted by project.
+func statusHints(m *dep.Manifest, l gps.Lock, hints map[gps.ProjectRoot][]constraintHint) []projectHints {
+	var out []projectHints
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if len(hints[pr]) == 0 {
+			continue
+		}
+		ph := projectHints{ProjectRoot: pr, Hints: hints[pr]}
+		if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+			ph.Constraint = pp.Constraint.String()
+		}
+		out = append(out, ph)
+	}
+	sort.Sort(byProjectHints(out))
+	return out
+}
+
+type byProjectHints []projectHints
+
+func (s byProjectHints) Len() int           { return len(s) }
+func (s byProjectHints) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byProjectHints) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
+
+// writeHints prints the hints as a table, with the constraint of the root
+// project on each project next to them.
+func writeHints(w io.Writer, hs []projectHints) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tCONSTRAINT\tHINTS")
+	for _, ph := range hs {
+		constraint := ph.Constraint
+		if constraint == "" {
+			constraint = "-"
+		}
+		hints := make([]string, len(ph.Hints))
+		for i, h := range ph.Hints {
+			hints[i] = fmt.Sprintf("%s from %s", h.Constraint, h.From)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ph.ProjectRoot, constraint, strings.Join(hints, ", "))
+	}
+	tw.Flush()
+}
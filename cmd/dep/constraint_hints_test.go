@@ -0,0 +1,102 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+type hintsTestSM struct {
+	gps.SourceManager
+	manifests map[gps.ProjectRoot]string
+}
+
+func (sm hintsTestSM) GetManifestAndLock(id gps.ProjectIdentifier, v gps.Version, an gps.ProjectAnalyzer) (gps.Manifest, gps.Lock, error) {
+	m, has := sm.manifests[id.ProjectRoot]
+	if !has {
+		return nil, nil, errors.Errorf("no manifest for %s", id)
+	}
+	rm, _, err := dep.ReadManifest(strings.NewReader(m))
+	return rm, nil, err
+}
+
+func TestConstraintHints(t *testing.T) {
+	sm := hintsTestSM{manifests: map[gps.ProjectRoot]string{
+		"github.com/a/a": `
+[[constraint]]
+  name = "github.com/c/c"
+  version = "1.2.0"
+
+[[constraint]]
+  name = "github.com/d/d"
+  branch = "master"
+
+[[constraint]]
+  name = "github.com/e/e"
+  version = "1.0.0"
+
+[[constraint]]
+  name = "github.com/not/locked"
+  version = "1.0.0"
+`,
+		"github.com/b/b": `
+[[constraint]]
+  name = "github.com/c/c"
+  version = ">=1.0.0, <1.5.0"
+
+[[constraint]]
+  name = "github.com/d/d"
+  branch = "develop"
+
+[[constraint]]
+  name = "github.com/a/a"
+`,
+		"github.com/c/c": ``,
+	}}
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("aaa"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, gps.NewVersion("v1.0.0").Pair("bbb"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/c/c", Source: "https://example.com/c"}, gps.NewVersion("v1.3.0").Pair("ccc"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/d/d"}, gps.NewBranch("master").Pair("ddd"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/e/e"}, gps.NewVersion("v1.0.0").Pair("eee"), []string{"."}),
+	}}
+	m := &dep.Manifest{Constraints: gps.ProjectConstraints{
+		"github.com/e/e": gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+	}}
+
+	hints := collectConstraintHints(&dep.Ctx{Out: discardLogger, Err: discardLogger}, l, sm)
+	if len(hints) != 3 {
+		t.Fatalf("expected hints on three projects, got %v", hints)
+	}
+	if h := hints["github.com/c/c"]; len(h) != 2 || h[0].From != "github.com/a/a" || h[0].Constraint.String() != "^1.2.0" || h[1].From != "github.com/b/b" {
+		t.Errorf("unexpected hints on github.com/c/c: %v", h)
+	}
+
+	var buf bytes.Buffer
+	writeHints(&buf, statusHints(m, l, hints))
+	want := `PROJECT         CONSTRAINT  HINTS
+github.com/c/c  -           ^1.2.0 from github.com/a/a, >=1.0.0, <1.5.0 from github.com/b/b
+github.com/d/d  -           master from github.com/a/a, develop from github.com/b/b
+github.com/e/e  master      ^1.0.0 from github.com/a/a
+`
+	if buf.String() != want {
+		t.Errorf("unexpected hints table:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), want)
+	}
+
+	roots := map[gps.ProjectRoot]bool{"github.com/c/c": true, "github.com/d/d": true, "github.com/e/e": true}
+	appender := hintConstraints(m, roots, hints, l)
+	if len(appender.Constraints) != 1 {
+		t.Fatalf("expected a constraint on github.com/c/c only, got %v", appender.Constraints)
+	}
+	if pp := appender.Constraints["github.com/c/c"]; pp.Constraint.String() != ">=1.2.0, <1.5.0" || pp.Source != "https://example.com/c" {
+		t.Errorf("unexpected constraint on github.com/c/c: %+v", pp)
+	}
+}
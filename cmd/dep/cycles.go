@@ -0,0 +1,137 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const cyclesShortHelp = `Report import cycles among the project and its dependencies`
+const cyclesLongHelp = `
+Analyze the imports of the packages of the project and of all the
+dependencies in Gopkg.lock, and report:
+
+  - cycles of imports between packages, which the go tool refuses to build
+  - cycles of imports between projects, which build, but are one import away
+    from a cycle between packages
+
+The packages of dependencies are read from vendor/ when it holds them, and
+from the locked versions in the cache otherwise. Test imports are ignored.
+
+dep cycles exits with an error when cycles between packages are found.
+`
+
+func (cmd *cyclesCommand) Name() string      { return "cycles" }
+func (cmd *cyclesCommand) Args() string      { return "" }
+func (cmd *cyclesCommand) ShortHelp() string { return cyclesShortHelp }
+func (cmd *cyclesCommand) LongHelp() string  { return cyclesLongHelp }
+func (cmd *cyclesCommand) Hidden() bool      { return false }
+
+func (cmd *cyclesCommand) Register(fs *flag.FlagSet) {}
+
+type cyclesCommand struct{}
+
+func (cmd *cyclesCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep cycles takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	trees, err := collectPackageTrees(p, sm)
+	if err != nil {
+		return err
+	}
+	ic := dep.FindImportCycles(trees)
+
+	var buf bytes.Buffer
+	writeImportCycles(&buf, ic)
+	ctx.Out.Print(buf.String())
+
+	if len(ic.Packages) > 0 {
+		return errors.New("import cycles found")
+	}
+	return nil
+}
+
+// collectPackageTrees returns the package trees of the project and of the
+// projects of its lock, keyed by their roots.
+func collectPackageTrees(p *dep.Project, sm gps.SourceManager) (map[gps.ProjectRoot]pkgtree.PackageTree, error) {
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the packages of the project")
+	}
+	trees := map[gps.ProjectRoot]pkgtree.PackageTree{p.ImportRoot: ptree}
+	if p.Lock == nil {
+		return trees, nil
+	}
+
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		dir := filepath.Join(p.VendorDir(), filepath.FromSlash(string(pr)))
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			ptree, err = pkgtree.ListPackages(dir, string(pr))
+		} else {
+			ptree, err = sm.ListPackages(lp.Ident(), lp.Version())
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the packages of %s", pr)
+		}
+		trees[pr] = ptree
+	}
+	return trees, nil
+}
+
+func writeImportCycles(w io.Writer, ic dep.ImportCycles) {
+	if ic.Empty() {
+		fmt.Fprintln(w, "No import cycles found.")
+		return
+	}
+
+	if len(ic.Packages) > 0 {
+		fmt.Fprintln(w, "Import cycles between packages:")
+		for _, c := range ic.Packages {
+			fmt.Fprintf(w, "  %s\n", strings.Join(c, " -> "))
+		}
+	}
+
+	if len(ic.Projects) > 0 {
+		if len(ic.Packages) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "Import cycles between projects:")
+		for _, c := range ic.Projects {
+			roots := make([]string, len(c.Projects))
+			for i, pr := range c.Projects {
+				roots[i] = string(pr)
+			}
+			fmt.Fprintf(w, "  %s\n", strings.Join(roots, " -> "))
+			for _, imp := range c.Imports {
+				fmt.Fprintf(w, "    %s imports %s\n", imp.From, imp.To)
+			}
+		}
+	}
+}
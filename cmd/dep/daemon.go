@@ -0,0 +1,270 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const daemonShortHelp = `Share a source manager among dep invocations`
+const daemonLongHelp = `
+Serve the operations on dependency sources of the other dep invocations of the
+host over a unix socket, so that they share the clones, the locks and the
+import path deductions of a single long-running process instead of each
+working on the cache on its own.
+
+Point dep at the daemon by setting DEPDAEMON to its socket:
+
+  DEPDAEMON=/var/run/dep.sock dep ensure
+
+When no daemon answers on the socket, dep starts one in the background, which
+exits after being idle for 30 minutes, and logs to the socket path with a .log
+suffix. The daemon uses the cache directory and the DEP* settings of the
+environment it was started in. It refuses the clients with other settings
+for the sources, such as credentials, DEPNOVENDORNET or -offline, or the
+source-override and module-proxy of their Gopkg.toml, as it would ignore
+them. As it acts with the permissions of its user, only that user may connect
+to its socket.
+
+The socket defaults to DEPDAEMON, or to daemon.sock in the cache directory
+($GOPATH/pkg/dep).
+`
+
+// daemonIdleTimeout is how long the daemons started on demand live without
+// being called.
+const daemonIdleTimeout = 30 * time.Minute
+
+// daemonStartTimeout is how long to wait for a daemon started on demand to
+// listen on its socket.
+const daemonStartTimeout = 10 * time.Second
+
+type daemonCommand struct {
+	socket string
+	idle   time.Duration
+}
+
+func (cmd *daemonCommand) Name() string      { return "daemon" }
+func (cmd *daemonCommand) Args() string      { return "[-socket path] [-idle duration]" }
+func (cmd *daemonCommand) ShortHelp() string { return daemonShortHelp }
+func (cmd *daemonCommand) LongHelp() string  { return daemonLongHelp }
+func (cmd *daemonCommand) Hidden() bool      { return false }
+
+func (cmd *daemonCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.socket, "socket", "", "unix socket to listen on")
+	fs.DurationVar(&cmd.idle, "idle", 0, "exit after being idle for this long; zero never exits")
+}
+
+func (cmd *daemonCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep daemon takes no arguments")
+	}
+	if cmd.idle < 0 {
+		return errors.Errorf("-idle must not be negative, got %s", cmd.idle)
+	}
+
+	socket := cmd.socket
+	if socket == "" {
+		socket = ctx.Daemon
+	}
+	if socket == "" {
+		socket = filepath.Join(ctx.CachePath(), "daemon.sock")
+	}
+
+	// The daemon serves its own source manager.
+	ctx.Daemon = ""
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	defer sm.Release()
+
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		return err
+	}
+	ln = peerCheckListener{Listener: ln, logger: ctx.Err}
+
+	// Closing the listener on interrupt or once idle makes http.Serve return,
+	// so that the source manager gets released.
+	stopped := make(chan struct{})
+	var stop sync.Once
+	stopServing := func() {
+		stop.Do(func() {
+			close(stopped)
+			ln.Close()
+		})
+	}
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt)
+	defer signal.Stop(sigch)
+	go func() {
+		<-sigch
+		stopServing()
+	}()
+
+	tracker := &idleTracker{last: time.Now()}
+	if cmd.idle > 0 {
+		go func() {
+			for {
+				select {
+				case <-stopped:
+					return
+				case <-time.After(cmd.idle / 10):
+				}
+				if tracker.idleFor() >= cmd.idle {
+					ctx.Err.Printf("Idle for %s, exiting", cmd.idle)
+					stopServing()
+					return
+				}
+			}
+		}()
+	}
+
+	ctx.Err.Printf("Serving sources on %s", socket)
+	handler := gps.NewSourceManagerDaemon(sm, ctx.SourceSettings(), ctx.Err, dep.Analyzer{}, dep.Analyzer{HintNestedVendor: true})
+	err = http.Serve(ln, tracker.wrap(handler))
+	select {
+	case <-stopped:
+		return nil
+	default:
+		return errors.Wrap(err, "daemon failed")
+	}
+}
+
+// listenDaemon listens on the unix socket, replacing the socket left by a
+// daemon which is gone. It fails if another daemon still answers on it. Only
+// the user of the daemon may connect to the socket, as the daemon acts with its
+// permissions, such as when exporting projects.
+func listenDaemon(socket string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, errors.Errorf("a daemon already listens on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to remove the stale daemon socket")
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0777); err != nil {
+		return nil, err
+	}
+	ln, err := listenPrivate(socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "daemon failed")
+	}
+	return ln, nil
+}
+
+// peerCheckListener drops the connections of clients which checkDaemonPeer
+// refuses, such as those of other users allowed in by the mode of the socket.
+type peerCheckListener struct {
+	net.Listener
+	logger *log.Logger
+}
+
+func (ln peerCheckListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDaemonPeer(conn); err != nil {
+			ln.logger.Printf("Dropped a connection: %s", err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// idleTracker tells how long its wrapped handlers have been idle.
+type idleTracker struct {
+	mu     sync.Mutex
+	active int
+	last   time.Time
+}
+
+func (t *idleTracker) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		t.active++
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			t.active--
+			t.last = time.Now()
+			t.mu.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// idleFor returns how long no request has been served, zero while serving
+// some.
+func (t *idleTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active > 0 {
+		return 0
+	}
+	return time.Since(t.last)
+}
+
+// startDaemon starts dep daemon in the background with the dep executable exe
+// and the environment env, to serve on the unix socket, and waits for it to
+// listen. Another dep invocation starting a daemon on the socket at the same
+// time is fine.
+func startDaemon(exe string, env []string, socket string) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0777); err != nil {
+		return err
+	}
+	logf, err := os.OpenFile(socket+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to start the dep daemon")
+	}
+	defer logf.Close()
+
+	cmd := exec.Command(exe, "daemon", "-socket", socket, "-idle", daemonIdleTimeout.String())
+	cmd.Env = env
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	detachDaemon(cmd)
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start the dep daemon")
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case err := <-exited:
+			// It may have found another daemon listening already.
+			if conn, derr := net.Dial("unix", socket); derr == nil {
+				conn.Close()
+				return nil
+			}
+			return errors.Errorf("the dep daemon exited (%v), see %s", err, logf.Name())
+		case <-deadline:
+			return errors.Errorf("the dep daemon didn't listen on %s within %s, see %s", socket, daemonStartTimeout, logf.Name())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// checkDaemonPeer fails unless the process on the other end of conn runs as the
+// user of the daemon, which is told by the credentials of unix sockets.
+func checkDaemonPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.Errorf("unexpected connection over %s", conn.LocalAddr().Network())
+	}
+	f, err := uc.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	// File leaves the duplicate, and so conn, in blocking mode.
+	defer syscall.SetNonblock(fd, true)
+
+	cred, err := syscall.GetsockoptUcred(fd, syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil {
+		return errors.Wrap(err, "unable to tell the user of the client")
+	}
+	if int(cred.Uid) != os.Getuid() {
+		return errors.Errorf("refused client %d of user %d", cred.Pid, cred.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestListenDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets aren't supported on windows")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("run")
+	socket := filepath.Join(h.Path("run"), "dep.sock")
+
+	ln, err := listenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	ln = peerCheckListener{Listener: ln, logger: log.New(&buf, "", 0)}
+	defer ln.Close()
+
+	// Other users may not connect.
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected the socket to be only accessible to its user, got mode %o", mode)
+	}
+
+	// The user of the daemon may.
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_, err = conn.Write([]byte("ok"))
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	b := make([]byte, 2)
+	if _, err := conn.Read(b); err != nil || string(b) != "ok" {
+		t.Fatalf("expected the connection to be served, got %q, %v (%s)", b, err, buf.String())
+	}
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+
+	// A second daemon can't listen on the same socket.
+	if _, err := listenDaemon(socket); err == nil {
+		t.Error("expected to fail to listen on the socket of a running daemon")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !windows
+
+package main
+
+import (
+	"net"
+	"os/exec"
+	"syscall"
+)
+
+// listenPrivate listens on the unix socket, creating it only accessible to the
+// user of the process, as setting its mode once created would leave a window
+// for other users to connect.
+func listenPrivate(socket string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", socket)
+}
+
+// detachDaemon makes the daemon started by cmd outlive the session of dep, and
+// its signals.
+func detachDaemon(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
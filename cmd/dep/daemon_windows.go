@@ -0,0 +1,18 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"os/exec"
+)
+
+// detachDaemon does nothing, the daemon outliving dep on windows already.
+func detachDaemon(cmd *exec.Cmd) {}
+
+// listenPrivate listens on the unix socket, which has no mode on windows.
+func listenPrivate(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
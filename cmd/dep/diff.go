@@ -0,0 +1,320 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const diffShortHelp = `Summarize the changes between Gopkg.lock and another lock`
+const diffLongHelp = `
+Compare Gopkg.lock with another lock, and print the projects which were added,
+removed or modified, along with the changes of their versions and revisions.
+
+  dep diff [<git-ref> | <path>]
+
+Compare the lock of the given git commit, or the lock at the given path, to
+Gopkg.lock, showing what changed since. The lock of HEAD is compared if none is
+given, which shows the uncommitted changes of Gopkg.lock.
+
+  dep diff -solve [-update]
+
+Compare Gopkg.lock to the lock of a fresh solve, showing what dep ensure would
+change, or dep ensure -update with -update. Nothing is written.
+
+Each project is printed on a line starting with + when it was added, - when it
+was removed and ~ when it was modified, followed by its version and revision.
+Changes of the source of a modified project, and the packages added to (+) or
+removed from (-) it, end the line. With -json, the changes are
+printed as a JSON array instead.
+`
+
+type diffCommand struct {
+	json   bool
+	solve  bool
+	update bool
+}
+
+func (cmd *diffCommand) Name() string      { return "diff" }
+func (cmd *diffCommand) Args() string      { return "[-json] [-solve [-update] | <git-ref> | <path>]" }
+func (cmd *diffCommand) ShortHelp() string { return diffShortHelp }
+func (cmd *diffCommand) LongHelp() string  { return diffLongHelp }
+func (cmd *diffCommand) Hidden() bool      { return false }
+
+func (cmd *diffCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+	fs.BoolVar(&cmd.solve, "solve", false, "compare to the lock of a fresh solve")
+	fs.BoolVar(&cmd.update, "update", false, "with -solve, allow the solve to update the locked versions")
+}
+
+func (cmd *diffCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 1 {
+		return errors.New("dep diff takes at most one git ref or lock path")
+	}
+	if cmd.solve && len(args) > 0 {
+		return errors.New("dep diff -solve takes no arguments")
+	}
+	if cmd.update && !cmd.solve {
+		return errors.New("-update only applies along with -solve")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to compare", dep.LockName)
+	}
+
+	var from, to *dep.Lock
+	var target string
+	if cmd.solve {
+		target = "a fresh solve"
+		from = p.Lock
+		if to, err = cmd.solveLock(ctx, p); err != nil {
+			return err
+		}
+	} else {
+		target = "HEAD"
+		if len(args) > 0 {
+			target = args[0]
+		}
+		to = p.Lock
+		if from, err = loadLockAt(p, target); err != nil {
+			return err
+		}
+	}
+
+	deltas := diffLockProjects(from, to)
+
+	var buf bytes.Buffer
+	if cmd.json {
+		if err := writeLockDiffJSON(&buf, deltas); err != nil {
+			return err
+		}
+	} else if len(deltas) == 0 {
+		fmt.Fprintf(&buf, "%s matches %s.\n", filepath.Base(p.LockPath()), target)
+	} else {
+		writeLockDiffText(&buf, deltas)
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// solveLock solves the dependencies of p as dep ensure would, and returns the
+// resulting lock.
+func (cmd *diffCommand) solveLock(ctx *dep.Ctx, p *dep.Project) (*dep.Lock, error) {
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return nil, err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	params := p.MakeParams()
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Err
+	}
+	params.TraceEvents = ctx.TraceEvents
+	params.RootPackageTree, err = pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return nil, errors.Wrap(err, "analysis of local packages failed")
+	}
+	params.ChangeAll = cmd.update
+
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare solver")
+	}
+	solution, err := solver.Solve()
+	if err != nil {
+		handleAllTheFailuresOfTheWorld(ctx, err)
+		return nil, errors.Wrap(err, "solve")
+	}
+	return dep.LockFromSolution(solution), nil
+}
+
+// loadLockAt loads the lock at the given path if there is a file there, or
+// the lock of p as of the given git commit otherwise.
+func loadLockAt(p *dep.Project, target string) (*dep.Lock, error) {
+	if fi, err := os.Stat(target); err == nil && !fi.IsDir() {
+		f, err := os.Open(target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open %s", target)
+		}
+		defer f.Close()
+		l, err := dep.ReadLock(f)
+		return l, errors.Wrapf(err, "unable to read the lock at %s", target)
+	}
+
+	name := filepath.Base(p.LockPath())
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "show", target+":./"+name)
+	cmd.Dir = p.AbsRoot
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("%s is neither a lock file nor a git ref with a %s: %s", target, name, msg)
+		}
+		return nil, errors.Wrapf(err, "git show of %s at %s failed", name, target)
+	}
+	l, err := dep.ReadLock(&stdout)
+	return l, errors.Wrapf(err, "unable to read the %s of %s", name, target)
+}
+
+// lockDelta describes how a project changed from one lock to another.
+type lockDelta struct {
+	ProjectRoot gps.ProjectRoot
+	// Change is one of "added", "removed" or "modified".
+	Change   string
+	Source   *gps.StringDiff `json:",omitempty"`
+	Version  gps.StringDiff
+	Revision gps.StringDiff
+	// Packages are the packages added to or removed from a modified project.
+	Packages []gps.StringDiff `json:",omitempty"`
+}
+
+type byDeltaRoot []lockDelta
+
+func (s byDeltaRoot) Len() int           { return len(s) }
+func (s byDeltaRoot) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byDeltaRoot) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
+
+// diffLockProjects returns the changes to the projects from lock from to lock
+// to, sorted by project root.
+func diffLockProjects(from, to *dep.Lock) []lockDelta {
+	fromProjects := make(map[gps.ProjectRoot]gps.LockedProject, len(from.P))
+	for _, lp := range from.P {
+		fromProjects[lp.Ident().ProjectRoot] = lp
+	}
+
+	var deltas []lockDelta
+	for _, lp := range to.P {
+		pr := lp.Ident().ProjectRoot
+		old, has := fromProjects[pr]
+		if !has {
+			deltas = append(deltas, newLockDelta("added", nil, &lp))
+			continue
+		}
+		delete(fromProjects, pr)
+
+		pd := gps.DiffProjects(old, lp)
+		if pd == nil {
+			continue
+		}
+		d := newLockDelta("modified", &old, &lp)
+		d.Source = pd.Source
+		d.Packages = pd.Packages
+		deltas = append(deltas, d)
+	}
+	for _, lp := range fromProjects {
+		lp := lp
+		deltas = append(deltas, newLockDelta("removed", &lp, nil))
+	}
+
+	sort.Sort(byDeltaRoot(deltas))
+	return deltas
+}
+
+// newLockDelta returns the change between the given projects, either of
+// which is nil when the project was added or removed.
+func newLockDelta(change string, from, to *gps.LockedProject) lockDelta {
+	d := lockDelta{Change: change}
+	if from != nil {
+		d.ProjectRoot = from.Ident().ProjectRoot
+		d.Version.Previous = versionName(from.Version())
+		d.Revision.Previous = string(revisionOf(from.Version()))
+	}
+	if to != nil {
+		d.ProjectRoot = to.Ident().ProjectRoot
+		d.Version.Current = versionName(to.Version())
+		d.Revision.Current = string(revisionOf(to.Version()))
+	}
+	return d
+}
+
+// versionName returns the name of the version v is paired with, if any.
+func versionName(v gps.Version) string {
+	if v == nil || v.Type() == gps.IsRevision {
+		return ""
+	}
+	return formatVersion(v)
+}
+
+func writeLockDiffText(w io.Writer, deltas []lockDelta) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, d := range deltas {
+		mark := "~"
+		switch d.Change {
+		case "added":
+			mark = "+"
+		case "removed":
+			mark = "-"
+		}
+		var notes []string
+		if d.Source != nil {
+			notes = append(notes, "source "+formatStringDiff(*d.Source, 0))
+		}
+		for _, pkg := range d.Packages {
+			if pkg.Current != "" {
+				notes = append(notes, "+"+pkg.Current)
+			} else {
+				notes = append(notes, "-"+pkg.Previous)
+			}
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%s", mark, d.ProjectRoot, formatStringDiff(d.Version, 0), formatStringDiff(d.Revision, 7))
+		if len(notes) > 0 {
+			fmt.Fprintf(tw, "\t%s", strings.Join(notes, " "))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// formatStringDiff formats a change of value, shortening each side to at most
+// n bytes if n isn't zero.
+func formatStringDiff(d gps.StringDiff, n int) string {
+	prev, cur := d.Previous, d.Current
+	if n > 0 && len(prev) > n {
+		prev = prev[:n]
+	}
+	if n > 0 && len(cur) > n {
+		cur = cur[:n]
+	}
+	switch {
+	case prev == cur || prev == "":
+		return cur
+	case cur == "":
+		return prev
+	}
+	return prev + " -> " + cur
+}
+
+func writeLockDiffJSON(w io.Writer, deltas []lockDelta) error {
+	if deltas == nil {
+		deltas = []lockDelta{}
+	}
+	b, err := json.MarshalIndent(deltas, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock diff")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestDiffLockProjects(t *testing.T) {
+	from := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+			gps.NewVersion("v0.7.0").Pair("a2d6902c6d2a2f194eb3fb474981ab7867c81505"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/sdboyer/deptest"},
+			gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "golang.org/x/net"},
+			gps.NewBranch("master").Pair("66aacef3dd8a676686c7ae3716979581e8b03c47"), []string{"context"}),
+	}}
+	to := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+			gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"), []string{".", "sub"}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/sdboyer/deptestdos"},
+			gps.Revision("5c607206be5decd28e6263ffffdcee067266015e"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "golang.org/x/net"},
+			gps.NewBranch("master").Pair("66aacef3dd8a676686c7ae3716979581e8b03c47"), []string{"context"}),
+	}}
+
+	deltas := diffLockProjects(from, to)
+	if len(deltas) != 3 {
+		t.Fatalf("expected 3 changed projects, got %+v", deltas)
+	}
+
+	var buf bytes.Buffer
+	writeLockDiffText(&buf, deltas)
+	want := `~ github.com/pkg/errors          v0.7.0 -> v0.8.0  a2d6902 -> 645ef00  +sub
+- github.com/sdboyer/deptest     v1.0.0            ff2948a
++ github.com/sdboyer/deptestdos                    5c60720
+`
+	if buf.String() != want {
+		t.Errorf("unexpected text output:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeLockDiffJSON(&buf, deltas); err != nil {
+		t.Fatal(err)
+	}
+	var raw []lockDelta
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw[0].Change != "modified" || raw[0].Version.Previous != "v0.7.0" || raw[0].Version.Current != "v0.8.0" {
+		t.Errorf("unexpected JSON delta for github.com/pkg/errors: %+v", raw[0])
+	}
+	if raw[1].Change != "removed" || raw[1].Version.Previous != "v1.0.0" || raw[1].Version.Current != "" {
+		t.Errorf("unexpected JSON delta for github.com/sdboyer/deptest: %+v", raw[1])
+	}
+	if raw[2].Change != "added" || raw[2].Revision.Current != "5c607206be5decd28e6263ffffdcee067266015e" {
+		t.Errorf("unexpected JSON delta for github.com/sdboyer/deptestdos: %+v", raw[2])
+	}
+
+	if deltas := diffLockProjects(to, to); len(deltas) != 0 {
+		t.Errorf("expected a lock to match itself, got %+v", deltas)
+	}
+}
@@ -12,9 +12,9 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/internal/gps"
@@ -29,6 +29,11 @@ Project spec:
 
   <import path>[:alt source URL][@<constraint>]
 
+The constraint may be a semver range (^1.2, ~1.2.0, >=1.2, <2), a branch or
+tag name, a revision, or latest for the versions compatible with the newest
+semver release. Alternate source URLs may hold '@' and ':' themselves, as in
+github.com/pkg/foo:git@git.internal.com:alt/foo.git@^1.2.
+
 
 Ensure gets a project into a complete, reproducible, and likely compilable state:
 
@@ -46,6 +51,19 @@ needed), but never touch vendor/.
 The effect of passing project spec arguments varies slightly depending on the
 combination of flags that are passed.
 
+The commands listed by the hooks table of Gopkg.toml run from the root of the
+project: those of pre-solve before solving, as to generate code, and those of
+post-write after writing Gopkg.lock and vendor/, as to patch vendored sources
+or regenerate build files. Post-write hooks find the changes made to Gopkg.lock
+in the file DEP_LOCK_DIFF points to, as dep diff -json prints them. Hooks don't
+run with -dry-run or -plan-out, nor with -no-hooks.
+
+If a solve fails, for instance because it was interrupted or lost the network,
+the versions it had selected so far are checkpointed in the cache directory.
+The next ensure with the same inputs tries those versions first, resuming about
+where the failed one stopped; sources already fetched are kept in the cache
+directory anyway.
+
 
 Examples:
 
@@ -67,7 +85,10 @@ dep ensure -vendor-only
 
     Write vendor/ from an exising Gopkg.lock file, without first verifying that
     the lock is in sync with imports and Gopkg.toml. (This may be useful for
-    e.g. strategically layering a Docker images)
+    e.g. strategically layering a Docker images) When vendor-checksums is set
+    in Gopkg.toml, the projects of vendor/ still matching their digest in
+    Gopkg.lock are kept as they are, and only the others are written again;
+    remove vendor/ first to write it all anew.
 
 dep ensure -add github.com/pkg/foo github.com/pkg/foo/bar
 
@@ -92,6 +113,29 @@ dep ensure -add github.com/pkg/foo:git.internal.com/alt/foo
 
     Specify an alternate location to treat as the upstream source for a dependency.
 
+dep ensure -add github.com/pkg/foo@latest github.com/pkg/bar:git@git.internal.com:alt/bar.git@^1.2
+
+    Introduce github.com/pkg/foo with a constraint allowing the versions
+    compatible with its newest semver release, and github.com/pkg/bar from an
+    alternate source over ssh with a semver range. Both the constraint and the
+    source are written to Gopkg.toml.
+
+dep ensure -sync-imports
+
+    As a plain "dep ensure", and also append a constraint to Gopkg.toml for
+    each imported project which has none, allowing the versions compatible
+    with the one in Gopkg.lock, as -add does. Projects locked to a bare
+    revision are left unconstrained.
+
+dep ensure -adopt-hints
+
+    As a plain "dep ensure", and also append a constraint to Gopkg.toml for
+    each imported project which has none, but which other dependencies
+    constrain in their own Gopkg.toml: the constraint allows the versions all
+    of them do, those they were built against. See them with
+    "dep status -hints". Combined with -sync-imports, the projects with no
+    hints get the constraints of -sync-imports.
+
 dep ensure -update github.com/pkg/foo github.com/pkg/bar
 
     Update a list of dependencies to the latest versions allowed by Gopkg.toml,
@@ -109,11 +153,111 @@ dep ensure -update -no-vendor
 
     As above, but only modify Gopkg.lock; leave vendor/ unchanged.
 
+dep ensure -update -patch
+dep ensure -update -minor github.com/pkg/foo
+
+    Update dependencies locked to a semver version only to the newest patch
+    release of the same minor version (-patch), or to the newest release of
+    the same major version (-minor), even if Gopkg.toml allows more. Other
+    versions are not considered, so the update may fail where a plain -update
+    would have crossed the boundary. Dependencies locked to a branch or a
+    revision are updated as usual. Useful to take low-risk updates
+    automatically.
+
+dep ensure -update -dry-run
+
+    Solve as above, but only print what would change: the projects Gopkg.lock
+    would gain or lose, and those whose version or revision would change, along
+    with the projects that would be written to vendor/. Nothing is written to
+    disk. -dry-run works with all the other modes of ensure but -apply-plan.
+
+dep ensure -verify
+
+    As a plain "dep ensure", but check each project written to vendor/ which
+    is locked as before against its digest in Gopkg.lock, and refuse to
+    proceed, leaving Gopkg.lock and vendor/ as they are, if any differs: the
+    source of the project no longer holds what it held at the locked revision,
+    as happens when its history is rewritten. The digests are recorded when
+    vendor-checksums is set in Gopkg.toml. Works with -vendor-only too.
+
+dep ensure -failure-json failure.json
+
+    When solving fails, dep explains why as a tree: for each version of the
+    project for which none could be selected, the constraints which excluded
+    it, and the projects they come from. With -failure-json, that explanation
+    is also written to failure.json, for tools to work on. Works with all the
+    other modes of ensure which solve.
+
+dep ensure -suggest
+dep ensure -suggest -apply-fix 2
+
+    When solving fails, also suggest changes to Gopkg.toml which would let in
+    one of the versions that were excluded: widening the constraint of the
+    project, or adding an override. With -apply-fix, make the suggested change
+    of the given number in Gopkg.toml, to solve with it on the next run.
+
+dep ensure -offline
+
+    As a plain "dep ensure", but without any network access: sources are only
+    served from the cache directory, and their version lists from the
+    persistent cache, if DEPCACHEAGE is set. If something needs the network,
+    ensure fails, listing the sources it would have contacted. With Gopkg.lock
+    in sync, and vendor-checksums set in Gopkg.toml so that the projects of
+    vendor/ matching their digest are kept, nothing needs to be fetched. Works
+    with all the modes of ensure; setting DEPNOVENDORNET=1 has the same effect,
+    for all commands.
+
+dep ensure -dev
+
+    If Gopkg.toml sets exclude-test-deps = true, dependencies which are only
+    imported by the tests of the project are marked as dev dependencies in
+    Gopkg.lock, and left out of vendor/. Pass this flag to vendor them as well,
+    e.g. to run the tests; the next "dep ensure" without it removes them
+    again. Combine it with -vendor-only to toggle them based on Gopkg.lock
+    alone.
+
+dep ensure -as-of 2017-03-01
+
+    Solve using only the versions of dependencies that were released before
+    March 1st, 2017, and their branches as they were then, ignoring the
+    versions recorded in Gopkg.lock. Useful to reproduce historical builds,
+    or to bisect which update broke the build. Only git repositories can
+    tell where their branches were; the branches of others which moved
+    since are left out.
+
+dep ensure -strategy prefer-minimal -no-vendor
+
+    Solve each dependency to the lowest version allowed by all the
+    constraints on it, ignoring the versions recorded in Gopkg.lock, and
+    record them in Gopkg.lock. Building and testing against the result checks
+    that the lower bounds of the constraints in Gopkg.toml are honest, or
+    reproduces bugs reported against them. A plain "dep ensure" keeps the
+    versions; "dep ensure -update" goes back to the newest ones.
+
+dep ensure -update -plan-out plan.json
+dep ensure -apply-plan plan.json
+
+    Compute the changes an update would make, and record them in plan.json
+    instead of making them: the new Gopkg.lock, the changes to the old one, and
+    the projects to write to vendor/. Once the plan is reviewed, make exactly
+    those changes, without solving again. The plan is refused if Gopkg.toml or
+    Gopkg.lock changed in between. -plan-out works with all the other modes of
+    ensure.
+
+dep ensure -update -report=markdown
+
+    Update all dependencies, and print a Markdown summary of the changes
+    suitable for a pull request description: the old and new versions, links
+    to the commits in between, possible breaking changes from major version
+    bumps, and license changes. The summary is printed once the changes are
+    written; with -dry-run or -plan-out, it's labeled as the changes the
+    update would make.
+
 `
 
 func (cmd *ensureCommand) Name() string { return "ensure" }
 func (cmd *ensureCommand) Args() string {
-	return "[-update | -add] [-no-vendor | -vendor-only] [-dry-run] [<spec>...]"
+	return "[-update [-patch | -minor] [-report=markdown] | -add | -sync-imports | -adopt-hints] [-no-vendor | -vendor-only] [-as-of <date>] [-strategy <name>] [-verify] [-offline] [-dry-run | -plan-out <file>] [-failure-json <file>] [-suggest [-apply-fix <n>]] [-no-hooks] [-stats] [<spec>...]"
 }
 func (cmd *ensureCommand) ShortHelp() string { return ensureShortHelp }
 func (cmd *ensureCommand) LongHelp() string  { return ensureLongHelp }
@@ -123,22 +267,58 @@ func (cmd *ensureCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.examples, "examples", false, "print detailed usage examples")
 	fs.BoolVar(&cmd.update, "update", false, "update the named dependencies (or all, if none are named) in Gopkg.lock to the latest allowed by Gopkg.toml")
 	fs.BoolVar(&cmd.add, "add", false, "add new dependencies, or populate Gopkg.toml with constraints for existing dependencies")
+	fs.BoolVar(&cmd.syncImports, "sync-imports", false, "append constraints to Gopkg.toml for the imported projects which have none")
+	fs.BoolVar(&cmd.adoptHints, "adopt-hints", false, "append the constraints dependencies declare on the imported projects which have none to Gopkg.toml")
 	fs.BoolVar(&cmd.vendorOnly, "vendor-only", false, "populate vendor/ from Gopkg.lock without updating it first")
 	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
 	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+	fs.BoolVar(&cmd.patch, "patch", false, "with -update, only update dependencies locked to a semver version to the newest patch release of their minor version")
+	fs.BoolVar(&cmd.minor, "minor", false, "with -update, only update dependencies locked to a semver version to the newest release of their major version")
+	fs.StringVar(&cmd.report, "report", "", "with -update, print a report of the changes in the given format (markdown)")
+	fs.BoolVar(&cmd.dev, "dev", false, "also vendor the dev dependencies that Gopkg.toml's exclude-test-deps leaves out")
+	fs.BoolVar(&cmd.dev, "include-test-deps", false, "same as -dev")
+	fs.StringVar(&cmd.asOf, "as-of", "", "only consider versions released before the given date (YYYY-MM-DD) or RFC 3339 timestamp")
+	fs.StringVar(&cmd.strategy, "strategy", "prefer-newest", "the versions to prefer when solving: prefer-newest or prefer-minimal")
+	fs.BoolVar(&cmd.stats, "stats", false, "print a breakdown of where the time went at exit")
+	fs.StringVar(&cmd.planOut, "plan-out", "", "write the changes that would be made to the given file as JSON, instead of making them")
+	fs.StringVar(&cmd.applyPlan, "apply-plan", "", "make the changes planned with -plan-out in the given file, without solving")
+	fs.BoolVar(&cmd.verify, "verify", false, "refuse to write vendor/ if a project no longer matches its digest in Gopkg.lock")
+	fs.StringVar(&cmd.failureJSON, "failure-json", "", "if solving fails, write the explanation of the failure to the given file as JSON")
+	fs.BoolVar(&cmd.suggest, "suggest", false, "if solving fails, suggest changes to Gopkg.toml which relax the conflicting constraints")
+	fs.IntVar(&cmd.applyFix, "apply-fix", 0, "with -suggest, make the suggested change of the given number in Gopkg.toml")
+	fs.BoolVar(&cmd.offline, "offline", false, "forbid all network access, serving sources from the cache directory only")
+	fs.BoolVar(&cmd.noHooks, "no-hooks", false, "skip the pre-solve and post-write hooks of Gopkg.toml")
 }
 
 type ensureCommand struct {
-	examples   bool
-	update     bool
-	add        bool
-	noVendor   bool
-	vendorOnly bool
-	dryRun     bool
-	overrides  stringSlice
+	examples    bool
+	update      bool
+	add         bool
+	syncImports bool
+	adoptHints  bool
+	noVendor    bool
+	vendorOnly  bool
+	dryRun      bool
+	report      string
+	patch       bool
+	minor       bool
+	asOf        string
+	strategy    string
+	overrides   stringSlice
+	dev         bool
+	stats       bool
+	rstats      *runStats
+	planOut     string
+	applyPlan   string
+	verify      bool
+	failureJSON string
+	suggest     bool
+	applyFix    int
+	offline     bool
+	noHooks     bool
 }
 
-func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
+func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) (err error) {
 	if cmd.examples {
 		ctx.Err.Println(strings.TrimSpace(ensureExamples))
 		return nil
@@ -152,23 +332,77 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := p.CheckGoVersion(); err != nil {
+		return err
+	}
+
+	if cmd.verify && (p.Lock == nil || len(p.Lock.Digests) == 0) {
+		return errors.Errorf("-verify needs the digests of the projects in %s; set vendor-checksums = true in %s and run dep ensure to record them", dep.LockName, dep.ManifestName)
+	}
 
+	if cmd.offline {
+		ctx.Offline = true
+	}
+	// Cached version lists would hide the releases made since they were.
+	ctx.RefreshVersionLists = cmd.update
 	sm, err := ctx.SourceManager()
 	if err != nil {
 		return err
 	}
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
+	defer recordNetworkUsage(ctx, "ensure", p, sm)
+	defer func() {
+		if err == nil {
+			recordLockSources(ctx, p, sm)
+		}
+	}()
+	if ctx.Offline {
+		defer func() { err = offlineError(err, sm) }()
+	}
+
+	if cmd.stats {
+		cmd.rstats = newRunStats()
+		defer cmd.rstats.report(ctx, sm)
+	}
+	// -stats breaks the network operations down already.
+	prog := startProgress(ctx, sm)
+	defer prog.stop(!cmd.stats)
 
 	params := p.MakeParams()
 	if ctx.Verbose {
 		params.TraceLogger = ctx.Err
 	}
+	params.TraceEvents = ctx.TraceEvents
+	if cmd.asOf != "" {
+		if params.AsOf, err = parseAsOf(cmd.asOf); err != nil {
+			return err
+		}
+	}
 
+	// The strategy was validated along with the flags.
+	params.Strategy, _ = gps.ParseStrategy(cmd.strategy)
+	switch {
+	case cmd.patch:
+		params.UpdateLevel = gps.UpdatePatch
+	case cmd.minor:
+		params.UpdateLevel = gps.UpdateMinor
+	}
+
+	if cmd.applyPlan != "" {
+		return cmd.runApplyPlan(ctx, args, p, sm)
+	}
 	if cmd.vendorOnly {
 		return cmd.runVendorOnly(ctx, args, p, sm, params)
 	}
 
+	// Hooks generating code must run before the imports are listed.
+	if cmd.runsHooks() {
+		if err := runPreSolveHooks(ctx, p); err != nil {
+			return err
+		}
+	}
+
 	params.RootPackageTree, err = pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
 	if err != nil {
 		return errors.Wrap(err, "ensure ListPackage for project")
@@ -191,13 +425,81 @@ func (cmd *ensureCommand) validateFlags() error {
 		return errors.New("cannot pass both -add and -update")
 	}
 
+	if cmd.syncImports && (cmd.add || cmd.update) {
+		return errors.New("-sync-imports only applies to a plain dep ensure; cannot pass it together with -add or -update")
+	}
+
+	if cmd.adoptHints && (cmd.add || cmd.update || cmd.vendorOnly) {
+		return errors.New("-adopt-hints only applies to a plain dep ensure; cannot pass it together with -add, -update or -vendor-only")
+	}
+
+	if cmd.patch || cmd.minor {
+		if !cmd.update {
+			return errors.New("-patch and -minor limit how far -update goes; pass -update too")
+		}
+		if cmd.patch && cmd.minor {
+			return errors.New("cannot pass both -patch and -minor")
+		}
+	}
+
+	if _, err := gps.ParseStrategy(cmd.strategy); err != nil {
+		return err
+	}
+
+	if cmd.report != "" {
+		if !cmd.update {
+			return errors.New("-report is only supported together with -update")
+		}
+		if cmd.report != "markdown" {
+			return errors.Errorf("unsupported report format %q; only markdown is supported", cmd.report)
+		}
+	}
+
+	if cmd.verify && cmd.noVendor {
+		return errors.New("-verify checks the projects written to vendor/; cannot pass it together with -no-vendor")
+	}
+
+	if cmd.applyFix != 0 {
+		if !cmd.suggest {
+			return errors.New("-apply-fix applies one of the fixes of -suggest; pass -suggest too")
+		}
+		if cmd.applyFix < 0 {
+			return errors.New("-apply-fix takes the number of a suggested fix, starting at 1")
+		}
+		if cmd.add || cmd.dryRun || cmd.planOut != "" {
+			return errors.New("-apply-fix changes Gopkg.toml; cannot pass it together with -add, -dry-run or -plan-out")
+		}
+	}
+
+	if cmd.planOut != "" && cmd.dryRun {
+		return errors.New("-plan-out already makes no changes; cannot pass it together with -dry-run")
+	}
+
+	if cmd.applyPlan != "" {
+		if cmd.add || cmd.update || cmd.syncImports || cmd.adoptHints || cmd.vendorOnly || cmd.noVendor || cmd.dev || cmd.asOf != "" || cmd.strategy != "prefer-newest" {
+			return errors.New("-apply-plan makes the changes as planned; cannot pass it together with flags which change them")
+		}
+		if cmd.dryRun || cmd.planOut != "" {
+			return errors.New("-apply-plan makes the changes as planned; review the plan itself instead of passing -dry-run or -plan-out")
+		}
+	}
+
 	if cmd.vendorOnly {
 		if cmd.update {
 			return errors.New("-vendor-only makes -update a no-op; cannot pass them together")
 		}
+		if cmd.asOf != "" {
+			return errors.New("-vendor-only does not solve, so -as-of would be a no-op; cannot pass them together")
+		}
+		if cmd.strategy != "prefer-newest" {
+			return errors.New("-vendor-only does not solve, so -strategy would be a no-op; cannot pass them together")
+		}
 		if cmd.add {
 			return errors.New("-vendor-only makes -add a no-op; cannot pass them together")
 		}
+		if cmd.syncImports {
+			return errors.New("-vendor-only does not look at imports, so -sync-imports would be a no-op; cannot pass them together")
+		}
 		if cmd.noVendor {
 			// TODO(sdboyer) can't think of anything not snarky right now
 			return errors.New("really?")
@@ -221,10 +523,23 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 		return errors.Wrap(err, "prepare solver")
 	}
 
-	if p.Lock != nil && bytes.Equal(p.Lock.InputHash(), solver.HashInputs()) {
+	// With an as-of time or the prefer-minimal strategy, the versions in the
+	// lock can't be trusted even if the memo matches, so a solve is always
+	// necessary. With -sync-imports and -adopt-hints, the constraints are
+	// derived from a solve too.
+	if p.Lock != nil && params.AsOf.IsZero() && params.Strategy != gps.PreferMinimal && !cmd.syncImports && !cmd.adoptHints && bytes.Equal(p.Lock.InputHash(), solver.HashInputs()) {
 		// Memo matches, so there's probably nothing to do.
 		if cmd.noVendor {
 			// The user said not to touch vendor/, so definitely nothing to do.
+			if cmd.planOut != "" {
+				sw, err := dep.NewSafeWriter(nil, p.Lock, p.Lock, dep.VendorNever)
+				if err != nil {
+					return err
+				}
+				sw.UseFileNames(p.ManifestName, p.LockName)
+				sw.UseVendorDir(p.Manifest.VendorDir)
+				return cmd.writePlan(sw, p, nil)
+			}
 			return nil
 		}
 
@@ -238,42 +553,158 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 		// that "verification" is supposed to look like (#121); in the meantime,
 		// we unconditionally write out vendor/ so that `dep ensure`'s behavior
 		// is maximally compatible with what it will eventually become.
-		sw, err := dep.NewSafeWriter(nil, p.Lock, p.Lock, dep.VendorAlways)
+		newLock, err := cmd.withDevProjects(ctx, p, params.RootPackageTree, p.Lock, sm)
+		if err != nil {
+			return err
+		}
+		sw, err := dep.NewSafeWriter(nil, p.Lock, newLock, dep.VendorAlways)
 		if err != nil {
 			return err
 		}
+		sw.UseFileNames(p.ManifestName, p.LockName)
+		sw.UseVendorDir(p.Manifest.VendorDir)
+		sw.ConfigureVendor(p.Manifest, newLock, cmd.dev, cmd.verify)
 
 		if cmd.dryRun {
 			ctx.Out.Printf("Would have populated vendor/ directory from %s", dep.LockName)
 			return nil
 		}
+		if cmd.planOut != "" {
+			return cmd.writePlan(sw, p, nil)
+		}
 
 		logger := ctx.Err
 		if !ctx.Verbose {
 			logger = log.New(ioutil.Discard, "", 0)
 		}
-		return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+		return errors.WithMessage(cmd.write(ctx, sw, p, sm, true, logger), "grouped write of manifest, lock and vendor")
 	}
 
-	solution, err := solver.Solve()
+	solution, err := cmd.solve(ctx, sm, params)
 	if err != nil {
-		handleAllTheFailuresOfTheWorld(err)
+		handleAllTheFailuresOfTheWorld(ctx, err)
+		if ferr := cmd.suggestFixes(ctx, p, sm, err); ferr != nil {
+			return ferr
+		}
 		return errors.Wrap(err, "ensure Solve()")
 	}
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), dep.VendorOnChanged)
+	newLock, err := cmd.withDevProjects(ctx, p, params.RootPackageTree, dep.LockFromSolution(solution), sm)
 	if err != nil {
 		return err
 	}
+	var extra []byte
+	if cmd.syncImports || cmd.adoptHints {
+		if extra, err = cmd.syncImportConstraints(ctx, p, sm, params, newLock); err != nil {
+			return err
+		}
+	}
+	sw, err := dep.NewSafeWriter(nil, p.Lock, newLock, dep.VendorOnChanged)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	sw.UseVendorDir(p.Manifest.VendorDir)
+	sw.ConfigureVendor(p.Manifest, newLock, cmd.dev, cmd.verify)
+	warnDuplicateProjects(ctx, newLock)
 	if cmd.dryRun {
+		if len(extra) > 0 {
+			ctx.Out.Printf("Would have appended to %s:\n%s", dep.ManifestName, extra)
+		}
 		return sw.PrintPreparedActions(ctx.Out)
 	}
+	if cmd.planOut != "" {
+		return cmd.writePlan(sw, p, extra)
+	}
 
 	logger := ctx.Err
 	if !ctx.Verbose {
 		logger = log.New(ioutil.Discard, "", 0)
 	}
-	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+	if err := errors.Wrap(cmd.write(ctx, sw, p, sm, false, logger), "grouped write of manifest, lock and vendor"); err != nil {
+		return err
+	}
+	return appendToManifest(p, extra)
+}
+
+// syncImportConstraints adds to the manifest of p a constraint for each project
+// imported or required by p which has no rules in it, derived from the hints
+// of the other projects in l with -adopt-hints, and from the version of the
+// project in l with -sync-imports. It updates the inputs digest of l
+// accordingly, and returns the constraints to append to the manifest on disk,
+// if any.
+func (cmd *ensureCommand) syncImportConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters, l *dep.Lock) ([]byte, error) {
+	rm, _ := params.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+	roots := make(map[gps.ProjectRoot]bool)
+	for _, ex := range append(rm.FlattenFn(paths.IsStandardImportPath), p.Manifest.Required...) {
+		root, err := sm.DeduceProjectRoot(ex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not deduce project root for %s", ex)
+		}
+		roots[root] = true
+	}
+
+	appender := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	if cmd.adoptHints {
+		appender = hintConstraints(p.Manifest, roots, collectConstraintHints(ctx, l, sm), l)
+	}
+	if cmd.syncImports {
+		for pr, pp := range importConstraints(p.Manifest, roots, l).Constraints {
+			if _, has := appender.Constraints[pr]; !has {
+				appender.Constraints[pr] = pp
+			}
+		}
+	}
+	if len(appender.Constraints) == 0 {
+		return nil, nil
+	}
+	if ctx.Verbose {
+		added := make([]string, 0, len(appender.Constraints))
+		for pr := range appender.Constraints {
+			added = append(added, string(pr))
+		}
+		sort.Strings(added)
+		ctx.Err.Printf("Adding constraints to %s for the imported projects:\n\t%s\n", dep.ManifestName, strings.Join(added, "\n\t"))
+	}
+
+	// The constraints are inputs of the solve; the lock satisfies them, but
+	// has to record the digest of the inputs including them to stay in sync.
+	for pr, pp := range appender.Constraints {
+		p.Manifest.Constraints[pr] = pp
+	}
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare solver")
+	}
+	l.SolveMeta.InputsDigest = solver.HashInputs()
+
+	extra, err := appender.MarshalTOML()
+	return extra, errors.Wrap(err, "could not marshal manifest into TOML")
+}
+
+// importConstraints returns a manifest holding a constraint for each of the
+// given project roots which has no rules in m, allowing the versions
+// compatible with that in l. Projects locked to a bare revision, or missing
+// from l, are left out.
+func importConstraints(m *dep.Manifest, roots map[gps.ProjectRoot]bool, l gps.Lock) *dep.Manifest {
+	appender := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		if !roots[pr] || m.HasConstraintsOn(pr) {
+			continue
+		}
+		pp := getProjectPropertiesFromVersion(lp.Version())
+		if pp.Constraint == nil {
+			continue
+		}
+		pp.Source = lp.Ident().Source
+		appender.Constraints[pr] = pp
+	}
+	return appender
 }
 
 func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -290,6 +721,14 @@ func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Proj
 	if err != nil {
 		return err
 	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	sw.UseVendorDir(p.Manifest.VendorDir)
+	// The dev projects are taken from the lock as they are, so that toggling
+	// them in and out of vendor/ is reproducible.
+	sw.ConfigureVendor(p.Manifest, p.Lock, cmd.dev, cmd.verify)
+	// The lock is the same as when vendor/ was written, so the projects still
+	// matching their digests needn't be written again.
+	sw.ReuseUnchangedVendor()
 
 	if cmd.dryRun {
 		ctx.Out.Printf("Would have populated vendor/ directory from %s", dep.LockName)
@@ -301,12 +740,15 @@ func (cmd *ensureCommand) runVendorOnly(ctx *dep.Ctx, args []string, p *dep.Proj
 		}
 		return nil
 	}
+	if cmd.planOut != "" {
+		return cmd.writePlan(sw, p, nil)
+	}
 
 	logger := ctx.Err
 	if !ctx.Verbose {
 		logger = log.New(ioutil.Discard, "", 0)
 	}
-	return errors.WithMessage(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor")
+	return errors.WithMessage(cmd.write(ctx, sw, p, sm, true, logger), "grouped write of manifest, lock and vendor")
 }
 
 func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -375,33 +817,73 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		params.ToChange = append(params.ToChange, gps.ProjectRoot(arg))
 	}
 
-	// Re-prepare a solver now that our params are complete.
-	solver, err = gps.Prepare(params, sm)
-	if err != nil {
-		return errors.Wrap(err, "fastpath solver prepare")
-	}
-	solution, err := solver.Solve()
+	solution, err := cmd.solve(ctx, sm, params)
 	if err != nil {
 		// TODO(sdboyer) special handling for warning cases as described in spec
 		// - e.g., named projects did not upgrade even though newer versions
 		// were available.
-		handleAllTheFailuresOfTheWorld(err)
+		handleAllTheFailuresOfTheWorld(ctx, err)
+		if ferr := cmd.suggestFixes(ctx, p, sm, err); ferr != nil {
+			return ferr
+		}
 		return errors.Wrap(err, "ensure Solve()")
 	}
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), dep.VendorOnChanged)
+	newLock, err := cmd.withDevProjects(ctx, p, params.RootPackageTree, dep.LockFromSolution(solution), sm)
 	if err != nil {
 		return err
 	}
+	sw, err := dep.NewSafeWriter(nil, p.Lock, newLock, dep.VendorOnChanged)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	sw.UseVendorDir(p.Manifest.VendorDir)
+	sw.ConfigureVendor(p.Manifest, newLock, cmd.dev, cmd.verify)
+	warnDuplicateProjects(ctx, newLock)
+
+	// The report is only printed as is once the changes are written, so that
+	// it isn't taken for a record of changes which failed to be.
+	var report string
+	if cmd.report != "" {
+		changes, err := collectUpgradeChanges(sm, p.Lock, newLock)
+		if err != nil {
+			return errors.Wrap(err, "failed to collect changes for the report")
+		}
+		var buf bytes.Buffer
+		if err := writeMarkdownReport(&buf, changes); err != nil {
+			return err
+		}
+		report = buf.String()
+	}
+
 	if cmd.dryRun {
+		if report != "" {
+			ctx.Out.Printf("Dry run, nothing was written. The update would make these changes:\n\n%s", report)
+		}
 		return sw.PrintPreparedActions(ctx.Out)
 	}
+	if cmd.planOut != "" {
+		if err := cmd.writePlan(sw, p, nil); err != nil {
+			return err
+		}
+		if report != "" {
+			ctx.Out.Printf("Nothing was written but the plan. Applying %s would make these changes:\n\n%s", cmd.planOut, report)
+		}
+		return nil
+	}
 
 	logger := ctx.Err
 	if !ctx.Verbose {
 		logger = log.New(ioutil.Discard, "", 0)
 	}
-	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+	if err := cmd.write(ctx, sw, p, sm, false, logger); err != nil {
+		return errors.Wrap(err, "grouped write of manifest, lock and vendor")
+	}
+	if report != "" {
+		ctx.Out.Print(report)
+	}
+	return nil
 }
 
 func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
@@ -589,15 +1071,13 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 		}
 	}
 
-	// Re-prepare a solver now that our params are complete.
-	solver, err = gps.Prepare(params, sm)
-	if err != nil {
-		return errors.Wrap(err, "fastpath solver prepare")
-	}
-	solution, err := solver.Solve()
+	solution, err := cmd.solve(ctx, sm, params)
 	if err != nil {
 		// TODO(sdboyer) detect if the failure was specifically about some of the -add arguments
-		handleAllTheFailuresOfTheWorld(err)
+		handleAllTheFailuresOfTheWorld(ctx, err)
+		if ferr := cmd.suggestFixes(ctx, p, sm, err); ferr != nil {
+			return ferr
+		}
 		return errors.Wrap(err, "ensure Solve()")
 	}
 
@@ -642,25 +1122,36 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	}
 	sort.Strings(reqlist)
 
-	sw, err := dep.NewSafeWriter(nil, p.Lock, dep.LockFromSolution(solution), dep.VendorOnChanged)
+	newLock, err := cmd.withDevProjects(ctx, p, params.RootPackageTree, dep.LockFromSolution(solution), sm)
 	if err != nil {
 		return err
 	}
+	sw, err := dep.NewSafeWriter(nil, p.Lock, newLock, dep.VendorOnChanged)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	sw.UseVendorDir(p.Manifest.VendorDir)
+	sw.ConfigureVendor(p.Manifest, newLock, cmd.dev, cmd.verify)
+	warnDuplicateProjects(ctx, newLock)
 
 	if cmd.dryRun {
 		return sw.PrintPreparedActions(ctx.Out)
 	}
+	if cmd.planOut != "" {
+		return cmd.writePlan(sw, p, extra)
+	}
 
 	logger := ctx.Err
 	if !ctx.Verbose {
 		logger = log.New(ioutil.Discard, "", 0)
 	}
-	if err := errors.Wrap(sw.Write(p.AbsRoot, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
+	if err := errors.Wrap(cmd.write(ctx, sw, p, sm, true, logger), "grouped write of manifest, lock and vendor"); err != nil {
 		return err
 	}
 
 	// FIXME(sdboyer) manifest writes ABSOLUTELY need verification - follow up!
-	f, err := os.OpenFile(filepath.Join(p.AbsRoot, dep.ManifestName), os.O_APPEND|os.O_WRONLY, 0666)
+	f, err := os.OpenFile(p.ManifestPath(), os.O_APPEND|os.O_WRONLY, 0666)
 	if err != nil {
 		return errors.Wrapf(err, "opening %s failed", dep.ManifestName)
 	}
@@ -709,31 +1200,253 @@ func (s *stringSlice) Set(value string) error {
 	*s = append(*s, value)
 	return nil
 }
-func getProjectConstraint(arg string, sm gps.SourceManager) (gps.ProjectConstraint, string, error) {
-	emptyPC := gps.ProjectConstraint{
-		Constraint: gps.Any(), // default to any; avoids panics later
+
+// withDevProjects returns a copy of l in which the projects only reachable
+// through the root project's tests are marked as dev projects, if the manifest
+// sets exclude-test-deps.
+func (cmd *ensureCommand) withDevProjects(ctx *dep.Ctx, p *dep.Project, ptree pkgtree.PackageTree, l *dep.Lock, sm gps.SourceManager) (*dep.Lock, error) {
+	nl := *l
+	nl.Dev = nil
+	if !p.Manifest.ExcludeTestDeps {
+		return &nl, nil
+	}
+
+	dev, err := dep.TestOnlyProjects(ptree, p.Manifest, l, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not determine dev dependencies")
+	}
+	if len(dev) > 0 {
+		nl.Dev = dev
+	}
+
+	if ctx.Verbose && len(dev) > 0 {
+		roots := make([]string, 0, len(dev))
+		for pr := range dev {
+			roots = append(roots, string(pr))
+		}
+		sort.Strings(roots)
+		ctx.Err.Printf("Dev dependencies, only imported by tests:\n\t%s\n", strings.Join(roots, "\n\t"))
+	}
+
+	return &nl, nil
+}
+
+// write runs sw.Write with the workers of ctx, recording how long writing
+// vendor/ took for -stats.
+func (cmd *ensureCommand) write(ctx *dep.Ctx, sw *dep.SafeWriter, p *dep.Project, sm gps.SourceManager, examples bool, logger *log.Logger) error {
+	sw.ExportWorkers(ctx.Workers)
+	start := time.Now()
+	err := sw.Write(p.AbsRoot, sm, examples, logger)
+	cmd.rstats.wrote(start, p.VendorDir(), sw)
+	if err != nil {
+		return err
+	}
+	policy := dep.NestedVendorStrip
+	if p.Manifest != nil {
+		policy = p.Manifest.NestedVendorPolicy()
+	}
+	warnNestedVendorConflicts(ctx, sw.NestedVendorConflicts(), policy)
+
+	if cmd.runsHooks() {
+		return runPostWriteHooks(ctx, p, p.Lock)
+	}
+	return nil
+}
+
+// runsHooks tells whether to run the hooks of Gopkg.toml, which are skipped
+// when no changes are to be made.
+func (cmd *ensureCommand) runsHooks() bool {
+	return !cmd.noHooks && !cmd.dryRun && cmd.planOut == ""
+}
+
+// writePlan writes the plan of the changes sw would make to the -plan-out
+// file, along with the constraints to append to the manifest, if any.
+func (cmd *ensureCommand) writePlan(sw *dep.SafeWriter, p *dep.Project, manifestAppend []byte) error {
+	plan, err := sw.Plan(p.AbsRoot)
+	if err != nil {
+		return err
+	}
+	plan.ManifestAppend = string(manifestAppend)
+
+	var buf bytes.Buffer
+	if err := dep.WritePlan(&buf, plan); err != nil {
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(cmd.planOut, buf.Bytes(), 0666), "failed to write the plan to %s", cmd.planOut)
+}
+
+// runApplyPlan makes the changes recorded in the -apply-plan file, provided
+// the manifest and lock didn't change since it was written.
+func (cmd *ensureCommand) runApplyPlan(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager) error {
+	if len(args) != 0 {
+		return errors.New("dep ensure -apply-plan takes no spec arguments")
+	}
+
+	f, err := os.Open(cmd.applyPlan)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the plan")
+	}
+	plan, err := dep.ReadPlan(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	sw, err := dep.NewSafeWriterFromPlan(p.AbsRoot, plan)
+	if err != nil {
+		return errors.Wrap(err, "cannot apply the plan")
+	}
+
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	if err := errors.Wrap(cmd.write(ctx, sw, p, sm, false, logger), "grouped write of manifest, lock and vendor"); err != nil {
+		return err
+	}
+	return appendToManifest(p, []byte(plan.ManifestAppend))
+}
+
+// appendToManifest appends extra, if any, to the manifest of p.
+func appendToManifest(p *dep.Project, extra []byte) error {
+	if len(extra) == 0 {
+		return nil
+	}
+
+	mf, err := os.OpenFile(p.ManifestPath(), os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s failed", dep.ManifestName)
+	}
+	if _, err := mf.Write(extra); err != nil {
+		mf.Close()
+		return errors.Wrapf(err, "writing to %s failed", dep.ManifestName)
+	}
+	return errors.Wrapf(mf.Close(), "closing %s", dep.ManifestName)
+}
+
+// solve runs a solve with the given params. When it fails, for instance because
+// it was interrupted, how far it got is checkpointed, and the next solve with
+// the same inputs resumes from there.
+func (cmd *ensureCommand) solve(ctx *dep.Ctx, sm gps.SourceManager, params gps.SolveParameters) (gps.Solution, error) {
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare solver")
+	}
+	digest := solver.HashInputs()
+
+	cp, err := ctx.LoadSolveCheckpoint(digest)
+	if err != nil {
+		ctx.Err.Printf("Warning: %s, solving from scratch", err)
+	} else if len(cp) > 0 {
+		if ctx.Verbose {
+			ctx.Err.Printf("Resuming from where the last solve stopped, with %d projects already selected", len(cp))
+		}
+		dep.ResumeFromCheckpoint(&params, cp)
+		if solver, err = gps.Prepare(params, sm); err != nil {
+			return nil, errors.Wrap(err, "prepare solver")
+		}
+	}
+
+	start := time.Now()
+	solution, err := solver.Solve()
+	cmd.rstats.solved(start, solution)
+	if err != nil {
+		if cerr := ctx.SaveSolveCheckpoint(digest, solver.Checkpoint()); cerr != nil {
+			ctx.Err.Printf("Warning: %s", cerr)
+		}
+		if cmd.failureJSON != "" {
+			if werr := writeSolveFailureJSON(cmd.failureJSON, err); werr != nil {
+				ctx.Err.Printf("Warning: %s", werr)
+			}
+		}
+		return nil, err
+	}
+
+	if err := ctx.ClearSolveCheckpoint(digest); err != nil {
+		ctx.Err.Printf("Warning: %s", err)
+	}
+	return solution, nil
+}
+
+// writeSolveFailureJSON writes the explanation of the given solve failure to
+// the file at path as JSON, or null if the failure can't be explained.
+func writeSolveFailureJSON(path string, err error) error {
+	var buf bytes.Buffer
+	if sf := gps.NewSolveFailure(err); sf != nil {
+		if err := sf.WriteJSON(&buf); err != nil {
+			return err
+		}
+	} else {
+		buf.WriteString("null\n")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, buf.Bytes(), 0666), "writing the solve failure to %s failed", path)
+}
+
+// warnNestedVendorConflicts warns about the projects vendored by dependencies
+// at other revisions than the locked ones. kept tells whether the nested
+// vendor directories were kept.
+func warnNestedVendorConflicts(ctx *dep.Ctx, conflicts []dep.NestedVendorConflict, policy string) {
+	for _, c := range conflicts {
+		vendored := "an unknown revision"
+		if c.Revision != "" {
+			vendored = string(c.Revision)
+		}
+		ctx.Err.Printf("Warning: %s vendors %s at %s, but it is locked at %s", c.Project, c.Vendored, vendored, c.Locked)
+		switch policy {
+		case dep.NestedVendorKeep:
+			ctx.Err.Printf("  %s/vendor/%s shadows vendor/%s for the packages of %s: %s",
+				c.Project, c.Vendored, c.Vendored, c.Project, strings.Join(c.Packages, ", "))
+		case dep.NestedVendorHint:
+			ctx.Err.Printf("  %s/vendor/%s was stripped, and its revision couldn't be kept, so %s is built against the locked version",
+				c.Project, c.Vendored, c.Project)
+		default:
+			ctx.Err.Printf("  %s/vendor/%s was stripped, so %s is built against the locked version; set nested-vendor = \"hint\" in %s to prefer its revision",
+				c.Project, c.Vendored, c.Project, dep.ManifestName)
+		}
 	}
+}
 
-	// try to split on '@'
-	// When there is no `@`, use any version
-	var versionStr string
-	atIndex := strings.Index(arg, "@")
-	if atIndex > 0 {
-		parts := strings.SplitN(arg, "@", 2)
-		arg = parts[0]
-		versionStr = parts[1]
+// warnDuplicateProjects warns about the projects of l which are most likely
+// the same repository under different import paths.
+func warnDuplicateProjects(ctx *dep.Ctx, l *dep.Lock) {
+	for _, d := range dep.DuplicateProjects(l) {
+		roots := make([]string, len(d.Roots))
+		for i, pr := range d.Roots {
+			roots[i] = string(pr)
+		}
+		ctx.Err.Printf("Warning: %s are likely the same project (%s).\n  Suggestion: %s\n", strings.Join(roots, ", "), d.Reason, d.Suggestion())
 	}
+}
 
-	// TODO: if we decide to keep equals.....
+// offlineError adds to err, if any, the sources which would have been
+// contacted were the network not forbidden.
+func offlineError(err error, sm *gps.SourceMgr) error {
+	urls := sm.OfflineDenied()
+	if err == nil || len(urls) == 0 {
+		return err
+	}
+	return errors.Errorf("%v\n\nThe network is forbidden, but these sources would have been contacted:\n  %s", err, strings.Join(urls, "\n  "))
+}
 
-	// split on colon if there is a network location
-	var source string
-	colonIndex := strings.Index(arg, ":")
-	if colonIndex > 0 {
-		parts := strings.SplitN(arg, ":", 2)
-		arg = parts[0]
-		source = parts[1]
+// parseAsOf parses the argument of the -as-of flag, which is either a date, to
+// be interpreted as midnight UTC, or a full RFC 3339 timestamp.
+func parseAsOf(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid -as-of value %q: expected YYYY-MM-DD or an RFC 3339 timestamp", s)
 	}
+	return t, nil
+}
+
+func getProjectConstraint(arg string, sm gps.SourceManager) (gps.ProjectConstraint, string, error) {
+	emptyPC := gps.ProjectConstraint{
+		Constraint: gps.Any(), // default to any; avoids panics later
+	}
+
+	arg, source, versionStr := parseProjectSpec(arg)
 
 	pr, err := sm.DeduceProjectRoot(arg)
 	if err != nil {
@@ -741,13 +1454,70 @@ func getProjectConstraint(arg string, sm gps.SourceManager) (gps.ProjectConstrai
 	}
 
 	pi := gps.ProjectIdentifier{ProjectRoot: pr, Source: source}
-	c, err := sm.InferConstraint(versionStr, pi)
+	var c gps.Constraint
+	if versionStr == "latest" {
+		c, err = latestConstraint(pi, sm)
+	} else {
+		c, err = sm.InferConstraint(versionStr, pi)
+	}
 	if err != nil {
 		return emptyPC, "", err
 	}
 	return gps.ProjectConstraint{Ident: pi, Constraint: c}, arg, nil
 }
 
+// parseProjectSpec splits a project spec into its import path, alternate
+// source and version. A source may itself hold '@' and ':', as in
+// git@example.com:foo.git, so the version is taken from after its last '@'
+// only when what precedes it is still a whole source.
+func parseProjectSpec(arg string) (path, source, version string) {
+	i := strings.IndexAny(arg, ":@")
+	if i <= 0 {
+		return arg, "", ""
+	}
+	if arg[i] == '@' {
+		return arg[:i], "", arg[i+1:]
+	}
+
+	path, source = arg[:i], arg[i+1:]
+	if j := strings.LastIndex(source, "@"); j >= 0 && !strings.Contains(source[j+1:], ":") && isWholeSource(source[:j]) {
+		source, version = source[:j], source[j+1:]
+	}
+	return path, source, version
+}
+
+// isWholeSource reports whether s can be a source URL on its own, rather than
+// the scheme and user of one, like ssh://git.
+func isWholeSource(s string) bool {
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	return strings.ContainsAny(s, "/:")
+}
+
+// latestConstraint returns the constraint for the latest version query: the
+// versions compatible with the newest semver release of the project, unless
+// it has a branch or tag actually named latest.
+func latestConstraint(pi gps.ProjectIdentifier, sm gps.SourceManager) (gps.Constraint, error) {
+	versions, err := sm.ListVersions(pi)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list versions for %s", pi)
+	}
+	gps.SortPairedForUpgrade(versions)
+
+	for _, v := range versions {
+		if v.String() == "latest" {
+			return sm.InferConstraint("latest", pi)
+		}
+	}
+	for _, v := range versions {
+		if v.Type() == gps.IsSemver {
+			return gps.NewSemverConstraintIC(v.String())
+		}
+	}
+	return nil, errors.Errorf("%s has no semver release to use as the latest version", pi)
+}
+
 func checkErrors(m map[string]pkgtree.PackageOrErr) error {
 	var (
 		buildErrors []string
@@ -8,15 +8,18 @@ import (
 	"errors"
 	"go/build"
 	"testing"
+	"time"
 
+	"github.com/golang/dep"
 	"github.com/golang/dep/internal/gps"
 	"github.com/golang/dep/internal/gps/pkgtree"
 )
 
 func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	ec := &ensureCommand{
-		update: true,
-		add:    true,
+		update:   true,
+		add:      true,
+		strategy: "prefer-newest",
 	}
 
 	if err := ec.validateFlags(); err == nil {
@@ -39,6 +42,65 @@ func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	}
 	ec.noVendor = false
 
+	ec.vendorOnly, ec.report = false, "markdown"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-report without -update should fail validation")
+	}
+
+	ec.update, ec.report = true, "html"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-report with an unknown format should fail validation")
+	}
+	ec.update, ec.report, ec.vendorOnly = false, "", true
+
+	ec.asOf = "2017-03-01"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-vendor-only with -as-of should fail validation")
+	}
+	ec.asOf, ec.strategy = "", "prefer-minimal"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-vendor-only with -strategy should fail validation")
+	}
+	ec.strategy, ec.vendorOnly = "prefer-oldest", false
+	if err := ec.validateFlags(); err == nil {
+		t.Error("an unknown -strategy should fail validation")
+	}
+	ec.strategy = "prefer-newest"
+
+	ec.patch = true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-patch without -update should fail validation")
+	}
+	ec.update, ec.minor = true, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-patch with -minor should fail validation")
+	}
+	ec.update, ec.patch, ec.minor = false, false, false
+
+	ec.syncImports, ec.update = true, true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-sync-imports with -update should fail validation")
+	}
+	ec.update = false
+
+	ec.vendorOnly = true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-vendor-only with -sync-imports should fail validation")
+	}
+	ec.syncImports, ec.vendorOnly = false, false
+
+	ec.planOut, ec.dryRun = "plan.json", true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-plan-out with -dry-run should fail validation")
+	}
+	ec.planOut, ec.dryRun = "", false
+
+	ec.applyPlan, ec.update = "plan.json", true
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-apply-plan with -update should fail validation")
+	}
+	ec.applyPlan, ec.update, ec.vendorOnly = "", false, true
+
 	// Also verify that the plain ensure path takes no args. This is a shady
 	// test, as lots of other things COULD return errors, and we don't check
 	// anything other than the error being non-nil. For now, it works well
@@ -53,6 +115,142 @@ func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	}
 }
 
+func TestImportConstraints(t *testing.T) {
+	semver := func(s string) gps.Constraint {
+		c, err := gps.NewSemverConstraintIC(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	rev := gps.Revision("d05d5aca9f895d19e9265839bffeadd74a2d2ecb")
+	m := &dep.Manifest{
+		Constraints: gps.ProjectConstraints{
+			"github.com/foo/constrained": {Constraint: gps.NewBranch("master")},
+		},
+	}
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/constrained"}, gps.NewVersion("v1.0.0").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/semver"}, gps.NewVersion("v1.2.0").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/branch", Source: "https://example.com/foo/branch"}, gps.NewBranch("dev").Pair(rev), nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/revision"}, rev, nil),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/transitive"}, gps.NewVersion("v2.0.0").Pair(rev), nil),
+		},
+	}
+	roots := map[gps.ProjectRoot]bool{
+		"github.com/foo/constrained": true,
+		"github.com/foo/semver":      true,
+		"github.com/foo/branch":      true,
+		"github.com/foo/revision":    true,
+		"github.com/foo/missing":     true,
+	}
+
+	want := gps.ProjectConstraints{
+		"github.com/foo/semver": {Constraint: semver("v1.2.0")},
+		"github.com/foo/branch": {Source: "https://example.com/foo/branch", Constraint: gps.NewBranch("dev")},
+	}
+	got := importConstraints(m, roots, l).Constraints
+	if len(got) != len(want) {
+		t.Fatalf("expected constraints on %d projects, got %v", len(want), got)
+	}
+	for pr, wpp := range want {
+		gpp, has := got[pr]
+		if !has {
+			t.Errorf("expected a constraint on %s", pr)
+			continue
+		}
+		if gpp.Source != wpp.Source || gpp.Constraint.String() != wpp.Constraint.String() {
+			t.Errorf("unexpected constraint on %s:\n\t(GOT): %v %s\n\t(WNT): %v %s", pr, gpp.Constraint, gpp.Source, wpp.Constraint, wpp.Source)
+		}
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	tests := map[string]time.Time{
+		"2017-03-01":                time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC),
+		"2017-03-01T15:04:05Z":      time.Date(2017, 3, 1, 15, 4, 5, 0, time.UTC),
+		"2017-03-01T15:04:05+02:00": time.Date(2017, 3, 1, 13, 4, 5, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := parseAsOf(in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", in, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: expected %s, got %s", in, want, got)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "03/01/2017"} {
+		if _, err := parseAsOf(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestParseProjectSpec(t *testing.T) {
+	tests := map[string][3]string{
+		"github.com/pkg/foo":                                     {"github.com/pkg/foo", "", ""},
+		"github.com/pkg/foo@^1.2":                                {"github.com/pkg/foo", "", "^1.2"},
+		"github.com/pkg/foo@latest":                              {"github.com/pkg/foo", "", "latest"},
+		"github.com/pkg/foo@feature/bar":                         {"github.com/pkg/foo", "", "feature/bar"},
+		"github.com/pkg/foo:git.internal.com/alt/foo":            {"github.com/pkg/foo", "git.internal.com/alt/foo", ""},
+		"github.com/pkg/foo:git.internal.com/alt/foo@v2":         {"github.com/pkg/foo", "git.internal.com/alt/foo", "v2"},
+		"github.com/pkg/foo:git@internal:repo.git":               {"github.com/pkg/foo", "git@internal:repo.git", ""},
+		"github.com/pkg/foo:git@internal:repo.git@v2":            {"github.com/pkg/foo", "git@internal:repo.git", "v2"},
+		"github.com/pkg/foo:ssh://git@internal/repo.git":         {"github.com/pkg/foo", "ssh://git@internal/repo.git", ""},
+		"github.com/pkg/foo:https://internal/repo.git@>=1.0, <2": {"github.com/pkg/foo", "https://internal/repo.git", ">=1.0, <2"},
+	}
+	for in, want := range tests {
+		path, source, version := parseProjectSpec(in)
+		if got := [3]string{path, source, version}; got != want {
+			t.Errorf("%s: expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+type latestTestSM struct {
+	gps.SourceManager
+	versions []gps.PairedVersion
+}
+
+func (sm latestTestSM) ListVersions(gps.ProjectIdentifier) ([]gps.PairedVersion, error) {
+	return sm.versions, nil
+}
+
+func (sm latestTestSM) InferConstraint(s string, pi gps.ProjectIdentifier) (gps.Constraint, error) {
+	return gps.NewBranch(s), nil
+}
+
+func TestLatestConstraint(t *testing.T) {
+	pi := gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/foo"}
+	sm := latestTestSM{versions: []gps.PairedVersion{
+		gps.NewVersion("v1.2.0").Pair("aaa"),
+		gps.NewVersion("v2.0.0-rc1").Pair("bbb"),
+		gps.NewVersion("v1.10.1").Pair("ccc"),
+		gps.NewBranch("master").Pair("ddd"),
+	}}
+	c, err := latestConstraint(pi, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "^1.10.1" {
+		t.Errorf("expected ^1.10.1, got %s", c)
+	}
+
+	sm.versions = append(sm.versions, gps.NewBranch("latest").Pair("eee"))
+	if c, err = latestConstraint(pi, sm); err != nil || c.String() != "latest" {
+		t.Errorf("expected the latest branch, got %v (%v)", c, err)
+	}
+
+	sm.versions = []gps.PairedVersion{gps.NewBranch("master").Pair("ddd")}
+	if _, err = latestConstraint(pi, sm); err == nil {
+		t.Error("expected an error without semver releases")
+	}
+}
+
 func TestCheckErrors(t *testing.T) {
 	tt := []struct {
 		name        string
@@ -0,0 +1,226 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const explainShortHelp = `Explain the choices of the solver`
+const explainLongHelp = `
+Explain why the solver doesn't choose a version of a dependency.
+
+  dep explain -why-not <project>@<version>
+
+The version may be a semver version, a tag, a branch or a revision of the
+project. It is checked, in turn, against:
+
+  - the constraint or override of Gopkg.toml on the project
+  - the constraints of the other locked dependencies on the project, unless
+    Gopkg.toml overrides it
+  - a solve with the project pinned to the version, which tells whether the
+    rest of the dependencies can be solved with it
+
+When the version passes them all, it only loses to the version the solver
+prefers: the locked one, which dep ensure keeps, or the newest allowed one,
+which dep ensure -update picks.
+`
+
+func (cmd *explainCommand) Name() string      { return "explain" }
+func (cmd *explainCommand) Args() string      { return "-why-not <project>@<version>" }
+func (cmd *explainCommand) ShortHelp() string { return explainShortHelp }
+func (cmd *explainCommand) LongHelp() string  { return explainLongHelp }
+func (cmd *explainCommand) Hidden() bool      { return false }
+
+func (cmd *explainCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.whyNot, "why-not", "", "explain why the given <project>@<version> isn't chosen")
+}
+
+type explainCommand struct {
+	whyNot string
+}
+
+// whyNotReport is what dep explain -why-not found out about a version of a
+// project.
+type whyNotReport struct {
+	Version gps.PairedVersion
+	// Constraint is the constraint of Gopkg.toml on the project, which is an
+	// override if Override is set.
+	Constraint gps.Constraint
+	Override   bool
+	// Downstream holds the constraints placed on the project by the other
+	// dependencies, and Rejecting those of them which don't allow Version.
+	Downstream map[gps.ProjectRoot]gps.Constraint
+	Rejecting  []gps.ProjectRoot
+	// SolveErr is the failure of the solve pinning the project to Version,
+	// which is only run if Solved is set.
+	Solved   bool
+	SolveErr error
+	// Locked is the version of Gopkg.lock, and Preferred the newest version
+	// allowed by all the constraints.
+	Locked    gps.Version
+	Preferred gps.Version
+}
+
+func (cmd *explainCommand) Run(ctx *dep.Ctx, args []string) error {
+	if cmd.whyNot == "" {
+		return errors.New("usage: dep explain -why-not <project>@<version>")
+	}
+	if len(args) > 0 {
+		return errors.New("dep explain takes no arguments")
+	}
+	at := strings.LastIndex(cmd.whyNot, "@")
+	if at <= 0 || at == len(cmd.whyNot)-1 {
+		return errors.Errorf("%s is not of the form <project>@<version>", cmd.whyNot)
+	}
+	arg, version := cmd.whyNot[:at], cmd.whyNot[at+1:]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pr, err := sm.DeduceProjectRoot(arg)
+	if err != nil {
+		return errors.Wrapf(err, "could not infer project root from dependency path: %s", arg)
+	}
+	id := gps.ProjectIdentifier{ProjectRoot: pr, Source: projectSource(p, pr)}
+
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the versions of %s", pr)
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	r := whyNotReport{Constraint: gps.Any()}
+	if r.Version = findVersion(vl, version); r.Version == nil {
+		return errors.Errorf("%s has no version, branch or revision %s", pr, version)
+	}
+	if pp, has := p.Manifest.Ovr[pr]; has && pp.Constraint != nil {
+		r.Constraint, r.Override = pp.Constraint, true
+	} else if pp, has := p.Manifest.Constraints[pr]; has && pp.Constraint != nil {
+		r.Constraint = pp.Constraint
+	}
+	if p.Lock != nil {
+		for _, lp := range p.Lock.Projects() {
+			if lp.Ident().ProjectRoot == pr {
+				r.Locked = lp.Version()
+			}
+		}
+	}
+
+	// Overrides supersede the constraints of the dependencies.
+	if !r.Override {
+		r.Downstream = collectDownstreamConstraints(ctx, p, sm, pr)
+		r.Rejecting = rejectingConstraints(r.Version, r.Downstream)
+	}
+	for _, v := range vl {
+		if r.Constraint.Matches(v) && len(rejectingConstraints(v, r.Downstream)) == 0 {
+			r.Preferred = v
+			break
+		}
+	}
+
+	// Only solve when the constraints allow the version, as the solve is what
+	// takes a while.
+	if r.Constraint.Matches(r.Version) && len(r.Rejecting) == 0 {
+		ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+		if err != nil {
+			return errors.Wrap(err, "analysis of local packages failed")
+		}
+		solver, err := gps.Prepare(pinnedParams(p, ptree, id, r.Version), sm)
+		if err != nil {
+			return errors.Wrap(err, "prepare solver")
+		}
+		if ctx.Verbose {
+			ctx.Err.Printf("Solving with %s@%s", pr, r.Version)
+		}
+		r.Solved = true
+		_, r.SolveErr = solver.Solve()
+	}
+
+	var buf bytes.Buffer
+	r.write(&buf, pr)
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// findVersion returns the version of vl of the given name, or whose revision is
+// the given one, or nil if there's none. Revisions may be abbreviated.
+func findVersion(vl []gps.PairedVersion, name string) gps.PairedVersion {
+	for _, v := range vl {
+		if v.String() == name {
+			return v
+		}
+	}
+	if len(name) < 7 {
+		return nil
+	}
+	for _, v := range vl {
+		if strings.HasPrefix(string(v.Revision()), name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (r whyNotReport) write(w io.Writer, pr gps.ProjectRoot) {
+	v := fmt.Sprintf("%s@%s", pr, r.Version)
+
+	if !r.Constraint.Matches(r.Version) {
+		kind := "constraint"
+		if r.Override {
+			kind = "override"
+		}
+		fmt.Fprintf(w, "%s is not allowed by the %s of %s: %s\n", v, kind, dep.ManifestName, r.Constraint)
+	}
+	if len(r.Rejecting) > 0 {
+		fmt.Fprintf(w, "%s is not allowed by the constraints of other dependencies:\n", v)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, dpr := range r.Rejecting {
+			fmt.Fprintf(tw, "  %s\t%s\t\n", dpr, r.Downstream[dpr])
+		}
+		tw.Flush()
+	}
+	if !r.Solved {
+		return
+	}
+
+	if r.SolveErr != nil {
+		fmt.Fprintf(w, "%s is allowed by all the constraints, but the other dependencies can't be solved with it:\n\n%s\n", v, r.SolveErr)
+		return
+	}
+
+	switch {
+	case r.Locked == nil:
+		fmt.Fprintf(w, "%s would solve, but %s is not in %s; is it imported by the project?\n", v, pr, dep.LockName)
+	case isLockedVersion(r.Locked, r.Version):
+		fmt.Fprintf(w, "%s is already the version of %s.\n", v, dep.LockName)
+	default:
+		fmt.Fprintf(w, "%s would solve, but loses to %s, the version of %s, which dep ensure keeps.\n", v, r.Locked, dep.LockName)
+		if r.Preferred != nil && r.Preferred.String() != r.Version.String() {
+			fmt.Fprintf(w, "dep ensure -update would pick %s, the newest allowed version.\n", r.Preferred)
+		} else if r.Preferred != nil {
+			fmt.Fprintf(w, "It's the newest allowed version, which dep ensure -update %s picks.\n", pr)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestFindVersion(t *testing.T) {
+	vl := []gps.PairedVersion{
+		gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+		gps.NewBranch("master").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+	}
+
+	cases := map[string]string{
+		"v1.0.0":  "v1.0.0",
+		"master":  "master",
+		"645ef00": "master",
+		"645ef00459ed84a119197bfb8d8205042c6df63d": "master",
+		"645e":   "",
+		"v2.0.0": "",
+	}
+	for name, want := range cases {
+		v := findVersion(vl, name)
+		var got string
+		if v != nil {
+			got = v.String()
+		}
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestWhyNotReportWrite(t *testing.T) {
+	mustConstraint := func(s string) gps.Constraint {
+		c, err := gps.NewSemverConstraint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	v090 := gps.NewVersion("v0.9.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d")
+	v080 := gps.NewVersion("v0.8.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf")
+
+	cases := map[string]struct {
+		report whyNotReport
+		want   string
+	}{
+		"excluded": {
+			report: whyNotReport{
+				Version:    v090,
+				Constraint: mustConstraint("^0.8.0"),
+				Downstream: map[gps.ProjectRoot]gps.Constraint{
+					"github.com/foo/bar": mustConstraint("<0.9.0"),
+					"github.com/foo/baz": mustConstraint(">=0.8.0"),
+				},
+				Rejecting: []gps.ProjectRoot{"github.com/foo/bar"},
+			},
+			want: `github.com/pkg/errors@v0.9.0 is not allowed by the constraint of Gopkg.toml: ^0.8.0
+github.com/pkg/errors@v0.9.0 is not allowed by the constraints of other dependencies:
+  github.com/foo/bar  <0.9.0  
+`,
+		},
+		"unsolvable": {
+			report: whyNotReport{
+				Version:    v090,
+				Constraint: gps.Any(),
+				Solved:     true,
+				SolveErr:   errors.New("no versions of github.com/foo/bar met constraints"),
+			},
+			want: `github.com/pkg/errors@v0.9.0 is allowed by all the constraints, but the other dependencies can't be solved with it:
+
+no versions of github.com/foo/bar met constraints
+`,
+		},
+		"locked": {
+			report: whyNotReport{
+				Version:    v090,
+				Constraint: gps.Any(),
+				Solved:     true,
+				Locked:     v080,
+				Preferred:  v090,
+			},
+			want: `github.com/pkg/errors@v0.9.0 would solve, but loses to v0.8.0, the version of Gopkg.lock, which dep ensure keeps.
+It's the newest allowed version, which dep ensure -update github.com/pkg/errors picks.
+`,
+		},
+		"unlocked": {
+			report: whyNotReport{
+				Version:    v090,
+				Constraint: gps.Any(),
+				Solved:     true,
+			},
+			want: "github.com/pkg/errors@v0.9.0 would solve, but github.com/pkg/errors is not in Gopkg.lock; is it imported by the project?\n",
+		},
+	}
+
+	for name, c := range cases {
+		var buf bytes.Buffer
+		c.report.write(&buf, "github.com/pkg/errors")
+		if got := buf.String(); got != c.want {
+			t.Errorf("%s: unexpected report:\n\t(GOT):\n%s\n\t(WNT):\n%s", name, got, c.want)
+		}
+	}
+}
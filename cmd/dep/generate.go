@@ -0,0 +1,159 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const generateShortHelp = `Render a template with the data of Gopkg.lock`
+const generateLongHelp = `
+Render a text/template with the projects of Gopkg.lock, for instance to embed
+the versions of the dependencies in the application.
+
+  dep generate -template versions.go.tmpl [-o versions.go]
+
+The output is printed, or written to the file given with -o, which is formatted
+with gofmt when its name ends in .go. The template is executed with:
+
+  .Root          the import path of the project
+  .InputsDigest  the inputs digest of Gopkg.lock
+  .Projects      the locked projects, sorted by name, each with:
+    .Name        the root import path of the project
+    .Source      the source of the project, if any
+    .Version     the locked version, if any
+    .Branch      the locked branch, if any
+    .Revision    the locked revision
+    .Packages    the packages used from the project
+
+For instance:
+
+  package main
+
+  var dependencies = map[string]string{
+  {{- range .Projects}}
+  	{{printf "%q" .Name}}: {{printf "%q" .Revision}},
+  {{- end}}
+  }
+`
+
+func (cmd *generateCommand) Name() string      { return "generate" }
+func (cmd *generateCommand) Args() string      { return "-template <file> [-o <file>]" }
+func (cmd *generateCommand) ShortHelp() string { return generateShortHelp }
+func (cmd *generateCommand) LongHelp() string  { return generateLongHelp }
+func (cmd *generateCommand) Hidden() bool      { return false }
+
+func (cmd *generateCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.template, "template", "", "the template to render")
+	fs.StringVar(&cmd.output, "o", "", "write the output to the given file")
+}
+
+type generateCommand struct {
+	template string
+	output   string
+}
+
+// generateData is the data generate templates are executed with.
+type generateData struct {
+	Root         string
+	InputsDigest string
+	Projects     []generateProject
+}
+
+type generateProject struct {
+	Name     string
+	Source   string
+	Version  string
+	Branch   string
+	Revision string
+	Packages []string
+}
+
+func (cmd *generateCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep generate takes no arguments")
+	}
+	if cmd.template == "" {
+		return errors.New("a template must be given with -template")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to generate from", dep.LockName)
+	}
+
+	text, err := ioutil.ReadFile(cmd.template)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the template")
+	}
+	tmpl, err := template.New(filepath.Base(cmd.template)).Parse(string(text))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the template")
+	}
+
+	var buf bytes.Buffer
+	if err := executeGenerate(&buf, tmpl, string(p.ImportRoot), p.Lock); err != nil {
+		return err
+	}
+
+	if cmd.output == "" {
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+
+	out := buf.Bytes()
+	if strings.HasSuffix(cmd.output, ".go") {
+		if out, err = format.Source(out); err != nil {
+			return errors.Wrap(err, "the template generated invalid Go code")
+		}
+	}
+	return errors.Wrapf(ioutil.WriteFile(cmd.output, out, 0666), "failed to write %s", cmd.output)
+}
+
+// executeGenerate executes tmpl with the data of l to w.
+func executeGenerate(w io.Writer, tmpl *template.Template, root string, l *dep.Lock) error {
+	data := generateData{
+		Root:         root,
+		InputsDigest: hex.EncodeToString(l.SolveMeta.InputsDigest),
+		Projects:     generateProjects(l),
+	}
+	return errors.Wrap(tmpl.Execute(w, data), "failed to execute the template")
+}
+
+// generateProjects returns the projects of l, sorted by name.
+func generateProjects(l *dep.Lock) []generateProject {
+	// Sort a copy, as Projects returns the slice of the lock itself.
+	projects := append([]gps.LockedProject(nil), l.Projects()...)
+	sort.Sort(dep.SortedLockedProjects(projects))
+
+	gprojects := make([]generateProject, 0, len(projects))
+	for _, lp := range projects {
+		id := lp.Ident()
+		gp := generateProject{
+			Name:     string(id.ProjectRoot),
+			Source:   id.Source,
+			Packages: lp.Packages(),
+		}
+		gp.Revision, gp.Branch, gp.Version = gps.VersionComponentStrings(lp.Version())
+		gprojects = append(gprojects, gp)
+	}
+	return gprojects
+}
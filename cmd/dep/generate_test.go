@@ -0,0 +1,52 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestExecuteGenerate(t *testing.T) {
+	l := &dep.Lock{
+		SolveMeta: dep.SolveMeta{InputsDigest: []byte{0xab, 0xcd}},
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors"},
+				gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: "github.com/Masterminds/semver", Source: "https://github.com/carolynvs/semver.git"},
+				gps.NewBranch("2.x").Pair("94ad6eaf8457cf85a68c9b53fa42e9b1b8683783"),
+				[]string{"."},
+			),
+		},
+	}
+
+	tmpl := template.Must(template.New("").Parse(`{{.Root}} {{.InputsDigest}}
+{{range .Projects}}{{.Name}} {{.Source}} {{.Version}} {{.Branch}} {{.Revision}} {{.Packages}}
+{{end}}`))
+
+	var buf bytes.Buffer
+	if err := executeGenerate(&buf, tmpl, "github.com/me/app", l); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `github.com/me/app abcd
+github.com/Masterminds/semver https://github.com/carolynvs/semver.git  2.x 94ad6eaf8457cf85a68c9b53fa42e9b1b8683783 [.]
+github.com/pkg/errors  v0.8.0  645ef00459ed84a119197bfb8d8205042c6df63d [.]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+	if l.P[0].Ident().ProjectRoot != "github.com/pkg/errors" {
+		t.Error("expected the projects of the lock to be left unsorted")
+	}
+}
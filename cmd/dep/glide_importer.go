@@ -31,6 +31,7 @@ type glideImporter struct {
 	logger  *log.Logger
 	verbose bool
 	sm      gps.SourceManager
+	report  *migrationReport
 }
 
 func newGlideImporter(logger *log.Logger, verbose bool, sm gps.SourceManager) *glideImporter {
@@ -75,6 +76,8 @@ func (g *glideImporter) Name() string {
 	return "glide"
 }
 
+func (g *glideImporter) setReport(r *migrationReport) { g.report = r }
+
 func (g *glideImporter) HasDepMetadata(dir string) bool {
 	// Only require glide.yaml, the lock is optional
 	y := filepath.Join(dir, glideYamlName)
@@ -156,6 +159,11 @@ func (g *glideImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, e
 		}
 
 		manifest.Constraints[pc.Ident.ProjectRoot] = gps.ProjectProperties{Source: pc.Ident.Source, Constraint: pc.Constraint}
+		if pkg.OS != "" || pkg.Arch != "" {
+			g.report.approximated(pc, pkg.Reference, "its os and arch are ignored")
+		} else {
+			g.report.translated(pc, pkg.Reference)
+		}
 	}
 
 	manifest.Ignored = append(manifest.Ignored, g.yaml.Ignores...)
@@ -240,6 +248,7 @@ func (g *glideImporter) buildLockedProject(pkg glideLockedPackage, manifest *dep
 		// Only warn about the problem, it is not enough to warrant failing
 		g.logger.Println(err.Error())
 	}
+	g.report.locked(pr, pkg.Reference, version, err)
 
 	lp = gps.NewLockedProject(pi, version, nil)
 
@@ -25,6 +25,7 @@ type godepImporter struct {
 	logger  *log.Logger
 	verbose bool
 	sm      gps.SourceManager
+	report  *migrationReport
 }
 
 func newGodepImporter(logger *log.Logger, verbose bool, sm gps.SourceManager) *godepImporter {
@@ -49,6 +50,8 @@ func (g *godepImporter) Name() string {
 	return "godep"
 }
 
+func (g *godepImporter) setReport(r *migrationReport) { g.report = r }
+
 func (g *godepImporter) HasDepMetadata(dir string) bool {
 	y := filepath.Join(dir, godepPath)
 	if _, err := os.Stat(y); err != nil {
@@ -118,6 +121,7 @@ func (g *godepImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, e
 			return nil, nil, err
 		}
 
+		comment := pkg.Comment
 		if pkg.Comment == "" {
 			// When there's no comment, try to get corresponding version for the Rev
 			// and fill Comment.
@@ -145,6 +149,11 @@ func (g *godepImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, e
 				return nil, nil, err
 			}
 			manifest.Constraints[pc.Ident.ProjectRoot] = gps.ProjectProperties{Constraint: pc.Constraint}
+			if comment != "" {
+				g.report.translated(pc, comment)
+			} else {
+				g.report.approximated(pc, "", "inferred from the version at revision "+pkg.Rev)
+			}
 		}
 
 		lp := g.buildLockedProject(pkg, manifest)
@@ -180,6 +189,7 @@ func (g *godepImporter) buildLockedProject(pkg godepPackage, manifest *dep.Manif
 		// Only warn about the problem, it is not enough to warrant failing
 		g.logger.Println(err.Error())
 	}
+	g.report.locked(pi.ProjectRoot, pkg.Rev, version, err)
 
 	lp := gps.NewLockedProject(pi, version, nil)
 	f := fb.NewLockedProjectFeedback(lp, fb.DepTypeImported)
@@ -0,0 +1,422 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep"
+	fb "github.com/golang/dep/internal/feedback"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const (
+	gomodName = "go.mod"
+	gosumName = "go.sum"
+)
+
+type gomodImporter struct {
+	mod gomodFile
+	sum []gomodModule
+
+	logger  *log.Logger
+	verbose bool
+	sm      gps.SourceManager
+	report  *migrationReport
+}
+
+// gomodFile holds the directives of a go.mod file which matter to dep.
+type gomodFile struct {
+	module  string
+	goVer   string
+	require []gomodRequire
+	replace []gomodReplace
+}
+
+// gomodModule is a version of a module. The version of the new module of a
+// replacement by a local directory is empty.
+type gomodModule struct {
+	path    string
+	version string
+}
+
+type gomodRequire struct {
+	gomodModule
+	indirect bool
+}
+
+// gomodReplace replaces a module, at any version if the version of old is
+// empty.
+type gomodReplace struct {
+	old, new gomodModule
+}
+
+func newGomodImporter(logger *log.Logger, verbose bool, sm gps.SourceManager) *gomodImporter {
+	return &gomodImporter{
+		logger:  logger,
+		verbose: verbose,
+		sm:      sm,
+	}
+}
+
+func (g *gomodImporter) Name() string { return "go.mod" }
+
+func (g *gomodImporter) setReport(r *migrationReport) { g.report = r }
+
+func (g *gomodImporter) HasDepMetadata(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, gomodName))
+	return err == nil
+}
+
+func (g *gomodImporter) Import(dir string, pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	g.logger.Println("Detected go.mod file...")
+
+	if err := g.load(dir); err != nil {
+		return nil, nil, err
+	}
+
+	return g.convert(pr)
+}
+
+func (g *gomodImporter) load(dir string) error {
+	g.logger.Println("Converting from go.mod...")
+
+	f, err := os.Open(filepath.Join(dir, gomodName))
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", gomodName)
+	}
+	defer f.Close()
+	if g.mod, err = parseGoMod(f); err != nil {
+		return errors.Wrapf(err, "unable to parse %s", gomodName)
+	}
+
+	// go.sum is optional: it only tells the versions of the indirect
+	// dependencies which go.mod doesn't list.
+	f, err = os.Open(filepath.Join(dir, gosumName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", gosumName)
+	}
+	defer f.Close()
+	g.sum, err = parseGoSum(f)
+	return errors.Wrapf(err, "unable to parse %s", gosumName)
+}
+
+func (g *gomodImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	manifest := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	lock := &dep.Lock{}
+
+	// The go directive is the release of Go the module needs.
+	if g.mod.goVer != "" {
+		if _, err := gps.ParseGoVersion(g.mod.goVer); err != nil {
+			g.logger.Printf("  Ignoring the go directive of %s: %s\n", gomodName, err)
+		} else {
+			manifest.GoVersion = g.mod.goVer
+		}
+	}
+
+	required := make(map[string]bool, len(g.mod.require))
+	for _, req := range g.mod.require {
+		required[req.path] = true
+		if err := g.convertModule(req.gomodModule, manifest, lock); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Seed the lock with the indirect dependencies only listed in go.sum, at
+	// their newest version, as the go command would select.
+	for _, mod := range newestModules(g.sum) {
+		if required[mod.path] {
+			continue
+		}
+		if err := g.convertModule(mod, nil, lock); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return manifest, lock, nil
+}
+
+// convertModule adds the constraint on the given version of a module to
+// manifest, if it's non-nil, and its locked version to lock.
+func (g *gomodImporter) convertModule(mod gomodModule, manifest *dep.Manifest, lock *dep.Lock) error {
+	if mod.path == "" {
+		return errors.New("invalid go.mod configuration, the module path is required")
+	}
+
+	var source string
+	if r, has := g.replacement(mod); has {
+		if r.version == "" {
+			g.logger.Printf("  Skipping %s, which is replaced by the local directory %s", mod.path, r.path)
+			if manifest != nil {
+				g.report.dropped(gps.ProjectRoot(mod.path), mod.version, "replaced by the local directory "+r.path)
+			}
+			return nil
+		}
+		if r.path != mod.path {
+			sr, err := g.sm.DeduceProjectRoot(r.path)
+			if err != nil {
+				return err
+			}
+			source = string(sr)
+		}
+		mod.version = r.version
+	}
+
+	root, err := g.sm.DeduceProjectRoot(mod.path)
+	if err != nil {
+		return err
+	}
+	// The modules of a project, as for its major versions, share its root.
+	if projectExistsInLock(lock, root) {
+		return nil
+	}
+	pi := gps.ProjectIdentifier{ProjectRoot: root, Source: source}
+
+	var c gps.Constraint
+	var locked gps.Version
+	rev := pseudoVersionRevision(mod.version)
+	if rev != "" {
+		// Pseudo-versions only tell a prefix of the revision, so look for it
+		// among those of the versions of the project.
+		locked, err = g.lookupRevision(pi, rev)
+		if manifest != nil {
+			g.report.dropped(root, mod.version, "pseudo-versions are only locked")
+		}
+	} else {
+		rev = mod.version
+		name := strings.TrimSuffix(mod.version, "+incompatible")
+		if c, err = gps.NewSemverConstraintIC(name); err != nil {
+			return errors.Wrapf(err, "unable to interpret version %s of %s", mod.version, mod.path)
+		}
+		locked, err = g.lookupVersion(pi, name)
+	}
+	if err != nil {
+		g.logger.Println(err.Error())
+	}
+	g.report.locked(root, rev, locked, err)
+
+	if manifest != nil && c != nil {
+		pc := gps.ProjectConstraint{Ident: pi, Constraint: c}
+		manifest.Constraints[root] = gps.ProjectProperties{Source: source, Constraint: c}
+		fb.NewConstraintFeedback(pc, fb.DepTypeImported).LogFeedback(g.logger)
+		if strings.HasSuffix(mod.version, "+incompatible") {
+			g.report.approximated(pc, mod.version, "the +incompatible suffix is ignored")
+		} else {
+			g.report.translated(pc, mod.version)
+		}
+	}
+	if locked != nil {
+		lp := gps.NewLockedProject(pi, locked, nil)
+		lock.P = append(lock.P, lp)
+		fb.NewLockedProjectFeedback(lp, fb.DepTypeImported).LogFeedback(g.logger)
+	}
+	return nil
+}
+
+// replacement returns the replacement of the given version of a module, if
+// any.
+func (g *gomodImporter) replacement(mod gomodModule) (gomodModule, bool) {
+	for _, r := range g.mod.replace {
+		if r.old.path == mod.path && (r.old.version == "" || r.old.version == mod.version) {
+			return r.new, true
+		}
+	}
+	return gomodModule{}, false
+}
+
+// lookupVersion returns the version of the project of the given name, paired
+// with its revision.
+func (g *gomodImporter) lookupVersion(pi gps.ProjectIdentifier, name string) (gps.Version, error) {
+	versions, err := g.sm.ListVersions(pi)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to lookup the revision of %s in %s. Leaving it out of the lock.", name, pi)
+	}
+	for _, v := range versions {
+		if v.String() == name {
+			return v, nil
+		}
+	}
+	return nil, errors.Errorf("%s has no version %s. Leaving it out of the lock.", pi, name)
+}
+
+// lookupRevision returns the full revision of the project starting with rev.
+func (g *gomodImporter) lookupRevision(pi gps.ProjectIdentifier, rev string) (gps.Version, error) {
+	versions, err := g.sm.ListVersions(pi)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to lookup revision %s in %s. Leaving it out of the lock.", rev, pi)
+	}
+	for _, v := range versions {
+		if strings.HasPrefix(string(v.Revision()), rev) {
+			return lookupVersionForLockedProject(pi, nil, v.Revision(), g.sm)
+		}
+	}
+	return nil, errors.Errorf("No branch or tag of %s is at revision %s. Leaving it out of the lock.", pi, rev)
+}
+
+// pseudoVersionRe matches the pseudo-versions the go command gives to
+// revisions, which end with a timestamp and a 12 character revision prefix.
+var pseudoVersionRe = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})(\+incompatible)?$`)
+
+// pseudoVersionRevision returns the revision prefix of a pseudo-version, or ""
+// for other versions.
+func pseudoVersionRevision(v string) string {
+	m := pseudoVersionRe.FindStringSubmatch(v)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// parseGoMod reads the module, require and replace directives of a go.mod
+// file. Other directives are ignored.
+func parseGoMod(r io.Reader) (gomodFile, error) {
+	var mf gomodFile
+	var block string
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		var comment string
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = line[:i], strings.TrimSpace(line[i+2:])
+		}
+		fields, err := gomodFields(line)
+		if err != nil {
+			return mf, errors.Wrapf(err, "line %d", n)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		verb := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "":
+			verb, fields = fields[0], fields[1:]
+			if len(fields) == 1 && fields[0] == "(" {
+				block = verb
+				continue
+			}
+		}
+
+		switch verb {
+		case "module":
+			if len(fields) != 1 {
+				return mf, errors.Errorf("line %d: usage: module <path>", n)
+			}
+			mf.module = fields[0]
+		case "go":
+			if len(fields) != 1 {
+				return mf, errors.Errorf("line %d: usage: go <version>", n)
+			}
+			mf.goVer = fields[0]
+		case "require":
+			if len(fields) != 2 {
+				return mf, errors.Errorf("line %d: usage: require <module> <version>", n)
+			}
+			mf.require = append(mf.require, gomodRequire{
+				gomodModule: gomodModule{path: fields[0], version: fields[1]},
+				indirect:    comment == "indirect",
+			})
+		case "replace":
+			arrow := -1
+			for i, f := range fields {
+				if f == "=>" {
+					arrow = i
+				}
+			}
+			if arrow < 1 || arrow > 2 || len(fields)-arrow-1 < 1 || len(fields)-arrow-1 > 2 {
+				return mf, errors.Errorf("line %d: usage: replace <module> [<version>] => <module> <version> | <directory>", n)
+			}
+			var rep gomodReplace
+			rep.old.path = fields[0]
+			if arrow == 2 {
+				rep.old.version = fields[1]
+			}
+			rep.new.path = fields[arrow+1]
+			if len(fields) == arrow+3 {
+				rep.new.version = fields[arrow+2]
+			}
+			mf.replace = append(mf.replace, rep)
+		}
+	}
+	return mf, sc.Err()
+}
+
+// gomodFields splits a line of go.mod into its fields, unquoting the quoted
+// ones.
+func gomodFields(line string) ([]string, error) {
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if strings.HasPrefix(f, `"`) {
+			uf, err := strconv.Unquote(f)
+			if err != nil {
+				return nil, errors.Errorf("invalid quoted string %s", f)
+			}
+			fields[i] = uf
+		}
+	}
+	return fields, nil
+}
+
+// parseGoSum reads the versions of the modules listed in a go.sum file. The
+// lines only holding the checksum of the go.mod file of a version are skipped,
+// as the go command doesn't need the code of such versions.
+func parseGoSum(r io.Reader) ([]gomodModule, error) {
+	var mods []gomodModule
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, errors.Errorf("line %d: expected a module, a version and a checksum", n)
+		}
+		if strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		mods = append(mods, gomodModule{path: fields[0], version: fields[1]})
+	}
+	return mods, sc.Err()
+}
+
+// newestModules returns the newest version of each module of mods, in the
+// order they first appear in.
+func newestModules(mods []gomodModule) []gomodModule {
+	var newest []gomodModule
+	index := make(map[string]int)
+	for _, mod := range mods {
+		i, has := index[mod.path]
+		if !has {
+			index[mod.path] = len(newest)
+			newest = append(newest, mod)
+			continue
+		}
+		v, err := semver.NewVersion(mod.version)
+		if err != nil {
+			continue
+		}
+		if cur, err := semver.NewVersion(newest[i].version); err != nil || v.GreaterThan(cur) {
+			newest[i] = mod
+		}
+	}
+	return newest
+}
@@ -0,0 +1,206 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const testGoMod = `module github.com/golang/notexist
+
+go 1.12
+
+require (
+	github.com/sdboyer/deptest v1.0.0
+	github.com/sdboyer/deptestdos/v2 v2.0.0+incompatible // indirect
+	"golang.org/x/text" v0.0.0-20170915032832-14c0d48ead0c
+)
+
+require github.com/pkg/errors v0.8.0 // errors
+
+exclude github.com/pkg/errors v0.7.0
+
+replace github.com/sdboyer/deptest => github.com/carolynvs/deptest v1.0.1
+replace (
+	golang.org/x/text v0.0.0-20170915032832-14c0d48ead0c => ../text
+)
+`
+
+func TestParseGoMod(t *testing.T) {
+	mf, err := parseGoMod(strings.NewReader(testGoMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := gomodFile{
+		module: "github.com/golang/notexist",
+		goVer:  "1.12",
+		require: []gomodRequire{
+			{gomodModule: gomodModule{"github.com/sdboyer/deptest", "v1.0.0"}},
+			{gomodModule: gomodModule{"github.com/sdboyer/deptestdos/v2", "v2.0.0+incompatible"}, indirect: true},
+			{gomodModule: gomodModule{"golang.org/x/text", "v0.0.0-20170915032832-14c0d48ead0c"}},
+			{gomodModule: gomodModule{"github.com/pkg/errors", "v0.8.0"}},
+		},
+		replace: []gomodReplace{
+			{old: gomodModule{"github.com/sdboyer/deptest", ""}, new: gomodModule{"github.com/carolynvs/deptest", "v1.0.1"}},
+			{old: gomodModule{"golang.org/x/text", "v0.0.0-20170915032832-14c0d48ead0c"}, new: gomodModule{"../text", ""}},
+		},
+	}
+	if !reflect.DeepEqual(mf, want) {
+		t.Errorf("unexpected go.mod:\n\t(GOT): %+v\n\t(WNT): %+v", mf, want)
+	}
+
+	for _, bad := range []string{
+		"require github.com/pkg/errors\n",
+		"replace github.com/pkg/errors v0.8.0\n",
+		"go 1.12 1.13\n",
+		`module "github.com/golang/notexist` + "\n",
+	} {
+		if _, err := parseGoMod(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestParseGoSum(t *testing.T) {
+	sum := `github.com/pkg/errors v0.7.0 h1:aaaa=
+github.com/pkg/errors v0.7.0/go.mod h1:bbbb=
+github.com/pkg/errors v0.8.0 h1:cccc=
+github.com/pkg/errors v0.8.0/go.mod h1:dddd=
+github.com/sdboyer/deptest v1.0.0/go.mod h1:eeee=
+golang.org/x/text v0.0.0-20170915032832-14c0d48ead0c h1:ffff=
+`
+	mods, err := parseGoSum(strings.NewReader(sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []gomodModule{
+		{"github.com/pkg/errors", "v0.8.0"},
+		{"golang.org/x/text", "v0.0.0-20170915032832-14c0d48ead0c"},
+	}
+	if got := newestModules(mods); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected newest modules:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	if _, err := parseGoSum(strings.NewReader("github.com/pkg/errors v0.8.0\n")); err == nil {
+		t.Error("expected a line without checksum to be refused")
+	}
+}
+
+func TestPseudoVersionRevision(t *testing.T) {
+	cases := map[string]string{
+		"v0.0.0-20170915032832-14c0d48ead0c":              "14c0d48ead0c",
+		"v1.2.4-0.20170915032832-14c0d48ead0c":            "14c0d48ead0c",
+		"v1.2.3-pre.0.20170915032832-14c0d48ead0c":        "14c0d48ead0c",
+		"v2.0.0-20170915032832-14c0d48ead0c+incompatible": "14c0d48ead0c",
+		"v1.2.3":              "",
+		"v1.2.3-rc1":          "",
+		"v2.0.0+incompatible": "",
+	}
+	for v, want := range cases {
+		if got := pseudoVersionRevision(v); got != want {
+			t.Errorf("%s: expected revision %q, got %q", v, want, got)
+		}
+	}
+}
+
+// gomodTestSM serves fixed version lists, and deduces the roots of github.com
+// and golang.org/x paths.
+type gomodTestSM struct {
+	gps.SourceManager
+	versions map[gps.ProjectRoot][]gps.PairedVersion
+}
+
+func (sm gomodTestSM) DeduceProjectRoot(ip string) (gps.ProjectRoot, error) {
+	parts := strings.Split(ip, "/")
+	if len(parts) < 3 {
+		return "", errors.Errorf("unable to deduce the root of %s", ip)
+	}
+	return gps.ProjectRoot(strings.Join(parts[:3], "/")), nil
+}
+
+func (sm gomodTestSM) ListVersions(id gps.ProjectIdentifier) ([]gps.PairedVersion, error) {
+	return sm.versions[id.ProjectRoot], nil
+}
+
+func TestGomodImporter_Convert(t *testing.T) {
+	sm := gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/sdboyer/deptest": {
+			gps.NewVersion("v1.0.1").Pair("4efe3f0e74ea3fc4d36e1d3a8d1ac9b1a7d3e0b8"),
+			gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+		},
+		"github.com/sdboyer/deptestdos": {
+			gps.NewVersion("v2.0.0").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+		},
+		"golang.org/x/text": {
+			gps.NewBranch("master").Pair("14c0d48ead0cd47e3a28f8d4a3d02e8a1ee1e4b3"),
+		},
+		"github.com/pkg/errors": {
+			gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+		},
+	}}
+
+	g := newGomodImporter(discardLogger, true, sm)
+	g.mod = gomodFile{
+		goVer: "1.12",
+		require: []gomodRequire{
+			{gomodModule: gomodModule{"github.com/sdboyer/deptest", "v1.0.0"}},
+			{gomodModule: gomodModule{"github.com/sdboyer/deptestdos/v2", "v2.0.0+incompatible"}},
+			{gomodModule: gomodModule{"golang.org/x/text", "v0.0.0-20170915032832-14c0d48ead0c"}},
+		},
+		replace: []gomodReplace{
+			{old: gomodModule{"github.com/sdboyer/deptest", ""}, new: gomodModule{"github.com/carolynvs/deptest", "v1.0.1"}},
+		},
+	}
+	g.sum = []gomodModule{
+		{"github.com/pkg/errors", "v0.8.0"},
+		{"golang.org/x/text", "v0.0.0-20170915032832-14c0d48ead0c"},
+	}
+
+	m, l, err := g.convert("github.com/golang/notexist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantConstraints := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest":    "^1.0.1",
+		"github.com/sdboyer/deptestdos": "^2.0.0",
+	}
+	if len(m.Constraints) != len(wantConstraints) {
+		t.Fatalf("expected %d constraints, got %v", len(wantConstraints), m.Constraints)
+	}
+	for pr, want := range wantConstraints {
+		if got := m.Constraints[pr].Constraint.String(); got != want {
+			t.Errorf("expected %s to be constrained to %s, got %s", pr, want, got)
+		}
+	}
+	if m.GoVersion != "1.12" {
+		t.Errorf("expected the go directive to be the go-version, got %q", m.GoVersion)
+	}
+	if src := m.Constraints["github.com/sdboyer/deptest"].Source; src != "github.com/carolynvs/deptest" {
+		t.Errorf("expected the replacement to be the source of github.com/sdboyer/deptest, got %q", src)
+	}
+
+	wantLock := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest":    "v1.0.1",
+		"github.com/sdboyer/deptestdos": "v2.0.0",
+		"golang.org/x/text":             "master",
+		"github.com/pkg/errors":         "v0.8.0",
+	}
+	if len(l.P) != len(wantLock) {
+		t.Fatalf("expected %d locked projects, got %v", len(wantLock), l.P)
+	}
+	for _, lp := range l.P {
+		if want := wantLock[lp.Ident().ProjectRoot]; lp.Version().String() != want {
+			t.Errorf("expected %s to be locked to %s, got %s", lp.Ident().ProjectRoot, want, lp.Version())
+		}
+	}
+}
@@ -178,6 +178,27 @@ func getProjectPropertiesFromVersion(v gps.Version) gps.ProjectProperties {
 	return pp
 }
 
+// pairWithRelease returns the release of the project at pr tagged at the
+// revision v is checked out at, unless v is a release already. Projects
+// checked out at a bare revision, or at the tip of a branch, are then
+// constrained to a range of releases rather than to what happens to be on disk.
+func (g *gopathScanner) pairWithRelease(pr gps.ProjectRoot, v gps.Version) gps.Version {
+	rev := revisionOf(v)
+	if v.Type() == gps.IsSemver || rev == "" {
+		return v
+	}
+
+	lv, err := lookupVersionForLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, nil, rev, g.sm)
+	if err != nil {
+		g.ctx.Err.Println(err.Error())
+		return v
+	}
+	if lv.Type() != gps.IsSemver {
+		return v
+	}
+	return lv
+}
+
 type projectData struct {
 	constraints  gps.ProjectConstraints          // constraints that could be found
 	dependencies map[gps.ProjectRoot][]string    // all dependencies (imports) found by project root
@@ -230,6 +251,7 @@ func (g *gopathScanner) scanGopathForDependencies() (projectData, error) {
 			continue
 		}
 
+		v = g.pairWithRelease(pr, v)
 		ondisk[pr] = v
 		pp := getProjectPropertiesFromVersion(v)
 		if pp.Constraint != nil || pp.Source != "" {
@@ -175,3 +175,36 @@ func TestGetProjectPropertiesFromVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestGopathScanner_PairWithRelease(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	const (
+		tagged   gps.Revision = "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"
+		untagged gps.Revision = "3f4c3bea144e112a69bbe5d8d01c1b09a544253f"
+	)
+	sm := gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		gps.ProjectRoot(testProject1): {
+			gps.NewBranch("master").Pair(tagged),
+			gps.NewVersion("v1.0.0").Pair(tagged),
+			gps.NewBranch("develop").Pair(untagged),
+		},
+	}}
+	g := newGopathScanner(newTestContext(h), nil, sm)
+
+	cases := []struct {
+		v, want gps.Version
+	}{
+		{tagged, gps.NewVersion("v1.0.0").Pair(tagged)},
+		{gps.NewBranch("master").Pair(tagged), gps.NewVersion("v1.0.0").Pair(tagged)},
+		{untagged, untagged},
+		{gps.NewBranch("develop").Pair(untagged), gps.NewBranch("develop").Pair(untagged)},
+		{gps.NewVersion("v0.9.0").Pair(untagged), gps.NewVersion("v0.9.0").Pair(untagged)},
+	}
+	for _, c := range cases {
+		if got := g.pairWithRelease(gps.ProjectRoot(testProject1), c.v); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("pairWithRelease(%s): expected %s, got %s", c.v, c.want, got)
+		}
+	}
+}
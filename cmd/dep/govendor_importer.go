@@ -0,0 +1,200 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	fb "github.com/golang/dep/internal/feedback"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// govendorPath is the path of the file written by govendor.
+var govendorPath = filepath.Join("vendor", "vendor.json")
+
+type govendorImporter struct {
+	file govendorFile
+
+	logger  *log.Logger
+	verbose bool
+	sm      gps.SourceManager
+	report  *migrationReport
+}
+
+type govendorFile struct {
+	RootPath string            `json:"rootPath"`
+	Package  []govendorPackage `json:"package"`
+}
+
+type govendorPackage struct {
+	Path string `json:"path"`
+	// Origin is the import path the package was fetched from, when it isn't
+	// Path, as for forks. It may lie in the vendor directory of another
+	// project.
+	Origin   string `json:"origin"`
+	Revision string `json:"revision"`
+	// Version is the version requested, such as a tag, a branch or a major
+	// version like v1, which VersionExact resolved to.
+	Version      string `json:"version"`
+	VersionExact string `json:"versionExact"`
+}
+
+func newGovendorImporter(logger *log.Logger, verbose bool, sm gps.SourceManager) *govendorImporter {
+	return &govendorImporter{
+		logger:  logger,
+		verbose: verbose,
+		sm:      sm,
+	}
+}
+
+func (g *govendorImporter) Name() string { return "govendor" }
+
+func (g *govendorImporter) setReport(r *migrationReport) { g.report = r }
+
+func (g *govendorImporter) HasDepMetadata(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, govendorPath))
+	return err == nil
+}
+
+func (g *govendorImporter) Import(dir string, pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	g.logger.Println("Detected govendor configuration file...")
+
+	if err := g.load(dir); err != nil {
+		return nil, nil, err
+	}
+
+	return g.convert(pr)
+}
+
+func (g *govendorImporter) load(dir string) error {
+	g.logger.Println("Converting from vendor/vendor.json...")
+
+	f, err := os.Open(filepath.Join(dir, govendorPath))
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", govendorPath)
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&g.file); err != nil {
+		return errors.Wrapf(err, "unable to parse %s", govendorPath)
+	}
+	return nil
+}
+
+func (g *govendorImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	manifest := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	lock := &dep.Lock{}
+
+	for _, pkg := range g.file.Package {
+		if pkg.Path == "" {
+			return nil, nil, errors.New("invalid govendor configuration, the path is required")
+		}
+		if pkg.Revision == "" {
+			return nil, nil, errors.Errorf("invalid govendor configuration, the revision of %s is required", pkg.Path)
+		}
+
+		root, err := g.sm.DeduceProjectRoot(pkg.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		// govendor vendors packages one by one, so several of them may share
+		// a project.
+		if projectExistsInLock(lock, root) || root == pr {
+			continue
+		}
+		pi := gps.ProjectIdentifier{ProjectRoot: root}
+
+		// The projects fetched from another origin are redirected to it by a
+		// source override, once it's known to serve the revision.
+		src := gps.ProjectIdentifier{ProjectRoot: root}
+		if pkg.Origin != "" {
+			url, err := g.originSource(root, pkg.Path, pkg.Origin, gps.Revision(pkg.Revision))
+			if err != nil {
+				g.logger.Printf("  Ignoring the origin %s of %s: %s\n", pkg.Origin, root, err)
+			} else if url != "" {
+				src.Source = url
+				manifest.SourceOverrides = append(manifest.SourceOverrides, gps.SourceOverride{Prefix: string(root), URL: url})
+				g.logger.Printf("  Fetching %s from its origin %s\n", root, url)
+			}
+		}
+
+		var c gps.Constraint
+		if pkg.Version != "" {
+			if c, err = g.sm.InferConstraint(pkg.Version, src); err != nil {
+				g.logger.Printf("  Unable to interpret the version %s of %s: %s\n", pkg.Version, root, err)
+				c = nil
+			}
+		}
+
+		version, err := lookupVersionForLockedProject(src, c, gps.Revision(pkg.Revision), g.sm)
+		if err != nil {
+			g.logger.Println(err.Error())
+		}
+		g.report.locked(root, pkg.Revision, version, err)
+
+		if c != nil {
+			pc := gps.ProjectConstraint{Ident: pi, Constraint: c}
+			manifest.Constraints[root] = gps.ProjectProperties{Constraint: c}
+			fb.NewConstraintFeedback(pc, fb.DepTypeImported).LogFeedback(g.logger)
+			g.report.translated(pc, "version "+pkg.Version)
+		}
+
+		lp := gps.NewLockedProject(pi, version, nil)
+		lock.P = append(lock.P, lp)
+		fb.NewLockedProjectFeedback(lp, fb.DepTypeImported).LogFeedback(g.logger)
+	}
+
+	return manifest, lock, nil
+}
+
+// originSource returns the URL of the repository of origin, which the package
+// at path of the project root was fetched from, checking that it serves rev.
+// It returns "" when origin is the project's own repository, or a copy of it
+// vendored by another project.
+func (g *govendorImporter) originSource(root gps.ProjectRoot, path, origin string, rev gps.Revision) (string, error) {
+	if i := strings.LastIndex(origin, "/vendor/"); i >= 0 {
+		origin = origin[i+len("/vendor/"):]
+	}
+	if origin == path {
+		return "", nil
+	}
+
+	oroot, err := g.sm.DeduceProjectRoot(origin)
+	if err != nil {
+		return "", err
+	}
+	if oroot == root {
+		return "", nil
+	}
+	// The package must lie at the same place in both projects, as the source
+	// override serves the whole project from the origin.
+	if path[len(root):] != origin[len(oroot):] {
+		return "", errors.Errorf("%s is not at the same place in %s as %s is in %s", origin, oroot, path, root)
+	}
+
+	url := "https://" + string(oroot)
+	pi := gps.ProjectIdentifier{ProjectRoot: root, Source: url}
+	if exists, err := g.sm.SourceExists(pi); err != nil || !exists {
+		if err == nil {
+			err = errors.New("no repository found there")
+		}
+		return "", errors.Wrapf(err, "unable to reach %s", url)
+	}
+	if present, err := g.sm.RevisionPresentIn(pi, rev); err != nil || !present {
+		if err == nil {
+			err = errors.Errorf("revision %s not found", rev)
+		}
+		return "", errors.Wrapf(err, "unable to check %s", url)
+	}
+	return url, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+// govendorTestSM serves the revisions of sources from a fixed set, and infers
+// the constraints of the semantic versions only.
+type govendorTestSM struct {
+	gomodTestSM
+	revisions map[string][]gps.Revision
+}
+
+func (sm govendorTestSM) SourceExists(id gps.ProjectIdentifier) (bool, error) {
+	_, has := sm.revisions[id.Source]
+	return has, nil
+}
+
+func (sm govendorTestSM) RevisionPresentIn(id gps.ProjectIdentifier, r gps.Revision) (bool, error) {
+	for _, rev := range sm.revisions[id.Source] {
+		if rev == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (sm govendorTestSM) InferConstraint(s string, pi gps.ProjectIdentifier) (gps.Constraint, error) {
+	return gps.NewSemverConstraintIC(s)
+}
+
+func TestGovendorImporter_Import(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempCopy(filepath.Join(testProjectRoot, govendorPath), "govendor/vendor.json")
+	projectRoot := h.Path(testProjectRoot)
+
+	sm := govendorTestSM{
+		gomodTestSM: gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+			"github.com/sdboyer/deptest": {
+				gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+			},
+			"github.com/sdboyer/deptestdos": {
+				gps.NewVersion("v2.0.0").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+			},
+			"github.com/pkg/errors": {
+				gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+			},
+		}},
+		revisions: map[string][]gps.Revision{
+			"https://github.com/carolynvs/deptestdos": {"5c607206be5decd28e6263ffffdcee067266015e"},
+		},
+	}
+
+	g := newGovendorImporter(discardLogger, true, sm)
+	if !g.HasDepMetadata(projectRoot) {
+		t.Fatal("expected the govendor file to be detected")
+	}
+	m, l, err := g.Import(projectRoot, testProjectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantConstraints := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest": "^1.0.0",
+		"github.com/pkg/errors":      "^0.8.0",
+	}
+	if len(m.Constraints) != len(wantConstraints) {
+		t.Fatalf("expected %d constraints, got %v", len(wantConstraints), m.Constraints)
+	}
+	for pr, want := range wantConstraints {
+		if got := m.Constraints[pr].Constraint.String(); got != want {
+			t.Errorf("expected %s to be constrained to %s, got %s", pr, want, got)
+		}
+	}
+
+	wantOverrides := []gps.SourceOverride{{Prefix: "github.com/sdboyer/deptestdos", URL: "https://github.com/carolynvs/deptestdos"}}
+	if len(m.SourceOverrides) != 1 || m.SourceOverrides[0] != wantOverrides[0] {
+		t.Errorf("unexpected source overrides:\n\t(GOT): %v\n\t(WNT): %v", m.SourceOverrides, wantOverrides)
+	}
+
+	wantLock := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest":    "v1.0.0",
+		"github.com/sdboyer/deptestdos": "v2.0.0",
+		"golang.org/x/text":             "14c0d48ead0cd47e3a28f8d4a3d02e8a1ee1e4b3",
+		"github.com/pkg/errors":         "v0.8.0",
+	}
+	if len(l.P) != len(wantLock) {
+		t.Fatalf("expected %d locked projects, got %v", len(wantLock), l.P)
+	}
+	for _, lp := range l.P {
+		if want := wantLock[lp.Ident().ProjectRoot]; lp.Version().String() != want {
+			t.Errorf("expected %s to be locked to %s, got %s", lp.Ident().ProjectRoot, want, lp.Version())
+		}
+		if lp.Ident().Source != "" {
+			t.Errorf("expected %s to be locked without a source, got %q", lp.Ident().ProjectRoot, lp.Ident().Source)
+		}
+	}
+}
+
+func TestGovendorImporter_OriginSource(t *testing.T) {
+	sm := govendorTestSM{revisions: map[string][]gps.Revision{
+		"https://github.com/fork/bar": {"abc"},
+	}}
+	g := newGovendorImporter(discardLogger, true, sm)
+
+	cases := []struct {
+		path, origin string
+		rev          gps.Revision
+		want         string
+		err          bool
+	}{
+		{"github.com/foo/bar/pkg", "github.com/fork/bar/pkg", "abc", "https://github.com/fork/bar", false},
+		{"github.com/foo/bar/pkg", "github.com/other/proj/vendor/github.com/foo/bar/pkg", "abc", "", false},
+		{"github.com/foo/bar/pkg", "github.com/fork/bar/other", "abc", "", true},
+		{"github.com/foo/bar/pkg", "github.com/fork/bar/pkg", "def", "", true},
+		{"github.com/foo/bar/pkg", "github.com/gone/bar/pkg", "abc", "", true},
+	}
+	for _, c := range cases {
+		got, err := g.originSource("github.com/foo/bar", c.path, c.origin, c.rev)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("%s from %s at %s: expected %q (error: %t), got %q (%v)", c.path, c.origin, c.rev, c.want, c.err, got, err)
+		}
+	}
+}
+
+func TestGovendorImporter_Convert_Invalid(t *testing.T) {
+	cases := map[string]govendorPackage{
+		"no path":     {Revision: "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"},
+		"no revision": {Path: "github.com/sdboyer/deptest"},
+	}
+	for name, pkg := range cases {
+		g := newGovendorImporter(discardLogger, true, govendorTestSM{})
+		g.file.Package = []govendorPackage{pkg}
+		if _, _, err := g.convert(testProjectRoot); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
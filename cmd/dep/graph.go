@@ -0,0 +1,418 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/paths"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const graphShortHelp = `Print the import graph of the packages of the project and its dependencies`
+const graphLongHelp = `
+Print the graph of the imports between the packages of the project and those of
+the dependencies in Gopkg.lock which it reaches, as read from vendor/ when it
+holds them, and from the locked versions in the cache otherwise.
+
+With -out, print the graph in the given format:
+
+  dot      A Graphviz digraph, the default
+  json     A JSON array of objects with the fields ImportPath, Project (the
+           root of the project of the package, empty for the packages of no
+           locked project) and Imports
+  graphml  A GraphML document, whose nodes have a project attribute
+
+-filter takes a comma-separated list of import path prefixes, and leaves out
+the packages under none of them, along with the imports of and to them.
+
+The packages of the standard library are left out unless -std is passed, and
+the test imports of the project are only followed with -tests. Those of the
+dependencies never are.
+
+To render the graph, pipe it through Graphviz:
+
+  dep graph -filter github.com/my/project | dot -T png > imports.png
+`
+
+type graphCommand struct {
+	output string
+	filter string
+	std    bool
+	tests  bool
+}
+
+func (cmd *graphCommand) Name() string { return "graph" }
+func (cmd *graphCommand) Args() string {
+	return "[-out dot|json|graphml] [-filter <prefix>,...] [-std] [-tests]"
+}
+func (cmd *graphCommand) ShortHelp() string { return graphShortHelp }
+func (cmd *graphCommand) LongHelp() string  { return graphLongHelp }
+func (cmd *graphCommand) Hidden() bool      { return false }
+
+func (cmd *graphCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.output, "out", "dot", "output format: dot, json or graphml")
+	fs.StringVar(&cmd.filter, "filter", "", "comma-separated list of import path prefixes of the packages to keep")
+	fs.BoolVar(&cmd.std, "std", false, "include the packages of the standard library")
+	fs.BoolVar(&cmd.tests, "tests", false, "follow the test imports of the project")
+}
+
+func (cmd *graphCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep graph takes no arguments")
+	}
+
+	var write func(io.Writer, []graphPackage) error
+	switch cmd.output {
+	case "dot":
+		write = writePackageGraphDot
+	case "json":
+		write = writePackageGraphJSON
+	case "graphml":
+		write = writePackageGraphML
+	default:
+		return errors.Errorf("unknown output format %q; must be dot, json or graphml", cmd.output)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	trees, err := collectPackageTrees(p, sm)
+	if err != nil {
+		return err
+	}
+
+	var prefixes []string
+	for _, prefix := range strings.Split(cmd.filter, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, strings.TrimSuffix(prefix, "/"))
+		}
+	}
+	g := filterPackageGraph(packageGraph(trees, p.ImportRoot, p.Manifest.IgnoredPackages(), cmd.std, cmd.tests), prefixes)
+
+	var buf bytes.Buffer
+	if err := write(&buf, g); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// graphPackage is a node of the import graph of packages.
+type graphPackage struct {
+	ImportPath string
+	// Project is the root of the project of the package, if it's the root
+	// project or in the lock.
+	Project string `json:",omitempty"`
+	// Imports holds the import paths of the packages of the graph the package
+	// imports, sorted.
+	Imports []string
+}
+
+type byImportPath []graphPackage
+
+func (s byImportPath) Len() int           { return len(s) }
+func (s byImportPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byImportPath) Less(i, j int) bool { return s[i].ImportPath < s[j].ImportPath }
+
+// packageGraph returns the packages reachable from those of the root project
+// among the given package trees, keyed by the roots of their projects, sorted
+// by import path. The ignored packages are left out, as are those of the
+// standard library unless std is set. The test imports of the root project are
+// followed if tests is set.
+func packageGraph(trees map[gps.ProjectRoot]pkgtree.PackageTree, root gps.ProjectRoot, ignored map[string]bool, std, tests bool) []graphPackage {
+	ig := pkgtree.NewIgnoreRules(ignored)
+	pkgs := make(map[string]pkgtree.Package)
+	pkgProject := make(map[string]gps.ProjectRoot)
+	for pr, ptree := range trees {
+		for ip, poe := range ptree.Packages {
+			if poe.Err == nil && !ig.IsIgnored(ip) {
+				pkgs[ip], pkgProject[ip] = poe.P, pr
+			}
+		}
+	}
+
+	nodes := make(map[string]*graphPackage)
+	var queue []string
+	visit := func(ip string) {
+		if _, seen := nodes[ip]; seen {
+			return
+		}
+		nodes[ip] = &graphPackage{ImportPath: ip, Project: string(pkgProject[ip])}
+		queue = append(queue, ip)
+	}
+	for ip := range trees[root].Packages {
+		if _, has := pkgs[ip]; has {
+			visit(ip)
+		}
+	}
+
+	for len(queue) > 0 {
+		ip := queue[0]
+		queue = queue[1:]
+		pkg, has := pkgs[ip]
+		if !has {
+			// Outside of the trees, as are the packages of the standard
+			// library and those missing from the lock.
+			continue
+		}
+
+		imports := pkg.Imports
+		if tests && pkgProject[ip] == root {
+			imports = append(append([]string(nil), imports...), pkg.TestImports...)
+		}
+		n := nodes[ip]
+		for _, imp := range imports {
+			if imp == ip || ig.IsIgnored(imp) || imp == "C" || !std && paths.IsStandardImportPath(imp) {
+				continue
+			}
+			visit(imp)
+			n.Imports = append(n.Imports, imp)
+		}
+	}
+
+	g := make([]graphPackage, 0, len(nodes))
+	for _, n := range nodes {
+		n.Imports = dedupSorted(n.Imports)
+		g = append(g, *n)
+	}
+	sort.Sort(byImportPath(g))
+	return g
+}
+
+// dedupSorted sorts s and removes its duplicates.
+func dedupSorted(s []string) []string {
+	sort.Strings(s)
+	var out []string
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// filterPackageGraph returns the packages of g under one of the given
+// prefixes, with only their imports of one another. g is returned as is if
+// there are no prefixes.
+func filterPackageGraph(g []graphPackage, prefixes []string) []graphPackage {
+	if len(prefixes) == 0 {
+		return g
+	}
+
+	kept := func(ip string) bool {
+		for _, prefix := range prefixes {
+			if isPathPrefixOrEqual(prefix, ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []graphPackage
+	for _, n := range g {
+		if !kept(n.ImportPath) {
+			continue
+		}
+		fn := graphPackage{ImportPath: n.ImportPath, Project: n.Project}
+		for _, imp := range n.Imports {
+			if kept(imp) {
+				fn.Imports = append(fn.Imports, imp)
+			}
+		}
+		out = append(out, fn)
+	}
+	return out
+}
+
+// shortestImportChains returns, for each package of the root project in g
+// which reaches a package matching target, the shortest chain of imports from
+// it to one, starting with the root package and ending with the matching one.
+// The chains are sorted by root package.
+func shortestImportChains(g []graphPackage, root gps.ProjectRoot, target func(ip string) bool) [][]string {
+	imports := make(map[string][]string, len(g))
+	for _, n := range g {
+		imports[n.ImportPath] = n.Imports
+	}
+
+	var chains [][]string
+	for _, n := range g {
+		if n.Project != string(root) {
+			continue
+		}
+		// Breadth first, recording where each package was reached from.
+		from := map[string]string{n.ImportPath: ""}
+		queue := []string{n.ImportPath}
+		for len(queue) > 0 {
+			ip := queue[0]
+			queue = queue[1:]
+			if target(ip) {
+				var chain []string
+				for ; ip != ""; ip = from[ip] {
+					chain = append([]string{ip}, chain...)
+				}
+				chains = append(chains, chain)
+				break
+			}
+			for _, imp := range imports[ip] {
+				if _, seen := from[imp]; !seen {
+					from[imp] = ip
+					queue = append(queue, imp)
+				}
+			}
+		}
+	}
+	return chains
+}
+
+// allImportChains returns every chain of imports in g from a package of the
+// root project to a package matching target, which doesn't go through another
+// matching package or twice through the same one, sorted. If limit is
+// positive, no more than limit chains are returned.
+func allImportChains(g []graphPackage, root gps.ProjectRoot, target func(ip string) bool, limit int) [][]string {
+	imports := make(map[string][]string, len(g))
+	for _, n := range g {
+		imports[n.ImportPath] = n.Imports
+	}
+
+	var chains [][]string
+	var chain []string
+	onChain := make(map[string]bool)
+	var walk func(ip string)
+	walk = func(ip string) {
+		if onChain[ip] || limit > 0 && len(chains) >= limit {
+			return
+		}
+		chain = append(chain, ip)
+		onChain[ip] = true
+		if target(ip) {
+			chains = append(chains, append([]string(nil), chain...))
+		} else {
+			for _, imp := range imports[ip] {
+				walk(imp)
+			}
+		}
+		chain = chain[:len(chain)-1]
+		delete(onChain, ip)
+	}
+	for _, n := range g {
+		if n.Project == string(root) {
+			walk(n.ImportPath)
+		}
+	}
+	return chains
+}
+
+func writePackageGraphDot(w io.Writer, g []graphPackage) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph {\n\tnode [shape=box];\n")
+	for _, n := range g {
+		fmt.Fprintf(&buf, "\t%q;\n", n.ImportPath)
+	}
+	for _, n := range g {
+		for _, imp := range n.Imports {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", n.ImportPath, imp)
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writePackageGraphJSON(w io.Writer, g []graphPackage) error {
+	if g == nil {
+		g = []graphPackage{}
+	}
+	for i := range g {
+		if g[i].Imports == nil {
+			g[i].Imports = []string{}
+		}
+	}
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the import graph")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func writePackageGraphML(w io.Writer, g []graphPackage) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  []graphMLKey{{ID: "project", For: "node", AttrName: "project", AttrType: "string"}},
+	}
+	doc.Graph.ID = "imports"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g {
+		node := graphMLNode{ID: n.ImportPath}
+		if n.Project != "" {
+			node.Data = []graphMLData{{Key: "project", Value: n.Project}}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+		for _, imp := range n.Imports {
+			doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: n.ImportPath, Target: imp})
+		}
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the import graph")
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, b)
+	return err
+}
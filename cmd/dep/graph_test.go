@@ -0,0 +1,188 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+// graphTestTree returns a package tree of the given packages, each with its
+// imports, and test imports after a "test:" marker.
+func graphTestTree(pkgs map[string][]string) pkgtree.PackageTree {
+	ptree := pkgtree.PackageTree{Packages: make(map[string]pkgtree.PackageOrErr)}
+	for ip, imports := range pkgs {
+		var p pkgtree.Package
+		p.ImportPath = ip
+		for _, imp := range imports {
+			if strings.HasPrefix(imp, "test:") {
+				p.TestImports = append(p.TestImports, strings.TrimPrefix(imp, "test:"))
+			} else {
+				p.Imports = append(p.Imports, imp)
+			}
+		}
+		ptree.Packages[ip] = pkgtree.PackageOrErr{P: p}
+	}
+	return ptree
+}
+
+func TestPackageGraph(t *testing.T) {
+	trees := map[gps.ProjectRoot]pkgtree.PackageTree{
+		"example.com/root": graphTestTree(map[string][]string{
+			"example.com/root":     {"fmt", "example.com/root/lib", "github.com/a/a/sub"},
+			"example.com/root/lib": {"github.com/b/b", "github.com/ignored/pkg", "test:github.com/c/c"},
+		}),
+		"github.com/a/a": graphTestTree(map[string][]string{
+			"github.com/a/a":     {"os"},
+			"github.com/a/a/sub": {"github.com/a/a", "github.com/b/b"},
+		}),
+		"github.com/b/b": graphTestTree(map[string][]string{
+			"github.com/b/b":        {"github.com/missing/m", "test:github.com/a/a"},
+			"github.com/b/b/unused": {"github.com/a/a"},
+		}),
+		"github.com/c/c": graphTestTree(map[string][]string{
+			"github.com/c/c": nil,
+		}),
+	}
+	ignored := map[string]bool{"github.com/ignored/pkg": true}
+
+	got := packageGraph(trees, "example.com/root", ignored, false, false)
+	want := []graphPackage{
+		{ImportPath: "example.com/root", Project: "example.com/root", Imports: []string{"example.com/root/lib", "github.com/a/a/sub"}},
+		{ImportPath: "example.com/root/lib", Project: "example.com/root", Imports: []string{"github.com/b/b"}},
+		{ImportPath: "github.com/a/a", Project: "github.com/a/a"},
+		{ImportPath: "github.com/a/a/sub", Project: "github.com/a/a", Imports: []string{"github.com/a/a", "github.com/b/b"}},
+		{ImportPath: "github.com/b/b", Project: "github.com/b/b", Imports: []string{"github.com/missing/m"}},
+		{ImportPath: "github.com/missing/m"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected graph:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	// With the standard library and the test imports of the root project.
+	got = packageGraph(trees, "example.com/root", ignored, true, true)
+	var ips []string
+	for _, n := range got {
+		ips = append(ips, n.ImportPath)
+	}
+	wantIPs := []string{"example.com/root", "example.com/root/lib", "fmt", "github.com/a/a", "github.com/a/a/sub", "github.com/b/b", "github.com/c/c", "github.com/missing/m", "os"}
+	if !reflect.DeepEqual(ips, wantIPs) {
+		t.Errorf("unexpected packages with -std -tests:\n\t(GOT): %v\n\t(WNT): %v", ips, wantIPs)
+	}
+
+	filtered := filterPackageGraph(packageGraph(trees, "example.com/root", ignored, false, false), []string{"github.com/a", "github.com/b/b"})
+	wantFiltered := []graphPackage{
+		{ImportPath: "github.com/a/a", Project: "github.com/a/a"},
+		{ImportPath: "github.com/a/a/sub", Project: "github.com/a/a", Imports: []string{"github.com/a/a", "github.com/b/b"}},
+		{ImportPath: "github.com/b/b", Project: "github.com/b/b"},
+	}
+	if !reflect.DeepEqual(filtered, wantFiltered) {
+		t.Fatalf("unexpected filtered graph:\n\t(GOT): %+v\n\t(WNT): %+v", filtered, wantFiltered)
+	}
+
+	var buf bytes.Buffer
+	if err := writePackageGraphDot(&buf, filtered); err != nil {
+		t.Fatal(err)
+	}
+	wantDot := `digraph {
+	node [shape=box];
+	"github.com/a/a";
+	"github.com/a/a/sub";
+	"github.com/b/b";
+	"github.com/a/a/sub" -> "github.com/a/a";
+	"github.com/a/a/sub" -> "github.com/b/b";
+}
+`
+	if buf.String() != wantDot {
+		t.Errorf("unexpected dot output:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), wantDot)
+	}
+
+	buf.Reset()
+	if err := writePackageGraphJSON(&buf, filtered); err != nil {
+		t.Fatal(err)
+	}
+	var raw []graphPackage
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 3 || raw[0].Imports == nil || raw[1].ImportPath != "github.com/a/a/sub" || len(raw[1].Imports) != 2 {
+		t.Errorf("unexpected JSON output: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writePackageGraphML(&buf, filtered); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<graph id="imports" edgedefault="directed">`,
+		`<node id="github.com/a/a/sub">`,
+		`<data key="project">github.com/a/a</data>`,
+		`<edge source="github.com/a/a/sub" target="github.com/b/b"></edge>`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected the GraphML output to contain %s, got:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestShortestImportChains(t *testing.T) {
+	g := []graphPackage{
+		{ImportPath: "example.com/root", Project: "example.com/root", Imports: []string{"example.com/root/lib", "github.com/a/a/sub"}},
+		{ImportPath: "example.com/root/lib", Project: "example.com/root", Imports: []string{"github.com/b/b"}},
+		{ImportPath: "example.com/root/other", Project: "example.com/root"},
+		{ImportPath: "github.com/a/a/sub", Project: "github.com/a/a", Imports: []string{"github.com/b/b"}},
+		{ImportPath: "github.com/b/b", Project: "github.com/b/b"},
+	}
+
+	got := shortestImportChains(g, "example.com/root", func(ip string) bool { return isPathPrefixOrEqual("github.com/b/b", ip) })
+	want := [][]string{
+		{"example.com/root", "example.com/root/lib", "github.com/b/b"},
+		{"example.com/root/lib", "github.com/b/b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected chains:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	if got := shortestImportChains(g, "example.com/root", func(ip string) bool { return ip == "github.com/missing/m" }); got != nil {
+		t.Errorf("expected no chains, got %q", got)
+	}
+}
+
+func TestAllImportChains(t *testing.T) {
+	g := []graphPackage{
+		{ImportPath: "example.com/root", Project: "example.com/root", Imports: []string{"example.com/root/lib", "github.com/a/a"}},
+		{ImportPath: "example.com/root/lib", Project: "example.com/root", Imports: []string{"github.com/a/a", "github.com/b/b/sub"}},
+		{ImportPath: "github.com/a/a", Project: "github.com/a/a", Imports: []string{"github.com/b/b", "github.com/c/c"}},
+		{ImportPath: "github.com/b/b", Project: "github.com/b/b", Imports: []string{"github.com/b/b/sub"}},
+		{ImportPath: "github.com/b/b/sub", Project: "github.com/b/b"},
+		{ImportPath: "github.com/c/c", Project: "github.com/c/c", Imports: []string{"github.com/a/a"}},
+	}
+	inB := func(ip string) bool { return isPathPrefixOrEqual("github.com/b/b", ip) }
+
+	got := allImportChains(g, "example.com/root", inB, 0)
+	want := [][]string{
+		{"example.com/root", "example.com/root/lib", "github.com/a/a", "github.com/b/b"},
+		{"example.com/root", "example.com/root/lib", "github.com/b/b/sub"},
+		{"example.com/root", "github.com/a/a", "github.com/b/b"},
+		{"example.com/root/lib", "github.com/a/a", "github.com/b/b"},
+		{"example.com/root/lib", "github.com/b/b/sub"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected chains:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	if got := allImportChains(g, "example.com/root", inB, 2); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("unexpected limited chains:\n\t(GOT): %q\n\t(WNT): %q", got, want[:2])
+	}
+	if got := allImportChains(g, "example.com/root", func(ip string) bool { return ip == "github.com/d/d" }, 0); got != nil {
+		t.Errorf("expected no chains, got %q", got)
+	}
+}
@@ -15,18 +15,25 @@ type graphviz struct {
 	ps []*gvnode
 	b  bytes.Buffer
 	h  map[string]uint32
+	// ovr holds the overrides of the root project, which label all the
+	// relations to the projects they apply to.
+	ovr map[string]string
 }
 
 type gvnode struct {
 	project  string
 	version  string
 	children []string
+	// constraints holds the constraints the project declares on the projects
+	// it imports, which label the relations to them.
+	constraints map[string]string
 }
 
 func (g graphviz) New() *graphviz {
 	ga := &graphviz{
-		ps: []*gvnode{},
-		h:  make(map[string]uint32),
+		ps:  []*gvnode{},
+		h:   make(map[string]uint32),
+		ovr: make(map[string]string),
 	}
 	return ga
 }
@@ -50,7 +57,11 @@ func (g graphviz) output() bytes.Buffer {
 					r := fmt.Sprintf("\n\t%d -> %d", g.h[dp.project], hsh)
 
 					if _, ex := rels[r]; !ex {
-						g.b.WriteString(r + ";")
+						if label := g.relationLabel(dp, pr); label != "" {
+							g.b.WriteString(fmt.Sprintf("%s [label=\"%s\"];", r, label))
+						} else {
+							g.b.WriteString(r + ";")
+						}
 						rels[r] = true
 					}
 
@@ -63,11 +74,21 @@ func (g graphviz) output() bytes.Buffer {
 	return g.b
 }
 
-func (g *graphviz) createNode(project, version string, children []string) {
+// relationLabel returns the label of the relation from dp to the project pr:
+// the override of pr if any, or else the constraint dp declares on pr.
+func (g graphviz) relationLabel(dp *gvnode, pr string) string {
+	if c, has := g.ovr[pr]; has {
+		return c + " (override)"
+	}
+	return dp.constraints[pr]
+}
+
+func (g *graphviz) createNode(project, version string, children []string, constraints map[string]string) {
 	pr := &gvnode{
-		project:  project,
-		version:  version,
-		children: children,
+		project:     project,
+		version:     version,
+		children:    children,
+		constraints: constraints,
 	}
 
 	g.h[pr.project] = pr.hash()
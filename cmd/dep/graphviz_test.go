@@ -32,9 +32,9 @@ func TestSimpleProject(t *testing.T) {
 
 	g := new(graphviz).New()
 
-	g.createNode("project", "", []string{"foo", "bar"})
-	g.createNode("foo", "master", []string{"bar"})
-	g.createNode("bar", "dev", []string{})
+	g.createNode("project", "", []string{"foo", "bar"}, nil)
+	g.createNode("foo", "master", []string{"bar"}, nil)
+	g.createNode("bar", "dev", []string{}, nil)
 
 	b := g.output()
 	want := h.GetTestFileString("graphviz/case1.dot")
@@ -50,7 +50,7 @@ func TestNoLinks(t *testing.T) {
 
 	g := new(graphviz).New()
 
-	g.createNode("project", "", []string{})
+	g.createNode("project", "", []string{}, nil)
 
 	b := g.output()
 	want := h.GetTestFileString("graphviz/case2.dot")
@@ -59,6 +59,26 @@ func TestNoLinks(t *testing.T) {
 	}
 }
 
+func TestProjectWithConstraints(t *testing.T) {
+	h := test.NewHelper(t)
+	h.Parallel()
+	defer h.Cleanup()
+
+	g := new(graphviz).New()
+	g.ovr["baz"] = "^2.0.0"
+
+	g.createNode("project", "", []string{"foo", "baz"}, map[string]string{"foo": "^1.0.0", "baz": "^1.0.0"})
+	g.createNode("foo", "v1.2.0", []string{"bar/sub", "baz"}, map[string]string{"bar": "branch master"})
+	g.createNode("bar", "master", []string{}, nil)
+	g.createNode("baz", "v2.1.0", []string{}, nil)
+
+	b := g.output()
+	want := h.GetTestFileString("graphviz/case3.dot")
+	if b.String() != want {
+		t.Fatalf("expected '%v', got '%v'", want, b.String())
+	}
+}
+
 func TestIsPathPrefix(t *testing.T) {
 	t.Parallel()
 
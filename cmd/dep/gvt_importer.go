@@ -0,0 +1,169 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	fb "github.com/golang/dep/internal/feedback"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// gvtPath is the path of the manifest written by gvt and gb-vendor, which
+// share its format.
+var gvtPath = filepath.Join("vendor", "manifest")
+
+type gvtImporter struct {
+	manifest gvtManifest
+
+	logger  *log.Logger
+	verbose bool
+	sm      gps.SourceManager
+	report  *migrationReport
+}
+
+type gvtManifest struct {
+	Deps []gvtPackage `json:"dependencies"`
+}
+
+type gvtPackage struct {
+	ImportPath string `json:"importpath"`
+	Repository string `json:"repository"`
+	Revision   string `json:"revision"`
+	Branch     string `json:"branch"`
+}
+
+func newGvtImporter(logger *log.Logger, verbose bool, sm gps.SourceManager) *gvtImporter {
+	return &gvtImporter{
+		logger:  logger,
+		verbose: verbose,
+		sm:      sm,
+	}
+}
+
+func (g *gvtImporter) Name() string { return "gvt" }
+
+func (g *gvtImporter) setReport(r *migrationReport) { g.report = r }
+
+func (g *gvtImporter) HasDepMetadata(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, gvtPath))
+	return err == nil
+}
+
+func (g *gvtImporter) Import(dir string, pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	g.logger.Println("Detected gvt configuration file...")
+
+	if err := g.load(dir); err != nil {
+		return nil, nil, err
+	}
+
+	return g.convert(pr)
+}
+
+func (g *gvtImporter) load(dir string) error {
+	g.logger.Println("Converting from vendor/manifest...")
+
+	f, err := os.Open(filepath.Join(dir, gvtPath))
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", gvtPath)
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&g.manifest); err != nil {
+		return errors.Wrapf(err, "unable to parse %s", gvtPath)
+	}
+	return nil
+}
+
+func (g *gvtImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	manifest := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	lock := &dep.Lock{}
+
+	for _, pkg := range g.manifest.Deps {
+		if pkg.ImportPath == "" {
+			return nil, nil, errors.New("invalid gvt configuration, the import path is required")
+		}
+		if pkg.Revision == "" {
+			return nil, nil, errors.Errorf("invalid gvt configuration, the revision of %s is required", pkg.ImportPath)
+		}
+
+		root, err := g.sm.DeduceProjectRoot(pkg.ImportPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		// gvt vendors packages one by one, so several of them may share a
+		// project.
+		if projectExistsInLock(lock, root) {
+			continue
+		}
+		pi := gps.ProjectIdentifier{ProjectRoot: root, Source: gvtSource(root, pkg.Repository)}
+
+		// Both gvt and gb-vendor record the master branch unless another one
+		// was requested, and gb-vendor records HEAD when a tag or a revision
+		// was requested instead. Only other branches are constraints then.
+		var c gps.Constraint
+		if pkg.Branch != "" && pkg.Branch != "master" && pkg.Branch != "HEAD" {
+			c = gps.NewBranch(pkg.Branch)
+		}
+
+		version, err := lookupVersionForLockedProject(pi, c, gps.Revision(pkg.Revision), g.sm)
+		if err != nil {
+			g.logger.Println(err.Error())
+		}
+
+		g.report.locked(root, pkg.Revision, version, err)
+
+		// Without a branch, constrain to the release the revision is tagged
+		// with, if any.
+		inferred := false
+		if c == nil {
+			if pv, ok := version.(gps.PairedVersion); ok && pv.Type() == gps.IsSemver {
+				c, _ = gps.NewSemverConstraintIC(pv.Unpair().String())
+				inferred = c != nil
+			}
+		}
+		if c != nil {
+			pc := gps.ProjectConstraint{Ident: pi, Constraint: c}
+			manifest.Constraints[root] = gps.ProjectProperties{Source: pi.Source, Constraint: c}
+			fb.NewConstraintFeedback(pc, fb.DepTypeImported).LogFeedback(g.logger)
+			if inferred {
+				g.report.approximated(pc, "", "inferred from the version at revision "+pkg.Revision)
+			} else {
+				g.report.translated(pc, "branch "+pkg.Branch)
+			}
+		}
+
+		lp := gps.NewLockedProject(pi, version, nil)
+		lock.P = append(lock.P, lp)
+		fb.NewLockedProjectFeedback(lp, fb.DepTypeImported).LogFeedback(g.logger)
+	}
+
+	return manifest, lock, nil
+}
+
+// gvtSource returns the repository of a project as its source, unless it's
+// the default repository of its root.
+func gvtSource(root gps.ProjectRoot, repository string) string {
+	if repository == "" {
+		return ""
+	}
+	trimmed := repository
+	if i := strings.Index(trimmed, "://"); i >= 0 {
+		trimmed = trimmed[i+len("://"):]
+	}
+	trimmed = strings.TrimSuffix(strings.TrimSuffix(trimmed, "/"), ".git")
+	if trimmed == string(root) {
+		return ""
+	}
+	return repository
+}
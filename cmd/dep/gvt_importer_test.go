@@ -0,0 +1,85 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestGvtImporter_Import(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempCopy(filepath.Join(testProjectRoot, gvtPath), "gvt/manifest")
+	projectRoot := h.Path(testProjectRoot)
+
+	sm := gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/sdboyer/deptest": {
+			gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+		},
+		"github.com/sdboyer/deptestdos": {
+			gps.NewVersion("v2.0.0").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+			gps.NewBranch("v2").Pair("5c607206be5decd28e6263ffffdcee067266015e"),
+		},
+	}}
+
+	g := newGvtImporter(discardLogger, true, sm)
+	if !g.HasDepMetadata(projectRoot) {
+		t.Fatal("expected the gvt manifest to be detected")
+	}
+	m, l, err := g.Import(projectRoot, testProjectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantConstraints := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest":    "^1.0.0",
+		"github.com/sdboyer/deptestdos": "v2",
+	}
+	if len(m.Constraints) != len(wantConstraints) {
+		t.Fatalf("expected %d constraints, got %v", len(wantConstraints), m.Constraints)
+	}
+	for pr, want := range wantConstraints {
+		if got := m.Constraints[pr].Constraint.String(); got != want {
+			t.Errorf("expected %s to be constrained to %s, got %s", pr, want, got)
+		}
+	}
+
+	wantLock := map[gps.ProjectRoot]struct{ version, source string }{
+		"github.com/sdboyer/deptest":    {"v1.0.0", ""},
+		"github.com/sdboyer/deptestdos": {"v2", "https://github.com/carolynvs/deptestdos"},
+		"golang.org/x/text":             {"14c0d48ead0cd47e3a28f8d4a3d02e8a1ee1e4b3", "https://go.googlesource.com/text"},
+	}
+	if len(l.P) != len(wantLock) {
+		t.Fatalf("expected %d locked projects, got %v", len(wantLock), l.P)
+	}
+	for _, lp := range l.P {
+		want := wantLock[lp.Ident().ProjectRoot]
+		if lp.Version().String() != want.version {
+			t.Errorf("expected %s to be locked to %s, got %s", lp.Ident().ProjectRoot, want.version, lp.Version())
+		}
+		if lp.Ident().Source != want.source {
+			t.Errorf("expected the source of %s to be %q, got %q", lp.Ident().ProjectRoot, want.source, lp.Ident().Source)
+		}
+	}
+}
+
+func TestGvtImporter_Convert_Invalid(t *testing.T) {
+	cases := map[string]gvtPackage{
+		"no import path": {Revision: "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"},
+		"no revision":    {ImportPath: "github.com/sdboyer/deptest"},
+	}
+	for name, pkg := range cases {
+		g := newGvtImporter(discardLogger, true, gomodTestSM{})
+		g.manifest.Deps = []gvtPackage{pkg}
+		if _, _, err := g.convert(testProjectRoot); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
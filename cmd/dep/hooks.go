@@ -0,0 +1,122 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+// The points of dep ensure at which the hooks of Gopkg.toml run.
+const (
+	hookPreSolve  = "pre-solve"
+	hookPostWrite = "post-write"
+)
+
+// hookEnv returns the variables describing the project to the hooks of the
+// given stage, on top of the environment of dep.
+func hookEnv(p *dep.Project, stage string) []string {
+	return []string{
+		"DEP_HOOK=" + stage,
+		"DEP_PROJECT_ROOT=" + p.AbsRoot,
+		"DEP_IMPORT_ROOT=" + string(p.ImportRoot),
+		"DEP_VENDOR_DIR=" + p.VendorDir(),
+	}
+}
+
+// runHooks runs the commands of the hooks of a stage in turn, from the root of
+// the project, with env added to their environment, stopping at the first
+// which fails.
+func runHooks(ctx *dep.Ctx, p *dep.Project, stage string, cmds []string, env []string) error {
+	for _, hook := range cmds {
+		ctx.Err.Printf("Running %s hook: %s", stage, hook)
+		c := hookCommand(hook)
+		c.Dir = p.AbsRoot
+		c.Env = append(os.Environ(), env...)
+		out, err := c.CombinedOutput()
+		if len(out) > 0 {
+			ctx.Err.Print(string(out))
+		}
+		if err != nil {
+			return errors.Errorf("%s hook %q failed: %s", stage, hook, err)
+		}
+	}
+	return nil
+}
+
+// hookCommand returns the command running hook through the shell.
+func hookCommand(hook string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", hook)
+	}
+	return exec.Command("sh", "-c", hook)
+}
+
+// runPreSolveHooks runs the pre-solve hooks of the project, if any.
+func runPreSolveHooks(ctx *dep.Ctx, p *dep.Project) error {
+	if p.Manifest == nil || len(p.Manifest.Hooks.PreSolve) == 0 {
+		return nil
+	}
+	return runHooks(ctx, p, hookPreSolve, p.Manifest.Hooks.PreSolve, hookEnv(p, hookPreSolve))
+}
+
+// runPostWriteHooks runs the post-write hooks of the project, if any, telling
+// them how the lock changed from old, the lock before the write, to the one
+// written. DEP_LOCK_DIFF is the path of a file holding the changes as dep diff
+// -json prints them, and DEP_CHANGED_PROJECTS the roots of the projects which
+// changed, separated by spaces.
+func runPostWriteHooks(ctx *dep.Ctx, p *dep.Project, old *dep.Lock) error {
+	if p.Manifest == nil || len(p.Manifest.Hooks.PostWrite) == 0 {
+		return nil
+	}
+
+	cur := &dep.Lock{}
+	if f, err := os.Open(p.LockPath()); err == nil {
+		l, err := dep.ReadLock(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrap(err, "unable to read the lock written")
+		}
+		cur = l
+	}
+	if old == nil {
+		old = &dep.Lock{}
+	}
+	deltas := diffLockProjects(old, cur)
+
+	var buf bytes.Buffer
+	if err := writeLockDiffJSON(&buf, deltas); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile("", "dep-lock-diff")
+	if err != nil {
+		return errors.Wrap(err, "unable to write the lock diff for the hooks")
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to write the lock diff for the hooks")
+	}
+
+	changed := make([]string, len(deltas))
+	for i, d := range deltas {
+		changed[i] = string(d.ProjectRoot)
+	}
+	env := append(hookEnv(p, hookPostWrite),
+		"DEP_LOCK_DIFF="+f.Name(),
+		"DEP_CHANGED_PROJECTS="+strings.Join(changed, " "),
+	)
+	return runHooks(ctx, p, hookPostWrite, p.Manifest.Hooks.PostWrite, env)
+}
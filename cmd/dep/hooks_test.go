@@ -0,0 +1,81 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestRunPostWriteHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are written for sh")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("project")
+	root := h.Path("project")
+
+	p := &dep.Project{
+		AbsRoot:    root,
+		ImportRoot: "github.com/golang/notexist",
+		LockName:   dep.LockName,
+		Manifest: &dep.Manifest{Hooks: dep.Hooks{PostWrite: []string{
+			`echo "$DEP_HOOK $DEP_IMPORT_ROOT $DEP_CHANGED_PROJECTS" > hook.out`,
+			`cp "$DEP_LOCK_DIFF" diff.json`,
+		}}},
+	}
+	old := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc"), []string{"."}),
+	}}
+	cur := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.1.0").Pair("def"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewVersion("v2.0.0").Pair("123"), []string{"."}),
+	}}
+	b, err := cur.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.TempFile(filepath.Join("project", dep.LockName), string(b))
+
+	ctx := &dep.Ctx{Out: discardLogger, Err: discardLogger}
+	if err := runPostWriteHooks(ctx, p, old); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadFile(filepath.Join(root, "hook.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "post-write github.com/golang/notexist github.com/foo/bar github.com/foo/baz"; strings.TrimSpace(string(out)) != want {
+		t.Errorf("unexpected hook environment:\n\t(GOT): %s\n\t(WNT): %s", out, want)
+	}
+
+	var deltas []lockDelta
+	b, err = ioutil.ReadFile(filepath.Join(root, "diff.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &deltas); err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 || deltas[0].Change != "modified" || deltas[1].Change != "added" {
+		t.Errorf("unexpected lock diff: %s", b)
+	}
+
+	p.Manifest.Hooks.PostWrite = []string{"exit 3"}
+	if err := runPostWriteHooks(ctx, p, old); err == nil || !strings.Contains(err.Error(), "post-write hook") {
+		t.Errorf("expected the failure of the hook to be reported, got %v", err)
+	}
+}
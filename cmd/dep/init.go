@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"io/ioutil"
 	"log"
@@ -29,7 +30,15 @@ specified, use the current directory.
 When configuration for another dependency management tool is detected, it is
 imported into the initial manifest and lock. Use the -skip-tools flag to
 disable this behavior. The following external tools are supported:
-glide, godep, vndr.
+glide, godep, vndr, gvt and gb (vendor/manifest), and Go modules (go.mod).
+Other tools are supported by importer plugins: executables named
+dep-importer-<tool> on the PATH, which are tried after the built-in importers.
+
+What the import did is reported once it's done: every constraint translated,
+approximated or dropped, and every locked revision which couldn't be paired
+with a version of its project. -migration-report writes the report as JSON to
+the given file as well, with the tool, constraints and unpaired fields, for the
+conversion to be audited.
 
 Any dependencies that are not constrained by external configuration use the
 GOPATH analysis below.
@@ -43,9 +52,11 @@ algorithm:
  - Non-semver tags (sorted lexicographically)
 
 An alternate mode can be activated by passing -gopath. In this mode, the version
-of each dependency will reflect the current state of the GOPATH. If a dependency
-doesn't exist in the GOPATH, a version will be selected based on the above
-network version selection algorithm.
+of each dependency will reflect the current state of the GOPATH. Dependencies
+checked out at a revision which upstream tagged as a semver release, including
+at the tip of a branch, are constrained to that release, as in ^1.2.0. If a
+dependency doesn't exist in the GOPATH, a version will be selected based on the
+above network version selection algorithm.
 
 A Gopkg.toml file will be written with inferred version constraints for all
 direct dependencies. Gopkg.lock will be written with precise versions, and
@@ -62,12 +73,16 @@ func (cmd *initCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.noExamples, "no-examples", false, "don't include example in Gopkg.toml")
 	fs.BoolVar(&cmd.skipTools, "skip-tools", false, "skip importing configuration from other dependency managers")
 	fs.BoolVar(&cmd.gopath, "gopath", false, "search in GOPATH for dependencies")
+	fs.BoolVar(&cmd.stats, "stats", false, "print a breakdown of where the time went at exit")
+	fs.StringVar(&cmd.migrationReport, "migration-report", "", "write the report of the imported configuration as JSON to the given file")
 }
 
 type initCommand struct {
-	noExamples bool
-	skipTools  bool
-	gopath     bool
+	noExamples      bool
+	skipTools       bool
+	gopath          bool
+	stats           bool
+	migrationReport string
 }
 
 func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -89,7 +104,10 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	}
 
 	var err error
-	p := new(dep.Project)
+	p := &dep.Project{
+		ManifestName: ctx.ManifestFile(),
+		LockName:     ctx.LockFile(),
+	}
 	if err = p.SetRoot(root); err != nil {
 		return errors.Wrap(err, "NewProject")
 	}
@@ -99,8 +117,8 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		return errors.Wrapf(err, "ctx.DetectProjectGOPATH")
 	}
 
-	mf := filepath.Join(root, dep.ManifestName)
-	lf := filepath.Join(root, dep.LockName)
+	mf := p.ManifestPath()
+	lf := p.LockPath()
 	vpath := filepath.Join(root, "vendor")
 
 	mok, err := fs.IsRegular(mf)
@@ -133,6 +151,12 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
 
+	var rs *runStats
+	if cmd.stats {
+		rs = newRunStats()
+		defer func() { rs.report(ctx, sm) }()
+	}
+
 	pkgT, directDeps, err := getDirectDependencies(sm, p)
 	if err != nil {
 		return err
@@ -144,6 +168,25 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := p.CheckGoVersion(); err != nil {
+		return err
+	}
+
+	// The source overrides imported from the other tool must apply to the
+	// solve, and the SourceManager only takes them before its first use.
+	if len(p.Manifest.SourceOverrides) > 0 {
+		sm.Release()
+		ctx.SourceOverrides = p.Manifest.SourceOverrides
+		if sm, err = ctx.SourceManager(); err != nil {
+			return errors.Wrap(err, "getSourceManager")
+		}
+		sm.UseDefaultSignalHandling()
+		defer sm.Release()
+		rootAnalyzer.sm = sm
+	}
+	if err := cmd.writeMigrationReport(ctx, rootAnalyzer.report); err != nil {
+		return err
+	}
 
 	if cmd.gopath {
 		gs := newGopathScanner(ctx, directDeps, sm)
@@ -162,11 +205,13 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		Manifest:        p.Manifest,
 		Lock:            p.Lock,
 		ProjectAnalyzer: rootAnalyzer,
+		GoVersion:       p.Manifest.GoVersion,
 	}
 
 	if ctx.Verbose {
 		params.TraceLogger = ctx.Err
 	}
+	params.TraceEvents = ctx.TraceEvents
 
 	if err := ctx.ValidateParams(sm, params); err != nil {
 		return err
@@ -177,9 +222,11 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		return errors.Wrap(err, "prepare solver")
 	}
 
+	start := time.Now()
 	soln, err := s.Solve()
+	rs.solved(start, soln)
 	if err != nil {
-		handleAllTheFailuresOfTheWorld(err)
+		handleAllTheFailuresOfTheWorld(ctx, err)
 		return err
 	}
 	p.Lock = dep.LockFromSolution(soln)
@@ -208,18 +255,49 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 	if err != nil {
 		return err
 	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	sw.ExportWorkers(ctx.Workers)
 
 	logger := ctx.Err
 	if !ctx.Verbose {
 		logger = log.New(ioutil.Discard, "", 0)
 	}
-	if err := sw.Write(root, sm, !cmd.noExamples, logger); err != nil {
+	start = time.Now()
+	err = sw.Write(root, sm, !cmd.noExamples, logger)
+	rs.wrote(start, filepath.Join(root, "vendor"), sw)
+	if err != nil {
 		return errors.Wrap(err, "safe write of manifest and lock")
 	}
+	recordLockSources(ctx, p, sm)
+	warnNestedVendorConflicts(ctx, sw.NestedVendorConflicts(), dep.NestedVendorStrip)
 
 	return nil
 }
 
+// writeMigrationReport prints the report of the imported configuration, if any
+// was, and writes it as JSON to the file given by -migration-report.
+func (cmd *initCommand) writeMigrationReport(ctx *dep.Ctx, r *migrationReport) error {
+	if r != nil && r.Tool != "" && !r.empty() {
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf); err != nil {
+			return err
+		}
+		ctx.Err.Print(buf.String())
+	}
+
+	if cmd.migrationReport == "" {
+		return nil
+	}
+	if r == nil {
+		r = &migrationReport{}
+	}
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(cmd.migrationReport, buf.Bytes(), 0666), "failed to write the migration report to %s", cmd.migrationReport)
+}
+
 func getDirectDependencies(sm gps.SourceManager, p *dep.Project) (pkgtree.PackageTree, map[string]bool, error) {
 	pkgT, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
 	if err != nil {
@@ -239,7 +317,19 @@ func getDirectDependencies(sm gps.SourceManager, p *dep.Project) (pkgtree.Packag
 	return pkgT, directDeps, nil
 }
 
+// handleAllTheFailuresOfTheWorld explains a solve failure to the user, as the
+// tree of the constraints which excluded each version of the project for which
+// none could be selected.
+//
 // TODO solve failures can be really creative - we need to be similarly creative
 // in handling them and informing the user appropriately
-func handleAllTheFailuresOfTheWorld(err error) {
+func handleAllTheFailuresOfTheWorld(ctx *dep.Ctx, err error) {
+	sf := gps.NewSolveFailure(err)
+	if sf == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	sf.WriteTree(&buf)
+	ctx.Err.Printf("Solving failure:\n%s", buf.String())
 }
@@ -0,0 +1,187 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// licenseFilePattern matches the names of the files in which projects
+// conventionally keep their license text.
+var licenseFilePattern = regexp.MustCompile(`(?i)^(un)?licen[cs]e(\.(md|txt))?$|^copying(\.(md|txt))?$`)
+
+// licenseSignatures maps SPDX identifiers to phrases which are distinctive of
+// the corresponding license text. They are checked in order, and the first
+// one for which all phrases are found wins, so more specific licenses must
+// come before the ones they could be mistaken for.
+var licenseSignatures = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"WTFPL", []string{"do what the fuck you want to public license"}},
+}
+
+// detectLicense identifies the license of the project rooted at dir. It
+// returns the SPDX identifier of the license, "Unknown" if a license file
+// exists but could not be identified, or the empty string if there is no
+// license file at all.
+func detectLicense(dir string) string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	var found bool
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || !licenseFilePattern.MatchString(fi.Name()) {
+			continue
+		}
+		found = true
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		if id := identifyLicense(string(b)); id != "" {
+			return id
+		}
+	}
+
+	if found {
+		return "Unknown"
+	}
+	return ""
+}
+
+// identifyLicense returns the SPDX identifier of the license whose text is
+// provided, or the empty string if it isn't recognized.
+func identifyLicense(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, sig := range licenseSignatures {
+		matched := true
+		for _, phrase := range sig.phrases {
+			if !strings.Contains(text, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return sig.id
+		}
+	}
+	return ""
+}
+
+// projectLicense is the license of a locked project, as reported by
+// dep status -licenses.
+type projectLicense struct {
+	ProjectRoot string
+	Version     string
+	Revision    gps.Revision
+	// License is the SPDX identifier of the license, "Unknown" if it could
+	// not be identified, or "None" if the project has no license file.
+	License string
+	Denied  bool `json:",omitempty"`
+}
+
+// lockedLicenses detects the licenses of the projects of l, from their copy in
+// vendorDir if it has a license file, or from their locked version exported
+// by sm otherwise. The licenses listed in deny are marked as denied.
+func lockedLicenses(l *dep.Lock, vendorDir string, sm gps.SourceManager, deny map[string]bool) ([]projectLicense, error) {
+	td, err := ioutil.TempDir("", "dep-licenses")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	licenses := make([]projectLicense, 0, len(l.P))
+	for i, lp := range l.P {
+		id := lp.Ident()
+		license := detectLicense(filepath.Join(vendorDir, filepath.FromSlash(string(id.ProjectRoot))))
+		if license == "" {
+			license = exportedLicense(sm, id, lp.Version(), filepath.Join(td, strconv.Itoa(i)))
+		}
+		if license == "" {
+			license = "None"
+		}
+
+		pl := projectLicense{
+			ProjectRoot: string(id.ProjectRoot),
+			License:     license,
+			Denied:      deny[license],
+		}
+		switch v := lp.Version().(type) {
+		case gps.PairedVersion:
+			pl.Version, pl.Revision = formatVersion(v.Unpair()), v.Revision()
+		case gps.Revision:
+			pl.Revision = v
+		}
+		licenses = append(licenses, pl)
+	}
+	return licenses, nil
+}
+
+// parseLicenseList parses a comma-separated list of licenses into a set.
+func parseLicenseList(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+func writeLicenses(w io.Writer, licenses []projectLicense) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tVERSION\tREVISION\tLICENSE")
+	for _, pl := range licenses {
+		license := pl.License
+		if pl.Denied {
+			license += " (denied)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", pl.ProjectRoot, pl.Version, formatVersion(pl.Revision), license)
+	}
+	tw.Flush()
+}
+
+// deniedLicensesError returns an error listing the projects whose license is
+// denied, or nil if there are none.
+func deniedLicensesError(licenses []projectLicense) error {
+	var denied []string
+	for _, pl := range licenses {
+		if pl.Denied {
+			denied = append(denied, fmt.Sprintf("%s (%s)", pl.ProjectRoot, pl.License))
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	return errors.Errorf("%d project(s) with a denied license: %s", len(denied), strings.Join(denied, ", "))
+}
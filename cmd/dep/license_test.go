@@ -0,0 +1,131 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestIdentifyLicense(t *testing.T) {
+	tests := map[string]string{
+		"Permission is hereby granted, free of charge, to any person obtaining a copy":                                        "MIT",
+		"Apache License\n   Version 2.0, January 2004":                                                                        "Apache-2.0",
+		"Redistribution and use in source and binary forms, with or without\nmodification... Neither the name of Google Inc.": "BSD-3-Clause",
+		"Redistribution and use in source and binary forms, with or without modification":                                     "BSD-2-Clause",
+		"GNU LESSER GENERAL PUBLIC LICENSE\n Version 3, 29 June 2007":                                                         "LGPL-3.0",
+		"Mozilla Public License Version 2.0":                                                                                  "MPL-2.0",
+		"All rights reserved.":                                                                                                "",
+	}
+
+	for text, want := range tests {
+		if got := identifyLicense(text); got != want {
+			t.Errorf("%q: expected %q, got %q", text, want, got)
+		}
+	}
+}
+
+func TestDetectLicense(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep-license-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if got := detectLicense(dir); got != "" {
+		t.Fatalf("expected no license, got %q", got)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "LICENSE.txt"), []byte("Proprietary. All rights reserved."), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectLicense(dir); got != "Unknown" {
+		t.Fatalf("expected unknown license, got %q", got)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "COPYING"), []byte("Permission is hereby granted, free of charge, to any person"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectLicense(dir); got != "MIT" {
+		t.Fatalf("expected MIT, got %q", got)
+	}
+}
+
+// licenseTestSM exports projects with the license text it holds for them.
+type licenseTestSM struct {
+	gps.SourceManager
+	licenses map[gps.ProjectRoot]string
+}
+
+func (sm licenseTestSM) ExportProject(id gps.ProjectIdentifier, v gps.Version, to string) error {
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	text, has := sm.licenses[id.ProjectRoot]
+	if !has {
+		return nil
+	}
+	return ioutil.WriteFile(filepath.Join(to, "LICENSE"), []byte(text), 0666)
+}
+
+func TestLockedLicenses(t *testing.T) {
+	vendorDir, err := ioutil.TempDir("", "dep-license-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(vendorDir)
+
+	vendored := filepath.Join(vendorDir, "github.com", "a", "vendored")
+	if err := os.MkdirAll(vendored, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(vendored, "LICENSE"), []byte("Apache License\n   Version 2.0, January 2004"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/exported"}, gps.NewVersion("v1.0.0").Pair("abcdef0123456789"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/gpl"}, gps.NewBranch("master").Pair("0123456789abcdef"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/none"}, gps.Revision("fedcba9876543210"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/vendored"}, gps.NewVersion("v2.0.0").Pair("9876543210fedcba"), []string{"."}),
+	}}
+	sm := licenseTestSM{licenses: map[gps.ProjectRoot]string{
+		"github.com/a/exported": "Permission is hereby granted, free of charge, to any person",
+		"github.com/a/gpl":      "GNU GENERAL PUBLIC LICENSE\n Version 3, 29 June 2007",
+		// Vendored projects are not exported.
+		"github.com/a/vendored": "Permission is hereby granted, free of charge, to any person",
+	}}
+
+	licenses, err := lockedLicenses(l, vendorDir, sm, parseLicenseList("GPL-3.0, None,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writeLicenses(&buf, licenses)
+	want := `PROJECT                VERSION        REVISION  LICENSE
+github.com/a/exported  v1.0.0         abcdef0   MIT
+github.com/a/gpl       branch master  0123456   GPL-3.0 (denied)
+github.com/a/none                     fedcba9   None (denied)
+github.com/a/vendored  v2.0.0         9876543   Apache-2.0
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected licenses:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	err = deniedLicensesError(licenses)
+	if err == nil || err.Error() != "2 project(s) with a denied license: github.com/a/gpl (GPL-3.0), github.com/a/none (None)" {
+		t.Errorf("unexpected error for the denied licenses: %v", err)
+	}
+	if err := deniedLicensesError(licenses[:1]); err != nil {
+		t.Errorf("expected no error without denied licenses, got %v", err)
+	}
+}
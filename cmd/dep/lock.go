@@ -0,0 +1,173 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const lockShortHelp = `Pin the dependencies to their locked revisions`
+const lockLongHelp = `
+Freeze dependencies at the revisions recorded in Gopkg.lock, for instance on a
+release branch.
+
+  dep lock pin [-overrides] (-all | <project>...)
+
+Make sure every selected entry of Gopkg.lock records the revision of its
+version or branch, looking up the revisions of the entries which only name a
+version. With -overrides, also add an override to Gopkg.toml pinning each
+selected project to its locked revision, replacing any existing override
+constraint, so that dep ensure -update can't move it anymore. Remove the
+overrides to unfreeze the projects.
+`
+
+func (cmd *lockCommand) Name() string      { return "lock" }
+func (cmd *lockCommand) Args() string      { return "pin [-overrides] (-all | <project>...)" }
+func (cmd *lockCommand) ShortHelp() string { return lockShortHelp }
+func (cmd *lockCommand) LongHelp() string  { return lockLongHelp }
+func (cmd *lockCommand) Hidden() bool      { return false }
+
+func (cmd *lockCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.all, "all", false, "pin all the projects of the lock")
+	fs.BoolVar(&cmd.overrides, "overrides", false, "also pin the projects with revision overrides in Gopkg.toml")
+}
+
+type lockCommand struct {
+	all       bool
+	overrides bool
+}
+
+func (cmd *lockCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 || args[0] != "pin" {
+		return errors.New("usage: dep lock pin [-overrides] (-all | <project>...)")
+	}
+
+	// The flags of the subcommand follow it.
+	fs := flag.NewFlagSet("lock pin", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.Register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if cmd.all == (fs.NArg() > 0) {
+		return errors.New("dep lock pin takes either -all or projects")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to pin", dep.LockName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	var roots map[gps.ProjectRoot]bool
+	if !cmd.all {
+		roots = make(map[gps.ProjectRoot]bool, fs.NArg())
+		for _, arg := range fs.Args() {
+			lp, err := findLockedProject(p.Lock, sm, arg)
+			if err != nil {
+				return err
+			}
+			roots[lp.Ident().ProjectRoot] = true
+		}
+	}
+
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	newLock := &dep.Lock{SolveMeta: p.Lock.SolveMeta, Dev: p.Lock.Dev}
+	for _, lp := range p.Lock.P {
+		id := lp.Ident()
+		v := lp.Version()
+		if (roots == nil || roots[id.ProjectRoot]) && revisionOf(v) == "" {
+			vl, err := sm.ListVersions(id)
+			if err != nil {
+				return errors.Wrapf(err, "failed to list the versions of %s", id.ProjectRoot)
+			}
+			pv := pairVersion(v, vl)
+			if pv == nil {
+				return errors.Errorf("%s has no version %s anymore", id.ProjectRoot, v)
+			}
+			logger.Printf("Pinning %s %s to %s", id.ProjectRoot, v, pv.Revision())
+			v = pv
+			lp = gps.NewLockedProject(id, v, lp.Packages())
+		}
+		newLock.P = append(newLock.P, lp)
+	}
+
+	var m *dep.Manifest
+	if cmd.overrides {
+		m = p.Manifest
+		pinOverrides(m, newLock, roots)
+
+		// The overrides change the solver inputs, so the digest needs to be
+		// recomputed for the lock to remain in sync.
+		ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+		if err != nil {
+			return errors.Wrap(err, "analysis of local packages failed")
+		}
+		params := p.MakeParams()
+		params.RootPackageTree = ptree
+		params.Lock = newLock
+		s, err := gps.Prepare(params, sm)
+		if err != nil {
+			return errors.Wrap(err, "could not set up solver for input hashing")
+		}
+		newLock.SolveMeta.InputsDigest = s.HashInputs()
+	}
+
+	sw, err := dep.NewSafeWriter(m, p.Lock, newLock, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	return errors.Wrap(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest and lock failed")
+}
+
+// pairVersion returns the version of vl matching the unpaired version v, or
+// nil if there is none.
+func pairVersion(v gps.Version, vl []gps.PairedVersion) gps.PairedVersion {
+	if pv, ok := v.(gps.PairedVersion); ok {
+		v = pv.Unpair()
+	}
+	for _, pv := range vl {
+		if pv.Type() == v.Type() && pv.String() == v.String() {
+			return pv
+		}
+	}
+	return nil
+}
+
+// pinOverrides overrides the constraints on the projects of l with the given
+// roots, or all of them if roots is nil, with their locked revisions.
+func pinOverrides(m *dep.Manifest, l *dep.Lock, roots map[gps.ProjectRoot]bool) {
+	for _, lp := range l.P {
+		id := lp.Ident()
+		if roots != nil && !roots[id.ProjectRoot] {
+			continue
+		}
+		m.Ovr[id.ProjectRoot] = gps.ProjectProperties{
+			Source:     id.Source,
+			Constraint: revisionOf(lp.Version()),
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestPairVersion(t *testing.T) {
+	vl := []gps.PairedVersion{
+		gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+		gps.NewBranch("v1.0.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+		gps.NewBranch("master").Pair("d1f1e3a5b87e8f1bf1cdcf3e5ebcb3bd1ed59da3"),
+	}
+
+	if got := pairVersion(gps.NewVersion("v1.0.0").Pair(""), vl); got != vl[0] {
+		t.Errorf("expected %s to be paired with %s, got %v", vl[0], vl[0].Revision(), got)
+	}
+	if got := pairVersion(gps.NewBranch("master"), vl); got != vl[2] {
+		t.Errorf("expected %s to be paired with %s, got %v", vl[2], vl[2].Revision(), got)
+	}
+	if got := pairVersion(gps.NewVersion("v2.0.0"), vl); got != nil {
+		t.Errorf("expected no version to pair v2.0.0 with, got %v", got)
+	}
+}
+
+func TestPinOverrides(t *testing.T) {
+	rev := gps.Revision("ff2948a2ac8f538c4ecd55962e919d1e13e74baf")
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a", Source: "github.com/fork/a"}, gps.NewVersion("v1.0.0").Pair(rev), []string{"."}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, rev, []string{"."}),
+		},
+	}
+
+	m := &dep.Manifest{
+		Ovr: gps.ProjectConstraints{
+			"github.com/b/b": {Constraint: gps.NewBranch("master")},
+		},
+	}
+	pinOverrides(m, l, map[gps.ProjectRoot]bool{"github.com/b/b": true})
+	want := gps.ProjectConstraints{
+		"github.com/b/b": {Constraint: rev},
+	}
+	if !reflect.DeepEqual(m.Ovr, want) {
+		t.Errorf("unexpected overrides:\n\t(GOT): %v\n\t(WNT): %v", m.Ovr, want)
+	}
+
+	pinOverrides(m, l, nil)
+	want["github.com/a/a"] = gps.ProjectProperties{Source: "github.com/fork/a", Constraint: rev}
+	if !reflect.DeepEqual(m.Ovr, want) {
+		t.Errorf("unexpected overrides:\n\t(GOT): %v\n\t(WNT): %v", m.Ovr, want)
+	}
+}
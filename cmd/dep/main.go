@@ -6,6 +6,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
@@ -13,10 +14,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
 )
 
 type command interface {
@@ -59,9 +64,32 @@ func (c *Config) Run() (exitCode int) {
 	commands := []command{
 		&initCommand{},
 		&statusCommand{},
+		&checkCommand{},
+		&outdatedCommand{},
+		&versionsCommand{},
+		&explainCommand{},
+		&whyCommand{},
+		&vendorCommand{},
+		&cyclesCommand{},
+		&graphCommand{},
+		&sizeCommand{},
+		&networkCommand{},
+		&toolCommand{},
+		&generateCommand{},
+		&buildinfoCommand{},
+		&suggestCommand{},
 		&ensureCommand{},
+		&tidyCommand{},
+		&renameCommand{},
+		&lockCommand{},
+		&checkManifestCommand{},
+		&diffCommand{},
 		&hashinCommand{},
 		&pruneCommand{},
+		&cacheCommand{},
+		&cacheServerCommand{},
+		&daemonCommand{},
+		&migrateCommand{},
 	}
 
 	examples := [][2]string{
@@ -123,6 +151,7 @@ func (c *Config) Run() (exitCode int) {
 			fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 			fs.SetOutput(c.Stderr)
 			verbose := fs.Bool("v", false, "enable verbose logging")
+			traceFile := fs.String("trace-file", "", "write the trace of the solver to this file, as JSON lines")
 
 			// Register the subcommand flags in there, too.
 			cmd.Register(fs)
@@ -146,18 +175,126 @@ func (c *Config) Run() (exitCode int) {
 
 			// Set up dep context.
 			ctx := &dep.Ctx{
-				Out:     outLogger,
-				Err:     errLogger,
-				Verbose: *verbose,
+				Out:         outLogger,
+				Err:         errLogger,
+				Verbose:     *verbose,
+				CacheServer: getEnv(c.Env, "DEPCACHESERVER"),
+				CacheDir:    getEnv(c.Env, "DEPCACHEDIR"),
+				SourcesDir:  getEnv(c.Env, "DEPSOURCESDIR"),
+				ModuleProxy: getEnv(c.Env, "DEPMODULEPROXY"),
+
+				ManifestName: getEnv(c.Env, "DEPMANIFEST"),
+				LockName:     getEnv(c.Env, "DEPLOCK"),
+			}
+			if isTerminal(c.Stderr, c.Env) && !*verbose {
+				ctx.Progress = c.Stderr
+			}
+			if err := ctx.CheckFileNames(); err != nil {
+				errLogger.Printf("%v\n", err)
+				exitCode = 1
+				return
+			}
+			if workers := getEnv(c.Env, "DEPWORKERS"); workers != "" {
+				n, err := strconv.Atoi(workers)
+				if err != nil || n <= 0 {
+					errLogger.Printf("DEPWORKERS must be a positive number, got %q\n", workers)
+					exitCode = 1
+					return
+				}
+				ctx.Workers = n
+			}
+
+			if age := getEnv(c.Env, "DEPCACHEAGE"); age != "" {
+				d, err := time.ParseDuration(age)
+				if err != nil || d < 0 {
+					errLogger.Printf("DEPCACHEAGE must be a non-negative duration, such as 24h, got %q\n", age)
+					exitCode = 1
+					return
+				}
+				ctx.CacheAge = d
+			}
+
+			if timeout := getEnv(c.Env, "DEPLOCKTIMEOUT"); timeout != "" {
+				d, err := time.ParseDuration(timeout)
+				if err != nil || d < 0 {
+					errLogger.Printf("DEPLOCKTIMEOUT must be a non-negative duration, such as 10m, got %q\n", timeout)
+					exitCode = 1
+					return
+				}
+				ctx.LockTimeout = d
+			}
+
+			if shallow := getEnv(c.Env, "DEPSHALLOW"); shallow != "" {
+				b, err := strconv.ParseBool(shallow)
+				if err != nil {
+					errLogger.Printf("DEPSHALLOW must be a boolean, such as 1 or 0, got %q\n", shallow)
+					exitCode = 1
+					return
+				}
+				ctx.ShallowClones = b
+			}
+
+			if offline := getEnv(c.Env, "DEPNOVENDORNET"); offline != "" {
+				b, err := strconv.ParseBool(offline)
+				if err != nil {
+					errLogger.Printf("DEPNOVENDORNET must be a boolean, such as 1 or 0, got %q\n", offline)
+					exitCode = 1
+					return
+				}
+				ctx.Offline = b
+			}
+
+			if protocols := getEnv(c.Env, "DEPPROTOCOLS"); protocols != "" {
+				pp, err := gps.ParseProtocolPolicies(protocols)
+				if err != nil {
+					errLogger.Printf("DEPPROTOCOLS: %v\n", err)
+					exitCode = 1
+					return
+				}
+				ctx.ProtocolPolicies = pp
+			}
+
+			creds, err := loadCredentials(c.Env)
+			if err != nil {
+				errLogger.Printf("%v\n", err)
+				exitCode = 1
+				return
+			}
+			ctx.Credentials = creds
+
+			if socket := getEnv(c.Env, "DEPDAEMON"); socket != "" {
+				env, exe := c.Env, c.Args[0]
+				ctx.Daemon = socket
+				ctx.StartDaemon = func(socket string) error {
+					return startDaemon(exe, env, socket)
+				}
 			}
 
 			GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
 			ctx.SetPaths(c.WorkingDir, GOPATHS...)
 
+			if *traceFile != "" {
+				f, err := os.Create(*traceFile)
+				if err != nil {
+					errLogger.Printf("%v\n", err)
+					exitCode = 1
+					return
+				}
+				w := bufio.NewWriter(f)
+				defer func() {
+					w.Flush()
+					f.Close()
+				}()
+				ctx.TraceEvents = w
+			}
+
 			// Run the command with the post-flag-processing args.
 			if err := cmd.Run(ctx, fs.Args()); err != nil {
 				errLogger.Printf("%v\n", err)
 				exitCode = 1
+				if ec, ok := err.(exitCoder); ok {
+					exitCode = ec.exitCode()
+				}
 				return
 			}
 
@@ -172,6 +309,12 @@ func (c *Config) Run() (exitCode int) {
 	return
 }
 
+// exitCoder is implemented by the errors of the commands which exit with a
+// code of their own, rather than 1.
+type exitCoder interface {
+	exitCode() int
+}
+
 func resetUsage(logger *log.Logger, fs *flag.FlagSet, name, args, longHelp string) {
 	var (
 		hasFlags   bool
@@ -228,6 +371,45 @@ func parseArgs(args []string) (cmdName string, printCmdUsage bool, exit bool) {
 	return cmdName, printCmdUsage, exit
 }
 
+// loadCredentials gathers the credentials of the hosts serving sources from the
+// auth file of DEPAUTHFILE, the API tokens of DEPGITHUBTOKEN and DEPGITLABTOKEN,
+// and the netrc file of NETRC, or of the home directory, in that order of
+// precedence.
+func loadCredentials(env []string) ([]gps.Credential, error) {
+	var auth, tokens, netrc []gps.Credential
+	var err error
+	if path := getEnv(env, "DEPAUTHFILE"); path != "" {
+		if auth, err = dep.ReadAuthFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if token := getEnv(env, "DEPGITHUBTOKEN"); token != "" {
+		tokens = append(tokens, dep.TokenCredential("github.com", token))
+	}
+	if token := getEnv(env, "DEPGITLABTOKEN"); token != "" {
+		tokens = append(tokens, dep.TokenCredential("gitlab.com", token))
+	}
+
+	path := getEnv(env, "NETRC")
+	if path == "" {
+		home, name := getEnv(env, "HOME"), ".netrc"
+		if runtime.GOOS == "windows" {
+			home, name = getEnv(env, "USERPROFILE"), "_netrc"
+		}
+		if home != "" {
+			path = filepath.Join(home, name)
+		}
+	}
+	if path != "" {
+		if netrc, err = dep.ReadNetrc(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return dep.MergeCredentials(auth, tokens, netrc), nil
+}
+
 // getEnv returns the last instance of an environment variable.
 func getEnv(env []string, key string) string {
 	for i := len(env) - 1; i >= 0; i-- {
@@ -0,0 +1,123 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const migrateShortHelp = `Rewrite Gopkg.toml and Gopkg.lock in the current format`
+const migrateLongHelp = `
+Rewrite Gopkg.toml and Gopkg.lock in the current version of their format,
+recorded in them as format-version.
+
+The files written by older versions of dep are migrated each time they are
+read, and written back in the format version they declare, so that the
+versions of dep they were written by can still read them. Once everyone
+working on the project has upgraded dep, run dep migrate to record the
+current format version in them for good.
+
+The changes the migration makes are reported. With -dry-run, they are only
+reported, and nothing is written.
+`
+
+type migrateCommand struct {
+	dryRun bool
+}
+
+func (cmd *migrateCommand) Name() string      { return "migrate" }
+func (cmd *migrateCommand) Args() string      { return "[-dry-run]" }
+func (cmd *migrateCommand) ShortHelp() string { return migrateShortHelp }
+func (cmd *migrateCommand) LongHelp() string  { return migrateLongHelp }
+func (cmd *migrateCommand) Hidden() bool      { return false }
+
+func (cmd *migrateCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes the migration makes")
+}
+
+func (cmd *migrateCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep migrate takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	mdata, err := ioutil.ReadFile(p.ManifestPath())
+	if err != nil {
+		return err
+	}
+	mversion, mchanges, err := dep.PendingManifestMigrations(mdata)
+	if err != nil {
+		return errors.Wrapf(err, "unable to migrate %s", p.ManifestPath())
+	}
+	writeManifest := p.Manifest.FormatVersion < dep.CurrentManifestFormat
+	writeMigration(&buf, filepath.Base(p.ManifestPath()), mversion, dep.CurrentManifestFormat, mchanges)
+
+	var oldLock, newLock *dep.Lock
+	if p.Lock != nil {
+		ldata, err := ioutil.ReadFile(p.LockPath())
+		if err != nil {
+			return err
+		}
+		lversion, lchanges, err := dep.PendingLockMigrations(ldata)
+		if err != nil {
+			return errors.Wrapf(err, "unable to migrate %s", p.LockPath())
+		}
+		writeMigration(&buf, filepath.Base(p.LockPath()), lversion, dep.CurrentLockFormat, lchanges)
+
+		if p.Lock.FormatVersion < dep.CurrentLockFormat {
+			nl := *p.Lock
+			nl.FormatVersion = dep.CurrentLockFormat
+			oldLock, newLock = p.Lock, &nl
+		}
+	}
+	ctx.Out.Print(buf.String())
+
+	if cmd.dryRun || (!writeManifest && newLock == nil) {
+		return nil
+	}
+
+	var m *dep.Manifest
+	if writeManifest {
+		m = p.Manifest
+		m.FormatVersion = dep.CurrentManifestFormat
+	}
+	sw, err := dep.NewSafeWriter(m, oldLock, newLock, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	return errors.Wrap(sw.Write(p.AbsRoot, nil, false, logger), "grouped write of manifest and lock failed")
+}
+
+// writeMigration writes to w how the file name migrates from the format
+// version it's in to current.
+func writeMigration(w io.Writer, name string, version, current int, changes []string) {
+	if version >= current && len(changes) == 0 {
+		fmt.Fprintf(w, "%s is in format version %d already\n", name, version)
+		return
+	}
+	fmt.Fprintf(w, "%s: format version %d to %d\n", name, version, current)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMigration(t *testing.T) {
+	var buf bytes.Buffer
+	writeMigration(&buf, "Gopkg.toml", 1, 2, []string{"renamed [[dependencies]] to [[constraint]]"})
+	writeMigration(&buf, "Gopkg.lock", 1, 2, nil)
+	writeMigration(&buf, "Gopkg.toml", 2, 2, nil)
+
+	want := `Gopkg.toml: format version 1 to 2
+  renamed [[dependencies]] to [[constraint]]
+Gopkg.lock: format version 1 to 2
+Gopkg.toml is in format version 2 already
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected migrations:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
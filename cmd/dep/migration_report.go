@@ -0,0 +1,215 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+// The statuses of the constraints of a migration report.
+const (
+	// migrationTranslated is the status of a constraint which means the same
+	// in Gopkg.toml as in the configuration of the other tool.
+	migrationTranslated = "translated"
+	// migrationApproximated is the status of a constraint which only
+	// approximates what the other tool was told, or which it wasn't told at
+	// all but was inferred.
+	migrationApproximated = "approximated"
+	// migrationDropped is the status of a constraint left out of Gopkg.toml.
+	migrationDropped = "dropped"
+)
+
+// migrationReport records what importing the configuration of another tool
+// did, for users to audit the conversion dep init made. Its methods may be
+// called on a nil report, which records nothing.
+type migrationReport struct {
+	// Tool is the name of the tool whose configuration was imported, or empty
+	// if none was.
+	Tool        string               `json:"tool,omitempty"`
+	Constraints []migratedConstraint `json:"constraints"`
+	// Unpaired holds the locked revisions which couldn't be paired with a
+	// version of their project.
+	Unpaired []unpairedRevision `json:"unpaired"`
+}
+
+// migratedConstraint is what became of the constraint of the other tool on a
+// project.
+type migratedConstraint struct {
+	ProjectRoot string `json:"projectRoot"`
+	// From is the version the other tool was told, if any.
+	From string `json:"from,omitempty"`
+	// To is the constraint in Gopkg.toml, unless it was dropped.
+	To     string `json:"to,omitempty"`
+	Status string `json:"status"`
+	// Reason tells why a constraint was approximated or dropped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// unpairedRevision is a revision locked by the other tool which is locked
+// without a version.
+type unpairedRevision struct {
+	ProjectRoot string `json:"projectRoot"`
+	Revision    string `json:"revision"`
+	// Reason is the error which prevented the versions of the project from
+	// being looked up, if any.
+	Reason string `json:"reason,omitempty"`
+}
+
+// translated records that from was translated into the constraint of pc.
+func (r *migrationReport) translated(pc gps.ProjectConstraint, from string) {
+	r.addConstraint(pc.Ident.ProjectRoot, from, pc.Constraint, migrationTranslated, "")
+}
+
+// approximated records that from was approximated by the constraint of pc.
+func (r *migrationReport) approximated(pc gps.ProjectConstraint, from, reason string) {
+	r.addConstraint(pc.Ident.ProjectRoot, from, pc.Constraint, migrationApproximated, reason)
+}
+
+// dropped records that no constraint was kept on pr for from.
+func (r *migrationReport) dropped(pr gps.ProjectRoot, from, reason string) {
+	r.addConstraint(pr, from, nil, migrationDropped, reason)
+}
+
+func (r *migrationReport) addConstraint(pr gps.ProjectRoot, from string, c gps.Constraint, status, reason string) {
+	if r == nil {
+		return
+	}
+	mc := migratedConstraint{
+		ProjectRoot: string(pr),
+		From:        from,
+		Status:      status,
+		Reason:      reason,
+	}
+	if c != nil {
+		mc.To = c.String()
+	}
+	r.Constraints = append(r.Constraints, mc)
+}
+
+// dropConstraint marks the constraint already recorded on pr as dropped.
+func (r *migrationReport) dropConstraint(pr gps.ProjectRoot, reason string) {
+	if r == nil {
+		return
+	}
+	for i, mc := range r.Constraints {
+		if mc.ProjectRoot == string(pr) && mc.Status != migrationDropped {
+			r.Constraints[i].To = ""
+			r.Constraints[i].Status = migrationDropped
+			r.Constraints[i].Reason = reason
+		}
+	}
+}
+
+// locked records rev as unpaired unless v, what it's locked to, is paired with
+// a version. err is the error looking the version up returned, if any. Only
+// the first revision of a project is recorded, as it's the one locked.
+func (r *migrationReport) locked(pr gps.ProjectRoot, rev string, v gps.Version, err error) {
+	if r == nil {
+		return
+	}
+	if _, paired := v.(gps.PairedVersion); paired {
+		return
+	}
+	for _, ur := range r.Unpaired {
+		if ur.ProjectRoot == string(pr) {
+			return
+		}
+	}
+	ur := unpairedRevision{ProjectRoot: string(pr), Revision: rev}
+	if err != nil {
+		ur.Reason = err.Error()
+	}
+	r.Unpaired = append(r.Unpaired, ur)
+}
+
+// empty reports whether nothing was recorded.
+func (r *migrationReport) empty() bool {
+	return r == nil || (len(r.Constraints) == 0 && len(r.Unpaired) == 0)
+}
+
+// sort orders the constraints and the revisions by project.
+func (r *migrationReport) sort() {
+	sort.Stable(byMigratedProject(r.Constraints))
+	sort.Stable(byUnpairedProject(r.Unpaired))
+}
+
+// WriteJSON writes the report as JSON to w.
+func (r *migrationReport) WriteJSON(w io.Writer) error {
+	r.sort()
+	if r.Constraints == nil {
+		r.Constraints = []migratedConstraint{}
+	}
+	if r.Unpaired == nil {
+		r.Unpaired = []unpairedRevision{}
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteText writes the report as tables to w.
+func (r *migrationReport) WriteText(w io.Writer) error {
+	r.sort()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Migration report for the %s configuration:\n", r.Tool)
+	if len(r.Constraints) > 0 {
+		fmt.Fprintln(&buf, "\nConstraints:")
+		tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  PROJECT\tFROM\tTO\tSTATUS\tREASON")
+		for _, mc := range r.Constraints {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", mc.ProjectRoot, orDash(mc.From), orDash(mc.To), mc.Status, mc.Reason)
+		}
+		tw.Flush()
+	}
+	if len(r.Unpaired) > 0 {
+		fmt.Fprintln(&buf, "\nRevisions locked without a version:")
+		tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  PROJECT\tREVISION\tREASON")
+		for _, ur := range r.Unpaired {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", ur.ProjectRoot, ur.Revision, ur.Reason)
+		}
+		tw.Flush()
+	}
+
+	// The tables pad empty reasons.
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range lines {
+		if strings.HasSuffix(line, "\n") {
+			lines[i] = strings.TrimRight(line, " \n") + "\n"
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, ""))
+	return err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+type byMigratedProject []migratedConstraint
+
+func (s byMigratedProject) Len() int           { return len(s) }
+func (s byMigratedProject) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byMigratedProject) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
+
+type byUnpairedProject []unpairedRevision
+
+func (s byUnpairedProject) Len() int           { return len(s) }
+func (s byUnpairedProject) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byUnpairedProject) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
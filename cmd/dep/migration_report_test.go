@@ -0,0 +1,110 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+func TestMigrationReport(t *testing.T) {
+	semver, err := gps.NewSemverConstraintIC("1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo := gps.ProjectConstraint{Ident: gps.ProjectIdentifier{ProjectRoot: "github.com/x/foo"}, Constraint: semver}
+	bar := gps.ProjectConstraint{Ident: gps.ProjectIdentifier{ProjectRoot: "github.com/x/bar"}, Constraint: gps.NewBranch("dev")}
+	rev := gps.Revision("ff2948a2ac8f538c4ecd55962e919d1e13e74baf")
+
+	r := &migrationReport{Tool: "glide"}
+	r.translated(foo, "~1.2.0")
+	r.approximated(bar, "dev", "its os and arch are ignored")
+	r.dropped("github.com/x/local", "v1.0.0", "replaced by the local directory ../local")
+	r.translated(gps.ProjectConstraint{Ident: gps.ProjectIdentifier{ProjectRoot: "github.com/x/indirect"}, Constraint: semver}, "1.2.0")
+
+	m := &dep.Manifest{Constraints: gps.ProjectConstraints{
+		foo.Ident.ProjectRoot:   gps.ProjectProperties{Constraint: foo.Constraint},
+		bar.Ident.ProjectRoot:   gps.ProjectProperties{Constraint: bar.Constraint},
+		"github.com/x/indirect": gps.ProjectProperties{Constraint: semver},
+	}}
+	a := &rootAnalyzer{directDeps: map[string]bool{"github.com/x/foo": true, "github.com/x/bar": true}}
+	a.removeTransitiveDependencies(m, r)
+
+	r.locked("github.com/x/foo", string(rev), gps.NewVersion("v1.2.0").Pair(rev), nil)
+	r.locked("github.com/x/bar", string(rev), rev, nil)
+	r.locked("github.com/x/bar", "0000000", rev, nil)
+	r.locked("github.com/x/baz", "v1.0.0", nil, errors.New("no version v1.0.0"))
+
+	var nilReport *migrationReport
+	nilReport.translated(foo, "~1.2.0")
+	nilReport.locked("github.com/x/bar", string(rev), rev, nil)
+	if !nilReport.empty() {
+		t.Error("expected a nil report to be empty")
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got migrationReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := migrationReport{
+		Tool: "glide",
+		Constraints: []migratedConstraint{
+			{ProjectRoot: "github.com/x/bar", From: "dev", To: "dev", Status: migrationApproximated, Reason: "its os and arch are ignored"},
+			{ProjectRoot: "github.com/x/foo", From: "~1.2.0", To: "^1.2.0", Status: migrationTranslated},
+			{ProjectRoot: "github.com/x/indirect", From: "1.2.0", Status: migrationDropped, Reason: "not imported by the project"},
+			{ProjectRoot: "github.com/x/local", From: "v1.0.0", Status: migrationDropped, Reason: "replaced by the local directory ../local"},
+		},
+		Unpaired: []unpairedRevision{
+			{ProjectRoot: "github.com/x/bar", Revision: string(rev)},
+			{ProjectRoot: "github.com/x/baz", Revision: "v1.0.0", Reason: "no version v1.0.0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected report:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	buf.Reset()
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantText := `Migration report for the glide configuration:
+
+Constraints:
+  PROJECT                FROM    TO      STATUS        REASON
+  github.com/x/bar       dev     dev     approximated  its os and arch are ignored
+  github.com/x/foo       ~1.2.0  ^1.2.0  translated
+  github.com/x/indirect  1.2.0   -       dropped       not imported by the project
+  github.com/x/local     v1.0.0  -       dropped       replaced by the local directory ../local
+
+Revisions locked without a version:
+  PROJECT           REVISION                                  REASON
+  github.com/x/bar  ff2948a2ac8f538c4ecd55962e919d1e13e74baf
+  github.com/x/baz  v1.0.0                                    no version v1.0.0
+`
+	if buf.String() != wantText {
+		t.Errorf("unexpected text report:\n(GOT):\n%s\n(WNT):\n%s", buf.String(), wantText)
+	}
+}
+
+func TestMigrationReportEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&migrationReport{}).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"constraints\": [],\n  \"unpaired\": []\n}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected report %q, want %q", got, want)
+	}
+}
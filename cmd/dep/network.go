@@ -0,0 +1,220 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const networkShortHelp = `Report the network usage of past runs`
+const networkLongHelp = `
+Report how much dep ensure and dep status used the network, per host, so that
+the dependencies which cost the most on metered or slow links can be found.
+
+Every run of dep ensure or dep status which contacts upstream records the
+number of contacts made with each source, deduction endpoint and cache
+server, along with the bytes downloaded, in a history kept in the cache
+directory. The bytes downloaded by VCS sources are estimated from the growth
+of their local copy, and contacts which merely list versions count none.
+
+The -since flag only reports the runs since a date, given as YYYY-MM-DD or an
+RFC 3339 timestamp. The -sources flag breaks the usage down per source, and
+the -runs flag per run. The -clear flag removes the history.
+`
+
+func (cmd *networkCommand) Name() string      { return "network" }
+func (cmd *networkCommand) Args() string      { return "[-since <date>] [-sources] [-runs] [-clear]" }
+func (cmd *networkCommand) ShortHelp() string { return networkShortHelp }
+func (cmd *networkCommand) LongHelp() string  { return networkLongHelp }
+func (cmd *networkCommand) Hidden() bool      { return false }
+
+func (cmd *networkCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.since, "since", "", "only report the runs since the given date")
+	fs.BoolVar(&cmd.sources, "sources", false, "break the usage down per source")
+	fs.BoolVar(&cmd.runs, "runs", false, "break the usage down per run")
+	fs.BoolVar(&cmd.clear, "clear", false, "remove the network history")
+}
+
+type networkCommand struct {
+	since   string
+	sources bool
+	runs    bool
+	clear   bool
+}
+
+func (cmd *networkCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.New("dep network takes no arguments")
+	}
+	if cmd.clear {
+		return ctx.ClearNetworkHistory()
+	}
+
+	var since time.Time
+	if cmd.since != "" {
+		var err error
+		if since, err = parseSince(cmd.since); err != nil {
+			return err
+		}
+	}
+
+	runs, err := ctx.NetworkHistory(since)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		ctx.Out.Println("No network usage recorded.")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	sources := make(map[string]gps.NetworkUsage)
+	for _, run := range runs {
+		addNetworkUsage(sources, run.Sources)
+	}
+	fmt.Fprintf(&buf, "%d runs since %s\n\n", len(runs), runs[0].Time.Format("2006-01-02 15:04"))
+	writeNetworkTable(&buf, "HOST", networkByHost(sources))
+	if cmd.sources {
+		buf.WriteString("\n")
+		writeNetworkTable(&buf, "SOURCE", sources)
+	}
+	if cmd.runs {
+		buf.WriteString("\n")
+		writeNetworkRuns(&buf, runs)
+	}
+	ctx.Out.Print(buf.String())
+
+	return nil
+}
+
+// recordNetworkUsage adds the network usage of sm to the network history, as
+// the run of the given command on project p.
+func recordNetworkUsage(ctx *dep.Ctx, command string, p *dep.Project, sm *gps.SourceMgr) {
+	run := dep.NetworkRun{
+		Time:    time.Now(),
+		Command: command,
+		Project: string(p.ImportRoot),
+		Sources: sm.Stats().Network,
+	}
+	if err := ctx.RecordNetworkUsage(run); err != nil {
+		ctx.Err.Printf("Warning: %s", err)
+	}
+}
+
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid -since value %q: expected YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	return t, nil
+}
+
+// addNetworkUsage adds the usage of from to that of to, per URL.
+func addNetworkUsage(to, from map[string]gps.NetworkUsage) {
+	for u, nu := range from {
+		sum := to[u]
+		sum.Contacts += nu.Contacts
+		sum.Bytes += nu.Bytes
+		to[u] = sum
+	}
+}
+
+// networkByHost sums up the usage of sources per host.
+func networkByHost(sources map[string]gps.NetworkUsage) map[string]gps.NetworkUsage {
+	hosts := make(map[string]gps.NetworkUsage)
+	for u, nu := range sources {
+		host := sourceHost(u)
+		sum := hosts[host]
+		sum.Contacts += nu.Contacts
+		sum.Bytes += nu.Bytes
+		hosts[host] = sum
+	}
+	return hosts
+}
+
+// sourceHost returns the host of a source URL, which may be scp-like, as in
+// git@github.com:foo/bar.
+func sourceHost(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Host != "" {
+		if host, _, err := net.SplitHostPort(u.Host); err == nil {
+			return host
+		}
+		return u.Host
+	}
+	if i := strings.Index(source, ":"); i > 0 {
+		host := source[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return host
+	}
+	return source
+}
+
+func writeNetworkTable(w io.Writer, title string, usage map[string]gps.NetworkUsage) {
+	names := make([]string, 0, len(usage))
+	for name := range usage {
+		names = append(names, name)
+	}
+	sort.Sort(byNetworkUsage{names, usage})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCONTACTS\tDOWNLOADED\t\n", title)
+	for _, name := range names {
+		nu := usage[name]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", name, nu.Contacts, formatBytes(nu.Bytes))
+	}
+	tw.Flush()
+}
+
+func writeNetworkRuns(w io.Writer, runs []dep.NetworkRun) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCOMMAND\tPROJECT\tCONTACTS\tDOWNLOADED\t")
+	for _, run := range runs {
+		var total gps.NetworkUsage
+		for _, nu := range run.Sources {
+			total.Contacts += nu.Contacts
+			total.Bytes += nu.Bytes
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t\n", run.Time.Format("2006-01-02 15:04"), run.Command, run.Project, total.Contacts, formatBytes(total.Bytes))
+	}
+	tw.Flush()
+}
+
+// byNetworkUsage sorts names by decreasing usage, the bytes downloaded first,
+// then the number of contacts.
+type byNetworkUsage struct {
+	names []string
+	usage map[string]gps.NetworkUsage
+}
+
+func (s byNetworkUsage) Len() int      { return len(s.names) }
+func (s byNetworkUsage) Swap(i, j int) { s.names[i], s.names[j] = s.names[j], s.names[i] }
+func (s byNetworkUsage) Less(i, j int) bool {
+	ui, uj := s.usage[s.names[i]], s.usage[s.names[j]]
+	if ui.Bytes != uj.Bytes {
+		return ui.Bytes > uj.Bytes
+	}
+	if ui.Contacts != uj.Contacts {
+		return ui.Contacts > uj.Contacts
+	}
+	return s.names[i] < s.names[j]
+}
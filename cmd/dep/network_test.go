@@ -0,0 +1,55 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestSourceHost(t *testing.T) {
+	cases := map[string]string{
+		"https://github.com/foo/bar":        "github.com",
+		"ssh://git@github.com:22/foo/bar":   "github.com",
+		"git@bitbucket.org:foo/bar":         "bitbucket.org",
+		"http://cache.example.com:8080":     "cache.example.com",
+		"https://golang.org/x/net?go-get=1": "golang.org",
+		"example.com/foo":                   "example.com/foo",
+	}
+	for source, want := range cases {
+		if got := sourceHost(source); got != want {
+			t.Errorf("sourceHost(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestNetworkByHost(t *testing.T) {
+	sources := map[string]gps.NetworkUsage{
+		"https://github.com/foo/bar":   {Contacts: 2, Bytes: 2048},
+		"git@github.com:foo/baz":       {Contacts: 1, Bytes: 1024},
+		"https://golang.org/x/net":     {Contacts: 1},
+		"https://go.googlesource.com/": {Contacts: 3, Bytes: 4096},
+	}
+	want := map[string]gps.NetworkUsage{
+		"github.com":          {Contacts: 3, Bytes: 3072},
+		"golang.org":          {Contacts: 1},
+		"go.googlesource.com": {Contacts: 3, Bytes: 4096},
+	}
+	hosts := networkByHost(sources)
+	if !reflect.DeepEqual(hosts, want) {
+		t.Fatalf("unexpected usage per host:\n\t(GOT): %v\n\t(WNT): %v", hosts, want)
+	}
+
+	var buf bytes.Buffer
+	writeNetworkTable(&buf, "HOST", hosts)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "go.googlesource.com") || !strings.HasPrefix(lines[3], "golang.org") {
+		t.Errorf("expected the hosts sorted by decreasing usage, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const outdatedShortHelp = `Report dependencies for which newer versions are available`
+const outdatedLongHelp = `
+Print each locked dependency for which a newer version than the one recorded
+in Gopkg.lock is allowed by the constraints in Gopkg.toml, or for which a newer
+release is out, even if the constraints don't allow it.
+
+  PROJECT   Import path
+  CURRENT   Version chosen, from the lock
+  RELEASED  Release date of the current version
+  LATEST    Newest version allowed by the manifest constraint
+  RELEASED  Release date of the newest allowed version
+  NEWEST    Newest release, whatever the constraints
+
+dep ensure -update picks the LATEST versions. Moving to the NEWEST ones takes
+changing the constraints first.
+
+dep outdated exits with a non-zero status when any dependency is reported, so
+that scripts can tell when updates are available.
+
+Release dates are taken from annotated tags where the source provides them,
+and from the date of the tagged commit otherwise. The messages of annotated
+tags for newer versions are printed beneath the table.
+
+With -changelog, the release notes between the current and latest versions
+are printed for each project as well. They are taken from the entries added to
+the project's changelog file (CHANGELOG.md or similar) if it has one, and from
+the messages of the annotated tags released in between otherwise.
+`
+
+const outdatedDateFormat = "2006-01-02"
+
+type outdatedCommand struct {
+	json      bool
+	changelog bool
+}
+
+func (cmd *outdatedCommand) Name() string      { return "outdated" }
+func (cmd *outdatedCommand) Args() string      { return "" }
+func (cmd *outdatedCommand) ShortHelp() string { return outdatedShortHelp }
+func (cmd *outdatedCommand) LongHelp() string  { return outdatedLongHelp }
+func (cmd *outdatedCommand) Hidden() bool      { return false }
+
+func (cmd *outdatedCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+	fs.BoolVar(&cmd.changelog, "changelog", false, "include the release notes of newer versions")
+}
+
+func (cmd *outdatedCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep outdated takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to compare against", dep.LockName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	projects, err := collectOutdated(ctx, p, sm, cmd.changelog)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if cmd.json {
+		if err := writeOutdatedJSON(&buf, projects); err != nil {
+			return err
+		}
+	} else {
+		writeOutdatedTable(&buf, projects)
+	}
+	ctx.Out.Print(buf.String())
+
+	if len(projects) > 0 {
+		return errOutdated(len(projects))
+	}
+	return nil
+}
+
+// OutdatedStatus describes a locked project for which a newer version is
+// allowed by the manifest.
+type OutdatedStatus struct {
+	ProjectRoot string
+	Current     gps.Version
+	CurrentInfo gps.VersionInfo
+	Latest      gps.Version
+	LatestInfo  gps.VersionInfo
+	// Newest is the newest release of the project, whether the manifest
+	// allows it or not, or nil if it has no releases.
+	Newest    gps.Version
+	Changelog string
+}
+
+// errOutdated is returned by dep outdated when updates are available.
+type errOutdated int
+
+func (e errOutdated) Error() string {
+	if e == 1 {
+		return "1 dependency can be updated"
+	}
+	return fmt.Sprintf("%d dependencies can be updated", int(e))
+}
+
+// collectOutdated returns the locked projects of p for which a newer version
+// than the locked one matches the manifest constraint, or for which a newer
+// release is out, sorted by project root.
+// If changelog is true, the release notes between the two versions are
+// collected as well.
+func collectOutdated(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, changelog bool) ([]OutdatedStatus, error) {
+	lp := p.Lock.Projects()
+	sort.Sort(dep.SortedLockedProjects(lp))
+
+	var projects []OutdatedStatus
+	for _, proj := range lp {
+		id := proj.Ident()
+		current := proj.Version()
+
+		// Bare revisions carry no notion of "newer", so there's nothing to
+		// report for them.
+		if _, isRev := current.(gps.Revision); isRev {
+			continue
+		}
+
+		c := gps.Any()
+		if pp, has := p.Manifest.Ovr[id.ProjectRoot]; has && pp.Constraint != nil {
+			c = pp.Constraint
+		} else if pp, has := p.Manifest.Constraints[id.ProjectRoot]; has && pp.Constraint != nil {
+			c = pp.Constraint
+		}
+
+		latest, err := latestMatchingVersion(sm, id, c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list versions for %s", id)
+		}
+		newest, err := newestRelease(sm, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list versions for %s", id)
+		}
+
+		st := OutdatedStatus{
+			ProjectRoot: string(id.ProjectRoot),
+			Current:     current,
+			Latest:      current,
+		}
+		updatable := latest != nil && latest.Revision() != revisionOf(current)
+		if updatable {
+			st.Latest = latest
+		} else if !newerRelease(newest, current) {
+			continue
+		}
+		if newest != nil {
+			st.Newest = newest
+		}
+
+		// Missing release information should not prevent the report from
+		// being produced; it just leaves the corresponding columns empty.
+		if st.CurrentInfo, err = sm.VersionInfo(id, current); err != nil && ctx.Verbose {
+			ctx.Err.Printf("Unable to retrieve release information for %s@%s: %s", id, formatVersion(current), err)
+		}
+		if st.LatestInfo, err = sm.VersionInfo(id, st.Latest); err != nil && ctx.Verbose {
+			ctx.Err.Printf("Unable to retrieve release information for %s@%s: %s", id, formatVersion(st.Latest), err)
+		}
+		if changelog && updatable {
+			if st.Changelog, err = collectChangelog(sm, id, current, st.Latest); err != nil {
+				ctx.Err.Printf("Unable to retrieve release notes for %s: %s", id, err)
+			}
+		}
+
+		projects = append(projects, st)
+	}
+
+	return projects, nil
+}
+
+// newestRelease returns the newest version of the project which is a semver
+// release, rather than a pre-release, or nil if there is none.
+func newestRelease(sm gps.SourceManager, id gps.ProjectIdentifier) (gps.PairedVersion, error) {
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	gps.SortPairedForUpgrade(vl)
+	for _, v := range vl {
+		if v.Type() != gps.IsSemver {
+			continue
+		}
+		if sv, err := semver.NewVersion(v.String()); err == nil && sv.Prerelease() == "" {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// newerRelease tells whether newest is a newer release than current, which
+// only semver versions can be compared with.
+func newerRelease(newest gps.PairedVersion, current gps.Version) bool {
+	if newest == nil || current.Type() != gps.IsSemver {
+		return false
+	}
+	nv, err := semver.NewVersion(newest.String())
+	if err != nil {
+		return false
+	}
+	cv, err := semver.NewVersion(current.String())
+	return err == nil && nv.GreaterThan(cv)
+}
+
+// revisionOf returns the underlying revision of a paired version or revision,
+// or the empty string for an unpaired version.
+func revisionOf(v gps.Version) gps.Revision {
+	switch tv := v.(type) {
+	case gps.Revision:
+		return tv
+	case gps.PairedVersion:
+		return tv.Revision()
+	}
+	return ""
+}
+
+func formatReleaseDate(vi gps.VersionInfo) string {
+	d := vi.ReleaseDate()
+	if d.IsZero() {
+		return ""
+	}
+	return d.UTC().Format(outdatedDateFormat)
+}
+
+func writeOutdatedTable(w io.Writer, projects []OutdatedStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "PROJECT\tCURRENT\tRELEASED\tLATEST\tRELEASED\tNEWEST\t\n")
+	for _, st := range projects {
+		fmt.Fprintf(tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			st.ProjectRoot,
+			formatVersion(st.Current),
+			formatReleaseDate(st.CurrentInfo),
+			formatVersion(st.Latest),
+			formatReleaseDate(st.LatestInfo),
+			formatVersion(st.Newest),
+		)
+	}
+	tw.Flush()
+
+	for _, st := range projects {
+		annotation := strings.TrimSpace(st.LatestInfo.Annotation)
+		if annotation == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s:\n", st.ProjectRoot, formatVersion(st.Latest))
+		for _, line := range strings.Split(annotation, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+
+	for _, st := range projects {
+		if st.Changelog == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s changes since %s:\n", st.ProjectRoot, formatVersion(st.Current))
+		for _, line := range strings.Split(st.Changelog, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+type rawOutdated struct {
+	ProjectRoot        string
+	Current            string
+	CurrentRevision    gps.Revision
+	CurrentReleaseDate *time.Time `json:",omitempty"`
+	Latest             string
+	LatestRevision     gps.Revision
+	LatestReleaseDate  *time.Time   `json:",omitempty"`
+	LatestAnnotation   string       `json:",omitempty"`
+	Newest             string       `json:",omitempty"`
+	NewestRevision     gps.Revision `json:",omitempty"`
+	Changelog          string       `json:",omitempty"`
+}
+
+func (st OutdatedStatus) marshalJSON() rawOutdated {
+	raw := rawOutdated{
+		ProjectRoot:      st.ProjectRoot,
+		Current:          formatVersion(st.Current),
+		CurrentRevision:  revisionOf(st.Current),
+		Latest:           formatVersion(st.Latest),
+		LatestRevision:   revisionOf(st.Latest),
+		LatestAnnotation: strings.TrimSpace(st.LatestInfo.Annotation),
+		Newest:           formatVersion(st.Newest),
+		NewestRevision:   revisionOf(st.Newest),
+		Changelog:        st.Changelog,
+	}
+	if d := st.CurrentInfo.ReleaseDate(); !d.IsZero() {
+		raw.CurrentReleaseDate = &d
+	}
+	if d := st.LatestInfo.ReleaseDate(); !d.IsZero() {
+		raw.LatestReleaseDate = &d
+	}
+	return raw
+}
+
+func writeOutdatedJSON(w io.Writer, projects []OutdatedStatus) error {
+	raw := make([]rawOutdated, 0, len(projects))
+	for _, st := range projects {
+		raw = append(raw, st.marshalJSON())
+	}
+
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal outdated report")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
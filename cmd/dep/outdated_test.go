@@ -0,0 +1,123 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestOutdatedOutput(t *testing.T) {
+	projects := []OutdatedStatus{
+		{
+			ProjectRoot: "github.com/foo/bar",
+			Current:     gps.NewVersion("v1.0.0").Pair("abc123"),
+			CurrentInfo: gps.VersionInfo{
+				Date: time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC),
+			},
+			Latest: gps.NewVersion("v1.2.0").Pair("def456"),
+			LatestInfo: gps.VersionInfo{
+				Date:       time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC),
+				TagDate:    time.Date(2017, 5, 2, 12, 0, 0, 0, time.UTC),
+				Annotation: "Release v1.2.0\n\nFaster frobnication.\n",
+			},
+			Newest:    gps.NewVersion("v2.0.0").Pair("fed789"),
+			Changelog: "## v1.2.0\n\n- Faster frobnication",
+		},
+		{
+			ProjectRoot: "github.com/foo/baz",
+			Current:     gps.NewBranch("master").Pair("aaaaaaa"),
+			Latest:      gps.NewBranch("master").Pair("bbbbbbb"),
+		},
+	}
+
+	var buf bytes.Buffer
+	writeOutdatedTable(&buf, projects)
+	table := buf.String()
+
+	for _, want := range []string{
+		"PROJECT             CURRENT        RELEASED    LATEST         RELEASED    NEWEST",
+		"github.com/foo/bar  v1.0.0         2016-03-01  v1.2.0         2017-05-02  v2.0.0",
+		"github.com/foo/baz  branch master              branch master",
+		"github.com/foo/bar v1.2.0:\n    Release v1.2.0\n    \n    Faster frobnication.\n",
+		"github.com/foo/bar changes since v1.0.0:\n    ## v1.2.0\n    \n    - Faster frobnication\n",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("expected table output to contain %q, got:\n%s", want, table)
+		}
+	}
+	if strings.Contains(table, "github.com/foo/baz branch master:") || strings.Contains(table, "github.com/foo/baz changes") {
+		t.Errorf("expected no annotation section for github.com/foo/baz, got:\n%s", table)
+	}
+
+	buf.Reset()
+	if err := writeOutdatedJSON(&buf, projects); err != nil {
+		t.Fatal(err)
+	}
+	js := buf.String()
+
+	for _, want := range []string{
+		`"CurrentRevision": "abc123"`,
+		`"CurrentReleaseDate": "2016-03-01T12:00:00Z"`,
+		`"LatestReleaseDate": "2017-05-02T12:00:00Z"`,
+		`"LatestAnnotation": "Release v1.2.0\n\nFaster frobnication."`,
+		`"Latest": "branch master"`,
+		`"Changelog": "## v1.2.0\n\n- Faster frobnication"`,
+		`"Newest": "v2.0.0"`,
+		`"NewestRevision": "fed789"`,
+	} {
+		if !strings.Contains(js, want) {
+			t.Errorf("expected JSON output to contain %s, got:\n%s", want, js)
+		}
+	}
+	if strings.Count(js, "ReleaseDate") != 2 {
+		t.Errorf("expected unknown release dates to be omitted, got:\n%s", js)
+	}
+}
+
+func TestNewestRelease(t *testing.T) {
+	sm := gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/foo/bar": {
+			gps.NewVersion("v1.0.0").Pair("aaaaaaa"),
+			gps.NewVersion("v2.1.0-rc1").Pair("bbbbbbb"),
+			gps.NewVersion("v2.0.0").Pair("ccccccc"),
+			gps.NewBranch("master").Pair("ddddddd"),
+		},
+		"github.com/foo/baz": {
+			gps.NewBranch("master").Pair("eeeeeee"),
+		},
+	}}
+
+	newest, err := newestRelease(sm, gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newest == nil || newest.String() != "v2.0.0" {
+		t.Fatalf("expected v2.0.0 to be the newest release, got %v", newest)
+	}
+	if !newerRelease(newest, gps.NewVersion("v1.0.0").Pair("aaaaaaa")) {
+		t.Error("expected v2.0.0 to be newer than v1.0.0")
+	}
+	for _, current := range []gps.Version{
+		gps.NewVersion("v2.0.0").Pair("ccccccc"),
+		gps.NewBranch("master").Pair("ddddddd"),
+	} {
+		if newerRelease(newest, current) {
+			t.Errorf("expected v2.0.0 not to be reported as newer than %s", current)
+		}
+	}
+
+	if newest, err = newestRelease(sm, gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}); err != nil || newest != nil {
+		t.Errorf("expected no release for a project without tags, got %v, %v", newest, err)
+	}
+
+	if got := errOutdated(2).Error(); got != "2 dependencies can be updated" {
+		t.Errorf("unexpected error message %q", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	fb "github.com/golang/dep/internal/feedback"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// importerPluginPrefix is the prefix of the names of the executables which
+// import the configuration of other dependency managers. An importer plugin
+// named dep-importer-foo is run as:
+//
+//	dep-importer-foo detect <dir>
+//
+// to tell, by exiting with status 0, whether the project in dir has the
+// configuration it converts, and then as:
+//
+//	dep-importer-foo import <dir> <project root>
+//
+// to print the dependencies of the project to its standard output, as the
+// JSON form of a pluginConfig.
+const importerPluginPrefix = "dep-importer-"
+
+// pluginConfig is what importer plugins print: the dependencies of a project.
+type pluginConfig struct {
+	Projects []pluginProject `json:"projects"`
+}
+
+// pluginProject is a dependency as described by an importer plugin. Only Name
+// is required. Version may be a semver range, or a tag; Revision, if set, is
+// locked.
+type pluginProject struct {
+	Name     string `json:"name"`
+	Source   string `json:"source,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+type pluginImporter struct {
+	name string
+	path string // the path of the executable
+	cfg  pluginConfig
+
+	logger  *log.Logger
+	verbose bool
+	sm      gps.SourceManager
+	report  *migrationReport
+}
+
+// findImporterPlugins returns the paths of the importer plugins in the
+// directories of the given PATH, keyed by name. As with commands, the first
+// directory holding a plugin of a given name wins.
+func findImporterPlugins(path string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, importerPluginPrefix+"*"))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			name := strings.TrimPrefix(filepath.Base(m), importerPluginPrefix)
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = name[:len(name)-len(".exe")]
+			} else if fi.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			if _, has := plugins[name]; name != "" && !has {
+				plugins[name] = m
+			}
+		}
+	}
+	return plugins
+}
+
+// newPluginImporters returns importers for the given plugins, sorted by name.
+func newPluginImporters(plugins map[string]string, logger *log.Logger, verbose bool, sm gps.SourceManager) []importer {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	importers := make([]importer, len(names))
+	for i, name := range names {
+		importers[i] = &pluginImporter{
+			name:    name,
+			path:    plugins[name],
+			logger:  logger,
+			verbose: verbose,
+			sm:      sm,
+		}
+	}
+	return importers
+}
+
+func (p *pluginImporter) Name() string { return p.name }
+
+func (p *pluginImporter) setReport(r *migrationReport) { p.report = r }
+
+func (p *pluginImporter) HasDepMetadata(dir string) bool {
+	cmd := exec.Command(p.path, "detect", dir)
+	cmd.Dir = dir
+	return cmd.Run() == nil
+}
+
+func (p *pluginImporter) Import(dir string, pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	p.logger.Printf("Detected %s configuration through %s...", p.name, p.path)
+
+	if err := p.load(dir, pr); err != nil {
+		return nil, nil, err
+	}
+
+	return p.convert(pr)
+}
+
+func (p *pluginImporter) load(dir string, pr gps.ProjectRoot) error {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.path, "import", dir, string(pr))
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	msg := strings.TrimSpace(stderr.String())
+	if err != nil {
+		return errors.Wrapf(err, "%s failed: %s", p.path, msg)
+	}
+	if p.verbose && msg != "" {
+		p.logger.Println(msg)
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), &p.cfg); err != nil {
+		return errors.Wrapf(err, "unable to parse the output of %s", p.path)
+	}
+	return nil
+}
+
+func (p *pluginImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error) {
+	manifest := &dep.Manifest{
+		Constraints: make(gps.ProjectConstraints),
+	}
+	lock := &dep.Lock{}
+
+	for _, pkg := range p.cfg.Projects {
+		if pkg.Name == "" {
+			return nil, nil, errors.Errorf("invalid %s configuration, the name of a project is required", p.name)
+		}
+		if pkg.Version != "" && pkg.Branch != "" {
+			return nil, nil, errors.Errorf("invalid %s configuration, %s has both a version and a branch", p.name, pkg.Name)
+		}
+
+		root, err := p.sm.DeduceProjectRoot(pkg.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, has := manifest.Constraints[root]; has || projectExistsInLock(lock, root) {
+			continue
+		}
+		pi := gps.ProjectIdentifier{ProjectRoot: root, Source: pkg.Source}
+
+		var c gps.Constraint
+		var from, unusable string
+		switch {
+		case pkg.Branch != "":
+			c = gps.NewBranch(pkg.Branch)
+			from = "branch " + pkg.Branch
+		case pkg.Version != "":
+			from = pkg.Version
+			if c, err = p.sm.InferConstraint(pkg.Version, pi); err != nil {
+				p.logger.Printf("Unable to use the version %s of %s: %s", pkg.Version, root, err)
+				c = nil
+				unusable = err.Error()
+			}
+		}
+		if c != nil || pi.Source != "" {
+			if c == nil {
+				c = gps.Any()
+			}
+			pc := gps.ProjectConstraint{Ident: pi, Constraint: c}
+			manifest.Constraints[root] = gps.ProjectProperties{Source: pi.Source, Constraint: c}
+			fb.NewConstraintFeedback(pc, fb.DepTypeImported).LogFeedback(p.logger)
+			if unusable != "" {
+				p.report.approximated(pc, from, unusable)
+			} else {
+				p.report.translated(pc, from)
+			}
+		} else if unusable != "" {
+			p.report.dropped(root, from, unusable)
+		}
+
+		if pkg.Revision != "" {
+			version, err := lookupVersionForLockedProject(pi, c, gps.Revision(pkg.Revision), p.sm)
+			if err != nil {
+				p.logger.Println(err.Error())
+			}
+			p.report.locked(root, pkg.Revision, version, err)
+			lp := gps.NewLockedProject(pi, version, nil)
+			lock.P = append(lock.P, lp)
+			fb.NewLockedProjectFeedback(lp, fb.DepTypeImported).LogFeedback(p.logger)
+		}
+	}
+
+	return manifest, lock, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+// pluginTestScript is an importer plugin converting the projects listed in
+// custom.json, which are already in its JSON form.
+const pluginTestScript = `#!/bin/sh
+case "$1" in
+detect) test -f "$2/custom.json" ;;
+import) cat "$2/custom.json" ;;
+*) exit 2 ;;
+esac
+`
+
+type pluginTestSM struct {
+	gomodTestSM
+}
+
+func (sm pluginTestSM) InferConstraint(s string, pi gps.ProjectIdentifier) (gps.Constraint, error) {
+	return gps.NewSemverConstraintIC(s)
+}
+
+func TestFindImporterPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("importer plugins are .exe files on windows")
+	}
+
+	dir1, err := ioutil.TempDir("", "dep-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "dep-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+
+	for path, mode := range map[string]os.FileMode{
+		filepath.Join(dir1, "dep-importer-custom"):  0755,
+		filepath.Join(dir1, "dep-importer-noexec"):  0644,
+		filepath.Join(dir2, "dep-importer-custom"):  0755,
+		filepath.Join(dir2, "dep-importer-another"): 0755,
+		filepath.Join(dir2, "dep-other"):            0755,
+	} {
+		if err := ioutil.WriteFile(path, []byte(pluginTestScript), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := findImporterPlugins(dir1 + string(filepath.ListSeparator) + dir2)
+	want := map[string]string{
+		"custom":  filepath.Join(dir1, "dep-importer-custom"),
+		"another": filepath.Join(dir2, "dep-importer-another"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected plugins %v, got %v", want, got)
+	}
+	for name, path := range want {
+		if got[name] != path {
+			t.Errorf("expected the %s plugin at %s, got %s", name, path, got[name])
+		}
+	}
+}
+
+func TestPluginImporter_Import(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+
+	bin, err := ioutil.TempDir("", "dep-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	if err = ioutil.WriteFile(filepath.Join(bin, "dep-importer-custom"), []byte(pluginTestScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	projectRoot, err := ioutil.TempDir("", "dep-project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(projectRoot)
+
+	sm := pluginTestSM{gomodTestSM{versions: map[gps.ProjectRoot][]gps.PairedVersion{
+		"github.com/sdboyer/deptest": {
+			gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"),
+		},
+	}}}
+	importers := newPluginImporters(findImporterPlugins(bin), discardLogger, true, sm)
+	if len(importers) != 1 || importers[0].Name() != "custom" {
+		t.Fatalf("expected the custom importer, got %v", importers)
+	}
+	i := importers[0]
+	if i.HasDepMetadata(projectRoot) {
+		t.Fatal("expected no custom configuration to be detected")
+	}
+
+	cfg := `{"projects": [
+		{"name": "github.com/sdboyer/deptest/subpkg", "version": "^1.0.0", "revision": "ff2948a2ac8f538c4ecd55962e919d1e13e74baf"},
+		{"name": "github.com/sdboyer/deptestdos", "branch": "v2", "source": "https://github.com/carolynvs/deptestdos"},
+		{"name": "golang.org/x/text", "revision": "14c0d48ead0cd47e3a28f8d4a3d02e8a1ee1e4b3"}
+	]}`
+	if err = ioutil.WriteFile(filepath.Join(projectRoot, "custom.json"), []byte(cfg), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if !i.HasDepMetadata(projectRoot) {
+		t.Fatal("expected the custom configuration to be detected")
+	}
+	m, l, err := i.Import(projectRoot, testProjectRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantConstraints := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest":    "^1.0.0",
+		"github.com/sdboyer/deptestdos": "v2",
+	}
+	if len(m.Constraints) != len(wantConstraints) {
+		t.Fatalf("expected %d constraints, got %v", len(wantConstraints), m.Constraints)
+	}
+	for pr, want := range wantConstraints {
+		if got := m.Constraints[pr].Constraint.String(); got != want {
+			t.Errorf("expected %s to be constrained to %s, got %s", pr, want, got)
+		}
+	}
+	if src := m.Constraints["github.com/sdboyer/deptestdos"].Source; src != "https://github.com/carolynvs/deptestdos" {
+		t.Errorf("unexpected source of github.com/sdboyer/deptestdos: %q", src)
+	}
+
+	wantLock := map[gps.ProjectRoot]string{
+		"github.com/sdboyer/deptest": "v1.0.0",
+		"golang.org/x/text":          "14c0d48ead0cd47e3a28f8d4a3d02e8a1ee1e4b3",
+	}
+	if len(l.P) != len(wantLock) {
+		t.Fatalf("expected %d locked projects, got %v", len(wantLock), l.P)
+	}
+	for _, lp := range l.P {
+		if want := wantLock[lp.Ident().ProjectRoot]; lp.Version().String() != want {
+			t.Errorf("expected %s to be locked to %s, got %s", lp.Ident().ProjectRoot, want, lp.Version())
+		}
+	}
+}
+
+func TestPluginImporter_Convert_Invalid(t *testing.T) {
+	cases := map[string]pluginProject{
+		"no name":            {Version: "^1.0.0"},
+		"version and branch": {Name: "github.com/sdboyer/deptest", Version: "^1.0.0", Branch: "master"},
+	}
+	for name, pkg := range cases {
+		p := &pluginImporter{name: "custom", logger: discardLogger, sm: pluginTestSM{}}
+		p.cfg.Projects = []pluginProject{pkg}
+		if _, _, err := p.convert(testProjectRoot); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+const (
+	// progressInterval is how often the status line is redrawn on a
+	// terminal, and slowCall how long a call runs before it's logged
+	// otherwise.
+	progressInterval = 200 * time.Millisecond
+	slowCall         = 5 * time.Second
+
+	// statusWidth is the width the status line is cut to, so that it fits
+	// on a line of the smallest terminals.
+	statusWidth = 79
+)
+
+// progressSM is the part of a SourceMgr progress reports on.
+type progressSM interface {
+	Running() []gps.RunningCall
+	Stats() gps.SourceMgrStats
+}
+
+// progress reports on what a SourceMgr does while a command runs. On the
+// terminal of ctx.Progress, it redraws a status line with the call running
+// for the longest and the counts of the calls done. Otherwise, it logs the
+// calls running for long to ctx.Err, once each, so as not to flood logs.
+type progress struct {
+	ctx   *dep.Ctx
+	sm    progressSM
+	start time.Time
+
+	logged map[string]bool // the calls logged already
+	width  int             // of the status line drawn last
+
+	stopc chan struct{}
+	done  chan struct{}
+}
+
+// startProgress starts reporting on what sm does.
+func startProgress(ctx *dep.Ctx, sm progressSM) *progress {
+	p := &progress{
+		ctx:    ctx,
+		sm:     sm,
+		start:  time.Now(),
+		logged: make(map[string]bool),
+		stopc:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *progress) run() {
+	defer close(p.done)
+	interval := progressInterval
+	if p.ctx.Progress == nil {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stopc:
+			p.draw("")
+			return
+		case now := <-t.C:
+			p.update(now)
+		}
+	}
+}
+
+// stop stops reporting, erasing the status line, and logs a summary of the
+// network operations performed, if any and summary is set.
+func (p *progress) stop(summary bool) {
+	close(p.stopc)
+	<-p.done
+	if st := p.sm.Stats(); summary && len(st.Network) > 0 {
+		p.ctx.Err.Println(networkSummary(st, time.Since(p.start)))
+	}
+}
+
+func (p *progress) update(now time.Time) {
+	calls := p.sm.Running()
+	if p.ctx.Progress != nil {
+		p.draw(statusLine(calls, p.sm.Stats(), now.Sub(p.start)))
+		return
+	}
+	for _, c := range calls {
+		key := c.Kind + " " + c.Name
+		if c.Kind == "analyze" || p.logged[key] || now.Sub(c.Started) < slowCall {
+			continue
+		}
+		p.logged[key] = true
+		p.ctx.Err.Printf("%s %s, for %s already", describeCall(c.Kind), c.Name, now.Sub(c.Started)/time.Second*time.Second)
+	}
+}
+
+// draw replaces the status line on the terminal with line.
+func (p *progress) draw(line string) {
+	if p.ctx.Progress == nil {
+		return
+	}
+	pad := ""
+	if n := p.width - len(line); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+	fmt.Fprintf(p.ctx.Progress, "\r%s%s\r%s", line, pad, line)
+	p.width = len(line)
+}
+
+// statusLine returns the status line telling what calls runs, after elapsed,
+// with the counts of the calls done according to st.
+func statusLine(calls []gps.RunningCall, st gps.SourceMgrStats, elapsed time.Duration) string {
+	head := fmt.Sprintf("[%s] ", elapsed/time.Second*time.Second)
+	tail := fmt.Sprintf(" | %d cloned, %d listed, %d exported", st.Fetched, st.ListVersions.Count, st.Export.Count)
+	if len(calls) == 0 {
+		return head + "solving" + tail
+	}
+
+	// The calls of the network are the ones worth telling about.
+	c := calls[0]
+	for _, rc := range calls {
+		if rc.Kind != "analyze" {
+			c = rc
+			break
+		}
+	}
+	head += describeCall(c.Kind) + " "
+	if len(calls) > 1 {
+		tail = fmt.Sprintf(" (+%d more)", len(calls)-1) + tail
+	}
+	// Cut the name rather than what's done to it.
+	name := c.Name
+	if max := statusWidth - len(head) - len(tail); len(name) > max {
+		if max > 3 {
+			name = name[:max-3] + "..."
+		} else {
+			name = ""
+		}
+	}
+	return head + name + tail
+}
+
+// describeCall describes what the calls of the given kind do.
+func describeCall(kind string) string {
+	switch kind {
+	case "deduce":
+		return "deducing the source of"
+	case "check":
+		return "checking"
+	case "clone":
+		return "cloning"
+	case "fetch":
+		return "fetching"
+	case "list":
+		return "listing the versions of"
+	case "download":
+		return "downloading"
+	case "export":
+		return "exporting"
+	}
+	return "analyzing"
+}
+
+// networkSummary sums up the network operations of st, performed in
+// elapsed.
+func networkSummary(st gps.SourceMgrStats, elapsed time.Duration) string {
+	var total gps.NetworkUsage
+	for _, nu := range st.Network {
+		total.Contacts += nu.Contacts
+		total.Bytes += nu.Bytes
+	}
+	return fmt.Sprintf("Contacted %d URLs %d times in %s: cloned %d sources, listed versions %d times, downloaded %s",
+		len(st.Network), total.Contacts, elapsed/time.Second*time.Second, st.Fetched, st.ListVersions.Count, formatBytes(total.Bytes))
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer, env []string) bool {
+	f, ok := w.(*os.File)
+	if !ok || getEnv(env, "TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+type fakeProgressSM struct {
+	calls []gps.RunningCall
+	stats gps.SourceMgrStats
+}
+
+func (sm *fakeProgressSM) Running() []gps.RunningCall { return sm.calls }
+func (sm *fakeProgressSM) Stats() gps.SourceMgrStats  { return sm.stats }
+
+func TestStatusLine(t *testing.T) {
+	now := time.Now()
+	st := gps.SourceMgrStats{Fetched: 3, ListVersions: gps.CallStats{Count: 12}, Export: gps.CallStats{Count: 5}}
+
+	cases := []struct {
+		name  string
+		calls []gps.RunningCall
+		want  string
+	}{
+		{"nothing running", nil, "[12s] solving | 3 cloned, 12 listed, 5 exported"},
+		{
+			"network calls first",
+			[]gps.RunningCall{
+				{Kind: "analyze", Name: "github.com/foo/bar", Started: now.Add(-time.Minute)},
+				{Kind: "clone", Name: "https://github.com/foo/baz", Started: now.Add(-time.Second)},
+				{Kind: "list", Name: "https://github.com/foo/qux", Started: now},
+			},
+			"[12s] cloning https://github.com... (+2 more) | 3 cloned, 12 listed, 5 exported",
+		},
+		{
+			"cut to fit",
+			[]gps.RunningCall{{Kind: "list", Name: "https://example.com/" + strings.Repeat("a", 80)}},
+			"[12s] listing the versions of https://exam... | 3 cloned, 12 listed, 5 exported",
+		},
+	}
+	for _, c := range cases {
+		got := statusLine(c.calls, st, 12500*time.Millisecond)
+		if got != c.want {
+			t.Errorf("%s: unexpected status line:\n\t(GOT): %q\n\t(WNT): %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestProgressLogsSlowCalls(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := &dep.Ctx{Err: log.New(&buf, "", 0)}
+	now := time.Now()
+	sm := &fakeProgressSM{calls: []gps.RunningCall{
+		{Kind: "clone", Name: "https://github.com/foo/bar", Started: now.Add(-7 * time.Second)},
+		{Kind: "list", Name: "https://github.com/foo/baz", Started: now.Add(-time.Second)},
+		{Kind: "analyze", Name: "github.com/foo/qux", Started: now.Add(-time.Minute)},
+	}}
+	p := &progress{ctx: ctx, sm: sm, start: now, logged: make(map[string]bool)}
+
+	p.update(now)
+	p.update(now.Add(time.Second))
+	want := "cloning https://github.com/foo/bar, for 7s already\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected log:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
+
+func TestProgressDraw(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{ctx: &dep.Ctx{Progress: &buf}}
+	p.draw("cloning")
+	p.draw("done")
+	p.draw("")
+	want := "\rcloning\rcloning\rdone   \rdone\r    \r"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
+
+func TestProgressSummary(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := &dep.Ctx{Err: log.New(&buf, "", 0)}
+	sm := &fakeProgressSM{}
+	startProgress(ctx, sm).stop(true)
+	if buf.Len() != 0 {
+		t.Errorf("expected no summary without network operations, got %q", buf.String())
+	}
+
+	sm.stats = gps.SourceMgrStats{
+		Fetched:      1,
+		ListVersions: gps.CallStats{Count: 2},
+		Network: map[string]gps.NetworkUsage{
+			"https://github.com/foo/bar": {Contacts: 2, Bytes: 2048},
+			"https://github.com/foo/baz": {Contacts: 1},
+		},
+	}
+	startProgress(ctx, sm).stop(false)
+	if buf.Len() != 0 {
+		t.Errorf("expected no summary when not asked for, got %q", buf.String())
+	}
+	startProgress(ctx, sm).stop(true)
+	want := "Contacted 2 URLs 3 times in 0s: cloned 1 sources, listed versions 2 times, downloaded " + formatBytes(2048) + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected summary:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+}
@@ -5,42 +5,77 @@
 package main
 
 import (
+	"bytes"
 	"path/filepath"
 	"reflect"
-	"sort"
 	"testing"
 
+	"github.com/golang/dep/internal/gps"
 	"github.com/golang/dep/internal/test"
 )
 
-func TestCalculatePrune(t *testing.T) {
+func TestPruneVendor(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
 
-	vendorDir := "vendor"
-	h.TempDir(vendorDir)
-	h.TempDir(filepath.Join(vendorDir, "github.com/keep/pkg/sub"))
-	h.TempDir(filepath.Join(vendorDir, "github.com/prune/pkg/sub"))
+	h.TempDir("vendor")
+	h.TempFile("vendor/github.com/keep/pkg/pkg.go", "package pkg\n")
+	h.TempFile("vendor/github.com/keep/pkg/pkg_test.go", "package pkg\n")
+	h.TempFile("vendor/github.com/keep/pkg/unused/unused.go", "package unused\n")
+	h.TempFile("vendor/github.com/tests/pkg/pkg.go", "package pkg\n")
+	h.TempFile("vendor/github.com/tests/pkg/pkg_test.go", "package pkg\n")
 
-	toKeep := []string{
-		filepath.FromSlash("github.com/keep/pkg"),
-		filepath.FromSlash("github.com/keep/pkg/sub"),
+	l := gps.SimpleLock{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/keep/pkg"}, gps.NewVersion("v1.0.0"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/missing/pkg"}, gps.NewVersion("v1.0.0"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/tests/pkg"}, gps.NewVersion("v1.0.0"), []string{"."}),
+	}
+	o := gps.CascadingPruneOptions{
+		DefaultOptions: gps.PruneUnusedPackages | gps.PruneGoTestFiles,
+		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
+			"github.com/tests/pkg": {Mask: gps.PruneGoTestFiles},
+		},
+	}
+	want := []prunedProject{
+		{Project: "github.com/keep/pkg", PruneStats: gps.PruneStats{Files: 2, Bytes: 27}},
+		{Project: "github.com/tests/pkg", PruneStats: gps.PruneStats{}},
 	}
 
-	got, err := calculatePrune(h.Path(vendorDir), toKeep, discardLogger)
+	got, err := pruneVendor(h.Path("vendor"), l, o, true)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected dry run:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+	h.MustExist(h.Path("vendor/github.com/keep/pkg/pkg_test.go"))
+	h.MustExist(h.Path("vendor/github.com/keep/pkg/unused/unused.go"))
 
-	sort.Sort(byLen(got))
-
-	want := []string{
-		h.Path(filepath.Join(vendorDir, "github.com/prune/pkg/sub")),
-		h.Path(filepath.Join(vendorDir, "github.com/prune/pkg")),
-		h.Path(filepath.Join(vendorDir, "github.com/prune")),
+	got, err = pruneVendor(h.Path("vendor"), l, o, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected prune:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
 	}
+	h.MustNotExist(filepath.Join(h.Path("vendor"), "github.com/keep/pkg/pkg_test.go"))
+	h.MustNotExist(filepath.Join(h.Path("vendor"), "github.com/keep/pkg/unused"))
+	h.MustExist(h.Path("vendor/github.com/keep/pkg/pkg.go"))
+	h.MustExist(h.Path("vendor/github.com/tests/pkg/pkg_test.go"))
+}
+
+func TestWritePruneTable(t *testing.T) {
+	var buf bytes.Buffer
+	writePruneTable(&buf, []prunedProject{
+		{Project: "github.com/a/b", PruneStats: gps.PruneStats{Files: 3, Bytes: 2048}},
+		{Project: "github.com/c/d", PruneStats: gps.PruneStats{Files: 1, Bytes: 10}},
+	})
 
-	if !reflect.DeepEqual(want, got) {
-		t.Fatalf("calculated prune paths are not as expected.\n(WNT) %s\n(GOT) %s", want, got)
+	want := "PROJECT         FILES  SIZE     \n" +
+		"github.com/a/b  3      2.0 KiB  \n" +
+		"github.com/c/d  1      10 B     \n" +
+		"total           4      2.0 KiB  \n"
+	if buf.String() != want {
+		t.Fatalf("unexpected table:\n(GOT):\n%s\n(WNT):\n%s", buf.String(), want)
 	}
 }
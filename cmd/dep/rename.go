@@ -0,0 +1,392 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const renameShortHelp = `Follow a dependency which moved to a new import path`
+const renameLongHelp = `
+Rename a dependency which moved upstream, for instance after its organization
+was renamed or it changed domains, keeping the locked revision.
+
+  dep rename [-rewrite-imports] <old root> <new root>
+  dep rename -adopt-fork <root>
+  dep rename -drop-fork <fork root> <upstream root>
+
+The project is renamed in the constraints and overrides of Gopkg.toml, along
+with its required and ignored packages, in Gopkg.lock, and its directory is
+moved in vendor/.
+
+With -rewrite-imports, the import statements of the project which refer to
+the old import path are rewritten to the new one, and Gopkg.lock is kept in
+sync. Otherwise, update them, then run dep ensure.
+
+Forks are handled the same way. With -adopt-fork, a project whose source is a
+fork is renamed to the import path of the fork, which it is then fetched from,
+and the imports are rewritten accordingly. With -drop-fork, the project is
+renamed back to its upstream import path, and the imports are rewritten, but
+the fork remains its source, so that the locked revision is still found.
+Remove the source from Gopkg.toml, then run dep ensure -update, to go back to
+upstream.
+`
+
+func (cmd *renameCommand) Name() string { return "rename" }
+func (cmd *renameCommand) Args() string {
+	return "[-rewrite-imports] <old root> <new root> | -adopt-fork <root> | -drop-fork <fork root> <upstream root>"
+}
+func (cmd *renameCommand) ShortHelp() string { return renameShortHelp }
+func (cmd *renameCommand) LongHelp() string  { return renameLongHelp }
+func (cmd *renameCommand) Hidden() bool      { return false }
+
+func (cmd *renameCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.rewriteImports, "rewrite-imports", false, "rewrite the imports of the old import path in the project")
+	fs.BoolVar(&cmd.adoptFork, "adopt-fork", false, "rename a project to the import path of its source, and rewrite the imports")
+	fs.BoolVar(&cmd.dropFork, "drop-fork", false, "rename a fork back to its upstream import path, and rewrite the imports")
+}
+
+type renameCommand struct {
+	rewriteImports bool
+	adoptFork      bool
+	dropFork       bool
+}
+
+func (cmd *renameCommand) Run(ctx *dep.Ctx, args []string) error {
+	if cmd.adoptFork && cmd.dropFork {
+		return errors.New("cannot pass both -adopt-fork and -drop-fork")
+	}
+	if cmd.adoptFork {
+		if len(args) != 1 {
+			return errors.New("dep rename -adopt-fork takes the root of the project whose source to adopt")
+		}
+	} else if len(args) != 2 {
+		return errors.New("dep rename takes the old and the new root of the project")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	from := gps.ProjectRoot(strings.TrimSuffix(args[0], "/"))
+	var to gps.ProjectRoot
+	// source is the source of the renamed project, which is kept unless
+	// adopting or dropping a fork.
+	source := projectSource(p, from)
+	switch {
+	case cmd.adoptFork:
+		if source == "" {
+			return errors.Errorf("%s has no source to adopt", from)
+		}
+		ip, err := sourceImportPath(source)
+		if err != nil {
+			return err
+		}
+		to, source = gps.ProjectRoot(ip), ""
+	case cmd.dropFork:
+		if source != "" {
+			return errors.Errorf("%s is fetched from %s, which is not a fork to drop", from, source)
+		}
+		to, source = gps.ProjectRoot(strings.TrimSuffix(args[1], "/")), string(from)
+	default:
+		to = gps.ProjectRoot(strings.TrimSuffix(args[1], "/"))
+	}
+	if from == to {
+		return errors.New("the old and new roots are the same")
+	}
+	rewrite := cmd.rewriteImports || cmd.adoptFork || cmd.dropFork
+
+	inManifest := p.Manifest.HasConstraintsOn(from)
+	inLock := p.Lock != nil && p.Lock.HasProjectWithRoot(from)
+	if !inManifest && !inLock {
+		return errors.Errorf("%s is neither in %s nor in %s", from, dep.ManifestName, dep.LockName)
+	}
+	if p.Manifest.HasConstraintsOn(to) || p.Lock != nil && p.Lock.HasProjectWithRoot(to) {
+		return errors.Errorf("%s is already a dependency", to)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	renameInManifest(p.Manifest, from, to, source)
+	var newLock *dep.Lock
+	if p.Lock != nil {
+		newLock = renameInLock(p.Lock, from, to, source)
+	}
+
+	if rewrite {
+		files, err := rewriteImports(p.AbsRoot, string(from), string(to))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			logger.Printf("Rewrote the imports of %s", f)
+		}
+
+		// With the imports rewritten, the solver inputs are the same as the
+		// lock's once renamed, so the lock remains in sync.
+		if newLock != nil {
+			ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+			if err != nil {
+				return errors.Wrap(err, "analysis of local packages failed")
+			}
+			params := p.MakeParams()
+			params.RootPackageTree = ptree
+			params.Lock = newLock
+			s, err := gps.Prepare(params, sm)
+			if err != nil {
+				return errors.Wrap(err, "could not set up solver for input hashing")
+			}
+			newLock.SolveMeta.InputsDigest = s.HashInputs()
+		}
+	}
+
+	sw, err := dep.NewSafeWriter(p.Manifest, p.Lock, newLock, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	if err := sw.Write(p.AbsRoot, sm, false, logger); err != nil {
+		return errors.Wrap(err, "grouped write of manifest and lock failed")
+	}
+
+	if err := renameVendorDir(p.VendorDir(), string(from), string(to), p.Manifest.VendorChecksums); err != nil {
+		return err
+	}
+
+	if !rewrite {
+		ctx.Out.Printf("Renamed %s to %s. Update the imports of %s, then run dep ensure.\n", from, to, from)
+	}
+	return nil
+}
+
+// renameImportPath returns the import path with the old root replaced by the
+// new one, and whether it was under the old root at all.
+func renameImportPath(path, from, to string) (string, bool) {
+	if path == from {
+		return to, true
+	}
+	if strings.HasPrefix(path, from+"/") {
+		return to + path[len(from):], true
+	}
+	return path, false
+}
+
+// projectSource returns the source of the project, as set by an override or
+// constraint of the manifest, or recorded in the lock.
+func projectSource(p *dep.Project, pr gps.ProjectRoot) string {
+	if pp, has := p.Manifest.Ovr[pr]; has && pp.Source != "" {
+		return pp.Source
+	}
+	if pp, has := p.Manifest.Constraints[pr]; has && pp.Source != "" {
+		return pp.Source
+	}
+	if p.Lock != nil {
+		for _, lp := range p.Lock.P {
+			if lp.Ident().ProjectRoot == pr {
+				return lp.Ident().Source
+			}
+		}
+	}
+	return ""
+}
+
+// sourceImportPath returns the import path matching the URL of a source, such
+// as github.com/user/repo for https://github.com/user/repo.git or
+// git@github.com:user/repo.git.
+func sourceImportPath(source string) (string, error) {
+	ip := source
+	if strings.Contains(ip, "://") {
+		u, err := url.Parse(ip)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid source %s", source)
+		}
+		ip = u.Host + u.Path
+	} else if i := strings.Index(ip, ":"); i >= 0 {
+		// The SCP-like syntax of ssh, user@host:path.
+		ip = ip[:i] + "/" + ip[i+1:]
+		if j := strings.Index(ip, "@"); j >= 0 && j < i {
+			ip = ip[j+1:]
+		}
+	}
+	ip = strings.TrimSuffix(strings.TrimSuffix(ip, "/"), ".git")
+	if ip == "" || strings.HasPrefix(ip, "/") {
+		return "", errors.Errorf("cannot tell the import path of source %s", source)
+	}
+	return ip, nil
+}
+
+// renameInManifest renames the project in the constraints, overrides,
+// required and ignored packages of m, and sets its source.
+func renameInManifest(m *dep.Manifest, from, to gps.ProjectRoot, source string) {
+	if pp, has := m.Constraints[from]; has {
+		delete(m.Constraints, from)
+		pp.Source = source
+		m.Constraints[to] = pp
+	}
+	if pp, has := m.Ovr[from]; has {
+		delete(m.Ovr, from)
+		pp.Source = source
+		m.Ovr[to] = pp
+	}
+	for i, path := range m.Required {
+		m.Required[i], _ = renameImportPath(path, string(from), string(to))
+	}
+	for i, path := range m.Ignored {
+		m.Ignored[i], _ = renameImportPath(path, string(from), string(to))
+	}
+}
+
+// renameInLock returns a copy of l in which the project is renamed, at the
+// same version, with the given source. Its vendored files are moved along, so
+// it keeps its digest.
+func renameInLock(l *dep.Lock, from, to gps.ProjectRoot, source string) *dep.Lock {
+	nl := &dep.Lock{SolveMeta: l.SolveMeta}
+	for _, lp := range l.P {
+		id := lp.Ident()
+		if id.ProjectRoot == from {
+			id.ProjectRoot, id.Source = to, source
+			lp = gps.NewLockedProject(id, lp.Version(), lp.Packages())
+		}
+		nl.P = append(nl.P, lp)
+	}
+	for pr := range l.Dev {
+		if nl.Dev == nil {
+			nl.Dev = make(map[gps.ProjectRoot]bool, len(l.Dev))
+		}
+		if pr == from {
+			pr = to
+		}
+		nl.Dev[pr] = true
+	}
+	for pr, digest := range l.Digests {
+		if nl.Digests == nil {
+			nl.Digests = make(map[gps.ProjectRoot][]byte, len(l.Digests))
+		}
+		if pr == from {
+			pr = to
+		}
+		nl.Digests[pr] = digest
+	}
+	return nl
+}
+
+// renameVendorDir moves the slash-separated dir from to to in vendorDir, if
+// it is vendored, and updates the recorded checksums of its files.
+func renameVendorDir(vendorDir, from, to string, checksums bool) error {
+	src := filepath.Join(vendorDir, filepath.FromSlash(from))
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	dst := filepath.Join(vendorDir, filepath.FromSlash(to))
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return errors.Wrapf(err, "failed to create the parent of vendor/%s", to)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return errors.Wrapf(err, "failed to move vendor/%s to vendor/%s", from, to)
+	}
+	// Remove the parent directories left empty by the move.
+	if err := removeVendorDir(vendorDir, from); err != nil {
+		return err
+	}
+
+	if checksums {
+		return dep.WriteVendorChecksums(vendorDir)
+	}
+	return dep.ForgetVendorChecksums(vendorDir, []string{from})
+}
+
+// rewriteImports rewrites the imports of the old root and its packages in the
+// Go files of the project at root, except in vendor/, and returns the paths
+// of the files it changed.
+func rewriteImports(root, from, to string) ([]string, error) {
+	var changed []string
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			// The go tool ignores the same directories.
+			name := fi.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		ok, err := rewriteFileImports(path, fi.Mode(), from, to)
+		if err != nil {
+			return err
+		}
+		if ok {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// rewriteFileImports rewrites the imports of the old root in the Go file, and
+// reports whether there were any.
+func rewriteFileImports(path string, mode os.FileMode, from, to string) (bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	changed := false
+	for _, imp := range f.Imports {
+		ip, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if renamed, ok := renameImportPath(ip, from, to); ok {
+			imp.Path.Value = strconv.Quote(renamed)
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	// Rewriting the imports may leave them out of order.
+	ast.SortImports(fset, f)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return false, errors.Wrapf(err, "failed to format %s", path)
+	}
+	return true, errors.Wrapf(ioutil.WriteFile(path, buf.Bytes(), mode), "failed to write %s", path)
+}
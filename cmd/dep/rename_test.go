@@ -0,0 +1,141 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestRenameInManifestAndLock(t *testing.T) {
+	m := &dep.Manifest{
+		Constraints: gps.ProjectConstraints{
+			"github.com/old/x":      {Constraint: gps.NewBranch("master")},
+			"github.com/old/xtra":   {Constraint: gps.Any()},
+			"github.com/other/proj": {Constraint: gps.Any()},
+		},
+		Ovr:      gps.ProjectConstraints{},
+		Required: []string{"github.com/old/x/cmd/gen", "github.com/old/xtra"},
+		Ignored:  []string{"github.com/old/x"},
+	}
+	renameInManifest(m, "github.com/old/x", "github.com/new/x", "")
+
+	want := &dep.Manifest{
+		Constraints: gps.ProjectConstraints{
+			"github.com/new/x":      {Constraint: gps.NewBranch("master")},
+			"github.com/old/xtra":   {Constraint: gps.Any()},
+			"github.com/other/proj": {Constraint: gps.Any()},
+		},
+		Ovr:      gps.ProjectConstraints{},
+		Required: []string{"github.com/new/x/cmd/gen", "github.com/old/xtra"},
+		Ignored:  []string{"github.com/new/x"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("unexpected manifest:\n\t(GOT): %+v\n\t(WNT): %+v", m, want)
+	}
+
+	v := gps.NewBranch("master").Pair("d1f1e3a5b87e8f1bf1cdcf3e5ebcb3bd1ed59da3")
+	l := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/old/x"}, v, []string{".", "cmd/gen"}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/other/proj"}, v, []string{"."}),
+		},
+		Dev: map[gps.ProjectRoot]bool{"github.com/old/x": true},
+	}
+	nl := renameInLock(l, "github.com/old/x", "github.com/new/x", "")
+
+	if !l.HasProjectWithRoot("github.com/old/x") {
+		t.Error("expected the original lock to be left alone")
+	}
+	wantLock := &dep.Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/new/x"}, v, []string{".", "cmd/gen"}),
+			gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/other/proj"}, v, []string{"."}),
+		},
+		Dev: map[gps.ProjectRoot]bool{"github.com/new/x": true},
+	}
+	if !reflect.DeepEqual(nl, wantLock) {
+		t.Errorf("unexpected lock:\n\t(GOT): %+v\n\t(WNT): %+v", nl, wantLock)
+	}
+}
+
+func TestSourceImportPath(t *testing.T) {
+	tests := map[string]string{
+		"github.com/fork/x":               "github.com/fork/x",
+		"https://github.com/fork/x.git":   "github.com/fork/x",
+		"ssh://git@github.com/fork/x.git": "github.com/fork/x",
+		"git@github.com:fork/x.git":       "github.com/fork/x",
+		"https://example.com/fork/x/":     "example.com/fork/x",
+	}
+	for source, want := range tests {
+		got, err := sourceImportPath(source)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", source, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("unexpected import path for %s: %s, expected %s", source, got, want)
+		}
+	}
+}
+
+func TestRewriteImports(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("project/main.go", `package main
+
+import (
+	"fmt"
+
+	"github.com/old/x"
+	xsub "github.com/old/x/sub"
+	"github.com/old/xtra"
+)
+
+func main() { fmt.Println(x.X, xsub.Y, xtra.Z) }
+`)
+	h.TempFile("project/other.go", `package main
+
+import "github.com/other/proj"
+
+var _ = proj.P
+`)
+	h.TempFile("project/vendor/github.com/a/a/a.go", `package a
+
+import "github.com/old/x"
+
+var _ = x.X
+`)
+
+	changed, err := rewriteImports(h.Path("project"), "github.com/old/x", "github.com/new/x")
+	h.Must(err)
+	if want := []string{h.Path("project/main.go")}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("unexpected rewritten files:\n\t(GOT): %v\n\t(WNT): %v", changed, want)
+	}
+
+	got, err := ioutil.ReadFile(h.Path("project/main.go"))
+	h.Must(err)
+	want := `package main
+
+import (
+	"fmt"
+
+	"github.com/new/x"
+	xsub "github.com/new/x/sub"
+	"github.com/old/xtra"
+)
+
+func main() { fmt.Println(x.X, xsub.Y, xtra.Z) }
+`
+	if string(got) != want {
+		t.Errorf("unexpected rewritten file:\n\t(GOT): %s\n\t(WNT): %s", got, want)
+	}
+}
@@ -7,6 +7,8 @@ package main
 import (
 	"io/ioutil"
 	"log"
+	"os"
+	"sync"
 
 	"github.com/golang/dep"
 	fb "github.com/golang/dep/internal/feedback"
@@ -20,6 +22,9 @@ type importer interface {
 	Name() string
 	Import(path string, pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, error)
 	HasDepMetadata(dir string) bool
+	// setReport sets the migration report the importer records what its
+	// conversion did in, which may be nil.
+	setReport(r *migrationReport)
 }
 
 // rootAnalyzer supplies manifest/lock data from both dep and external tool's
@@ -32,6 +37,15 @@ type rootAnalyzer struct {
 	ctx        *dep.Ctx
 	sm         gps.SourceManager
 	directDeps map[string]bool
+
+	// report records what importing the configuration of the root project
+	// did; see InitializeRootManifestAndLock.
+	report *migrationReport
+
+	// plugins holds the importer plugins found on the PATH, keyed by name;
+	// see importerPluginPrefix. They're looked up once.
+	pluginsOnce sync.Once
+	plugins     map[string]string
 }
 
 func newRootAnalyzer(skipTools bool, ctx *dep.Ctx, directDeps map[string]bool, sm gps.SourceManager) *rootAnalyzer {
@@ -45,6 +59,7 @@ func newRootAnalyzer(skipTools bool, ctx *dep.Ctx, directDeps map[string]bool, s
 
 func (a *rootAnalyzer) InitializeRootManifestAndLock(dir string, pr gps.ProjectRoot) (rootM *dep.Manifest, rootL *dep.Lock, err error) {
 	if !a.skipTools {
+		a.report = &migrationReport{}
 		rootM, rootL, err = a.importManifestAndLock(dir, pr, false)
 		if err != nil {
 			return
@@ -66,24 +81,39 @@ func (a *rootAnalyzer) InitializeRootManifestAndLock(dir string, pr gps.ProjectR
 
 func (a *rootAnalyzer) importManifestAndLock(dir string, pr gps.ProjectRoot, suppressLogs bool) (*dep.Manifest, *dep.Lock, error) {
 	logger := a.ctx.Err
+	report := a.report
 	if suppressLogs {
 		logger = log.New(ioutil.Discard, "", 0)
+		report = nil
 	}
 
 	importers := []importer{
 		newGlideImporter(logger, a.ctx.Verbose, a.sm),
 		newGodepImporter(logger, a.ctx.Verbose, a.sm),
 		newVndrImporter(logger, a.ctx.Verbose, a.sm),
+		newGvtImporter(logger, a.ctx.Verbose, a.sm),
+		newGovendorImporter(logger, a.ctx.Verbose, a.sm),
+		newGomodImporter(logger, a.ctx.Verbose, a.sm),
 	}
+	// Importer plugins convert the configuration of the other tools, after
+	// the built-in importers.
+	a.pluginsOnce.Do(func() {
+		a.plugins = findImporterPlugins(os.Getenv("PATH"))
+	})
+	importers = append(importers, newPluginImporters(a.plugins, logger, a.ctx.Verbose, a.sm)...)
 
 	for _, i := range importers {
 		if i.HasDepMetadata(dir) {
 			a.ctx.Err.Printf("Importing configuration from %s. These are only initial constraints, and are further refined during the solve process.", i.Name())
+			if report != nil {
+				report.Tool = i.Name()
+			}
+			i.setReport(report)
 			m, l, err := i.Import(dir, pr)
 			if err != nil {
 				return nil, nil, err
 			}
-			a.removeTransitiveDependencies(m)
+			a.removeTransitiveDependencies(m, report)
 			return m, l, err
 		}
 	}
@@ -92,10 +122,11 @@ func (a *rootAnalyzer) importManifestAndLock(dir string, pr gps.ProjectRoot, sup
 	return emptyManifest, nil, nil
 }
 
-func (a *rootAnalyzer) removeTransitiveDependencies(m *dep.Manifest) {
+func (a *rootAnalyzer) removeTransitiveDependencies(m *dep.Manifest, report *migrationReport) {
 	for pr := range m.Constraints {
 		if _, isDirect := a.directDeps[string(pr)]; !isDirect {
 			delete(m.Constraints, pr)
+			report.dropConstraint(pr, "not imported by the project")
 		}
 	}
 }
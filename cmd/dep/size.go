@@ -0,0 +1,236 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const sizeShortHelp = `Attribute the size of a binary to the dependencies`
+const sizeLongHelp = `
+Build a binary of the project, and report how much of its size comes from the
+project itself, from each project in Gopkg.lock, and from the standard
+library, largest first.
+
+  dep size [package]
+  dep size -binary <file>
+
+The package to build defaults to the root package of the project. With
+-binary, the given binary is analyzed instead; it must have been built from
+the project, and with its symbol table, i.e. without -ldflags=-s.
+
+Sizes are summed from the code and data symbols of the binary, as reported by
+go tool nm. Symbols which belong to no package in particular, such as those
+generated by the runtime, are reported as "(other)".
+`
+
+func (cmd *sizeCommand) Name() string      { return "size" }
+func (cmd *sizeCommand) Args() string      { return "[-binary <file>] [package]" }
+func (cmd *sizeCommand) ShortHelp() string { return sizeShortHelp }
+func (cmd *sizeCommand) LongHelp() string  { return sizeLongHelp }
+func (cmd *sizeCommand) Hidden() bool      { return false }
+
+func (cmd *sizeCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.binary, "binary", "", "analyze the given binary instead of building one")
+}
+
+type sizeCommand struct {
+	binary string
+}
+
+// projectSize is the size of the symbols of a binary attributed to a project.
+type projectSize struct {
+	Project string
+	Size    int64
+}
+
+func (cmd *sizeCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 1 || cmd.binary != "" && len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	bin := cmd.binary
+	if bin == "" {
+		td, err := ioutil.TempDir("", "dep-size")
+		if err != nil {
+			return errors.Wrap(err, "failed to create temporary directory")
+		}
+		defer os.RemoveAll(td)
+
+		pkg := "."
+		if len(args) == 1 {
+			pkg = args[0]
+		}
+		bin = filepath.Join(td, "bin")
+		c := exec.Command("go", "build", "-o", bin, pkg)
+		c.Dir = p.AbsRoot
+		if out, err := c.CombinedOutput(); err != nil {
+			return errors.Errorf("failed to build %s: %s\n%s", pkg, err, out)
+		}
+	}
+
+	c := exec.Command("go", "tool", "nm", "-size", bin)
+	out, err := c.Output()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the symbols of %s", bin)
+	}
+
+	var roots []string
+	if p.Lock != nil {
+		for _, lp := range p.Lock.Projects() {
+			roots = append(roots, string(lp.Ident().ProjectRoot))
+		}
+	}
+	sizes, err := attributeSizes(bytes.NewReader(out), string(p.ImportRoot), roots)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeSizeTable(&buf, sizes)
+	ctx.Out.Print(buf.String())
+
+	return nil
+}
+
+// Names under which the sizes not attributed to projects are reported.
+const (
+	sizeStdlib = "(standard library)"
+	sizeOther  = "(other)"
+)
+
+// attributeSizes sums the sizes of the symbols listed in r, as printed by go
+// tool nm -size, by the project they belong to: the root project, one of the
+// locked projects, the standard library or none of them. The sizes are sorted
+// from the largest.
+func attributeSizes(r io.Reader, root string, locked []string) ([]projectSize, error) {
+	// Prefer the longest roots, for nested projects.
+	roots := append([]string{root}, locked...)
+	sort.Sort(sort.Reverse(byLength(roots)))
+
+	totals := make(map[string]int64)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// Lines read "<address> <size> <type> <name>", where the name may
+		// contain spaces.
+		fields := strings.SplitN(strings.TrimSpace(sc.Text()), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		fields = strings.Fields(fields[1])
+		if len(fields) < 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("unexpected output from go tool nm: %q", sc.Text())
+		}
+		// Only code and data take up room in the binary.
+		switch fields[1] {
+		case "T", "t", "R", "r", "D", "d":
+		default:
+			continue
+		}
+
+		name := strings.Join(fields[2:], " ")
+		totals[symbolProject(name, root, roots)] += size
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	sizes := make([]projectSize, 0, len(totals))
+	for pr, size := range totals {
+		sizes = append(sizes, projectSize{Project: pr, Size: size})
+	}
+	sort.Sort(bySize(sizes))
+	return sizes, nil
+}
+
+// symbolProject returns the project the symbol belongs to, among roots.
+func symbolProject(name, root string, roots []string) string {
+	for _, prefix := range []string{"go.itab.", "go:itab.", "type.", "type:"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	name = strings.TrimLeft(name, "*")
+	if i := strings.LastIndex(name, "/vendor/"); i >= 0 {
+		name = name[i+len("/vendor/"):]
+	}
+
+	if strings.HasPrefix(name, "main.") {
+		return root
+	}
+	for _, pr := range roots {
+		if strings.HasPrefix(name, pr+".") || strings.HasPrefix(name, pr+"/") {
+			return pr
+		}
+	}
+
+	// Packages of the standard library have no dot in the first element of
+	// their import path, unlike go-gettable ones and the symbols generated by
+	// the toolchain, such as go.string.* or go:func.*.
+	elem := name
+	if i := strings.Index(elem, "/"); i >= 0 {
+		elem = elem[:i]
+	} else if i := strings.Index(elem, "."); i >= 0 {
+		elem = elem[:i]
+	}
+	if elem == "" || elem == "go" || strings.ContainsAny(elem, ".:") {
+		return sizeOther
+	}
+	return sizeStdlib
+}
+
+func writeSizeTable(w io.Writer, sizes []projectSize) {
+	var total int64
+	for _, s := range sizes {
+		total += s.Size
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tSIZE\tSHARE\t")
+	for _, s := range sizes {
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t\n", s.Project, formatBytes(s.Size), 100*float64(s.Size)/float64(total))
+	}
+	fmt.Fprintf(tw, "total\t%s\t\t\n", formatBytes(total))
+	tw.Flush()
+}
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLength) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+
+type bySize []projectSize
+
+func (s bySize) Len() int      { return len(s) }
+func (s bySize) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySize) Less(i, j int) bool {
+	if s[i].Size != s[j].Size {
+		return s[i].Size > s[j].Size
+	}
+	return s[i].Project < s[j].Project
+}
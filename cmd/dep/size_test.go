@@ -0,0 +1,45 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributeSizes(t *testing.T) {
+	nm := `
+  4c3a20        100 T main.main
+  4c3b20         50 T github.com/me/app/internal/util.Helper
+  4c3c20        300 T github.com/me/app/vendor/github.com/pkg/errors.Wrap
+  4c3d20         20 R type.*github.com/me/app/vendor/github.com/pkg/errors.fundamental
+  4c3e20         80 D gopkg.in/yaml.v2.defaultResolve
+  4c3f20         40 T gopkg.in/yaml.v2/internal.parse
+  4c4020        500 T runtime.mallocgc
+  4c4120        100 T net/http.(*Client).Do
+  4c4220       1000 B runtime.mheap_
+  4c4320         70 r go:func.*
+                    U _cgo_init
+`
+	sizes, err := attributeSizes(strings.NewReader(nm), "github.com/me/app", []string{
+		"github.com/pkg/errors",
+		"gopkg.in/yaml.v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []projectSize{
+		{Project: sizeStdlib, Size: 600},
+		{Project: "github.com/pkg/errors", Size: 320},
+		{Project: "github.com/me/app", Size: 150},
+		{Project: "gopkg.in/yaml.v2", Size: 120},
+		{Project: sizeOther, Size: 70},
+	}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("unexpected sizes:\n\t(GOT): %+v\n\t(WNT): %+v", sizes, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+// runStats collects the statistics printed by the -stats flag. The methods
+// of a nil *runStats do nothing, so that commands can record their phases
+// unconditionally.
+type runStats struct {
+	start time.Time
+
+	solves   int
+	solve    time.Duration
+	attempts int
+
+	writes    int
+	vendor    time.Duration
+	vendorDir string
+}
+
+func newRunStats() *runStats {
+	return &runStats{start: time.Now()}
+}
+
+// solved records a solve which started at start, and resulted in soln, which
+// is nil if the solve failed.
+func (rs *runStats) solved(start time.Time, soln gps.Solution) {
+	if rs == nil {
+		return
+	}
+	rs.solves++
+	rs.solve += time.Since(start)
+	if soln != nil {
+		rs.attempts += soln.Attempts()
+	}
+}
+
+// wrote records a write of the SafeWriter sw of the vendor tree at vendorDir,
+// which started at start.
+func (rs *runStats) wrote(start time.Time, vendorDir string, sw *dep.SafeWriter) {
+	if rs == nil || !sw.HasVendor() {
+		return
+	}
+	rs.writes++
+	rs.vendor += time.Since(start)
+	rs.vendorDir = vendorDir
+}
+
+// report prints the breakdown of the run to ctx.Err, with the work done by
+// sm.
+func (rs *runStats) report(ctx *dep.Ctx, sm *gps.SourceMgr) {
+	if rs == nil {
+		return
+	}
+	var buf bytes.Buffer
+	rs.print(&buf, sm)
+	ctx.Err.Print(buf.String())
+}
+
+// print writes the breakdown of the run to w, with the work done by sm.
+func (rs *runStats) print(w io.Writer, sm *gps.SourceMgr) {
+	if rs == nil {
+		return
+	}
+	st := sm.Stats()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tCOUNT\tTIME\tDETAILS")
+	line := func(phase string, cs gps.CallStats, details string) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", phase, cs.Count, roundDuration(cs.Time), details)
+	}
+
+	line("deduction", st.Deduction, "")
+	line("version listing", st.ListVersions, "")
+	line("fetching", st.Fetch, fmt.Sprintf("%d fetched (%s), %d cache hits", st.Fetched, formatBytes(st.FetchedBytes), st.CacheHits))
+	line("analysis", st.Analysis, "")
+	if rs.solves > 0 {
+		// Every attempt but the first follows a backtrack.
+		backtracks := rs.attempts - 1
+		if backtracks < 0 {
+			backtracks = 0
+		}
+		line("solving", gps.CallStats{Count: rs.solves, Time: rs.solve}, fmt.Sprintf("%d attempts, %d backtracks", rs.attempts, backtracks))
+	}
+	if rs.writes > 0 {
+		files, size := dirUsage(rs.vendorDir)
+		line("vendor writing", gps.CallStats{Count: rs.writes, Time: rs.vendor}, fmt.Sprintf("%d files (%s)", files, formatBytes(size)))
+	}
+	if len(st.Network) > 0 {
+		hosts := networkByHost(st.Network)
+		var total gps.NetworkUsage
+		for _, nu := range hosts {
+			total.Contacts += nu.Contacts
+			total.Bytes += nu.Bytes
+		}
+		fmt.Fprintf(tw, "network\t%d\t\t%d hosts, %s downloaded\n", total.Contacts, len(hosts), formatBytes(total.Bytes))
+		names := make([]string, 0, len(hosts))
+		for host := range hosts {
+			names = append(names, host)
+		}
+		sort.Sort(byNetworkUsage{names, hosts})
+		for _, host := range names {
+			fmt.Fprintf(tw, "  %s\t%d\t\t%s downloaded\n", host, hosts[host].Contacts, formatBytes(hosts[host].Bytes))
+		}
+	}
+	fmt.Fprintf(tw, "total\t\t%s\t\n", roundDuration(time.Since(rs.start)))
+	tw.Flush()
+}
+
+// dirUsage returns the number of files beneath dir, and their total size.
+func dirUsage(dir string) (files int, size int64) {
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d - d%time.Millisecond
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestRunStatsPrint(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("cache")
+	h.TempFile("project/vendor/github.com/foo/bar/bar.go", "package bar\n")
+	h.TempFile("project/vendor/github.com/foo/bar/LICENSE", "MIT\n")
+
+	sm, err := gps.NewSourceManager(h.Path("cache"))
+	h.Must(err)
+	defer sm.Release()
+
+	rs := newRunStats()
+	rs.solves, rs.solve, rs.attempts = 1, time.Second, 3
+	rs.writes, rs.vendor, rs.vendorDir = 1, time.Second, h.Path("project/vendor")
+
+	var buf bytes.Buffer
+	rs.print(&buf, sm)
+	out := buf.String()
+
+	for _, want := range []string{
+		"PHASE",
+		"deduction",
+		"fetching",
+		"0 fetched (0 B), 0 cache hits",
+		"3 attempts, 2 backtracks",
+		"2 files (16 B)",
+		"total",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the stats to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Commands record their phases whether -stats is passed or not.
+	var none *runStats
+	none.solved(time.Now(), nil)
+	none.print(&buf, sm)
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1024:        "1.0 KiB",
+		1536:        "1.5 KiB",
+		5 << 20:     "5.0 MiB",
+		3 << 30 / 2: "1.5 GiB",
+	}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -33,30 +33,145 @@ With no arguments, print the status of each dependency of the project.
   LATEST      Latest VCS revision available
   PKGS USED   Number of packages from this project that are actually used
 
-With one or more explicitly specified packages, or with the -detailed flag,
-print an extended status output for each dependency of the project.
+With one or more projects of Gopkg.lock, or packages of them, print a detailed
+report on each of them instead, or on every dependency with -detailed:
+
+  CONSTRAINT       The rule of Gopkg.toml on the project, telling whether it's
+                   a constraint or an override, followed by the constraints
+                   the other dependencies declare on it in their manifests
+  VERSION          Version chosen, from the lock
+  REVISION         VCS revision of the chosen version
+  LATEST MATCHING  Newest version the rule of Gopkg.toml allows
+  LATEST           Newest version overall
+  PACKAGES         Packages of the project used, from the lock
+  IMPORTED BY      For each package of the project importing it, directly or
+                   through other dependencies, the shortest chain of imports
+  TEST ONLY        Whether it's only reached through test imports
+
+With -json, the reports are printed as a JSON array of objects with the
+ProjectRoot, Source, Constraint, ConstraintSource, Hints, Version, Revision,
+LatestMatching, Latest, Packages, ImportChains and TestOnly fields.
+
+With -blame, print who last changed the rules of each dependency and its entry
+in the lock, and in which commit, as found by git blame over the manifest and
+lock:
+
+  CONSTRAINT CHANGED  Commit and author of the last change to the rules
+  LOCK CHANGED        Commit and author of the last change to the lock entry
+
+With -licenses, print the license of each project of the lock instead, as
+detected from its license files in vendor/, or in its locked version if
+vendor/ holds none:
+
+  PROJECT   Import path
+  VERSION   Version chosen, from the lock
+  REVISION  VCS revision of the chosen version
+  LICENSE   SPDX identifier of the license, Unknown if it can't be identified,
+            or None if there is no license file
+
+With -json, the licenses are printed as a JSON array of objects with the
+ProjectRoot, Version, Revision, License and Denied fields. -deny-licenses
+takes a comma-separated list of licenses, such as "GPL-3.0,AGPL-3.0,Unknown",
+and makes dep status -licenses fail if any project has one of them.
+
+With -hints, print instead the constraints which the projects of the lock
+declare on one another in their own manifests, next to the constraint of the
+project on each, to help pick versions the dependencies were built against:
 
-  TODO    Another column description
-  FOOBAR  Another column description
+  PROJECT     Import path
+  CONSTRAINT  Version constraint, from the manifest, or - if there is none
+  HINTS       The constraints on the project from the manifests of the others
+
+With -json, the hints are printed as a JSON array of objects with the
+ProjectRoot, Constraint and Hints fields, each hint having the From and
+Constraint fields. "dep ensure -adopt-hints" adds them to Gopkg.toml.
+
+With -constraints, check the rules of Gopkg.toml against the imports of the
+project instead, and list the constraints on projects it no longer imports or
+requires directly, and the projects it imports directly with no rules, along
+with the constraint that allows the versions compatible with the one in
+Gopkg.lock. With -json, they're printed as a JSON object with the Unused and
+Missing fields. dep status -constraints fails if it finds any, unless
+-autofix is passed to remove the unused constraints from Gopkg.toml and add
+the missing ones; the projects locked to a bare revision are left for you to
+constrain.
+
+With -lock-drift, -missing or -vendor-missing, run only the checks they select
+instead, print their outcome, and fail with an exit code of their own, the sum
+of those of the failed checks when several fail, so that CI can assert exactly
+the invariant it cares about:
+
+  -lock-drift      2  The inputs digest of Gopkg.lock doesn't match Gopkg.toml
+                      and the imports of the project
+  -missing         4  Projects imported by the project are missing from
+                      Gopkg.lock
+  -vendor-missing  8  Projects of Gopkg.lock are missing from vendor/
+
+Exit code 1 stays that of the other errors. With -json, the outcome is printed
+as a JSON array of objects with the Check, Failed, ExitCode and Projects
+fields.
+
+With -json, print the status of each dependency as a JSON array of objects,
+followed by an array of the projects with missing packages, if any. Each
+object of the first array has the fields:
+
+  ProjectRoot   Import path
+  Constraint    Version constraint, from the manifest
+  Override      Whether the constraint is an override
+  Version       Version chosen, from the lock
+  Revision      Full VCS revision of the chosen version
+  Latest        Full latest VCS revision available
+  PackageCount  Number of packages from this project that are actually used
+
+along with ConstraintChange and LockChange with -blame.
+
+With -out, print the status in the given format:
+
+  text  The table above, the default
+  json  The JSON arrays above, as -json does
+  dot   A Graphviz digraph of the projects imported by the project and its
+        dependencies, as -dot does. Each project is labeled with its version
+        from the lock, and each import with the constraint the importing
+        project declares on the imported one in its manifest, or with the
+        override of Gopkg.toml on it, if any
+  bazel A go_repository rule of Gazelle for each project of Gopkg.lock, with
+        its name, importpath and locked commit, and the remote it's fetched
+        from if the lock names a source, for the WORKSPACE of Bazel
+
+To render the graph, pipe it through Graphviz:
+
+  dep status -out dot | dot -T png > deps.png
+
+The bazel output only reads Gopkg.lock, so it takes no network access.
 
 Status returns exit code zero if all dependencies are in a "good state".
 `
 
 func (cmd *statusCommand) Name() string      { return "status" }
-func (cmd *statusCommand) Args() string      { return "[package...]" }
+func (cmd *statusCommand) Args() string      { return "[project...]" }
 func (cmd *statusCommand) ShortHelp() string { return statusShortHelp }
 func (cmd *statusCommand) LongHelp() string  { return statusLongHelp }
 func (cmd *statusCommand) Hidden() bool      { return false }
 
 func (cmd *statusCommand) Register(fs *flag.FlagSet) {
-	fs.BoolVar(&cmd.detailed, "detailed", false, "report more detailed status")
+	fs.BoolVar(&cmd.detailed, "detailed", false, "report the detailed status of every dependency")
 	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
 	fs.StringVar(&cmd.template, "f", "", "output in text/template format")
 	fs.BoolVar(&cmd.dot, "dot", false, "output the dependency graph in GraphViz format")
+	fs.StringVar(&cmd.output, "out", "", "output format: text, json, dot or bazel")
 	fs.BoolVar(&cmd.old, "old", false, "only show out-of-date dependencies")
-	fs.BoolVar(&cmd.missing, "missing", false, "only show missing dependencies")
+	fs.BoolVar(&cmd.missing, "missing", false, "check that Gopkg.lock has every project imported, failing with exit code 4")
+	fs.BoolVar(&cmd.lockDrift, "lock-drift", false, "check that the inputs digest of Gopkg.lock matches, failing with exit code 2")
+	fs.BoolVar(&cmd.vendorMissing, "vendor-missing", false, "check that vendor/ has every project of Gopkg.lock, failing with exit code 8")
 	fs.BoolVar(&cmd.unused, "unused", false, "only show unused dependencies")
 	fs.BoolVar(&cmd.modified, "modified", false, "only show modified dependencies")
+	fs.BoolVar(&cmd.stats, "stats", false, "print a breakdown of where the time went at exit")
+	fs.BoolVar(&cmd.blame, "blame", false, "show who last changed the rules and lock entry of each dependency, from git blame")
+	fs.BoolVar(&cmd.licenses, "licenses", false, "show the license of each dependency")
+	fs.StringVar(&cmd.denyLicenses, "deny-licenses", "", "comma-separated list of licenses for -licenses to fail on")
+	fs.BoolVar(&cmd.hints, "hints", false, "show the constraints the dependencies declare on one another in their own manifests")
+	fs.BoolVar(&cmd.constraints, "constraints", false, "show the constraints on projects not imported directly, and the direct imports without one")
+	fs.BoolVar(&cmd.autofix, "autofix", false, "with -constraints, remove the unused constraints from Gopkg.toml and add the missing ones")
 }
 
 type statusCommand struct {
@@ -69,6 +184,17 @@ type statusCommand struct {
 	missing  bool
 	unused   bool
 	modified bool
+	stats    bool
+	blame    bool
+
+	licenses     bool
+	denyLicenses string
+	hints        bool
+	constraints  bool
+	autofix      bool
+
+	lockDrift     bool
+	vendorMissing bool
 }
 
 type outputter interface {
@@ -80,9 +206,16 @@ type outputter interface {
 	MissingFooter()
 }
 
-type tableOutput struct{ w *tabwriter.Writer }
+type tableOutput struct {
+	w     *tabwriter.Writer
+	blame bool
+}
 
 func (out *tableOutput) BasicHeader() {
+	if out.blame {
+		fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\tCONSTRAINT CHANGED\tLOCK CHANGED\n")
+		return
+	}
 	fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tLATEST\tPKGS USED\n")
 }
 
@@ -91,6 +224,20 @@ func (out *tableOutput) BasicFooter() {
 }
 
 func (out *tableOutput) BasicLine(bs *BasicStatus) {
+	if out.blame {
+		fmt.Fprintf(out.w,
+			"%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t\n",
+			bs.ProjectRoot,
+			bs.getConsolidatedConstraint(),
+			formatVersion(bs.Version),
+			formatVersion(bs.Revision),
+			formatVersion(bs.Latest),
+			bs.PackageCount,
+			bs.ConstraintChange,
+			bs.LockChange,
+		)
+		return
+	}
 	fmt.Fprintf(out.w,
 		"%s\t%s\t%s\t%s\t%s\t%d\t\n",
 		bs.ProjectRoot,
@@ -150,7 +297,6 @@ func (out *jsonOutput) MissingFooter() {
 
 type dotOutput struct {
 	w io.Writer
-	o string
 	g *graphviz
 	p *dep.Project
 }
@@ -161,7 +307,15 @@ func (out *dotOutput) BasicHeader() {
 	ptree, _ := pkgtree.ListPackages(out.p.ResolvedAbsRoot, string(out.p.ImportRoot))
 	prm, _ := ptree.ToReachMap(true, false, false, nil)
 
-	out.g.createNode(string(out.p.ImportRoot), "", prm.FlattenFn(paths.IsStandardImportPath))
+	var constraints map[string]string
+	if out.p.Manifest != nil {
+		constraints = constraintLabels(out.p.Manifest.Constraints)
+		for pr, c := range constraintLabels(out.p.Manifest.Ovr) {
+			out.g.ovr[pr] = c
+		}
+	}
+
+	out.g.createNode(string(out.p.ImportRoot), "", prm.FlattenFn(paths.IsStandardImportPath), constraints)
 }
 
 func (out *dotOutput) BasicFooter() {
@@ -170,13 +324,59 @@ func (out *dotOutput) BasicFooter() {
 }
 
 func (out *dotOutput) BasicLine(bs *BasicStatus) {
-	out.g.createNode(bs.ProjectRoot, bs.getConsolidatedVersion(), bs.Children)
+	out.g.createNode(bs.ProjectRoot, bs.getConsolidatedVersion(), bs.Children, constraintLabels(bs.dependencyConstraints))
+}
+
+// constraintLabels returns the constraints of pc which actually constrain
+// their project, as labels for the graph, keyed by project root.
+func constraintLabels(pc gps.ProjectConstraints) map[string]string {
+	labels := make(map[string]string, len(pc))
+	for pr, pp := range pc {
+		if pp.Constraint == nil || gps.IsAny(pp.Constraint) {
+			continue
+		}
+		if v, ok := pp.Constraint.(gps.Version); ok {
+			labels[string(pr)] = formatVersion(v)
+		} else {
+			labels[string(pr)] = pp.Constraint.String()
+		}
+	}
+	return labels
 }
 
 func (out *dotOutput) MissingHeader()                {}
 func (out *dotOutput) MissingLine(ms *MissingStatus) {}
 func (out *dotOutput) MissingFooter()                {}
 
+// outputFormat returns the output format set by -out, or by its -json and -dot
+// shorthands, which defaults to text.
+func (cmd *statusCommand) outputFormat() (string, error) {
+	format := cmd.output
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{cmd.json, "json"},
+		{cmd.dot, "dot"},
+	} {
+		if !f.set {
+			continue
+		}
+		if format != "" && format != f.name {
+			return "", errors.Errorf("conflicting output formats %s and %s", format, f.name)
+		}
+		format = f.name
+	}
+
+	switch format {
+	case "":
+		return "text", nil
+	case "text", "json", "dot", "bazel":
+		return format, nil
+	}
+	return "", errors.Errorf("unknown output format %q; must be text, json, dot or bazel", format)
+}
+
 func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 	p, err := ctx.LoadProject()
 	if err != nil {
@@ -189,29 +389,98 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 	}
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
+	defer recordNetworkUsage(ctx, "status", p, sm)
+
+	if cmd.stats {
+		defer newRunStats().report(ctx, sm)
+	}
+
+	format, err := cmd.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	if cmd.denyLicenses != "" && !cmd.licenses {
+		return errors.New("-deny-licenses only applies together with -licenses")
+	}
+	if cmd.autofix && !cmd.constraints {
+		return errors.New("-autofix only applies together with -constraints")
+	}
+	if format == "bazel" {
+		if cmd.lockDrift || cmd.missing || cmd.vendorMissing || cmd.constraints || cmd.hints || cmd.licenses || cmd.detailed || cmd.blame || cmd.old || len(args) > 0 {
+			return errors.New("-out bazel is not supported with projects or other options")
+		}
+		return runStatusBazel(ctx, p)
+	}
+	if cmd.lockDrift || cmd.missing || cmd.vendorMissing {
+		if format == "dot" || cmd.blame || cmd.licenses || cmd.hints || cmd.constraints || cmd.detailed || len(args) > 0 {
+			return errors.New("-lock-drift, -missing and -vendor-missing are not supported with projects, -detailed, -blame, -licenses, -hints, -constraints or -out dot")
+		}
+		return runStatusChecks(ctx, p, sm, cmd.lockDrift, cmd.missing, cmd.vendorMissing, format == "json")
+	}
+	if cmd.constraints {
+		if format == "dot" || cmd.blame || cmd.licenses || cmd.hints {
+			return errors.New("-constraints is not supported with -blame, -licenses, -hints or -out dot")
+		}
+		return runStatusConstraints(ctx, p, sm, format == "json", cmd.autofix)
+	}
+	if cmd.hints {
+		if format == "dot" || cmd.blame || cmd.licenses {
+			return errors.New("-hints is not supported with -blame, -licenses or -out dot")
+		}
+		return runStatusHints(ctx, p, sm, format == "json")
+	}
+	if cmd.licenses {
+		if format == "dot" || cmd.blame {
+			return errors.New("-licenses is not supported with -blame or -out dot")
+		}
+		return runStatusLicenses(ctx, p, sm, format == "json", parseLicenseList(cmd.denyLicenses))
+	}
+
+	if cmd.detailed || len(args) > 0 {
+		if format == "dot" || cmd.blame {
+			return errors.New("the detailed status is not supported with -blame or -out dot")
+		}
+		if p.Lock == nil {
+			return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+		}
+		roots, err := resolveLockedProjects(p.Lock, args)
+		if err != nil {
+			return err
+		}
+		return runStatusProjects(ctx, p, sm, roots, format == "json")
+	}
+
+	var blame *entryBlame
+	if cmd.blame {
+		if format == "dot" {
+			return errors.New("-blame is not supported with -out dot")
+		}
+		if blame, err = blameEntries(p); err != nil {
+			return err
+		}
+	}
 
 	var buf bytes.Buffer
 	var out outputter
 	switch {
-	case cmd.detailed:
-		return errors.Errorf("not implemented")
-	case cmd.json:
+	case format == "json":
 		out = &jsonOutput{
 			w: &buf,
 		}
-	case cmd.dot:
+	case format == "dot":
 		out = &dotOutput{
 			p: p,
-			o: cmd.output,
 			w: &buf,
 		}
 	default:
 		out = &tableOutput{
-			w: tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			w:     tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0),
+			blame: cmd.blame,
 		}
 	}
 
-	digestMismatch, hasMissingPkgs, err := runStatusAll(ctx, out, p, sm)
+	digestMismatch, hasMissingPkgs, err := runStatusAll(ctx, out, p, sm, blame)
 	if err != nil {
 		return err
 	}
@@ -229,16 +498,73 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		ctx.Out.Print(buf.String())
 	}
 
+	if p.Lock != nil {
+		warnDuplicateProjects(ctx, p.Lock)
+	}
+
+	return nil
+}
+
+// runStatusLicenses prints the license of each project of the lock of p, and
+// fails if any of them is in deny.
+func runStatusLicenses(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, asJSON bool, deny map[string]bool) error {
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	licenses, err := lockedLicenses(p.Lock, p.VendorDir(), sm, deny)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if asJSON {
+		if err := json.NewEncoder(&buf).Encode(licenses); err != nil {
+			return errors.Wrap(err, "failed to marshal licenses")
+		}
+	} else {
+		writeLicenses(&buf, licenses)
+	}
+	ctx.Out.Print(buf.String())
+
+	return deniedLicensesError(licenses)
+}
+
+// runStatusHints prints the constraints the projects of the lock of p declare
+// on one another, next to those of p.
+func runStatusHints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, asJSON bool) error {
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	hints := statusHints(p.Manifest, p.Lock, collectConstraintHints(ctx, p.Lock, sm))
+
+	var buf bytes.Buffer
+	if asJSON {
+		if hints == nil {
+			hints = []projectHints{}
+		}
+		if err := json.NewEncoder(&buf).Encode(hints); err != nil {
+			return errors.Wrap(err, "failed to marshal hints")
+		}
+	} else {
+		writeHints(&buf, hints)
+	}
+	ctx.Out.Print(buf.String())
 	return nil
 }
 
 type rawStatus struct {
 	ProjectRoot  string
 	Constraint   string
+	Override     bool
 	Version      string
 	Revision     gps.Revision
 	Latest       gps.Version
 	PackageCount int
+
+	ConstraintChange *Attribution `json:",omitempty"`
+	LockChange       *Attribution `json:",omitempty"`
 }
 
 // BasicStatus contains all the information reported about a single dependency
@@ -252,6 +578,15 @@ type BasicStatus struct {
 	Latest       gps.Version
 	PackageCount int
 	hasOverride  bool
+
+	// dependencyConstraints holds the constraints the manifest of the project
+	// declares on its own dependencies, for the dot output.
+	dependencyConstraints gps.ProjectConstraints
+
+	// ConstraintChange and LockChange are the last changes to the rules of
+	// the project in the manifest and to its entry in the lock, with -blame.
+	ConstraintChange *Attribution
+	LockChange       *Attribution
 }
 
 func (bs *BasicStatus) getConsolidatedConstraint() string {
@@ -283,10 +618,14 @@ func (bs *BasicStatus) marshalJSON() *rawStatus {
 	return &rawStatus{
 		ProjectRoot:  bs.ProjectRoot,
 		Constraint:   bs.getConsolidatedConstraint(),
+		Override:     bs.hasOverride,
 		Version:      formatVersion(bs.Version),
 		Revision:     bs.Revision,
 		Latest:       bs.Latest,
 		PackageCount: bs.PackageCount,
+
+		ConstraintChange: bs.ConstraintChange,
+		LockChange:       bs.LockChange,
 	}
 }
 
@@ -296,7 +635,9 @@ type MissingStatus struct {
 	MissingPackages []string
 }
 
-func runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceManager) (bool, bool, error) {
+// runStatusAll prints the status of all the dependencies of p to out. The last
+// changes to their entries are added from blame, unless it's nil.
+func runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceManager, blame *entryBlame) (bool, bool, error) {
 	var digestMismatch, hasMissingPkgs bool
 
 	if p.Lock == nil {
@@ -310,14 +651,10 @@ func runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceMana
 		return digestMismatch, hasMissingPkgs, errors.Errorf("analysis of local packages failed: %v", err)
 	}
 
-	// Set up a solver in order to check the InputHash.
-	params := gps.SolveParameters{
-		ProjectAnalyzer: dep.Analyzer{},
-		RootDir:         p.AbsRoot,
-		RootPackageTree: ptree,
-		Manifest:        p.Manifest,
-		// Locks aren't a part of the input hash check, so we can omit it.
-	}
+	// Set up a solver in order to check the InputHash, with the parameters
+	// dep ensure hashes.
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
 
 	logger := ctx.Err
 	if ctx.Verbose {
@@ -372,6 +709,13 @@ func runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceMana
 
 				prm, _ := ptr.ToReachMap(true, false, false, nil)
 				bs.Children = prm.FlattenFn(paths.IsStandardImportPath)
+
+				m, _, err := sm.GetManifestAndLock(proj.Ident(), proj.Version(), dep.Analyzer{})
+				if err != nil {
+					logger.Printf("Unable to read the manifest of %s: %s\n", proj.Ident().ProjectRoot, err)
+				} else if m != nil {
+					bs.dependencyConstraints = m.DependencyConstraints()
+				}
 			}
 
 			// Split apart the version from the lock into its constituent parts
@@ -409,19 +753,17 @@ func runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceMana
 				// transitive project deps will always show "any" here.
 				bs.Constraint = c.Constraint
 
-				vl, err := sm.ListVersions(proj.Ident())
-				if err == nil {
-					gps.SortPairedForUpgrade(vl)
-
-					for _, v := range vl {
-						// Because we've sorted the version list for
-						// upgrade, the first version we encounter that
-						// matches our constraint will be what we want.
-						if c.Constraint.Matches(v) {
-							bs.Latest = v.Revision()
-							break
-						}
-					}
+				if v, err := latestMatchingVersion(sm, proj.Ident(), c.Constraint); err == nil && v != nil {
+					bs.Latest = v.Revision()
+				}
+			}
+
+			if blame != nil {
+				bs.LockChange = blame.projects[bs.ProjectRoot]
+				if bs.hasOverride {
+					bs.ConstraintChange = blame.overrides[bs.ProjectRoot]
+				} else {
+					bs.ConstraintChange = blame.constraints[bs.ProjectRoot]
 				}
 			}
 
@@ -494,6 +836,27 @@ outer:
 	return digestMismatch, hasMissingPkgs, nil
 }
 
+// latestMatchingVersion returns the newest version of the project that is
+// allowed by the provided constraint, or nil if none matches.
+func latestMatchingVersion(sm gps.SourceManager, id gps.ProjectIdentifier, c gps.Constraint) (gps.PairedVersion, error) {
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	gps.SortPairedForUpgrade(vl)
+	for _, v := range vl {
+		// Because we've sorted the version list for upgrade, the first
+		// version we encounter that matches our constraint will be what we
+		// want.
+		if c.Matches(v) {
+			return v, nil
+		}
+	}
+
+	return nil, nil
+}
+
 func formatVersion(v gps.Version) string {
 	if v == nil {
 		return ""
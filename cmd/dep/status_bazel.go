@@ -0,0 +1,153 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+// runStatusBazel prints the go_repository rules of the projects of the lock,
+// for the WORKSPACE of Bazel.
+func runStatusBazel(ctx *dep.Ctx, p *dep.Project) error {
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	var buf bytes.Buffer
+	if err := writeBazelRules(&buf, p.Lock); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// writeBazelRules writes a go_repository rule of Gazelle for each project of
+// l, sorted by project root, pinned to its locked revision. The projects
+// locked to another source are fetched from it, if its VCS can be told from
+// it; the rules of the others say so in a comment, their remote and vcs left
+// for the user to set.
+func writeBazelRules(w io.Writer, l *dep.Lock) error {
+	lps := l.Projects()
+	sort.Sort(dep.SortedLockedProjects(lps))
+	for i, lp := range lps {
+		id := lp.Ident()
+		rev := revisionOf(lp.Version())
+		if rev == "" {
+			return errors.Errorf("%s is locked without a revision", id.ProjectRoot)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "go_repository(")
+		fmt.Fprintf(w, "    name = %q,\n", bazelRepoName(string(id.ProjectRoot)))
+		fmt.Fprintf(w, "    importpath = %q,\n", string(id.ProjectRoot))
+		fmt.Fprintf(w, "    commit = %q,\n", string(rev))
+		if id.Source != "" {
+			if vcs := bazelVCS(id.Source); vcs != "" {
+				fmt.Fprintf(w, "    remote = %q,\n", bazelRemote(id.Source))
+				fmt.Fprintf(w, "    vcs = %q,\n", vcs)
+			} else {
+				fmt.Fprintf(w, "    # Fetched from %s, of an unknown VCS: set remote and vcs.\n", id.Source)
+			}
+		}
+		fmt.Fprintln(w, ")")
+	}
+	return nil
+}
+
+// bazelRepoName returns the name Gazelle gives the repository of an import
+// path: its host name reversed, followed by the rest of its path, with
+// underscores for anything but letters and digits, as github.com/pkg/errors
+// gives com_github_pkg_errors.
+func bazelRepoName(importPath string) string {
+	parts := strings.Split(importPath, "/")
+	host := strings.Split(parts[0], ".")
+	for i, j := 0, len(host)-1; i < j; i, j = i+1, j-1 {
+		host[i], host[j] = host[j], host[i]
+	}
+	name := strings.Join(append(host, parts[1:]...), "_")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '_':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, name)
+}
+
+// bazelRemote returns the URL to fetch a source from, which Gazelle needs in
+// full, while a source may be an import path.
+func bazelRemote(source string) string {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+		return source
+	}
+	return "https://" + source
+}
+
+// bazelSchemeVCS maps the URL schemes proper to a VCS to it.
+var bazelSchemeVCS = map[string]string{
+	"git":     "git",
+	"git+ssh": "git",
+	"bzr":     "bzr",
+	"bzr+ssh": "bzr",
+	"svn":     "svn",
+	"svn+ssh": "svn",
+}
+
+// bazelVCSHosts maps the hosts serving a single VCS to it.
+var bazelVCSHosts = map[string]string{
+	"github.com":          "git",
+	"gitlab.com":          "git",
+	"gopkg.in":            "git",
+	"go.googlesource.com": "git",
+	"git.apache.org":      "git",
+	"hub.jazz.net":        "git",
+	"git.launchpad.net":   "git",
+	"launchpad.net":       "bzr",
+}
+
+// bazelVCS returns the VCS of a source, as Gazelle names it, telling it from
+// the scheme of its URL, the VCS extension of its path, as in
+// example.com/repo.hg, or its host. It returns "" for the sources whose VCS
+// can't be told so, such as those of hosts serving several of them.
+func bazelVCS(source string) string {
+	if strings.HasPrefix(source, "git@") {
+		return "git"
+	}
+	rest := source
+	if i := strings.Index(source, "://"); i >= 0 {
+		if vcs := bazelSchemeVCS[source[:i]]; vcs != "" {
+			return vcs
+		}
+		rest = source[i+3:]
+	}
+
+	path := strings.TrimSuffix(rest, "/")
+	for _, vcs := range []string{"git", "hg", "bzr", "svn"} {
+		if strings.HasSuffix(path, "."+vcs) || strings.Contains(path, "."+vcs+"/") {
+			return vcs
+		}
+	}
+
+	host := strings.SplitN(path, "/", 2)[0]
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return bazelVCSHosts[host]
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestWriteBazelRules(t *testing.T) {
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "gopkg.in/yaml.v2"}, gps.Revision("a5b47d31c556af34a302ce5d659e6fea44d90de0"), []string{"."}),
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "github.com/pkg/errors", Source: "github.com/fork/errors"},
+			gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"),
+			[]string{"."},
+		),
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "example.com/hg", Source: "https://hg.example.com/repo.hg"},
+			gps.Revision("d7a4f8b6b26c0ba4d8b1c2a1f0e5e4c7b3a29d11"),
+			[]string{"."},
+		),
+		gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: "example.com/unknown", Source: "https://code.example.com/repo"},
+			gps.Revision("0e4b1f3d8a6c2e9f7b5d3a1c9e7f5b3d1a8c6e4f"),
+			[]string{"."},
+		),
+	}}
+
+	var buf bytes.Buffer
+	if err := writeBazelRules(&buf, l); err != nil {
+		t.Fatal(err)
+	}
+	want := `go_repository(
+    name = "com_example_hg",
+    importpath = "example.com/hg",
+    commit = "d7a4f8b6b26c0ba4d8b1c2a1f0e5e4c7b3a29d11",
+    remote = "https://hg.example.com/repo.hg",
+    vcs = "hg",
+)
+
+go_repository(
+    name = "com_example_unknown",
+    importpath = "example.com/unknown",
+    commit = "0e4b1f3d8a6c2e9f7b5d3a1c9e7f5b3d1a8c6e4f",
+    # Fetched from https://code.example.com/repo, of an unknown VCS: set remote and vcs.
+)
+
+go_repository(
+    name = "com_github_pkg_errors",
+    importpath = "github.com/pkg/errors",
+    commit = "645ef00459ed84a119197bfb8d8205042c6df63d",
+    remote = "https://github.com/fork/errors",
+    vcs = "git",
+)
+
+go_repository(
+    name = "in_gopkg_yaml_v2",
+    importpath = "gopkg.in/yaml.v2",
+    commit = "a5b47d31c556af34a302ce5d659e6fea44d90de0",
+)
+`
+	if buf.String() != want {
+		t.Errorf("unexpected rules:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), want)
+	}
+
+	l.P = append(l.P, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0"), []string{"."}))
+	if err := writeBazelRules(&buf, l); err == nil {
+		t.Error("expected an error for a project locked without a revision")
+	}
+}
+
+func TestBazelRepoName(t *testing.T) {
+	cases := map[string]string{
+		"github.com/pkg/errors":          "com_github_pkg_errors",
+		"golang.org/x/net":               "org_golang_x_net",
+		"gopkg.in/yaml.v2":               "in_gopkg_yaml_v2",
+		"github.com/Sirupsen/logrus":     "com_github_sirupsen_logrus",
+		"example.com/my-project/go.util": "com_example_my_project_go_util",
+	}
+	for in, want := range cases {
+		if got := bazelRepoName(in); got != want {
+			t.Errorf("%s: expected %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestBazelVCS(t *testing.T) {
+	cases := map[string]string{
+		"github.com/fork/errors":              "git",
+		"git@github.com:fork/errors.git":      "git",
+		"git+ssh://example.com/repo":          "git",
+		"https://example.com/repo.git":        "git",
+		"example.com/repo.hg/sub":             "hg",
+		"ssh://hg@example.com/repo.hg":        "hg",
+		"bzr+ssh://bazaar.example.com/repo":   "bzr",
+		"launchpad.net/project":               "bzr",
+		"svn://example.com/repo":              "svn",
+		"https://user@gitlab.com:443/foo/bar": "git",
+		"bitbucket.org/foo/bar":               "",
+		"https://code.example.com/repo":       "",
+	}
+	for in, want := range cases {
+		if got := bazelVCS(in); got != want {
+			t.Errorf("%s: expected %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// The exit codes of the checks of dep status. When several checks fail, dep
+// exits with the sum of their codes, so that each stays recognizable.
+const (
+	exitLockDrift     = 2
+	exitLockMissing   = 4
+	exitVendorMissing = 8
+)
+
+// statusCheck is the outcome of one of the checks of dep status.
+type statusCheck struct {
+	// Check is the name of the check, that of its flag.
+	Check  string
+	Failed bool
+	// ExitCode is the code the check adds to that of dep when it fails.
+	ExitCode int
+	// Projects holds the projects the check failed on, if it's about
+	// projects.
+	Projects []gps.ProjectRoot `json:",omitempty"`
+}
+
+// statusCheckError is returned by dep status when some of its checks failed,
+// and makes dep exit with code.
+type statusCheckError struct {
+	failed []string
+	code   int
+}
+
+func (e statusCheckError) Error() string {
+	return fmt.Sprintf("dep status: failed check(s): %s", strings.Join(e.failed, ", "))
+}
+
+func (e statusCheckError) exitCode() int {
+	return e.code
+}
+
+// runStatusChecks runs the checks of dep status which are set, and prints
+// their outcome. It returns a statusCheckError if any failed.
+func runStatusChecks(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, lockDrift, missing, vendorMissing, asJSON bool) error {
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return errors.Wrap(err, "analysis of local packages failed")
+	}
+
+	var checks []statusCheck
+	if lockDrift {
+		drifted, err := lockDrifted(p, ptree, sm)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, statusCheck{Check: "lock-drift", Failed: drifted, ExitCode: exitLockDrift})
+	}
+	if missing {
+		roots, err := missingFromLock(p.Lock, directImports(ptree, p.Manifest, p.ImportRoot), sm)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, statusCheck{Check: "missing", Failed: len(roots) > 0, ExitCode: exitLockMissing, Projects: roots})
+	}
+	if vendorMissing {
+		roots, err := missingFromVendor(p.Lock, p.VendorDir())
+		if err != nil {
+			return err
+		}
+		checks = append(checks, statusCheck{Check: "vendor-missing", Failed: len(roots) > 0, ExitCode: exitVendorMissing, Projects: roots})
+	}
+
+	var buf bytes.Buffer
+	if asJSON {
+		if err := json.NewEncoder(&buf).Encode(checks); err != nil {
+			return errors.Wrap(err, "failed to marshal status checks")
+		}
+	} else {
+		writeStatusChecks(&buf, checks)
+	}
+	ctx.Out.Print(buf.String())
+
+	var cerr statusCheckError
+	for _, c := range checks {
+		if c.Failed {
+			cerr.failed = append(cerr.failed, c.Check)
+			cerr.code += c.ExitCode
+		}
+	}
+	if cerr.code != 0 {
+		return cerr
+	}
+	return nil
+}
+
+// lockDrifted reports whether the inputs digest of the lock of p differs from
+// that of its manifest and the imports of ptree.
+func lockDrifted(p *dep.Project, ptree pkgtree.PackageTree, sm gps.SourceManager) (bool, error) {
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
+	s, err := gps.Prepare(params, sm)
+	if err != nil {
+		return false, errors.Wrap(err, "could not set up solver for input hashing")
+	}
+	return !bytes.Equal(s.HashInputs(), p.Lock.SolveMeta.InputsDigest), nil
+}
+
+// missingFromLock returns the projects of the imports which have no project in
+// l, sorted. Only the imports under no project of l are deduced.
+func missingFromLock(l *dep.Lock, imports []string, sm gps.SourceManager) ([]gps.ProjectRoot, error) {
+	missing := make(map[gps.ProjectRoot]bool)
+outer:
+	for _, pkg := range imports {
+		for _, lp := range l.Projects() {
+			if isPathPrefixOrEqual(string(lp.Ident().ProjectRoot), pkg) {
+				continue outer
+			}
+		}
+		root, err := sm.DeduceProjectRoot(pkg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not deduce project root for %s", pkg)
+		}
+		missing[root] = true
+	}
+
+	roots := make([]gps.ProjectRoot, 0, len(missing))
+	for root := range missing {
+		roots = append(roots, root)
+	}
+	sort.Sort(byProjectRoot(roots))
+	return roots, nil
+}
+
+// missingFromVendor returns the projects of l which have no directory in
+// vendorDir, sorted.
+func missingFromVendor(l *dep.Lock, vendorDir string) ([]gps.ProjectRoot, error) {
+	var roots []gps.ProjectRoot
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		fi, err := os.Stat(filepath.Join(vendorDir, filepath.FromSlash(string(pr))))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "could not check vendor/%s", pr)
+		}
+		if err != nil || !fi.IsDir() {
+			roots = append(roots, pr)
+		}
+	}
+	sort.Sort(byProjectRoot(roots))
+	return roots, nil
+}
+
+// writeStatusChecks prints the outcome of each check, followed by the projects
+// it failed on.
+func writeStatusChecks(w io.Writer, checks []statusCheck) {
+	for _, c := range checks {
+		switch {
+		case c.Check == "lock-drift" && c.Failed:
+			fmt.Fprintf(w, "lock-drift: the inputs digest of %s doesn't match %s and the imports of the project; run dep ensure\n", dep.LockName, dep.ManifestName)
+		case c.Check == "lock-drift":
+			fmt.Fprintf(w, "lock-drift: %s is in sync with %s and the imports of the project\n", dep.LockName, dep.ManifestName)
+		case c.Check == "missing" && c.Failed:
+			fmt.Fprintf(w, "missing: %d project(s) imported but missing from %s; run dep ensure\n", len(c.Projects), dep.LockName)
+		case c.Check == "missing":
+			fmt.Fprintf(w, "missing: every project imported is in %s\n", dep.LockName)
+		case c.Check == "vendor-missing" && c.Failed:
+			fmt.Fprintf(w, "vendor-missing: %d project(s) of %s missing from vendor/; run dep ensure -vendor-only\n", len(c.Projects), dep.LockName)
+		case c.Check == "vendor-missing":
+			fmt.Fprintf(w, "vendor-missing: every project of %s is in vendor/\n", dep.LockName)
+		}
+		for _, pr := range c.Projects {
+			fmt.Fprintf(w, "  %s\n", pr)
+		}
+	}
+}
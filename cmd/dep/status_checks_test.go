@@ -0,0 +1,65 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestStatusChecksMissing(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("vendor/github.com/foo/bar")
+	h.TempFile("vendor/github.com/foo/file", "")
+
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.Revision("aaa"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.Revision("bbb"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/file"}, gps.Revision("ccc"), []string{"."}),
+	}}
+
+	imports := []string{"github.com/foo/bar/sub", "github.com/foo/qux/a", "github.com/foo/qux/b", "golang.org/x/net/context"}
+	roots, err := missingFromLock(l, imports, gomodTestSM{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/qux", "golang.org/x/net"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("unexpected projects missing from the lock:\n\t(GOT): %v\n\t(WNT): %v", roots, want)
+	}
+
+	roots, err = missingFromVendor(l, h.Path("vendor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []gps.ProjectRoot{"github.com/foo/baz", "github.com/foo/file"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("unexpected projects missing from vendor/:\n\t(GOT): %v\n\t(WNT): %v", roots, want)
+	}
+}
+
+func TestWriteStatusChecks(t *testing.T) {
+	var buf bytes.Buffer
+	writeStatusChecks(&buf, []statusCheck{
+		{Check: "lock-drift", ExitCode: exitLockDrift},
+		{Check: "vendor-missing", Failed: true, ExitCode: exitVendorMissing, Projects: []gps.ProjectRoot{"github.com/foo/baz"}},
+	})
+	want := "lock-drift: Gopkg.lock is in sync with Gopkg.toml and the imports of the project\n" +
+		"vendor-missing: 1 project(s) of Gopkg.lock missing from vendor/; run dep ensure -vendor-only\n" +
+		"  github.com/foo/baz\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n\t(GOT): %q\n\t(WNT): %q", got, want)
+	}
+
+	err := statusCheckError{failed: []string{"lock-drift", "missing"}, code: exitLockDrift + exitLockMissing}
+	var ec exitCoder = err
+	if ec.exitCode() != 6 || err.Error() != "dep status: failed check(s): lock-drift, missing" {
+		t.Errorf("unexpected error %q with exit code %d", err, ec.exitCode())
+	}
+}
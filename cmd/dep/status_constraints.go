@@ -0,0 +1,194 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// constraintStatus is the outcome of dep status -constraints.
+type constraintStatus struct {
+	// Unused holds the projects constrained in the manifest which the project
+	// doesn't import or require directly.
+	Unused []gps.ProjectRoot
+	// Missing holds the projects imported or required directly which have
+	// no rules in the manifest.
+	Missing []missingConstraint
+}
+
+// missingConstraint is a project imported directly without a constraint.
+type missingConstraint struct {
+	ProjectRoot gps.ProjectRoot
+	// Constraint is the constraint -autofix adds, allowing the versions
+	// compatible with the locked one. It's empty for the projects locked to a
+	// bare revision, or not locked.
+	Constraint string `json:",omitempty"`
+}
+
+type byMissingRoot []missingConstraint
+
+func (s byMissingRoot) Len() int           { return len(s) }
+func (s byMissingRoot) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byMissingRoot) Less(i, j int) bool { return s[i].ProjectRoot < s[j].ProjectRoot }
+
+type byProjectRoot []gps.ProjectRoot
+
+func (s byProjectRoot) Len() int           { return len(s) }
+func (s byProjectRoot) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byProjectRoot) Less(i, j int) bool { return s[i] < s[j] }
+
+// checkConstraints compares the constraints of m with the packages the root
+// project imports directly. The constraints the missing ones would get are
+// taken from l, which may be nil.
+func checkConstraints(m *dep.Manifest, l *dep.Lock, imports []string, sm gps.SourceManager) (constraintStatus, *dep.Manifest, error) {
+	var cs constraintStatus
+	roots := make(map[gps.ProjectRoot]bool)
+	for _, pkg := range imports {
+		root, err := sm.DeduceProjectRoot(pkg)
+		if err != nil {
+			return cs, nil, errors.Wrapf(err, "could not deduce project root for %s", pkg)
+		}
+		roots[root] = true
+	}
+
+	for pr := range m.Constraints {
+		if roots[pr] {
+			continue
+		}
+		// A constraint naming a package still applies to its imported project.
+		imported := false
+		for _, pkg := range imports {
+			if isPathPrefixOrEqual(string(pr), pkg) {
+				imported = true
+				break
+			}
+		}
+		if !imported {
+			cs.Unused = append(cs.Unused, pr)
+		}
+	}
+	sort.Sort(byProjectRoot(cs.Unused))
+
+	appender := &dep.Manifest{Constraints: make(gps.ProjectConstraints)}
+	if l != nil {
+		appender = importConstraints(m, roots, l)
+	}
+	for pr := range roots {
+		if m.HasConstraintsOn(pr) {
+			continue
+		}
+		mc := missingConstraint{ProjectRoot: pr}
+		if pp, has := appender.Constraints[pr]; has {
+			mc.Constraint = pp.Constraint.String()
+		}
+		cs.Missing = append(cs.Missing, mc)
+	}
+	sort.Sort(byMissingRoot(cs.Missing))
+
+	return cs, appender, nil
+}
+
+// writeConstraintStatus prints the unused and missing constraints as tables.
+func writeConstraintStatus(w io.Writer, cs constraintStatus) {
+	if len(cs.Unused) == 0 && len(cs.Missing) == 0 {
+		fmt.Fprintf(w, "No unused or missing constraints in %s.\n", dep.ManifestName)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(cs.Unused) > 0 {
+		fmt.Fprintln(tw, "Unused constraints, on projects not imported directly:")
+		for _, pr := range cs.Unused {
+			fmt.Fprintf(tw, "  %s\n", pr)
+		}
+	}
+	if len(cs.Missing) > 0 {
+		fmt.Fprintln(tw, "Missing constraints, on projects imported directly:")
+		for _, mc := range cs.Missing {
+			c := mc.Constraint
+			if c == "" {
+				c = "- (not locked to a version, add one by hand)"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", mc.ProjectRoot, c)
+		}
+	}
+	tw.Flush()
+}
+
+// runStatusConstraints reports the constraints of the manifest of p on the
+// projects it doesn't import directly, and the projects it imports directly
+// without a constraint. With autofix, the former are removed from the
+// manifest, and the latter added to it when they're locked to a version.
+func runStatusConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, asJSON, autofix bool) error {
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return errors.Wrap(err, "analysis of local packages failed")
+	}
+
+	cs, appender, err := checkConstraints(p.Manifest, p.Lock, directImports(ptree, p.Manifest, p.ImportRoot), sm)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if asJSON {
+		if cs.Unused == nil {
+			cs.Unused = []gps.ProjectRoot{}
+		}
+		if cs.Missing == nil {
+			cs.Missing = []missingConstraint{}
+		}
+		if err := json.NewEncoder(&buf).Encode(cs); err != nil {
+			return errors.Wrap(err, "failed to marshal constraint status")
+		}
+	} else {
+		writeConstraintStatus(&buf, cs)
+	}
+	ctx.Out.Print(buf.String())
+
+	if !autofix {
+		if len(cs.Unused) > 0 || len(cs.Missing) > 0 {
+			return errors.Errorf("found %d unused and %d missing constraint(s) in %s; run dep status -constraints -autofix to fix them", len(cs.Unused), len(cs.Missing), dep.ManifestName)
+		}
+		return nil
+	}
+
+	for _, pr := range cs.Unused {
+		delete(p.Manifest.Constraints, pr)
+	}
+	for pr, pp := range appender.Constraints {
+		p.Manifest.Constraints[pr] = pp
+	}
+	if len(cs.Unused) == 0 && len(appender.Constraints) == 0 {
+		return nil
+	}
+
+	sw, err := dep.NewSafeWriter(p.Manifest, nil, nil, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	if err := sw.Write(p.AbsRoot, sm, false, logger); err != nil {
+		return errors.Wrapf(err, "failed to write %s", dep.ManifestName)
+	}
+	ctx.Err.Printf("Removed %d unused and added %d missing constraint(s) to %s. Run dep ensure to bring %s in sync with it.", len(cs.Unused), len(appender.Constraints), dep.ManifestName, dep.LockName)
+	return nil
+}
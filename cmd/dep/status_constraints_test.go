@@ -0,0 +1,80 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestCheckConstraints(t *testing.T) {
+	m := &dep.Manifest{
+		Constraints: gps.ProjectConstraints{
+			"github.com/used/a":     gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+			"github.com/unused/b":   gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+			"github.com/used/c/pkg": gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+		},
+		Ovr: gps.ProjectConstraints{
+			"github.com/overridden/d": gps.ProjectProperties{Constraint: gps.NewBranch("master")},
+		},
+	}
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/missing/e", Source: "https://example.com/e"}, gps.NewVersion("v1.2.0").Pair("eee"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/missing/f"}, gps.Revision("fff"), []string{"."}),
+	}}
+	imports := []string{
+		"github.com/used/a",
+		"github.com/used/c/pkg",
+		"github.com/overridden/d",
+		"github.com/missing/e/sub",
+		"github.com/missing/f",
+	}
+
+	cs, appender, err := checkConstraints(m, l, imports, gomodTestSM{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.Unused) != 1 || cs.Unused[0] != "github.com/unused/b" {
+		t.Errorf("unexpected unused constraints: %v", cs.Unused)
+	}
+	wantMissing := []missingConstraint{
+		{ProjectRoot: "github.com/missing/e", Constraint: "^1.2.0"},
+		{ProjectRoot: "github.com/missing/f"},
+		{ProjectRoot: "github.com/used/c"},
+	}
+	if len(cs.Missing) != len(wantMissing) {
+		t.Fatalf("expected %d missing constraints, got %v", len(wantMissing), cs.Missing)
+	}
+	for i, want := range wantMissing {
+		if cs.Missing[i] != want {
+			t.Errorf("unexpected missing constraint %d: %+v, wanted %+v", i, cs.Missing[i], want)
+		}
+	}
+	if pp := appender.Constraints["github.com/missing/e"]; len(appender.Constraints) != 1 || pp.Source != "https://example.com/e" {
+		t.Errorf("unexpected constraints to add: %v", appender.Constraints)
+	}
+
+	var buf bytes.Buffer
+	writeConstraintStatus(&buf, cs)
+	want := `Unused constraints, on projects not imported directly:
+  github.com/unused/b
+Missing constraints, on projects imported directly:
+  github.com/missing/e  ^1.2.0
+  github.com/missing/f  - (not locked to a version, add one by hand)
+  github.com/used/c     - (not locked to a version, add one by hand)
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeConstraintStatus(&buf, constraintStatus{})
+	if buf.String() != "No unused or missing constraints in Gopkg.toml.\n" {
+		t.Errorf("unexpected output without problems: %q", buf.String())
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// projectStatus is the detailed status of a project of the lock, which dep
+// status prints for the projects it's given.
+type projectStatus struct {
+	ProjectRoot string
+	Source      string `json:",omitempty"`
+	// Constraint is the rule of the manifest on the project, and
+	// ConstraintSource where it comes from: "override" or "constraint", or
+	// "none" if the manifest has no rules on it.
+	Constraint       string
+	ConstraintSource string
+	// Hints holds the constraints the other projects of the lock declare on
+	// the project in their own manifests.
+	Hints []constraintHint `json:",omitempty"`
+
+	Version  string
+	Revision gps.Revision
+	// LatestMatching is the newest version the constraint allows, and Latest
+	// the newest one overall.
+	LatestMatching string
+	Latest         string
+
+	// Packages holds the packages of the project used, from the lock.
+	Packages []string
+	// ImportChains holds, for each package of the root project which imports
+	// the project, directly or not, the shortest chain of imports from it to
+	// a package of the project.
+	ImportChains [][]string
+	// TestOnly is set when the project is only reached through the test
+	// imports of the root project.
+	TestOnly bool
+}
+
+// resolveLockedProjects returns the roots of the projects of l the given
+// import paths belong to, in order.
+func resolveLockedProjects(l *dep.Lock, args []string) ([]gps.ProjectRoot, error) {
+	var roots []gps.ProjectRoot
+	for _, arg := range args {
+		arg = strings.TrimSuffix(arg, "/")
+		var root gps.ProjectRoot
+		for _, lp := range l.Projects() {
+			pr := lp.Ident().ProjectRoot
+			if isPathPrefixOrEqual(string(pr), arg) && len(pr) > len(root) {
+				root = pr
+			}
+		}
+		if root == "" {
+			return nil, errors.Errorf("%s is not in any project of %s", arg, dep.LockName)
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// runStatusProjects prints the detailed status of the projects of the lock of
+// p with the given roots, or of all of them if there are none.
+func runStatusProjects(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, roots []gps.ProjectRoot, asJSON bool) error {
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+	if len(roots) == 0 {
+		for _, lp := range p.Lock.Projects() {
+			roots = append(roots, lp.Ident().ProjectRoot)
+		}
+	}
+
+	trees, err := collectPackageTrees(p, sm)
+	if err != nil {
+		return err
+	}
+	ignored := p.Manifest.IgnoredPackages()
+	g := packageGraph(trees, p.ImportRoot, ignored, false, false)
+	testGraph := packageGraph(trees, p.ImportRoot, ignored, false, true)
+	hints := collectConstraintHints(ctx, p.Lock, sm)
+
+	var statuses []projectStatus
+	for _, pr := range roots {
+		var lp gps.LockedProject
+		for _, l := range p.Lock.Projects() {
+			if l.Ident().ProjectRoot == pr {
+				lp = l
+			}
+		}
+
+		ps := projectStatus{
+			ProjectRoot: string(pr),
+			Source:      lp.Ident().Source,
+			Hints:       hints[pr],
+			Packages:    lp.Packages(),
+		}
+
+		c := gps.Any()
+		if pp, has := p.Manifest.Ovr[pr]; has && pp.Constraint != nil {
+			c, ps.ConstraintSource = pp.Constraint, "override"
+		} else if pp, has := p.Manifest.Constraints[pr]; has && pp.Constraint != nil {
+			c, ps.ConstraintSource = pp.Constraint, "constraint"
+		} else {
+			ps.ConstraintSource = "none"
+		}
+		ps.Constraint = c.String()
+
+		switch v := lp.Version().(type) {
+		case gps.PairedVersion:
+			ps.Version, ps.Revision = formatVersion(v.Unpair()), v.Revision()
+		case gps.Revision:
+			ps.Revision = v
+		case gps.UnpairedVersion:
+			ps.Version = formatVersion(v)
+		}
+
+		if v, _ := latestMatchingVersion(sm, lp.Ident(), c); v != nil {
+			ps.LatestMatching = formatVersion(v.Unpair())
+		}
+		if v, _ := latestMatchingVersion(sm, lp.Ident(), gps.Any()); v != nil {
+			ps.Latest = formatVersion(v.Unpair())
+		}
+
+		inProject := func(ip string) bool { return isPathPrefixOrEqual(string(pr), ip) }
+		ps.ImportChains = shortestImportChains(g, p.ImportRoot, inProject)
+		if len(ps.ImportChains) == 0 {
+			ps.ImportChains = shortestImportChains(testGraph, p.ImportRoot, inProject)
+			ps.TestOnly = len(ps.ImportChains) > 0
+		}
+
+		statuses = append(statuses, ps)
+	}
+
+	var buf bytes.Buffer
+	if asJSON {
+		if err := json.NewEncoder(&buf).Encode(statuses); err != nil {
+			return errors.Wrap(err, "failed to marshal the status")
+		}
+	} else {
+		writeProjectStatuses(&buf, statuses)
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// writeProjectStatuses writes the detailed statuses to w, one block each.
+func writeProjectStatuses(w io.Writer, statuses []projectStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, ps := range statuses {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "PROJECT\t%s\n", ps.ProjectRoot)
+		if ps.Source != "" {
+			fmt.Fprintf(tw, "SOURCE\t%s\n", ps.Source)
+		}
+		switch ps.ConstraintSource {
+		case "none":
+			fmt.Fprintf(tw, "CONSTRAINT\t%s (no rules in %s)\n", ps.Constraint, dep.ManifestName)
+		default:
+			fmt.Fprintf(tw, "CONSTRAINT\t%s (%s in %s)\n", ps.Constraint, ps.ConstraintSource, dep.ManifestName)
+		}
+		for _, h := range ps.Hints {
+			fmt.Fprintf(tw, "\t%s (declared by %s)\n", h.Constraint, h.From)
+		}
+		fmt.Fprintf(tw, "VERSION\t%s\n", orDash(ps.Version))
+		fmt.Fprintf(tw, "REVISION\t%s\n", orDash(string(ps.Revision)))
+		fmt.Fprintf(tw, "LATEST MATCHING\t%s\n", orDash(ps.LatestMatching))
+		fmt.Fprintf(tw, "LATEST\t%s\n", orDash(ps.Latest))
+		fmt.Fprintf(tw, "PACKAGES\t%s\n", strings.Join(ps.Packages, ", "))
+		if len(ps.ImportChains) == 0 {
+			fmt.Fprintf(tw, "IMPORTED BY\t-\n")
+		}
+		for j, chain := range ps.ImportChains {
+			label := ""
+			if j == 0 {
+				label = "IMPORTED BY"
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", label, strings.Join(chain, " -> "))
+		}
+		testOnly := "no"
+		if ps.TestOnly {
+			testOnly = "yes"
+		}
+		fmt.Fprintf(tw, "TEST ONLY\t%s\n", testOnly)
+	}
+	tw.Flush()
+}
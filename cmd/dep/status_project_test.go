@@ -0,0 +1,97 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestResolveLockedProjects(t *testing.T) {
+	l := &dep.Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar/v2"}, gps.NewVersion("v2.0.0"), []string{"."}),
+	}}
+
+	got, err := resolveLockedProjects(l, []string{"github.com/foo/bar/v2/sub", "github.com/foo/bar/", "github.com/foo/bar/pkg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gps.ProjectRoot{"github.com/foo/bar/v2", "github.com/foo/bar", "github.com/foo/bar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected projects %v, want %v", got, want)
+	}
+
+	if _, err := resolveLockedProjects(l, []string{"github.com/foo/barbaz"}); err == nil {
+		t.Error("expected an error for a package of no locked project")
+	}
+}
+
+func TestWriteProjectStatuses(t *testing.T) {
+	semver, err := gps.NewSemverConstraintIC("1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []projectStatus{
+		{
+			ProjectRoot:      "github.com/foo/bar",
+			Constraint:       "^1.0.0",
+			ConstraintSource: "constraint",
+			Hints:            []constraintHint{{From: "github.com/x/y", Constraint: semver}},
+			Version:          "v1.0.0",
+			Revision:         "ff2948a2ac8f538c4ecd55962e919d1e13e74baf",
+			LatestMatching:   "v1.2.0",
+			Latest:           "v2.0.0",
+			Packages:         []string{".", "sub"},
+			ImportChains: [][]string{
+				{"example.com/root", "github.com/foo/bar"},
+				{"example.com/root/cmd", "github.com/x/y", "github.com/foo/bar/sub"},
+			},
+		},
+		{
+			ProjectRoot:      "github.com/test/only",
+			Source:           "https://example.com/only.git",
+			Constraint:       "*",
+			ConstraintSource: "none",
+			Revision:         "d05d5aca9f895d19e9265839bffeadd74a2d2ecb",
+			Packages:         []string{"."},
+			ImportChains:     [][]string{{"example.com/root", "github.com/test/only"}},
+			TestOnly:         true,
+		},
+	}
+
+	var buf bytes.Buffer
+	writeProjectStatuses(&buf, statuses)
+	want := `PROJECT          github.com/foo/bar
+CONSTRAINT       ^1.0.0 (constraint in Gopkg.toml)
+                 ^1.0.0 (declared by github.com/x/y)
+VERSION          v1.0.0
+REVISION         ff2948a2ac8f538c4ecd55962e919d1e13e74baf
+LATEST MATCHING  v1.2.0
+LATEST           v2.0.0
+PACKAGES         ., sub
+IMPORTED BY      example.com/root -> github.com/foo/bar
+                 example.com/root/cmd -> github.com/x/y -> github.com/foo/bar/sub
+TEST ONLY        no
+
+PROJECT          github.com/test/only
+SOURCE           https://example.com/only.git
+CONSTRAINT       * (no rules in Gopkg.toml)
+VERSION          -
+REVISION         d05d5aca9f895d19e9265839bffeadd74a2d2ecb
+LATEST MATCHING  -
+LATEST           -
+PACKAGES         .
+IMPORTED BY      example.com/root -> github.com/test/only
+TEST ONLY        yes
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected status:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
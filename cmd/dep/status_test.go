@@ -6,6 +6,8 @@ package main
 
 import (
 	"bytes"
+	"io/ioutil"
+	"log"
 	"testing"
 	"text/tabwriter"
 
@@ -13,6 +15,8 @@ import (
 
 	"github.com/golang/dep"
 	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/golang/dep/internal/test"
 )
 
 func TestStatusFormatVersion(t *testing.T) {
@@ -32,6 +36,38 @@ func TestStatusFormatVersion(t *testing.T) {
 	}
 }
 
+func TestStatusOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cmd     statusCommand
+		want    string
+		wantErr bool
+	}{
+		{cmd: statusCommand{}, want: "text"},
+		{cmd: statusCommand{output: "dot"}, want: "dot"},
+		{cmd: statusCommand{json: true}, want: "json"},
+		{cmd: statusCommand{dot: true, output: "dot"}, want: "dot"},
+		{cmd: statusCommand{json: true, output: "dot"}, wantErr: true},
+		{cmd: statusCommand{json: true, dot: true}, wantErr: true},
+		{cmd: statusCommand{output: "yaml"}, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := test.cmd.outputFormat()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%+v: expected an error, got format %s", test.cmd, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.cmd, err)
+		} else if got != test.want {
+			t.Errorf("%+v: expected format %s, got %s", test.cmd, test.want, got)
+		}
+	}
+}
+
 func TestBasicLine(t *testing.T) {
 	project := dep.Project{}
 	aSemverConstraint, _ := gps.NewSemverConstraint("1.2.3")
@@ -82,9 +118,22 @@ func TestBasicLine(t *testing.T) {
 				Revision:    gps.Revision("revxyz"),
 			},
 			wantDotStatus:   []string{`[label="github.com/foo/bar\n1.0.0"];`},
-			wantJSONStatus:  []string{`"Revision":"revxyz"`, `"Constraint":"1.2.3"`, `"Version":"1.0.0"`},
+			wantJSONStatus:  []string{`"Revision":"revxyz"`, `"Constraint":"1.2.3"`, `"Override":false`, `"Version":"1.0.0"`},
 			wantTableStatus: []string{`github.com/foo/bar  1.2.3       1.0.0    revxyz            0`},
 		},
+		{
+			name: "BasicStatus with Override, Version and Revision",
+			status: BasicStatus{
+				ProjectRoot: "github.com/foo/bar",
+				Constraint:  aSemverConstraint,
+				Version:     gps.NewVersion("1.0.0"),
+				Revision:    gps.Revision("revxyz"),
+				hasOverride: true,
+			},
+			wantDotStatus:   []string{`[label="github.com/foo/bar\n1.0.0"];`},
+			wantJSONStatus:  []string{`"Constraint":"1.2.3 (override)"`, `"Override":true`},
+			wantTableStatus: []string{`github.com/foo/bar  1.2.3 (override)  1.0.0    revxyz            0`},
+		},
 	}
 
 	for _, test := range tests {
@@ -220,3 +269,39 @@ func TestBasicStatusGetConsolidatedVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusGoVersionInSync(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("src/example.com/proj/main.go", "package main\n\nfunc main() {}\n")
+	h.TempDir("cache")
+	p := &dep.Project{
+		ImportRoot: "example.com/proj",
+		Manifest:   &dep.Manifest{GoVersion: "1.7"},
+		Lock:       &dep.Lock{},
+	}
+	h.Must(p.SetRoot(h.Path("src/example.com/proj")))
+
+	sm, err := gps.NewSourceManager(h.Path("cache"))
+	h.Must(err)
+	defer sm.Release()
+
+	// The lock is written with the digest of dep ensure.
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	h.Must(err)
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
+	s, err := gps.Prepare(params, sm)
+	h.Must(err)
+	p.Lock.SolveMeta.InputsDigest = s.HashInputs()
+
+	ctx := &dep.Ctx{Out: log.New(ioutil.Discard, "", 0), Err: log.New(ioutil.Discard, "", 0)}
+	var buf bytes.Buffer
+	out := &jsonOutput{w: &buf}
+	digestMismatch, _, err := runStatusAll(ctx, out, p, sm, nil)
+	h.Must(err)
+	if digestMismatch {
+		t.Error("expected the lock of a project with a go-version to be in sync")
+	}
+}
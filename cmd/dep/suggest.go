@@ -0,0 +1,255 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const suggestShortHelp = `Suggest a version constraint for a dependency`
+const suggestLongHelp = `
+Suggest the version constraint for a project that is most likely to remain
+solvable, based on the project's release history and the constraints that the
+other dependencies recorded in Gopkg.lock place on it.
+
+The suggestion is a caret constraint on the newest non-prerelease semver tag
+that every dependency constraining the project accepts. Newer majors which
+some dependency rejects are reported, along with the dependencies at fault.
+
+With -write, the suggested constraint is written into Gopkg.toml, replacing any
+existing constraint on the project.
+`
+
+type suggestCommand struct {
+	write bool
+}
+
+func (cmd *suggestCommand) Name() string      { return "suggest" }
+func (cmd *suggestCommand) Args() string      { return "<project>" }
+func (cmd *suggestCommand) ShortHelp() string { return suggestShortHelp }
+func (cmd *suggestCommand) LongHelp() string  { return suggestLongHelp }
+func (cmd *suggestCommand) Hidden() bool      { return false }
+
+func (cmd *suggestCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.write, "write", false, "write the suggested constraint into Gopkg.toml")
+}
+
+func (cmd *suggestCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("dep suggest takes exactly one project argument")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pr, err := sm.DeduceProjectRoot(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "could not infer project root from %s", args[0])
+	}
+	if string(pr) != args[0] {
+		return errors.Errorf("%s is not a project root, try %s instead", args[0], pr)
+	}
+
+	id := gps.ProjectIdentifier{ProjectRoot: pr}
+	if pp, has := p.Manifest.Constraints[pr]; has {
+		id.Source = pp.Source
+	}
+
+	versions, err := sm.ListVersions(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list versions for %s", id)
+	}
+
+	downstream := collectDownstreamConstraints(ctx, p, sm, pr)
+	s, err := suggestConstraint(versions, downstream)
+	if err != nil {
+		return errors.Wrapf(err, "unable to suggest a constraint for %s", pr)
+	}
+
+	var buf bytes.Buffer
+	s.write(&buf, pr)
+	ctx.Out.Print(buf.String())
+
+	if !cmd.write {
+		return nil
+	}
+
+	if _, has := p.Manifest.Ovr[pr]; has {
+		ctx.Err.Printf("Warning: %s has an override in %s, which takes precedence over the written constraint", pr, dep.ManifestName)
+	}
+	p.Manifest.Constraints[pr] = gps.ProjectProperties{
+		Source:     id.Source,
+		Constraint: s.Constraint,
+	}
+
+	sw, err := dep.NewSafeWriter(p.Manifest, nil, nil, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	return errors.Wrapf(sw.Write(p.AbsRoot, sm, false, logger), "failed to write %s", dep.ManifestName)
+}
+
+// collectDownstreamConstraints returns the constraints declared on the project
+// pr by the manifests of the other projects in the lock, keyed by the root of
+// the declaring project. Projects whose manifest can't be retrieved are
+// skipped.
+func collectDownstreamConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, pr gps.ProjectRoot) map[gps.ProjectRoot]gps.Constraint {
+	downstream := make(map[gps.ProjectRoot]gps.Constraint)
+	if p.Lock == nil {
+		return downstream
+	}
+
+	for _, lp := range p.Lock.Projects() {
+		if lp.Ident().ProjectRoot == pr {
+			continue
+		}
+
+		m, _, err := sm.GetManifestAndLock(lp.Ident(), lp.Version(), dep.Analyzer{})
+		if err != nil {
+			if ctx.Verbose {
+				ctx.Err.Printf("Unable to read the manifest of %s: %s", lp.Ident(), err)
+			}
+			continue
+		}
+		if m == nil {
+			continue
+		}
+
+		if pp, has := m.DependencyConstraints()[pr]; has && pp.Constraint != nil && !gps.IsAny(pp.Constraint) {
+			downstream[lp.Ident().ProjectRoot] = pp.Constraint
+		}
+	}
+
+	return downstream
+}
+
+// constraintSuggestion is the outcome of suggestConstraint.
+type constraintSuggestion struct {
+	// Constraint is the suggested constraint.
+	Constraint gps.Constraint
+	// Newest is the newest release of the project.
+	Newest gps.Version
+	// Downstream holds the constraints placed on the project by other
+	// dependencies.
+	Downstream map[gps.ProjectRoot]gps.Constraint
+	// Rejecting lists the dependencies whose constraints reject Newest.
+	Rejecting []gps.ProjectRoot
+}
+
+// suggestConstraint picks a caret constraint on the newest release in versions
+// that is accepted by all the downstream constraints. Only non-prerelease
+// semver versions are considered releases.
+func suggestConstraint(versions []gps.PairedVersion, downstream map[gps.ProjectRoot]gps.Constraint) (constraintSuggestion, error) {
+	s := constraintSuggestion{Downstream: downstream}
+
+	vl := make([]gps.PairedVersion, 0, len(versions))
+	for _, v := range versions {
+		if v.Type() != gps.IsSemver {
+			continue
+		}
+		if sv, err := semver.NewVersion(v.String()); err != nil || sv.Prerelease() != "" {
+			continue
+		}
+		vl = append(vl, v)
+	}
+	if len(vl) == 0 {
+		return s, errors.New("the project has no semver releases; consider constraining it to a branch instead")
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	s.Newest = vl[0].Unpair()
+	s.Rejecting = rejectingConstraints(vl[0], downstream)
+
+	for _, v := range vl {
+		if len(rejectingConstraints(v, downstream)) != 0 {
+			continue
+		}
+
+		c, err := gps.NewSemverConstraint("^" + strings.TrimPrefix(v.String(), "v"))
+		if err != nil {
+			return s, err
+		}
+		s.Constraint = c
+		return s, nil
+	}
+
+	return s, errors.New("no release satisfies the constraints of all dependencies")
+}
+
+// rejectingConstraints returns the sorted roots of the projects whose
+// constraint doesn't allow v.
+func rejectingConstraints(v gps.Version, downstream map[gps.ProjectRoot]gps.Constraint) []gps.ProjectRoot {
+	var rejecting []string
+	for pr, c := range downstream {
+		if !c.Matches(v) {
+			rejecting = append(rejecting, string(pr))
+		}
+	}
+	sort.Strings(rejecting)
+
+	roots := make([]gps.ProjectRoot, len(rejecting))
+	for i, pr := range rejecting {
+		roots[i] = gps.ProjectRoot(pr)
+	}
+	return roots
+}
+
+func (s constraintSuggestion) write(w io.Writer, pr gps.ProjectRoot) {
+	fmt.Fprintf(w, "Suggested constraint for %s: %s\n", pr, s.Constraint)
+	fmt.Fprintf(w, "Newest release: %s\n", s.Newest)
+
+	if len(s.Downstream) == 0 {
+		fmt.Fprintln(w, "No other dependency constrains this project.")
+		return
+	}
+
+	roots := make([]string, 0, len(s.Downstream))
+	for pr := range s.Downstream {
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+
+	fmt.Fprintln(w, "\nConstraints from other dependencies:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range roots {
+		fmt.Fprintf(tw, "  %s\t%s\t\n", r, s.Downstream[gps.ProjectRoot(r)])
+	}
+	tw.Flush()
+
+	if len(s.Rejecting) > 0 {
+		rejecting := make([]string, len(s.Rejecting))
+		for i, pr := range s.Rejecting {
+			rejecting[i] = string(pr)
+		}
+		fmt.Fprintf(w, "\n%s is not allowed by: %s\n", s.Newest, strings.Join(rejecting, ", "))
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestSuggestConstraint(t *testing.T) {
+	versions := []gps.PairedVersion{
+		gps.NewVersion("v1.0.0").Pair("r100"),
+		gps.NewVersion("v1.4.0").Pair("r140"),
+		gps.NewVersion("v1.5.0").Pair("r150"),
+		gps.NewVersion("v2.0.0").Pair("r200"),
+		gps.NewVersion("v2.1.0-rc1").Pair("r210"),
+		gps.NewBranch("master").Pair("rmaster"),
+	}
+
+	mustConstraint := func(body string) gps.Constraint {
+		c, err := gps.NewSemverConstraint(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	s, err := suggestConstraint(versions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Constraint.String(); got != "^2.0.0" {
+		t.Errorf("expected ^2.0.0 without downstream constraints, got %s", got)
+	}
+
+	downstream := map[gps.ProjectRoot]gps.Constraint{
+		"github.com/a/a": mustConstraint("^1.2.0"),
+		"github.com/b/b": mustConstraint(">=1.0.0, <1.5.0"),
+		"github.com/c/c": mustConstraint(">=1.0.0"),
+	}
+	s, err = suggestConstraint(versions, downstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Constraint.String(); got != "^1.4.0" {
+		t.Errorf("expected ^1.4.0, got %s", got)
+	}
+	if want := []gps.ProjectRoot{"github.com/a/a", "github.com/b/b"}; !reflect.DeepEqual(s.Rejecting, want) {
+		t.Errorf("expected %v to reject the newest release, got %v", want, s.Rejecting)
+	}
+
+	var buf bytes.Buffer
+	s.write(&buf, "github.com/foo/bar")
+	for _, want := range []string{
+		"Suggested constraint for github.com/foo/bar: ^1.4.0",
+		"Newest release: v2.0.0",
+		"github.com/b/b  >=1.0.0, <1.5.0",
+		"v2.0.0 is not allowed by: github.com/a/a, github.com/b/b",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	downstream["github.com/d/d"] = mustConstraint("^3.0.0")
+	if _, err := suggestConstraint(versions, downstream); err == nil {
+		t.Error("expected an error when no release satisfies all dependencies")
+	}
+
+	if _, err := suggestConstraint([]gps.PairedVersion{gps.NewBranch("master").Pair("rmaster")}, nil); err == nil {
+		t.Error("expected an error when the project has no semver releases")
+	}
+}
@@ -0,0 +1,438 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/paths"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const tidyShortHelp = `Detect and remove unused dependencies`
+const tidyLongHelp = `
+Compare Gopkg.toml, Gopkg.lock and vendor/ against the import graph of the
+project, and report:
+
+  * projects which are constrained, locked or vendored, but which nothing
+    imports or requires anymore
+  * ignored packages which nothing imports anymore
+  * required packages which are also ignored
+  * directories in vendor/ which don't belong to any project in Gopkg.lock
+
+With -fix, the unused projects are removed from Gopkg.toml, Gopkg.lock and
+vendor/, along with the vendored directories not in Gopkg.lock. Ignored and
+required packages are only reported, as they may be kept on purpose.
+
+Gopkg.lock must be in sync with the project for tidy to tell which projects
+are unused; run dep ensure first if it isn't.
+`
+
+type tidyCommand struct {
+	fix bool
+}
+
+func (cmd *tidyCommand) Name() string      { return "tidy" }
+func (cmd *tidyCommand) Args() string      { return "" }
+func (cmd *tidyCommand) ShortHelp() string { return tidyShortHelp }
+func (cmd *tidyCommand) LongHelp() string  { return tidyLongHelp }
+func (cmd *tidyCommand) Hidden() bool      { return false }
+
+func (cmd *tidyCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.fix, "fix", false, "remove the unused projects from Gopkg.toml, Gopkg.lock and vendor/")
+}
+
+func (cmd *tidyCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("dep tidy takes no arguments")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("%s must exist for tidy to know which projects are in use", dep.LockName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	ptree, err := pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot))
+	if err != nil {
+		return errors.Wrap(err, "analysis of local packages failed")
+	}
+
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
+	s, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "could not set up solver for input hashing")
+	}
+	if !bytes.Equal(s.HashInputs(), p.Lock.SolveMeta.InputsDigest) {
+		return errors.Errorf("%s is out of sync; run dep ensure before tidying", dep.LockName)
+	}
+
+	r, err := collectTidyReport(p, ptree, sm)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	r.write(&buf, cmd.fix)
+	ctx.Out.Print(buf.String())
+
+	if !cmd.fix || (len(r.Projects) == 0 && len(r.OrphanedVendor) == 0) {
+		return nil
+	}
+
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	return r.fix(p, ptree, sm, logger)
+}
+
+// tidyProject is a project which nothing uses anymore, and the places it is
+// still recorded in.
+type tidyProject struct {
+	ProjectRoot gps.ProjectRoot
+	InManifest  bool
+	InLock      bool
+	InVendor    bool
+}
+
+// tidyReport holds everything dep tidy found to be unused or inconsistent.
+type tidyReport struct {
+	Projects        []tidyProject
+	StaleIgnores    []string
+	IgnoredRequired []string
+	// OrphanedVendor holds the slash-separated paths, relative to vendor/, of
+	// the directories which don't belong to any project in the lock.
+	OrphanedVendor []string
+}
+
+// collectTidyReport compares the manifest, lock and vendor directory of the
+// project against its import graph.
+func collectTidyReport(p *dep.Project, ptree pkgtree.PackageTree, sm gps.SourceManager) (tidyReport, error) {
+	var r tidyReport
+
+	unused, err := dep.UnusedProjects(ptree, p.Manifest, p.Lock, sm)
+	if err != nil {
+		return r, err
+	}
+
+	locked := make(map[gps.ProjectRoot]bool)
+	for _, lp := range p.Lock.Projects() {
+		locked[lp.Ident().ProjectRoot] = true
+	}
+
+	byRoot := make(map[gps.ProjectRoot]*tidyProject)
+	for pr := range unused {
+		byRoot[pr] = &tidyProject{ProjectRoot: pr, InLock: true}
+	}
+	for pr := range p.Manifest.Constraints {
+		// A constraint on a project missing from an up to date lock is a
+		// constraint on a project nothing imports.
+		if !unused[pr] && locked[pr] {
+			continue
+		}
+		if _, has := byRoot[pr]; !has {
+			byRoot[pr] = &tidyProject{ProjectRoot: pr}
+		}
+		byRoot[pr].InManifest = true
+	}
+
+	vendorDir := p.VendorDir()
+	roots := make([]string, 0, len(byRoot))
+	for pr, tp := range byRoot {
+		if fi, err := os.Stat(filepath.Join(vendorDir, filepath.FromSlash(string(pr)))); err == nil && fi.IsDir() {
+			tp.InVendor = true
+		}
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+	for _, pr := range roots {
+		r.Projects = append(r.Projects, *byRoot[gps.ProjectRoot(pr)])
+	}
+
+	imported, err := importedPackages(ptree, p.Lock, sm)
+	if err != nil {
+		return r, err
+	}
+	ignored := pkgtree.NewIgnoreRules(p.Manifest.IgnoredPackages())
+	for _, pkg := range p.Manifest.Ignored {
+		if !ignoreApplies(pkg, imported) {
+			r.StaleIgnores = append(r.StaleIgnores, pkg)
+		}
+	}
+	for _, pkg := range p.Manifest.Required {
+		if ignored.IsIgnored(pkg) {
+			r.IgnoredRequired = append(r.IgnoredRequired, pkg)
+		}
+	}
+	sort.Strings(r.StaleIgnores)
+	sort.Strings(r.IgnoredRequired)
+
+	lockedRoots := make([]gps.ProjectRoot, 0, len(locked))
+	for pr := range locked {
+		lockedRoots = append(lockedRoots, pr)
+	}
+	r.OrphanedVendor, err = orphanedVendorDirs(vendorDir, lockedRoots)
+	return r, err
+}
+
+// importedPackages returns the set of all the packages imported by the root
+// project, tests included, and by the locked packages of its dependencies,
+// regardless of ignores.
+func importedPackages(ptree pkgtree.PackageTree, l *dep.Lock, sm gps.SourceManager) (map[string]bool, error) {
+	imported := make(map[string]bool)
+
+	rm, _ := ptree.ToReachMap(true, true, false, nil)
+	for _, pkg := range rm.FlattenFn(paths.IsStandardImportPath) {
+		imported[pkg] = true
+	}
+
+	for _, lp := range l.Projects() {
+		lptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list packages of %s", lp.Ident().ProjectRoot)
+		}
+
+		for _, pkg := range lp.Packages() {
+			ip := path.Join(string(lp.Ident().ProjectRoot), pkg)
+			if poe, has := lptree.Packages[ip]; has && poe.Err == nil {
+				for _, imp := range poe.P.Imports {
+					imported[imp] = true
+				}
+			}
+		}
+	}
+
+	return imported, nil
+}
+
+// ignoreApplies reports whether the ignore list entry, an import path or a
+// pattern, matches one of the imported packages.
+func ignoreApplies(entry string, imported map[string]bool) bool {
+	if !pkgtree.IsIgnorePattern(entry) {
+		return imported[entry]
+	}
+	rules := pkgtree.NewIgnoreRules(map[string]bool{strings.TrimPrefix(entry, "!"): true})
+	for pkg := range imported {
+		if rules.IsIgnored(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanedVendorDirs walks vendorDir and returns the slash-separated paths of
+// the outermost directories which neither belong to one of the given project
+// roots, nor lead to one.
+func orphanedVendorDirs(vendorDir string, roots []gps.ProjectRoot) ([]string, error) {
+	if _, err := os.Stat(vendorDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var orphaned []string
+	err := filepath.Walk(vendorDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || p == vendorDir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(vendorDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, pr := range roots {
+			if rel == string(pr) {
+				return filepath.SkipDir
+			}
+			if strings.HasPrefix(string(pr), rel+"/") {
+				// An ancestor of a locked project; keep walking down.
+				return nil
+			}
+		}
+
+		orphaned = append(orphaned, rel)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk vendor directory")
+	}
+
+	sort.Strings(orphaned)
+	return orphaned, nil
+}
+
+func (r tidyReport) write(w io.Writer, fix bool) {
+	if len(r.Projects) == 0 && len(r.StaleIgnores) == 0 && len(r.IgnoredRequired) == 0 && len(r.OrphanedVendor) == 0 {
+		fmt.Fprintln(w, "Nothing to tidy.")
+		return
+	}
+
+	var sections int
+	section := func(title string) {
+		if sections > 0 {
+			fmt.Fprintln(w)
+		}
+		sections++
+		fmt.Fprintln(w, title)
+	}
+
+	if len(r.Projects) > 0 {
+		section("Unused projects:")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, tp := range r.Projects {
+			var in []string
+			if tp.InManifest {
+				in = append(in, dep.ManifestName)
+			}
+			if tp.InLock {
+				in = append(in, dep.LockName)
+			}
+			if tp.InVendor {
+				in = append(in, "vendor/")
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", tp.ProjectRoot, strings.Join(in, ", "))
+		}
+		tw.Flush()
+	}
+
+	if len(r.StaleIgnores) > 0 {
+		section("Ignored packages nothing imports:")
+		for _, pkg := range r.StaleIgnores {
+			fmt.Fprintf(w, "  %s\n", pkg)
+		}
+	}
+
+	if len(r.IgnoredRequired) > 0 {
+		section("Required packages which are also ignored:")
+		for _, pkg := range r.IgnoredRequired {
+			fmt.Fprintf(w, "  %s\n", pkg)
+		}
+	}
+
+	if len(r.OrphanedVendor) > 0 {
+		section(fmt.Sprintf("Directories in vendor/ not provided by %s:", dep.LockName))
+		for _, dir := range r.OrphanedVendor {
+			fmt.Fprintf(w, "  %s\n", dir)
+		}
+	}
+
+	if !fix && (len(r.Projects) > 0 || len(r.OrphanedVendor) > 0) {
+		fmt.Fprintln(w, "\nRun `dep tidy -fix` to remove the unused projects and directories.")
+	}
+}
+
+// fix removes the unused projects from the manifest, lock and vendor
+// directory of the project, as well as the orphaned vendor directories.
+func (r tidyReport) fix(p *dep.Project, ptree pkgtree.PackageTree, sm gps.SourceManager, logger *log.Logger) error {
+	if err := p.CheckVendorDir(); err != nil {
+		return err
+	}
+
+	remove := make(map[gps.ProjectRoot]bool, len(r.Projects))
+	for _, tp := range r.Projects {
+		remove[tp.ProjectRoot] = true
+		delete(p.Manifest.Constraints, tp.ProjectRoot)
+	}
+
+	newLock := &dep.Lock{SolveMeta: p.Lock.SolveMeta}
+	for _, lp := range p.Lock.P {
+		pr := lp.Ident().ProjectRoot
+		if remove[pr] {
+			continue
+		}
+		newLock.P = append(newLock.P, lp)
+		if p.Lock.Dev[pr] {
+			if newLock.Dev == nil {
+				newLock.Dev = make(map[gps.ProjectRoot]bool)
+			}
+			newLock.Dev[pr] = true
+		}
+	}
+
+	// Dropping constraints changes the solver inputs, so the digest needs to
+	// be recomputed for the lock to remain in sync.
+	params := p.MakeParams()
+	params.RootPackageTree = ptree
+	params.Lock = newLock
+	s, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "could not set up solver for input hashing")
+	}
+	newLock.SolveMeta.InputsDigest = s.HashInputs()
+
+	sw, err := dep.NewSafeWriter(p.Manifest, p.Lock, newLock, dep.VendorNever)
+	if err != nil {
+		return err
+	}
+	sw.UseFileNames(p.ManifestName, p.LockName)
+	if err := sw.Write(p.AbsRoot, sm, false, logger); err != nil {
+		return errors.Wrap(err, "grouped write of manifest and lock failed")
+	}
+
+	vendorDir := p.VendorDir()
+	var dirs []string
+	for _, tp := range r.Projects {
+		if tp.InVendor {
+			dirs = append(dirs, string(tp.ProjectRoot))
+		}
+	}
+	dirs = append(dirs, r.OrphanedVendor...)
+
+	for _, dir := range dirs {
+		logger.Printf("Removing vendor/%s", dir)
+		if err := removeVendorDir(vendorDir, dir); err != nil {
+			return err
+		}
+	}
+	return dep.ForgetVendorChecksums(vendorDir, dirs)
+}
+
+// removeVendorDir removes the slash-separated dir from vendorDir, along with
+// the parent directories left empty by its removal.
+func removeVendorDir(vendorDir, dir string) error {
+	target := filepath.Join(vendorDir, filepath.FromSlash(dir))
+	if err := os.RemoveAll(target); err != nil {
+		return errors.Wrapf(err, "failed to remove vendor/%s", dir)
+	}
+
+	for parent := filepath.Dir(target); parent != vendorDir && strings.HasPrefix(parent, vendorDir); parent = filepath.Dir(parent) {
+		entries, err := ioutil.ReadDir(parent)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(parent); err != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func mkVendorDirs(t *testing.T, dirs ...string) string {
+	vendorDir, err := ioutil.TempDir("", "dep-tidy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(vendorDir, filepath.FromSlash(dir)), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vendorDir
+}
+
+func TestOrphanedVendorDirs(t *testing.T) {
+	vendorDir := mkVendorDirs(t,
+		"github.com/foo/bar/sub",
+		"github.com/foo/stale",
+		"github.com/other/thing/pkg",
+		"golang.org/x/net/context",
+	)
+	defer os.RemoveAll(vendorDir)
+
+	got, err := orphanedVendorDirs(vendorDir, []gps.ProjectRoot{"github.com/foo/bar", "golang.org/x/net"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"github.com/foo/stale", "github.com/other"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected orphaned directories:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	got, err = orphanedVendorDirs(filepath.Join(vendorDir, "nonexistent"), nil)
+	if err != nil || got != nil {
+		t.Errorf("Expected no orphans and no error for a missing vendor directory, got %v, %v", got, err)
+	}
+}
+
+func TestRemoveVendorDir(t *testing.T) {
+	vendorDir := mkVendorDirs(t, "github.com/foo/bar/sub", "github.com/foo/baz")
+	defer os.RemoveAll(vendorDir)
+
+	if err := removeVendorDir(vendorDir, "github.com/foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir, "github.com", "foo", "baz")); err != nil {
+		t.Fatalf("Expected sibling project to be kept: %s", err)
+	}
+
+	if err := removeVendorDir(vendorDir, "github.com/foo/baz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir, "github.com")); !os.IsNotExist(err) {
+		t.Fatal("Expected empty parent directories to be removed")
+	}
+	if _, err := os.Stat(vendorDir); err != nil {
+		t.Fatalf("Expected vendor directory to be kept: %s", err)
+	}
+}
+
+func TestTidyReportWrite(t *testing.T) {
+	var buf bytes.Buffer
+	tidyReport{}.write(&buf, false)
+	if got := buf.String(); got != "Nothing to tidy.\n" {
+		t.Errorf("Unexpected output for an empty report: %q", got)
+	}
+
+	r := tidyReport{
+		Projects: []tidyProject{
+			{ProjectRoot: "github.com/foo/bar", InManifest: true, InLock: true, InVendor: true},
+			{ProjectRoot: "github.com/foo/constrained", InManifest: true},
+		},
+		StaleIgnores:    []string{"github.com/gone/pkg"},
+		IgnoredRequired: []string{"github.com/req/tool"},
+		OrphanedVendor:  []string{"github.com/stale"},
+	}
+
+	buf.Reset()
+	r.write(&buf, false)
+	got := buf.String()
+	for _, want := range []string{
+		"Unused projects:\n  github.com/foo/bar          Gopkg.toml, Gopkg.lock, vendor/\n  github.com/foo/constrained  Gopkg.toml\n",
+		"Ignored packages nothing imports:\n  github.com/gone/pkg\n",
+		"Required packages which are also ignored:\n  github.com/req/tool\n",
+		"Directories in vendor/ not provided by Gopkg.lock:\n  github.com/stale\n",
+		"dep tidy -fix",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	buf.Reset()
+	r.write(&buf, true)
+	if strings.Contains(buf.String(), "dep tidy -fix") {
+		t.Errorf("Did not expect a hint to run -fix when already fixing, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const toolShortHelp = `Install the required tools of the project`
+const toolLongHelp = `
+Build the tools listed in the required field of Gopkg.toml, such as code
+generators and linters, at the revisions recorded in Gopkg.lock.
+
+  dep tool install [<name>...]
+
+The tools are built from vendor/, which dep ensure must have populated, and
+installed in the bin directory of the project, or in the directory set by the
+tool-bin field of Gopkg.toml. A tool is named after the last element of its
+import path, and can be selected by that name or by its full import path.
+Without names, all the required packages which are commands are installed.
+
+The revisions the tools were built at are recorded in .dep-tools, next to
+them, so that tools whose locked revision didn't change aren't rebuilt.
+`
+
+// toolStampName is the name of the file recording the revisions the tools of
+// a bin directory were built at.
+const toolStampName = ".dep-tools"
+
+func (cmd *toolCommand) Name() string      { return "tool" }
+func (cmd *toolCommand) Args() string      { return "install [<name>...]" }
+func (cmd *toolCommand) ShortHelp() string { return toolShortHelp }
+func (cmd *toolCommand) LongHelp() string  { return toolLongHelp }
+func (cmd *toolCommand) Hidden() bool      { return false }
+
+func (cmd *toolCommand) Register(fs *flag.FlagSet) {}
+
+type toolCommand struct{}
+
+// lockedTool is a required package, along with the revision it is locked at.
+type lockedTool struct {
+	Name       string
+	ImportPath string
+	Revision   gps.Revision
+}
+
+func (cmd *toolCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return errors.New("usage: dep tool install [<name>...]")
+	}
+	names := args[1:]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to install the tools from, run dep ensure first", dep.LockName)
+	}
+
+	tools, err := selectTools(p.Manifest.Required, names)
+	if err != nil {
+		return err
+	}
+
+	vendorDir := p.VendorDir()
+	var install []lockedTool
+	for _, name := range tools {
+		t := lockedTool{Name: path.Base(name), ImportPath: name}
+		for _, lp := range p.Lock.Projects() {
+			pr := string(lp.Ident().ProjectRoot)
+			if name == pr || strings.HasPrefix(name, pr+"/") {
+				t.Revision = revisionOf(lp.Version())
+				break
+			}
+		}
+		if t.Revision == "" {
+			return errors.Errorf("%s is not in %s, run dep ensure first", name, dep.LockName)
+		}
+
+		pkg, err := build.ImportDir(filepath.Join(vendorDir, filepath.FromSlash(name)), 0)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s from vendor/, run dep ensure first", name)
+		}
+		if pkg.Name != "main" {
+			// Libraries are required too, but only the listed ones are an error.
+			if len(names) > 0 {
+				return errors.Errorf("%s is not a command", name)
+			}
+			continue
+		}
+		install = append(install, t)
+	}
+
+	bin := p.Manifest.ToolBin
+	if bin == "" {
+		bin = "bin"
+	}
+	bin = filepath.Join(p.AbsRoot, filepath.FromSlash(bin))
+	if err := os.MkdirAll(bin, 0777); err != nil {
+		return errors.Wrapf(err, "failed to create %s", bin)
+	}
+
+	stampFile := filepath.Join(bin, toolStampName)
+	stamps, err := readToolStamps(stampFile)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range install {
+		exe := filepath.Join(bin, t.Name)
+		if runtime.GOOS == "windows" {
+			exe += ".exe"
+		}
+		if st, has := stamps[t.Name]; has && st == t {
+			if _, err := os.Stat(exe); err == nil {
+				if ctx.Verbose {
+					ctx.Err.Printf("%s is up to date\n", t.Name)
+				}
+				continue
+			}
+		}
+
+		c := exec.Command("go", "build", "-o", exe, "./vendor/"+t.ImportPath)
+		c.Dir = p.AbsRoot
+		if out, err := c.CombinedOutput(); err != nil {
+			return errors.Errorf("failed to build %s: %s\n%s", t.ImportPath, err, out)
+		}
+
+		// Record the revision as soon as the tool is built, so that a failure
+		// building the next one doesn't cause it to be rebuilt.
+		stamps[t.Name] = t
+		if err := writeToolStamps(stampFile, stamps); err != nil {
+			return err
+		}
+		ctx.Out.Printf("Installed %s at %s\n", t.Name, t.Revision)
+	}
+
+	return nil
+}
+
+// selectTools returns the required packages matching names, by import path or
+// by the last element of it, or all of them if there are no names.
+func selectTools(required, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return required, nil
+	}
+
+	var tools []string
+	for _, name := range names {
+		found := false
+		for _, r := range required {
+			if name == r || name == path.Base(r) {
+				tools = append(tools, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("%s is not a required package of %s", name, dep.ManifestName)
+		}
+	}
+	return tools, nil
+}
+
+// readToolStamps reads the tools recorded in the given stamp file, keyed by
+// their name. A missing file records no tools.
+func readToolStamps(file string) (map[string]lockedTool, error) {
+	stamps := make(map[string]lockedTool)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return stamps, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// Lines read "<name> <import path> <revision>".
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		stamps[fields[0]] = lockedTool{Name: fields[0], ImportPath: fields[1], Revision: gps.Revision(fields[2])}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", file)
+	}
+	return stamps, nil
+}
+
+// writeToolStamps records the given tools in the stamp file, sorted by name.
+func writeToolStamps(file string, stamps map[string]lockedTool) error {
+	names := make([]string, 0, len(stamps))
+	for name := range stamps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		t := stamps[name]
+		fmt.Fprintf(&buf, "%s %s %s\n", t.Name, t.ImportPath, t.Revision)
+	}
+	return errors.Wrapf(ioutil.WriteFile(file, buf.Bytes(), 0666), "failed to write %s", file)
+}
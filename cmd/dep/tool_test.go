@@ -0,0 +1,68 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSelectTools(t *testing.T) {
+	required := []string{
+		"github.com/golang/protobuf/protoc-gen-go",
+		"github.com/golang/lint/golint",
+		"github.com/pkg/errors",
+	}
+
+	got, err := selectTools(required, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, required) {
+		t.Errorf("expected all the required packages without names, got %v", got)
+	}
+
+	got, err = selectTools(required, []string{"golint", "github.com/golang/protobuf/protoc-gen-go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"github.com/golang/lint/golint", "github.com/golang/protobuf/protoc-gen-go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected tools:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	if _, err = selectTools(required, []string{"stringer"}); err == nil {
+		t.Error("expected an error for a tool which isn't required")
+	}
+}
+
+func TestToolStamps(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("bin")
+	file := filepath.Join(h.Path("bin"), toolStampName)
+
+	stamps, err := readToolStamps(file)
+	h.Must(err)
+	if len(stamps) != 0 {
+		t.Fatalf("expected no tools without a stamp file, got %v", stamps)
+	}
+
+	want := map[string]lockedTool{
+		"golint":        {Name: "golint", ImportPath: "github.com/golang/lint/golint", Revision: "c5fb716d6688a859aae56d26d3e6070808df29f7"},
+		"protoc-gen-go": {Name: "protoc-gen-go", ImportPath: "github.com/golang/protobuf/protoc-gen-go", Revision: "2402d76f3d41f928c7902a765dfc872356dd3aad"},
+	}
+	h.Must(writeToolStamps(file, want))
+
+	got, err := readToolStamps(file)
+	h.Must(err)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected tools:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// upgradeChange describes how a single project changed between two locks.
+// From is nil for newly added projects, and To is nil for removed ones.
+type upgradeChange struct {
+	ProjectRoot gps.ProjectRoot
+	Source      string
+	From, To    gps.Version
+	FromLicense string
+	ToLicense   string
+}
+
+// collectUpgradeChanges compares the old and new locks, and returns a change
+// for each project which was added, removed, or moved to another revision,
+// sorted by project root.
+//
+// The license of each side of the change is detected by exporting the
+// corresponding tree through the SourceManager.
+func collectUpgradeChanges(sm gps.SourceManager, oldLock, newLock gps.Lock) ([]upgradeChange, error) {
+	byRoot := make(map[gps.ProjectRoot]*upgradeChange)
+	get := func(lp gps.LockedProject) *upgradeChange {
+		pr := lp.Ident().ProjectRoot
+		if c, has := byRoot[pr]; has {
+			return c
+		}
+		c := &upgradeChange{ProjectRoot: pr, Source: lp.Ident().Source}
+		byRoot[pr] = c
+		return c
+	}
+
+	if oldLock != nil {
+		for _, lp := range oldLock.Projects() {
+			get(lp).From = lp.Version()
+		}
+	}
+	if newLock != nil {
+		for _, lp := range newLock.Projects() {
+			c := get(lp)
+			c.To = lp.Version()
+			if lp.Ident().Source != "" {
+				c.Source = lp.Ident().Source
+			}
+		}
+	}
+
+	td, err := ioutil.TempDir("", "dep-upgrade-report")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	roots := make([]string, 0, len(byRoot))
+	for pr := range byRoot {
+		roots = append(roots, string(pr))
+	}
+	sort.Strings(roots)
+
+	var changes []upgradeChange
+	for _, pr := range roots {
+		c := byRoot[gps.ProjectRoot(pr)]
+		if c.From != nil && c.To != nil && revisionOf(c.From) == revisionOf(c.To) && c.From.String() == c.To.String() {
+			continue
+		}
+
+		id := gps.ProjectIdentifier{ProjectRoot: c.ProjectRoot, Source: c.Source}
+		if c.From != nil {
+			c.FromLicense = exportedLicense(sm, id, c.From, filepath.Join(td, fmt.Sprintf("%d-from", len(changes))))
+		}
+		if c.To != nil {
+			c.ToLicense = exportedLicense(sm, id, c.To, filepath.Join(td, fmt.Sprintf("%d-to", len(changes))))
+		}
+		changes = append(changes, *c)
+	}
+
+	return changes, nil
+}
+
+// exportedLicense exports the project at version v into dir and returns the
+// license detected there. Failure to export results in an empty license, as
+// the report should still be produced without it.
+func exportedLicense(sm gps.SourceManager, id gps.ProjectIdentifier, v gps.Version, dir string) string {
+	if err := sm.ExportProject(id, v, dir); err != nil {
+		return ""
+	}
+	defer os.RemoveAll(dir)
+	return detectLicense(dir)
+}
+
+// kind returns a short description of the kind of change.
+func (c upgradeChange) kind() string {
+	switch {
+	case c.From == nil:
+		return "added"
+	case c.To == nil:
+		return "removed"
+	case c.isMajorBump():
+		return "major"
+	}
+
+	if c.From.Type() != gps.IsSemver || c.To.Type() != gps.IsSemver {
+		return "update"
+	}
+	from, fromErr := semver.NewVersion(c.From.String())
+	to, toErr := semver.NewVersion(c.To.String())
+	switch {
+	case fromErr != nil || toErr != nil:
+		return "update"
+	case to.LessThan(from):
+		return "downgrade"
+	case to.Minor() != from.Minor():
+		return "minor"
+	}
+	return "patch"
+}
+
+// isMajorBump reports whether the change moves between semver versions with a
+// different major version, or between minor versions while the major version
+// is still zero, both of which allow for breaking changes.
+func (c upgradeChange) isMajorBump() bool {
+	if c.From == nil || c.To == nil || c.From.Type() != gps.IsSemver || c.To.Type() != gps.IsSemver {
+		return false
+	}
+
+	from, err := semver.NewVersion(c.From.String())
+	if err != nil {
+		return false
+	}
+	to, err := semver.NewVersion(c.To.String())
+	if err != nil {
+		return false
+	}
+
+	if from.Major() != to.Major() {
+		return true
+	}
+	return from.Major() == 0 && from.Minor() != to.Minor()
+}
+
+// compareURL returns a link to a web view of the commits between the two
+// revisions of the change, if the project is hosted somewhere known to offer
+// one.
+func (c upgradeChange) compareURL() string {
+	from, to := revisionOf(c.From), revisionOf(c.To)
+	if from == "" || to == "" {
+		return ""
+	}
+
+	root := string(c.ProjectRoot)
+	if c.Source != "" {
+		root = c.Source
+	}
+	for _, prefix := range []string{"https://", "http://", "ssh://git@", "git@"} {
+		root = strings.TrimPrefix(root, prefix)
+	}
+	root = strings.TrimSuffix(strings.Replace(root, ":", "/", 1), ".git")
+
+	parts := strings.Split(root, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	switch parts[0] {
+	case "github.com", "gitlab.com":
+		return fmt.Sprintf("https://%s/%s/%s/compare/%s...%s", parts[0], parts[1], parts[2], from, to)
+	case "bitbucket.org":
+		return fmt.Sprintf("https://bitbucket.org/%s/%s/branches/compare/%s..%s", parts[1], parts[2], to, from)
+	}
+	return ""
+}
+
+func formatReportVersion(v gps.Version) string {
+	if v == nil {
+		return "-"
+	}
+	return "`" + formatVersion(v) + "`"
+}
+
+// writeMarkdownReport writes a Markdown document describing the changes,
+// suitable for use as the description of a pull request.
+func writeMarkdownReport(w io.Writer, changes []upgradeChange) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("## Dependency updates\n\n")
+	if len(changes) == 0 {
+		buf.WriteString("No dependencies changed.\n")
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	buf.WriteString("| Project | From | To | Change | Commits |\n")
+	buf.WriteString("|---|---|---|---|---|\n")
+	for _, c := range changes {
+		commits := "-"
+		if u := c.compareURL(); u != "" {
+			commits = fmt.Sprintf("[%s...%s](%s)", formatVersion(revisionOf(c.From)), formatVersion(revisionOf(c.To)), u)
+		} else if c.From != nil && c.To != nil {
+			commits = fmt.Sprintf("`%s...%s`", formatVersion(revisionOf(c.From)), formatVersion(revisionOf(c.To)))
+		}
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s |\n",
+			c.ProjectRoot, formatReportVersion(c.From), formatReportVersion(c.To), c.kind(), commits)
+	}
+
+	var breaking, licenses []string
+	for _, c := range changes {
+		if c.isMajorBump() {
+			breaking = append(breaking, fmt.Sprintf("- **%s**: %s → %s", c.ProjectRoot, formatReportVersion(c.From), formatReportVersion(c.To)))
+		}
+		if c.From != nil && c.To != nil && c.FromLicense != c.ToLicense {
+			licenses = append(licenses, fmt.Sprintf("- **%s**: %s → %s", c.ProjectRoot, formatLicense(c.FromLicense), formatLicense(c.ToLicense)))
+		} else if c.From == nil && c.ToLicense != "" {
+			licenses = append(licenses, fmt.Sprintf("- **%s** (new): %s", c.ProjectRoot, formatLicense(c.ToLicense)))
+		}
+	}
+
+	if len(breaking) > 0 {
+		buf.WriteString("\n### Possible breaking changes\n\n")
+		buf.WriteString("The following projects moved to a new major version, which may include incompatible API changes:\n\n")
+		buf.WriteString(strings.Join(breaking, "\n"))
+		buf.WriteString("\n")
+	}
+
+	if len(licenses) > 0 {
+		buf.WriteString("\n### License changes\n\n")
+		buf.WriteString(strings.Join(licenses, "\n"))
+		buf.WriteString("\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func formatLicense(l string) string {
+	if l == "" {
+		return "none"
+	}
+	return l
+}
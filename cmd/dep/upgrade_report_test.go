@@ -0,0 +1,107 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestUpgradeChangeKind(t *testing.T) {
+	tests := []struct {
+		from, to gps.Version
+		want     string
+		major    bool
+	}{
+		{nil, gps.NewVersion("v1.0.0"), "added", false},
+		{gps.NewVersion("v1.0.0"), nil, "removed", false},
+		{gps.NewVersion("v1.0.0"), gps.NewVersion("v2.0.0"), "major", true},
+		{gps.NewVersion("v0.1.0"), gps.NewVersion("v0.2.0"), "major", true},
+		{gps.NewVersion("v1.0.0"), gps.NewVersion("v1.1.0"), "minor", false},
+		{gps.NewVersion("v1.0.0"), gps.NewVersion("v1.0.1"), "patch", false},
+		{gps.NewVersion("v1.0.1"), gps.NewVersion("v1.0.0"), "downgrade", false},
+		{gps.NewBranch("master").Pair("abc"), gps.NewBranch("master").Pair("def"), "update", false},
+	}
+
+	for _, tc := range tests {
+		c := upgradeChange{ProjectRoot: "github.com/foo/bar", From: tc.from, To: tc.to}
+		if got := c.kind(); got != tc.want {
+			t.Errorf("%v -> %v: expected kind %q, got %q", tc.from, tc.to, tc.want, got)
+		}
+		if got := c.isMajorBump(); got != tc.major {
+			t.Errorf("%v -> %v: expected major bump to be %t", tc.from, tc.to, tc.major)
+		}
+	}
+}
+
+func TestUpgradeChangeCompareURL(t *testing.T) {
+	from := gps.NewVersion("v1.0.0").Pair("abc")
+	to := gps.NewVersion("v1.1.0").Pair("def")
+
+	tests := []struct {
+		root, source, want string
+	}{
+		{"github.com/foo/bar", "", "https://github.com/foo/bar/compare/abc...def"},
+		{"github.com/foo/bar/v2", "", "https://github.com/foo/bar/compare/abc...def"},
+		{"example.com/bar", "git@gitlab.com:fork/bar.git", "https://gitlab.com/fork/bar/compare/abc...def"},
+		{"bitbucket.org/foo/bar", "", "https://bitbucket.org/foo/bar/branches/compare/def..abc"},
+		{"example.com/bar", "", ""},
+	}
+
+	for _, tc := range tests {
+		c := upgradeChange{ProjectRoot: gps.ProjectRoot(tc.root), Source: tc.source, From: from, To: to}
+		if got := c.compareURL(); got != tc.want {
+			t.Errorf("%s (%s): expected %q, got %q", tc.root, tc.source, tc.want, got)
+		}
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	changes := []upgradeChange{
+		{
+			ProjectRoot: "github.com/foo/bar",
+			From:        gps.NewVersion("v1.0.0").Pair("abcdef0123"),
+			To:          gps.NewVersion("v2.0.0").Pair("0123abcdef"),
+			FromLicense: "MIT",
+			ToLicense:   "Apache-2.0",
+		},
+		{
+			ProjectRoot: "example.com/baz",
+			To:          gps.NewVersion("v0.1.0").Pair("fedcba9876"),
+			ToLicense:   "BSD-3-Clause",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeMarkdownReport(&buf, changes); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"| github.com/foo/bar | `v1.0.0` | `v2.0.0` | major | [abcdef0...0123abc](https://github.com/foo/bar/compare/abcdef0123...0123abcdef) |",
+		"| example.com/baz | - | `v0.1.0` | added | - |",
+		"### Possible breaking changes",
+		"- **github.com/foo/bar**: `v1.0.0` → `v2.0.0`",
+		"### License changes",
+		"- **github.com/foo/bar**: MIT → Apache-2.0",
+		"- **example.com/baz** (new): BSD-3-Clause",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	buf.Reset()
+	if err := writeMarkdownReport(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No dependencies changed.") {
+		t.Errorf("expected empty report to say so, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+const vendorShortHelp = `Review the upstream changes to a dependency`
+const vendorLongHelp = `
+Review what updating a dependency would pull into vendor/, before running
+dep ensure.
+
+  dep vendor diff [-log] [-patch] <project>[@<version>]
+
+Print the commits of the project between the revision recorded in Gopkg.lock
+and the target version, newest first, followed by the changes to its files,
+as a unified diff. With -log, only the commits are printed, and with -patch,
+only the changes to the files.
+
+The target version defaults to the newest version allowed by Gopkg.toml, as
+dep ensure -update would pick. Any version, branch, revision or semver range
+can be given instead, as in dep ensure -add.
+
+Everything is read from the local cache of the project, which is only updated
+from upstream when the target version isn't in it yet. Listing the commits is
+only supported for git repositories; the changes to the files are shown for
+all of them.
+`
+
+func (cmd *vendorCommand) Name() string      { return "vendor" }
+func (cmd *vendorCommand) Args() string      { return "diff [-log] [-patch] <project>[@<version>]" }
+func (cmd *vendorCommand) ShortHelp() string { return vendorShortHelp }
+func (cmd *vendorCommand) LongHelp() string  { return vendorLongHelp }
+func (cmd *vendorCommand) Hidden() bool      { return false }
+
+func (cmd *vendorCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.log, "log", false, "only print the commits")
+	fs.BoolVar(&cmd.patch, "patch", false, "only print the changes to the files")
+}
+
+type vendorCommand struct {
+	log   bool
+	patch bool
+}
+
+func (cmd *vendorCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 || args[0] != "diff" {
+		return errors.New("usage: dep vendor diff [-log] [-patch] <project>[@<version>]")
+	}
+
+	// The flags of the subcommand follow it.
+	fs := flag.NewFlagSet("vendor diff", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	cmd.Register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("dep vendor diff takes exactly one project")
+	}
+	if !cmd.log && !cmd.patch {
+		cmd.log, cmd.patch = true, true
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s exists to compare against", dep.LockName)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	arg := fs.Arg(0)
+	var spec string
+	if i := strings.Index(arg, "@"); i >= 0 {
+		arg, spec = arg[:i], arg[i+1:]
+	}
+	lp, err := findLockedProject(p.Lock, sm, arg)
+	if err != nil {
+		return err
+	}
+	id, locked := lp.Ident(), lp.Version()
+
+	target, err := diffTarget(sm, p.Manifest, id, spec)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s: %s to %s\n", id.ProjectRoot, formatVersion(locked), formatVersion(target))
+	if revisionOf(locked) == revisionOf(target) {
+		fmt.Fprintln(&buf, "No changes.")
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+
+	if cmd.log {
+		log, err := sm.CommitLog(id, locked, target)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the commits of %s", id.ProjectRoot)
+		}
+		fmt.Fprintln(&buf)
+		writeCommitLog(&buf, log)
+	}
+	if cmd.patch {
+		patch, err := diffVersions(sm, id, locked, target)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(&buf)
+		buf.Write(patch)
+	}
+	ctx.Out.Print(buf.String())
+
+	return nil
+}
+
+// findLockedProject returns the project of the lock with the given root, or
+// containing the given import path.
+func findLockedProject(l *dep.Lock, sm gps.SourceManager, path string) (gps.LockedProject, error) {
+	pr := gps.ProjectRoot(path)
+	if !l.HasProjectWithRoot(pr) {
+		var err error
+		if pr, err = sm.DeduceProjectRoot(path); err != nil {
+			return gps.LockedProject{}, err
+		}
+	}
+	for _, lp := range l.Projects() {
+		if lp.Ident().ProjectRoot == pr {
+			return lp, nil
+		}
+	}
+	return gps.LockedProject{}, errors.Errorf("%s is not in %s", path, dep.LockName)
+}
+
+// diffTarget returns the version to compare the locked version of a project
+// against: the newest one matching spec, or the manifest constraint of the
+// project if spec is empty.
+func diffTarget(sm gps.SourceManager, m *dep.Manifest, id gps.ProjectIdentifier, spec string) (gps.Version, error) {
+	c := gps.Any()
+	if spec != "" {
+		var err error
+		if c, err = sm.InferConstraint(spec, id); err != nil {
+			return nil, err
+		}
+		if r, ok := c.(gps.Revision); ok {
+			return r, nil
+		}
+	} else if m != nil {
+		if pp, has := m.Ovr[id.ProjectRoot]; has && pp.Constraint != nil {
+			c = pp.Constraint
+		} else if pp, has := m.Constraints[id.ProjectRoot]; has && pp.Constraint != nil {
+			c = pp.Constraint
+		}
+	}
+
+	v, err := latestMatchingVersion(sm, id, c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list versions for %s", id.ProjectRoot)
+	}
+	if v == nil {
+		return nil, errors.Errorf("no version of %s matches %s", id.ProjectRoot, c)
+	}
+	return v, nil
+}
+
+// writeCommitLog writes the commits of log to w, in the format of git log.
+func writeCommitLog(w io.Writer, log []gps.VersionInfo) {
+	if len(log) == 0 {
+		fmt.Fprintln(w, "No commits.")
+		return
+	}
+	for i, ci := range log {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "commit %s\n", ci.Revision)
+		fmt.Fprintf(w, "Author: %s\n", ci.Author)
+		fmt.Fprintf(w, "Date:   %s\n\n", ci.Date.Format("Mon Jan 2 15:04:05 2006 -0700"))
+		for _, line := range strings.Split(ci.Message, "\n") {
+			if line == "" {
+				fmt.Fprintln(w)
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+// diffVersions returns the unified diff between the trees of two versions of
+// a project, as produced by git diff.
+func diffVersions(sm gps.SourceManager, id gps.ProjectIdentifier, from, to gps.Version) ([]byte, error) {
+	td, err := ioutil.TempDir("", "dep-vendor-diff")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(td)
+
+	if err := sm.ExportProject(id, from, filepath.Join(td, "old")); err != nil {
+		return nil, errors.Wrapf(err, "failed to export %s@%s", id, formatVersion(from))
+	}
+	if err := sm.ExportProject(id, to, filepath.Join(td, "new")); err != nil {
+		return nil, errors.Wrapf(err, "failed to export %s@%s", id, formatVersion(to))
+	}
+
+	c := exec.Command("git", "diff", "--no-index", "--no-color", "old", "new")
+	c.Dir = td
+	out, err := c.Output()
+	// git diff exits with 1 when the trees differ.
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.ExitStatus() == 1 {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to diff the trees")
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestWriteCommitLog(t *testing.T) {
+	date := time.Date(2017, time.June, 1, 12, 0, 0, 0, time.UTC)
+	log := []gps.VersionInfo{
+		{Revision: "bbb", Author: "Jane <jane@example.com>", Date: date, Message: "Add a feature"},
+		{Revision: "aaa", Author: "John <john@example.com>", Date: date, Message: "Fix a bug\n\nIt was nasty."},
+	}
+
+	var buf bytes.Buffer
+	writeCommitLog(&buf, log)
+
+	want := `commit bbb
+Author: Jane <jane@example.com>
+Date:   Thu Jun 1 12:00:00 2017 +0000
+
+    Add a feature
+
+commit aaa
+Author: John <john@example.com>
+Date:   Thu Jun 1 12:00:00 2017 +0000
+
+    Fix a bug
+
+    It was nasty.
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected log:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+
+	buf.Reset()
+	writeCommitLog(&buf, nil)
+	if got := buf.String(); got != "No commits.\n" {
+		t.Errorf("unexpected output for an empty log: %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+const versionsShortHelp = `List the versions of a dependency`
+const versionsLongHelp = `
+List the versions and branches of a project, newest first, as dep ensure
+would consider them, and mark:
+
+  MATCHES  whether the version is allowed by the constraints in Gopkg.toml
+  SOLVES   with -solve, whether the rest of the dependencies can be solved
+           with that version, regardless of Gopkg.toml's own constraint
+  LOCKED   whether Gopkg.lock uses the version
+
+The versions are read from the local cache of the project, which is updated
+from upstream first when it's stale. Checking whether each version solves
+runs a solve per version, which can take a while for projects with many of
+them.
+`
+
+func (cmd *versionsCommand) Name() string      { return "versions" }
+func (cmd *versionsCommand) Args() string      { return "[-solve] <project>" }
+func (cmd *versionsCommand) ShortHelp() string { return versionsShortHelp }
+func (cmd *versionsCommand) LongHelp() string  { return versionsLongHelp }
+func (cmd *versionsCommand) Hidden() bool      { return false }
+
+func (cmd *versionsCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.solve, "solve", false, "check whether each version solves against the rest of the dependencies")
+}
+
+type versionsCommand struct {
+	solve bool
+}
+
+// projectVersion is a version of a project, along with how it relates to the
+// manifest and lock.
+type projectVersion struct {
+	Version gps.PairedVersion
+	Matches bool
+	// Solves is only meaningful if Solved is set.
+	Solved bool
+	Solves bool
+	Locked bool
+}
+
+func (cmd *versionsCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.New("dep versions takes exactly one project")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pr, err := sm.DeduceProjectRoot(args[0])
+	if err != nil {
+		return err
+	}
+	id := gps.ProjectIdentifier{ProjectRoot: pr, Source: projectSource(p, pr)}
+
+	c := gps.Any()
+	if pp, has := p.Manifest.Ovr[pr]; has && pp.Constraint != nil {
+		c = pp.Constraint
+	} else if pp, has := p.Manifest.Constraints[pr]; has && pp.Constraint != nil {
+		c = pp.Constraint
+	}
+
+	var locked gps.Version
+	if p.Lock != nil {
+		for _, lp := range p.Lock.Projects() {
+			if lp.Ident().ProjectRoot == pr {
+				locked = lp.Version()
+			}
+		}
+	}
+
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the versions of %s", pr)
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	var ptree pkgtree.PackageTree
+	if cmd.solve {
+		if ptree, err = pkgtree.ListPackages(p.ResolvedAbsRoot, string(p.ImportRoot)); err != nil {
+			return errors.Wrap(err, "analysis of local packages failed")
+		}
+	}
+
+	versions := make([]projectVersion, 0, len(vl))
+	for _, v := range vl {
+		pv := projectVersion{
+			Version: v,
+			Matches: c.Matches(v),
+			Locked:  isLockedVersion(locked, v),
+		}
+		if cmd.solve {
+			if ctx.Verbose {
+				ctx.Err.Printf("Solving with %s@%s", pr, v)
+			}
+			pv.Solved = true
+			if pv.Solves, err = solvesWith(p, ptree, sm, id, v); err != nil {
+				return err
+			}
+		}
+		versions = append(versions, pv)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s, constrained to %s\n\n", pr, c)
+	writeVersionsTable(&buf, versions, cmd.solve)
+	ctx.Out.Print(buf.String())
+
+	return nil
+}
+
+// isLockedVersion reports whether the locked version of a project, which may
+// be a bare revision, is v.
+func isLockedVersion(locked gps.Version, v gps.PairedVersion) bool {
+	switch tl := locked.(type) {
+	case gps.PairedVersion:
+		return tl.Revision() == v.Revision() && tl.Type() == v.Type() && tl.String() == v.String()
+	case gps.Revision:
+		return tl == v.Revision()
+	}
+	return false
+}
+
+// solvesWith reports whether the project can be solved with the given version
+// of one of its dependencies, overriding the manifest. The other dependencies
+// are kept at their locked versions where possible.
+func solvesWith(p *dep.Project, ptree pkgtree.PackageTree, sm gps.SourceManager, id gps.ProjectIdentifier, v gps.Version) (bool, error) {
+	solver, err := gps.Prepare(pinnedParams(p, ptree, id, v), sm)
+	if err != nil {
+		return false, errors.Wrap(err, "prepare solver")
+	}
+	_, err = solver.Solve()
+	return err == nil, nil
+}
+
+// pinnedParams returns the parameters solving the project with one of its
+// dependencies pinned to the given version by an override.
+func pinnedParams(p *dep.Project, ptree pkgtree.PackageTree, id gps.ProjectIdentifier, v gps.Version) gps.SolveParameters {
+	m := *p.Manifest
+	m.Ovr = make(gps.ProjectConstraints, len(p.Manifest.Ovr)+1)
+	for pr, pp := range p.Manifest.Ovr {
+		m.Ovr[pr] = pp
+	}
+	m.Ovr[id.ProjectRoot] = gps.ProjectProperties{Source: id.Source, Constraint: v}
+
+	params := p.MakeParams()
+	params.Manifest = p.WithMembers(&m)
+	params.RootPackageTree = ptree
+	return params
+}
+
+func writeVersionsTable(w io.Writer, versions []projectVersion, solve bool) {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if solve {
+		fmt.Fprintln(tw, "VERSION\tTYPE\tREVISION\tMATCHES\tSOLVES\tLOCKED\t")
+	} else {
+		fmt.Fprintln(tw, "VERSION\tTYPE\tREVISION\tMATCHES\tLOCKED\t")
+	}
+	for _, pv := range versions {
+		var typ string
+		switch pv.Version.Type() {
+		case gps.IsSemver:
+			typ = "semver"
+		case gps.IsVersion:
+			typ = "version"
+		case gps.IsBranch:
+			typ = "branch"
+		}
+		locked := ""
+		if pv.Locked {
+			locked = "*"
+		}
+		rev := string(pv.Version.Revision())
+		if len(rev) > 7 {
+			rev = rev[:7]
+		}
+
+		if solve {
+			solves := ""
+			if pv.Solved {
+				solves = yesNo(pv.Solves)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", pv.Version, typ, rev, yesNo(pv.Matches), solves, locked)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", pv.Version, typ, rev, yesNo(pv.Matches), locked)
+		}
+	}
+	tw.Flush()
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestIsLockedVersion(t *testing.T) {
+	rev := gps.Revision("645ef00459ed84a119197bfb8d8205042c6df63d")
+	v := gps.NewVersion("v0.8.0").Pair(rev)
+
+	if !isLockedVersion(gps.NewVersion("v0.8.0").Pair(rev), v) {
+		t.Error("expected the same paired version to be locked")
+	}
+	if !isLockedVersion(rev, v) {
+		t.Error("expected a version of the locked revision to be locked")
+	}
+	if isLockedVersion(gps.NewBranch("master").Pair(rev), v) {
+		t.Error("expected another version at the same revision not to be locked")
+	}
+	if isLockedVersion(nil, v) {
+		t.Error("expected no version to be locked without a lock")
+	}
+}
+
+func TestWriteVersionsTable(t *testing.T) {
+	versions := []projectVersion{
+		{Version: gps.NewVersion("v1.0.0").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"), Matches: false, Solved: true, Solves: false},
+		{Version: gps.NewVersion("v0.8.0").Pair("645ef00459ed84a119197bfb8d8205042c6df63d"), Matches: true, Solved: true, Solves: true, Locked: true},
+		{Version: gps.NewBranch("master").Pair("ff2948a2ac8f538c4ecd55962e919d1e13e74baf"), Matches: false},
+	}
+
+	var buf bytes.Buffer
+	writeVersionsTable(&buf, versions, true)
+	want := `VERSION  TYPE    REVISION  MATCHES  SOLVES  LOCKED  
+v1.0.0   semver  ff2948a   no       no              
+v0.8.0   semver  645ef00   yes      yes     *       
+master   branch  ff2948a   no                       
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected table:\n\t(GOT):\n%s\n\t(WNT):\n%s", got, want)
+	}
+}
@@ -27,6 +27,7 @@ type vndrImporter struct {
 	logger  *log.Logger
 	verbose bool
 	sm      gps.SourceManager
+	report  *migrationReport
 }
 
 func newVndrImporter(log *log.Logger, verbose bool, sm gps.SourceManager) *vndrImporter {
@@ -39,6 +40,8 @@ func newVndrImporter(log *log.Logger, verbose bool, sm gps.SourceManager) *vndrI
 
 func (v *vndrImporter) Name() string { return "vndr" }
 
+func (v *vndrImporter) setReport(r *migrationReport) { v.report = r }
+
 func (v *vndrImporter) HasDepMetadata(dir string) bool {
 	_, err := os.Stat(vndrFile(dir))
 	return err == nil
@@ -110,12 +113,14 @@ func (v *vndrImporter) convert(pr gps.ProjectRoot) (*dep.Manifest, *dep.Lock, er
 			Constraint: pc.Constraint,
 		}
 		fb.NewConstraintFeedback(pc, fb.DepTypeImported).LogFeedback(v.logger)
+		v.report.translated(pc, pkg.revision)
 
 		revision := gps.Revision(pkg.revision)
 		version, err := lookupVersionForLockedProject(pc.Ident, pc.Constraint, revision, v.sm)
 		if err != nil {
 			v.logger.Println(err.Error())
 		}
+		v.report.locked(pc.Ident.ProjectRoot, pkg.revision, version, err)
 
 		lp := gps.NewLockedProject(pc.Ident, version, nil)
 
@@ -0,0 +1,113 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const whyShortHelp = `Explain why a package is a dependency of the project`
+const whyLongHelp = `
+Print every chain of imports from the packages of the project to the given
+import path, one per line, to tell why it's a dependency before removing it.
+The import path may be that of a package, or of a project, in which case the
+chains lead to any of its packages.
+
+  dep why github.com/pkg/errors
+
+The packages of the dependencies are read from vendor/ when it holds them, and
+from the locked versions in the cache otherwise. The test imports of the
+project are only followed with -tests. Those of the dependencies never are.
+
+-limit caps the number of chains printed, which can grow large in projects
+with many dependencies.
+
+With -json, the chains are printed as a JSON array of arrays of import paths.
+`
+
+func (cmd *whyCommand) Name() string      { return "why" }
+func (cmd *whyCommand) Args() string      { return "[-tests] [-limit n] [-json] <import path>" }
+func (cmd *whyCommand) ShortHelp() string { return whyShortHelp }
+func (cmd *whyCommand) LongHelp() string  { return whyLongHelp }
+func (cmd *whyCommand) Hidden() bool      { return false }
+
+func (cmd *whyCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.tests, "tests", false, "follow the test imports of the project")
+	fs.IntVar(&cmd.limit, "limit", 0, "print at most this many chains, or all of them if zero")
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+type whyCommand struct {
+	tests bool
+	limit int
+	json  bool
+}
+
+func (cmd *whyCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.New("dep why takes exactly one import path")
+	}
+	if cmd.limit < 0 {
+		return errors.New("-limit must not be negative")
+	}
+	target := strings.TrimSuffix(args[0], "/")
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	trees, err := collectPackageTrees(p, sm)
+	if err != nil {
+		return err
+	}
+	g := packageGraph(trees, p.ImportRoot, p.Manifest.IgnoredPackages(), false, cmd.tests)
+	chains := allImportChains(g, p.ImportRoot, func(ip string) bool { return isPathPrefixOrEqual(target, ip) }, cmd.limit)
+
+	var buf bytes.Buffer
+	if cmd.json {
+		if chains == nil {
+			chains = [][]string{}
+		}
+		if err := json.NewEncoder(&buf).Encode(chains); err != nil {
+			return errors.Wrap(err, "failed to marshal the import chains")
+		}
+	} else {
+		writeImportChains(&buf, target, chains, cmd.tests)
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// writeImportChains writes the chains of imports leading to target to w, or
+// why there are none.
+func writeImportChains(w io.Writer, target string, chains [][]string, tests bool) {
+	if len(chains) == 0 {
+		if tests {
+			fmt.Fprintf(w, "%s is not imported by the project\n", target)
+		} else {
+			fmt.Fprintf(w, "%s is not imported by the project, leaving its tests aside; try -tests\n", target)
+		}
+		return
+	}
+	for _, chain := range chains {
+		fmt.Fprintln(w, strings.Join(chain, " -> "))
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteImportChains(t *testing.T) {
+	var buf bytes.Buffer
+	writeImportChains(&buf, "github.com/b/b", [][]string{
+		{"example.com/root", "github.com/a/a", "github.com/b/b"},
+		{"example.com/root/lib", "github.com/b/b/sub"},
+	}, false)
+	writeImportChains(&buf, "github.com/c/c", nil, false)
+	writeImportChains(&buf, "github.com/c/c", nil, true)
+
+	want := `example.com/root -> github.com/a/a -> github.com/b/b
+example.com/root/lib -> github.com/b/b/sub
+github.com/c/c is not imported by the project, leaving its tests aside; try -tests
+github.com/c/c is not imported by the project
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected chains:\n(GOT):\n%s\n(WNT):\n%s", got, want)
+	}
+}
@@ -5,10 +5,16 @@
 package dep
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/golang/dep/internal/fs"
 	"github.com/golang/dep/internal/gps"
@@ -34,11 +40,41 @@ import (
 //	}
 //
 type Ctx struct {
-	WorkingDir string      // Where to execute.
-	GOPATH     string      // Selected Go path, containing WorkingDir.
-	GOPATHs    []string    // Other Go paths.
-	Out, Err   *log.Logger // Required loggers.
-	Verbose    bool        // Enables more verbose logging.
+	WorkingDir  string      // Where to execute.
+	GOPATH      string      // Selected Go path, containing WorkingDir.
+	GOPATHs     []string    // Other Go paths.
+	Out, Err    *log.Logger // Required loggers.
+	Verbose     bool        // Enables more verbose logging.
+	TraceEvents io.Writer   // Where to write the trace of the solver as JSON lines, if anywhere.
+	Progress    io.Writer   // Terminal to redraw the progress of long operations on, if Err goes to one.
+	CacheServer string      // URL of the dep cache-server to fetch sources from, if any.
+	CacheDir    string      // Where to keep the cache, if not in GOPATH/pkg/dep.
+	SourcesDir  string      // Where to keep the sources of the cache, if not in CacheDir.
+
+	ManifestName string // Name of the manifest of the project, if not Gopkg.toml.
+	LockName     string // Name of the lock of the project, if not Gopkg.lock.
+
+	Workers int // Number of projects to write to vendor/ at once, if not the number of CPUs.
+
+	CacheAge time.Duration // How long version lists are kept in the persistent cache; zero disables it.
+
+	RefreshVersionLists bool // Whether to list versions anew rather than from the persistent cache, as -update does.
+
+	LockTimeout time.Duration // How long to wait for the lock of a source held by another process; zero waits for as long as it takes.
+
+	ShallowClones bool // Whether to clone the git sources missing from the cache shallowly.
+
+	Offline bool // Whether to forbid all network access, serving sources from the cache only.
+
+	Credentials []gps.Credential // Credentials of the hosts serving sources, if any.
+
+	ProtocolPolicies []gps.ProtocolPolicy // Protocols the sources under some prefixes may be fetched over, if restricted.
+
+	Daemon      string                    // Unix socket of the dep daemon to delegate source operations to, if any.
+	StartDaemon func(socket string) error // Starts a dep daemon on the socket when none answers, if set.
+
+	SourceOverrides []gps.SourceOverride // Where to fetch the sources under some prefixes from; set by LoadProject.
+	ModuleProxy     string               // Go module proxies to fetch the sources from, if any; set by LoadProject unless set already.
 }
 
 // SetPaths sets the WorkingDir and GOPATHs fields. If GOPATHs is empty, then
@@ -83,24 +119,207 @@ func defaultGOPATH() string {
 	return ""
 }
 
+// CachePath returns the directory where dep keeps its cache: CacheDir if set,
+// GOPATH/pkg/dep otherwise.
+func (c *Ctx) CachePath() string {
+	if c.CacheDir != "" {
+		return c.CacheDir
+	}
+	return filepath.Join(c.GOPATH, "pkg", "dep")
+}
+
+// SourcesPath returns the directory where dep keeps the local copies of the
+// sources it fetches: SourcesDir if set, the cache directory otherwise.
+func (c *Ctx) SourcesPath() string {
+	if c.SourcesDir != "" {
+		return c.SourcesDir
+	}
+	return c.CachePath()
+}
+
+// ManifestFile returns the name of the manifest of the project: ManifestName if
+// set, Gopkg.toml otherwise.
+func (c *Ctx) ManifestFile() string {
+	if c.ManifestName != "" {
+		return c.ManifestName
+	}
+	return ManifestName
+}
+
+// LockFile returns the name of the lock of the project: LockName if set, that
+// of the manifest with a .lock extension instead of .toml if only ManifestName
+// is set, so that alternate manifests get their own lock, and Gopkg.lock
+// otherwise.
+func (c *Ctx) LockFile() string {
+	if c.LockName != "" {
+		return c.LockName
+	}
+	if c.ManifestName != "" {
+		return strings.TrimSuffix(c.ManifestName, ".toml") + ".lock"
+	}
+	return LockName
+}
+
+// CheckFileNames checks that the names of the manifest and lock are those of
+// distinct files of the project root.
+func (c *Ctx) CheckFileNames() error {
+	mf, lf := c.ManifestFile(), c.LockFile()
+	for _, name := range []string{mf, lf} {
+		if name != filepath.Base(name) || name == "." || name == ".." || name == "vendor" {
+			return errors.Errorf("invalid manifest or lock name %q, it must be the name of a file of the project root", name)
+		}
+	}
+	if mf == lf {
+		return errors.Errorf("the manifest and lock can't both be named %s", mf)
+	}
+	return nil
+}
+
 func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
-	return gps.NewSourceManager(filepath.Join(c.GOPATH, "pkg", "dep"))
+	sm, err := gps.NewSourceManager(c.CachePath())
+	if err != nil {
+		return nil, err
+	}
+
+	if c.LockTimeout > 0 {
+		sm.UseLockTimeout(c.LockTimeout)
+	}
+	if c.Err != nil {
+		sm.UseLockProgress(c.Err)
+	}
+
+	// The daemon serves the sources with its own settings, which must be
+	// those of c.
+	if c.Daemon != "" {
+		if err := c.useDaemon(sm); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "DEPDAEMON")
+		}
+		return sm, nil
+	}
+
+	if len(c.Credentials) > 0 {
+		if err := sm.UseCredentials(c.Credentials); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "credentials")
+		}
+	}
+
+	if len(c.ProtocolPolicies) > 0 {
+		if err := sm.UseProtocolPolicies(c.ProtocolPolicies); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "DEPPROTOCOLS")
+		}
+	}
+
+	if c.SourcesDir != "" {
+		if err := sm.UseSourcesDir(c.SourcesDir); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "DEPSOURCESDIR")
+		}
+	}
+
+	if c.CacheServer != "" {
+		if err := sm.UseCacheServer(c.CacheServer); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "DEPCACHESERVER")
+		}
+	}
+
+	if c.ModuleProxy != "" {
+		if err := sm.UseModuleProxy(c.ModuleProxy); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "module proxy")
+		}
+	}
+
+	if c.ShallowClones {
+		sm.UseShallowClones()
+	}
+
+	if c.Offline {
+		sm.UseOffline()
+	}
+
+	if c.CacheAge > 0 {
+		// Another dep process may be holding the persistent cache, in which
+		// case this one does without it.
+		if err := sm.UsePersistentCache(c.CacheAge); err != nil {
+			c.Err.Printf("dep: WARNING: %v\n", err)
+		}
+		// Offline, the persistent cache is all there is to list versions from.
+		if c.RefreshVersionLists && !c.Offline {
+			sm.RefreshVersionLists()
+		}
+	}
+
+	if len(c.SourceOverrides) > 0 {
+		if err := sm.UseSourceOverrides(c.SourceOverrides); err != nil {
+			sm.Release()
+			return nil, errors.Wrap(err, "source-override")
+		}
+	}
+	return sm, nil
+}
+
+// useDaemon makes sm delegate to the daemon on c.Daemon, starting it first if
+// it doesn't answer and c.StartDaemon is set.
+func (c *Ctx) useDaemon(sm *gps.SourceMgr) error {
+	settings := c.SourceSettings()
+	err := sm.UseDaemon(c.Daemon, settings)
+	if _, ok := err.(*gps.DaemonSettingsError); err == nil || ok || c.StartDaemon == nil {
+		return err
+	}
+	if err := c.StartDaemon(c.Daemon); err != nil {
+		return err
+	}
+	return sm.UseDaemon(c.Daemon, settings)
+}
+
+// SourceSettings returns digests of the settings of c the SourceMgr serves
+// sources with, for a dep daemon and its clients to check that they agree.
+// Only those which are set are returned.
+func (c *Ctx) SourceSettings() gps.DaemonSettings {
+	settings := gps.DaemonSettings{}
+	add := func(name string, set bool, v interface{}) {
+		if set {
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", v)))
+			settings[name] = hex.EncodeToString(sum[:])
+		}
+	}
+	add("credentials", len(c.Credentials) > 0, c.Credentials)
+	add("DEPPROTOCOLS", len(c.ProtocolPolicies) > 0, c.ProtocolPolicies)
+	add("DEPSOURCESDIR", c.SourcesDir != "", c.SourcesDir)
+	add("DEPCACHESERVER", c.CacheServer != "", c.CacheServer)
+	add("module-proxy", c.ModuleProxy != "", c.ModuleProxy)
+	add("DEPSHALLOW", c.ShallowClones, c.ShallowClones)
+	add("offline", c.Offline, c.Offline)
+	add("DEPCACHEAGE", c.CacheAge > 0, c.CacheAge)
+	add("source-override", len(c.SourceOverrides) > 0, c.SourceOverrides)
+	return settings
 }
 
 // LoadProject starts from the current working directory and searches up the
 // directory tree for a project root.  The search stops when a file with the name
-// ManifestName (Gopkg.toml, by default) is located.
+// of the manifest (Gopkg.toml, by default) is located.
 //
 // The Project contains the parsed manifest as well as a parsed lock file, if
 // present.  The import path is calculated as the remaining path segment
 // below Ctx.GOPATH/src.
+//
+// If the project found is a member of a workspace, the root of the workspace
+// is loaded instead, along with the manifests of all its members.
 func (c *Ctx) LoadProject() (*Project, error) {
-	root, err := findProjectRoot(c.WorkingDir)
+	root, err := findProjectRoot(c.WorkingDir, c.ManifestFile())
 	if err != nil {
 		return nil, err
 	}
+	root = findWorkspaceRoot(root, c.ManifestFile())
 
-	p := new(Project)
+	p := &Project{
+		ManifestName: c.ManifestFile(),
+		LockName:     c.LockFile(),
+	}
 
 	if err = p.SetRoot(root); err != nil {
 		return nil, err
@@ -117,12 +336,12 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	}
 	p.ImportRoot = gps.ProjectRoot(ip)
 
-	mp := filepath.Join(p.AbsRoot, ManifestName)
+	mp := p.ManifestPath()
 	mf, err := os.Open(mp)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// TODO: list possible solutions? (dep init, cd $project)
-			return nil, errors.Errorf("no %v found in project root %v", ManifestName, p.AbsRoot)
+			return nil, errors.Errorf("no %v found in project root %v", p.ManifestName, p.AbsRoot)
 		}
 		// Unable to read the manifest file
 		return nil, err
@@ -137,8 +356,19 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	if err != nil {
 		return nil, errors.Errorf("error while parsing %s: %s", mp, err)
 	}
+	p.Members, warns, err = loadWorkspaceMembers(p.AbsRoot, p.ManifestName, p.LockName, p.Manifest)
+	for _, warn := range warns {
+		c.Err.Printf("dep: WARNING: %v\n", warn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.SourceOverrides = p.Manifest.SourceOverrides
+	if c.ModuleProxy == "" {
+		c.ModuleProxy = p.Manifest.ModuleProxy
+	}
 
-	lp := filepath.Join(p.AbsRoot, LockName)
+	lp := p.LockPath()
 	lf, err := os.Open(lp)
 	if err != nil {
 		if os.IsNotExist(err) {
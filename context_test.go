@@ -14,6 +14,7 @@ import (
 	"testing"
 	"unicode"
 
+	"github.com/golang/dep/internal/gps"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -445,3 +446,109 @@ func TestDetectGOPATH(t *testing.T) {
 		}
 	}
 }
+
+func TestCachePath(t *testing.T) {
+	c := &Ctx{GOPATH: "go"}
+	if got, want := c.CachePath(), filepath.Join("go", "pkg", "dep"); got != want {
+		t.Errorf("expected the cache in %s by default, got %s", want, got)
+	}
+	if got, want := c.SourcesPath(), filepath.Join("go", "pkg", "dep"); got != want {
+		t.Errorf("expected the sources in the cache by default, got %s", got)
+	}
+
+	c.CacheDir = filepath.Join("var", "cache", "dep")
+	if got, want := c.SourcesPath(), c.CacheDir; got != want {
+		t.Errorf("expected the sources in %s, got %s", want, got)
+	}
+	c.SourcesDir = filepath.Join("mnt", "dep")
+	if got, want := c.CachePath(), c.CacheDir; got != want {
+		t.Errorf("expected the cache in %s, got %s", want, got)
+	}
+	if got, want := c.SourcesPath(), c.SourcesDir; got != want {
+		t.Errorf("expected the sources in %s, got %s", want, got)
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	c := &Ctx{}
+	if c.ManifestFile() != ManifestName || c.LockFile() != LockName {
+		t.Errorf("expected %s and %s by default, got %s and %s", ManifestName, LockName, c.ManifestFile(), c.LockFile())
+	}
+
+	c.ManifestName = "Gopkg.staging.toml"
+	if got, want := c.LockFile(), "Gopkg.staging.lock"; got != want {
+		t.Errorf("expected the lock of an alternate manifest to be %s, got %s", want, got)
+	}
+	c.LockName = "staging.lock"
+	if got, want := c.LockFile(), c.LockName; got != want {
+		t.Errorf("expected the lock to be %s, got %s", want, got)
+	}
+	if err := c.CheckFileNames(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, names := range [][2]string{
+		{filepath.Join("sub", "Gopkg.toml"), ""},
+		{"", ".."},
+		{"Gopkg.toml", "Gopkg.toml"},
+	} {
+		c := &Ctx{ManifestName: names[0], LockName: names[1]}
+		if err := c.CheckFileNames(); err == nil {
+			t.Errorf("expected manifest %q and lock %q to be refused", names[0], names[1])
+		}
+	}
+}
+
+func TestLoadProjectFileNames(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir(filepath.Join("src", "test", "sub"))
+	h.TempFile(filepath.Join("src", "test", ManifestName), `ignored = ["github.com/foo/primary"]`)
+	h.TempFile(filepath.Join("src", "test", "Gopkg.staging.toml"), `ignored = ["github.com/foo/staging"]`)
+
+	ctx := &Ctx{
+		Out:          discardLogger,
+		Err:          discardLogger,
+		ManifestName: "Gopkg.staging.toml",
+	}
+	if err := ctx.SetPaths(h.Path(filepath.Join("src", "test", "sub")), h.Path(".")); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		t.Fatalf("LoadProject failed: %+v", err)
+	}
+	if len(p.Manifest.Ignored) != 1 || p.Manifest.Ignored[0] != "github.com/foo/staging" {
+		t.Errorf("expected the alternate manifest to be loaded, got %+v", p.Manifest)
+	}
+	if got, want := p.LockPath(), filepath.Join(h.Path(filepath.Join("src", "test")), "Gopkg.staging.lock"); got != want {
+		t.Errorf("expected the lock at %s, got %s", want, got)
+	}
+}
+
+func TestSourceSettings(t *testing.T) {
+	c := &Ctx{}
+	if s := c.SourceSettings(); len(s) != 0 {
+		t.Errorf("expected no settings by default, got %v", s)
+	}
+
+	c = &Ctx{
+		Offline:     true,
+		Credentials: []gps.Credential{{Host: "github.com", Username: "me", Password: "secret"}},
+	}
+	s := c.SourceSettings()
+	if len(s) != 2 || s["offline"] == "" || s["credentials"] == "" {
+		t.Fatalf("unexpected settings: %v", s)
+	}
+	if strings.Contains(s["credentials"], "secret") {
+		t.Error("expected the credentials to only be digested")
+	}
+
+	c.SourceOverrides = []gps.SourceOverride{{Prefix: "github.com/foo", URL: "https://example.com/foo.git"}}
+	other := c.SourceSettings()
+	if other["source-override"] == "" || other["credentials"] != s["credentials"] {
+		t.Errorf("unexpected settings with a source override: %v", other)
+	}
+}
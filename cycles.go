@@ -0,0 +1,236 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"sort"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+// ImportCycles holds the cycles found in the imports of a set of projects.
+type ImportCycles struct {
+	// Packages holds the cycles of imports between packages, which the go
+	// tool refuses to build. Each of them starts with its lexically smallest
+	// package, which is repeated at the end.
+	Packages [][]string
+	// Projects holds the cycles of imports between projects which aren't
+	// already part of a cycle between packages. They build, but any new import
+	// along them is likely to close a cycle between packages.
+	Projects []ProjectCycle
+}
+
+// ProjectCycle is a cycle of imports between projects.
+type ProjectCycle struct {
+	// Projects starts with the lexically smallest project of the cycle, which
+	// is repeated at the end.
+	Projects []gps.ProjectRoot
+	// Imports holds an import leading from each project of the cycle to the
+	// next one.
+	Imports []PackageImport
+}
+
+// PackageImport is an import of a package by another.
+type PackageImport struct {
+	From, To string
+}
+
+// Empty reports whether no cycles were found.
+func (ic ImportCycles) Empty() bool {
+	return len(ic.Packages) == 0 && len(ic.Projects) == 0
+}
+
+// FindImportCycles looks for cycles in the imports of the packages of the
+// given projects, keyed by their roots. Test imports are left out, as are the
+// imports of packages outside of the projects, such as those of the standard
+// library.
+func FindImportCycles(trees map[gps.ProjectRoot]pkgtree.PackageTree) ImportCycles {
+	pkgProject := make(map[string]gps.ProjectRoot)
+	for pr, ptree := range trees {
+		for ip, poe := range ptree.Packages {
+			if poe.Err == nil {
+				pkgProject[ip] = pr
+			}
+		}
+	}
+
+	pkgEdges := make(map[string][]string)
+	projEdges := make(map[string][]string)
+	// witnesses holds the lexically smallest package import between two
+	// projects.
+	type projectEdge struct{ from, to gps.ProjectRoot }
+	witnesses := make(map[projectEdge]PackageImport)
+	for _, ptree := range trees {
+		for ip, poe := range ptree.Packages {
+			if poe.Err != nil {
+				continue
+			}
+			for _, imp := range poe.P.Imports {
+				to, has := pkgProject[imp]
+				if !has || imp == ip {
+					continue
+				}
+				pkgEdges[ip] = append(pkgEdges[ip], imp)
+
+				from := pkgProject[ip]
+				if from == to {
+					continue
+				}
+				pe := projectEdge{from: from, to: to}
+				w, seen := witnesses[pe]
+				if !seen {
+					projEdges[string(from)] = append(projEdges[string(from)], string(to))
+				}
+				if !seen || imp < w.To || imp == w.To && ip < w.From {
+					witnesses[pe] = PackageImport{From: ip, To: imp}
+				}
+			}
+		}
+	}
+
+	var ic ImportCycles
+	for _, scc := range stronglyConnected(pkgEdges) {
+		ic.Packages = append(ic.Packages, shortestCycle(scc, pkgEdges))
+	}
+
+	// The cycles between projects made of cycles between packages are
+	// already reported as such.
+	inPkgCycle := make(map[string]bool)
+	for _, c := range ic.Packages {
+		for _, ip := range c {
+			inPkgCycle[string(pkgProject[ip])] = true
+		}
+	}
+	for _, scc := range stronglyConnected(projEdges) {
+		cycle := shortestCycle(scc, projEdges)
+		covered := true
+		for _, pr := range cycle {
+			covered = covered && inPkgCycle[pr]
+		}
+		if covered {
+			continue
+		}
+
+		var pc ProjectCycle
+		for i, pr := range cycle {
+			pc.Projects = append(pc.Projects, gps.ProjectRoot(pr))
+			if i+1 < len(cycle) {
+				pc.Imports = append(pc.Imports, witnesses[projectEdge{from: gps.ProjectRoot(pr), to: gps.ProjectRoot(cycle[i+1])}])
+			}
+		}
+		ic.Projects = append(ic.Projects, pc)
+	}
+
+	return ic
+}
+
+// stronglyConnected returns the strongly connected components of more than
+// one node of the graph described by edges, each sorted, and sorted by their
+// first node.
+func stronglyConnected(edges map[string][]string) [][]string {
+	nodes := make([]string, 0, len(edges))
+	for n := range edges {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	// Tarjan's algorithm.
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		sccs    [][]string
+		visit   func(n string)
+	)
+	visit = func(n string) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, m := range edges[n] {
+			if _, visited := index[m]; !visited {
+				visit(m)
+				if lowlink[m] < lowlink[n] {
+					lowlink[n] = lowlink[m]
+				}
+			} else if onStack[m] && index[m] < lowlink[n] {
+				lowlink[n] = index[m]
+			}
+		}
+
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []string
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			scc = append(scc, m)
+			if m == n {
+				break
+			}
+		}
+		if len(scc) > 1 {
+			sort.Strings(scc)
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, n := range nodes {
+		if _, visited := index[n]; !visited {
+			visit(n)
+		}
+	}
+
+	sort.Sort(byFirstNode(sccs))
+	return sccs
+}
+
+type byFirstNode [][]string
+
+func (s byFirstNode) Len() int           { return len(s) }
+func (s byFirstNode) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFirstNode) Less(i, j int) bool { return s[i][0] < s[j][0] }
+
+// shortestCycle returns the shortest cycle through the first node of the
+// strongly connected component scc, staying within it. The first node is
+// repeated at the end.
+func shortestCycle(scc []string, edges map[string][]string) []string {
+	in := make(map[string]bool, len(scc))
+	for _, n := range scc {
+		in[n] = true
+	}
+
+	start := scc[0]
+	parent := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		next := append([]string(nil), edges[n]...)
+		sort.Strings(next)
+		for _, m := range next {
+			if m == start {
+				var cycle []string
+				for p := n; p != ""; p = parent[p] {
+					cycle = append([]string{p}, cycle...)
+				}
+				return append(cycle, start)
+			}
+			if _, seen := parent[m]; !seen && in[m] {
+				parent[m] = n
+				queue = append(queue, m)
+			}
+		}
+	}
+
+	// Not reached for a strongly connected component.
+	return append(scc, start)
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+func TestFindImportCycles(t *testing.T) {
+	tree := func(root string, imports map[string][]string) pkgtree.PackageTree {
+		ptree := pkgtree.PackageTree{
+			ImportRoot: root,
+			Packages:   make(map[string]pkgtree.PackageOrErr),
+		}
+		for ip, imps := range imports {
+			ptree.Packages[ip] = pkgtree.PackageOrErr{
+				P: pkgtree.Package{ImportPath: ip, Imports: imps},
+			}
+		}
+		return ptree
+	}
+
+	trees := map[gps.ProjectRoot]pkgtree.PackageTree{
+		// The root project and a cycle through a and b, between packages.
+		"example.com/root": tree("example.com/root", map[string][]string{
+			"example.com/root": {"fmt", "github.com/a/a"},
+		}),
+		"github.com/a/a": tree("github.com/a/a", map[string][]string{
+			"github.com/a/a":     {"github.com/b/b/sub"},
+			"github.com/a/a/log": {"os"},
+		}),
+		"github.com/b/b": tree("github.com/b/b", map[string][]string{
+			"github.com/b/b/sub": {"github.com/a/a", "github.com/c/c"},
+		}),
+		// A cycle between c and d, through different packages.
+		"github.com/c/c": tree("github.com/c/c", map[string][]string{
+			"github.com/c/c":       {"github.com/d/d"},
+			"github.com/c/c/util":  {"strings"},
+			"github.com/c/c/other": {"github.com/d/d"},
+		}),
+		"github.com/d/d": tree("github.com/d/d", map[string][]string{
+			"github.com/d/d": {"github.com/c/c/util"},
+		}),
+	}
+
+	got := FindImportCycles(trees)
+	want := ImportCycles{
+		Packages: [][]string{
+			{"github.com/a/a", "github.com/b/b/sub", "github.com/a/a"},
+		},
+		Projects: []ProjectCycle{
+			{
+				Projects: []gps.ProjectRoot{"github.com/c/c", "github.com/d/d", "github.com/c/c"},
+				Imports: []PackageImport{
+					{From: "github.com/c/c", To: "github.com/d/d"},
+					{From: "github.com/d/d", To: "github.com/c/c/util"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected cycles:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	if !FindImportCycles(map[gps.ProjectRoot]pkgtree.PackageTree{"example.com/root": trees["example.com/root"]}).Empty() {
+		t.Error("expected no cycles for a project without dependencies")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+// DuplicateProject is a set of projects in a lock which most likely are the
+// same repository under different import paths. Each of them gets its own
+// copy in vendor/, so the types they declare are silently duplicated at
+// compile time.
+type DuplicateProject struct {
+	// Roots holds the sorted roots of the duplicated projects.
+	Roots []gps.ProjectRoot
+	// Reason explains why the projects are deemed to be duplicates.
+	Reason string
+	// Canonical is the root suggested to be used for all the imports.
+	Canonical gps.ProjectRoot
+}
+
+// Suggestion describes how to get rid of the duplication.
+func (d DuplicateProject) Suggestion() string {
+	var others []string
+	for _, pr := range d.Roots {
+		if pr != d.Canonical {
+			others = append(others, string(pr))
+		}
+	}
+	return fmt.Sprintf("import %s everywhere instead of %s; dependencies importing the other paths need to be updated, or replaced with a fork through a source override",
+		d.Canonical, strings.Join(others, ", "))
+}
+
+// DuplicateProjects looks for projects of the lock which are the same
+// repository under different import paths. These are detected when:
+//
+//  - their roots only differ by case, as with old and new spellings of an
+//    organization name
+//  - they are fetched from the same source
+//  - they are locked to the same revision, as with a vanity import path and
+//    the repository it points to
+func DuplicateProjects(l gps.Lock) []DuplicateProject {
+	if l == nil {
+		return nil
+	}
+
+	var dups []DuplicateProject
+	reported := make(map[string]bool)
+	group := func(reason string, key func(gps.LockedProject) string) {
+		byKey := make(map[string][]gps.ProjectRoot)
+		var keys []string
+		for _, lp := range l.Projects() {
+			k := key(lp)
+			if k == "" {
+				continue
+			}
+			if _, has := byKey[k]; !has {
+				keys = append(keys, k)
+			}
+			byKey[k] = append(byKey[k], lp.Ident().ProjectRoot)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			roots := byKey[k]
+			if len(roots) < 2 {
+				continue
+			}
+
+			names := make([]string, len(roots))
+			for i, pr := range roots {
+				names[i] = string(pr)
+			}
+			sort.Strings(names)
+			id := strings.Join(names, " ")
+			if reported[id] {
+				continue
+			}
+			reported[id] = true
+
+			d := DuplicateProject{Reason: reason}
+			for _, name := range names {
+				d.Roots = append(d.Roots, gps.ProjectRoot(name))
+			}
+			d.Canonical = canonicalRoot(d.Roots)
+			dups = append(dups, d)
+		}
+	}
+
+	group("import paths differing only by case", func(lp gps.LockedProject) string {
+		return strings.ToLower(string(lp.Ident().ProjectRoot))
+	})
+	group("same source repository", func(lp gps.LockedProject) string {
+		if lp.Ident().Source == "" {
+			return normalizeSourceURL(string(lp.Ident().ProjectRoot))
+		}
+		return normalizeSourceURL(lp.Ident().Source)
+	})
+	group("same locked revision", func(lp gps.LockedProject) string {
+		switch v := lp.Version().(type) {
+		case gps.PairedVersion:
+			return string(v.Revision())
+		case gps.Revision:
+			return string(v)
+		}
+		return ""
+	})
+
+	return dups
+}
+
+// normalizeSourceURL reduces the various spellings of a source URL to a
+// common form, for comparison purposes.
+func normalizeSourceURL(s string) string {
+	s = strings.ToLower(s)
+	for _, prefix := range []string{"https://", "http://", "git://", "ssh://", "git+ssh://", "bzr+ssh://"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if i := strings.Index(s, "@"); i >= 0 && i < strings.Index(s, "/") {
+		s = s[i+1:]
+	}
+	s = strings.Replace(s, ":", "/", 1)
+	return strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+}
+
+// canonicalRoot picks which of the roots of a duplicated project should be
+// used. Vanity import paths are preferred to the hosting site they redirect to,
+// and lowercase paths to mixed case ones.
+func canonicalRoot(roots []gps.ProjectRoot) gps.ProjectRoot {
+	hosted := func(pr gps.ProjectRoot) bool {
+		for _, host := range []string{"github.com/", "bitbucket.org/", "gitlab.com/", "launchpad.net/", "hub.jazz.net/", "git.apache.org/"} {
+			if strings.HasPrefix(string(pr), host) {
+				return true
+			}
+		}
+		return false
+	}
+	lower := func(pr gps.ProjectRoot) bool {
+		return strings.ToLower(string(pr)) == string(pr)
+	}
+
+	best := roots[0]
+	for _, pr := range roots[1:] {
+		switch {
+		case hosted(best) && !hosted(pr):
+			best = pr
+		case hosted(best) == hosted(pr) && !lower(best) && lower(pr):
+			best = pr
+		}
+	}
+	return best
+}
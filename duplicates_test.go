@@ -0,0 +1,77 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestDuplicateProjects(t *testing.T) {
+	mkLP := func(root, source, rev string) gps.LockedProject {
+		return gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root), Source: source},
+			gps.NewVersion("v1.0.0").Pair(gps.Revision(rev)),
+			[]string{"."},
+		)
+	}
+
+	l := &Lock{P: []gps.LockedProject{
+		mkLP("github.com/Sirupsen/logrus", "", "1"),
+		mkLP("github.com/sirupsen/logrus", "", "2"),
+		mkLP("github.com/go-yaml/yaml", "", "3"),
+		mkLP("gopkg.in/yaml.v2", "", "3"),
+		mkLP("example.com/fork", "git@github.com:someone/lib.git", "4"),
+		mkLP("github.com/someone/lib", "", "5"),
+		mkLP("github.com/unrelated/thing", "", "6"),
+	}}
+
+	got := DuplicateProjects(l)
+	want := []DuplicateProject{
+		{
+			Roots:     []gps.ProjectRoot{"github.com/Sirupsen/logrus", "github.com/sirupsen/logrus"},
+			Reason:    "import paths differing only by case",
+			Canonical: "github.com/sirupsen/logrus",
+		},
+		{
+			Roots:     []gps.ProjectRoot{"example.com/fork", "github.com/someone/lib"},
+			Reason:    "same source repository",
+			Canonical: "example.com/fork",
+		},
+		{
+			Roots:     []gps.ProjectRoot{"github.com/go-yaml/yaml", "gopkg.in/yaml.v2"},
+			Reason:    "same locked revision",
+			Canonical: "gopkg.in/yaml.v2",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected duplicates:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+
+	if s := got[0].Suggestion(); !strings.Contains(s, "import github.com/sirupsen/logrus everywhere instead of github.com/Sirupsen/logrus") {
+		t.Errorf("Unexpected suggestion: %s", s)
+	}
+
+	if dups := DuplicateProjects(&Lock{P: l.P[6:]}); len(dups) != 0 {
+		t.Errorf("Expected no duplicates in a lock with a single project, got %+v", dups)
+	}
+}
+
+func TestNormalizeSourceURL(t *testing.T) {
+	for _, s := range []string{
+		"github.com/foo/bar",
+		"https://github.com/foo/bar",
+		"https://github.com/Foo/bar.git",
+		"git@github.com:foo/bar.git",
+		"ssh://git@github.com/foo/bar",
+	} {
+		if got := normalizeSourceURL(s); got != "github.com/foo/bar" {
+			t.Errorf("%s: expected github.com/foo/bar, got %s", s, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// The versions of the formats of the manifest and the lock which dep writes
+// when migrating them. The files which don't declare the version of their
+// format, as those written before it was recorded, are in version 1.
+const (
+	CurrentManifestFormat = 2
+	CurrentLockFormat     = 2
+)
+
+// formatVersionKey is the top-level key of the manifest and the lock holding
+// the version of their format.
+const formatVersionKey = "format-version"
+
+// formatMigration upgrades a file from a version of its format to the next.
+type formatMigration struct {
+	// from is the version the migration upgrades from.
+	from int
+	// migrate changes the top-level table of the file in place, returning the
+	// descriptions of the changes it made, if any.
+	migrate func(table map[string]interface{}) ([]string, error)
+}
+
+// manifestMigrations are the migrations of the manifest, in order.
+var manifestMigrations = []formatMigration{
+	{from: 1, migrate: renameLegacyManifestTables},
+}
+
+// lockMigrations are the migrations of the lock, in order.
+var lockMigrations = []formatMigration{
+	{from: 1, migrate: moveLegacyMemo},
+}
+
+// PendingManifestMigrations returns the version of the format of the manifest
+// in data, along with the descriptions of the changes reading it makes to
+// bring it to the current one.
+func PendingManifestMigrations(data []byte) (int, []string, error) {
+	_, version, changes, err := migrateFormat(data, CurrentManifestFormat, manifestMigrations)
+	return version, changes, err
+}
+
+// PendingLockMigrations returns the version of the format of the lock in
+// data, along with the descriptions of the changes reading it makes to bring
+// it to the current one.
+func PendingLockMigrations(data []byte) (int, []string, error) {
+	_, version, changes, err := migrateFormat(data, CurrentLockFormat, lockMigrations)
+	return version, changes, err
+}
+
+// migrateFormat applies the migrations to data, from the version of the format
+// it declares up to current. It returns the migrated data, which is data
+// itself if nothing changed, the version it was in and the descriptions of the
+// changes made.
+func migrateFormat(data []byte, current int, migrations []formatMigration) ([]byte, int, []string, error) {
+	tree, err := toml.LoadReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	table := tree.ToMap()
+
+	version := 1
+	if v, has := table[formatVersionKey]; has {
+		n, ok := v.(int64)
+		if !ok || n < 1 {
+			return nil, 0, nil, errors.Errorf("%q must be a positive integer", formatVersionKey)
+		}
+		if n > int64(current) {
+			return nil, 0, nil, errors.Errorf("format version %d is newer than %d, the latest this version of dep reads; upgrade dep", n, current)
+		}
+		version = int(n)
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		c, err := m.migrate(table)
+		if err != nil {
+			return nil, 0, nil, errors.Wrapf(err, "unable to migrate from format version %d", m.from)
+		}
+		changes = append(changes, c...)
+	}
+	if len(changes) == 0 {
+		return data, version, nil, nil
+	}
+
+	tree, err = toml.TreeFromMap(table)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	s, err := tree.ToTomlString()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return []byte(s), version, changes, nil
+}
+
+// renameLegacyManifestTables renames the tables of the constraints and the
+// overrides from their names before they were settled.
+func renameLegacyManifestTables(table map[string]interface{}) ([]string, error) {
+	var changes []string
+	for _, r := range []struct{ from, to string }{
+		{"dependencies", "constraint"},
+		{"overrides", "override"},
+	} {
+		v, has := table[r.from]
+		if !has {
+			continue
+		}
+		if _, has := table[r.to]; has {
+			return nil, errors.Errorf("both [[%s]] and [[%s]] are set, merge the former into the latter", r.from, r.to)
+		}
+		table[r.to] = v
+		delete(table, r.from)
+		changes = append(changes, fmt.Sprintf("renamed [[%s]] to [[%s]]", r.from, r.to))
+	}
+	return changes, nil
+}
+
+// moveLegacyMemo moves the inputs digest of the lock from the top-level memo
+// key it was in into the solve-meta table.
+func moveLegacyMemo(table map[string]interface{}) ([]string, error) {
+	memo, has := table["memo"]
+	if !has {
+		return nil, nil
+	}
+	meta, _ := table["solve-meta"].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+		table["solve-meta"] = meta
+	}
+	if _, has := meta["inputs-digest"]; has {
+		return nil, errors.New("both memo and solve-meta.inputs-digest are set")
+	}
+	meta["inputs-digest"] = memo
+	delete(table, "memo")
+	return []string{"moved memo to solve-meta.inputs-digest"}, nil
+}
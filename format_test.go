@@ -0,0 +1,140 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+)
+
+func TestReadLegacyManifest(t *testing.T) {
+	in := `[[dependencies]]
+  name = "github.com/foo/bar"
+  version = "1.0.0"
+
+[[overrides]]
+  name = "github.com/foo/baz"
+  branch = "master"
+`
+	version, changes, err := PendingManifestMigrations([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantChanges := []string{"renamed [[dependencies]] to [[constraint]]", "renamed [[overrides]] to [[override]]"}
+	if version != 1 || !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("unexpected migrations from version %d: %q", version, changes)
+	}
+
+	m, warns, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warns) != 2 {
+		t.Errorf("expected a warning per migration, got %v", warns)
+	}
+	if _, has := m.Constraints["github.com/foo/bar"]; !has {
+		t.Errorf("expected the dependency to be migrated to a constraint, got %v", m.Constraints)
+	}
+	if _, has := m.Ovr["github.com/foo/baz"]; !has {
+		t.Errorf("expected the override to be migrated, got %v", m.Ovr)
+	}
+	if m.FormatVersion != 0 {
+		t.Errorf("expected no format version, got %d", m.FormatVersion)
+	}
+
+	// The manifests declaring the current format aren't migrated.
+	m, _, err = readManifest(strings.NewReader("format-version = 2\n\n" + in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Constraints) != 0 || m.FormatVersion != 2 {
+		t.Errorf("expected the manifest not to be migrated, got %+v", m)
+	}
+
+	if _, _, err := readManifest(strings.NewReader(in + "\n[[constraint]]\n  name = \"github.com/foo/qux\"\n")); err == nil {
+		t.Error("expected an error for both dependencies and constraints")
+	}
+}
+
+func TestReadLegacyLock(t *testing.T) {
+	in := `memo = "2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e"
+
+[[projects]]
+  name = "github.com/golang/dep"
+  branch = "master"
+  revision = "d05d5aca9f895d19e9265839bffeadd74a2d2ecb"
+  packages = ["."]
+`
+	l, err := readLock(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(l.SolveMeta.InputsDigest); got != "2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e" {
+		t.Errorf("expected the memo to be migrated to the inputs digest, got %q", got)
+	}
+	if len(l.P) != 1 || l.P[0].Ident().ProjectRoot != "github.com/golang/dep" {
+		t.Errorf("unexpected projects %v", l.P)
+	}
+
+	l.FormatVersion = CurrentLockFormat
+	b, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "format-version = 2\n") {
+		t.Errorf("expected the format version to be written first, got:\n%s", b)
+	}
+	if strings.Contains(string(b), "memo") {
+		t.Errorf("expected the memo to be gone, got:\n%s", b)
+	}
+	got, err := readLock(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, l) {
+		t.Errorf("the migrated lock did not survive a round trip:\n\t(GOT): %+v\n\t(WNT): %+v", got, l)
+	}
+}
+
+func TestReadNewerFormat(t *testing.T) {
+	if _, _, err := readManifest(strings.NewReader("format-version = 3\n")); err == nil {
+		t.Error("expected an error reading a manifest in a newer format")
+	}
+	if _, err := readLock(strings.NewReader("format-version = 3\n")); err == nil {
+		t.Error("expected an error reading a lock in a newer format")
+	}
+	if _, err := readLock(strings.NewReader("format-version = \"2\"\n")); err == nil {
+		t.Error("expected an error for a format version which isn't an integer")
+	}
+}
+
+func TestSafeWriterKeepsLockFormat(t *testing.T) {
+	lp := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("rev"), []string{"."})
+	oldLock := &Lock{P: []gps.LockedProject{lp}, FormatVersion: CurrentLockFormat}
+
+	// A lock from a solution is written in the format of the old one.
+	newLock := &Lock{P: []gps.LockedProject{lp, lp}}
+	sw, err := NewSafeWriter(nil, oldLock, newLock, VendorNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw.lock.FormatVersion != CurrentLockFormat {
+		t.Errorf("expected the format version of the old lock, got %d", sw.lock.FormatVersion)
+	}
+
+	// A newer format alone is a change to write.
+	legacy := &Lock{P: []gps.LockedProject{lp}}
+	sw, err = NewSafeWriter(nil, legacy, oldLock, VendorNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sw.writeLock {
+		t.Error("expected a lock in a newer format to be written")
+	}
+}
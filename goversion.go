@@ -0,0 +1,59 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// GoToolchainVersion returns the release of the go command found in PATH, as
+// runtime.Version reports it, such as go1.9.2. It falls back to the release
+// dep was built with if the go command can't be run.
+func GoToolchainVersion() string {
+	out, err := exec.Command("go", "version").Output()
+	if err == nil {
+		// go version go1.9.2 linux/amd64
+		if fields := strings.Fields(string(out)); len(fields) >= 3 {
+			return fields[2]
+		}
+	}
+	return runtime.Version()
+}
+
+// CheckGoVersion checks that the go command is a release of Go no earlier than
+// the go-version of the manifests of p. The development versions of Go, which
+// can't be told apart, pass.
+func (p *Project) CheckGoVersion() error {
+	return p.checkGoVersion(GoToolchainVersion)
+}
+
+// checkGoVersion is CheckGoVersion, with the release of the go command
+// returned by toolchain, only called if the manifests require one.
+func (p *Project) checkGoVersion(toolchain func() string) error {
+	if p.Manifest == nil {
+		return nil
+	}
+	gm, ok := p.WithMembers(p.Manifest).(gps.GoVersionManifest)
+	if !ok || gm.RequiredGoVersion() == "" {
+		return nil
+	}
+	req, err := gps.ParseGoVersion(gm.RequiredGoVersion())
+	if err != nil {
+		return err
+	}
+	tv, err := gps.ParseGoVersion(toolchain())
+	if err != nil {
+		return nil
+	}
+	if tv.Less(req) {
+		return errors.Errorf("%s requires Go %s, but the go command is Go %s", p.ManifestName, req, tv)
+	}
+	return nil
+}
@@ -35,9 +35,9 @@ func HasFilepathPrefix(path, prefix string) bool {
 	// handling of volume name/drive letter on Windows. vnPath and vnPrefix
 	// are first compared, and then used to initialize initial values of p and
 	// d which will be appended to for incremental checks using
-	// isCaseSensitiveFilesystem and then equality.
+	// IsCaseSensitiveFilesystem and then equality.
 
-	// no need to check isCaseSensitiveFilesystem because VolumeName return
+	// no need to check IsCaseSensitiveFilesystem because VolumeName return
 	// empty string on all non-Windows machines
 	vnPath := strings.ToLower(filepath.VolumeName(path))
 	vnPrefix := strings.ToLower(filepath.VolumeName(prefix))
@@ -84,7 +84,7 @@ func HasFilepathPrefix(path, prefix string) bool {
 		// something like ext4 filesystem mounted on FAT
 		// mountpoint, mounted on ext4 filesystem, i.e. the
 		// problematic filesystem is not the last one.
-		if isCaseSensitiveFilesystem(filepath.Join(d, dirs[i])) {
+		if IsCaseSensitiveFilesystem(filepath.Join(d, dirs[i])) {
 			d = filepath.Join(d, dirs[i])
 			p = filepath.Join(p, prefixes[i])
 		} else {
@@ -140,7 +140,7 @@ func renameByCopy(src, dst string) error {
 	return errors.Wrapf(os.RemoveAll(src), "cannot delete %s", src)
 }
 
-// isCaseSensitiveFilesystem determines if the filesystem where dir
+// IsCaseSensitiveFilesystem determines if the filesystem where dir
 // exists is case sensitive or not.
 //
 // CAVEAT: this function works by taking the last component of the given
@@ -159,7 +159,7 @@ func renameByCopy(src, dst string) error {
 // If the input directory is such that the last component is composed
 // exclusively of case-less codepoints (e.g.  numbers), this function will
 // return false.
-func isCaseSensitiveFilesystem(dir string) bool {
+func IsCaseSensitiveFilesystem(dir string) bool {
 	alt := filepath.Join(filepath.Dir(dir),
 		genTestFilename(filepath.Base(dir)))
 
@@ -220,7 +220,7 @@ func CopyDir(src, dst string) error {
 
 	// We use os.Lstat() here to ensure we don't fall in a loop where a symlink
 	// actually links to a one of its parent directories.
-	fi, err := os.Lstat(src)
+	fi, err := os.Lstat(LongPath(src))
 	if err != nil {
 		return err
 	}
@@ -228,7 +228,7 @@ func CopyDir(src, dst string) error {
 		return errSrcNotDir
 	}
 
-	_, err = os.Stat(dst)
+	_, err = os.Stat(LongPath(dst))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -236,11 +236,11 @@ func CopyDir(src, dst string) error {
 		return errDstExist
 	}
 
-	if err = os.MkdirAll(dst, fi.Mode()); err != nil {
+	if err = os.MkdirAll(LongPath(dst), fi.Mode()); err != nil {
 		return errors.Wrapf(err, "cannot mkdir %s", dst)
 	}
 
-	entries, err := ioutil.ReadDir(src)
+	entries, err := ioutil.ReadDir(LongPath(src))
 	if err != nil {
 		return errors.Wrapf(err, "cannot read directory %s", dst)
 	}
@@ -271,6 +271,8 @@ func CopyDir(src, dst string) error {
 // of the source file. The file mode will be copied from the source and
 // the copied data is synced/flushed to stable storage.
 func copyFile(src, dst string) (err error) {
+	src, dst = LongPath(src), LongPath(dst)
+
 	if sym, err := IsSymlink(src); err != nil {
 		return errors.Wrap(err, "symlink check failed")
 	} else if sym {
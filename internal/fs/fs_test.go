@@ -294,6 +294,41 @@ func TestCopyDir(t *testing.T) {
 	}
 }
 
+func TestCopyDirLongPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nest the file deep enough for its path to exceed the MAX_PATH limit of
+	// Windows, as happens with deeply nested vendor directories.
+	deep := strings.Repeat(filepath.Join("deeply", "nested", "directory")+string(filepath.Separator), 15)
+	srcdir := filepath.Join(dir, "src")
+	fn := filepath.Join(srcdir, deep, "file.go")
+	if len(fn) <= 260 {
+		t.Fatalf("expected a path longer than 260 characters, got %d", len(fn))
+	}
+	if err := os.MkdirAll(LongPath(filepath.Dir(fn)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(LongPath(fn), []byte("package deep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	destdir := filepath.Join(dir, "dest")
+	if err := CopyDir(srcdir, destdir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(LongPath(filepath.Join(destdir, deep, "file.go")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package deep\n" {
+		t.Errorf("unexpected contents of the copy: %q", got)
+	}
+}
+
 func TestCopyDirFail_SrcInaccessible(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		// XXX: setting permissions works differently in
@@ -0,0 +1,17 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !windows go1.8
+
+package fs
+
+// LongPath returns a form of path which can be passed to the os package even
+// when it exceeds the MAX_PATH limit of Windows, as deeply nested dependencies
+// do.
+//
+// Only Windows has such a limit, and go1.8 and later lift it in the os package
+// itself, so path is returned unchanged.
+func LongPath(path string) string {
+	return path
+}
@@ -0,0 +1,24 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build windows
+// +build !go1.8
+
+package fs
+
+import "path/filepath"
+
+// LongPath returns a form of path which can be passed to the os package even
+// when it exceeds the MAX_PATH limit of Windows, as deeply nested dependencies
+// do.
+func LongPath(path string) string {
+	if len(path) < maxShortPath {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedLengthPath(abs)
+}
@@ -0,0 +1,18 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux,!darwin,!windows
+
+package fs
+
+// IsNetworkFilesystem reports whether path is on a filesystem shared over the
+// network, such as NFS or SMB, whose semantics differ from those of local
+// filesystems: hard links may not be supported, and the processes using its
+// files may run on other machines.
+//
+// Network filesystems can't be told apart on this platform, so false is
+// returned.
+func IsNetworkFilesystem(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import "syscall"
+
+// Magic numbers of the network filesystems, as found in linux/magic.h.
+var networkFilesystems = map[uint32]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x5346414f: true, // AFS
+	0x73757245: true, // Coda
+	0x01021997: true, // 9P
+	0x00c36400: true, // Ceph
+}
+
+// IsNetworkFilesystem reports whether path is on a filesystem shared over the
+// network, such as NFS or SMB, whose semantics differ from those of local
+// filesystems: hard links may not be supported, and the processes using its
+// files may run on other machines.
+func IsNetworkFilesystem(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return networkFilesystems[uint32(st.Type)], nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsNetworkFilesystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Temporary directories are expected to be local.
+	if shared, err := IsNetworkFilesystem(dir); err != nil || shared {
+		t.Errorf("expected %s to be on a local filesystem, got %v, %v", dir, shared, err)
+	}
+	if _, err := IsNetworkFilesystem(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const driveRemote = 4 // DRIVE_REMOTE
+
+var procGetDriveTypeW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// IsNetworkFilesystem reports whether path is on a filesystem shared over the
+// network, such as NFS or SMB, whose semantics differ from those of local
+// filesystems: hard links may not be supported, and the processes using its
+// files may run on other machines.
+//
+// UNC paths and mapped network drives are on network filesystems.
+func IsNetworkFilesystem(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		return false, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	vol := filepath.VolumeName(abs)
+	if strings.HasPrefix(vol, `\\`) {
+		return true, nil
+	}
+	root, err := syscall.UTF16PtrFromString(vol + `\`)
+	if err != nil {
+		return false, err
+	}
+	if err := procGetDriveTypeW.Find(); err != nil {
+		return false, err
+	}
+	t, _, _ := procGetDriveTypeW.Call(uintptr(unsafe.Pointer(root)))
+	return t == driveRemote, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName returns the Unicode Normalization Form C (NFC) of a file
+// name, or path.
+//
+// The same name may be spelled by different sequences of code points, as é
+// which is either U+00E9, or e followed by the combining U+0301. Most
+// filesystems keep names as they're given, in whichever form, but those of
+// macOS store them decomposed, in a form close to NFD. Names read from the
+// filesystem must therefore be normalized before being compared, hashed or
+// sorted, for the results to be the same on every platform. NFC is used, as
+// it's the form names are given in on other platforms, and that of import
+// paths.
+//
+// Names which aren't valid UTF-8 are returned as they are.
+func NormalizeName(name string) string {
+	if !utf8.ValidString(name) {
+		return name
+	}
+	return norm.NFC.String(name)
+}
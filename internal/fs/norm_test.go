@@ -0,0 +1,38 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name, want string
+	}{
+		{"github.com/foo/bar.go", "github.com/foo/bar.go"},
+		// Composed and decomposed spellings of the same names.
+		{"caf\u00e9/na\u00efve.go", "caf\u00e9/na\u00efve.go"},
+		{"cafe\u0301/nai\u0308ve.go", "caf\u00e9/na\u00efve.go"},
+		{"\uac01", "\uac01"},
+		{"\u1100\u1161\u11a8", "\uac01"},
+		// Combining marks are put in canonical order before composing.
+		{"a\u0302\u0323", "\u1ead"},
+		{"a\u0323\u0302", "\u1ead"},
+		{"\u00e2\u0323", "\u1ead"},
+		// A mark blocked by another of the same class isn't composed.
+		{"a\u0305\u0301", "a\u0305\u0301"},
+		// Singletons are replaced, and excluded runes decomposed.
+		{"\u212b", "\u00c5"},
+		{"\u0958", "\u0915\u093c"},
+		{"\u0915\u093c", "\u0915\u093c"},
+		// Leading marks have no starter to be composed with.
+		{"\u0301e", "\u0301e"},
+		{"invalid\xffe\u0301", "invalid\xffe\u0301"},
+	}
+	for _, c := range cases {
+		if got := NormalizeName(c.name); got != c.want {
+			t.Errorf("NormalizeName(%+q) = %+q, want %+q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxShortPath is the length from which Windows paths need the extended-length
+// syntax. It's shorter than MAX_PATH, as directories must leave room for 8.3
+// file names.
+const maxShortPath = 248
+
+// extendedLengthPath returns the extended-length form of the absolute Windows
+// path, as \\?\C:\dir or \\?\UNC\server\share\dir, which isn't subject to the
+// MAX_PATH limit of 260 characters. Extended-length paths are passed as is to
+// the filesystem, so the path is cleaned up first. Other paths are returned
+// unchanged.
+func extendedLengthPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	path = strings.Replace(path, "/", `\`, -1)
+
+	var prefix string
+	switch {
+	case len(path) >= 3 && path[1] == ':' && path[2] == '\\':
+		prefix, path = `\\?\`+path[:2], path[2:]
+	case strings.HasPrefix(path, `\\`):
+		prefix, path = `\\?\UNC`, path[1:]
+	default:
+		return path
+	}
+
+	var elems []string
+	for _, elem := range strings.Split(path, `\`) {
+		switch elem {
+		case "", ".":
+		case "..":
+			if len(elems) > 0 {
+				elems = elems[:len(elems)-1]
+			}
+		default:
+			elems = append(elems, elem)
+		}
+	}
+	return prefix + `\` + strings.Join(elems, `\`)
+}
+
+// windowsReservedNames are the names of devices, which Windows doesn't allow
+// as file names, whatever their extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsWindowsReservedName reports whether the file name can't be used on
+// Windows: the names of devices such as CON or aux.go, names ending with a
+// dot or a space, and names containing characters Windows doesn't allow.
+func IsWindowsReservedName(name string) bool {
+	if name == "" || strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return name != "." && name != ".."
+	}
+	if strings.ContainsAny(name, `<>:"|?*\`) {
+		return true
+	}
+	for _, r := range name {
+		if r < 32 {
+			return true
+		}
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	return windowsReservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// CaseCollisions returns the pairs of slash-separated paths which only differ
+// in case, and thus name the same file on case-insensitive filesystems. Paths
+// colliding through one of their parent directories are reported through
+// that directory only. The pairs are sorted.
+func CaseCollisions(paths []string) [][2]string {
+	// Directories are checked along with files, as foo/a and FOO/b collide
+	// through their directories.
+	seen := make(map[string]string)
+	collided := make(map[[2]string]bool)
+	var collisions [][2]string
+	for _, p := range paths {
+		elems := strings.Split(p, "/")
+		for i := range elems {
+			prefix := strings.Join(elems[:i+1], "/")
+			folded := strings.ToLower(prefix)
+			other, has := seen[folded]
+			if !has {
+				seen[folded] = prefix
+				continue
+			}
+			if other == prefix {
+				continue
+			}
+
+			pair := [2]string{other, prefix}
+			if pair[1] < pair[0] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if !collided[pair] {
+				collided[pair] = true
+				collisions = append(collisions, pair)
+			}
+			break
+		}
+	}
+
+	sort.Sort(byCollision(collisions))
+	return collisions
+}
+
+type byCollision [][2]string
+
+func (s byCollision) Len() int      { return len(s) }
+func (s byCollision) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCollision) Less(i, j int) bool {
+	if s[i][0] != s[j][0] {
+		return s[i][0] < s[j][0]
+	}
+	return s[i][1] < s[j][1]
+}
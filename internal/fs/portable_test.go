@@ -0,0 +1,77 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	cases := map[string]string{
+		`C:\Users\dep\vendor\github.com\foo\bar`:  `\\?\C:\Users\dep\vendor\github.com\foo\bar`,
+		`C:/Users/dep/vendor/./github.com/../foo`: `\\?\C:\Users\dep\vendor\foo`,
+		`C:\Users\dep\\vendor\`:                   `\\?\C:\Users\dep\vendor`,
+		`\\server\share\vendor\foo`:               `\\?\UNC\server\share\vendor\foo`,
+		`\\?\C:\Users\dep`:                        `\\?\C:\Users\dep`,
+		`vendor\foo`:                              `vendor\foo`,
+		`C:vendor`:                                `C:vendor`,
+	}
+	for path, want := range cases {
+		if got := extendedLengthPath(path); got != want {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestIsWindowsReservedName(t *testing.T) {
+	cases := map[string]bool{
+		"main.go":     false,
+		"console.go":  false,
+		"lpt10":       false,
+		".gitignore":  false,
+		".":           false,
+		"CON":         true,
+		"aux.go":      true,
+		"Nul.txt":     true,
+		"com1.tar.gz": true,
+		"trailing.":   true,
+		"trailing ":   true,
+		"a:b":         true,
+		"what?":       true,
+		"tab\tname":   true,
+	}
+	for name, want := range cases {
+		if got := IsWindowsReservedName(name); got != want {
+			t.Errorf("IsWindowsReservedName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	paths := []string{
+		"README.md",
+		"readme.md",
+		"foo/a.go",
+		"FOO/b.go",
+		"Foo/c.go",
+		"bar/x.go",
+		"bar/X.go",
+		"bar/y.go",
+		"baz/z.go",
+	}
+	want := [][2]string{
+		{"FOO", "foo"},
+		{"Foo", "foo"},
+		{"README.md", "readme.md"},
+		{"bar/X.go", "bar/x.go"},
+	}
+	if got := CaseCollisions(paths); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected collisions:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+	if got := CaseCollisions([]string{"foo/a.go", "foo/b.go"}); got != nil {
+		t.Errorf("expected no collisions, got %v", got)
+	}
+}
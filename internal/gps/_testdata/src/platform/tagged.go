@@ -0,0 +1,15 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build plan9,arm !cgo
+
+package platform
+
+import (
+	"github.com/golang/dep/internal/gps/paths"
+)
+
+var (
+	_ = paths.IsStandardImportPath
+)
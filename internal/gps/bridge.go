@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/golang/dep/internal/gps/pkgtree"
 )
@@ -29,6 +30,7 @@ type sourceBridge interface {
 	//sourceExists(ProjectIdentifier) (bool, error)
 	//syncSourceFor(ProjectIdentifier) error
 	listVersions(ProjectIdentifier) ([]Version, error)
+	pairRef(ProjectIdentifier, string) (PairedVersion, error)
 	//revisionPresentIn(ProjectIdentifier, Revision) (bool, error)
 	//listPackages(ProjectIdentifier, Version) (pkgtree.PackageTree, error)
 	//getManifestAndLock(ProjectIdentifier, Version, ProjectAnalyzer) (Manifest, Lock, error)
@@ -68,6 +70,10 @@ type bridge struct {
 	// current solve run.
 	vlists map[ProjectIdentifier][]Version
 
+	// Cache of the metadata of the versions of projects, whose release dates
+	// are looked up for each version listed, with the error it came with.
+	vinfos map[versionInfoKey]versionInfoResult
+
 	// Indicates whether lock breaking has already been run
 	lockbroken int32
 
@@ -82,9 +88,23 @@ func mkBridge(s *solver, sm SourceManager, down bool) *bridge {
 		s:      s,
 		down:   down,
 		vlists: make(map[ProjectIdentifier][]Version),
+		vinfos: make(map[versionInfoKey]versionInfoResult),
 	}
 }
 
+// versionInfoKey identifies a version of a project in bridge.vinfos.
+type versionInfoKey struct {
+	id   ProjectIdentifier
+	typ  VersionType
+	name string
+	rev  Revision
+}
+
+type versionInfoResult struct {
+	vi  VersionInfo
+	err error
+}
+
 func (b *bridge) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
 	if b.s.rd.isRoot(id.ProjectRoot) {
 		return b.s.rd.rm, b.s.rd.rl, nil
@@ -102,12 +122,26 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	}
 
 	b.s.mtr.push("b-list-versions")
-	pvl, err := b.sm.ListVersions(id)
+	vid := b.versionSource(id)
+	pvl, err := b.sm.ListVersions(vid)
 	if err != nil {
 		b.s.mtr.pop()
 		return nil, err
 	}
 
+	if !b.s.rd.asOf.IsZero() {
+		pvl = b.releasedAsOf(vid, pvl)
+	}
+	if !b.s.rd.releasedBefore.IsZero() {
+		pvl = b.cooledDown(vid, pvl)
+	}
+	if b.s.rd.updateLevel != UpdateAny {
+		pvl = b.withinUpdateLevel(vid, pvl)
+	}
+	if b.s.rd.prereleases.For(id.ProjectRoot) == PrereleaseNever {
+		pvl = withoutPrereleases(pvl)
+	}
+
 	vl := hidePair(pvl)
 	if b.down {
 		SortForDowngrade(vl)
@@ -120,6 +154,113 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	return vl, nil
 }
 
+// versionSource returns the identifier of the source to list the versions of
+// the project id from: the upstream of its source, the one its root deduces
+// to, for the projects fetched from a fork which track the versions of their
+// upstream, and its source otherwise.
+func (b *bridge) versionSource(id ProjectIdentifier) ProjectIdentifier {
+	if id.Source != "" && b.s.rd.upstreamVersions[id.ProjectRoot] {
+		return ProjectIdentifier{ProjectRoot: id.ProjectRoot}
+	}
+	return id
+}
+
+func (b *bridge) pairRef(id ProjectIdentifier, ref string) (PairedVersion, error) {
+	b.s.mtr.push("b-pair-ref")
+	pv, err := b.sm.PairRef(id, ref)
+	b.s.mtr.pop()
+	return pv, err
+}
+
+// versionInfo returns the metadata of the version v of the project id, as
+// VersionInfo of the SourceManager does, only looking it up once per solve.
+func (b *bridge) versionInfo(id ProjectIdentifier, v PairedVersion) (VersionInfo, error) {
+	k := versionInfoKey{id: id, typ: v.Type(), name: v.String(), rev: v.Revision()}
+	if res, has := b.vinfos[k]; has {
+		return res.vi, res.err
+	}
+	vi, err := b.sm.VersionInfo(id, v)
+	b.vinfos[k] = versionInfoResult{vi: vi, err: err}
+	return vi, err
+}
+
+// releasedAsOf returns the versions in vl as they were as of the solve's as-of
+// time: those released no later than it, and the branches which moved since at
+// the revision they were at then. Branches which didn't exist yet, or whose
+// past revision can't be told, as for sources other than git, and other
+// versions whose release date can't be determined, are dropped, as there's no
+// telling whether they predate it.
+func (b *bridge) releasedAsOf(id ProjectIdentifier, vl []PairedVersion) []PairedVersion {
+	b.s.mtr.push("b-released-as-of")
+	defer b.s.mtr.pop()
+
+	released := make([]PairedVersion, 0, len(vl))
+	for _, v := range vl {
+		vi, err := b.versionInfo(id, v)
+		if err != nil {
+			continue
+		}
+		d := vi.ReleaseDate()
+		switch {
+		case d.IsZero():
+		case !d.After(b.s.rd.asOf):
+			released = append(released, v)
+		case v.Type() == IsBranch:
+			r, err := b.sm.RevisionAsOf(id, v, b.s.rd.asOf)
+			if err != nil {
+				b.s.traceInfo("dropping branch %s of %s, as where it was as of %s can't be told: %s", v, id.errString(), b.s.rd.asOf.Format(time.RFC3339), err)
+				continue
+			}
+			if r != "" {
+				released = append(released, v.Unpair().Pair(r))
+			}
+		}
+	}
+	return released
+}
+
+// cooledDown returns the versions in vl that were released no later than the
+// solve's cool-down time, along with the locked version of the project, if any,
+// even if it's no longer listed, as for branches which moved on since. Other
+// versions whose release date can't be determined are dropped.
+func (b *bridge) cooledDown(id ProjectIdentifier, vl []PairedVersion) []PairedVersion {
+	b.s.mtr.push("b-cooled-down")
+	defer b.s.mtr.pop()
+
+	var locked Revision
+	var lockedPair PairedVersion
+	if lp, has := b.s.rd.rlm[id.ProjectRoot]; has {
+		switch tv := lp.Version().(type) {
+		case PairedVersion:
+			locked, lockedPair = tv.Revision(), tv
+		case Revision:
+			locked = tv
+		}
+	}
+
+	cooled := make([]PairedVersion, 0, len(vl)+1)
+	for _, v := range vl {
+		if locked != "" && v.Revision() == locked {
+			cooled = append(cooled, v)
+			if lockedPair != nil && v.Type() == lockedPair.Type() && v.String() == lockedPair.String() {
+				lockedPair = nil
+			}
+			continue
+		}
+		vi, err := b.versionInfo(id, v)
+		if err != nil {
+			continue
+		}
+		if d := vi.ReleaseDate(); !d.IsZero() && !d.After(b.s.rd.releasedBefore) {
+			cooled = append(cooled, v)
+		}
+	}
+	if lockedPair != nil {
+		cooled = append(cooled, lockedPair)
+	}
+	return cooled
+}
+
 func (b *bridge) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
 	b.s.mtr.push("b-rev-present-in")
 	i, e := b.sm.RevisionPresentIn(id, r)
@@ -135,7 +276,7 @@ func (b *bridge) SourceExists(id ProjectIdentifier) (bool, error) {
 }
 
 func (b *bridge) vendorCodeExists(id ProjectIdentifier) (bool, error) {
-	fi, err := os.Stat(filepath.Join(b.s.rd.dir, "vendor", string(id.ProjectRoot)))
+	fi, err := os.Stat(filepath.Join(b.s.rd.vendorDir, string(id.ProjectRoot)))
 	if err != nil {
 		return false, err
 	} else if fi.IsDir() {
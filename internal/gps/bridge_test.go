@@ -0,0 +1,298 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// releaseDateSM serves a fixed version list, with release dates keyed by
+// revision. The revisions of history, oldest first, are those branches were
+// at in the past.
+type releaseDateSM struct {
+	*depspecSourceManager
+	vl      []PairedVersion
+	dates   map[Revision]time.Time
+	history []Revision
+	infos   int
+}
+
+func (sm *releaseDateSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	return sm.vl, nil
+}
+
+func (sm *releaseDateSM) VersionInfo(id ProjectIdentifier, v Version) (VersionInfo, error) {
+	sm.infos++
+	r := v.(PairedVersion).Revision()
+	d, has := sm.dates[r]
+	if !has {
+		return VersionInfo{}, fmt.Errorf("no release date for %s", r)
+	}
+	return VersionInfo{Revision: r, Date: d}, nil
+}
+
+func (sm *releaseDateSM) RevisionAsOf(id ProjectIdentifier, v Version, t time.Time) (Revision, error) {
+	if sm.history == nil {
+		return "", fmt.Errorf("no history for %s", v)
+	}
+	var past Revision
+	for _, r := range sm.history {
+		if sm.dates[r].After(t) {
+			break
+		}
+		past = r
+	}
+	return past, nil
+}
+
+func TestBridgeListVersionsAsOf(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2017, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	sm := &releaseDateSM{
+		depspecSourceManager: newdepspecSM(nil, nil),
+		vl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("r1"),
+			NewVersion("v1.1.0").Pair("r2"),
+			NewVersion("v1.2.0").Pair("r3"),
+			NewBranch("master").Pair("r4"),
+			NewVersion("v0.9.0").Pair("r5"),
+		},
+		dates: map[Revision]time.Time{
+			"r1": day(1),
+			"r2": day(2),
+			"r3": day(3),
+			"r4": day(4),
+		},
+		history: []Revision{"r1", "r2", "r3", "r4"},
+	}
+	id := mkPI("foo")
+
+	s := &solver{
+		rd:  rootdata{asOf: day(2)},
+		mtr: newMetrics(),
+	}
+	vl, err := mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The branch which moved since is listed at the revision it was at then.
+	want := []Version{NewVersion("v1.1.0").Pair("r2"), NewVersion("v1.0.0").Pair("r1"), NewBranch("master").Pair("r2")}
+	if !reflect.DeepEqual(vl, want) {
+		t.Fatalf("expected versions %s, got %s", want, vl)
+	}
+	if sm.infos != len(sm.vl) {
+		t.Errorf("expected the release date of each version to be looked up once, got %d lookups", sm.infos)
+	}
+
+	// Branches which didn't exist yet are left out.
+	s.rd.asOf = day(1).Add(-time.Hour)
+	vl, err = mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vl) != 0 {
+		t.Fatalf("expected no versions before the first commit, got %s", vl)
+	}
+
+	// Those whose past revision can't be told are dropped, and traced.
+	sm.history = nil
+	s.rd.asOf = day(2)
+	var trace bytes.Buffer
+	s.tl, s.sel = log.New(&trace, "", 0), &selection{}
+	vl, err = mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = want[:2]
+	if !reflect.DeepEqual(vl, want) {
+		t.Fatalf("expected versions %s, got %s", want, vl)
+	}
+	if !strings.Contains(trace.String(), "dropping branch master of foo") {
+		t.Errorf("expected the dropped branch to be traced, got %q", trace.String())
+	}
+	s.tl = nil
+
+	s.rd.asOf = time.Time{}
+	vl, err = mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vl) != len(sm.vl) {
+		t.Fatalf("expected all versions to be listed without as-of time, got %s", vl)
+	}
+}
+
+func TestBridgeListVersionsCooledDown(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2017, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	sm := &releaseDateSM{
+		depspecSourceManager: newdepspecSM(nil, nil),
+		vl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("r1"),
+			NewVersion("v1.1.0").Pair("r2"),
+			NewVersion("v1.2.0").Pair("r3"),
+			NewVersion("v1.3.0").Pair("r4"),
+			NewVersion("v0.9.0").Pair("r5"),
+		},
+		dates: map[Revision]time.Time{
+			"r1": day(1),
+			"r2": day(2),
+			"r3": day(3),
+			"r4": day(4),
+		},
+	}
+	id := mkPI("foo")
+
+	// The locked version is kept, even though it's too recent.
+	s := &solver{
+		rd: rootdata{
+			releasedBefore: day(2),
+			rlm: map[ProjectRoot]LockedProject{
+				id.ProjectRoot: NewLockedProject(id, NewVersion("v1.2.0").Pair("r3"), nil),
+			},
+		},
+		mtr: newMetrics(),
+	}
+	vl, err := mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Version{NewVersion("v1.2.0"), NewVersion("v1.1.0"), NewVersion("v1.0.0")}
+	if len(vl) != len(want) {
+		t.Fatalf("expected versions %s, got %s", want, vl)
+	}
+	for i := range want {
+		if vl[i].String() != want[i].String() {
+			t.Fatalf("expected versions %s, got %s", want, vl)
+		}
+	}
+
+	// A locked branch which moved on to a too recent commit is kept at its
+	// locked revision.
+	sm.vl = append(sm.vl, NewBranch("master").Pair("r6"))
+	sm.dates["r6"] = day(4)
+	s.rd.rlm[id.ProjectRoot] = NewLockedProject(id, NewBranch("master").Pair("r0"), nil)
+	vl, err = mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var master Version
+	for _, v := range vl {
+		if v.String() == "master" {
+			master = v
+		}
+	}
+	if master == nil || master.(PairedVersion).Revision() != "r0" {
+		t.Errorf("expected master to be kept at its locked revision, got %s", vl)
+	}
+}
+
+func TestBridgeListVersionsUpdateLevel(t *testing.T) {
+	sm := &releaseDateSM{
+		depspecSourceManager: newdepspecSM(nil, nil),
+		vl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("r1"),
+			NewVersion("v1.1.0").Pair("r2"),
+			NewVersion("v1.1.1").Pair("r3"),
+			NewVersion("v1.2.0").Pair("r4"),
+			NewVersion("v2.0.0").Pair("r5"),
+			NewBranch("master").Pair("r6"),
+		},
+	}
+	id := mkPI("foo")
+	s := &solver{
+		rd: rootdata{
+			rlm: map[ProjectRoot]LockedProject{
+				id.ProjectRoot: NewLockedProject(id, NewVersion("v1.1.0").Pair("r2"), nil),
+			},
+		},
+		mtr: newMetrics(),
+	}
+
+	for level, want := range map[UpdateLevel][]string{
+		UpdateAny:   {"v2.0.0", "v1.2.0", "v1.1.1", "v1.1.0", "v1.0.0", "master"},
+		UpdateMinor: {"v1.2.0", "v1.1.1", "v1.1.0"},
+		UpdatePatch: {"v1.1.1", "v1.1.0"},
+	} {
+		s.rd.updateLevel = level
+		vl, err := mkBridge(s, sm, false).listVersions(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(vl))
+		for i, v := range vl {
+			got[i] = v.String()
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected versions %s at update level %s, got %s", want, level, got)
+		}
+	}
+
+	// Projects not locked to a semantic version are left alone.
+	s.rd.rlm[id.ProjectRoot] = NewLockedProject(id, NewBranch("master").Pair("r6"), nil)
+	vl, err := mkBridge(s, sm, false).listVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vl) != len(sm.vl) {
+		t.Errorf("expected all versions of a project locked to a branch, got %s", vl)
+	}
+}
+
+// forkSM serves the version lists of sources by URL, that of the upstream
+// under the empty one.
+type forkSM struct {
+	*depspecSourceManager
+	vls map[string][]PairedVersion
+}
+
+func (sm *forkSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	return sm.vls[id.Source], nil
+}
+
+func TestBridgeListVersionsUpstream(t *testing.T) {
+	sm := &forkSM{
+		depspecSourceManager: newdepspecSM(nil, nil),
+		vls: map[string][]PairedVersion{
+			"":                             {NewVersion("v1.0.0").Pair("r1"), NewVersion("v1.1.0").Pair("r2")},
+			"https://example.com/fork.git": {NewBranch("patched").Pair("r3")},
+		},
+	}
+	s := &solver{
+		rd:  rootdata{upstreamVersions: map[ProjectRoot]bool{"tracked": true}},
+		mtr: newMetrics(),
+	}
+
+	for id, want := range map[ProjectIdentifier][]string{
+		{ProjectRoot: "tracked", Source: "https://example.com/fork.git"}: {"v1.1.0", "v1.0.0"},
+		{ProjectRoot: "other", Source: "https://example.com/fork.git"}:   {"patched"},
+		// Without a fork, the upstream is listed anyway.
+		{ProjectRoot: "tracked"}: {"v1.1.0", "v1.0.0"},
+	} {
+		vl, err := mkBridge(s, sm, false).listVersions(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(vl))
+		for i, v := range vl {
+			got[i] = v.String()
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected versions %s for %s, got %s", want, id, got)
+		}
+	}
+}
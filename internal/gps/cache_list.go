@@ -0,0 +1,133 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CachedSource is the local copy of a source in a sources directory, as listed
+// by ListCachedSources.
+type CachedSource struct {
+	// Name is the name of the local copy, in the sources subdirectory.
+	Name string
+	// Source is the URL of the source, if it can be told.
+	Source string
+	// Size is the size of the local copy on disk, in bytes.
+	Size int64
+	// Updated is the last time the local copy was changed, as by a fetch.
+	Updated time.Time
+}
+
+// ListCachedSources returns the local copies of the sources kept in the sources
+// directory sourcesdir, sorted by name.
+func ListCachedSources(ctx context.Context, sourcesdir string) ([]CachedSource, error) {
+	fis, err := ioutil.ReadDir(filepath.Join(sourcesdir, "sources"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var srcs []CachedSource
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		path := filepath.Join(sourcesdir, "sources", fi.Name())
+		vcs := localCopyVCS(path)
+		cs := CachedSource{
+			Name:    fi.Name(),
+			Source:  localCopyRemote(ctx, path, vcs),
+			Updated: fi.ModTime(),
+		}
+		if ctx.Err() != nil {
+			return srcs, ctx.Err()
+		}
+
+		// Fetches change the files at the top of the VCS directory, such as
+		// FETCH_HEAD, rather than the local copy itself.
+		if vcs != "" {
+			if vfis, err := ioutil.ReadDir(filepath.Join(path, "."+vcs)); err == nil {
+				for _, vfi := range vfis {
+					if vfi.ModTime().After(cs.Updated) {
+						cs.Updated = vfi.ModTime()
+					}
+				}
+			}
+		}
+		filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				cs.Size += info.Size()
+			}
+			return nil
+		})
+		srcs = append(srcs, cs)
+	}
+	return srcs, nil
+}
+
+// RemoveCachedSource removes the local copy of a source, of the given name,
+// from the sources directory sourcesdir, so that it's fetched anew when next
+// needed. The source is locked while it's removed, waiting for the processes
+// using it to be done.
+func RemoveCachedSource(ctx context.Context, sourcesdir, name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return errors.Errorf("invalid cached source name %q", name)
+	}
+	path := filepath.Join(sourcesdir, "sources", name)
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	locker := newSourceLocker(sourceLocksDir(sourcesdir))
+	if err := locker.checkFilesystem(); err != nil {
+		return err
+	}
+	unlock, err := locker.lock(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return os.RemoveAll(path)
+}
+
+// LocalSourceNames returns the names of the local copies of the sources of the
+// given projects in the sources directory, as listed by ListCachedSources,
+// keyed by project root. Only the sources the SourceMgr has already set up,
+// and which it keeps a local copy of, are named.
+func (sm *SourceMgr) LocalSourceNames(ids []ProjectIdentifier) map[ProjectRoot]string {
+	names := make(map[ProjectRoot]string)
+	sc := sm.srcCoord
+	for _, id := range ids {
+		sc.srcmut.RLock()
+		var srcGate *sourceGateway
+		if url, has := sc.nameToURL[id.normalizedSource()]; has {
+			srcGate = sc.srcs[url]
+		}
+		sc.srcmut.RUnlock()
+		if srcGate == nil {
+			continue
+		}
+
+		srcGate.mu.Lock()
+		lp, ok := srcGate.src.(interface {
+			localPath() string
+		})
+		srcGate.mu.Unlock()
+		if ok {
+			names[id.ProjectRoot] = filepath.Base(lp.localPath())
+		}
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestListAndRemoveCachedSources(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	cachedir, err := ioutil.TempDir("", "cachelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+	sources := filepath.Join(cachedir, "sources")
+
+	srcs, err := ListCachedSources(context.Background(), cachedir)
+	if err != nil || srcs != nil {
+		t.Fatalf("expected no sources in an empty cache, got %v (%v)", srcs, err)
+	}
+
+	repo := filepath.Join(sources, "https---example.com-repo")
+	junk := filepath.Join(sources, "https---example.com-junk")
+	for _, dir := range []string{repo, junk} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", repo}, {"-C", repo, "remote", "add", "origin", "https://example.com/repo"}} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(junk, "file"), make([]byte, 100), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	srcs, err = ListCachedSources(context.Background(), cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srcs) != 2 {
+		t.Fatalf("expected 2 sources, got %+v", srcs)
+	}
+	if srcs[0].Name != "https---example.com-junk" || srcs[0].Source != "" || srcs[0].Size != 100 || srcs[0].Updated.IsZero() {
+		t.Errorf("unexpected source %+v", srcs[0])
+	}
+	if srcs[1].Name != "https---example.com-repo" || srcs[1].Source != "https://example.com/repo" || srcs[1].Size == 0 {
+		t.Errorf("unexpected source %+v", srcs[1])
+	}
+
+	for _, name := range []string{"", "..", ".locks", "a/b"} {
+		if err := RemoveCachedSource(context.Background(), cachedir, name); err == nil {
+			t.Errorf("expected removing %q to fail", name)
+		}
+	}
+	if err := RemoveCachedSource(context.Background(), cachedir, "https---example.com-repo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(repo); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", repo, err)
+	}
+	if _, err := os.Stat(junk); err != nil {
+		t.Errorf("expected %s to be kept, got %v", junk, err)
+	}
+}
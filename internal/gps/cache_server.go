@@ -0,0 +1,449 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// cacheServerVersion is the JSON form of a PairedVersion served by a cache
+// server.
+type cacheServerVersion struct {
+	// Type is one of "branch", "version" or "semver".
+	Type     string   `json:"type"`
+	Version  string   `json:"version"`
+	Revision Revision `json:"revision"`
+	// Default is set for the default branch of the source.
+	Default bool `json:"default,omitempty"`
+}
+
+func toCacheServerVersion(pv PairedVersion) cacheServerVersion {
+	csv := cacheServerVersion{
+		Version:  pv.String(),
+		Revision: pv.Revision(),
+	}
+	switch uv := pv.Unpair().(type) {
+	case branchVersion:
+		csv.Type = "branch"
+		csv.Default = uv.isDefault
+	case semVersion:
+		csv.Type = "semver"
+	default:
+		csv.Type = "version"
+	}
+	return csv
+}
+
+func (csv cacheServerVersion) pairedVersion() (PairedVersion, error) {
+	if csv.Revision == "" {
+		return nil, errors.Errorf("no revision for version %s", csv.Version)
+	}
+
+	switch csv.Type {
+	case "branch":
+		if csv.Default {
+			return newDefaultBranch(csv.Version).Pair(csv.Revision), nil
+		}
+		return NewBranch(csv.Version).Pair(csv.Revision), nil
+	case "semver", "version":
+		return NewVersion(csv.Version).Pair(csv.Revision), nil
+	}
+	return nil, errors.Errorf("unknown type %q for version %s", csv.Type, csv.Version)
+}
+
+// hexRevision matches the full hexadecimal hashes of commits, the only
+// revisions the cache server serves the trees of, lest the parameter reach the
+// commands of the VCS as something else.
+var hexRevision = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// NewCacheServer returns an http.Handler serving the version lists and the
+// source trees of the sources managed by sm, which SourceMgrs configured with
+// UseCacheServer then fetch instead of reaching out to the upstream
+// repositories themselves. Sources are identified by their URL. It serves:
+//
+//  - GET /versions?source=URL: the versions of the source, as a JSON array
+//  - GET /tree?source=URL&rev=REVISION: the tree of the source at the given
+//    revision, as a gzipped tarball. The revision must be a full hexadecimal
+//    hash, as of git and hg, present in the source.
+//
+// Requests are logged to logger.
+func NewCacheServer(sm SourceManager, logger *log.Logger) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/versions", func(w http.ResponseWriter, req *http.Request) {
+		source := req.URL.Query().Get("source")
+		if source == "" {
+			http.Error(w, "missing source parameter", http.StatusBadRequest)
+			return
+		}
+		logger.Printf("listing versions of %s", source)
+
+		pvl, err := sm.ListVersions(ProjectIdentifier{ProjectRoot: ProjectRoot(source), Source: source})
+		if err != nil {
+			logger.Printf("failed to list versions of %s: %s", source, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		vl := make([]cacheServerVersion, len(pvl))
+		for i, pv := range pvl {
+			vl[i] = toCacheServerVersion(pv)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(vl)
+	})
+
+	mux.HandleFunc("/tree", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		source, rev := q.Get("source"), Revision(q.Get("rev"))
+		if source == "" || rev == "" {
+			http.Error(w, "missing source or rev parameter", http.StatusBadRequest)
+			return
+		}
+		if !hexRevision.MatchString(string(rev)) {
+			http.Error(w, "rev must be a full hexadecimal revision", http.StatusBadRequest)
+			return
+		}
+		id := ProjectIdentifier{ProjectRoot: ProjectRoot(source), Source: source}
+		present, err := sm.RevisionPresentIn(id, rev)
+		if err != nil {
+			logger.Printf("failed to look for %s in %s: %s", rev, source, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if !present {
+			http.Error(w, fmt.Sprintf("no revision %s in %s", rev, source), http.StatusNotFound)
+			return
+		}
+		logger.Printf("serving %s at %s", source, rev)
+
+		td, err := ioutil.TempDir("", "dep-cache-server")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(td)
+
+		dir := filepath.Join(td, "tree")
+		if err := sm.ExportProject(id, rev, dir); err != nil {
+			logger.Printf("failed to export %s at %s: %s", source, rev, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-gzip")
+		if err := writeTarball(w, dir); err != nil {
+			// The headers are gone already, so the best that can be done is
+			// to leave the tarball unterminated, which clients reject.
+			logger.Printf("failed to send %s at %s: %s", source, rev, err)
+		}
+	})
+
+	return mux
+}
+
+// writeTarball writes the files beneath dir to w, as a gzipped tarball.
+func writeTarball(w io.Writer, dir string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// extractTarball extracts the gzipped tarball read from r into dir. The tarball
+// comes from the network, so its entries must stay within dir: the symlinks it
+// holds must point within dir, and no entry is written through a symlink.
+func extractTarball(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+
+	var links []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return errors.Errorf("tarball entry %s is outside of the tree", hdr.Name)
+		}
+		if err := checkNoSymlinkOnPath(dir, name); err != nil {
+			return err
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(p, 0777)
+		case tar.TypeSymlink:
+			target := filepath.ToSlash(hdr.Linkname)
+			if rel := path.Join(path.Dir(name), target); path.IsAbs(target) || filepath.IsAbs(hdr.Linkname) || rel == ".." || strings.HasPrefix(rel, "../") {
+				return errors.Errorf("tarball symlink %s points outside of the tree, to %s", hdr.Name, hdr.Linkname)
+			}
+			if err = os.MkdirAll(filepath.Dir(p), 0777); err == nil {
+				err = os.Symlink(hdr.Linkname, p)
+			}
+			links = append(links, name)
+		case tar.TypeReg, tar.TypeRegA:
+			err = extractFile(tr, p, os.FileMode(hdr.Mode).Perm())
+		default:
+			err = errors.Errorf("unsupported type for tarball entry %s", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Symlinks can still lead out of the tree through one another, as a link
+	// to "." followed by "..", which only shows once they're all in place.
+	for _, name := range links {
+		if resolvesOutside(dir, name) {
+			return errors.Errorf("tarball symlink %s points outside of the tree", name)
+		}
+	}
+	return nil
+}
+
+// checkNoSymlinkOnPath fails if any of the directories of the slash-separated
+// path name within dir, or the file at name itself, is a symlink, which
+// writing the entry at name would follow.
+func checkNoSymlinkOnPath(dir, name string) error {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		fi, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(strings.Join(parts[:i+1], "/"))))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("tarball entry %s goes through the symlink %s", name, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	return nil
+}
+
+// resolvesOutside tells whether the slash-separated path name within dir
+// resolves to a path outside of dir, following the symlinks along the way.
+func resolvesOutside(dir, name string) bool {
+	var cur []string
+	pending := strings.Split(name, "/")
+	for hops := 0; len(pending) > 0; {
+		c := pending[0]
+		pending = pending[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(cur) == 0 {
+				return true
+			}
+			cur = cur[:len(cur)-1]
+			continue
+		}
+
+		cur = append(cur, c)
+		p := filepath.Join(dir, filepath.FromSlash(strings.Join(cur, "/")))
+		fi, err := os.Lstat(p)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if hops++; hops > 255 {
+			return true
+		}
+		target, err := os.Readlink(p)
+		if err != nil || filepath.IsAbs(target) || path.IsAbs(filepath.ToSlash(target)) {
+			return true
+		}
+		cur = cur[:len(cur)-1]
+		pending = append(strings.Split(filepath.ToSlash(target), "/"), pending...)
+	}
+	return false
+}
+
+// extractFile writes the file at p, refusing to replace an existing one, which
+// would be followed were it a symlink.
+func extractFile(r io.Reader, p string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// cacheServerClient fetches version lists and source trees from a cache
+// server.
+type cacheServerClient struct {
+	base *url.URL
+}
+
+func newCacheServerClient(rawurl string) (*cacheServerClient, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cache server URL %q", rawurl)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("invalid cache server URL %q: only http and https are supported", rawurl)
+	}
+	return &cacheServerClient{base: u}, nil
+}
+
+func (c *cacheServerClient) get(ctx context.Context, endpoint string, q url.Values) (io.ReadCloser, error) {
+	u := *c.base
+	u.Path = path.Join(u.Path, endpoint)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "cache server request failed")
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("cache server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// listVersions fetches the versions of the source at the given URL.
+func (c *cacheServerClient) listVersions(ctx context.Context, source string) ([]PairedVersion, error) {
+	body, err := c.get(ctx, "versions", url.Values{"source": {source}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var vl []cacheServerVersion
+	if err := json.NewDecoder(body).Decode(&vl); err != nil {
+		return nil, errors.Wrap(err, "invalid version list from cache server")
+	}
+
+	pvl := make([]PairedVersion, len(vl))
+	for i, csv := range vl {
+		if pvl[i], err = csv.pairedVersion(); err != nil {
+			return nil, errors.Wrap(err, "invalid version list from cache server")
+		}
+	}
+	return pvl, nil
+}
+
+// fetchTree fetches the tree of the source at the given URL and revision into
+// dir, which must not exist, returning the number of bytes downloaded. Nothing
+// is left behind on failure.
+func (c *cacheServerClient) fetchTree(ctx context.Context, source string, r Revision, dir string) (int64, error) {
+	body, err := c.get(ctx, "tree", url.Values{"source": {source}, "rev": {string(r)}})
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return 0, err
+	}
+	td, err := ioutil.TempDir(filepath.Dir(dir), ".download")
+	if err != nil {
+		return 0, err
+	}
+	cr := &countingReader{r: body}
+	if err := extractTarball(cr, td); err != nil {
+		os.RemoveAll(td)
+		return 0, errors.Wrap(err, "invalid tree from cache server")
+	}
+	if err := os.Rename(td, dir); err != nil {
+		os.RemoveAll(td)
+		return 0, err
+	}
+	return cr.n, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,258 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// cacheServerTestSM serves a fixed set of versions and files for a single
+// source.
+type cacheServerTestSM struct {
+	SourceManager
+	source string
+	pvl    []PairedVersion
+	files  map[string]string
+}
+
+func (sm cacheServerTestSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	if id.Source != sm.source {
+		return nil, errors.Errorf("unknown source %s", id.Source)
+	}
+	return sm.pvl, nil
+}
+
+func (sm cacheServerTestSM) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
+	if id.Source != sm.source {
+		return false, errors.Errorf("unknown source %s", id.Source)
+	}
+	for _, pv := range sm.pvl {
+		if pv.Revision() == r {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (sm cacheServerTestSM) ExportProject(id ProjectIdentifier, v Version, to string) error {
+	if id.Source != sm.source || v != sm.pvl[0].Revision() {
+		return errors.Errorf("unknown source %s at %s", id.Source, v)
+	}
+	for name, content := range sm.files {
+		p := filepath.Join(to, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCacheServer(t *testing.T) {
+	sm := cacheServerTestSM{
+		source: "https://github.com/foo/bar",
+		pvl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("3f4e1a2b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+			NewVersion("stable").Pair("3f4e1a2b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"),
+			newDefaultBranch("master").Pair("8c2d4e6f8a0b2c4d6e8f0a2b4c6d8e0f2a4b6c8d"),
+			NewBranch("dev").Pair("d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0"),
+		},
+		files: map[string]string{
+			"bar.go":     "package bar\n",
+			"baz/baz.go": "package baz\n",
+		},
+	}
+
+	srv := httptest.NewServer(NewCacheServer(sm, log.New(ioutil.Discard, "", 0)))
+	defer srv.Close()
+
+	c, err := newCacheServerClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	pvl, err := c.listVersions(ctx, sm.source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pvl, sm.pvl) {
+		t.Errorf("unexpected versions:\n\t(GOT): %#v\n\t(WNT): %#v", pvl, sm.pvl)
+	}
+
+	td, err := ioutil.TempDir("", "cacheserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "tree")
+	n, err := c.fetchTree(ctx, sm.source, "3f4e1a2b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Error("expected the downloaded bytes to be counted")
+	}
+	for name, want := range sm.files {
+		got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("expected %s to be fetched: %s", name, err)
+		} else if string(got) != want {
+			t.Errorf("unexpected content for %s: %q", name, got)
+		}
+	}
+
+	// Failures are reported, and leave nothing behind.
+	if _, err := c.listVersions(ctx, "https://github.com/foo/qux"); err == nil {
+		t.Error("expected an error listing the versions of an unknown source")
+	}
+	dir = filepath.Join(td, "missing")
+	if _, err := c.fetchTree(ctx, sm.source, "8c2d4e6f8a0b2c4d6e8f0a2b4c6d8e0f2a4b6c8d", dir); err == nil {
+		t.Error("expected an error fetching an unknown revision")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be left behind at %s", dir)
+	}
+
+	// Only full hexadecimal revisions present in the source are served, the
+	// others never reaching the VCS.
+	cases := map[string]int{
+		"--index-output=/tmp/dep-cache-server":     http.StatusBadRequest,
+		"abc123":                                   http.StatusBadRequest,
+		"0123456789abcdef0123456789abcdef01234567": http.StatusNotFound,
+	}
+	for rev, want := range cases {
+		resp, err := http.Get(srv.URL + "/tree?" + url.Values{"source": {sm.source}, "rev": {rev}}.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected status %d, got %d", rev, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestExtractTarballOutsideOfTree(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "../evil.go", Mode: 0644, Size: 0, Typeflag: tar.TypeReg})
+	tw.Close()
+	gw.Close()
+
+	td, err := ioutil.TempDir("", "cacheserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := extractTarball(&buf, filepath.Join(td, "tree")); err == nil {
+		t.Error("expected an error for an entry outside of the tree")
+	}
+	if _, err := os.Stat(filepath.Join(td, "evil.go")); !os.IsNotExist(err) {
+		t.Error("expected the entry outside of the tree not to be extracted")
+	}
+}
+
+// writeTestTarball writes a gzipped tarball of the given entries, symlinks for
+// those with a link, regular files for the others.
+func writeTestTarball(t *testing.T, entries []tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range entries {
+		hdr.Mode = 0644
+		if hdr.Linkname != "" {
+			hdr.Typeflag = tar.TypeSymlink
+		} else {
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len("pwned"))
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte("pwned"))
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return &buf
+}
+
+func TestExtractTarballSymlinks(t *testing.T) {
+	td, err := ioutil.TempDir("", "cacheserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	outside := filepath.Join(td, "outside")
+	if err := os.Mkdir(outside, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]tar.Header{
+		"absolute link": {
+			{Name: "link", Linkname: outside},
+			{Name: "link/file"},
+		},
+		"relative link out of the tree": {
+			{Name: "link", Linkname: "../outside"},
+			{Name: "link/file"},
+		},
+		"write through a link to a file": {
+			{Name: "sub/file"},
+			{Name: "link", Linkname: "sub/file"},
+			{Name: "link"},
+		},
+		"links out of the tree through one another": {
+			{Name: "dot", Linkname: "."},
+			{Name: "up", Linkname: "dot/../outside"},
+		},
+	}
+	for name, entries := range cases {
+		dir := filepath.Join(td, "tree")
+		err := extractTarball(writeTestTarball(t, entries), dir)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected nothing to be written outside of the tree", name)
+		}
+		os.RemoveAll(dir)
+	}
+
+	// Links within the tree are kept.
+	dir := filepath.Join(td, "tree")
+	entries := []tar.Header{
+		{Name: "sub/file"},
+		{Name: "sub/link", Linkname: "file"},
+		{Name: "link", Linkname: "sub"},
+	}
+	if err := extractTarball(writeTestTarball(t, entries), dir); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "link", "link")); err != nil || string(b) != "pwned" {
+		t.Errorf("expected the links within the tree to be kept, got %q (%v)", b, err)
+	}
+}
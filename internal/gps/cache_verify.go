@@ -0,0 +1,183 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// CacheProblem is a corrupted entry of a cache directory, as found by
+// VerifyCache.
+type CacheProblem struct {
+	// Path is the path of the entry, relative to the directory it's kept in.
+	Path string
+	// Source is the URL of the source the entry is the local copy of, if it
+	// can be told.
+	Source string
+	// Err describes the corruption.
+	Err error
+	// Removed is set if the entry was removed, so that it's fetched anew when
+	// next needed.
+	Removed bool
+}
+
+// staleDownloadAge is the time after which the temporary directory of a tree
+// downloaded from a cache server is assumed to be left behind by an
+// interrupted download, rather than in use by one in progress.
+const staleDownloadAge = time.Hour
+
+// VerifyCache checks the local copies of the sources kept in the sources
+// directory sourcesdir, and the trees fetched from cache servers kept in the
+// cache directory cachedir, for the corruption left behind by interrupted
+// clones and downloads, which otherwise shows as baffling solve errors. If
+// repair is set, the corrupted entries are removed, so that they're fetched
+// anew when next needed.
+//
+// Each source is locked while it's checked, so that other processes can keep
+// using the cache meanwhile.
+func VerifyCache(ctx context.Context, cachedir, sourcesdir string, repair bool) ([]CacheProblem, error) {
+	locker := newSourceLocker(sourceLocksDir(sourcesdir))
+	if err := locker.checkFilesystem(); err != nil {
+		return nil, err
+	}
+
+	var problems []CacheProblem
+	fis, err := ioutil.ReadDir(filepath.Join(sourcesdir, "sources"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range fis {
+		path := filepath.Join(sourcesdir, "sources", fi.Name())
+		unlock, err := locker.lock(ctx, path)
+		if err != nil {
+			return problems, err
+		}
+		p, err := verifySource(ctx, path, repair)
+		unlock()
+		if err != nil {
+			return problems, err
+		}
+		if p.Err != nil {
+			p.Path = filepath.Join("sources", fi.Name())
+			problems = append(problems, p)
+		}
+	}
+
+	// Trees are renamed into place once completely downloaded, so only the
+	// temporary directories of interrupted downloads may be left behind.
+	downloads, err := filepath.Glob(filepath.Join(cachedir, "trees", "*", ".download*"))
+	if err != nil {
+		return problems, err
+	}
+	for _, path := range downloads {
+		fi, err := os.Stat(path)
+		if err != nil || time.Since(fi.ModTime()) < staleDownloadAge {
+			continue
+		}
+		p := CacheProblem{Err: errors.New("download from a cache server interrupted")}
+		p.Path, _ = filepath.Rel(cachedir, path)
+		if repair {
+			if err := os.RemoveAll(path); err != nil {
+				return problems, err
+			}
+			p.Removed = true
+		}
+		problems = append(problems, p)
+	}
+
+	return problems, nil
+}
+
+// verifySource checks the local copy of a source at path, with the tools of
+// its VCS, removing it if it's corrupted and repair is set. An error is only
+// returned if the local copy couldn't be checked, or removed.
+func verifySource(ctx context.Context, path string, repair bool) (CacheProblem, error) {
+	var p CacheProblem
+	vcs := localCopyVCS(path)
+	p.Source = localCopyRemote(ctx, path, vcs)
+	switch vcs {
+	case "git":
+		if _, err := runFromDir(ctx, path, defaultCmdTimeout, "git", "rev-parse", "--verify", "--quiet", "HEAD^{commit}"); err != nil {
+			p.Err = errors.New("git repository without commits, as left by an interrupted clone")
+		} else if out, err := runFromDir(ctx, path, expensiveCmdTimeout, "git", "fsck", "--connectivity-only", "--no-progress", "--no-dangling"); err != nil {
+			p.Err = fmt.Errorf("git fsck failed: %s", commandError(out, err))
+		}
+	case "hg":
+		if out, err := runFromDir(ctx, path, expensiveCmdTimeout, "hg", "verify", "--quiet"); err != nil {
+			p.Err = fmt.Errorf("hg verify failed: %s", commandError(out, err))
+		}
+	case "bzr":
+		if out, err := runFromDir(ctx, path, expensiveCmdTimeout, "bzr", "check"); err != nil {
+			p.Err = fmt.Errorf("bzr check failed: %s", commandError(out, err))
+		}
+	default:
+		p.Err = errors.New("not a git, hg or bzr repository, as left by an interrupted clone")
+	}
+	if ctx.Err() != nil {
+		return p, ctx.Err()
+	}
+
+	if p.Err != nil && repair {
+		if err := os.RemoveAll(path); err != nil {
+			return p, err
+		}
+		p.Removed = true
+	}
+	return p, nil
+}
+
+// localCopyVCS returns the VCS of the local copy of a source at path, or ""
+// if it's not a git, hg or bzr repository.
+func localCopyVCS(path string) string {
+	for _, vcs := range []string{"git", "hg", "bzr"} {
+		if is, _ := fs.IsDir(filepath.Join(path, "."+vcs)); is {
+			return vcs
+		}
+	}
+	return ""
+}
+
+// localCopyRemote returns the URL of the upstream of the local copy of a
+// source at path, a repository of the given VCS, or "" if it can't be told.
+func localCopyRemote(ctx context.Context, path, vcs string) string {
+	switch vcs {
+	case "git":
+		return commandOutput(runFromDir(ctx, path, defaultCmdTimeout, "git", "config", "--get", "remote.origin.url"))
+	case "hg":
+		return commandOutput(runFromDir(ctx, path, defaultCmdTimeout, "hg", "paths", "default"))
+	case "bzr":
+		return commandOutput(runFromDir(ctx, path, defaultCmdTimeout, "bzr", "config", "parent_location"))
+	}
+	return ""
+}
+
+// commandOutput returns the output of a successful command, trimmed, or the
+// empty string if it failed.
+func commandOutput(out []byte, err error) string {
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commandError describes the failure of a command by the first line of its
+// output, or by err if it has none.
+func commandError(out []byte, err error) string {
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	cachedir, err := ioutil.TempDir("", "cacheverify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+	sources := filepath.Join(cachedir, "sources")
+
+	// A healthy repository, one left without commits by an interrupted clone,
+	// and a directory which isn't a repository at all.
+	good := filepath.Join(sources, "https---example.com-good")
+	empty := filepath.Join(sources, "https---example.com-empty")
+	for _, dir := range []string{good, empty, filepath.Join(sources, "https---example.com-junk")} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitCmds := [][]string{
+		{"init", good},
+		{"-C", good, "-c", "user.name=dep", "-c", "user.email=dep@example.com", "commit", "--allow-empty", "-m", "initial"},
+		{"-C", good, "remote", "add", "origin", "https://example.com/good"},
+		{"init", empty},
+		{"-C", empty, "remote", "add", "origin", "https://example.com/empty"},
+	}
+	for _, args := range gitCmds {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+
+	// The temporary directory of an interrupted download from a cache server,
+	// and that of one in progress.
+	stale := filepath.Join(cachedir, "trees", "abc", ".download123")
+	inProgress := filepath.Join(cachedir, "trees", "abc", ".download456")
+	for _, dir := range []string{stale, inProgress} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleDownloadAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := VerifyCache(context.Background(), cachedir, cachedir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CacheProblem{
+		{Path: filepath.Join("sources", "https---example.com-empty"), Source: "https://example.com/empty"},
+		{Path: filepath.Join("sources", "https---example.com-junk")},
+		{Path: filepath.Join("trees", "abc", ".download123")},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %+v", len(want), problems)
+	}
+	for i, p := range problems {
+		if p.Path != want[i].Path || p.Source != want[i].Source || p.Err == nil || p.Removed {
+			t.Errorf("unexpected problem:\n\t(GOT): %+v\n\t(WNT): %+v", p, want[i])
+		}
+	}
+
+	problems, err = VerifyCache(context.Background(), cachedir, cachedir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %+v", len(want), problems)
+	}
+	for _, p := range problems {
+		if !p.Removed {
+			t.Errorf("expected %s to be removed", p.Path)
+		}
+		if _, err := os.Stat(filepath.Join(cachedir, p.Path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", p.Path, err)
+		}
+	}
+	for _, dir := range []string{good, inProgress} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("expected %s to be kept, got %v", dir, err)
+		}
+	}
+
+	if problems, err = VerifyCache(context.Background(), cachedir, cachedir, false); err != nil || len(problems) != 0 {
+		t.Errorf("expected no problem once repaired, got %+v, %v", problems, err)
+	}
+}
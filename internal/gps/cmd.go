@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"sync"
 	"sync/atomic"
@@ -188,14 +189,56 @@ func (e killCmdError) Error() string {
 
 func runFromCwd(ctx context.Context, timeout time.Duration, cmd string, args ...string) ([]byte, error) {
 	c := newMonitoredCmd(exec.Command(cmd, args...), timeout)
+	setCmdEnv(ctx, c.cmd)
+	return c.combinedOutput(ctx)
+}
+
+func runFromDir(ctx context.Context, dir string, timeout time.Duration, cmd string, args ...string) ([]byte, error) {
+	c := newMonitoredCmd(exec.Command(cmd, args...), timeout)
+	c.cmd.Dir = dir
+	setCmdEnv(ctx, c.cmd)
 	return c.combinedOutput(ctx)
 }
 
 func runFromRepoDir(ctx context.Context, repo vcs.Repo, timeout time.Duration, cmd string, args ...string) ([]byte, error) {
 	c := newMonitoredCmd(repo.CmdFromDir(cmd, args...), timeout)
+	setCmdEnv(ctx, c.cmd)
 	return c.combinedOutput(ctx)
 }
 
+// gitEnv is set for all the git commands run by gps. Nobody is there to answer
+// git's prompts, so git must not ask for credentials on the terminal, where it
+// would hang until killed for lack of activity, nor through an askpass
+// program. Configured credential helpers are still consulted; Git Credential
+// Manager is asked to fail instead of opening a dialog.
+var gitEnv = []string{
+	"GIT_TERMINAL_PROMPT=0",
+	"GIT_ASKPASS=",
+	"GCM_INTERACTIVE=never",
+}
+
+// setCmdEnv sets up the environment of a command run by gps: all of them get
+// the proxies gps uses, and git commands get gitEnv and the credentials ctx
+// carries, if any.
+func setCmdEnv(ctx context.Context, cmd *exec.Cmd) {
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = mergeEnvLists(proxyConfigFromEnv(os.Getenv).env(), env)
+	isGit := len(cmd.Args) > 0 && cmd.Args[0] == "git"
+	if isGit {
+		env = mergeEnvLists(gitEnv, env)
+	}
+	cmd.Env = env
+	if creds := credentialsFrom(ctx); isGit && creds != nil {
+		creds.setGitCmd(cmd)
+	}
+	if pp := protocolPoliciesFrom(ctx); isGit && pp != nil {
+		pp.setGitCmd(cmd)
+	}
+}
+
 const (
 	// expensiveCmdTimeout is meant to be used in a command that is expensive
 	// in terms of computation and we know it will take long or one that uses
@@ -122,3 +122,43 @@ func TestCombinedOutput(t *testing.T) {
 		t.Errorf("Expecting to receive output from stderr")
 	}
 }
+
+func TestGitCommandsDoNotPrompt(t *testing.T) {
+	requiresBins(t, "git")
+
+	// Have git print the environment it runs its subcommands with.
+	out, err := runFromCwd(context.Background(), defaultCmdTimeout, "git", "-c", "alias.printenv=!env", "printenv")
+	if err != nil {
+		t.Fatalf("%s: %s", out, err)
+	}
+
+	env := strings.Split(string(out), "\n")
+	for _, kv := range gitEnv {
+		found := false
+		for _, got := range env {
+			if got == kv {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected %s to be set for git commands", kv)
+		}
+	}
+}
+
+func TestIsGitAuthFailure(t *testing.T) {
+	cases := map[string]bool{
+		"fatal: could not read Username for 'https://github.com': terminal prompts disabled":                    true,
+		"remote: HTTP Basic: Access denied\nfatal: Authentication failed for 'https://gitlab.com/foo/bar.git/'": true,
+		"git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.":         true,
+		"fatal: repository 'https://github.com/foo/bar/' not found":                                             false,
+		"fatal: unable to access 'https://example.com/foo/': Could not resolve host: example.com":               false,
+	}
+
+	for out, want := range cases {
+		if got := isGitAuthFailure([]byte(out)); got != want {
+			t.Errorf("isGitAuthFailure(%q) = %v, want %v", out, got, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Credential holds what gps authenticates with to a host serving sources.
+type Credential struct {
+	// Host is the name of the host, followed by its port if it isn't the
+	// default one.
+	Host string
+	// Username and Password are used for HTTP basic authentication, by git
+	// over HTTPS, and for the go-get metadata of import paths and the module
+	// proxies. An API token is used as the password, with the username the
+	// host expects, such as x-access-token for GitHub or oauth2 for GitLab.
+	// They are never sent over plain HTTP.
+	Username, Password string
+	// SSHKey is the path to the private key ssh authenticates to the host
+	// with, instead of those it would pick otherwise.
+	SSHKey string
+}
+
+// credentials holds the credentials of a SourceMgr, which its supervisor
+// passes down to the calls it runs in their context.
+type credentials struct {
+	byHost map[string]Credential
+	// hosts are those of byHost, sorted, for git to be configured the same way
+	// every time.
+	hosts []string
+	// sshConfig is the path of the ssh configuration file selecting the keys
+	// of the hosts, if any has one.
+	sshConfig string
+}
+
+func newCredentials(creds []Credential) (*credentials, error) {
+	c := &credentials{byHost: make(map[string]Credential, len(creds))}
+	var sshHosts []string
+	for _, cred := range creds {
+		host := strings.ToLower(cred.Host)
+		if host == "" || strings.ContainsAny(host, "/ \t\n") {
+			return nil, errors.Errorf("invalid host %q for credentials", cred.Host)
+		}
+		if _, dup := c.byHost[host]; dup {
+			return nil, errors.Errorf("multiple credentials for %s", host)
+		}
+		if cred.SSHKey != "" {
+			if _, err := os.Stat(cred.SSHKey); err != nil {
+				return nil, errors.Wrapf(err, "invalid SSH key for %s", host)
+			}
+			sshHosts = append(sshHosts, host)
+		}
+		c.byHost[host] = cred
+		c.hosts = append(c.hosts, host)
+	}
+	sort.Strings(c.hosts)
+	sort.Strings(sshHosts)
+
+	if len(sshHosts) > 0 {
+		if err := c.writeSSHConfig(sshHosts); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// writeSSHConfig writes the ssh configuration file making ssh use the keys of
+// the given hosts. The configuration of the user applies to the rest.
+func (c *credentials) writeSSHConfig(hosts []string) error {
+	var buf bytes.Buffer
+	for _, host := range hosts {
+		name := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			name = h
+		}
+		key, err := filepath.Abs(c.byHost[host].SSHKey)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "Host %s\n  IdentityFile \"%s\"\n  IdentitiesOnly yes\n", name, filepath.ToSlash(key))
+	}
+	buf.WriteString("Host *\n  Include ~/.ssh/config\n")
+
+	f, err := ioutil.TempFile("", "dep-ssh-config")
+	if err != nil {
+		return errors.Wrap(err, "failed to write the ssh configuration")
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to write the ssh configuration")
+	}
+	c.sshConfig = f.Name()
+	return nil
+}
+
+// release removes the files written for the credentials.
+func (c *credentials) release() {
+	if c != nil && c.sshConfig != "" {
+		os.Remove(c.sshConfig)
+	}
+}
+
+// forURL returns the credential for the host of u, if any.
+func (c *credentials) forURL(u *url.URL) (Credential, bool) {
+	if c == nil {
+		return Credential{}, false
+	}
+	cred, has := c.byHost[strings.ToLower(u.Host)]
+	return cred, has
+}
+
+// authorize sets the basic authentication of req, if it goes over HTTPS to a
+// host with a username or password.
+func (c *credentials) authorize(req *http.Request) bool {
+	cred, has := c.forURL(req.URL)
+	if !has || req.URL.Scheme != "https" || (cred.Username == "" && cred.Password == "") {
+		return false
+	}
+	req.SetBasicAuth(cred.Username, cred.Password)
+	return true
+}
+
+// setGitCmd configures the git command cmd, whose environment is set already,
+// to authenticate with the credentials. Passwords are handed to git by a
+// credential helper reading them from the environment, so that they don't show
+// among the arguments of the process.
+func (c *credentials) setGitCmd(cmd *exec.Cmd) {
+	var args, env []string
+	for i, host := range c.hosts {
+		cred := c.byHost[host]
+		if cred.Username == "" && cred.Password == "" {
+			continue
+		}
+		key := "credential.https://" + host + ".helper"
+		user, pass := fmt.Sprintf("DEP_GIT_USERNAME_%d", i), fmt.Sprintf("DEP_GIT_PASSWORD_%d", i)
+		// The empty helper drops those configured for the host, so that
+		// these credentials come first.
+		args = append(args, "-c", key+"=", "-c",
+			fmt.Sprintf(`%s=!f() { test "$1" = get && printf 'username=%%s\npassword=%%s\n' "$%s" "$%s"; }; f`, key, user, pass))
+		env = append(env, user+"="+cred.Username, pass+"="+cred.Password)
+	}
+
+	// An ssh command set by the user is left alone.
+	if c.sshConfig != "" && os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -F '%s'", filepath.ToSlash(c.sshConfig)))
+	}
+
+	if len(args) > 0 {
+		cmd.Args = append(append([]string{cmd.Args[0]}, args...), cmd.Args[1:]...)
+	}
+	cmd.Env = mergeEnvLists(env, cmd.Env)
+}
+
+type credentialsKey struct{}
+
+// withCredentials returns a copy of ctx carrying c, for the commands and HTTP
+// requests run in it.
+func withCredentials(ctx context.Context, c *credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, c)
+}
+
+// credentialsFrom returns the credentials ctx carries, or nil.
+func credentialsFrom(ctx context.Context) *credentials {
+	c, _ := ctx.Value(credentialsKey{}).(*credentials)
+	return c
+}
+
+// httpAuthFailure indicates that an HTTP server refused a request for lack of
+// valid credentials.
+type httpAuthFailure struct {
+	url    string
+	status string
+	// sent tells whether credentials were sent, and so rejected.
+	sent bool
+}
+
+func (e httpAuthFailure) Error() string {
+	if e.sent {
+		return fmt.Sprintf("authentication failed for %s (%s): the credentials configured for its host were rejected", e.url, e.status)
+	}
+	return fmt.Sprintf("authentication required for %s (%s): no credentials are configured for its host", e.url, e.status)
+}
+
+// isHTTPAuthFailure reports whether resp refused the request for lack of
+// valid credentials.
+func isHTTPAuthFailure(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
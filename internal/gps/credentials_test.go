@@ -0,0 +1,173 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNewCredentialsInvalid(t *testing.T) {
+	cases := map[string][]Credential{
+		"no host":         {{Username: "u", Password: "p"}},
+		"host with path":  {{Host: "github.com/org", Password: "p"}},
+		"duplicate hosts": {{Host: "github.com", Password: "a"}, {Host: "GitHub.com", Password: "b"}},
+		"missing ssh key": {{Host: "github.com", SSHKey: filepath.Join(os.TempDir(), "dep-no-such-key")}},
+	}
+	for name, creds := range cases {
+		if _, err := newCredentials(creds); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCredentialsAuthorize(t *testing.T) {
+	c, err := newCredentials([]Credential{
+		{Host: "github.com", Username: "x-access-token", Password: "secret"},
+		{Host: "git.example.com:8443", Username: "me", Password: "pass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url        string
+		user, pass string
+	}{
+		{"https://github.com/foo/bar?go-get=1", "x-access-token", "secret"},
+		{"https://GitHub.com/foo/bar?go-get=1", "x-access-token", "secret"},
+		{"https://git.example.com:8443/foo", "me", "pass"},
+		// Credentials never go over plain HTTP, nor to other hosts.
+		{"http://github.com/foo/bar?go-get=1", "", ""},
+		{"https://git.example.com/foo", "", ""},
+		{"https://gitlab.com/foo/bar", "", ""},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent := c.authorize(req)
+		user, pass, ok := req.BasicAuth()
+		if ok != sent || user != tc.user || pass != tc.pass {
+			t.Errorf("%s: expected credentials %q:%q, got %q:%q (sent %v)", tc.url, tc.user, tc.pass, user, pass, sent)
+		}
+	}
+
+	var none *credentials
+	req, _ := http.NewRequest("GET", "https://github.com/foo/bar", nil)
+	if none.authorize(req) {
+		t.Error("expected no credentials to be sent without any")
+	}
+}
+
+func TestCredentialsGitHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the credential helper needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	c, err := newCredentials([]Credential{
+		{Host: "git.example.com", Username: "me", Password: "s3cr3t"},
+		{Host: "other.example.com", Username: "you", Password: "hunter2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withCredentials(context.Background(), c)
+
+	cmd := exec.Command("git", "credential", "fill")
+	setCmdEnv(ctx, cmd)
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=git.example.com\npath=foo/bar.git\n\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git credential fill failed: %s\n%s", err, out)
+	}
+	for _, want := range []string{"username=me\n", "password=s3cr3t\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q from git, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(strings.Join(cmd.Args, " "), "s3cr3t") {
+		t.Errorf("expected the password not to be among the arguments: %q", cmd.Args)
+	}
+}
+
+func TestCredentialsSSHConfig(t *testing.T) {
+	key, err := ioutil.TempFile("", "dep-ssh-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Close()
+	defer os.Remove(key.Name())
+
+	c, err := newCredentials([]Credential{
+		{Host: "github.com", SSHKey: key.Name()},
+		{Host: "git.example.com:2222", SSHKey: key.Name()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.sshConfig == "" {
+		t.Fatal("expected an ssh configuration to be written")
+	}
+
+	b, err := ioutil.ReadFile(c.sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs(key.Name())
+	want := "Host git.example.com\n  IdentityFile \"" + filepath.ToSlash(abs) + "\"\n  IdentitiesOnly yes\n" +
+		"Host github.com\n  IdentityFile \"" + filepath.ToSlash(abs) + "\"\n  IdentitiesOnly yes\n" +
+		"Host *\n  Include ~/.ssh/config\n"
+	if string(b) != want {
+		t.Errorf("unexpected ssh configuration:\n(GOT):\n%s\n(WNT):\n%s", b, want)
+	}
+
+	cmd := exec.Command("git", "ls-remote", "ssh://git@github.com/foo/bar")
+	cmd.Env = []string{}
+	c.setGitCmd(cmd)
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		want := "GIT_SSH_COMMAND=ssh -F '" + filepath.ToSlash(c.sshConfig) + "'"
+		if len(cmd.Env) != 1 || cmd.Env[0] != want {
+			t.Errorf("expected the environment %q, got %q", want, cmd.Env)
+		}
+	}
+
+	c.release()
+	if _, err := os.Stat(c.sshConfig); !os.IsNotExist(err) {
+		t.Errorf("expected the ssh configuration to be removed on release")
+	}
+}
+
+func TestFetchMetadataAuthFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	path := strings.TrimPrefix(srv.URL, "http://") + "/foo/bar"
+	_, err := fetchMetadata(context.Background(), path, "http")
+	authErr, ok := err.(httpAuthFailure)
+	if !ok {
+		t.Fatalf("expected an authentication failure, got %v", err)
+	}
+	if authErr.sent {
+		t.Error("expected no credentials to have been sent")
+	}
+	if !strings.Contains(err.Error(), "no credentials are configured") {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
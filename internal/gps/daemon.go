@@ -0,0 +1,449 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// daemonRequest is the JSON form of a call to a SourceManager served by a
+// daemon. Only the fields the method takes are set.
+type daemonRequest struct {
+	Method   string              `json:"method"`
+	ID       ProjectIdentifier   `json:"id"`
+	Version  *cachedVersion      `json:"version,omitempty"`
+	To       *cachedVersion      `json:"to,omitempty"`
+	Ref      string              `json:"ref,omitempty"`
+	Revision Revision            `json:"revision,omitempty"`
+	Time     time.Time           `json:"time,omitempty"`
+	Dir      string              `json:"dir,omitempty"`
+	Path     string              `json:"path,omitempty"`
+	Analyzer ProjectAnalyzerInfo `json:"analyzer"`
+	Settings DaemonSettings      `json:"settings,omitempty"`
+}
+
+// daemonResponse is the JSON form of the outcome of a daemonRequest.
+type daemonResponse struct {
+	Error      string             `json:"error,omitempty"`
+	NoAnalyzer bool               `json:"noAnalyzer,omitempty"`
+	Bool       bool               `json:"bool,omitempty"`
+	Root       ProjectRoot        `json:"root,omitempty"`
+	Versions   []cachedVersion    `json:"versions,omitempty"`
+	Tree       *cachedPackageTree `json:"tree,omitempty"`
+	Info       *cachedProjectInfo `json:"info,omitempty"`
+	Log        []VersionInfo      `json:"log,omitempty"`
+	Revision   Revision           `json:"revision,omitempty"`
+	Mismatch   []string           `json:"mismatch,omitempty"`
+}
+
+// DaemonSettings holds digests of the settings a SourceMgr serves sources
+// with, such as its credentials or source overrides, keyed by their name. A
+// daemon only serves the clients with the same settings as its own, as it
+// would ignore theirs.
+type DaemonSettings map[string]string
+
+// mismatch returns the sorted names of the settings which differ between s
+// and other.
+func (s DaemonSettings) mismatch(other DaemonSettings) []string {
+	var names []string
+	for name, v := range s {
+		if other[name] != v {
+			names = append(names, name)
+		}
+	}
+	for name := range other {
+		if _, has := s[name]; !has {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DaemonSettingsError is returned by UseDaemon when the daemon serves sources
+// with other settings than the client's.
+type DaemonSettingsError struct {
+	Socket   string
+	Settings []string // names of the settings which differ
+}
+
+func (e *DaemonSettingsError) Error() string {
+	return fmt.Sprintf("the dep daemon on %s serves sources with other settings than these: %s; stop it, or use its settings", e.Socket, strings.Join(e.Settings, ", "))
+}
+
+// NewSourceManagerDaemon returns an http.Handler serving the operations of sm
+// to the SourceMgrs configured with UseDaemon, so that several processes share
+// its sources and caches. Only the clients with the given settings, those of
+// sm, are served. The manifests and locks of projects are derived with
+// whichever of the given analyzers has the Info of the analyzer of the client;
+// the clients with other analyzers derive them from an export of the project.
+// Calls are logged to logger.
+func NewSourceManagerDaemon(sm SourceManager, settings DaemonSettings, logger *log.Logger, analyzers ...ProjectAnalyzer) http.Handler {
+	ans := make(map[ProjectAnalyzerInfo]ProjectAnalyzer, len(analyzers))
+	for _, an := range analyzers {
+		ans[an.Info()] = an
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/call" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req daemonRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "ping":
+		case "DeduceProjectRoot":
+			logger.Printf("%s %s", req.Method, req.Path)
+		default:
+			logger.Printf("%s %s", req.Method, req.ID.errString())
+		}
+
+		var resp daemonResponse
+		var err error
+		if req.Method == "ping" {
+			if resp.Mismatch = settings.mismatch(req.Settings); len(resp.Mismatch) > 0 {
+				logger.Printf("Refused a client with other settings: %s", strings.Join(resp.Mismatch, ", "))
+			}
+		} else {
+			resp, err = serveDaemonRequest(sm, ans, req)
+		}
+		if err != nil {
+			resp = daemonResponse{Error: err.Error()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+func serveDaemonRequest(sm SourceManager, ans map[ProjectAnalyzerInfo]ProjectAnalyzer, req daemonRequest) (resp daemonResponse, err error) {
+	var v, to Version
+	if req.Version != nil {
+		if v, err = req.Version.version(); err != nil {
+			return resp, err
+		}
+	}
+	if req.To != nil {
+		if to, err = req.To.version(); err != nil {
+			return resp, err
+		}
+	}
+
+	switch req.Method {
+	case "SourceExists":
+		resp.Bool, err = sm.SourceExists(req.ID)
+	case "SyncSourceFor":
+		err = sm.SyncSourceFor(req.ID)
+	case "ListVersions":
+		var pvl []PairedVersion
+		if pvl, err = sm.ListVersions(req.ID); err == nil {
+			resp.Versions, err = toCachedVersions(pvl)
+		}
+	case "PairRef":
+		var pv PairedVersion
+		if pv, err = sm.PairRef(req.ID, req.Ref); err == nil {
+			resp.Versions, err = toCachedVersions([]PairedVersion{pv})
+		}
+	case "RevisionPresentIn":
+		resp.Bool, err = sm.RevisionPresentIn(req.ID, req.Revision)
+	case "ListPackages":
+		var ptree pkgtree.PackageTree
+		if ptree, err = sm.ListPackages(req.ID, v); err == nil {
+			cpt := toCachedPackageTree(ptree)
+			resp.Tree = &cpt
+		}
+	case "GetManifestAndLock":
+		an, ok := ans[req.Analyzer]
+		if !ok {
+			resp.NoAnalyzer = true
+			return resp, nil
+		}
+		var m Manifest
+		var l Lock
+		if m, l, err = sm.GetManifestAndLock(req.ID, v, an); err == nil {
+			var info cachedProjectInfo
+			if info, err = toCachedProjectInfo(m, l); err == nil {
+				resp.Info = &info
+			}
+		}
+	case "ExportProject":
+		if !filepath.IsAbs(req.Dir) {
+			return resp, errors.Errorf("the daemon only exports to absolute paths, not %s", req.Dir)
+		}
+		err = sm.ExportProject(req.ID, v, req.Dir)
+	case "VersionInfo":
+		var vi VersionInfo
+		if vi, err = sm.VersionInfo(req.ID, v); err == nil {
+			resp.Log = []VersionInfo{vi}
+		}
+	case "CommitLog":
+		resp.Log, err = sm.CommitLog(req.ID, v, to)
+	case "RevisionAsOf":
+		resp.Revision, err = sm.RevisionAsOf(req.ID, v, req.Time)
+	case "DeduceProjectRoot":
+		resp.Root, err = sm.DeduceProjectRoot(req.Path)
+	default:
+		err = errors.Errorf("unknown method %q", req.Method)
+	}
+	return resp, err
+}
+
+func toCachedVersions(pvl []PairedVersion) ([]cachedVersion, error) {
+	cvl := make([]cachedVersion, len(pvl))
+	for i, pv := range pvl {
+		cv, err := toCachedVersion(pv)
+		if err != nil {
+			return nil, err
+		}
+		cvl[i] = cv
+	}
+	return cvl, nil
+}
+
+// daemonClient calls the operations of a SourceManager served by a daemon
+// listening on a unix socket.
+type daemonClient struct {
+	socket string
+	client *http.Client
+}
+
+func newDaemonClient(socket string) *daemonClient {
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return &daemonClient{
+		socket: socket,
+		client: &http.Client{Transport: &http.Transport{
+			DialContext:         dial,
+			MaxIdleConnsPerHost: 16,
+		}},
+	}
+}
+
+// call sends req to the daemon and returns its response. The errors of the
+// SourceManager of the daemon are returned as plain errors.
+func (c *daemonClient) call(req daemonRequest) (daemonResponse, error) {
+	var resp daemonResponse
+	body, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+
+	// The host is ignored, as the connection is made to the socket.
+	hresp, err := c.client.Post("http://dep-daemon/call", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return resp, errors.Wrapf(err, "failed to reach the dep daemon at %s", c.socket)
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("dep daemon returned %s", hresp.Status)
+	}
+	if err := json.NewDecoder(hresp.Body).Decode(&resp); err != nil {
+		return resp, errors.Wrap(err, "invalid response from the dep daemon")
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// ping checks that the daemon answers, and serves sources with the given
+// settings.
+func (c *daemonClient) ping(settings DaemonSettings) error {
+	resp, err := c.call(daemonRequest{Method: "ping", Settings: settings})
+	if err != nil {
+		return err
+	}
+	if len(resp.Mismatch) > 0 {
+		return &DaemonSettingsError{Socket: c.socket, Settings: resp.Mismatch}
+	}
+	return nil
+}
+
+func (c *daemonClient) versionCall(method string, id ProjectIdentifier, v Version) (daemonRequest, error) {
+	req := daemonRequest{Method: method, ID: id}
+	if v != nil {
+		cv, err := toCachedVersion(v)
+		if err != nil {
+			return req, err
+		}
+		req.Version = &cv
+	}
+	return req, nil
+}
+
+func (c *daemonClient) sourceExists(id ProjectIdentifier) (bool, error) {
+	resp, err := c.call(daemonRequest{Method: "SourceExists", ID: id})
+	return resp.Bool, err
+}
+
+func (c *daemonClient) syncSourceFor(id ProjectIdentifier) error {
+	_, err := c.call(daemonRequest{Method: "SyncSourceFor", ID: id})
+	return err
+}
+
+func (c *daemonClient) listVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	resp, err := c.call(daemonRequest{Method: "ListVersions", ID: id})
+	if err != nil {
+		return nil, err
+	}
+	return fromCachedVersions(resp.Versions)
+}
+
+func (c *daemonClient) pairRef(id ProjectIdentifier, ref string) (PairedVersion, error) {
+	resp, err := c.call(daemonRequest{Method: "PairRef", ID: id, Ref: ref})
+	if err != nil {
+		return nil, err
+	}
+	pvl, err := fromCachedVersions(resp.Versions)
+	if err != nil {
+		return nil, err
+	}
+	if len(pvl) != 1 {
+		return nil, errors.New("invalid response from the dep daemon")
+	}
+	return pvl[0], nil
+}
+
+func (c *daemonClient) revisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
+	resp, err := c.call(daemonRequest{Method: "RevisionPresentIn", ID: id, Revision: r})
+	return resp.Bool, err
+}
+
+func (c *daemonClient) listPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	req, err := c.versionCall("ListPackages", id, v)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	resp, err := c.call(req)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	if resp.Tree == nil {
+		return pkgtree.PackageTree{}, errors.New("invalid response from the dep daemon")
+	}
+	return resp.Tree.packageTree(), nil
+}
+
+func (c *daemonClient) getManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	req, err := c.versionCall("GetManifestAndLock", id, v)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Analyzer = an.Info()
+	resp, err := c.call(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.NoAnalyzer {
+		// The daemon can't run this analyzer, so run it here on a copy of
+		// the project.
+		dir, err := ioutil.TempDir("", "dep-daemon")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(dir)
+		if err := c.exportProject(id, v, dir); err != nil {
+			return nil, nil, err
+		}
+		return an.DeriveManifestAndLock(dir, id.ProjectRoot)
+	}
+	if resp.Info == nil {
+		return nil, nil, errors.New("invalid response from the dep daemon")
+	}
+	return resp.Info.manifestAndLock()
+}
+
+func (c *daemonClient) exportProject(id ProjectIdentifier, v Version, to string) error {
+	req, err := c.versionCall("ExportProject", id, v)
+	if err != nil {
+		return err
+	}
+	if req.Dir, err = filepath.Abs(to); err != nil {
+		return err
+	}
+	_, err = c.call(req)
+	return err
+}
+
+func (c *daemonClient) versionInfo(id ProjectIdentifier, v Version) (VersionInfo, error) {
+	req, err := c.versionCall("VersionInfo", id, v)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	resp, err := c.call(req)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	if len(resp.Log) != 1 {
+		return VersionInfo{}, errors.New("invalid response from the dep daemon")
+	}
+	return resp.Log[0], nil
+}
+
+func (c *daemonClient) commitLog(id ProjectIdentifier, from, to Version) ([]VersionInfo, error) {
+	req, err := c.versionCall("CommitLog", id, from)
+	if err != nil {
+		return nil, err
+	}
+	cto, err := toCachedVersion(to)
+	if err != nil {
+		return nil, err
+	}
+	req.To = &cto
+	resp, err := c.call(req)
+	return resp.Log, err
+}
+
+func (c *daemonClient) revisionAsOf(id ProjectIdentifier, v Version, t time.Time) (Revision, error) {
+	req, err := c.versionCall("RevisionAsOf", id, v)
+	if err != nil {
+		return "", err
+	}
+	req.Time = t
+	resp, err := c.call(req)
+	return resp.Revision, err
+}
+
+func (c *daemonClient) deduceProjectRoot(ip string) (ProjectRoot, error) {
+	resp, err := c.call(daemonRequest{Method: "DeduceProjectRoot", Path: ip})
+	return resp.Root, err
+}
+
+func fromCachedVersions(cvl []cachedVersion) ([]PairedVersion, error) {
+	pvl := make([]PairedVersion, len(cvl))
+	for i, cv := range cvl {
+		v, err := cv.version()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid response from the dep daemon")
+		}
+		pv, ok := v.(PairedVersion)
+		if !ok {
+			return nil, errors.Errorf("invalid response from the dep daemon: version %s has no revision", v)
+		}
+		pvl[i] = pv
+	}
+	return pvl, nil
+}
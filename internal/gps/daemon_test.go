@@ -0,0 +1,180 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// daemonTestSM serves a fixed set of versions and a single file for a single
+// source, deduced from the import paths under its root.
+type daemonTestSM struct {
+	SourceManager
+	root ProjectRoot
+	pvl  []PairedVersion
+}
+
+func (sm daemonTestSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	if id.ProjectRoot != sm.root {
+		return nil, errors.Errorf("unknown project %s", id.ProjectRoot)
+	}
+	return sm.pvl, nil
+}
+
+func (sm daemonTestSM) DeduceProjectRoot(ip string) (ProjectRoot, error) {
+	if !strings.HasPrefix(ip, string(sm.root)) {
+		return "", errors.Errorf("unable to deduce repository and source type for %q", ip)
+	}
+	return sm.root, nil
+}
+
+func (sm daemonTestSM) ExportProject(id ProjectIdentifier, v Version, to string) error {
+	if v != sm.pvl[0].Revision() {
+		return errors.Errorf("unknown version %s", v)
+	}
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(to, "bar.go"), []byte("package bar\n"), 0666)
+}
+
+func (sm daemonTestSM) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	return an.DeriveManifestAndLock("", id.ProjectRoot)
+}
+
+// otherAnalyzer reports whether the project it analyzes was exported.
+type otherAnalyzer struct {
+	exported *bool
+}
+
+func (a otherAnalyzer) DeriveManifestAndLock(dir string, pr ProjectRoot) (Manifest, Lock, error) {
+	_, err := os.Stat(filepath.Join(dir, "bar.go"))
+	*a.exported = err == nil
+	return nil, nil, nil
+}
+
+func (otherAnalyzer) Info() ProjectAnalyzerInfo {
+	return ProjectAnalyzerInfo{Name: "other-analyzer", Version: 1}
+}
+
+func TestSourceManagerDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "smdaemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "daemon.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	dsm := daemonTestSM{
+		root: "github.com/foo/bar",
+		pvl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("abc123"),
+			newDefaultBranch("master").Pair("def456"),
+		},
+	}
+	go http.Serve(l, NewSourceManagerDaemon(dsm, DaemonSettings{"offline": "1"}, log.New(ioutil.Discard, "", 0), naiveAnalyzer{}))
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	// The clients with other settings are refused, as the daemon would ignore
+	// them.
+	err = sm.UseDaemon(socket, DaemonSettings{"offline": "1", "source-override": "2"})
+	if serr, ok := err.(*DaemonSettingsError); !ok || !reflect.DeepEqual(serr.Settings, []string{"source-override"}) {
+		t.Fatalf("expected a *DaemonSettingsError naming source-override, got %v", err)
+	}
+	if err = sm.UseDaemon(socket, DaemonSettings{}); err == nil {
+		t.Fatal("expected a client without the settings of the daemon to be refused")
+	}
+	if sm.daemon != nil {
+		t.Fatal("expected the SourceMgr not to use the daemon")
+	}
+
+	if err := sm.UseDaemon(socket, DaemonSettings{"offline": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := sm.DeduceProjectRoot("github.com/foo/bar/baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != dsm.root {
+		t.Fatalf("expected root %s, got %s", dsm.root, root)
+	}
+	if _, err := sm.DeduceProjectRoot("example.com/nope"); err == nil || !strings.Contains(err.Error(), "unable to deduce") {
+		t.Fatalf("expected the error of the daemon, got %v", err)
+	}
+
+	id := mkPI("github.com/foo/bar")
+	pvl, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pvl, dsm.pvl) {
+		t.Fatalf("expected versions %v, got %v", dsm.pvl, pvl)
+	}
+
+	to := filepath.Join(dir, "export")
+	if err := sm.ExportProject(id, Revision("abc123"), to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "bar.go")); err != nil {
+		t.Fatalf("expected the project to be exported: %s", err)
+	}
+
+	if _, _, err := sm.GetManifestAndLock(id, Revision("abc123"), naiveAnalyzer{}); err != nil {
+		t.Fatal(err)
+	}
+	// The analyzers the daemon doesn't have run on an export of the project.
+	var exported bool
+	if _, _, err := sm.GetManifestAndLock(id, Revision("abc123"), otherAnalyzer{&exported}); err != nil {
+		t.Fatal(err)
+	}
+	if !exported {
+		t.Fatal("expected the project to be exported for the analyzer")
+	}
+}
+
+func TestSourceManagerUseDaemonUnreachable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "smdaemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+	if err := sm.UseDaemon(filepath.Join(dir, "daemon.sock"), nil); err == nil {
+		t.Fatal("expected an error without a daemon")
+	}
+	if sm.daemon != nil {
+		t.Fatal("expected the SourceMgr not to use the daemon")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -66,7 +67,9 @@ var (
 	//gpinOldRegex = regexp.MustCompile(`^(?P<root>gopkg\.in/(?:([a-z0-9][-a-z0-9]+)/)?((?:v0|v[1-9][0-9]*)(?:\.0|\.[1-9][0-9]*){0,2}(-unstable)?)/([a-zA-Z][-a-zA-Z0-9]*)(?:\.git)?)((?:/[a-zA-Z][-a-zA-Z0-9]*)*)$`)
 	bbRegex = regexp.MustCompile(`^(?P<root>bitbucket\.org(?P<bitname>/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
 	//lpRegex = regexp.MustCompile(`^(?P<root>launchpad\.net/([A-Za-z0-9-._]+)(/[A-Za-z0-9-._]+)?)(/.+)?`)
-	lpRegex = regexp.MustCompile(`^(?P<root>launchpad\.net(/[A-Za-z0-9-._]+))((?:/[A-Za-z0-9_.\-]+)*)?`)
+	// Personal branches, and the +junk branches which belong to no project,
+	// nest their roots under ~user.
+	lpRegex = regexp.MustCompile(`^(?P<root>launchpad\.net(/~[A-Za-z0-9_.\-]+/(?:\+junk|[A-Za-z0-9_.\-]+)/[A-Za-z0-9_.\-]+|/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
 	//glpRegex = regexp.MustCompile(`^(?P<root>git\.launchpad\.net/([A-Za-z0-9_.\-]+)|~[A-Za-z0-9_.\-]+/(\+git|[A-Za-z0-9_.\-]+)/[A-Za-z0-9_.\-]+)$`)
 	glpRegex = regexp.MustCompile(`^(?P<root>git\.launchpad\.net(/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
 	//gcRegex      = regexp.MustCompile(`^(?P<root>code\.google\.com/[pr]/(?P<project>[a-z0-9\-]+)(\.(?P<subrepo>[a-z0-9\-]+))?)(/[A-Za-z0-9_.\-]+)*$`)
@@ -78,7 +81,7 @@ var (
 // Other helper regexes
 var (
 	scpSyntaxRe = regexp.MustCompile(`^([a-zA-Z0-9_]+)@([a-zA-Z0-9._-]+):(.*)$`)
-	pathvld     = regexp.MustCompile(`^([A-Za-z0-9-]+)(\.[A-Za-z0-9-]+)+(/[A-Za-z0-9-_.~]+)*$`)
+	pathvld     = regexp.MustCompile(`^([A-Za-z0-9-]+)(\.[A-Za-z0-9-]+)+(/[A-Za-z0-9-_.~+]+)*$`)
 )
 
 func pathDeducerTrie() *deducerTrie {
@@ -317,6 +320,12 @@ type launchpadDeducer struct {
 func (m launchpadDeducer) deduceRoot(path string) (string, error) {
 	// TODO(sdboyer) lp handling is nasty - there's ambiguities which can only really
 	// be resolved with a metadata request. See https://github.com/golang/go/issues/11436
+	//
+	// Series branches, as in launchpad.net/project/series, can't be told from
+	// the packages of the project's development focus without such a request,
+	// so the project is taken as the root; their source needs to be given
+	// explicitly. Personal branches, as in launchpad.net/~user/project/branch,
+	// are unambiguous.
 	v := m.regexp.FindStringSubmatch(path)
 	if v == nil {
 		return "", fmt.Errorf("%s is not a valid path for a source on launchpad.net", path)
@@ -551,10 +560,95 @@ type deducer interface {
 }
 
 type deductionCoordinator struct {
-	suprvsr  *supervisor
-	mut      sync.RWMutex
-	rootxt   *radix.Tree
-	deducext *deducerTrie
+	suprvsr   *supervisor
+	mut       sync.RWMutex
+	rootxt    *radix.Tree
+	deducext  *deducerTrie
+	overrides []sourceOverride
+}
+
+// SourceOverride directs the deduction of the import paths under Prefix to the
+// git repository at URL, rather than to the one the path would otherwise be
+// deduced to. The repository of an import path below the deduced root of the
+// override's prefix is expected at the same relative path under URL.
+type SourceOverride struct {
+	Prefix string
+	URL    string
+}
+
+type sourceOverride struct {
+	prefix string
+	url    *url.URL
+}
+
+// byPrefixLen sorts source overrides from the longest prefix to the shortest.
+type byPrefixLen []sourceOverride
+
+func (s byPrefixLen) Len() int           { return len(s) }
+func (s byPrefixLen) Less(i, j int) bool { return len(s[i].prefix) > len(s[j].prefix) }
+func (s byPrefixLen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// setOverrides validates the given source overrides, and makes them take
+// precedence over the known and go-get metadata deductions.
+func (dc *deductionCoordinator) setOverrides(overrides []SourceOverride) error {
+	so := make([]sourceOverride, 0, len(overrides))
+	for _, o := range overrides {
+		_, prefix, err := normalizeURI(o.Prefix)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source override prefix %q", o.Prefix)
+		}
+		u, _, err := normalizeURI(o.URL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source override URL %q", o.URL)
+		}
+		if u.Host == "" || !validateVCSScheme(u.Scheme, "git") {
+			return errors.Errorf("invalid source override URL %q: expected an absolute git URL", o.URL)
+		}
+		so = append(so, sourceOverride{prefix: strings.TrimSuffix(prefix, "/"), url: u})
+	}
+	sort.Stable(byPrefixLen(so))
+
+	dc.mut.Lock()
+	dc.overrides = so
+	dc.mut.Unlock()
+	return nil
+}
+
+// deduceOverride deduces the given path to the source of the first of the
+// source overrides its prefix matches, if any.
+func (dc *deductionCoordinator) deduceOverride(p string) (pathDeduction, bool) {
+	dc.mut.RLock()
+	overrides := dc.overrides
+	dc.mut.RUnlock()
+	if len(overrides) == 0 {
+		return pathDeduction{}, false
+	}
+
+	// Full URLs name their source explicitly, so only import paths are
+	// overridden.
+	if _, np, err := normalizeURI(p); err != nil || np != p {
+		return pathDeduction{}, false
+	}
+	for _, o := range overrides {
+		if !strings.HasPrefix(p, o.prefix) || !isPathPrefixOrEqual(o.prefix, p) {
+			continue
+		}
+
+		// Keep the root the path would be deduced to when it lies under the
+		// prefix, so that several projects can share an override; otherwise the
+		// prefix itself is the root.
+		root := o.prefix
+		if _, mtch, has := dc.deducext.LongestPrefix(p); has {
+			if r, err := mtch.deduceRoot(p); err == nil && strings.HasPrefix(r, o.prefix) && isPathPrefixOrEqual(o.prefix, r) {
+				root = r
+			}
+		}
+
+		u := *o.url
+		u.Path = path.Join(u.Path, root[len(o.prefix):])
+		return pathDeduction{root: root, mb: maybeGitSource{url: &u}}, true
+	}
+	return pathDeduction{}, false
 }
 
 func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
@@ -600,7 +694,15 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 		panic(fmt.Sprintf("unexpected %T in deductionCoordinator.rootxt: %v", data, data))
 	}
 
-	// No match. Try known path deduction first.
+	// No match. Source overrides take precedence over any deduction.
+	if pd, has := dc.deduceOverride(path); has {
+		dc.mut.Lock()
+		dc.rootxt.Insert(pd.root, pd.mb)
+		dc.mut.Unlock()
+		return pd, nil
+	}
+
+	// Try known path deduction first.
 	pd, err := dc.deduceKnownPaths(path)
 	if err == nil {
 		// Deduction worked; store it in the rootxt, send on retchan and
@@ -714,6 +816,11 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
+		if hmd.suprvsr.offline {
+			hmd.deduceErr = errors.Wrapf(hmd.suprvsr.denyNetwork(u.Scheme+"://"+path), "unable to deduce repository and source type for %q", opath)
+			return
+		}
+
 		// Make the HTTP call to attempt to retrieve go-get metadata
 		var root, vcs, reporoot string
 		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
@@ -723,6 +830,7 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 			}
 			return err
 		})
+		hmd.suprvsr.recordNetwork(u.Scheme+"://"+path, 0)
 		if err != nil {
 			err = errors.Wrapf(err, "unable to deduce repository and source type for %q", opath)
 			hmd.deduceErr = err
@@ -828,6 +936,10 @@ func fetchMetadata(ctx context.Context, path, scheme string) (rc io.ReadCloser,
 	if err == nil {
 		return
 	}
+	// Falling back to http wouldn't send the credentials.
+	if _, ok := err.(httpAuthFailure); ok {
+		return
+	}
 
 	rc, err = doFetchMetadata(ctx, "http", path)
 	return
@@ -842,10 +954,15 @@ func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, e
 			return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", url)
 		}
 
-		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		sent := credentialsFrom(ctx).authorize(req)
+		resp, err := httpClient.Do(req.WithContext(ctx))
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed HTTP request to URL %q", url)
 		}
+		if isHTTPAuthFailure(resp) {
+			resp.Body.Close()
+			return nil, httpAuthFailure{url: url, status: resp.Status, sent: sent}
+		}
 
 		return resp.Body, nil
 	default:
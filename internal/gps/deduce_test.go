@@ -336,6 +336,30 @@ var pathDeductionFixtures = map[string][]pathDeductionFixture{
 			in:   "launchpad.net/repo root",
 			rerr: errors.New("launchpad.net/repo root is not a valid path for a source on launchpad.net"),
 		},
+		{
+			in:   "launchpad.net/~someone/govcstestbzrrepo/fix-thing/foo",
+			root: "launchpad.net/~someone/govcstestbzrrepo/fix-thing",
+			mb: maybeSources{
+				maybeBzrSource{url: mkurl("https://launchpad.net/~someone/govcstestbzrrepo/fix-thing")},
+				maybeBzrSource{url: mkurl("bzr+ssh://launchpad.net/~someone/govcstestbzrrepo/fix-thing")},
+				maybeBzrSource{url: mkurl("bzr://launchpad.net/~someone/govcstestbzrrepo/fix-thing")},
+				maybeBzrSource{url: mkurl("http://launchpad.net/~someone/govcstestbzrrepo/fix-thing")},
+			},
+		},
+		{
+			in:   "launchpad.net/~someone/+junk/scratch",
+			root: "launchpad.net/~someone/+junk/scratch",
+			mb: maybeSources{
+				maybeBzrSource{url: mkurl("https://launchpad.net/~someone/+junk/scratch")},
+				maybeBzrSource{url: mkurl("bzr+ssh://launchpad.net/~someone/+junk/scratch")},
+				maybeBzrSource{url: mkurl("bzr://launchpad.net/~someone/+junk/scratch")},
+				maybeBzrSource{url: mkurl("http://launchpad.net/~someone/+junk/scratch")},
+			},
+		},
+		{
+			in:   "launchpad.net/~someone/govcstestbzrrepo",
+			rerr: errors.New("launchpad.net/~someone/govcstestbzrrepo is not a valid path for a source on launchpad.net"),
+		},
 	},
 	"git.launchpad": {
 		{
@@ -426,6 +450,17 @@ var pathDeductionFixtures = map[string][]pathDeductionFixture{
 				maybeHgSource{url: mkurl("http://foo-bar.com/baz.hg")},
 			},
 		},
+		{
+			// The subrepositories of an hg repository are checked out within
+			// it, so they share its root.
+			in:   "foo-bar.com/baz.hg/sub.hg/pkg",
+			root: "foo-bar.com/baz.hg",
+			mb: maybeSources{
+				maybeHgSource{url: mkurl("https://foo-bar.com/baz.hg")},
+				maybeHgSource{url: mkurl("ssh://foo-bar.com/baz.hg")},
+				maybeHgSource{url: mkurl("http://foo-bar.com/baz.hg")},
+			},
+		},
 		{
 			in:   "git@foobar.com:baz.git",
 			root: "foobar.com/baz.git",
@@ -657,6 +692,72 @@ func TestVanityDeductionSchemeMismatch(t *testing.T) {
 	}
 }
 
+func TestSourceOverrideDeduction(t *testing.T) {
+	ctx := context.Background()
+	dc := newDeductionCoordinator(newSupervisor(ctx))
+	err := dc.setOverrides([]SourceOverride{
+		{Prefix: "github.com", URL: "https://mirror.example.com/github"},
+		{Prefix: "github.com/sdboyer/deptest", URL: "git@example.com:forks/deptest"},
+		{Prefix: "corp.example.com/lib", URL: "ssh://git@git.example.com/lib.git"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		root, url string
+	}{
+		"github.com/pkg/errors":          {"github.com/pkg/errors", "https://mirror.example.com/github/pkg/errors"},
+		"github.com/sdboyer/deptest/foo": {"github.com/sdboyer/deptest", "ssh://git@example.com/forks/deptest"},
+		"corp.example.com/lib/sub/pkg":   {"corp.example.com/lib", "ssh://git@git.example.com/lib.git"},
+	}
+	for path, want := range cases {
+		pd, err := dc.deduceRootPath(ctx, path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", path, err)
+			continue
+		}
+		if pd.root != want.root {
+			t.Errorf("%s: expected root %s, got %s", path, want.root, pd.root)
+		}
+		mb, ok := pd.mb.(maybeGitSource)
+		if !ok {
+			t.Errorf("%s: expected a git source, got %T", path, pd.mb)
+			continue
+		}
+		if got := mb.url.String(); got != want.url {
+			t.Errorf("%s: expected URL %s, got %s", path, want.url, got)
+		}
+	}
+
+	// Paths no prefix matches, and full URLs, are deduced as usual.
+	for path, want := range map[string]string{
+		"bitbucket.org/sdboyer/reporoot":         "bitbucket.org/sdboyer/reporoot",
+		"https://github.com/sdboyer/deptesttres": "github.com/sdboyer/deptesttres",
+	} {
+		pd, err := dc.deduceRootPath(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pd.root != want {
+			t.Errorf("%s: expected root %s, got %s", path, want, pd.root)
+		}
+		if mb, ok := pd.mb.(maybeGitSource); ok && mb.url.Host != "github.com" && mb.url.Host != "bitbucket.org" {
+			t.Errorf("%s: expected no override, got %s", path, mb.url)
+		}
+	}
+
+	for _, bad := range []SourceOverride{
+		{Prefix: "github.com/pkg", URL: "mirror/errors"},
+		{Prefix: "github.com/pkg", URL: "bzr://example.com/errors"},
+		{Prefix: "", URL: "https://example.com/errors"},
+	} {
+		if err := dc.setOverrides([]SourceOverride{bad}); err == nil {
+			t.Errorf("expected %+v to be refused", bad)
+		}
+	}
+}
+
 // borrow from stdlib
 // more useful string for debugging than fmt's struct printer
 func ufmt(u *url.URL) string {
@@ -0,0 +1,294 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/internal/fs"
+)
+
+// gitSubmodule is a submodule recorded in the tree of a git revision.
+type gitSubmodule struct {
+	// path is the slash-separated path of the submodule within the tree.
+	path string
+	// url is the absolute URL the submodule is fetched from.
+	url string
+	// rev is the commit of the submodule recorded in the tree.
+	rev Revision
+}
+
+// exportGitSubmodules exports the submodules of the given revision of the
+// repository in gitDir, at the revisions recorded in that revision, beneath
+// to, which must already hold the export of the revision itself. Nested
+// submodules are exported as well.
+//
+// Submodules are fetched into bare repositories kept beneath cacheDir, so that
+// later exports only need to fetch what changed upstream.
+func exportGitSubmodules(ctx context.Context, gitDir, remote, cacheDir string, rev Revision, to string) error {
+	subs, err := listGitSubmodules(ctx, gitDir, remote, rev, to)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		modDir := filepath.Join(cacheDir, "dep-submodules", submoduleCacheName(sub.url))
+		if err := fetchGitSubmodule(ctx, modDir, sub); err != nil {
+			return err
+		}
+
+		dest := filepath.Join(to, filepath.FromSlash(sub.path))
+		if err := exportGitTree(ctx, modDir, sub.rev, dest); err != nil {
+			return err
+		}
+		if err := exportGitSubmodules(ctx, modDir, sub.url, cacheDir, sub.rev, dest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listGitSubmodules returns the submodules recorded in the tree of the given
+// revision, matching the gitlinks of the tree against the .gitmodules file
+// exported in dir to find where to fetch them from.
+func listGitSubmodules(ctx context.Context, gitDir, remote string, rev Revision, dir string) ([]gitSubmodule, error) {
+	out, err := runFromCwd(ctx, defaultCmdTimeout, "git", "--git-dir="+gitDir, "ls-tree", "-r", "-z", "--", rev.String())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", out, err)
+	}
+
+	// Each entry reads "<mode> <type> <object>\t<path>"; gitlinks are the
+	// entries of type commit.
+	var subs []gitSubmodule
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		tab := bytes.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(string(entry[:tab]))
+		if len(fields) != 3 || fields[1] != "commit" {
+			continue
+		}
+		subs = append(subs, gitSubmodule{
+			path: string(entry[tab+1:]),
+			rev:  Revision(fields[2]),
+		})
+	}
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	gitmodules := filepath.Join(dir, ".gitmodules")
+	if _, err := os.Stat(gitmodules); err != nil {
+		return nil, fmt.Errorf("%s has submodules at %s, but no .gitmodules file", rev, subs[0].path)
+	}
+	out, err = runFromCwd(ctx, defaultCmdTimeout, "git", "config", "-z", "-f", gitmodules, "--get-regexp", `^submodule\..*\.(path|url)$`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", out, err)
+	}
+
+	// Each entry reads "submodule.<name>.<key>\n<value>".
+	paths, urls := make(map[string]string), make(map[string]string)
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		kv := strings.SplitN(string(entry), "\n", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimPrefix(kv[0], "submodule.")
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			continue
+		}
+		switch name := key[:dot]; key[dot+1:] {
+		case "path":
+			paths[kv[1]] = name
+		case "url":
+			urls[name] = kv[1]
+		}
+	}
+
+	for i, sub := range subs {
+		name, has := paths[sub.path]
+		if !has || urls[name] == "" {
+			return nil, fmt.Errorf("no URL set in .gitmodules for submodule at %s", sub.path)
+		}
+		u, err := resolveSubmoduleURL(remote, urls[name])
+		if err != nil {
+			return nil, err
+		}
+		subs[i].url = u
+	}
+
+	return subs, nil
+}
+
+// scpLikeURL matches the scp-like syntax of git URLs, as in git@host:path. The
+// host must be longer than a letter, which would be a Windows drive, and be
+// followed by a path, not by the second colon of a transport like ext::.
+var scpLikeURL = regexp.MustCompile(`^(?:[A-Za-z0-9_.~-]+@)?[A-Za-z0-9_][A-Za-z0-9_.-]+:[^:]`)
+
+// resolveSubmoduleURL resolves the URL of a submodule, as set in .gitmodules,
+// against the URL of the repository it belongs to. Only URLs starting with
+// ./ or ../ are relative.
+//
+// The .gitmodules file comes from the dependency, so its absolute URLs must be
+// remote ones, over https, http, ssh or git, or in the scp-like syntax: local
+// paths and transports such as ext:: or file:// would let a dependency run
+// commands or read files, and URLs starting with - would be taken for options
+// by git.
+func resolveSubmoduleURL(base, rel string) (string, error) {
+	if strings.HasPrefix(rel, "-") {
+		return "", fmt.Errorf("invalid submodule URL %q", rel)
+	}
+	if !strings.HasPrefix(rel, "./") && !strings.HasPrefix(rel, "../") {
+		if u, err := url.Parse(rel); err == nil && strings.Contains(rel, "://") {
+			switch u.Scheme {
+			case "https", "http", "ssh", "git":
+				if u.Host != "" {
+					return rel, nil
+				}
+			}
+		} else if scpLikeURL.MatchString(rel) {
+			return rel, nil
+		}
+		return "", fmt.Errorf("invalid submodule URL %q: only https, http, ssh and git URLs are allowed", rel)
+	}
+
+	base = strings.TrimSuffix(base, "/")
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" && u.Host != "" {
+		u.Path = path.Join(u.Path, rel)
+		return u.String(), nil
+	}
+
+	// scp-like syntax (git@host:path) and local paths.
+	prefix, p := "", base
+	if i := strings.Index(base, ":"); i >= 0 && !filepath.IsAbs(base) {
+		prefix, p = base[:i+1], base[i+1:]
+	}
+	joined := path.Join(p, rel)
+	if joined == "." || strings.HasPrefix(joined, "../") {
+		return "", fmt.Errorf("cannot resolve submodule URL %s relative to %s", rel, base)
+	}
+	return prefix + joined, nil
+}
+
+// submoduleCacheName turns a submodule URL into a directory name.
+func submoduleCacheName(u string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.':
+			return r
+		}
+		return '-'
+	}, u)
+}
+
+// fetchGitSubmodule makes sure the bare repository in modDir holds the commit
+// recorded for the submodule, cloning or fetching it as needed.
+func fetchGitSubmodule(ctx context.Context, modDir string, sub gitSubmodule) error {
+	if _, err := os.Stat(modDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(modDir), 0777); err != nil {
+			return err
+		}
+		out, err := runFromCwd(ctx, expensiveCmdTimeout, "git", "clone", "--bare", "--", sub.url, modDir)
+		if err != nil {
+			if isGitAuthFailure(out) {
+				return gitAuthFailure{remote: sub.url, out: string(out)}
+			}
+			return fmt.Errorf("failed to clone submodule %s from %s: %s: %s", sub.path, sub.url, out, err)
+		}
+	}
+
+	hasRev := func() bool {
+		_, err := runFromCwd(ctx, defaultCmdTimeout, "git", "--git-dir="+modDir, "cat-file", "-e", "--", sub.rev.String()+"^{commit}")
+		return err == nil
+	}
+	if hasRev() {
+		return nil
+	}
+
+	out, err := runFromCwd(ctx, expensiveCmdTimeout, "git", "--git-dir="+modDir, "fetch", "--tags", "--", sub.url, "+refs/heads/*:refs/heads/*")
+	if err != nil {
+		if isGitAuthFailure(out) {
+			return gitAuthFailure{remote: sub.url, out: string(out)}
+		}
+		return fmt.Errorf("failed to fetch submodule %s from %s: %s: %s", sub.path, sub.url, out, err)
+	}
+	if !hasRev() {
+		return fmt.Errorf("submodule %s is recorded at %s, which does not exist in %s", sub.path, sub.rev, sub.url)
+	}
+	return nil
+}
+
+// exportGitTree writes out the tree of the given revision of the repository
+// in gitDir to the directory to, using a throwaway index.
+func exportGitTree(ctx context.Context, gitDir string, rev Revision, to string) error {
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	if err := checkGitTreeExportable(ctx, gitDir, rev, to); err != nil {
+		return err
+	}
+
+	idx := filepath.Join(gitDir, "dep-export-index")
+	defer os.Remove(idx)
+
+	to = strings.TrimSuffix(to, string(os.PathSeparator)) + string(os.PathSeparator)
+	for _, args := range [][]string{
+		{"read-tree", rev.String()},
+		{"-c", "core.longpaths=true", "checkout-index", "-a", "--prefix=" + to},
+	} {
+		args = append([]string{"--git-dir=" + gitDir, "--work-tree=" + to}, args...)
+		out, err := runGitWithIndex(ctx, defaultCmdTimeout, idx, args...)
+		if err != nil {
+			return fmt.Errorf("%s: %s", out, err)
+		}
+	}
+	return nil
+}
+
+// checkGitTreeExportable returns an error if the tree of the given revision
+// of the repository in gitDir can't be written to the directory to, as
+// checkExportablePaths tells. The tree is only listed when the platform or
+// filesystem of to are restrictive.
+func checkGitTreeExportable(ctx context.Context, gitDir string, rev Revision, to string) error {
+	windows := runtime.GOOS == "windows"
+	caseSensitive := fs.IsCaseSensitiveFilesystem(to)
+	if !windows && caseSensitive {
+		return nil
+	}
+
+	out, err := runFromCwd(ctx, defaultCmdTimeout, "git", "--git-dir="+gitDir, "ls-tree", "-r", "-z", "--name-only", "--", rev.String())
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
+	}
+	var paths []string
+	for _, p := range bytes.Split(out, []byte{0}) {
+		if len(p) > 0 {
+			paths = append(paths, string(p))
+		}
+	}
+	return checkExportablePaths(paths, windows, caseSensitive)
+}
+
+// runGitWithIndex runs git with idx as its index file.
+func runGitWithIndex(ctx context.Context, timeout time.Duration, idx string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	setCmdEnv(ctx, cmd)
+	cmd.Env = append(cmd.Env, "GIT_INDEX_FILE="+idx)
+	return newMonitoredCmd(cmd, timeout).combinedOutput(ctx)
+}
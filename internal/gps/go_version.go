@@ -0,0 +1,95 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GoVersion is a release of Go, such as 1.9 or 1.8.3.
+type GoVersion struct {
+	Major, Minor, Patch int
+}
+
+// ParseGoVersion parses a release of Go, with or without the go prefix
+// runtime.Version has, such as 1.9, go1.8.3 or go1.10beta1. Pre-releases count
+// as the release they precede.
+func ParseGoVersion(s string) (GoVersion, error) {
+	var v GoVersion
+	parts := strings.Split(strings.TrimPrefix(s, "go"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid Go version %q, it must look like 1.9 or 1.8.3", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		// Cut the pre-release, which only the last part may have.
+		if i == len(parts)-1 {
+			if j := strings.IndexAny(part, "abcdefghijklmnopqrstuvwxyz"); j > 0 {
+				part = part[:j]
+			}
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return GoVersion{}, fmt.Errorf("invalid Go version %q, it must look like 1.9 or 1.8.3", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// IsZero reports whether v is the zero GoVersion, which stands for no version.
+func (v GoVersion) IsZero() bool {
+	return v == GoVersion{}
+}
+
+// Less reports whether v is an earlier release than o.
+func (v GoVersion) Less(o GoVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+func (v GoVersion) String() string {
+	if v.Patch == 0 {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// GoVersionManifest is a Manifest which may require a release of Go. The
+// solver only considers the versions of a project whose manifest requires no
+// later release than SolveParameters.GoVersion.
+type GoVersionManifest interface {
+	Manifest
+
+	// RequiredGoVersion returns the earliest release of Go the project builds
+	// with, as ParseGoVersion parses it, or "" if any does.
+	RequiredGoVersion() string
+}
+
+// goVersionManifest adds a required release of Go to a Manifest.
+type goVersionManifest struct {
+	Manifest
+	goVersion string
+}
+
+func (m goVersionManifest) RequiredGoVersion() string {
+	return m.goVersion
+}
+
+// requiredGoVersion returns the release of Go m requires, or "" if it
+// requires none.
+func requiredGoVersion(m Manifest) string {
+	if gm, ok := m.(GoVersionManifest); ok {
+		return gm.RequiredGoVersion()
+	}
+	return ""
+}
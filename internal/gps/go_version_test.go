@@ -0,0 +1,122 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	cases := []struct {
+		in   string
+		want GoVersion
+		str  string
+	}{
+		{"1.9", GoVersion{1, 9, 0}, "1.9"},
+		{"go1.8.3", GoVersion{1, 8, 3}, "1.8.3"},
+		{"go1.10beta1", GoVersion{1, 10, 0}, "1.10"},
+		{"1.9rc2", GoVersion{1, 9, 0}, "1.9"},
+	}
+	for _, c := range cases {
+		got, err := ParseGoVersion(c.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.in, err)
+			continue
+		}
+		if got != c.want || got.String() != c.str {
+			t.Errorf("%s: expected %s, got %s (%+v)", c.in, c.str, got, got)
+		}
+	}
+
+	for _, in := range []string{"", "1", "devel +5fd2bd4 Thu Oct 5", "1.x", "1.9.2.1", "go1.-2"} {
+		if _, err := ParseGoVersion(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+
+	if !(GoVersion{1, 9, 2}).Less(GoVersion{1, 10, 0}) || (GoVersion{1, 10, 0}).Less(GoVersion{1, 9, 2}) {
+		t.Error("expected 1.9.2 to be earlier than 1.10")
+	}
+}
+
+// goVersionSM serves the manifests of the depspecs, requiring the release of
+// Go in req for the projects at the versions in it.
+type goVersionSM struct {
+	*depspecSourceManager
+	req map[string]string
+}
+
+func (sm *goVersionSM) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	m, l, err := sm.depspecSourceManager.GetManifestAndLock(id, v, an)
+	if gv, has := sm.req[string(id.ProjectRoot)+" "+v.String()]; has && err == nil {
+		m = goVersionManifest{Manifest: m, goVersion: gv}
+	}
+	return m, l, err
+}
+
+func TestSolveGoVersion(t *testing.T) {
+	fix := basicFixture{
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *"),
+			mkDepspec("a 1.0.0"),
+			mkDepspec("a 1.1.0"),
+			mkDepspec("a 2.0.0"),
+		},
+	}
+	sm := &goVersionSM{
+		depspecSourceManager: newdepspecSM(fix.ds, nil),
+		req:                  map[string]string{"a 2.0.0": "1.10", "a 1.1.0": "go1.9"},
+	}
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	solve := func(goVersion string) (Solution, []byte) {
+		params.GoVersion = goVersion
+		s, err := Prepare(params, sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		soln, err := s.Solve()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return soln, s.HashInputs()
+	}
+	version := func(soln Solution) string {
+		for _, lp := range soln.Projects() {
+			if lp.Ident().ProjectRoot == "a" {
+				return lp.Version().String()
+			}
+		}
+		return ""
+	}
+
+	soln, dig := solve("")
+	if v := version(soln); v != "2.0.0" || soln.GoVersion() != "" {
+		t.Errorf("expected a at 2.0.0 for any Go, got %s for Go %q", v, soln.GoVersion())
+	}
+	soln, dig9 := solve("1.9")
+	if v := version(soln); v != "1.1.0" || soln.GoVersion() != "1.9" {
+		t.Errorf("expected a at 1.1.0 for Go 1.9, got %s for Go %q", v, soln.GoVersion())
+	}
+	if reflect.DeepEqual(dig, dig9) {
+		t.Error("expected the release of Go to change the digest")
+	}
+	if soln, _ = solve("go1.8.7"); version(soln) != "1.0.0" {
+		t.Errorf("expected a at 1.0.0 for Go 1.8.7, got %s", version(soln))
+	}
+
+	params.GoVersion = "latest"
+	if _, err := Prepare(params, sm); err == nil {
+		t.Error("expected an invalid release of Go to be rejected")
+	}
+}
@@ -7,6 +7,7 @@ package gps
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"sort"
 	"strconv"
@@ -20,6 +21,10 @@ const (
 	hhIgnores     = "-IGNORES-"
 	hhOverrides   = "-OVERRIDES-"
 	hhAnalyzer    = "-ANALYZER-"
+	hhPrerelease  = "-PRERELEASES-"
+	hhUpstream    = "-UPSTREAM-VERSIONS-"
+	hhGoVersion   = "-GO-VERSION-"
+	hhPatches     = "-PATCHES-"
 )
 
 // HashInputs computes a hash digest of all data in SolveParams and the
@@ -109,6 +114,50 @@ func (s *solver) writeHashingInputs(w io.Writer) {
 	ai := s.rd.an.Info()
 	writeString(ai.Name)
 	writeString(strconv.Itoa(ai.Version))
+
+	// The pre-release policies only take part when they aren't the default,
+	// so that the digests of the other solves stay the same.
+	if !s.rd.prereleases.isDefault() {
+		writeString(hhPrerelease)
+		for _, in := range s.rd.prereleases.hashingInputs() {
+			writeString(in)
+		}
+	}
+
+	// Likewise for the projects whose versions are listed from upstream.
+	if len(s.rd.upstreamVersions) > 0 {
+		writeString(hhUpstream)
+		roots := make([]string, 0, len(s.rd.upstreamVersions))
+		for pr, upstream := range s.rd.upstreamVersions {
+			if upstream {
+				roots = append(roots, string(pr))
+			}
+		}
+		sort.Strings(roots)
+		for _, root := range roots {
+			writeString(root)
+		}
+	}
+
+	// Likewise for the release of Go the solution must build with.
+	if !s.rd.goVersion.IsZero() {
+		writeString(hhGoVersion)
+		writeString(s.rd.goVersion.String())
+	}
+
+	// Likewise for the patches applied to projects.
+	if len(s.rd.patchDigests) > 0 {
+		writeString(hhPatches)
+		roots := make([]string, 0, len(s.rd.patchDigests))
+		for pr := range s.rd.patchDigests {
+			roots = append(roots, string(pr))
+		}
+		sort.Strings(roots)
+		for _, root := range roots {
+			writeString(root)
+			writeString(hex.EncodeToString(s.rd.patchDigests[ProjectRoot(root)]))
+		}
+	}
 }
 
 // bytes.Buffer wrapper that injects newlines after each call to Write().
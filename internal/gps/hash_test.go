@@ -594,3 +594,62 @@ func diffHashingInputs(s Solver, wnt []string) string {
 	tw.Flush()
 	return buf.String()
 }
+
+func TestHashInputsUpstreamVersions(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig := s.HashInputs()
+
+	params.UpstreamVersions = map[ProjectRoot]bool{"a": true}
+	s, err = Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dig, s.HashInputs()) {
+		t.Error("expected listing the versions of a project from upstream to change the digest")
+	}
+}
+
+func TestHashInputsPatchDigests(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	var digests [][]byte
+	for _, pd := range []map[ProjectRoot][]byte{
+		nil,
+		{"a": []byte{1}},
+		{"a": []byte{2}},
+	} {
+		params.PatchDigests = pd
+		s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, s.HashInputs())
+	}
+	if bytes.Equal(digests[0], digests[1]) {
+		t.Error("expected patching a project to change the digest")
+	}
+	if bytes.Equal(digests[1], digests[2]) {
+		t.Error("expected changing a patch to change the digest")
+	}
+}
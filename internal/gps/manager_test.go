@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -90,20 +91,12 @@ func TestSourceManagerInit(t *testing.T) {
 		t.Errorf("Should have gotten CouldNotCreateLockError error type, but got %T", te)
 	}
 
-	if _, err = os.Stat(path.Join(cpath, "sm.lock")); err != nil {
-		t.Errorf("Global cache lock file not created correctly")
-	}
-
 	sm.Release()
 	err = removeAll(cpath)
 	if err != nil {
 		t.Errorf("removeAll failed: %s", err)
 	}
 
-	if _, err = os.Stat(path.Join(cpath, "sm.lock")); !os.IsNotExist(err) {
-		t.Fatalf("Global cache lock file not cleared correctly on Release()")
-	}
-
 	// Set another one up at the same spot now, just to be sure
 	sm, err = NewSourceManager(cpath)
 	if err != nil {
@@ -117,6 +110,43 @@ func TestSourceManagerInit(t *testing.T) {
 	}
 }
 
+func TestSourceManagerUseSourcesDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeAll(dir)
+	cpath, spath := filepath.Join(dir, "cache"), filepath.Join(dir, "sources")
+
+	sm, err := NewSourceManager(cpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.UseSourcesDir(spath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(spath, "sources")); err != nil {
+		t.Errorf("expected the sources directory to be set up, got %s", err)
+	}
+	if sm.srcCoord.sourcesdir != spath || sm.srcCoord.locker.dir != sourceLocksDir(spath) {
+		t.Errorf("expected sources and their locks to be kept in %s, got %s and %s", spath, sm.srcCoord.sourcesdir, sm.srcCoord.locker.dir)
+	}
+
+	// The sources directory is in use until the SourceMgr is released.
+	if _, err := NewSourceManager(spath); err == nil {
+		t.Errorf("expected a SourceMgr using the sources directory as cache to fail")
+	} else if _, ok := err.(CouldNotCreateLockError); !ok {
+		t.Errorf("expected a CouldNotCreateLockError, got %T", err)
+	}
+	sm.Release()
+
+	sm, err = NewSourceManager(spath)
+	if err != nil {
+		t.Fatalf("expected the sources directory to be usable once released, got %s", err)
+	}
+	sm.Release()
+}
+
 func TestSourceInit(t *testing.T) {
 	// This test is a bit slow, skip it on -short
 	if testing.Short() {
@@ -883,3 +913,40 @@ func TestSupervisor(t *testing.T) {
 		return nil
 	})
 }
+
+func TestSourceManagerRunning(t *testing.T) {
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	if calls := sm.Running(); len(calls) != 0 {
+		t.Fatalf("expected no running calls, got %v", calls)
+	}
+
+	list := callInfo{name: "https://github.com/foo/bar", typ: ctListVersions}
+	clone := callInfo{name: "https://github.com/foo/baz", typ: ctSourceInit}
+	for _, ci := range []callInfo{list, clone, list} {
+		if _, err := sm.suprvsr.start(ci); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := sm.Running()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 running calls, got %v", calls)
+	}
+	counts := make(map[string]int)
+	for _, c := range calls {
+		counts[c.Kind+" "+c.Name] = c.Count
+	}
+	want := map[string]int{"list " + list.name: 2, "clone " + clone.name: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("unexpected running calls %v", calls)
+	}
+
+	sm.suprvsr.done(list)
+	sm.suprvsr.done(list)
+	sm.suprvsr.done(clone)
+	if calls := sm.Running(); len(calls) != 0 {
+		t.Errorf("expected no running calls once done, got %v", calls)
+	}
+}
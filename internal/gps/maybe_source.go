@@ -99,15 +99,19 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string, c singleSource
 			repo: r,
 		},
 	}
+	if superv.offline {
+		return tryOffline(superv, src, r, ustr)
+	}
 
 	// Pinging invokes the same action as calling listVersions, so just do that.
 	var vl []PairedVersion
-	err = superv.do(ctx, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
+	err = superv.do(ctx, ustr, ctListVersions, func(ctx context.Context) (err error) {
 		if vl, err = src.listVersions(ctx); err != nil {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
+	superv.recordNetwork(ustr, 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -122,6 +126,15 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string, c singleSource
 	return src, state, nil
 }
 
+// tryOffline sets up src without contacting its upstream, which can only be
+// done if it has a local copy.
+func tryOffline(superv *supervisor, src source, r ctxRepo, ustr string) (source, sourceState, error) {
+	if !r.CheckLocal() {
+		return nil, 0, superv.denyNetwork(ustr)
+	}
+	return src, sourceIsSetUp | sourceExistsLocally, nil
+}
+
 func (m maybeGitSource) getURL() string {
 	return m.url.String()
 }
@@ -161,14 +174,18 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string, c singleSo
 		major:    m.major,
 		unstable: m.unstable,
 	}
+	if superv.offline {
+		return tryOffline(superv, src, r, ustr)
+	}
 
 	var vl []PairedVersion
-	err = superv.do(ctx, "git:lv:maybe", ctListVersions, func(ctx context.Context) (err error) {
+	err = superv.do(ctx, ustr, ctListVersions, func(ctx context.Context) (err error) {
 		if vl, err = src.listVersions(ctx); err != nil {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
+	superv.recordNetwork(ustr, 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -200,12 +217,17 @@ func (m maybeBzrSource) try(ctx context.Context, cachedir string, c singleSource
 		return nil, 0, unwrapVcsErr(err)
 	}
 
-	err = superv.do(ctx, "bzr:ping", ctSourcePing, func(ctx context.Context) error {
+	if superv.offline {
+		return tryOffline(superv, &bzrSource{baseVCSSource: baseVCSSource{repo: r}}, r, ustr)
+	}
+
+	err = superv.do(ctx, ustr, ctSourcePing, func(ctx context.Context) error {
 		if !r.Ping() {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
+	superv.recordNetwork(ustr, 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -241,12 +263,17 @@ func (m maybeHgSource) try(ctx context.Context, cachedir string, c singleSourceC
 		return nil, 0, unwrapVcsErr(err)
 	}
 
-	err = superv.do(ctx, "hg:ping", ctSourcePing, func(ctx context.Context) error {
+	if superv.offline {
+		return tryOffline(superv, &hgSource{baseVCSSource: baseVCSSource{repo: r}}, r, ustr)
+	}
+
+	err = superv.do(ctx, ustr, ctSourcePing, func(ctx context.Context) error {
 		if !r.Ping() {
 			return fmt.Errorf("remote repository at %s does not exist, or is inaccessible", ustr)
 		}
 		return nil
 	})
+	superv.recordNetwork(ustr, 0)
 	if err != nil {
 		return nil, 0, err
 	}
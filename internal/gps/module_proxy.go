@@ -0,0 +1,446 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// parseModuleProxies parses a comma-separated list of module proxy URLs, as
+// GOPROXY, which are tried in order. It may end with "direct", which stands
+// for the upstream sources, to fall back to them once the proxies failed.
+func parseModuleProxies(list string) (proxies []*url.URL, direct bool, err error) {
+	entries := strings.Split(list, ",")
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "direct" {
+			if i != len(entries)-1 {
+				return nil, false, errors.Errorf("invalid module proxy list %q: direct must come last", list)
+			}
+			direct = true
+			break
+		}
+
+		u, err := url.Parse(entry)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "invalid module proxy URL %q", entry)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, false, errors.Errorf("invalid module proxy URL %q: only http and https are supported", entry)
+		}
+		proxies = append(proxies, u)
+	}
+	if len(proxies) == 0 {
+		return nil, false, errors.Errorf("invalid module proxy list %q: no proxy", list)
+	}
+	return proxies, direct, nil
+}
+
+// escapeModulePath escapes a module path or version for use in the URLs of a
+// module proxy, which replace each upper case letter with an exclamation mark
+// followed by the letter's lower case, so that they are served right from case
+// insensitive file systems.
+func escapeModulePath(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// moduleProxyURL returns the URL identifying the given module of the proxy at
+// base.
+func moduleProxyURL(base *url.URL, module string) string {
+	return strings.TrimSuffix(base.String(), "/") + "/" + module
+}
+
+type maybeModuleProxySource struct {
+	base   *url.URL
+	module string
+}
+
+func (m maybeModuleProxySource) try(ctx context.Context, cachedir string, c singleSourceCache, superv *supervisor) (source, sourceState, error) {
+	src := &moduleProxySource{
+		base:     m.base,
+		module:   m.module,
+		treesdir: filepath.Join(cachedir, "trees"),
+		versions: make(map[Revision]string),
+	}
+	if superv.offline {
+		return nil, 0, superv.denyNetwork(m.base.String())
+	}
+
+	// Only the modules the proxy has a version list for are served by it. As
+	// trees are downloaded on demand, there is no local copy to update.
+	var vl []PairedVersion
+	err := superv.do(ctx, m.base.String(), ctListVersions, func(ctx context.Context) (err error) {
+		vl, err = src.listVersions(ctx)
+		return err
+	})
+	superv.recordNetwork(m.base.String(), 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c.storeVersionMap(vl, true)
+	return src, sourceIsSetUp | sourceExistsUpstream | sourceExistsLocally | sourceHasLatestLocally | sourceHasLatestVersionList, nil
+}
+
+func (m maybeModuleProxySource) getURL() string {
+	return moduleProxyURL(m.base, m.module)
+}
+
+// moduleProxyInfo is the metadata of a version of a module, as served by a
+// module proxy at MODULE/@v/VERSION.info.
+type moduleProxyInfo struct {
+	Version string
+	Time    time.Time
+	// Origin is only served by the proxies which record where the versions
+	// came from.
+	Origin *struct {
+		VCS  string
+		URL  string
+		Hash string
+	}
+}
+
+// revision returns the revision the version is identified by: the commit it
+// came from when the proxy tells, or the version itself otherwise, as module
+// versions are immutable.
+func (info moduleProxyInfo) revision() Revision {
+	if info.Origin != nil && info.Origin.Hash != "" {
+		return Revision(info.Origin.Hash)
+	}
+	return Revision(info.Version)
+}
+
+// moduleProxySource is a source served by a Go module proxy, as run for
+// GOPROXY, rather than by the VCS repository of the project. Proxies serve the
+// tagged versions of the modules, as zip files, without the history, so trees
+// are downloaded one by one into the trees directory of the cache, and the
+// refs and commit logs of the source are unavailable.
+type moduleProxySource struct {
+	base     *url.URL
+	module   string
+	treesdir string
+
+	mu sync.Mutex
+	// versions maps the revisions seen so far to the versions of the module
+	// they are served at.
+	versions map[Revision]string
+}
+
+func (s *moduleProxySource) get(ctx context.Context, elem string) (io.ReadCloser, error) {
+	u := *s.base
+	u.Path = path.Join(u.Path, escapeModulePath(s.module), "@v", elem)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	sent := credentialsFrom(ctx).authorize(req)
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "module proxy request failed")
+	}
+	if isHTTPAuthFailure(resp) {
+		resp.Body.Close()
+		return nil, httpAuthFailure{url: u.String(), status: resp.Status, sent: sent}
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("module proxy returned %s for %s: %s", resp.Status, u.String(), strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// info fetches the metadata of the given version, or of the version a
+// revision is served at, and records its revision.
+func (s *moduleProxySource) info(ctx context.Context, v string) (moduleProxyInfo, error) {
+	var info moduleProxyInfo
+	body, err := s.get(ctx, escapeModulePath(v)+".info")
+	if err != nil {
+		return info, err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(&info); err != nil {
+		return info, errors.Wrapf(err, "invalid info for %s@%s from module proxy", s.module, v)
+	}
+	if info.Version == "" {
+		return info, errors.Errorf("invalid info for %s@%s from module proxy: no version", s.module, v)
+	}
+
+	s.mu.Lock()
+	s.versions[info.revision()] = info.Version
+	s.mu.Unlock()
+	return info, nil
+}
+
+// resolve returns the version of the module r is served at.
+func (s *moduleProxySource) resolve(ctx context.Context, r Revision) (string, error) {
+	s.mu.Lock()
+	v, has := s.versions[r]
+	s.mu.Unlock()
+	if has {
+		return v, nil
+	}
+
+	// Proxies resolve the commits of the upstream repository to the
+	// pseudo-versions they are served at.
+	info, err := s.info(ctx, string(r))
+	if err != nil {
+		return "", errors.Wrapf(err, "revision %s of %s is not served by the module proxy", r, s.module)
+	}
+	return info.Version, nil
+}
+
+// tree returns the path to the tree of the module at r, downloading it first
+// if it isn't in the cache yet. Nothing is left behind on failure.
+func (s *moduleProxySource) tree(ctx context.Context, r Revision) (string, error) {
+	dir := filepath.Join(s.treesdir, sanitizer.Replace(s.upstreamURL()), string(r))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	v, err := s.resolve(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	body, err := s.get(ctx, escapeModulePath(v)+".zip")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return "", err
+	}
+	// Zip files are read from their end, so they have to be downloaded in
+	// full first.
+	f, err := ioutil.TempFile(filepath.Dir(dir), ".download")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %s@%s from module proxy", s.module, v)
+	}
+
+	td, err := ioutil.TempDir(filepath.Dir(dir), ".download")
+	if err != nil {
+		return "", err
+	}
+	if err := extractModuleZip(f, n, s.module+"@"+v+"/", td); err != nil {
+		os.RemoveAll(td)
+		return "", errors.Wrapf(err, "invalid zip for %s@%s from module proxy", s.module, v)
+	}
+	if err := os.Rename(td, dir); err != nil {
+		os.RemoveAll(td)
+		// Another process may have downloaded the same tree meanwhile.
+		if _, serr := os.Stat(dir); serr != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// extractModuleZip extracts the module zip file read from r, of the given
+// size, into dir. All the files of the zip must be under prefix, which is
+// stripped from their paths.
+func extractModuleZip(r io.ReaderAt, size int64, prefix, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if !strings.HasPrefix(zf.Name, prefix) {
+			return errors.Errorf("zip entry %s is outside of %s", zf.Name, prefix)
+		}
+		name := path.Clean(zf.Name[len(prefix):])
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return errors.Errorf("zip entry %s is outside of the tree", zf.Name)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(p, 0777); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		// Module zips carry no file modes.
+		err = extractFile(rc, p, 0666)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *moduleProxySource) existsLocally(ctx context.Context) bool {
+	return true
+}
+
+func (s *moduleProxySource) existsUpstream(ctx context.Context) bool {
+	body, err := s.get(ctx, "list")
+	if err != nil {
+		return false
+	}
+	body.Close()
+	return true
+}
+
+func (s *moduleProxySource) upstreamURL() string {
+	return moduleProxyURL(s.base, s.module)
+}
+
+func (s *moduleProxySource) sourceType() string {
+	return "proxy"
+}
+
+func (s *moduleProxySource) initLocal(ctx context.Context) error {
+	return nil
+}
+
+func (s *moduleProxySource) updateLocal(ctx context.Context) error {
+	return nil
+}
+
+// listVersions lists the tagged versions of the module, fetching the info of
+// each of them to pair it with its revision. The +incompatible suffix the
+// proxies give to the major versions of modules without a go.mod is dropped,
+// as it isn't part of the upstream tags.
+func (s *moduleProxySource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	body, err := s.get(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var vl []string
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		if v := strings.TrimSpace(sc.Text()); v != "" {
+			vl = append(vl, v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the versions of %s from module proxy", s.module)
+	}
+
+	pvl := make([]PairedVersion, 0, len(vl))
+	for _, v := range vl {
+		info, err := s.info(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		pvl = append(pvl, NewVersion(strings.TrimSuffix(info.Version, "+incompatible")).Pair(info.revision()))
+	}
+	return pvl, nil
+}
+
+func (s *moduleProxySource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.tree(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *moduleProxySource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.tree(ctx, r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *moduleProxySource) revisionPresentIn(r Revision) (bool, error) {
+	s.mu.Lock()
+	_, has := s.versions[r]
+	s.mu.Unlock()
+	return has, nil
+}
+
+func (s *moduleProxySource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.tree(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.CopyDir(dir, to)
+}
+
+func (s *moduleProxySource) versionInfo(ctx context.Context, uv UnpairedVersion, r Revision) (VersionInfo, error) {
+	v, err := s.resolve(ctx, r)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	info, err := s.info(ctx, v)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	return VersionInfo{Revision: r, Date: info.Time}, nil
+}
+
+func (s *moduleProxySource) commitLog(ctx context.Context, from, to Revision) ([]VersionInfo, error) {
+	return nil, errors.Errorf("the commit log of %s is not served by module proxies", s.module)
+}
+
+func (s *moduleProxySource) revisionAsOf(ctx context.Context, r Revision, t time.Time) (Revision, error) {
+	return "", errors.Errorf("the past revisions of branches of %s are not served by module proxies", s.module)
+}
+
+func (s *moduleProxySource) fetchRef(ctx context.Context, ref string) (Revision, error) {
+	return "", errors.Errorf("ref %s of %s is not served by module proxies", ref, s.module)
+}
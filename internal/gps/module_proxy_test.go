@@ -0,0 +1,160 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// moduleZip returns a module zip holding the given files under prefix.
+func moduleZip(t *testing.T, prefix string, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(prefix + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestModuleProxySource(t *testing.T) {
+	files := map[string]string{
+		"bar.go":     "package bar\n",
+		"baz/baz.go": "package baz\n",
+	}
+	// The module path is escaped in URLs, but not in zips.
+	served := map[string]string{
+		"/github.com/!foo/bar/@v/list":                     "v1.0.0\nv2.0.0+incompatible\n",
+		"/github.com/!foo/bar/@v/v1.0.0.info":              `{"Version":"v1.0.0","Time":"2017-06-01T00:00:00Z","Origin":{"VCS":"git","Hash":"abc123"}}`,
+		"/github.com/!foo/bar/@v/v1.0.0.zip":               moduleZip(t, "github.com/Foo/bar@v1.0.0/", files),
+		"/github.com/!foo/bar/@v/v2.0.0+incompatible.info": `{"Version":"v2.0.0+incompatible","Time":"2017-07-01T00:00:00Z"}`,
+		// Revisions out of the list are resolved to pseudo-versions.
+		"/github.com/!foo/bar/@v/def456.info":                      `{"Version":"v0.0.0-20170801000000-def456","Time":"2017-08-01T00:00:00Z"}`,
+		"/github.com/!foo/bar/@v/v0.0.0-20170801000000-def456.zip": moduleZip(t, "github.com/Foo/bar@v0.0.0-20170801000000-def456/", files),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		content, has := served[req.URL.Path]
+		if !has {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+	if err := sm.UseModuleProxy(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	pi := ProjectIdentifier{ProjectRoot: "github.com/Foo/bar"}
+	pvl, err := sm.ListVersions(pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[PairedVersion]bool{
+		NewVersion("v1.0.0").Pair("abc123"):              true,
+		NewVersion("v2.0.0").Pair("v2.0.0+incompatible"): true,
+	}
+	if len(pvl) != len(want) {
+		t.Fatalf("unexpected versions: %v", pvl)
+	}
+	for _, pv := range pvl {
+		if !want[pv] {
+			t.Errorf("unexpected version %#v", pv)
+		}
+	}
+
+	ptree, err := sm.ListPackages(pi, NewVersion("v1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"github.com/Foo/bar", "github.com/Foo/bar/baz"} {
+		if _, has := ptree.Packages[ip]; !has {
+			t.Errorf("expected package %s to be listed", ip)
+		}
+	}
+
+	td, err := ioutil.TempDir("", "moduleproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "tree")
+	if err := sm.ExportProject(pi, Revision("def456"), dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("expected %s to be exported: %s", name, err)
+		} else if string(got) != want {
+			t.Errorf("unexpected content for %s: %q", name, got)
+		}
+	}
+
+	// Without direct, the projects the proxy doesn't serve are unavailable.
+	if _, err := sm.ListVersions(ProjectIdentifier{ProjectRoot: "github.com/foo/qux"}); err == nil {
+		t.Error("expected an error listing the versions of a module the proxy doesn't serve")
+	}
+	if err := sm.ExportProject(pi, Revision("789abc"), filepath.Join(td, "missing")); err == nil {
+		t.Error("expected an error exporting a revision the proxy doesn't serve")
+	}
+}
+
+func TestParseModuleProxies(t *testing.T) {
+	proxies, direct, err := parseModuleProxies("https://proxy.example.com, http://localhost:3000/mod,direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxies) != 2 || proxies[0].String() != "https://proxy.example.com" || proxies[1].String() != "http://localhost:3000/mod" || !direct {
+		t.Errorf("unexpected proxies %v, direct %v", proxies, direct)
+	}
+
+	for _, list := range []string{"", "direct", "direct,https://proxy.example.com", "file:///var/proxy", "proxy.example.com"} {
+		if _, _, err := parseModuleProxies(list); err == nil {
+			t.Errorf("expected an error for %q", list)
+		}
+	}
+}
+
+func TestExtractModuleZipOutsideOfTree(t *testing.T) {
+	prefix := "github.com/foo/bar@v1.0.0/"
+	for _, files := range []map[string]string{
+		{"../evil.go": "package evil\n"},
+		{"../../bar@v1.0.1/evil.go": "package evil\n"},
+	} {
+		z := moduleZip(t, prefix, files)
+		td, err := ioutil.TempDir("", "moduleproxy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(td)
+
+		if err := extractModuleZip(bytes.NewReader([]byte(z)), int64(len(z)), prefix, filepath.Join(td, "tree")); err == nil {
+			t.Errorf("expected an error for %v", files)
+		}
+	}
+
+	z := moduleZip(t, "github.com/foo/baz@v1.0.0/", map[string]string{"baz.go": "package baz\n"})
+	if err := extractModuleZip(bytes.NewReader([]byte(z)), int64(len(z)), prefix, os.TempDir()); err == nil {
+		t.Error("expected an error for a zip of another module")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 
+	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
 
@@ -217,9 +218,10 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 		// Write the relative pathname to hash because the hash is a function of
 		// the node names, node types, and node contents. Added benefit is that
 		// empty directories, named pipes, sockets, devices, and symbolic links
-		// will also affect final hash value. Use `filepath.ToSlash` to ensure
-		// relative pathname is os-agnostic.
-		writeBytesWithNull(closure.someHash, []byte(filepath.ToSlash(osRelative)))
+		// will also affect final hash value. Use `filepath.ToSlash` and
+		// `fs.NormalizeName` to ensure relative pathname is os-agnostic, even
+		// when the filesystem decomposes non-ASCII names, as on macOS.
+		writeBytesWithNull(closure.someHash, []byte(fs.NormalizeName(filepath.ToSlash(osRelative))))
 
 		binary.LittleEndian.PutUint32(closure.someModeBytes, uint32(mt)) // encode the type of mode
 		writeBytesWithNull(closure.someHash, closure.someModeBytes)      // and write to hash
@@ -229,16 +231,16 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 		}
 
 		if mt == os.ModeSymlink { // okay to check for equivalence because we set to this value
-			osRelative, err = os.Readlink(osPathname) // read the symlink referent
+			osRelative, err = os.Readlink(fs.LongPath(osPathname)) // read the symlink referent
 			if err != nil {
 				return errors.Wrap(err, "cannot Readlink")
 			}
-			writeBytesWithNull(closure.someHash, []byte(filepath.ToSlash(osRelative))) // write referent to hash
-			return nil                                                                 // proceed to next node in queue
+			writeBytesWithNull(closure.someHash, []byte(fs.NormalizeName(filepath.ToSlash(osRelative)))) // write referent to hash
+			return nil                                                                                   // proceed to next node in queue
 		}
 
 		// If we get here, node is a regular file.
-		fh, err := os.Open(osPathname)
+		fh, err := os.Open(fs.LongPath(osPathname))
 		if err != nil {
 			return errors.Wrap(err, "cannot Open")
 		}
@@ -261,6 +263,23 @@ func DigestFromDirectory(osDirname string) ([]byte, error) {
 	return closure.someHash.Sum(nil), nil
 }
 
+// DigestFromFile returns a hash of the contents of the specified regular file.
+// As with DigestFromDirectory, CRLF sequences are read as LF, so that the hash
+// matches for any checkout of the file, on any supported Go platform.
+func DigestFromFile(osPathname string) ([]byte, error) {
+	fh, err := os.Open(fs.LongPath(osPathname))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot Open")
+	}
+	defer fh.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, newLineEndingReader(fh)); err != nil {
+		return nil, errors.Wrap(err, "cannot Copy")
+	}
+	return h.Sum(nil), nil
+}
+
 // VendorStatus represents one of a handful of possible status conditions for a
 // particular file sytem node in the vendor directory tree.
 type VendorStatus uint8
@@ -329,7 +348,7 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 	osDirname = filepath.Clean(osDirname)
 
 	// Ensure top level pathname is a directory
-	fi, err := os.Stat(osDirname)
+	fi, err := os.Stat(fs.LongPath(osDirname))
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot Stat")
 	}
@@ -386,9 +405,13 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 	// Mark directories of expected projects as required. When each respective
 	// project is later found while traversing the vendor root hierarchy, its
 	// status will be updated to reflect whether its digest is empty, or,
-	// whether or not it matches the expected digest.
+	// whether or not it matches the expected digest. Projects are found by
+	// their normalized pathnames, as those read from the file system may be
+	// spelled differently than in the lock.
+	wantNames := make(map[string]string, len(wantSums))
 	for slashPathname := range wantSums {
 		slashStatus[slashPathname] = NotInTree
+		wantNames[fs.NormalizeName(slashPathname)] = slashPathname
 	}
 
 	for len(queue) > 0 {
@@ -397,10 +420,10 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 		// in the slice's backing array as we proceed.
 		lq1 := len(queue) - 1
 		currentNode, queue[lq1], queue = queue[lq1], nil, queue[:lq1]
-		slashPathname := filepath.ToSlash(currentNode.osRelative)
 		osPathname := filepath.Join(osDirname, currentNode.osRelative)
 
-		if expectedSum, ok := wantSums[slashPathname]; ok {
+		if slashPathname, ok := wantNames[fs.NormalizeName(filepath.ToSlash(currentNode.osRelative))]; ok {
+			expectedSum := wantSums[slashPathname]
 			ls := EmptyDigestInLock
 			if len(expectedSum) > 0 {
 				projectSum, err := DigestFromDirectory(osPathname)
@@ -441,7 +464,7 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 				// index set to the index of the current node.
 				otherNode := &fsnode{osRelative: osChildRelative, myIndex: len(nodes), parentIndex: currentNode.myIndex}
 
-				fi, err := os.Stat(osChildPathname)
+				fi, err := os.Stat(fs.LongPath(osChildPathname))
 				if err != nil {
 					return nil, errors.Wrap(err, "cannot Stat")
 				}
@@ -463,7 +486,7 @@ func VerifyDepTree(osDirname string, wantSums map[string][]byte) (map[string]Ven
 		currentNode, nodes[ln1], nodes = nodes[ln1], nil, nodes[:ln1]
 
 		if !currentNode.isRequiredAncestor && nodes[currentNode.parentIndex].isRequiredAncestor {
-			slashStatus[filepath.ToSlash(currentNode.osRelative)] = NotInLock
+			slashStatus[fs.NormalizeName(filepath.ToSlash(currentNode.osRelative))] = NotInLock
 		}
 	}
 	currentNode, nodes = nil, nil
@@ -6,10 +6,16 @@ package pkgtree
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/golang/dep/internal/fs"
 )
 
 // crossBuffer is a test io.Reader that emits a few canned responses.
@@ -139,6 +145,130 @@ func TestDigestFromDirectory(t *testing.T) {
 	})
 }
 
+func TestDigestFromDirectoryLongPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The same tree, once beneath a short path, and once beneath a path long
+	// enough for its files to exceed the MAX_PATH limit of Windows.
+	deep := strings.Repeat(filepath.Join("deeply", "nested", "directory")+string(filepath.Separator), 15)
+	roots := []string{filepath.Join(dir, "short"), filepath.Join(dir, deep, "long")}
+	for _, root := range roots {
+		for _, file := range []string{"foo.go", filepath.Join("sub", "bar.go")} {
+			fn := filepath.Join(root, file)
+			if err := os.MkdirAll(fs.LongPath(filepath.Dir(fn)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(fs.LongPath(fn), []byte("package foo\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want, err := DigestFromDirectory(roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DigestFromDirectory(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("\n(GOT):\n\t%#v\n(WNT):\n\t%#v", got, want)
+	}
+}
+
+func TestDigestFromDirectoryNormalization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The same tree, with composed names as on most platforms, and decomposed
+	// ones as on macOS, which also sort differently around f.go.
+	trees := map[string][]string{
+		"composed":   {"caf\u00e9.go", "f.go", filepath.Join("na\u00efve", "x.go")},
+		"decomposed": {"cafe\u0301.go", "f.go", filepath.Join("nai\u0308ve", "x.go")},
+	}
+	for root, files := range trees {
+		for _, file := range files {
+			fn := filepath.Join(dir, root, file)
+			if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(fn, []byte("package foo\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want, err := DigestFromDirectory(filepath.Join(dir, "composed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DigestFromDirectory(filepath.Join(dir, "decomposed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("\n(GOT):\n\t%#v\n(WNT):\n\t%#v", got, want)
+	}
+
+	// A lock verified on one platform verifies on the others, with the names
+	// of the lock.
+	sum, err := DigestFromDirectory(filepath.Join(dir, "composed", "na\u00efve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := VerifyDepTree(filepath.Join(dir, "decomposed"), map[string][]byte{"na\u00efve": sum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStatus := map[string]VendorStatus{
+		"na\u00efve":   NoMismatch,
+		"caf\u00e9.go": NotInLock,
+		"f.go":         NotInLock,
+	}
+	if !reflect.DeepEqual(status, wantStatus) {
+		t.Errorf("\n(GOT):\n\t%v\n(WNT):\n\t%v", status, wantStatus)
+	}
+}
+
+func TestDigestFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest-from-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lf, crlf := filepath.Join(dir, "lf"), filepath.Join(dir, "crlf")
+	if err = ioutil.WriteFile(lf, []byte("one\ntwo\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(crlf, []byte("one\r\ntwo\r\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	want := sha256.Sum256([]byte("one\ntwo\n"))
+	for _, name := range []string{lf, crlf} {
+		got, err := DigestFromFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("%s:\n(GOT):\n\t%#v\n(WNT):\n\t%#v", filepath.Base(name), got, want[:])
+		}
+	}
+
+	if _, err = DigestFromFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
 func TestVerifyDepTree(t *testing.T) {
 	vendorRoot := getTestdataVerifyRoot(t)
 
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
 
@@ -33,14 +34,15 @@ type DirWalkFunc func(osPathname string, info os.FileInfo, err error) error
 
 // DirWalk walks the file tree rooted at osDirname, calling for each file system
 // node in the tree, including root. All errors that arise visiting nodes are
-// filtered by walkFn. The nodes are walked in lexical order, which makes the
-// output deterministic but means that for very large directories DirWalk can be
+// filtered by walkFn. The nodes are walked in the lexical order of their names,
+// once normalized as by fs.NormalizeName, which makes the output deterministic
+// on every platform but means that for very large directories DirWalk can be
 // inefficient. Unlike filepath.Walk, DirWalk does follow symbolic links.
 func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 	osDirname = filepath.Clean(osDirname)
 
 	// Ensure parameter is a directory
-	fi, err := os.Stat(osDirname)
+	fi, err := os.Stat(fs.LongPath(osDirname))
 	if err != nil {
 		return errors.Wrap(err, "cannot read node")
 	}
@@ -64,7 +66,7 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 
 		// walkFn needs to choose how to handle symbolic links, therefore obtain
 		// lstat rather than stat.
-		fi, err = os.Lstat(osPathname)
+		fi, err = os.Lstat(fs.LongPath(osPathname))
 		if err == nil {
 			err = walkFn(osPathname, fi, nil)
 		} else {
@@ -76,7 +78,7 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 				if fi.Mode()&os.ModeSymlink > 0 {
 					// Resolve symbolic link referent to determine whether node
 					// is directory or not.
-					fi, err = os.Stat(osPathname)
+					fi, err = os.Stat(fs.LongPath(osPathname))
 					if err != nil {
 						return errors.Wrap(err, "cannot visit node")
 					}
@@ -116,10 +118,10 @@ func DirWalk(osDirname string, walkFn DirWalkFunc) error {
 	return nil
 }
 
-// sortedChildrenFromDirname returns a lexicographically sorted list of child
-// nodes for the specified directory.
+// sortedChildrenFromDirname returns a list of child nodes for the specified
+// directory, lexicographically sorted by their normalized names.
 func sortedChildrenFromDirname(osDirname string) ([]string, error) {
-	fh, err := os.Open(osDirname)
+	fh, err := os.Open(fs.LongPath(osDirname))
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot Open")
 	}
@@ -128,7 +130,7 @@ func sortedChildrenFromDirname(osDirname string) ([]string, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot Readdirnames")
 	}
-	sort.Strings(osChildrenNames)
+	sort.Sort(byNormalizedName(osChildrenNames))
 
 	// Close the file handle to the open directory without masking possible
 	// previous error value.
@@ -137,3 +139,13 @@ func sortedChildrenFromDirname(osDirname string) ([]string, error) {
 	}
 	return osChildrenNames, err
 }
+
+// byNormalizedName sorts names as normalized by fs.NormalizeName, as the same
+// name may be spelled differently by the filesystems of different platforms.
+type byNormalizedName []string
+
+func (s byNormalizedName) Len() int      { return len(s) }
+func (s byNormalizedName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byNormalizedName) Less(i, j int) bool {
+	return fs.NormalizeName(s[i]) < fs.NormalizeName(s[j])
+}
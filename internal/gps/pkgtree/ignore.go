@@ -0,0 +1,133 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IgnoreRules tells which import paths are ignored, given the entries of an
+// ignore list. An entry is either an import path, ignored as is, or a pattern
+// matched against whole import paths, element by element: * and ? match any
+// sequence of characters and any single character within an element, as with
+// path.Match, and an element of ** matches any number of elements, including
+// none.
+//
+// A pattern prefixed with ! negates it: the paths it matches are not ignored,
+// whatever the other entries say. So, with
+//
+//	github.com/foo/bar/**
+//	!github.com/foo/bar/keep
+//
+// github.com/foo/bar and all its subpackages are ignored, but for
+// github.com/foo/bar/keep.
+//
+// The zero value, as well as a nil *IgnoreRules, ignores nothing.
+type IgnoreRules struct {
+	paths    map[string]bool
+	patterns []string
+	negated  []string
+}
+
+// NewIgnoreRules returns the rules of the given ignore list, which is keyed by
+// entry as returned by RootManifest.IgnoredPackages. Invalid patterns match
+// nothing; see ValidateIgnorePattern.
+func NewIgnoreRules(ignore map[string]bool) *IgnoreRules {
+	r := &IgnoreRules{paths: make(map[string]bool)}
+	for entry, ig := range ignore {
+		if !ig {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry, "!"):
+			r.negated = append(r.negated, entry[1:])
+		case IsIgnorePattern(entry):
+			r.patterns = append(r.patterns, entry)
+		default:
+			r.paths[entry] = true
+		}
+	}
+	return r
+}
+
+// IsIgnored reports whether the package at the given import path is ignored.
+func (r *IgnoreRules) IsIgnored(ip string) bool {
+	if r == nil {
+		return false
+	}
+
+	ignored := r.paths[ip]
+	for i := 0; !ignored && i < len(r.patterns); i++ {
+		ignored = matchImportPath(r.patterns[i], ip)
+	}
+	if !ignored {
+		return false
+	}
+	for _, pattern := range r.negated {
+		if matchImportPath(pattern, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsIgnorePattern reports whether the ignore list entry is a pattern, negated
+// or not, rather than an import path.
+func IsIgnorePattern(entry string) bool {
+	return strings.HasPrefix(entry, "!") || strings.ContainsAny(entry, "*?[")
+}
+
+// ValidateIgnorePattern returns an error if the ignore list entry is a
+// malformed pattern.
+func ValidateIgnorePattern(entry string) error {
+	pattern := strings.TrimPrefix(entry, "!")
+	if pattern == "" {
+		return errors.Errorf("invalid ignore pattern %q: empty pattern", entry)
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == "**" {
+			continue
+		}
+		if strings.Contains(elem, "**") {
+			return errors.Errorf("invalid ignore pattern %q: ** must be a whole path element", entry)
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return errors.Wrapf(err, "invalid ignore pattern %q", entry)
+		}
+	}
+	return nil
+}
+
+// matchImportPath reports whether the import path ip matches pattern, as
+// described on IgnoreRules.
+func matchImportPath(pattern, ip string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(ip, "/"))
+}
+
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest of the pattern at every remaining
+			// element, including past the last one.
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], elems[0]); err != nil || !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgtree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := NewIgnoreRules(map[string]bool{
+		"github.com/a/exact":     true,
+		"github.com/b/bar/**":    true,
+		"!github.com/b/bar/keep": true,
+		"github.com/c/*/gen":     true,
+		"github.com/d/**/mock?":  true,
+		"github.com/e/off":       false,
+	})
+
+	cases := map[string]bool{
+		"github.com/a/exact":         true,
+		"github.com/a/exact/sub":     false,
+		"github.com/b/bar":           true,
+		"github.com/b/bar/x":         true,
+		"github.com/b/bar/x/y":       true,
+		"github.com/b/bar/keep":      false,
+		"github.com/b/bar/keep/sub":  true,
+		"github.com/b/barn":          false,
+		"github.com/c/x/gen":         true,
+		"github.com/c/x/y/gen":       false,
+		"github.com/d/mocks":         true,
+		"github.com/d/x/y/mock1":     true,
+		"github.com/d/x/mock":        false,
+		"github.com/e/off":           false,
+		"github.com/unrelated/thing": false,
+	}
+	for ip, want := range cases {
+		if got := rules.IsIgnored(ip); got != want {
+			t.Errorf("IsIgnored(%q) = %v, want %v", ip, got, want)
+		}
+	}
+
+	var none *IgnoreRules
+	if none.IsIgnored("github.com/a/exact") {
+		t.Error("nil rules should ignore nothing")
+	}
+}
+
+func TestValidateIgnorePattern(t *testing.T) {
+	for _, valid := range []string{"github.com/a/**", "!github.com/a/keep", "github.com/a/[ab]*"} {
+		if err := ValidateIgnorePattern(valid); err != nil {
+			t.Errorf("unexpected error for %q: %s", valid, err)
+		}
+	}
+	for _, invalid := range []string{"!", "github.com/a/x**", "github.com/a/[ab"} {
+		if err := ValidateIgnorePattern(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestToReachMapIgnorePatterns(t *testing.T) {
+	pkg := func(ip string, imports ...string) PackageOrErr {
+		return PackageOrErr{P: Package{ImportPath: ip, Name: "p", Imports: imports}}
+	}
+	ptree := PackageTree{
+		ImportRoot: "root",
+		Packages: map[string]PackageOrErr{
+			"root":          pkg("root", "root/gen/a", "root/gen/b", "github.com/x/x"),
+			"root/gen/a":    pkg("root/gen/a", "github.com/gen/a"),
+			"root/gen/b":    pkg("root/gen/b", "github.com/gen/b"),
+			"root/internal": pkg("root/internal"),
+		},
+	}
+
+	rm, _ := ptree.ToReachMap(true, false, false, map[string]bool{
+		"root/gen/*":     true,
+		"!root/gen/b":    true,
+		"github.com/x/*": true,
+	})
+	want := []string{"github.com/gen/b"}
+	if got := rm["root"].External; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected external imports of root: %v, want %v", got, want)
+	}
+	if _, has := rm["root/gen/a"]; has {
+		t.Error("expected root/gen/a to be ignored")
+	}
+}
@@ -59,6 +59,10 @@ var vcsRoots = map[string]struct{}{
 // A PackageTree is returned, which contains the ImportRoot and map of import path
 // to PackageOrErr - each path under the root that exists will have either a
 // Package, or an error describing why the directory is not a valid package.
+//
+// Build constraints, whether given by +build lines or by GOOS/GOARCH file name
+// suffixes, are not evaluated: the imports of every file are recorded, so that
+// the imports needed on any platform are reported regardless of the host.
 func ListPackages(fileRoot, importRoot string) (PackageTree, error) {
 	ptree := PackageTree{
 		ImportRoot: importRoot,
@@ -384,8 +388,8 @@ type PackageTree struct {
 // to a nonexistent internal package) should be backpropagated, transitively
 // "poisoning" all corresponding importers to all importers.
 //
-// ignore is a map of import paths that, if encountered, should be excluded from
-// analysis. This exclusion applies to both internal and external packages. If
+// ignore is a map of import paths, or patterns as described on IgnoreRules,
+// that, if encountered, should be excluded from analysis. This exclusion applies to both internal and external packages. If
 // an external import path is ignored, it is simply omitted from the results.
 //
 // If an internal path is ignored, then it not only does not appear in the final
@@ -441,9 +445,7 @@ type PackageTree struct {
 // 	"A/bar": []string{"B/baz"},
 //  }
 func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bool) (ReachMap, map[string]*ProblemImportError) {
-	if ignore == nil {
-		ignore = make(map[string]bool)
-	}
+	ig := NewIgnoreRules(ignore)
 
 	// world's simplest adjacency list
 	workmap := make(map[string]wm)
@@ -463,7 +465,7 @@ func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bo
 			continue
 		}
 		// Skip ignored packages
-		if ignore[ip] {
+		if ig.IsIgnored(ip) {
 			continue
 		}
 
@@ -484,7 +486,7 @@ func (t PackageTree) ToReachMap(main, tests, backprop bool, ignore map[string]bo
 		// For each import, decide whether it should be ignored, or if it
 		// belongs in the external or internal imports list.
 		for _, imp := range imps {
-			if ignore[imp] || imp == "." {
+			if imp == "." || ig.IsIgnored(imp) {
 				continue
 			}
 
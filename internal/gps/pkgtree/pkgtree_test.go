@@ -1251,6 +1251,27 @@ func TestListPackages(t *testing.T) {
 				},
 			},
 		},
+		"imports behind build constraints are included for all platforms": {
+			fileRoot:   j("platform"),
+			importRoot: "platform",
+			out: PackageTree{
+				ImportRoot: "platform",
+				Packages: map[string]PackageOrErr{
+					"platform": {
+						P: Package{
+							ImportPath:  "platform",
+							CommentPath: "",
+							Name:        "platform",
+							Imports: []string{
+								"github.com/golang/dep/internal/gps",
+								"github.com/golang/dep/internal/gps/paths",
+								"sort",
+							},
+						},
+					},
+				},
+			},
+		},
 		"does not skip directories starting with '.'": {
 			fileRoot:   j("dotgodir"),
 			importRoot: "dotgodir",
@@ -0,0 +1,135 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrereleasePolicy tells whether the semver versions with a pre-release, such
+// as v1.2.0-beta.1, are candidates when solving.
+type PrereleasePolicy int
+
+const (
+	// PrereleaseConstraint leaves pre-releases to the semver constraints,
+	// which only allow those of the version their lower bound names, as
+	// ^1.2.0-beta allows v1.2.0-rc.1 but not v1.3.0-beta. It is the default.
+	PrereleaseConstraint PrereleasePolicy = iota
+
+	// PrereleaseAllow makes the pre-releases of the versions a semver range
+	// allows candidates as well, as ^1.2.0 then allows v1.3.0-beta, as long
+	// as they aren't below the range.
+	PrereleaseAllow
+
+	// PrereleaseNever keeps pre-releases from being candidates at all, even
+	// the locked one or those a constraint names.
+	PrereleaseNever
+)
+
+func (p PrereleasePolicy) String() string {
+	switch p {
+	case PrereleaseConstraint:
+		return "constraint"
+	case PrereleaseAllow:
+		return "allow"
+	case PrereleaseNever:
+		return "never"
+	}
+	return "unknown"
+}
+
+// ParsePrereleasePolicy returns the policy of the given name: constraint,
+// allow or never. The empty name stands for the default one.
+func ParsePrereleasePolicy(name string) (PrereleasePolicy, error) {
+	switch name {
+	case "", "constraint":
+		return PrereleaseConstraint, nil
+	case "allow":
+		return PrereleaseAllow, nil
+	case "never":
+		return PrereleaseNever, nil
+	}
+	return PrereleaseConstraint, fmt.Errorf("invalid pre-release policy %q, it must be constraint, allow or never", name)
+}
+
+// PrereleasePolicies holds the pre-release policy of a solve, and those of the
+// projects which differ.
+type PrereleasePolicies struct {
+	Default  PrereleasePolicy
+	Projects map[ProjectRoot]PrereleasePolicy
+}
+
+// For returns the pre-release policy applying to the project at pr.
+func (pp PrereleasePolicies) For(pr ProjectRoot) PrereleasePolicy {
+	if p, has := pp.Projects[pr]; has {
+		return p
+	}
+	return pp.Default
+}
+
+// isDefault reports whether the policies leave pre-releases to the
+// constraints for all projects.
+func (pp PrereleasePolicies) isDefault() bool {
+	if pp.Default != PrereleaseConstraint {
+		return false
+	}
+	for _, p := range pp.Projects {
+		if p != PrereleaseConstraint {
+			return false
+		}
+	}
+	return true
+}
+
+// hashingInputs returns the policies as strings to hash, sorted by project.
+func (pp PrereleasePolicies) hashingInputs() []string {
+	in := []string{"default=" + pp.Default.String()}
+	projects := make([]string, 0, len(pp.Projects))
+	for pr, p := range pp.Projects {
+		projects = append(projects, string(pr)+"="+p.String())
+	}
+	sort.Strings(projects)
+	return append(in, projects...)
+}
+
+// withoutPrereleases filters the pre-releases out of vl.
+func withoutPrereleases(vl []PairedVersion) []PairedVersion {
+	kept := make([]PairedVersion, 0, len(vl))
+	for _, v := range vl {
+		if sv, ok := v.Unpair().(semVersion); ok && sv.sv.Prerelease() != "" {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// prereleaseMatches reports whether v is a pre-release that the semver range c
+// allows under PrereleaseAllow: the release it precedes is in the range, and
+// the range reaches down to it.
+func prereleaseMatches(c Constraint, v Version) bool {
+	sc, ok := c.(semverConstraint)
+	if !ok {
+		return false
+	}
+	if pv, ok := v.(versionPair); ok {
+		v = pv.v
+	}
+	sv, ok := v.(semVersion)
+	if !ok || sv.sv.Prerelease() == "" {
+		return false
+	}
+
+	release, err := NewSemverConstraint(fmt.Sprintf("%d.%d.%d", sv.sv.Major(), sv.sv.Minor(), sv.sv.Patch()))
+	if err != nil {
+		return false
+	}
+	below, err := NewSemverConstraint("<=" + sv.sv.String())
+	if err != nil {
+		return false
+	}
+	return sc.MatchesAny(release) && sc.MatchesAny(below)
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePrereleasePolicy(t *testing.T) {
+	for name, want := range map[string]PrereleasePolicy{
+		"":           PrereleaseConstraint,
+		"constraint": PrereleaseConstraint,
+		"allow":      PrereleaseAllow,
+		"never":      PrereleaseNever,
+	} {
+		got, err := ParsePrereleasePolicy(name)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", name, err)
+		} else if got != want {
+			t.Errorf("%q: expected %s, got %s", name, want, got)
+		}
+	}
+	if _, err := ParsePrereleasePolicy("always"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestVersionUnifierPrereleaseAllow(t *testing.T) {
+	vu := versionUnifier{
+		b:   lvFixBridge{},
+		mtr: newMetrics(),
+		prereleases: PrereleasePolicies{
+			Projects: map[ProjectRoot]PrereleasePolicy{"allowed": PrereleaseAllow},
+		},
+	}
+	allowed, other := mkPI("allowed"), mkPI("other")
+
+	caret, _ := NewSemverConstraintIC("^1.2.0")
+	tilde, _ := NewSemverConstraintIC("~1.2.0")
+	pre, _ := NewSemverConstraintIC("^1.2.0-beta")
+	cases := []struct {
+		c              Constraint
+		v              Version
+		allowed, other bool
+	}{
+		{caret, NewVersion("v1.3.0-beta.1"), true, false},
+		{caret, NewVersion("v1.3.0-beta.1").Pair("rev"), true, false},
+		{caret, NewVersion("v1.2.1-rc.1+build.5"), true, false},
+		// Pre-releases below the range, or of the release at its end, aren't
+		// allowed.
+		{caret, NewVersion("v1.2.0-beta"), false, false},
+		{caret, NewVersion("v2.0.0-beta"), false, false},
+		{tilde, NewVersion("v1.3.0-beta"), false, false},
+		// Nor are they by exact versions.
+		{NewVersion("v1.3.0"), NewVersion("v1.3.0-beta"), false, false},
+		// The constraints alone allow the pre-releases of their lower bound.
+		{pre, NewVersion("v1.2.0-rc.1"), true, true},
+		{caret, NewVersion("v1.3.0"), true, true},
+	}
+	for _, tc := range cases {
+		if got := vu.matches(allowed, tc.c, tc.v); got != tc.allowed {
+			t.Errorf("%s matching %s under allow: expected %v, got %v", tc.c, tc.v, tc.allowed, got)
+		}
+		if got := vu.matches(other, tc.c, tc.v); got != tc.other {
+			t.Errorf("%s matching %s by default: expected %v, got %v", tc.c, tc.v, tc.other, got)
+		}
+	}
+}
+
+func TestBridgeListVersionsPrereleaseNever(t *testing.T) {
+	sm := &releaseDateSM{
+		depspecSourceManager: newdepspecSM(nil, nil),
+		vl: []PairedVersion{
+			NewVersion("v1.0.0").Pair("r1"),
+			NewVersion("v1.1.0-beta.1").Pair("r2"),
+			NewVersion("v1.1.0").Pair("r3"),
+			NewVersion("v2.0.0-rc.1+build.2").Pair("r4"),
+			NewBranch("master").Pair("r5"),
+		},
+	}
+	s := &solver{
+		rd: rootdata{
+			prereleases: PrereleasePolicies{
+				Default:  PrereleaseNever,
+				Projects: map[ProjectRoot]PrereleasePolicy{"bar": PrereleaseConstraint},
+			},
+		},
+		mtr: newMetrics(),
+	}
+
+	for id, want := range map[ProjectIdentifier][]string{
+		mkPI("foo"): {"v1.1.0", "v1.0.0", "master"},
+		mkPI("bar"): {"v1.1.0", "v1.0.0", "v2.0.0-rc.1+build.2", "v1.1.0-beta.1", "master"},
+	} {
+		vl, err := mkBridge(s, sm, false).listVersions(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(vl))
+		for i, v := range vl {
+			got[i] = v.String()
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected versions %s for %s, got %s", want, id, got)
+		}
+	}
+}
+
+func TestHashInputsPrereleasePolicies(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dig := s.HashInputs()
+
+	params.Prereleases = PrereleasePolicies{
+		Projects: map[ProjectRoot]PrereleasePolicy{"a": PrereleaseConstraint},
+	}
+	s, err = Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dig, s.HashInputs()) {
+		t.Error("expected the default policies to leave the digest alone")
+	}
+
+	params.Prereleases.Projects["a"] = PrereleaseAllow
+	s, err = Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(dig, s.HashInputs()) {
+		t.Error("expected a pre-release policy to change the digest")
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProtocolPolicy restricts the protocols over which the sources whose URL lies
+// under a prefix are fetched.
+type ProtocolPolicy struct {
+	// Prefix is the host of the sources, followed by the path they lie under
+	// if they don't all do, such as github.com or git.example.com/team. Of
+	// the policies whose prefix matches the host and path of the URL of a
+	// source, that of the longest applies; * matches all of them.
+	Prefix string
+	// Protocols holds the URL schemes the sources may be fetched over, such
+	// as https or ssh, from the preferred one.
+	Protocols []string
+}
+
+// protocolSchemes are the URL schemes of the protocols sources are fetched
+// over.
+var protocolSchemes = []string{"https", "http", "ssh", "git", "bzr", "bzr+ssh", "svn", "svn+ssh"}
+
+// ParseProtocolPolicies parses a comma-separated list of protocol policies,
+// each written as the prefix, an equal sign and the protocols separated by |,
+// as in github.com=https,git.example.com=ssh|https.
+func ParseProtocolPolicies(s string) ([]ProtocolPolicy, error) {
+	var pp []ProtocolPolicy
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		eq := strings.Index(entry, "=")
+		if eq < 0 {
+			return nil, errors.Errorf("invalid protocol policy %q, expected <prefix>=<protocol>[|<protocol>...]", entry)
+		}
+		p := ProtocolPolicy{Prefix: strings.TrimSpace(entry[:eq])}
+		for _, proto := range strings.Split(entry[eq+1:], "|") {
+			p.Protocols = append(p.Protocols, strings.TrimSpace(proto))
+		}
+		pp = append(pp, p)
+	}
+	return pp, nil
+}
+
+// protocolPolicies holds the protocol policies of a SourceMgr, which restrict
+// the URLs deduced for the sources, and are passed down to the git commands in
+// their context.
+type protocolPolicies struct {
+	// policies are sorted from the longest prefix to the shortest, with the
+	// prefixes lowercased and without a trailing slash.
+	policies []ProtocolPolicy
+}
+
+func newProtocolPolicies(pp []ProtocolPolicy) (*protocolPolicies, error) {
+	p := &protocolPolicies{policies: make([]ProtocolPolicy, 0, len(pp))}
+	seen := make(map[string]bool, len(pp))
+	for _, pol := range pp {
+		prefix := strings.TrimSuffix(strings.ToLower(pol.Prefix), "/")
+		if prefix == "" || strings.Contains(prefix, "://") || strings.ContainsAny(prefix, " \t\n") {
+			return nil, errors.Errorf("invalid protocol policy prefix %q, expected a host, optionally followed by a path", pol.Prefix)
+		}
+		if seen[prefix] {
+			return nil, errors.Errorf("multiple protocol policies for %s", prefix)
+		}
+		seen[prefix] = true
+		if len(pol.Protocols) == 0 {
+			return nil, errors.Errorf("no protocols allowed by the protocol policy of %s", prefix)
+		}
+		for _, proto := range pol.Protocols {
+			if !isProtocolScheme(proto) {
+				return nil, errors.Errorf("invalid protocol %q for %s, expected one of %s", proto, prefix, strings.Join(protocolSchemes, ", "))
+			}
+		}
+		p.policies = append(p.policies, ProtocolPolicy{Prefix: prefix, Protocols: pol.Protocols})
+	}
+	sort.Stable(byPolicyPrefixLen(p.policies))
+	return p, nil
+}
+
+func isProtocolScheme(s string) bool {
+	for _, scheme := range protocolSchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// byPolicyPrefixLen sorts protocol policies from the longest prefix to the
+// shortest, leaving * last.
+type byPolicyPrefixLen []ProtocolPolicy
+
+func (s byPolicyPrefixLen) Len() int      { return len(s) }
+func (s byPolicyPrefixLen) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPolicyPrefixLen) Less(i, j int) bool {
+	if s[i].Prefix == "*" || s[j].Prefix == "*" {
+		return s[j].Prefix == "*" && s[i].Prefix != "*"
+	}
+	return len(s[i].Prefix) > len(s[j].Prefix)
+}
+
+// forURL returns the policy applying to u, if any.
+func (p *protocolPolicies) forURL(u *url.URL) (ProtocolPolicy, bool) {
+	if p == nil {
+		return ProtocolPolicy{}, false
+	}
+	key := strings.ToLower(u.Host) + u.Path
+	for _, pol := range p.policies {
+		if pol.Prefix == "*" || strings.HasPrefix(key, pol.Prefix) && isPathPrefixOrEqual(pol.Prefix, key) {
+			return pol, true
+		}
+	}
+	return ProtocolPolicy{}, false
+}
+
+// rank returns the preference of the policies for u, lower being preferred.
+func (p *protocolPolicies) rank(u *url.URL) int {
+	if pol, has := p.forURL(u); has {
+		for i, proto := range pol.Protocols {
+			if proto == u.Scheme {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// urls returns the URLs a source of the given type at u may be fetched from:
+// u itself if the policies allow its protocol, or else u over each of the
+// protocols allowed the type supports, from the preferred one.
+func (p *protocolPolicies) urls(u *url.URL, typ string) []*url.URL {
+	pol, has := p.forURL(u)
+	if !has {
+		return []*url.URL{u}
+	}
+	for _, proto := range pol.Protocols {
+		if proto == u.Scheme {
+			return []*url.URL{u}
+		}
+	}
+
+	var us []*url.URL
+	for _, proto := range pol.Protocols {
+		if !validateVCSScheme(proto, typ) {
+			continue
+		}
+		u2 := *u
+		u2.Scheme, u2.User = proto, nil
+		if strings.HasSuffix(proto, "ssh") {
+			switch typ {
+			case "git":
+				u2.User = url.User("git")
+			case "hg":
+				u2.User = url.User("hg")
+			}
+		}
+		us = append(us, &u2)
+	}
+	return us
+}
+
+// restrict returns mb with the URLs of its sources replaced by those the
+// policies allow, from the preferred protocol. The sources none of whose URLs
+// is allowed fail to be set up.
+func (p *protocolPolicies) restrict(mb maybeSource) maybeSource {
+	if p == nil {
+		return mb
+	}
+
+	var out maybeSources
+	var ranks []int
+	seen := make(map[string]bool)
+	add := func(m maybeSource, u *url.URL) {
+		if key := fmt.Sprintf("%T %s", m, u); !seen[key] {
+			seen[key] = true
+			out = append(out, m)
+			ranks = append(ranks, p.rank(u))
+		}
+	}
+	var denied []string
+	var walk func(maybeSource)
+	walk = func(m maybeSource) {
+		var typ string
+		var u *url.URL
+		switch tm := m.(type) {
+		case maybeSources:
+			for _, m := range tm {
+				walk(m)
+			}
+			return
+		case maybeGitSource:
+			typ, u = "git", tm.url
+		case maybeGopkginSource:
+			typ, u = "git", tm.url
+		case maybeHgSource:
+			typ, u = "hg", tm.url
+		case maybeBzrSource:
+			typ, u = "bzr", tm.url
+		default:
+			out = append(out, m)
+			ranks = append(ranks, 0)
+			return
+		}
+
+		us := p.urls(u, typ)
+		if len(us) == 0 {
+			denied = append(denied, u.String())
+		}
+		for _, u := range us {
+			switch tm := m.(type) {
+			case maybeGitSource:
+				add(maybeGitSource{url: u}, u)
+			case maybeGopkginSource:
+				tm.url = u
+				add(tm, u)
+			case maybeHgSource:
+				add(maybeHgSource{url: u}, u)
+			case maybeBzrSource:
+				add(maybeBzrSource{url: u}, u)
+			}
+		}
+	}
+	walk(mb)
+
+	if len(out) == 0 {
+		return deniedSource{urls: denied, policies: p}
+	}
+	sort.Stable(byRank{out, ranks})
+	if len(out) == 1 {
+		return out[0]
+	}
+	return out
+}
+
+// byRank sorts maybeSources by the rank of their URL.
+type byRank struct {
+	mbs   maybeSources
+	ranks []int
+}
+
+func (s byRank) Len() int           { return len(s.mbs) }
+func (s byRank) Less(i, j int) bool { return s.ranks[i] < s.ranks[j] }
+func (s byRank) Swap(i, j int) {
+	s.mbs[i], s.mbs[j] = s.mbs[j], s.mbs[i]
+	s.ranks[i], s.ranks[j] = s.ranks[j], s.ranks[i]
+}
+
+// deniedSource stands for a source whose URLs the protocol policies all deny.
+type deniedSource struct {
+	urls     []string
+	policies *protocolPolicies
+}
+
+func (m deniedSource) try(ctx context.Context, cachedir string, c singleSourceCache, superv *supervisor) (source, sourceState, error) {
+	u, _ := url.Parse(m.urls[0])
+	pol, _ := m.policies.forURL(u)
+	return nil, 0, errors.Errorf("%s can't be fetched over the protocols allowed for %s: %s", m.getURL(), pol.Prefix, strings.Join(pol.Protocols, ", "))
+}
+
+func (m deniedSource) getURL() string {
+	return strings.Join(m.urls, ", ")
+}
+
+// setGitCmd configures the git command cmd to rewrite the URLs under the
+// prefixes of the policies, as those of the submodules, to the preferred
+// protocol git supports, when their protocol isn't allowed.
+func (p *protocolPolicies) setGitCmd(cmd *exec.Cmd) {
+	var args []string
+	for i, pol := range p.policies {
+		for _, from := range gitSchemes {
+			// Keep the broader policies from rewriting the URLs this one
+			// allows, as git applies the longest matching prefix only.
+			if isAllowed(pol, from) && p.rewritesUnder(i, from) {
+				for _, base := range gitURLBases(from, pol.Prefix) {
+					args = append(args, "-c", "url."+base+".insteadOf="+base)
+				}
+			}
+		}
+
+		to := ""
+		for _, proto := range pol.Protocols {
+			if validateVCSScheme(proto, "git") {
+				to = gitURLBases(proto, pol.Prefix)[0]
+				break
+			}
+		}
+		if to == "" {
+			continue
+		}
+		for _, from := range gitSchemes {
+			if isAllowed(pol, from) {
+				continue
+			}
+			for _, base := range gitURLBases(from, pol.Prefix) {
+				args = append(args, "-c", "url."+to+".insteadOf="+base)
+			}
+		}
+	}
+	if len(args) > 0 {
+		cmd.Args = append(append([]string{cmd.Args[0]}, args...), cmd.Args[1:]...)
+	}
+}
+
+// rewritesUnder reports whether a policy broader than the i-th one, which
+// contains it, rewrites the git URLs over the given protocol.
+func (p *protocolPolicies) rewritesUnder(i int, scheme string) bool {
+	prefix := p.policies[i].Prefix
+	for _, pol := range p.policies[i+1:] {
+		if pol.Prefix == "*" || strings.HasPrefix(prefix, pol.Prefix) && isPathPrefixOrEqual(pol.Prefix, prefix) {
+			return !isAllowed(pol, scheme)
+		}
+	}
+	return false
+}
+
+func isAllowed(pol ProtocolPolicy, scheme string) bool {
+	for _, proto := range pol.Protocols {
+		if proto == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// gitURLBases returns the starts of the git URLs over the given protocol
+// under prefix, as url.<base>.insteadOf takes them. Those over ssh may also be
+// written in the scp-like syntax, which has no scheme and so can only be
+// matched under a host.
+func gitURLBases(scheme, prefix string) []string {
+	user := ""
+	if scheme == "ssh" {
+		user = "git@"
+	}
+	if prefix == "*" {
+		return []string{scheme + "://" + user}
+	}
+	bases := []string{scheme + "://" + user + prefix + "/"}
+	if scheme == "ssh" {
+		host, rest := prefix, ""
+		if i := strings.Index(host, "/"); i >= 0 {
+			host, rest = host[:i], host[i+1:]+"/"
+		}
+		bases = append(bases, "git@"+host+":"+rest)
+	}
+	return bases
+}
+
+type protocolPoliciesKey struct{}
+
+// withProtocolPolicies returns a copy of ctx carrying p, for the git commands
+// run in it.
+func withProtocolPolicies(ctx context.Context, p *protocolPolicies) context.Context {
+	return context.WithValue(ctx, protocolPoliciesKey{}, p)
+}
+
+// protocolPoliciesFrom returns the protocol policies ctx carries, or nil.
+func protocolPoliciesFrom(ctx context.Context) *protocolPolicies {
+	p, _ := ctx.Value(protocolPoliciesKey{}).(*protocolPolicies)
+	return p
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProtocolPolicies(t *testing.T) {
+	pp, err := ParseProtocolPolicies(" github.com=https, git.example.com/team=ssh|https,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProtocolPolicy{
+		{Prefix: "github.com", Protocols: []string{"https"}},
+		{Prefix: "git.example.com/team", Protocols: []string{"ssh", "https"}},
+	}
+	if !reflect.DeepEqual(pp, want) {
+		t.Errorf("unexpected policies:\n\t(GOT): %+v\n\t(WNT): %+v", pp, want)
+	}
+
+	if _, err := ParseProtocolPolicies("github.com"); err == nil {
+		t.Error("expected a policy without protocols to be rejected")
+	}
+
+	for _, pp := range [][]ProtocolPolicy{
+		{{Prefix: "github.com", Protocols: []string{"ftp"}}},
+		{{Prefix: "github.com"}},
+		{{Prefix: "https://github.com", Protocols: []string{"https"}}},
+		{{Prefix: "github.com", Protocols: []string{"https"}}, {Prefix: "GitHub.com/", Protocols: []string{"ssh"}}},
+	} {
+		if _, err := newProtocolPolicies(pp); err == nil {
+			t.Errorf("expected %+v to be rejected", pp)
+		}
+	}
+}
+
+func TestProtocolPoliciesRestrict(t *testing.T) {
+	p, err := newProtocolPolicies([]ProtocolPolicy{
+		{Prefix: "*", Protocols: []string{"https", "http"}},
+		{Prefix: "github.com/corp", Protocols: []string{"ssh", "https"}},
+		{Prefix: "bitbucket.org", Protocols: []string{"ssh"}},
+		{Prefix: "svn.example.com", Protocols: []string{"svn"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := func(mb maybeSource) []string {
+		var us []string
+		if mbs, ok := mb.(maybeSources); ok {
+			for _, m := range mbs {
+				us = append(us, m.getURL())
+			}
+			return us
+		}
+		return []string{mb.getURL()}
+	}
+	gitSources := func(us ...string) maybeSources {
+		var mbs maybeSources
+		for _, u := range us {
+			mbs = append(mbs, maybeGitSource{url: mkurl(u)})
+		}
+		return mbs
+	}
+
+	cases := []struct {
+		name string
+		in   maybeSource
+		want []string
+	}{
+		{
+			"rewritten to https",
+			gitSources("ssh://git@github.com/foo/bar", "git://github.com/foo/bar"),
+			[]string{"https://github.com/foo/bar", "http://github.com/foo/bar"},
+		},
+		{
+			"preferred first",
+			gitSources("https://github.com/corp/bar", "http://github.com/corp/bar", "ssh://git@github.com/corp/bar"),
+			[]string{"ssh://git@github.com/corp/bar", "https://github.com/corp/bar"},
+		},
+		{
+			"path boundary",
+			gitSources("ssh://git@github.com/corporate/bar"),
+			[]string{"https://github.com/corporate/bar", "http://github.com/corporate/bar"},
+		},
+		{
+			"hg over ssh",
+			maybeHgSource{url: mkurl("https://bitbucket.org/foo/bar")},
+			[]string{"ssh://hg@bitbucket.org/foo/bar"},
+		},
+	}
+	for _, c := range cases {
+		if got := urls(p.restrict(c.in)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: unexpected URLs:\n\t(GOT): %q\n\t(WNT): %q", c.name, got, c.want)
+		}
+	}
+
+	gm := p.restrict(maybeGopkginSource{opath: "gopkg.in/yaml.v2", url: mkurl("ssh://git@github.com/go-yaml/yaml"), major: 2})
+	if m := gm.(maybeSources)[0].(maybeGopkginSource); m.opath != "gopkg.in/yaml.v2" || m.url.String() != "https://github.com/go-yaml/yaml" {
+		t.Errorf("expected gopkg.in/yaml.v2 to be fetched from https://github.com/go-yaml/yaml, got %s from %s", m.opath, m.url)
+	}
+
+	denied := p.restrict(maybeBzrSource{url: mkurl("https://svn.example.com/foo/bar")})
+	_, _, err = denied.try(context.Background(), "", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "allowed for svn.example.com: svn") {
+		t.Errorf("expected the bzr source to be denied, got %v", err)
+	}
+
+	var none *protocolPolicies
+	if mb := gitSources("git://github.com/foo/bar"); !reflect.DeepEqual(none.restrict(mb), mb) {
+		t.Error("expected no policies to leave the sources as they are")
+	}
+}
+
+func TestProtocolPoliciesGitCmd(t *testing.T) {
+	p, err := newProtocolPolicies([]ProtocolPolicy{
+		{Prefix: "*", Protocols: []string{"https"}},
+		{Prefix: "git.example.com/team", Protocols: []string{"ssh"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("git", "clone", "https://github.com/foo/bar")
+	p.setGitCmd(cmd)
+	want := []string{
+		"git",
+		"-c", "url.ssh://git@git.example.com/team/.insteadOf=ssh://git@git.example.com/team/",
+		"-c", "url.git@git.example.com:team/.insteadOf=git@git.example.com:team/",
+		"-c", "url.ssh://git@git.example.com/team/.insteadOf=https://git.example.com/team/",
+		"-c", "url.ssh://git@git.example.com/team/.insteadOf=git://git.example.com/team/",
+		"-c", "url.ssh://git@git.example.com/team/.insteadOf=http://git.example.com/team/",
+		"-c", "url.https://.insteadOf=ssh://git@",
+		"-c", "url.https://.insteadOf=git://",
+		"-c", "url.https://.insteadOf=http://",
+		"clone", "https://github.com/foo/bar",
+	}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("unexpected arguments:\n\t(GOT): %q\n\t(WNT): %q", cmd.Args, want)
+	}
+
+	cmd = exec.Command("git", "ls-remote")
+	ctx := withProtocolPolicies(context.Background(), p)
+	setCmdEnv(ctx, cmd)
+	if !reflect.DeepEqual(cmd.Args, append(want[:len(want)-2:len(want)-2], "ls-remote")) {
+		t.Errorf("expected the policies in the context to be applied, got %q", cmd.Args)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// proxyConfig holds the proxies to use for HTTP and HTTPS URLs, as set in the
+// environment. The proxies may be HTTP, HTTPS or SOCKS5 ones, though only git
+// supports the latter; see httpProxyURL.
+type proxyConfig struct {
+	httpProxy  string
+	httpsProxy string
+	// noProxy is a comma-separated list of hosts, domain suffixes and IP
+	// addresses not to use a proxy for, or "*" for all of them.
+	noProxy string
+}
+
+// proxyConfigFromEnv reads the proxy configuration with getenv. Upper and lower
+// case variables are both honored, and ALL_PROXY is the fallback for the
+// schemes without their own variable.
+func proxyConfigFromEnv(getenv func(string) string) proxyConfig {
+	get := func(name string) string {
+		if v := getenv(strings.ToUpper(name)); v != "" {
+			return v
+		}
+		return getenv(name)
+	}
+
+	c := proxyConfig{
+		httpProxy:  get("http_proxy"),
+		httpsProxy: get("https_proxy"),
+		noProxy:    get("no_proxy"),
+	}
+	if all := get("all_proxy"); all != "" {
+		if c.httpProxy == "" {
+			c.httpProxy = all
+		}
+		if c.httpsProxy == "" {
+			c.httpsProxy = all
+		}
+	}
+	return c
+}
+
+// proxyURL returns the URL of the proxy to reach u through, or nil if u is to
+// be reached directly.
+func (c proxyConfig) proxyURL(u *url.URL) (*url.URL, error) {
+	var proxy string
+	switch u.Scheme {
+	case "http":
+		proxy = c.httpProxy
+	case "https":
+		proxy = c.httpsProxy
+	}
+	if proxy == "" || !c.useProxy(u.Host) {
+		return nil, nil
+	}
+
+	// Like curl, consider proxies without a scheme to be HTTP ones.
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	return url.Parse(proxy)
+}
+
+// httpProxyURL is like proxyURL, for the HTTP requests gps makes itself. The
+// HTTP client of the Go versions dep supports can't talk to SOCKS5 proxies,
+// and would send them HTTP requests, so an error tells so instead.
+func (c proxyConfig) httpProxyURL(u *url.URL) (*url.URL, error) {
+	proxy, err := c.proxyURL(u)
+	if err != nil || proxy == nil {
+		return proxy, err
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, errors.Errorf("unsupported proxy %s for %s: only git supports %s proxies, dep needs an HTTP one", proxy, u.Host, proxy.Scheme)
+	}
+	return proxy, nil
+}
+
+// useProxy reports whether a proxy should be used to reach host, which may
+// carry a port.
+func (c proxyConfig) useProxy(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, np := range strings.Split(c.noProxy, ",") {
+		np = strings.ToLower(strings.TrimSpace(np))
+		if h, _, err := net.SplitHostPort(np); err == nil {
+			np = h
+		}
+		switch {
+		case np == "":
+			continue
+		case np == "*":
+			return false
+		}
+
+		if _, ipnet, err := net.ParseCIDR(np); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ipnet.Contains(ip) {
+				return false
+			}
+			continue
+		}
+
+		np = strings.TrimPrefix(np, ".")
+		if host == np || strings.HasSuffix(host, "."+np) {
+			return false
+		}
+	}
+	return true
+}
+
+// env returns the environment variables that make git, hg and bzr use the
+// same proxies as gps itself. They only all agree on the lower case variables,
+// and none of them honor ALL_PROXY, so the resolved proxies are set
+// explicitly.
+func (c proxyConfig) env() []string {
+	var env []string
+	for _, v := range []struct{ name, value string }{
+		{"http_proxy", c.httpProxy},
+		{"https_proxy", c.httpsProxy},
+		{"no_proxy", c.noProxy},
+	} {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env
+}
+
+// httpClient is used for all the HTTP requests made by gps. Unlike
+// http.DefaultClient, it honors ALL_PROXY, and reads the proxy configuration
+// from the environment on every request. Its transport otherwise has the
+// settings of http.DefaultTransport.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return proxyConfigFromEnv(os.Getenv).httpProxyURL(req.URL)
+		},
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
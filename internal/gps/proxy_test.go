@@ -0,0 +1,138 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProxyConfigFromEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want proxyConfig
+	}{
+		{
+			name: "none",
+			want: proxyConfig{},
+		},
+		{
+			name: "upper case wins",
+			env: map[string]string{
+				"HTTP_PROXY":  "http://upper:3128",
+				"http_proxy":  "http://lower:3128",
+				"https_proxy": "http://lower:3128",
+				"no_proxy":    "example.com",
+			},
+			want: proxyConfig{
+				httpProxy:  "http://upper:3128",
+				httpsProxy: "http://lower:3128",
+				noProxy:    "example.com",
+			},
+		},
+		{
+			name: "all proxy fallback",
+			env: map[string]string{
+				"ALL_PROXY":  "socks5://socks:1080",
+				"HTTP_PROXY": "http://proxy:3128",
+			},
+			want: proxyConfig{
+				httpProxy:  "http://proxy:3128",
+				httpsProxy: "socks5://socks:1080",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := proxyConfigFromEnv(func(k string) string { return c.env[k] })
+			if got != c.want {
+				t.Errorf("unexpected proxy config:\n\t(GOT): %+v\n\t(WNT): %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	c := proxyConfig{
+		httpProxy:  "proxy.corp:3128",
+		httpsProxy: "socks5://socks.corp:1080",
+		noProxy:    "internal.corp, .example.org,10.0.0.0/8,git.local:8080",
+	}
+
+	cases := map[string]string{
+		"http://github.com/foo/bar":     "http://proxy.corp:3128",
+		"https://github.com/foo/bar":    "socks5://socks.corp:1080",
+		"https://internal.corp/foo":     "",
+		"https://git.internal.corp/foo": "",
+		"https://example.org/foo":       "",
+		"https://go.example.org/foo":    "",
+		"https://notexample.org/foo":    "socks5://socks.corp:1080",
+		"https://10.1.2.3/foo":          "",
+		"https://11.1.2.3/foo":          "socks5://socks.corp:1080",
+		"https://git.local/foo":         "",
+		"http://localhost:8080/foo":     "",
+		"http://127.0.0.1/foo":          "",
+		"ftp://github.com/foo/bar":      "",
+	}
+
+	for in, want := range cases {
+		u, err := url.Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.proxyURL(u)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", in, err)
+			continue
+		}
+		var gots string
+		if got != nil {
+			gots = got.String()
+		}
+		if gots != want {
+			t.Errorf("%s: expected proxy %q, got %q", in, want, gots)
+		}
+	}
+
+	if got, _ := (proxyConfig{httpsProxy: "socks5://socks.corp:1080", noProxy: "*"}).proxyURL(&url.URL{Scheme: "https", Host: "github.com"}); got != nil {
+		t.Errorf("expected no proxy to be used with NO_PROXY=*, got %s", got)
+	}
+}
+
+func TestHTTPProxyURL(t *testing.T) {
+	u := &url.URL{Scheme: "https", Host: "github.com"}
+	got, err := (proxyConfig{httpsProxy: "proxy.corp:3128"}).httpProxyURL(u)
+	if err != nil || got == nil || got.String() != "http://proxy.corp:3128" {
+		t.Errorf("expected the HTTP proxy to be used, got %v, %v", got, err)
+	}
+
+	// The HTTP client can't talk to SOCKS5 proxies, only git can.
+	_, err = (proxyConfig{httpsProxy: "socks5://socks.corp:1080"}).httpProxyURL(u)
+	if err == nil || !strings.Contains(err.Error(), "only git supports socks5 proxies") {
+		t.Errorf("expected the SOCKS5 proxy to be refused, got %v", err)
+	}
+}
+
+func TestProxyConfigEnv(t *testing.T) {
+	c := proxyConfigFromEnv(func(k string) string {
+		return map[string]string{
+			"ALL_PROXY": "socks5://socks.corp:1080",
+			"NO_PROXY":  "internal.corp",
+		}[k]
+	})
+
+	want := []string{
+		"http_proxy=socks5://socks.corp:1080",
+		"https_proxy=socks5://socks.corp:1080",
+		"no_proxy=internal.corp",
+	}
+	if got := c.env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected environment:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
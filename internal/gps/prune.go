@@ -0,0 +1,200 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PruneOptions is a set of flags telling which files to prune from a project
+// written out by WriteDepTree.
+type PruneOptions uint8
+
+const (
+	// PruneUnusedPackages prunes the directories which hold none of the
+	// packages listed in the lock for the project, nor any of their parents.
+	PruneUnusedPackages PruneOptions = 1 << iota
+	// PruneNonGoFiles prunes the files which the go tool doesn't build, other
+	// than the legal files.
+	PruneNonGoFiles
+	// PruneGoTestFiles prunes the Go test files.
+	PruneGoTestFiles
+)
+
+// PruneOptionSet overrides the default prune options for a project: the
+// options in Mask are set as they are in Options, the others are left as
+// they are by default.
+type PruneOptionSet struct {
+	Mask, Options PruneOptions
+}
+
+// CascadingPruneOptions holds the prune options of all the projects, as
+// defaults of the root project overridden for some projects.
+type CascadingPruneOptions struct {
+	DefaultOptions    PruneOptions
+	PerProjectOptions map[ProjectRoot]PruneOptionSet
+}
+
+// PruneOptionsFor returns the prune options of the given project.
+func (o CascadingPruneOptions) PruneOptionsFor(pr ProjectRoot) PruneOptions {
+	ps, has := o.PerProjectOptions[pr]
+	if !has {
+		return o.DefaultOptions
+	}
+	return o.DefaultOptions&^ps.Mask | ps.Options&ps.Mask
+}
+
+// PruneDepTree prunes the projects listed in the lock, as written out beneath
+// basedir by WriteDepTree, each according to its own options.
+func PruneDepTree(basedir string, l Lock, o CascadingPruneOptions) error {
+	for _, p := range l.Projects() {
+		if err := PruneProject(basedir, p, o.PruneOptionsFor(p.Ident().ProjectRoot)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneProject prunes the given project, as written out beneath basedir by
+// WriteDepTree, according to the options. Nested vendor directories are left
+// alone; see StripNestedVendor.
+func PruneProject(basedir string, lp LockedProject, options PruneOptions) error {
+	_, err := PruneProjectStats(basedir, lp, options, false)
+	return err
+}
+
+// PruneStats tells how much pruning removed from a project.
+type PruneStats struct {
+	Files int   // Number of files removed.
+	Bytes int64 // Total size of the files removed.
+}
+
+// PruneProjectStats prunes the given project as PruneProject does, and reports
+// how many files it removed. With dryRun, nothing is removed, and what would be
+// is reported instead.
+func PruneProjectStats(basedir string, lp LockedProject, options PruneOptions, dryRun bool) (PruneStats, error) {
+	root := filepath.Join(basedir, filepath.FromSlash(string(lp.Ident().ProjectRoot)))
+	pr := &pruner{dryRun: dryRun, gone: make(map[string]bool)}
+
+	if options&PruneUnusedPackages != 0 {
+		if err := pr.pruneUnusedPackages(root, lp.Packages()); err != nil {
+			return pr.stats, err
+		}
+	}
+	if options&(PruneNonGoFiles|PruneGoTestFiles) == 0 {
+		return pr.stats, nil
+	}
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fi.Name() == "vendor" || pr.gone[p] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := fi.Name()
+		switch {
+		case pr.gone[p]:
+			return nil
+		case options&PruneGoTestFiles != 0 && strings.HasSuffix(name, "_test.go"):
+		case options&PruneNonGoFiles != 0 && !isGoBuildFile(name) && !legalFilePattern.MatchString(name):
+		default:
+			return nil
+		}
+		return pr.removeFile(p, fi)
+	})
+	if err != nil || dryRun {
+		return pr.stats, err
+	}
+
+	_, err = pruneEmptyDirs(root, false)
+	return pr.stats, err
+}
+
+// pruner removes the files of a project, keeping count of them. On a dry run,
+// it only records them as gone.
+type pruner struct {
+	dryRun bool
+	stats  PruneStats
+	gone   map[string]bool // Paths removed, or which would be on a dry run.
+}
+
+func (pr *pruner) removeFile(p string, fi os.FileInfo) error {
+	pr.stats.Files++
+	pr.stats.Bytes += fi.Size()
+	if pr.dryRun {
+		pr.gone[p] = true
+		return nil
+	}
+	return os.Remove(p)
+}
+
+func (pr *pruner) removeDir(dir string) error {
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			pr.stats.Files++
+			pr.stats.Bytes += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if pr.dryRun {
+		pr.gone[dir] = true
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+// goBuildExts are the extensions of the files the go tool builds.
+var goBuildExts = map[string]bool{
+	".go": true, ".s": true, ".S": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".cxx": true,
+	".hh": true, ".hpp": true, ".hxx": true, ".m": true, ".f": true, ".F": true, ".for": true, ".f90": true,
+	".swig": true, ".swigcxx": true, ".syso": true,
+}
+
+func isGoBuildFile(name string) bool {
+	return goBuildExts[filepath.Ext(name)]
+}
+
+// pruneEmptyDirs removes the directories beneath dir which are left empty,
+// and dir itself if it's left empty and remove is set. It reports whether dir
+// was removed. Nested vendor directories are left alone.
+func pruneEmptyDirs(dir string, remove bool) (bool, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	left := len(fis)
+	for _, fi := range fis {
+		if !fi.IsDir() || fi.Name() == "vendor" {
+			continue
+		}
+		removed, err := pruneEmptyDirs(filepath.Join(dir, fi.Name()), true)
+		if err != nil {
+			return false, err
+		}
+		if removed {
+			left--
+		}
+	}
+
+	if left > 0 || !remove {
+		return false, nil
+	}
+	return true, os.Remove(dir)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/golang/dep/internal/fs"
+)
+
+// legalFilePattern matches the names of the files holding the license and
+// other legal notices of projects, which pruning always keeps.
+var legalFilePattern = regexp.MustCompile(`(?i)^((un)?licen[cs]e|copying|copyright|notice|patents|authors|contributors)([.\-_].*)?$`)
+
+// pruneUnusedPackages prunes the project written out at root, keeping the
+// packages pkgs, given relative to root, and their parents. Only the legal
+// files, such as LICENSE or NOTICE, are kept in those parents, so that the
+// project keeps its license.
+//
+// The packages listed for a project are all those reachable from the imports
+// of the root project, including the ones imported from within the project
+// itself, so what remains still builds. Nested vendor directories are left
+// alone; see StripNestedVendor.
+func (pr *pruner) pruneUnusedPackages(root string, pkgs []string) error {
+	// Names are compared once normalized, as they may be spelled differently
+	// on the filesystem than in the lock.
+	keep := make(map[string]bool, len(pkgs))
+	parents := map[string]bool{".": true}
+	for _, pkg := range pkgs {
+		pkg = fs.NormalizeName(pkg)
+		keep[pkg] = true
+		for dir := path.Dir(pkg); dir != "."; dir = path.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = fs.NormalizeName(filepath.ToSlash(rel))
+
+		if fi.IsDir() {
+			switch {
+			case keep[rel] || parents[rel]:
+				return nil
+			case fi.Name() == "vendor":
+				return filepath.SkipDir
+			}
+			if err := pr.removeDir(p); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+
+		if keep[path.Dir(rel)] || legalFilePattern.MatchString(fi.Name()) {
+			return nil
+		}
+		return pr.removeFile(p, fi)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPruneUnusedPackages(t *testing.T) {
+	basedir, err := ioutil.TempDir("", "pruneunused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(basedir)
+
+	root := filepath.Join(basedir, "github.com", "example", "mono")
+	files := []string{
+		"LICENSE",
+		"README.md",
+		"mono.go",
+		"cmd/tool/main.go",
+		"lib/NOTICE.txt",
+		"lib/lib.go",
+		"lib/json/json.go",
+		"lib/json/testdata/in.json",
+		"lib/json/internal/scan/scan.go",
+		"lib/xml/xml.go",
+		"lib/vendor/github.com/other/other.go",
+		"services/billing/billing.go",
+	}
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/example/mono"), NewVersion("v1.0.0"), []string{"lib/json", "lib/json/internal/scan"}),
+	}
+	if err := PruneDepTree(basedir, l, CascadingPruneOptions{DefaultOptions: PruneUnusedPackages}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"LICENSE",
+		"lib/NOTICE.txt",
+		"lib/json/internal/scan/scan.go",
+		"lib/json/json.go",
+		"lib/vendor/github.com/other/other.go",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected files left:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
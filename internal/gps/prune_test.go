@@ -0,0 +1,197 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCascadingPruneOptions(t *testing.T) {
+	o := CascadingPruneOptions{
+		DefaultOptions: PruneUnusedPackages | PruneGoTestFiles,
+		PerProjectOptions: map[ProjectRoot]PruneOptionSet{
+			"github.com/keep/tests": {Mask: PruneGoTestFiles},
+			"github.com/strip/all":  {Mask: PruneNonGoFiles, Options: PruneNonGoFiles},
+		},
+	}
+
+	cases := map[ProjectRoot]PruneOptions{
+		"github.com/other/project": PruneUnusedPackages | PruneGoTestFiles,
+		"github.com/keep/tests":    PruneUnusedPackages,
+		"github.com/strip/all":     PruneUnusedPackages | PruneGoTestFiles | PruneNonGoFiles,
+	}
+	for pr, want := range cases {
+		if got := o.PruneOptionsFor(pr); got != want {
+			t.Errorf("%s: expected options %b, got %b", pr, want, got)
+		}
+	}
+}
+
+func TestPruneDepTree(t *testing.T) {
+	basedir, err := ioutil.TempDir("", "prunedeptree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(basedir)
+
+	files := []string{
+		"LICENSE",
+		"README.md",
+		"a.go",
+		"a_test.go",
+		"asm_amd64.s",
+		"docs/guide.md",
+		"testdata/in.json",
+		"vendor/github.com/other/README.md",
+	}
+	projects := []ProjectRoot{"github.com/example/tests", "github.com/example/nongo"}
+	for _, pr := range projects {
+		for _, f := range files {
+			path := filepath.Join(basedir, filepath.FromSlash(string(pr)), filepath.FromSlash(f))
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(f), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	l := SimpleLock{
+		NewLockedProject(mkPI("github.com/example/tests"), NewVersion("v1.0.0"), []string{"."}),
+		NewLockedProject(mkPI("github.com/example/nongo"), NewVersion("v1.0.0"), []string{"."}),
+	}
+	o := CascadingPruneOptions{
+		DefaultOptions: PruneGoTestFiles,
+		PerProjectOptions: map[ProjectRoot]PruneOptionSet{
+			"github.com/example/nongo": {Mask: PruneNonGoFiles | PruneGoTestFiles, Options: PruneNonGoFiles},
+		},
+	}
+	if err := PruneDepTree(basedir, l, o); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[ProjectRoot][]string{
+		"github.com/example/tests": {
+			"LICENSE",
+			"README.md",
+			"a.go",
+			"asm_amd64.s",
+			"docs/guide.md",
+			"testdata/in.json",
+			"vendor/github.com/other/README.md",
+		},
+		"github.com/example/nongo": {
+			"LICENSE",
+			"a.go",
+			"a_test.go",
+			"asm_amd64.s",
+			"vendor/github.com/other/README.md",
+		},
+	}
+	for _, pr := range projects {
+		root := filepath.Join(basedir, filepath.FromSlash(string(pr)))
+		var got []string
+		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			if fi.IsDir() {
+				if fis, _ := ioutil.ReadDir(path); len(fis) == 0 {
+					t.Errorf("%s: expected empty directory %s to be removed", pr, rel)
+				}
+				return nil
+			}
+			got = append(got, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, want[pr]) {
+			t.Errorf("%s: unexpected files left:\n\t(GOT): %v\n\t(WNT): %v", pr, got, want[pr])
+		}
+	}
+}
+
+func TestPruneProjectStats(t *testing.T) {
+	basedir, err := ioutil.TempDir("", "pruneprojectstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(basedir)
+
+	root := filepath.Join(basedir, "github.com", "example", "mono")
+	kept := []string{
+		"LICENSE",
+		"lib/NOTICE.txt",
+		"lib/json/json.go",
+	}
+	pruned := []string{
+		"README.md",
+		"mono.go",
+		"cmd/tool/main.go",
+		"lib/lib.go",
+		"lib/json/json_test.go",
+		"lib/json/testdata/in.json",
+		"lib/xml/xml.go",
+	}
+	var want PruneStats
+	for _, f := range append(kept, pruned...) {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range pruned {
+		want.Files++
+		want.Bytes += int64(len(f))
+	}
+
+	lp := NewLockedProject(mkPI("github.com/example/mono"), NewVersion("v1.0.0"), []string{"lib/json"})
+	options := PruneUnusedPackages | PruneNonGoFiles | PruneGoTestFiles
+
+	got, err := PruneProjectStats(basedir, lp, options, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("unexpected dry run stats: (GOT) %+v (WNT) %+v", got, want)
+	}
+	for _, f := range pruned {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s to be left on a dry run: %s", f, err)
+		}
+	}
+
+	got, err = PruneProjectStats(basedir, lp, options, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("unexpected stats: (GOT) %+v (WNT) %+v", got, want)
+	}
+	for _, f := range pruned {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(f))); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be pruned", f)
+		}
+	}
+	for _, f := range kept {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s to be kept: %s", f, err)
+		}
+	}
+}
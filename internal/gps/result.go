@@ -9,8 +9,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 
+	"github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
 
@@ -26,6 +29,9 @@ type Solution interface {
 	SolverName() string
 	// The version of the Solver used in generating this solution.
 	SolverVersion() int
+	// The release of Go the solution builds with, as SolveParameters.GoVersion
+	// set it, or "" if it was not set.
+	GoVersion() string
 	Attempts() int
 }
 
@@ -44,6 +50,9 @@ type solution struct {
 
 	// The solver used in producing this solution
 	solv Solver
+
+	// The release of Go the solution builds with, if not zero
+	goVersion GoVersion
 }
 
 // WriteDepTree takes a basedir and a Lock, and exports all the projects
@@ -55,7 +64,17 @@ type solution struct {
 // It requires a SourceManager to do the work, and takes a flag indicating
 // whether or not to strip vendor directories contained in the exported
 // dependencies.
+//
+// The projects are exported by as many workers as there are CPUs; see
+// WriteDepTreeParallel to set their number.
 func WriteDepTree(basedir string, l Lock, sm SourceManager, sv bool, logger *log.Logger) error {
+	return WriteDepTreeParallel(basedir, l, sm, sv, 0, logger)
+}
+
+// WriteDepTreeParallel is WriteDepTree, exporting the projects with the given
+// number of concurrent workers, or as many as there are CPUs if workers isn't
+// positive. Once an export failed, the projects not yet started are skipped.
+func WriteDepTreeParallel(basedir string, l Lock, sm SourceManager, sv bool, workers int, logger *log.Logger) error {
 	if l == nil {
 		return fmt.Errorf("must provide non-nil Lock to WriteDepTree")
 	}
@@ -65,25 +84,59 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, sv bool, logger *log
 		return err
 	}
 
-	var wg sync.WaitGroup
+	// Projects whose roots collide would be written over each other.
+	roots := make([]string, 0, len(l.Projects()))
+	for _, p := range l.Projects() {
+		roots = append(roots, string(p.Ident().ProjectRoot))
+	}
+	if err := checkExportablePaths(roots, runtime.GOOS == "windows", fs.IsCaseSensitiveFilesystem(basedir)); err != nil {
+		return err
+	}
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(l.Projects()) {
+		workers = len(l.Projects())
+	}
+
+	projects := make(chan LockedProject)
+	// Closed on the first failure, to stop handing out projects.
+	failed := make(chan struct{})
+	var failOnce sync.Once
 	errCh := make(chan error, len(l.Projects()))
 
-	for _, p := range l.Projects() {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(p LockedProject) {
-			to := filepath.FromSlash(filepath.Join(basedir, string(p.Ident().ProjectRoot)))
-			logger.Printf("Writing out %s@%s", p.Ident().errString(), p.Version())
-
-			if err := sm.ExportProject(p.Ident(), p.Version(), to); err != nil {
-				errCh <- errors.Wrapf(err, "failed to export %s", p.Ident().ProjectRoot)
+		go func() {
+			defer wg.Done()
+			for p := range projects {
+				to := filepath.FromSlash(filepath.Join(basedir, string(p.Ident().ProjectRoot)))
+				logger.Printf("Writing out %s@%s", p.Ident().errString(), p.Version())
+
+				if err := sm.ExportProject(p.Ident(), p.Version(), to); err != nil {
+					errCh <- errors.Wrapf(err, "failed to export %s", p.Ident().ProjectRoot)
+					failOnce.Do(func() { close(failed) })
+					continue
+				}
+
+				if sv {
+					filepath.Walk(to, stripVendor)
+				}
 			}
+		}()
+	}
 
-			if sv {
-				filepath.Walk(to, stripVendor)
-			}
-			wg.Done()
-		}(p)
+feed:
+	for _, p := range l.Projects() {
+		select {
+		case projects <- p:
+		case <-failed:
+			break feed
+		}
 	}
+	close(projects)
 
 	wg.Wait()
 	close(errCh)
@@ -101,6 +154,40 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, sv bool, logger *log
 	return nil
 }
 
+// checkExportablePaths returns an error if the slash-separated paths can't all
+// be written to the filesystem: on Windows, if one of them uses a reserved
+// name, and on case-insensitive filesystems, if two of them only differ in
+// case, which would make one overwrite the other.
+func checkExportablePaths(paths []string, windows, caseSensitive bool) error {
+	if windows {
+		for _, p := range paths {
+			for _, elem := range strings.Split(p, "/") {
+				if fs.IsWindowsReservedName(elem) {
+					return fmt.Errorf("%s can't be written on Windows, as %q is a reserved name", p, elem)
+				}
+			}
+		}
+	}
+	if !caseSensitive {
+		if c := fs.CaseCollisions(paths); len(c) > 0 {
+			return fmt.Errorf("%s and %s only differ in case, and can't both be written on a case-insensitive filesystem", c[0][0], c[0][1])
+		}
+	}
+	return nil
+}
+
+// StripNestedVendor removes the vendor directories contained in the projects
+// listed in the lock, as written out beneath basedir by WriteDepTree.
+func StripNestedVendor(basedir string, l Lock) error {
+	for _, p := range l.Projects() {
+		to := filepath.FromSlash(filepath.Join(basedir, string(p.Ident().ProjectRoot)))
+		if err := filepath.Walk(to, stripVendor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r solution) Projects() []LockedProject {
 	return r.p
 }
@@ -128,3 +215,10 @@ func (r solution) SolverName() string {
 func (r solution) SolverVersion() int {
 	return r.solv.Version()
 }
+
+func (r solution) GoVersion() string {
+	if r.goVersion.IsZero() {
+		return ""
+	}
+	return r.goVersion.String()
+}
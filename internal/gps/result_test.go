@@ -5,12 +5,17 @@
 package gps
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var discardLogger = log.New(ioutil.Discard, "", 0)
@@ -114,6 +119,70 @@ func testWriteDepTree(t *testing.T) {
 	}
 }
 
+// exportCountingSM records the projects it exports, and how many of them it
+// exported at once at most.
+type exportCountingSM struct {
+	SourceManager
+	fail bool
+
+	mu            sync.Mutex
+	exported      []ProjectRoot
+	running, peak int
+}
+
+func (sm *exportCountingSM) ExportProject(id ProjectIdentifier, v Version, to string) error {
+	sm.mu.Lock()
+	sm.exported = append(sm.exported, id.ProjectRoot)
+	sm.running++
+	if sm.running > sm.peak {
+		sm.peak = sm.running
+	}
+	sm.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	sm.mu.Lock()
+	sm.running--
+	sm.mu.Unlock()
+	if sm.fail {
+		return errors.New("export failed")
+	}
+	return nil
+}
+
+func TestWriteDepTreeParallel(t *testing.T) {
+	var l SimpleLock
+	for i := 0; i < 8; i++ {
+		l = append(l, NewLockedProject(pi(fmt.Sprintf("example.com/p%d", i)), NewVersion("v1.0.0"), nil))
+	}
+
+	tmp, err := ioutil.TempDir("", "write-dep-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	sm := &exportCountingSM{}
+	if err := WriteDepTreeParallel(tmp, l, sm, false, 3, discardLogger); err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.exported) != len(l) {
+		t.Errorf("expected all %d projects to be exported, got %v", len(l), sm.exported)
+	}
+	if sm.peak > 3 {
+		t.Errorf("expected at most 3 projects to be exported at once, got %d", sm.peak)
+	}
+
+	// Once an export failed, the other projects are skipped.
+	sm = &exportCountingSM{fail: true}
+	if err := WriteDepTreeParallel(tmp, l, sm, false, 1, discardLogger); err == nil {
+		t.Fatal("expected the failed export to be reported")
+	}
+	if len(sm.exported) != 1 {
+		t.Errorf("expected the projects after the failed one to be skipped, got %v", sm.exported)
+	}
+}
+
 func BenchmarkCreateVendorTree(b *testing.B) {
 	// We're fs-bound here, so restrict to single parallelism
 	b.SetParallelism(1)
@@ -158,3 +227,17 @@ func BenchmarkCreateVendorTree(b *testing.B) {
 	sm.Release()
 	os.RemoveAll(tmp) // comment this to leave temp dir behind for inspection
 }
+
+func TestCheckExportablePaths(t *testing.T) {
+	paths := []string{"foo/aux.go", "foo/bar.go", "FOO/baz.go"}
+
+	if err := checkExportablePaths(paths, false, true); err != nil {
+		t.Errorf("expected any path to be exportable on case-sensitive filesystems, got %s", err)
+	}
+	if err := checkExportablePaths(paths, true, true); err == nil || !strings.Contains(err.Error(), `"aux.go" is a reserved name`) {
+		t.Errorf("expected aux.go to be reported as reserved on Windows, got %v", err)
+	}
+	if err := checkExportablePaths(paths, false, false); err == nil || !strings.Contains(err.Error(), "FOO and foo only differ in case") {
+		t.Errorf("expected FOO and foo to collide on case-insensitive filesystems, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ package gps
 
 import (
 	"sort"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/golang/dep/internal/gps/pkgtree"
@@ -17,7 +18,11 @@ type rootdata struct {
 	// Path to the root of the project on which gps is operating.
 	dir string
 
-	// Map of packages to ignore.
+	// Path to the directory holding the dependencies of the root project,
+	// vendor/ under dir by default.
+	vendorDir string
+
+	// Map of packages, or patterns of packages, to ignore.
 	ig map[string]bool
 
 	// Map of packages to require.
@@ -48,6 +53,35 @@ type rootdata struct {
 
 	// The ProjectAnalyzer to use for all GetManifestAndLock calls.
 	an ProjectAnalyzer
+
+	// If non-zero, only versions released no later than this time are
+	// considered.
+	asOf time.Time
+
+	// If non-zero, only versions released no later than this time are
+	// considered, besides the locked ones.
+	releasedBefore time.Time
+
+	// How far the projects locked to a semantic version may be updated.
+	updateLevel UpdateLevel
+
+	// Whether the pre-releases of the projects are candidates.
+	prereleases PrereleasePolicies
+
+	// The projects whose versions are listed from their upstream rather than
+	// from the fork they're fetched from.
+	upstreamVersions map[ProjectRoot]bool
+
+	// The release of Go the solution must build with, if not zero.
+	goVersion GoVersion
+
+	// The digests of the patches applied to projects once written out.
+	patchDigests map[ProjectRoot][]byte
+}
+
+// ignoreRules returns the rules telling which packages are ignored.
+func (rd rootdata) ignoreRules() *pkgtree.IgnoreRules {
+	return pkgtree.NewIgnoreRules(rd.ig)
 }
 
 // externalImportList returns a list of the unique imports from the root data.
@@ -189,9 +223,10 @@ func (rd rootdata) rootAtom() atomWithPackages {
 		v: rootRev,
 	}
 
+	ig := rd.ignoreRules()
 	list := make([]string, 0, len(rd.rpt.Packages))
 	for path, pkg := range rd.rpt.Packages {
-		if pkg.Err != nil && !rd.ig[path] {
+		if pkg.Err != nil && !ig.IsIgnored(path) {
 			list = append(list, path)
 		}
 	}
@@ -7,6 +7,7 @@ package gps
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestRootdataExternalImports(t *testing.T) {
@@ -222,3 +223,36 @@ func TestGetApplicableConstraints(t *testing.T) {
 		})
 	}
 }
+
+func TestRootdataAsOfImpliesChangeAll(t *testing.T) {
+	fix := basicFixtures["shared dependency with overlapping constraints"]
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	is, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	if is.(*solver).rd.chngall {
+		t.Error("Expected projects not to be changed without ChangeAll or AsOf")
+	}
+
+	params.AsOf = time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	is, err = Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while prepping solver: %s", err)
+	}
+	rd := is.(*solver).rd
+	if !rd.chngall {
+		t.Error("Expected AsOf to imply ChangeAll")
+	}
+	if !rd.asOf.Equal(params.AsOf) {
+		t.Errorf("Expected rootdata to carry the as-of time %s, got %s", params.AsOf, rd.asOf)
+	}
+}
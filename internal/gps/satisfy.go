@@ -4,6 +4,14 @@
 
 package gps
 
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
 // check performs constraint checks on the provided atom. The set of checks
 // differ slightly depending on whether the atom is pkgonly, or if it's the
 // entire project being added for the first time.
@@ -20,7 +28,11 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 
 	s.mtr.push("satisfy")
 	var err error
+	// step names the check being run, for the trace events.
+	var step string
+	start := time.Now()
 	defer func() {
+		s.traceCheck(a, pkgonly, step, time.Since(start), err)
 		if err != nil {
 			s.traceInfo(err)
 		}
@@ -30,16 +42,23 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	// If we're pkgonly, then base atom was already determined to be allowable,
 	// so we can skip the checkAtomAllowable step.
 	if !pkgonly {
+		step = "atom-allowable"
 		if err = s.checkAtomAllowable(pa); err != nil {
 			return err
 		}
+		step = "go-version"
+		if err = s.checkGoVersion(pa); err != nil {
+			return err
+		}
 	}
 
+	step = "required-packages-exist"
 	if err = s.checkRequiredPackagesExist(a); err != nil {
 		return err
 	}
 
 	var deps []completeDep
+	step = "imports-and-constraints"
 	_, deps, err = s.getImportsAndConstraintsOf(a)
 	if err != nil {
 		// An err here would be from the package fetcher; pass it straight back
@@ -51,21 +70,30 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	// now, but won't be good enough when we get around to doing static
 	// analysis.
 	for _, dep := range deps {
+		step = "ident-matches"
 		if err = s.checkIdentMatches(a, dep); err != nil {
 			return err
 		}
+		step = "deps-constraints-allowable"
 		if err = s.checkDepsConstraintsAllowable(a, dep); err != nil {
 			return err
 		}
+		step = "deps-disallows-selected"
 		if err = s.checkDepsDisallowsSelected(a, dep); err != nil {
 			return err
 		}
+		step = "revision-exists"
 		if err = s.checkRevisionExists(a, dep); err != nil {
 			return err
 		}
+		step = "package-imports-from-dep-exist"
 		if err = s.checkPackageImportsFromDepExist(a, dep); err != nil {
 			return err
 		}
+		step = "internal-imports-visible"
+		if err = s.checkInternalImportsVisible(a, dep); err != nil {
+			return err
+		}
 
 		// TODO(sdboyer) add check that fails if adding this atom would create a loop
 	}
@@ -100,6 +128,34 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	return err
 }
 
+// checkGoVersion ensures that the manifest of an atom requires no later release
+// of Go than the one the solution must build with.
+func (s *solver) checkGoVersion(pa atom) error {
+	if s.rd.goVersion.IsZero() {
+		return nil
+	}
+	m, _, err := s.b.GetManifestAndLock(pa.id, pa.v, s.rd.an)
+	if err != nil {
+		return err
+	}
+	req := requiredGoVersion(m)
+	if req == "" {
+		return nil
+	}
+	rv, err := ParseGoVersion(req)
+	if err != nil {
+		return err
+	}
+	if s.rd.goVersion.Less(rv) {
+		return &goVersionFailure{
+			goal:      pa,
+			required:  rv,
+			goVersion: s.rd.goVersion,
+		}
+	}
+	return nil
+}
+
 // checkRequiredPackagesExist ensures that all required packages enumerated by
 // existing dependencies on this atom are actually present in the atom.
 func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
@@ -281,3 +337,125 @@ func (s *solver) checkRevisionExists(a atomWithPackages, cdep completeDep) error
 		r: r,
 	}
 }
+
+// checkInternalImportsVisible ensures that none of the packages an atom
+// requires from a dep are internal packages that the go tool would refuse to
+// let the atom's packages import, once both projects are placed in vendor/.
+func (s *solver) checkInternalImportsVisible(a atomWithPackages, cdep completeDep) error {
+	var ptree pkgtree.PackageTree
+	var from []string
+	var tests bool
+	ig := s.rd.ignoreRules()
+	if s.rd.isRoot(a.a.id.ProjectRoot) {
+		// All of the root project's packages are built, including their tests.
+		ptree, tests = s.rd.rpt, true
+		from = make([]string, 0, len(ptree.Packages))
+		for path := range ptree.Packages {
+			if !ig.IsIgnored(path) {
+				from = append(from, path)
+			}
+		}
+		sort.Strings(from)
+	} else {
+		var err error
+		ptree, err = s.b.ListPackages(a.a.id, a.a.v)
+		if err != nil {
+			// TODO(sdboyer) handle this more gracefully
+			return err
+		}
+		from = a.pl
+	}
+
+	for _, pkg := range cdep.pl {
+		parent, is := internalParent(pkg)
+		if !is {
+			continue
+		}
+
+		if chain := invisibleInternalImport(ptree, from, pkg, parent, tests, ig); chain != nil {
+			return &internalImportFailure{
+				goal: dependency{
+					depender: a.a,
+					dep:      cdep,
+				},
+				parent: parent,
+				chain:  chain,
+			}
+		}
+	}
+	return nil
+}
+
+// internalParent returns the path of the tree to which the internal package at
+// path is visible, which is everything preceding its last "internal" element.
+// The last element matters, as it places the most restrictive requirement on
+// the importer.
+func internalParent(path string) (string, bool) {
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		return strings.TrimSuffix(path, "/internal"), true
+	case strings.Contains(path, "/internal/"):
+		return path[:strings.LastIndex(path, "/internal/")], true
+	case path == "internal", strings.HasPrefix(path, "internal/"):
+		return "", true
+	}
+	return "", false
+}
+
+// invisibleInternalImport walks the packages of ptree reachable from the
+// packages in from, looking for one that imports the internal package target
+// from outside of parent, the tree to which target is visible. If there is
+// one, the import chain from one of the packages in from down to target is
+// returned.
+func invisibleInternalImport(ptree pkgtree.PackageTree, from []string, target, parent string, tests bool, ig *pkgtree.IgnoreRules) []string {
+	visible := func(path string) bool {
+		return parent == "" || path == parent || strings.HasPrefix(path, parent+"/")
+	}
+
+	pred := make(map[string]string)
+	seen := make(map[string]bool)
+	queue := make([]string, 0, len(from))
+	for _, pkg := range from {
+		if !seen[pkg] {
+			seen[pkg] = true
+			queue = append(queue, pkg)
+		}
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		poe, has := ptree.Packages[pkg]
+		if !has || poe.Err != nil {
+			continue
+		}
+
+		imports := poe.P.Imports
+		if tests {
+			imports = append(imports[:len(imports):len(imports)], poe.P.TestImports...)
+		}
+
+		for _, imp := range imports {
+			if imp == target {
+				if visible(pkg) {
+					continue
+				}
+
+				chain := []string{target}
+				for p := pkg; p != ""; p = pred[p] {
+					chain = append([]string{p}, chain...)
+				}
+				return chain
+			}
+
+			if _, local := ptree.Packages[imp]; local && !seen[imp] && !ig.IsIgnored(imp) {
+				seen[imp] = true
+				pred[imp] = pkg
+				queue = append(queue, imp)
+			}
+		}
+	}
+
+	return nil
+}
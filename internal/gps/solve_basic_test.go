@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/golang/dep/internal/gps/pkgtree"
@@ -1472,6 +1473,22 @@ func (sm *depspecSourceManager) ExportProject(id ProjectIdentifier, v Version, t
 	return fmt.Errorf("dummy sm doesn't support exporting")
 }
 
+func (sm *depspecSourceManager) VersionInfo(id ProjectIdentifier, v Version) (VersionInfo, error) {
+	return VersionInfo{}, fmt.Errorf("dummy sm doesn't support version info")
+}
+
+func (sm *depspecSourceManager) PairRef(id ProjectIdentifier, ref string) (PairedVersion, error) {
+	return nil, fmt.Errorf("dummy sm doesn't support refs")
+}
+
+func (sm *depspecSourceManager) CommitLog(id ProjectIdentifier, from, to Version) ([]VersionInfo, error) {
+	return nil, fmt.Errorf("dummy sm doesn't support commit logs")
+}
+
+func (sm *depspecSourceManager) RevisionAsOf(id ProjectIdentifier, v Version, t time.Time) (Revision, error) {
+	return "", fmt.Errorf("dummy sm doesn't support past revisions")
+}
+
 func (sm *depspecSourceManager) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 	for _, ds := range sm.allSpecs() {
 		n := string(ds.n)
@@ -546,6 +546,77 @@ var bimodalFixtures = map[string]bimodalFixture{
 			},
 		},
 	},
+	// Internal packages of a dependency can't be imported by another
+	// dependency once both are in vendor/, so versions doing so are rejected
+	"internal import from another project": {
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "a"),
+			),
+			dsp(mkDepspec("a 2.0.0"),
+				pkg("a", "a/inner"),
+				pkg("a/inner", "b/internal/foo"),
+			),
+			dsp(mkDepspec("a 1.0.0"),
+				pkg("a", "b"),
+			),
+			dsp(mkDepspec("b 1.0.0"),
+				pkg("b", "b/internal/foo"),
+				pkg("b/internal/foo"),
+			),
+		},
+		r: mksolution(
+			"a 1.0.0",
+			mklp("b 1.0.0", ".", "internal/foo"),
+		),
+	},
+	"internal import from another project, no alternative": {
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "a"),
+			),
+			dsp(mkDepspec("a 1.0.0"),
+				pkg("a", "a/inner"),
+				pkg("a/inner", "b/internal/foo"),
+			),
+			dsp(mkDepspec("b 1.0.0"),
+				pkg("b"),
+				pkg("b/internal/foo"),
+			),
+		},
+		fail: &noVersionError{
+			pn: mkPI("a"),
+			fails: []failedVersion{
+				{
+					v: NewVersion("1.0.0"),
+					f: &internalImportFailure{
+						goal:   mkADep("a 1.0.0", "b", Any(), "b/internal/foo"),
+						parent: "b",
+						chain:  []string{"a", "a/inner", "b/internal/foo"},
+					},
+				},
+			},
+		},
+	},
+	// The root project is subject to the same rule, but there's nothing to
+	// backtrack to
+	"internal import from root": {
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "root/foo"),
+				pkg("root/foo", "b/internal/foo"),
+			),
+			dsp(mkDepspec("b 1.0.0"),
+				pkg("b"),
+				pkg("b/internal/foo"),
+			),
+		},
+		fail: &internalImportFailure{
+			goal:   mkADep("root", "b", Any(), "b/internal/foo"),
+			parent: "b",
+			chain:  []string{"root/foo", "b/internal/foo"},
+		},
+	},
 	// Check ignores on the root project
 	"ignore in double-subpkg": {
 		ds: []depspec{
@@ -0,0 +1,229 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A SolveFailure explains why a solve failed: the project for which no
+// version could be selected, and for each of the versions tried, the
+// constraints from other projects which excluded it.
+type SolveFailure struct {
+	Project  ProjectRoot
+	Versions []VersionFailure
+}
+
+// A VersionFailure explains why a version of a project could not be selected.
+type VersionFailure struct {
+	Version string
+	// Type is the type of Version: semver, version, branch or revision.
+	Type string
+	// Reason is the kind of the failure, one of:
+	//
+	//  not-allowed             the version is excluded by Constraints
+	//  disjoint-constraint     Goal has no overlap with Constraints
+	//  constraint-not-allowed  Goal doesn't allow the version already selected
+	//  source-mismatch         the projects disagree on the source of a project
+	//  problem-packages        packages required from a project are missing
+	//  missing-revision        a project is required at a missing revision
+	//  internal-import         a package internal to a project is imported
+	//  go-version              the version requires a later release of Go
+	//  other                   any other failure
+	Reason string
+	// Summary is a one-line description of the failure.
+	Summary string
+	// Goal is the constraint the version itself brought in, for the failures
+	// about the constraints of the version.
+	Goal *ExcludingConstraint `json:",omitempty"`
+	// Constraints holds the constraints which excluded the version.
+	Constraints []ExcludingConstraint `json:",omitempty"`
+}
+
+// An ExcludingConstraint is a constraint from a project on another, which took
+// part in excluding a version.
+type ExcludingConstraint struct {
+	// From is the depender, as "(root)" or "<project>@<version>".
+	From       string
+	On         ProjectRoot
+	Constraint string
+	// Type is the type of Constraint: semver, version, branch, revision or
+	// any. It's empty for source-mismatch failures, where Constraint is the
+	// source the depender agreed upon.
+	Type string
+	// Overlaps is set for the constraints which overlap with the goal of the
+	// failure, but not with the intersection of them all.
+	Overlaps bool `json:",omitempty"`
+}
+
+// NewSolveFailure returns the explanation of the given error returned by a
+// solve, or nil if err is not a failure to select a version of a project.
+func NewSolveFailure(err error) *SolveFailure {
+	nve, ok := errors.Cause(err).(*noVersionError)
+	if !ok {
+		return nil
+	}
+
+	sf := &SolveFailure{Project: nve.pn.ProjectRoot}
+	for _, f := range nve.fails {
+		sf.Versions = append(sf.Versions, newVersionFailure(f))
+	}
+	return sf
+}
+
+func excludingConstraint(d dependency) ExcludingConstraint {
+	return ExcludingConstraint{
+		From:       a2vs(d.depender),
+		On:         d.dep.Ident.ProjectRoot,
+		Constraint: d.dep.Constraint.String(),
+		Type:       constraintType(d.dep.Constraint),
+	}
+}
+
+// constraintType names the type of c, as recorded in a SolveFailure.
+func constraintType(c Constraint) string {
+	switch tc := c.(type) {
+	case semverConstraint:
+		return "semver"
+	case anyConstraint:
+		return "any"
+	case Version:
+		switch tc.Type() {
+		case IsRevision:
+			return "revision"
+		case IsBranch:
+			return "branch"
+		case IsVersion:
+			return "version"
+		case IsSemver:
+			return "semver"
+		}
+	}
+	return ""
+}
+
+func newVersionFailure(f failedVersion) VersionFailure {
+	vf := VersionFailure{Version: f.v.String(), Type: constraintType(f.v)}
+
+	switch e := f.f.(type) {
+	case *versionNotAllowedFailure:
+		vf.Reason = "not-allowed"
+		vf.Summary = fmt.Sprintf("not allowed by %s", e.c)
+		for _, d := range e.failparent {
+			vf.Constraints = append(vf.Constraints, excludingConstraint(d))
+		}
+	case *disjointConstraintFailure:
+		goal := excludingConstraint(e.goal)
+		vf.Reason = "disjoint-constraint"
+		vf.Summary = fmt.Sprintf("%s depends on %s with %s, which has no overlap with", goal.From, goal.On, goal.Constraint)
+		vf.Goal = &goal
+		for _, d := range e.failsib {
+			vf.Constraints = append(vf.Constraints, excludingConstraint(d))
+		}
+		// The siblings which overlap with the goal only matter when none is
+		// disjoint with it on its own.
+		if len(e.failsib) == 0 {
+			for _, d := range e.nofailsib {
+				ec := excludingConstraint(d)
+				ec.Overlaps = true
+				vf.Constraints = append(vf.Constraints, ec)
+			}
+		}
+	case *constraintNotAllowedFailure:
+		goal := excludingConstraint(e.goal)
+		vf.Reason = "constraint-not-allowed"
+		vf.Summary = fmt.Sprintf("%s depends on %s with %s, which does not allow the selected version %s", goal.From, goal.On, goal.Constraint, e.v)
+		vf.Goal = &goal
+	case *goVersionFailure:
+		vf.Reason = "go-version"
+		vf.Summary = fmt.Sprintf("requires Go %s, later than %s", e.required, e.goVersion)
+	case *sourceMismatchFailure:
+		vf.Reason = "source-mismatch"
+		vf.Summary = fmt.Sprintf("%s wants %s from %s, but it comes from %s", a2vs(e.prob), e.shared, e.mismatch, e.current)
+		for _, d := range e.sel {
+			vf.Constraints = append(vf.Constraints, ExcludingConstraint{
+				From:       a2vs(d.depender),
+				On:         e.shared,
+				Constraint: e.current,
+			})
+		}
+	case *checkeeHasProblemPackagesFailure:
+		vf.Reason = "problem-packages"
+		vf.Summary = fmt.Sprintf("%s lacks packages required from it: %s", a2vs(e.goal), strings.Join(sortedKeys(e.failpkg), ", "))
+	case *depHasProblemPackagesFailure:
+		pkgs := make([]string, 0, len(e.prob))
+		for pkg := range e.prob {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+		goal := excludingConstraint(e.goal)
+		vf.Reason = "problem-packages"
+		vf.Summary = fmt.Sprintf("%s requires packages %s lacks at %s: %s", goal.From, goal.On, e.v, strings.Join(pkgs, ", "))
+		vf.Goal = &goal
+	case *nonexistentRevisionFailure:
+		goal := excludingConstraint(e.goal)
+		vf.Reason = "missing-revision"
+		vf.Summary = fmt.Sprintf("%s requires %s at revision %s, which does not exist", goal.From, goal.On, e.r)
+		vf.Goal = &goal
+	case *internalImportFailure:
+		goal := excludingConstraint(e.goal)
+		vf.Reason = "internal-import"
+		vf.Summary = fmt.Sprintf("%s imports %s, which is internal to %s", goal.From, e.chain[len(e.chain)-1], e.parent)
+		vf.Goal = &goal
+	default:
+		vf.Reason = "other"
+		vf.Summary = strings.Replace(strings.TrimSpace(f.f.Error()), "\n", " ", -1)
+	}
+	return vf
+}
+
+func sortedKeys(m map[string]errDeppers) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteTree writes the failure to w as an indented tree, with a line for each
+// version tried and, under it, one for each constraint which excluded it.
+func (sf *SolveFailure) WriteTree(w io.Writer) error {
+	var buf bytes.Buffer
+	if len(sf.Versions) == 0 {
+		fmt.Fprintf(&buf, "%s: no versions found\n", sf.Project)
+	} else {
+		fmt.Fprintf(&buf, "%s: no version met the constraints\n", sf.Project)
+	}
+	for _, vf := range sf.Versions {
+		fmt.Fprintf(&buf, "  %s: %s\n", vf.Version, vf.Summary)
+		for _, c := range vf.Constraints {
+			fmt.Fprintf(&buf, "    %s from %s", c.Constraint, c.From)
+			if c.Overlaps {
+				buf.WriteString(" (some overlap)")
+			}
+			buf.WriteString("\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteJSON writes the failure to w as JSON.
+func (sf *SolveFailure) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the solve failure")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewSolveFailure(t *testing.T) {
+	if sf := NewSolveFailure(errors.New("not a solve failure")); sf != nil {
+		t.Errorf("expected no explanation of an unrelated error, got %+v", sf)
+	}
+
+	sf := NewSolveFailure(errors.Wrap(basicFixtures["no version that matches combined constraint"].fail, "solve"))
+	if sf == nil {
+		t.Fatal("expected an explanation of the failure")
+	}
+	want := &SolveFailure{
+		Project: "shared",
+		Versions: []VersionFailure{
+			{
+				Version:     "3.5.0",
+				Type:        "semver",
+				Reason:      "not-allowed",
+				Summary:     "not allowed by ^2.9.0",
+				Constraints: []ExcludingConstraint{{From: "foo@1.0.0", On: "shared", Constraint: "^2.0.0", Type: "semver"}},
+			},
+			{
+				Version:     "2.5.0",
+				Type:        "semver",
+				Reason:      "not-allowed",
+				Summary:     "not allowed by ^2.9.0",
+				Constraints: []ExcludingConstraint{{From: "bar@1.0.0", On: "shared", Constraint: ">=2.9.0, <4.0.0", Type: "semver"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(sf, want) {
+		t.Fatalf("unexpected explanation:\n\t(GOT): %+v\n\t(WNT): %+v", sf, want)
+	}
+
+	var buf bytes.Buffer
+	if err := sf.WriteTree(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantTree := `shared: no version met the constraints
+  3.5.0: not allowed by ^2.9.0
+    ^2.0.0 from foo@1.0.0
+  2.5.0: not allowed by ^2.9.0
+    >=2.9.0, <4.0.0 from bar@1.0.0
+`
+	if buf.String() != wantTree {
+		t.Errorf("unexpected tree:\n\t(GOT):\n%s\n\t(WNT):\n%s", buf.String(), wantTree)
+	}
+
+	buf.Reset()
+	if err := sf.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var raw SolveFailure
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&raw, want) {
+		t.Errorf("unexpected JSON explanation: %s", buf.String())
+	}
+
+	sf = NewSolveFailure(basicFixtures["disjoint constraints"].fail)
+	goal := ExcludingConstraint{From: "foo@1.0.0", On: "shared", Constraint: "<=2.0.0", Type: "semver"}
+	if len(sf.Versions) != 1 || sf.Versions[0].Reason != "disjoint-constraint" || !reflect.DeepEqual(sf.Versions[0].Goal, &goal) {
+		t.Fatalf("unexpected explanation of disjoint constraints: %+v", sf.Versions)
+	}
+	if c := sf.Versions[0].Constraints; len(c) != 1 || c[0].From != "bar@1.0.0" || c[0].Constraint != ">3.0.0" || c[0].Overlaps {
+		t.Errorf("unexpected disjoint constraints: %+v", c)
+	}
+}
@@ -224,6 +224,28 @@ func (e *versionNotAllowedFailure) traceString() string {
 	return buf.String()
 }
 
+// goVersionFailure describes a failure where an atom is rejected because its
+// manifest requires a later release of Go than the solution must build with.
+type goVersionFailure struct {
+	goal atom
+	// required is the release of Go the manifest of the atom requires, and
+	// goVersion the one the solution must build with.
+	required, goVersion GoVersion
+}
+
+func (e *goVersionFailure) Error() string {
+	return fmt.Sprintf(
+		"Could not introduce %s, as it requires Go %s, but the solution must build with Go %s",
+		a2vs(e.goal),
+		e.required,
+		e.goVersion,
+	)
+}
+
+func (e *goVersionFailure) traceString() string {
+	return fmt.Sprintf("%s requires Go %s, later than %s", a2vs(e.goal), e.required, e.goVersion)
+}
+
 type missingSourceFailure struct {
 	goal ProjectIdentifier
 	prob string
@@ -494,3 +516,38 @@ func (e *nonexistentRevisionFailure) traceString() string {
 		e.goal.dep.Ident.errString(),
 	)
 }
+
+// internalImportFailure indicates that an atom imports an internal package
+// from one of its dependencies. The go tool only allows internal packages to
+// be imported from within the tree rooted at their parent directory, which no
+// other project is part of once they've all been placed in vendor/.
+type internalImportFailure struct {
+	// goal is the dependency through which the internal package is required.
+	goal dependency
+	// parent is the path of the tree to which the internal package is visible.
+	parent string
+	// chain is the import chain from a package of the depender to the
+	// internal package.
+	chain []string
+}
+
+func (e *internalImportFailure) Error() string {
+	return fmt.Sprintf(
+		"Could not introduce %s, as it imports package %s from %s, which is internal to %s:\n\t%s",
+		a2vs(e.goal.depender),
+		e.chain[len(e.chain)-1],
+		e.goal.dep.Ident.errString(),
+		e.parent,
+		strings.Join(e.chain, " -> "),
+	)
+}
+
+func (e *internalImportFailure) traceString() string {
+	return fmt.Sprintf(
+		"%s imports internal package %s of %s via %s",
+		a2vs(e.goal.depender),
+		e.chain[len(e.chain)-1],
+		e.goal.dep.Ident.errString(),
+		strings.Join(e.chain, " -> "),
+	)
+}
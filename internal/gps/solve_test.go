@@ -100,6 +100,24 @@ func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err erro
 	return fixtureSolveSimpleChecks(fix, res, err, t)
 }
 
+// PreferMinimal solves to the lowest versions, whatever the lock says.
+func TestSolvePreferMinimal(t *testing.T) {
+	fix := basicFixtures["downgrade through lock"]
+	sm := newdepspecSM(fix.ds, nil)
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		Lock:            fix.l,
+		Strategy:        PreferMinimal,
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+
+	res, err := fixSolve(params, sm, t)
+	fixtureSolveSimpleChecks(fix, res, err, t)
+}
+
 // Test all the bimodal table fixtures.
 //
 // Or, just the one named in the fix arg.
@@ -269,3 +287,43 @@ func TestRootLockNoVersionPairMatching(t *testing.T) {
 
 	fixtureSolveSimpleChecks(fix, res, err, t)
 }
+
+func TestSolverCheckpoint(t *testing.T) {
+	fix := basicFixture{
+		n: "checkpoint of a failed solve",
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a 1.0.0", "b 1.0.0"),
+			mkDepspec("a 1.0.0"),
+			mkDepspec("b 1.0.0", "c 2.0.0"),
+			mkDepspec("c 1.0.0"),
+		},
+	}
+
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		Lock:            dummyLock{},
+		ProjectAnalyzer: naiveAnalyzer{},
+		TraceLogger:     log.New(testlogger{T: t}, "", 0),
+		stdLibFn:        func(string) bool { return false },
+		mkBridgeFn:      overrideMkBridge,
+	}
+
+	s, err := Prepare(params, newdepspecSM(fix.ds, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp := s.Checkpoint(); cp != nil {
+		t.Errorf("expected no checkpoint before solving, got %v", cp)
+	}
+
+	if _, err := s.Solve(); err == nil {
+		t.Fatal("expected the solve to fail")
+	}
+
+	want := []LockedProject{mklp("a 1.0.0", "."), mklp("b 1.0.0", ".")}
+	if cp := s.Checkpoint(); !reflect.DeepEqual(cp, want) {
+		t.Errorf("unexpected checkpoint:\n\t(GOT): %v\n\t(WNT): %v", cp, want)
+	}
+}
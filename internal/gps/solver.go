@@ -6,11 +6,15 @@ package gps
 
 import (
 	"container/heap"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/golang/dep/internal/gps/paths"
@@ -37,6 +41,11 @@ type SolveParameters struct {
 	// A real path to a readable directory is required.
 	RootDir string
 
+	// The path to the directory holding the dependencies of the root project,
+	// if not the vendor/ directory of RootDir. A relative path is relative to
+	// RootDir.
+	VendorDir string
+
 	// The ProjectAnalyzer is responsible for extracting Manifest and
 	// (optionally) Lock information from dependencies. The solver passes it
 	// along to its SourceManager's GetManifestAndLock() method as needed.
@@ -89,11 +98,70 @@ type SolveParameters struct {
 	// typical case.
 	Downgrade bool
 
+	// Strategy tells which of the versions allowed by the constraints the
+	// solver tries first. PreferMinimal implies Downgrade, and ChangeAll so
+	// that the versions in the lock don't keep the solver from the minimal
+	// ones.
+	Strategy Strategy
+
+	// AsOf, if non-zero, restricts the solver to versions that were released
+	// no later than the given time, as reported by SourceManager.VersionInfo.
+	// Branches which moved since are considered at the revision they were at
+	// then, as reported by SourceManager.RevisionAsOf. Versions whose release
+	// date can't be determined, and branches whose past revision can't be, are
+	// not considered.
+	//
+	// As the versions in the lock may well postdate it, setting AsOf implies
+	// ChangeAll.
+	AsOf time.Time
+
+	// ReleasedBefore, if non-zero, keeps the solver from picking versions
+	// released after the given time, as reported by SourceManager.VersionInfo,
+	// which lets new releases cool down before they're adopted. Unlike with
+	// AsOf, the locked version of each project is still allowed whatever its
+	// release date. Other versions whose release date can't be determined are
+	// not considered.
+	ReleasedBefore time.Time
+
+	// UpdateLevel, if not UpdateAny, limits the versions the projects locked
+	// to a semantic version may change to: those of the locked major version
+	// or, with UpdatePatch, minor version, no lower than the locked one.
+	// Other versions are not considered, whatever the constraints allow.
+	UpdateLevel UpdateLevel
+
+	// Prereleases tells whether the pre-releases of the semver versions are
+	// candidates, by default and for some projects in particular. By default,
+	// only the semver constraints decide.
+	Prereleases PrereleasePolicies
+
+	// UpstreamVersions holds the projects fetched from a fork, as the source
+	// of their constraint or override tells, whose versions are listed from
+	// their upstream instead: the source their root deduces to. The code of
+	// the versions picked is still fetched from the fork, which must hold
+	// their revisions.
+	UpstreamVersions map[ProjectRoot]bool
+
+	// GoVersion, if set, is the release of Go the solution must build with,
+	// as ParseGoVersion parses it. The versions of projects whose manifest is
+	// a GoVersionManifest requiring a later release are not considered.
+	GoVersion string
+
+	// PatchDigests holds the digests of the patches applied to projects once
+	// written out, keyed by project root. They don't change the solve, but
+	// take part in the inputs digest, so that a change to a patch shows.
+	PatchDigests map[ProjectRoot][]byte
+
 	// TraceLogger is the logger to use for generating trace output. If set, the
 	// solver will generate informative trace output as it moves through the
 	// solving process.
 	TraceLogger *log.Logger
 
+	// TraceEvents, if set, receives the trace of the solve as a stream of
+	// TraceEvents, one JSON object per line, with their timing. Unlike the
+	// output of the TraceLogger, it is meant for tools, as to profile solves
+	// taking long.
+	TraceEvents io.Writer
+
 	// stdLibFn is the function to use to recognize standard library import paths.
 	// Only overridden for tests. Defaults to paths.IsStandardImportPath if nil.
 	stdLibFn func(string) bool
@@ -115,6 +183,11 @@ type solver struct {
 	// Logger used exclusively for trace output, or nil to suppress.
 	tl *log.Logger
 
+	// Encoder of the trace events, or nil to suppress, and the start of the
+	// solve they are timed from.
+	te    *json.Encoder
+	start time.Time
+
 	// The function to use to recognize standard library import paths.
 	stdLibFn func(string) bool
 
@@ -160,6 +233,11 @@ type solver struct {
 
 	// metrics for the current solve run.
 	mtr *metrics
+
+	// The projects selected when the selection was at its deepest, and that
+	// depth. Reported by Checkpoint() when the solve fails.
+	partial      []LockedProject
+	partialDepth int
 }
 
 func (params SolveParameters) toRootdata() (rootdata, error) {
@@ -190,9 +268,30 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		rpt:     params.RootPackageTree.Copy(),
 		chng:    make(map[ProjectRoot]struct{}),
 		rlm:     make(map[ProjectRoot]LockedProject),
-		chngall: params.ChangeAll,
+		chngall: params.ChangeAll || !params.AsOf.IsZero() || params.Strategy == PreferMinimal,
 		dir:     params.RootDir,
 		an:      params.ProjectAnalyzer,
+		asOf:    params.AsOf,
+
+		vendorDir:      filepath.Join(params.RootDir, "vendor"),
+		releasedBefore: params.ReleasedBefore,
+		updateLevel:    params.UpdateLevel,
+		prereleases:    params.Prereleases,
+
+		upstreamVersions: params.UpstreamVersions,
+		patchDigests:     params.PatchDigests,
+	}
+	if params.GoVersion != "" {
+		var err error
+		if rd.goVersion, err = ParseGoVersion(params.GoVersion); err != nil {
+			return rootdata{}, badOptsFailure(err.Error())
+		}
+	}
+	if params.VendorDir != "" {
+		rd.vendorDir = params.VendorDir
+		if !filepath.IsAbs(rd.vendorDir) {
+			rd.vendorDir = filepath.Join(params.RootDir, rd.vendorDir)
+		}
 	}
 
 	// Ensure the required, ignore and overrides maps are at least initialized
@@ -207,9 +306,10 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 	}
 
 	if len(rd.ig) != 0 {
+		ig := rd.ignoreRules()
 		var both []string
 		for pkg := range params.Manifest.RequiredPackages() {
-			if rd.ig[pkg] {
+			if ig.IsIgnored(pkg) {
 				both = append(both, pkg)
 			}
 		}
@@ -289,20 +389,25 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 		stdLibFn: params.stdLibFn,
 		rd:       rd,
 	}
+	if params.TraceEvents != nil {
+		s.te = json.NewEncoder(params.TraceEvents)
+	}
 
 	// Set up the bridge and ensure the root dir is in good, working order
 	// before doing anything else.
+	down := params.Downgrade || params.Strategy == PreferMinimal
 	if params.mkBridgeFn == nil {
-		s.b = mkBridge(s, sm, params.Downgrade)
+		s.b = mkBridge(s, sm, down)
 	} else {
-		s.b = params.mkBridgeFn(s, sm, params.Downgrade)
+		s.b = params.mkBridgeFn(s, sm, down)
 	}
 	err = s.b.verifyRootDir(params.RootDir)
 	if err != nil {
 		return nil, err
 	}
 	s.vUnify = &versionUnifier{
-		b: s.b,
+		b:           s.b,
+		prereleases: s.rd.prereleases,
 	}
 
 	// Initialize stacks and queues
@@ -372,6 +477,15 @@ type Solver interface {
 	// Chronology is the only implication of the ordering - that lower version
 	// numbers were published before higher numbers.
 	Version() int
+
+	// Checkpoint returns the projects that were selected when a failed Solve()
+	// had gotten the furthest, sorted by project root. Passing them in the Lock
+	// of the next solve's parameters makes the solver try those versions
+	// first, which lets a solve cut short by an interruption or a network
+	// failure pick up about where it stopped.
+	//
+	// It returns nil if Solve() has not been called, or succeeded.
+	Checkpoint() []LockedProject
 }
 
 func (s *solver) Name() string {
@@ -432,6 +546,7 @@ func ValidateParams(params SolveParameters, sm SourceManager) error {
 func (s *solver) Solve() (Solution, error) {
 	// Set up a metrics object
 	s.mtr = newMetrics()
+	s.start = time.Now()
 	s.vUnify.mtr = s.mtr
 
 	// Prime the queues with the root project
@@ -445,8 +560,9 @@ func (s *solver) Solve() (Solution, error) {
 	var soln solution
 	if err == nil {
 		soln = solution{
-			att:  s.attempts,
-			solv: s,
+			att:       s.attempts,
+			solv:      s,
+			goVersion: s.rd.goVersion,
 		}
 		soln.analyzerInfo = s.rd.an.Info()
 		soln.hd = s.HashInputs()
@@ -460,6 +576,10 @@ func (s *solver) Solve() (Solution, error) {
 		}
 	}
 
+	if err == nil {
+		s.partial = nil
+	}
+
 	s.traceFinish(soln, err)
 	if s.tl != nil {
 		s.mtr.dump(s.tl)
@@ -558,6 +678,11 @@ func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 
 	// Getting this far means we successfully found a solution. Combine the
 	// selected projects and packages.
+	return s.selectedProjects(), nil
+}
+
+// selectedProjects combines the currently selected projects and packages.
+func (s *solver) selectedProjects() map[atom]map[string]struct{} {
 	projs := make(map[atom]map[string]struct{})
 
 	// Skip the first project. It's always the root, and that shouldn't be
@@ -573,7 +698,26 @@ func (s *solver) solve() (map[atom]map[string]struct{}, error) {
 			pm[path] = struct{}{}
 		}
 	}
-	return projs, nil
+	return projs
+}
+
+func (s *solver) Checkpoint() []LockedProject {
+	return s.partial
+}
+
+// recordPartial keeps a copy of the current selection if it is the deepest
+// one so far.
+func (s *solver) recordPartial() {
+	if len(s.sel.projects) <= s.partialDepth {
+		return
+	}
+	s.partialDepth = len(s.sel.projects)
+
+	s.partial = s.partial[:0]
+	for pa, pl := range s.selectedProjects() {
+		s.partial = append(s.partial, pa2lp(pa, pl))
+	}
+	sort.Sort(lpsorter(s.partial))
 }
 
 // selectRoot is a specialized selectAtom, used solely to initially
@@ -592,6 +736,15 @@ func (s *solver) selectRoot() error {
 		panic(fmt.Sprintf("shouldn't be possible %s", err))
 	}
 
+	for _, dep := range deps {
+		// The root project's imports are fixed, so there's no backtracking
+		// out of an internal import violation; fail the solve outright.
+		if err := s.checkInternalImportsVisible(awp, dep); err != nil {
+			s.mtr.pop()
+			return err
+		}
+	}
+
 	for _, dep := range deps {
 		// If we have no lock, or if this dep isn't in the lock, then prefetch
 		// it. See longer explanation in selectAtom() for how we benefit from
@@ -655,9 +808,10 @@ func (s *solver) getImportsAndConstraintsOf(a atomWithPackages) ([]string, []com
 
 	// Add to the list those packages that are reached by the packages
 	// explicitly listed in the atom
+	ig := s.rd.ignoreRules()
 	for _, pkg := range a.pl {
 		// Skip ignored packages
-		if s.rd.ig[pkg] {
+		if ig.IsIgnored(pkg) {
 			continue
 		}
 
@@ -865,6 +1019,17 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 		q.pi = append([]Version{tc}, q.pi...)
 	}
 
+	// Likewise, refs other than branches and tags, as refs/pull/123/head, are
+	// left out of ListVersions(), so fetch the one the constraint names and
+	// put it in at the front, unless it's there already, from the lock.
+	if tc, ok := s.sel.getConstraint(bmi.id).(branchVersion); ok && IsRefName(tc.name) && !tc.Matches(q.pi[0]) {
+		pv, err := s.b.pairRef(bmi.id, tc.name)
+		if err != nil {
+			return nil, err
+		}
+		q.pi = append([]Version{pv}, q.pi...)
+	}
+
 	// Having assembled the queue, search it for a valid version.
 	s.traceCheckQueue(q, bmi, false, 1)
 	return q, s.findValidVersion(q, bmi.pl)
@@ -887,7 +1052,7 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 
 	for {
 		cur := q.current()
-		s.traceInfo("try %s@%s", q.id.errString(), cur)
+		s.traceTry(q.id, cur)
 		err := s.check(atomWithPackages{
 			a: atom{
 				id: q.id,
@@ -1194,6 +1359,7 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) {
 	// selection stack
 	a.pl = pl
 	s.sel.pushSelection(a, pkgonly)
+	s.recordPartial()
 
 	// If this atom has a lock, pull it out so that we can potentially inject
 	// preferred versions into any bmis we enqueue
@@ -8,9 +8,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/golang/dep/internal/fs"
 	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/sdboyer/constext"
 )
 
 // sourceState represent the states that a source can be in, depending on how
@@ -27,6 +33,10 @@ const (
 	sourceHasLatestLocally
 )
 
+// localStates are the states involving the local copy of a source, which
+// must be locked to reach them or work from them.
+const localStates = sourceExistsLocally | sourceHasLatestVersionList | sourceHasLatestLocally
+
 type srcReturnChans struct {
 	ret chan *sourceGateway
 	err chan error
@@ -49,6 +59,14 @@ type sourceCoordinator struct {
 	protoSrcs  map[string][]srcReturnChans
 	deducer    deducer
 	cachedir   string
+	sourcesdir string // where the local copies of sources are kept
+	locker     *sourceLocker
+	remote     *cacheServerClient // cache server to fetch sources from, if any
+	disk       *boltCache         // persistent cache of the metadata of sources, if any
+	proxies    []*url.URL         // module proxies to fetch the sources of import paths from, if any
+	direct     bool               // whether to fall back to the upstream sources when proxies are used
+	shallow    bool               // whether to make shallow clones of the git sources
+	protocols  *protocolPolicies  // restricts the protocols of the deduced sources, if set
 }
 
 func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string) *sourceCoordinator {
@@ -56,6 +74,8 @@ func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string)
 		supervisor: superv,
 		deducer:    deducer,
 		cachedir:   cachedir,
+		sourcesdir: cachedir,
+		locker:     newSourceLocker(sourceLocksDir(cachedir)),
 		srcs:       make(map[string]*sourceGateway),
 		nameToURL:  make(map[string]string),
 		protoSrcs:  make(map[string][]srcReturnChans),
@@ -141,7 +161,22 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 	}
 	sc.srcmut.RUnlock()
 
-	srcGate = newSourceGateway(pd.mb, sc.supervisor, sc.cachedir)
+	// Only import paths are fetched from the module proxies; sources given as
+	// URLs are always reached directly.
+	mb := sc.protocols.restrict(pd.mb)
+	if len(sc.proxies) > 0 && pd.root == normalizedName {
+		mbs := make(maybeSources, 0, len(sc.proxies)+1)
+		for _, u := range sc.proxies {
+			mbs = append(mbs, maybeModuleProxySource{base: u, module: pd.root})
+		}
+		if sc.direct {
+			mbs = append(mbs, mb)
+		}
+		mb = mbs
+	}
+
+	srcGate = newSourceGateway(mb, sc.supervisor, sc.cachedir, sc.sourcesdir, sc.locker, sc.remote, sc.disk)
+	srcGate.shallow = sc.shallow
 
 	// The normalized name is usually different from the source URL- e.g.
 	// github.com/golang/dep/internal/gps vs. https://github.com/golang/dep/internal/gps. But it's
@@ -180,22 +215,35 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 // sourceGateways manage all incoming calls for data from sources, serializing
 // and caching them as needed.
 type sourceGateway struct {
-	cachedir string
-	maybe    maybeSource
-	srcState sourceState
-	src      source
-	cache    singleSourceCache
-	mu       sync.Mutex // global lock, serializes all behaviors
-	suprvsr  *supervisor
+	cachedir   string
+	sourcesdir string
+	maybe      maybeSource
+	srcState   sourceState
+	src        source
+	cache      singleSourceCache
+	mu         sync.Mutex // global lock, serializes all behaviors
+	suprvsr    *supervisor
+	locker     *sourceLocker
+	unlock     func() // releases the lock on the local copy, if held
+	remote     *cacheServerClient
+	refs       map[string]Revision // the refs fetched so far; see fetchRef
+	// cachedVersions is set when the persistent cache had a fresh version
+	// list of the source, which then needn't be listed upstream.
+	cachedVersions bool
+	// shallow is set when the source is to be cloned shallowly, if it can be.
+	shallow bool
 }
 
-func newSourceGateway(maybe maybeSource, superv *supervisor, cachedir string) *sourceGateway {
+func newSourceGateway(maybe maybeSource, superv *supervisor, cachedir, sourcesdir string, locker *sourceLocker, remote *cacheServerClient, disk *boltCache) *sourceGateway {
 	sg := &sourceGateway{
-		maybe:    maybe,
-		cachedir: cachedir,
-		suprvsr:  superv,
+		maybe:      maybe,
+		cachedir:   cachedir,
+		sourcesdir: sourcesdir,
+		suprvsr:    superv,
+		locker:     locker,
+		remote:     remote,
 	}
-	sg.cache = sg.createSingleSourceCache()
+	sg.cache = sg.createSingleSourceCache(disk)
 
 	return sg
 }
@@ -203,6 +251,7 @@ func newSourceGateway(maybe maybeSource, superv *supervisor, cachedir string) *s
 func (sg *sourceGateway) syncLocal(ctx context.Context) error {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally|sourceHasLatestLocally)
 	return err
@@ -211,6 +260,7 @@ func (sg *sourceGateway) syncLocal(ctx context.Context) error {
 func (sg *sourceGateway) existsInCache(ctx context.Context) bool {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
 	if err != nil {
@@ -223,6 +273,7 @@ func (sg *sourceGateway) existsInCache(ctx context.Context) bool {
 func (sg *sourceGateway) existsUpstream(ctx context.Context) bool {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsUpstream)
 	if err != nil {
@@ -235,6 +286,13 @@ func (sg *sourceGateway) existsUpstream(ctx context.Context) bool {
 func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to string) error {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	if ok, err := sg.withCacheServerTree(ctx, v, func(dir string) error {
+		return fs.CopyDir(dir, to)
+	}); ok {
+		return err
+	}
 
 	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
 	if err != nil {
@@ -270,6 +328,7 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	r, err := sg.convertToRevision(ctx, v)
 	if err != nil {
@@ -281,6 +340,21 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 		return m, l, nil
 	}
 
+	if ok, err := sg.withCacheServerTree(ctx, r, func(dir string) (err error) {
+		m, l, err = an.DeriveManifestAndLock(dir, pr)
+		return err
+	}); ok {
+		if err != nil {
+			return nil, nil, err
+		}
+		if l != nil && l != Lock(nil) {
+			l = prepLock(l)
+		}
+		m = prepManifest(m)
+		sg.cache.setManifestAndLock(r, an.Info(), m, l)
+		return m, l, nil
+	}
+
 	_, err = sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
 	if err != nil {
 		return nil, nil, err
@@ -325,6 +399,7 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Version) (pkgtree.PackageTree, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	r, err := sg.convertToRevision(ctx, v)
 	if err != nil {
@@ -336,6 +411,17 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 		return ptree, nil
 	}
 
+	if ok, err := sg.withCacheServerTree(ctx, r, func(dir string) (err error) {
+		ptree, err = pkgtree.ListPackages(dir, string(pr))
+		return err
+	}); ok {
+		if err != nil {
+			return pkgtree.PackageTree{}, err
+		}
+		sg.cache.setPackageTree(r, ptree)
+		return ptree, nil
+	}
+
 	_, err = sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
 	if err != nil {
 		return pkgtree.PackageTree{}, err
@@ -376,6 +462,27 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 }
 
 func (sg *sourceGateway) convertToRevision(ctx context.Context, v Version) (Revision, error) {
+	// Refs other than branches and tags aren't part of the version list, nor
+	// fetched along with the rest of the local copy; see IsRefName.
+	var uv UnpairedVersion
+	switch tv := v.(type) {
+	case UnpairedVersion:
+		uv = tv
+	case PairedVersion:
+		uv = tv.Unpair()
+	}
+	if uv != nil && uv.Type() == IsBranch && IsRefName(uv.String()) {
+		r, err := sg.fetchRef(ctx, uv.String())
+		if err != nil {
+			return "", err
+		}
+		// Respect the revision v is paired with, as for other versions.
+		if pv, ok := v.(PairedVersion); ok {
+			return pv.Revision(), nil
+		}
+		return r, nil
+	}
+
 	// When looking up by Version, there are four states that may have
 	// differing opinions about version->revision mappings:
 	//
@@ -418,6 +525,11 @@ func (sg *sourceGateway) convertToRevision(ctx context.Context, v Version) (Revi
 func (sg *sourceGateway) listVersions(ctx context.Context) ([]PairedVersion, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	if sg.cachedVersions && sg.srcState&sourceHasLatestVersionList == 0 {
+		return sg.cache.getAllVersions(), nil
+	}
 
 	// TODO(sdboyer) The problem here is that sourceExistsUpstream may not be
 	// sufficient (e.g. bzr, hg), but we don't want to force local b/c git
@@ -430,9 +542,57 @@ func (sg *sourceGateway) listVersions(ctx context.Context) ([]PairedVersion, err
 	return sg.cache.getAllVersions(), nil
 }
 
+// pairRef fetches a ref of the source which isn't among the branches and tags
+// its versions are listed from, and returns it as a branch paired with the
+// revision it points to.
+func (sg *sourceGateway) pairRef(ctx context.Context, ref string) (PairedVersion, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	r, err := sg.fetchRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return NewBranch(ref).Pair(r), nil
+}
+
+// fetchRef fetches ref into the local copy of the source, once, and returns
+// the revision it points to.
+func (sg *sourceGateway) fetchRef(ctx context.Context, ref string) (Revision, error) {
+	if r, has := sg.refs[ref]; has {
+		return r, nil
+	}
+
+	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
+	if err != nil {
+		return "", err
+	}
+	if sg.suprvsr.offline {
+		return "", sg.suprvsr.denyNetwork(sg.src.upstreamURL())
+	}
+
+	var r Revision
+	err = sg.suprvsr.do(ctx, fmt.Sprintf("%s:%s", sg.src.upstreamURL(), ref), ctSourceFetch, func(ctx context.Context) error {
+		r, err = sg.src.fetchRef(ctx, ref)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if sg.refs == nil {
+		sg.refs = make(map[string]Revision)
+	}
+	sg.refs[ref] = r
+	sg.cache.markRevisionExists(r)
+	return r, nil
+}
+
 func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (bool, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
 	if err != nil {
@@ -450,9 +610,125 @@ func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (boo
 	return present, err
 }
 
+func (sg *sourceGateway) versionInfo(ctx context.Context, v Version) (VersionInfo, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+
+	r, err := sg.convertToRevision(ctx, v)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+
+	// Tag annotations can only be looked up by name, so make sure we have the
+	// unpaired form of the version if one exists.
+	var uv UnpairedVersion
+	switch tv := v.(type) {
+	case UnpairedVersion:
+		uv = tv
+	case PairedVersion:
+		uv = tv.Unpair()
+	}
+
+	var vi VersionInfo
+	label := fmt.Sprintf("%s:%s", sg.src.upstreamURL(), r)
+	err = sg.suprvsr.do(ctx, label, ctVersionInfo, func(ctx context.Context) error {
+		vi, err = sg.src.versionInfo(ctx, uv, r)
+		return err
+	})
+
+	// As with other operations on specific revisions, the local repository
+	// may simply be behind upstream; update it and retry.
+	if err != nil && sg.srcState&sourceHasLatestLocally == 0 {
+		_, err = sg.require(ctx, sourceHasLatestLocally)
+		if err != nil {
+			return VersionInfo{}, err
+		}
+
+		err = sg.suprvsr.do(ctx, label, ctVersionInfo, func(ctx context.Context) error {
+			vi, err = sg.src.versionInfo(ctx, uv, r)
+			return err
+		})
+	}
+
+	return vi, err
+}
+
+func (sg *sourceGateway) commitLog(ctx context.Context, from, to Version) ([]VersionInfo, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
+	if err != nil {
+		return nil, err
+	}
+
+	fr, err := sg.convertToRevision(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := sg.convertToRevision(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var log []VersionInfo
+	label := fmt.Sprintf("%s:%s..%s", sg.src.upstreamURL(), fr, tr)
+	err = sg.suprvsr.do(ctx, label, ctCommitLog, func(ctx context.Context) error {
+		log, err = sg.src.commitLog(ctx, fr, tr)
+		return err
+	})
+
+	// The local repository may be behind upstream; update it and retry.
+	if err != nil && sg.srcState&sourceHasLatestLocally == 0 {
+		_, err = sg.require(ctx, sourceHasLatestLocally)
+		if err != nil {
+			return nil, err
+		}
+
+		err = sg.suprvsr.do(ctx, label, ctCommitLog, func(ctx context.Context) error {
+			log, err = sg.src.commitLog(ctx, fr, tr)
+			return err
+		})
+	}
+
+	return log, err
+}
+
+func (sg *sourceGateway) revisionAsOf(ctx context.Context, v Version, t time.Time) (Revision, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
+
+	_, err := sg.require(ctx, sourceIsSetUp|sourceExistsLocally)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := sg.convertToRevision(ctx, v)
+	if err != nil {
+		return "", err
+	}
+
+	var ar Revision
+	label := fmt.Sprintf("%s:%s@%s", sg.src.upstreamURL(), r, t.Format(time.RFC3339))
+	err = sg.suprvsr.do(ctx, label, ctCommitLog, func(ctx context.Context) error {
+		ar, err = sg.src.revisionAsOf(ctx, r, t)
+		return err
+	})
+	return ar, err
+}
+
 func (sg *sourceGateway) sourceURL(ctx context.Context) (string, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
+	defer sg.unlockLocal()
 
 	_, err := sg.require(ctx, sourceIsSetUp)
 	if err != nil {
@@ -462,12 +738,77 @@ func (sg *sourceGateway) sourceURL(ctx context.Context) (string, error) {
 	return sg.src.upstreamURL(), nil
 }
 
+// withCacheServerTree calls f with the path to the tree of the source at
+// version v, as fetched from the cache server. Trees are kept in the cache
+// directory once fetched, as revisions never change.
+//
+// It reports false, without calling f, if no cache server is used or if the
+// tree could not be fetched from it, in which case the caller is expected to
+// fall back to the upstream source.
+func (sg *sourceGateway) withCacheServerTree(ctx context.Context, v Version, f func(dir string) error) (bool, error) {
+	if sg.remote == nil || sg.suprvsr.offline {
+		return false, nil
+	}
+
+	if _, err := sg.require(ctx, sourceIsSetUp); err != nil {
+		return false, nil
+	}
+	r, err := sg.convertToRevision(ctx, v)
+	if err != nil {
+		return false, nil
+	}
+
+	dir := filepath.Join(sg.cachedir, "trees", sanitizer.Replace(sg.src.upstreamURL()), string(r))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		var n int64
+		label := fmt.Sprintf("%s:%s", sg.src.upstreamURL(), r)
+		err = sg.suprvsr.do(ctx, label, ctCacheServer, func(ctx context.Context) (err error) {
+			n, err = sg.remote.fetchTree(ctx, sg.src.upstreamURL(), r, dir)
+			return err
+		})
+		sg.suprvsr.recordNetwork(sg.remote.base.String(), n)
+		if err != nil {
+			return false, nil
+		}
+		sg.suprvsr.recordSource(true, n)
+	} else {
+		sg.suprvsr.recordSource(false, 0)
+	}
+
+	return true, f(dir)
+}
+
+// localSize returns the size on disk of the local copy of the source, or 0 if
+// it can't be told.
+func (sg *sourceGateway) localSize() int64 {
+	lp, ok := sg.src.(interface {
+		localPath() string
+	})
+	if !ok {
+		return 0
+	}
+
+	var size int64
+	filepath.Walk(lp.localPath(), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
 // createSingleSourceCache creates a singleSourceCache instance for use by
-// the encapsulated source.
-func (sg *sourceGateway) createSingleSourceCache() singleSourceCache {
-	// TODO(sdboyer) when persistent caching is ready, just drop in the creation
-	// of a source-specific handle here
-	return newMemoryCache()
+// the encapsulated source, backed by the persistent cache, if any.
+func (sg *sourceGateway) createSingleSourceCache(disk *boltCache) singleSourceCache {
+	mem := newMemoryCache()
+	if disk == nil {
+		return mem
+	}
+
+	c := &multiCache{mem: mem, disk: disk.newSingleSourceCache(sg.maybe.getURL())}
+	sg.cachedVersions = len(c.getAllVersions()) > 0
+	return c
 }
 
 func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errState sourceState, err error) {
@@ -484,42 +825,90 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errSt
 			errState = flag
 			var addlState sourceState
 
+			if flag&localStates != 0 {
+				if err = sg.lockLocal(ctx); err != nil {
+					return
+				}
+			}
+
 			switch flag {
 			case sourceIsSetUp:
-				sg.src, addlState, err = sg.maybe.try(ctx, sg.cachedir, sg.cache, sg.suprvsr)
+				sg.src, addlState, err = sg.maybe.try(ctx, sg.sourcesdir, sg.cache, sg.suprvsr)
+				if err == nil && addlState&sourceExistsLocally != 0 {
+					sg.suprvsr.recordSource(false, 0)
+				}
+				if sc, ok := sg.src.(shallowCloner); ok && err == nil && sg.shallow {
+					sc.useShallowClone()
+				}
 			case sourceExistsUpstream:
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourcePing, func(ctx context.Context) error {
+				if sg.suprvsr.offline {
+					err = sg.suprvsr.denyNetwork(sg.src.upstreamURL())
+					break
+				}
+				err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourcePing, func(ctx context.Context) error {
 					if !sg.src.existsUpstream(ctx) {
 						return fmt.Errorf("%s does not exist upstream", sg.src.upstreamURL())
 					}
 					return nil
 				})
+				sg.suprvsr.recordNetwork(sg.src.upstreamURL(), 0)
 			case sourceExistsLocally:
-				if !sg.src.existsLocally(ctx) {
-					err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
+				if sg.suprvsr.offline && !sg.src.existsLocally(ctx) {
+					err = sg.suprvsr.denyNetwork(sg.src.upstreamURL())
+				} else if !sg.src.existsLocally(ctx) {
+					err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourceInit, func(ctx context.Context) error {
 						return sg.src.initLocal(ctx)
 					})
 
+					size := sg.localSize()
+					sg.suprvsr.recordNetwork(sg.src.upstreamURL(), size)
 					if err == nil {
 						addlState |= sourceHasLatestLocally
+						sg.suprvsr.recordSource(true, size)
 					} else {
 						err = fmt.Errorf("%s does not exist in the local cache and fetching failed: %s", sg.src.upstreamURL(), err)
 					}
+				} else {
+					sg.suprvsr.recordSource(false, 0)
 				}
 			case sourceHasLatestVersionList:
+				// Offline, the version list can only come from the
+				// persistent cache.
+				if sg.suprvsr.offline {
+					if len(sg.cache.getAllVersions()) == 0 {
+						err = sg.suprvsr.denyNetwork(sg.src.upstreamURL())
+					}
+					break
+				}
 				var pvl []PairedVersion
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
-					pvl, err = sg.src.listVersions(ctx)
-					return err
-				})
+				if sg.remote != nil {
+					err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctCacheServer, func(ctx context.Context) error {
+						pvl, err = sg.remote.listVersions(ctx, sg.src.upstreamURL())
+						return err
+					})
+					sg.suprvsr.recordNetwork(sg.remote.base.String(), 0)
+				}
+				if sg.remote == nil || err != nil {
+					err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctListVersions, func(ctx context.Context) error {
+						pvl, err = sg.src.listVersions(ctx)
+						return err
+					})
+					sg.suprvsr.recordNetwork(sg.src.upstreamURL(), 0)
+				}
 
 				if err == nil {
 					sg.cache.storeVersionMap(pvl, true)
 				}
 			case sourceHasLatestLocally:
-				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
+				if sg.suprvsr.offline {
+					err = sg.suprvsr.denyNetwork(sg.src.upstreamURL())
+					break
+				}
+				before := sg.localSize()
+				err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
+				sg.suprvsr.recordNetwork(sg.src.upstreamURL(), sg.localSize()-before)
 			}
 
 			if err != nil {
@@ -534,9 +923,48 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (errSt
 		flag <<= 1
 	}
 
+	// The caller is about to work on the local copy.
+	if wanted&localStates != 0 {
+		if err = sg.lockLocal(ctx); err != nil {
+			return sourceExistsLocally, err
+		}
+	}
 	return 0, nil
 }
 
+// lockLocal takes the lock on the local copy of the source, unless the
+// current call already holds it. Calls release it with unlockLocal once done.
+func (sg *sourceGateway) lockLocal(ctx context.Context) error {
+	lp, ok := sg.src.(interface {
+		localPath() string
+	})
+	if sg.unlock != nil || !ok {
+		return nil
+	}
+
+	ctx, cancel := constext.Cons(ctx, sg.suprvsr.getLifetimeContext())
+	defer cancel()
+	unlock, err := sg.locker.lock(ctx, lp.localPath())
+	if err != nil {
+		return err
+	}
+	sg.unlock = unlock
+	return nil
+}
+
+func (sg *sourceGateway) unlockLocal() {
+	if sg.unlock != nil {
+		sg.unlock()
+		sg.unlock = nil
+	}
+}
+
+// shallowCloner is implemented by the sources whose local copy can be a
+// shallow clone, holding only the recent history of the source.
+type shallowCloner interface {
+	useShallowClone()
+}
+
 // source is an abstraction around the different underlying types (git, bzr, hg,
 // svn, maybe raw on-disk code, and maybe eventually a registry) that can
 // provide versioned project source trees.
@@ -551,5 +979,9 @@ type source interface {
 	listPackages(context.Context, ProjectRoot, Revision) (pkgtree.PackageTree, error)
 	revisionPresentIn(Revision) (bool, error)
 	exportRevisionTo(context.Context, Revision, string) error
+	versionInfo(context.Context, UnpairedVersion, Revision) (VersionInfo, error)
+	commitLog(ctx context.Context, from, to Revision) ([]VersionInfo, error)
+	revisionAsOf(ctx context.Context, r Revision, t time.Time) (Revision, error)
+	fetchRef(ctx context.Context, ref string) (Revision, error)
 	sourceType() string
 }
@@ -226,6 +226,7 @@ func (c *singleSourceCacheMemory) toUnpaired(v Version) (UnpairedVersion, bool)
 type cachedManifest struct {
 	constraints, overrides ProjectConstraints
 	ignored, required      map[string]bool
+	goVersion              string
 }
 
 func (m *cachedManifest) DependencyConstraints() ProjectConstraints {
@@ -244,6 +245,10 @@ func (m *cachedManifest) RequiredPackages() map[string]bool {
 	return m.required
 }
 
+func (m *cachedManifest) RequiredGoVersion() string {
+	return m.goVersion
+}
+
 // cachedManifest implements Lock and is populated from cached data.
 type cachedLock struct {
 	inputHash []byte
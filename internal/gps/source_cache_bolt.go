@@ -0,0 +1,616 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// boltCacheFilename is the name of the file of the persistent cache, in the
+// cache directory. Its suffix is to be bumped whenever the layout of the
+// database changes.
+const boltCacheFilename = "bolt-v1.db"
+
+var (
+	cacheKeyVersions     = []byte("versions")
+	cacheKeyVersionsTime = []byte("versions-time")
+	cacheKeyRevisions    = []byte("revisions")
+	cacheKeyPackageTrees = []byte("ptrees")
+	cacheKeyInfos        = []byte("infos")
+)
+
+// boltCache is a persistent cache of the metadata of sources, kept in a bolt
+// database so that it outlives the process.
+//
+// Package trees, manifests and locks are cached per revision, and thus never
+// go stale. Version lists are only used for as long as the cache age given to
+// newBoltCache, after which they're fetched again from the source.
+type boltCache struct {
+	db    *bolt.DB
+	epoch int64 // version lists stored earlier than this Unix time are stale
+}
+
+// newBoltCache opens the persistent cache in cachedir, where version lists
+// are fresh for age.
+func newBoltCache(cachedir string, age time.Duration) (*boltCache, error) {
+	path := filepath.Join(cachedir, boltCacheFilename)
+	// Bolt locks the database file for as long as it's open, so don't wait
+	// for long on another process holding it.
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open the persistent cache %s", path)
+	}
+	return &boltCache{db: db, epoch: time.Now().Add(-age).Unix()}, nil
+}
+
+// newSingleSourceCache returns the persistent cache of the source identified
+// by the given name, usually its URL.
+func (c *boltCache) newSingleSourceCache(source string) singleSourceCache {
+	return &singleSourceCacheBolt{boltCache: c, source: []byte(source)}
+}
+
+func (c *boltCache) close() error {
+	return errors.Wrapf(c.db.Close(), "error closing the persistent cache %s", c.db.Path())
+}
+
+// singleSourceCacheBolt is the persistent cache of a single source, which it
+// keeps in a bucket of its own. Errors from the database make it behave as
+// an empty cache: they're not worth failing the operation which would
+// otherwise fall back to the source.
+type singleSourceCacheBolt struct {
+	*boltCache
+	source []byte
+}
+
+// view calls f with the bucket of the source, unless it doesn't exist yet.
+func (c *singleSourceCacheBolt) view(f func(b *bolt.Bucket) error) error {
+	return c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.source)
+		if b == nil {
+			return errors.New("source not cached")
+		}
+		return f(b)
+	})
+}
+
+// update calls f with the bucket of the source, creating it if needed.
+func (c *singleSourceCacheBolt) update(f func(b *bolt.Bucket) error) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(c.source)
+		if err != nil {
+			return err
+		}
+		return f(b)
+	})
+}
+
+// putJSON stores the JSON encoding of v at key in the sub-bucket of b with the
+// given name.
+func putJSON(b *bolt.Bucket, bucket, key []byte, v interface{}) error {
+	sub, err := b.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sub.Put(key, data)
+}
+
+// getJSON decodes the value at key in the sub-bucket of b with the given name
+// into v.
+func getJSON(b *bolt.Bucket, bucket, key []byte, v interface{}) error {
+	sub := b.Bucket(bucket)
+	if sub == nil {
+		return errors.Errorf("no %s cached", bucket)
+	}
+	data := sub.Get(key)
+	if data == nil {
+		return errors.Errorf("%s not cached", key)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func markRevision(b *bolt.Bucket, r Revision) error {
+	revs, err := b.CreateBucketIfNotExists(cacheKeyRevisions)
+	if err != nil {
+		return err
+	}
+	return revs.Put([]byte(r), []byte{})
+}
+
+func infoKey(r Revision, pai ProjectAnalyzerInfo) []byte {
+	return []byte(fmt.Sprintf("%s/%d/%s", pai.Name, pai.Version, r))
+}
+
+func (c *singleSourceCacheBolt) setManifestAndLock(r Revision, pai ProjectAnalyzerInfo, m Manifest, l Lock) {
+	info, err := toCachedProjectInfo(m, l)
+	if err != nil {
+		return
+	}
+	c.update(func(b *bolt.Bucket) error {
+		if err := putJSON(b, cacheKeyInfos, infoKey(r, pai), info); err != nil {
+			return err
+		}
+		return markRevision(b, r)
+	})
+}
+
+func (c *singleSourceCacheBolt) getManifestAndLock(r Revision, pai ProjectAnalyzerInfo) (Manifest, Lock, bool) {
+	var info cachedProjectInfo
+	err := c.view(func(b *bolt.Bucket) error {
+		return getJSON(b, cacheKeyInfos, infoKey(r, pai), &info)
+	})
+	if err != nil {
+		return nil, nil, false
+	}
+	m, l, err := info.manifestAndLock()
+	if err != nil {
+		return nil, nil, false
+	}
+	return m, l, true
+}
+
+func (c *singleSourceCacheBolt) setPackageTree(r Revision, ptree pkgtree.PackageTree) {
+	c.update(func(b *bolt.Bucket) error {
+		if err := putJSON(b, cacheKeyPackageTrees, []byte(r), toCachedPackageTree(ptree)); err != nil {
+			return err
+		}
+		return markRevision(b, r)
+	})
+}
+
+func (c *singleSourceCacheBolt) getPackageTree(r Revision) (pkgtree.PackageTree, bool) {
+	var cpt cachedPackageTree
+	err := c.view(func(b *bolt.Bucket) error {
+		return getJSON(b, cacheKeyPackageTrees, []byte(r), &cpt)
+	})
+	if err != nil {
+		return pkgtree.PackageTree{}, false
+	}
+	return cpt.packageTree(), true
+}
+
+func (c *singleSourceCacheBolt) markRevisionExists(r Revision) {
+	c.update(func(b *bolt.Bucket) error {
+		return markRevision(b, r)
+	})
+}
+
+func (c *singleSourceCacheBolt) storeVersionMap(versionList []PairedVersion, flush bool) {
+	c.update(func(b *bolt.Bucket) error {
+		vl := versionList
+		if !flush {
+			// Keep the existing list, and its age, but for the versions
+			// which are replaced.
+			seen := make(map[UnpairedVersion]bool, len(versionList))
+			for _, pv := range versionList {
+				seen[pv.Unpair()] = true
+			}
+			old, _ := readVersions(b, 0)
+			for _, pv := range old {
+				if !seen[pv.Unpair()] {
+					vl = append(vl, pv)
+				}
+			}
+		}
+
+		cvl := make([]cachedVersion, len(vl))
+		for i, pv := range vl {
+			if err := markRevision(b, pv.Revision()); err != nil {
+				return err
+			}
+			cv, err := toCachedVersion(pv)
+			if err != nil {
+				return err
+			}
+			cvl[i] = cv
+		}
+		data, err := json.Marshal(cvl)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(cacheKeyVersions, data); err != nil {
+			return err
+		}
+		if flush || b.Get(cacheKeyVersionsTime) == nil {
+			return b.Put(cacheKeyVersionsTime, []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+		}
+		return nil
+	})
+}
+
+// readVersions reads the version list of the source from b, unless it was
+// stored before epoch.
+func readVersions(b *bolt.Bucket, epoch int64) ([]PairedVersion, error) {
+	ts, err := strconv.ParseInt(string(b.Get(cacheKeyVersionsTime)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ts < epoch {
+		return nil, errors.New("stale version list")
+	}
+
+	var cvl []cachedVersion
+	if err := json.Unmarshal(b.Get(cacheKeyVersions), &cvl); err != nil {
+		return nil, err
+	}
+	vl := make([]PairedVersion, 0, len(cvl))
+	for _, cv := range cvl {
+		v, err := cv.version()
+		if err != nil {
+			return nil, err
+		}
+		pv, ok := v.(PairedVersion)
+		if !ok {
+			return nil, errors.Errorf("no revision for version %s", cv.Value)
+		}
+		vl = append(vl, pv)
+	}
+	return vl, nil
+}
+
+// versions returns the version list of the source, if fresh.
+func (c *singleSourceCacheBolt) versions() []PairedVersion {
+	var vl []PairedVersion
+	c.view(func(b *bolt.Bucket) (err error) {
+		vl, err = readVersions(b, c.epoch)
+		return err
+	})
+	return vl
+}
+
+func (c *singleSourceCacheBolt) getVersionsFor(r Revision) ([]UnpairedVersion, bool) {
+	var has bool
+	c.view(func(b *bolt.Bucket) error {
+		if revs := b.Bucket(cacheKeyRevisions); revs != nil {
+			has = revs.Get([]byte(r)) != nil
+		}
+		return nil
+	})
+	if !has {
+		return nil, false
+	}
+
+	var uvl []UnpairedVersion
+	for _, pv := range c.versions() {
+		if pv.Revision() == r {
+			uvl = append(uvl, pv.Unpair())
+		}
+	}
+	return uvl, true
+}
+
+func (c *singleSourceCacheBolt) getAllVersions() []PairedVersion {
+	return c.versions()
+}
+
+func (c *singleSourceCacheBolt) getRevisionFor(uv UnpairedVersion) (Revision, bool) {
+	for _, pv := range c.versions() {
+		if pv.Unpair() == uv {
+			return pv.Revision(), true
+		}
+	}
+	return "", false
+}
+
+func (c *singleSourceCacheBolt) toRevision(v Version) (Revision, bool) {
+	switch t := v.(type) {
+	case Revision:
+		return t, true
+	case PairedVersion:
+		return t.Revision(), true
+	case UnpairedVersion:
+		return c.getRevisionFor(t)
+	default:
+		panic(fmt.Sprintf("Unknown version type %T", v))
+	}
+}
+
+func (c *singleSourceCacheBolt) toUnpaired(v Version) (UnpairedVersion, bool) {
+	switch t := v.(type) {
+	case UnpairedVersion:
+		return t, true
+	case PairedVersion:
+		return t.Unpair(), true
+	case Revision:
+		if uvl, has := c.getVersionsFor(t); has && len(uvl) > 0 {
+			return uvl[0], true
+		}
+		return nil, false
+	default:
+		panic(fmt.Sprintf("unknown version type %T", v))
+	}
+}
+
+// cachedVersion is the JSON form of a Version or of a Constraint in the
+// persistent cache.
+type cachedVersion struct {
+	// Type is one of "branch", "version", "semver" or "revision" for
+	// versions, and of "range", "any" or "none" for other constraints.
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+	// Revision is set for paired versions.
+	Revision Revision `json:"revision,omitempty"`
+	// Default is set for the default branch of the source.
+	Default bool `json:"default,omitempty"`
+}
+
+func toCachedConstraint(c Constraint) (cachedVersion, error) {
+	switch t := c.(type) {
+	case anyConstraint:
+		return cachedVersion{Type: "any"}, nil
+	case noneConstraint:
+		return cachedVersion{Type: "none"}, nil
+	case semverConstraint:
+		return cachedVersion{Type: "range", Value: t.c.String()}, nil
+	case Version:
+		return toCachedVersion(t)
+	}
+	return cachedVersion{}, errors.Errorf("unsupported constraint type %T", c)
+}
+
+func toCachedVersion(v Version) (cachedVersion, error) {
+	switch t := v.(type) {
+	case Revision:
+		return cachedVersion{Type: "revision", Value: string(t)}, nil
+	case PairedVersion:
+		cv, err := toCachedVersion(t.Unpair())
+		cv.Revision = t.Revision()
+		return cv, err
+	case branchVersion:
+		return cachedVersion{Type: "branch", Value: t.name, Default: t.isDefault}, nil
+	case semVersion:
+		return cachedVersion{Type: "semver", Value: t.String()}, nil
+	case plainVersion:
+		return cachedVersion{Type: "version", Value: t.String()}, nil
+	}
+	return cachedVersion{}, errors.Errorf("unsupported version type %T", v)
+}
+
+func (cv cachedVersion) constraint() (Constraint, error) {
+	switch cv.Type {
+	case "any":
+		return anyConstraint{}, nil
+	case "none":
+		return noneConstraint{}, nil
+	case "range":
+		return NewSemverConstraint(cv.Value)
+	}
+	return cv.version()
+}
+
+func (cv cachedVersion) version() (Version, error) {
+	var uv UnpairedVersion
+	switch cv.Type {
+	case "revision":
+		return Revision(cv.Value), nil
+	case "branch":
+		if cv.Default {
+			uv = newDefaultBranch(cv.Value)
+		} else {
+			uv = NewBranch(cv.Value)
+		}
+	case "semver":
+		uv = NewVersion(cv.Value)
+	case "version":
+		uv = plainVersion(cv.Value)
+	default:
+		return nil, errors.Errorf("unknown type %q for version %s", cv.Type, cv.Value)
+	}
+	if cv.Revision != "" {
+		return uv.Pair(cv.Revision), nil
+	}
+	return uv, nil
+}
+
+type cachedProjectProperties struct {
+	Root       ProjectRoot    `json:"root"`
+	Source     string         `json:"source,omitempty"`
+	Constraint *cachedVersion `json:"constraint,omitempty"`
+}
+
+type cachedLockedProject struct {
+	Root     ProjectRoot   `json:"root"`
+	Source   string        `json:"source,omitempty"`
+	Version  cachedVersion `json:"version"`
+	Packages []string      `json:"packages,omitempty"`
+}
+
+// cachedProjectInfo is the JSON form of a manifest and a lock in the
+// persistent cache.
+type cachedProjectInfo struct {
+	Constraints []cachedProjectProperties `json:"constraints,omitempty"`
+
+	// Root is set for the manifests which are RootManifests, with the fields
+	// below.
+	Root      bool                      `json:"root,omitempty"`
+	Overrides []cachedProjectProperties `json:"overrides,omitempty"`
+	Ignored   []string                  `json:"ignored,omitempty"`
+	Required  []string                  `json:"required,omitempty"`
+
+	// GoVersion is the release of Go the manifest requires, if any.
+	GoVersion string `json:"go-version,omitempty"`
+
+	// Locked is set when there is a lock.
+	Locked    bool                  `json:"locked,omitempty"`
+	InputHash []byte                `json:"input-hash,omitempty"`
+	Projects  []cachedLockedProject `json:"projects,omitempty"`
+}
+
+func toCachedProjectConstraints(pc ProjectConstraints) ([]cachedProjectProperties, error) {
+	cpl := make([]cachedProjectProperties, 0, len(pc))
+	for pr, pp := range pc {
+		cpp := cachedProjectProperties{Root: pr, Source: pp.Source}
+		if pp.Constraint != nil {
+			cv, err := toCachedConstraint(pp.Constraint)
+			if err != nil {
+				return nil, err
+			}
+			cpp.Constraint = &cv
+		}
+		cpl = append(cpl, cpp)
+	}
+	return cpl, nil
+}
+
+func toCachedProjectInfo(m Manifest, l Lock) (info cachedProjectInfo, err error) {
+	if m != nil {
+		if info.Constraints, err = toCachedProjectConstraints(m.DependencyConstraints()); err != nil {
+			return
+		}
+		if rm, ok := m.(RootManifest); ok {
+			info.Root = true
+			if info.Overrides, err = toCachedProjectConstraints(rm.Overrides()); err != nil {
+				return
+			}
+			for ip := range rm.IgnoredPackages() {
+				info.Ignored = append(info.Ignored, ip)
+			}
+			for ip := range rm.RequiredPackages() {
+				info.Required = append(info.Required, ip)
+			}
+		}
+		info.GoVersion = requiredGoVersion(m)
+	}
+
+	if l != nil {
+		info.Locked = true
+		info.InputHash = l.InputHash()
+		for _, lp := range l.Projects() {
+			cv, err := toCachedVersion(lp.Version())
+			if err != nil {
+				return info, err
+			}
+			info.Projects = append(info.Projects, cachedLockedProject{
+				Root:     lp.Ident().ProjectRoot,
+				Source:   lp.Ident().Source,
+				Version:  cv,
+				Packages: lp.Packages(),
+			})
+		}
+	}
+	return info, nil
+}
+
+func fromCachedProjectConstraints(cpl []cachedProjectProperties) (ProjectConstraints, error) {
+	pc := make(ProjectConstraints, len(cpl))
+	for _, cpp := range cpl {
+		pp := ProjectProperties{Source: cpp.Source}
+		if cpp.Constraint != nil {
+			c, err := cpp.Constraint.constraint()
+			if err != nil {
+				return nil, err
+			}
+			pp.Constraint = c
+		}
+		pc[cpp.Root] = pp
+	}
+	return pc, nil
+}
+
+func stringSet(l []string) map[string]bool {
+	set := make(map[string]bool, len(l))
+	for _, s := range l {
+		set[s] = true
+	}
+	return set
+}
+
+func (info cachedProjectInfo) manifestAndLock() (Manifest, Lock, error) {
+	deps, err := fromCachedProjectConstraints(info.Constraints)
+	if err != nil {
+		return nil, nil, err
+	}
+	var m Manifest = SimpleManifest{Deps: deps}
+	if info.Root {
+		ovr, err := fromCachedProjectConstraints(info.Overrides)
+		if err != nil {
+			return nil, nil, err
+		}
+		m = &cachedManifest{
+			constraints: deps,
+			overrides:   ovr,
+			ignored:     stringSet(info.Ignored),
+			required:    stringSet(info.Required),
+			goVersion:   info.GoVersion,
+		}
+	} else if info.GoVersion != "" {
+		m = goVersionManifest{Manifest: m, goVersion: info.GoVersion}
+	}
+
+	if !info.Locked {
+		return m, nil, nil
+	}
+	l := &cachedLock{inputHash: info.InputHash}
+	for _, clp := range info.Projects {
+		v, err := clp.Version.version()
+		if err != nil {
+			return nil, nil, err
+		}
+		pi := ProjectIdentifier{ProjectRoot: clp.Root, Source: clp.Source}
+		l.projects = append(l.projects, NewLockedProject(pi, v, clp.Packages))
+	}
+	return m, l, nil
+}
+
+// cachedPackageTree is the JSON form of a PackageTree in the persistent
+// cache.
+type cachedPackageTree struct {
+	ImportRoot string                        `json:"import-root"`
+	Packages   map[string]cachedPackageOrErr `json:"packages"`
+}
+
+type cachedPackageOrErr struct {
+	P   pkgtree.Package `json:"package"`
+	Err string          `json:"error,omitempty"`
+	// NoGo is set for build.NoGoErrors, whose directory is then in Err.
+	NoGo bool `json:"no-go,omitempty"`
+}
+
+func toCachedPackageTree(ptree pkgtree.PackageTree) cachedPackageTree {
+	cpt := cachedPackageTree{
+		ImportRoot: ptree.ImportRoot,
+		Packages:   make(map[string]cachedPackageOrErr, len(ptree.Packages)),
+	}
+	for ip, poe := range ptree.Packages {
+		cpoe := cachedPackageOrErr{P: poe.P}
+		if ne, ok := poe.Err.(*build.NoGoError); ok {
+			cpoe.NoGo, cpoe.Err = true, ne.Dir
+		} else if poe.Err != nil {
+			cpoe.Err = poe.Err.Error()
+		}
+		cpt.Packages[ip] = cpoe
+	}
+	return cpt
+}
+
+func (cpt cachedPackageTree) packageTree() pkgtree.PackageTree {
+	ptree := pkgtree.PackageTree{
+		ImportRoot: cpt.ImportRoot,
+		Packages:   make(map[string]pkgtree.PackageOrErr, len(cpt.Packages)),
+	}
+	for ip, cpoe := range cpt.Packages {
+		poe := pkgtree.PackageOrErr{P: cpoe.P}
+		switch {
+		case cpoe.NoGo:
+			poe.Err = &build.NoGoError{Dir: cpoe.Err}
+		case cpoe.Err != "":
+			poe.Err = errors.New(cpoe.Err)
+		}
+		ptree.Packages[ip] = poe
+	}
+	return ptree
+}
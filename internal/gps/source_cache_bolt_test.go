@@ -0,0 +1,150 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"errors"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+// unreachableMaybeSource is a maybeSource which can't be reached.
+type unreachableMaybeSource struct{}
+
+func (unreachableMaybeSource) try(context.Context, string, singleSourceCache, *supervisor) (source, sourceState, error) {
+	return nil, 0, errors.New("unreachable")
+}
+
+func (unreachableMaybeSource) getURL() string {
+	return "https://example.com/unreachable"
+}
+
+func TestBoltCachePersistence(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "boltcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	const rev Revision = "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"
+	versions := []PairedVersion{
+		NewVersion("v1.0.0").Pair(rev),
+		newDefaultBranch("master").Pair(rev),
+	}
+	ptree := pkgtree.PackageTree{
+		ImportRoot: "example.com/unreachable",
+		Packages: map[string]pkgtree.PackageOrErr{
+			"example.com/unreachable": {
+				P: pkgtree.Package{Name: "unreachable", ImportPath: "example.com/unreachable", Imports: []string{"sort"}},
+			},
+			"example.com/unreachable/docs": {
+				Err: &build.NoGoError{Dir: "/tmp/docs"},
+			},
+		},
+	}
+
+	bc, err := newBoltCache(cachedir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := bc.newSingleSourceCache(unreachableMaybeSource{}.getURL())
+	c.storeVersionMap(versions, true)
+	c.setPackageTree(rev, ptree)
+	if err = bc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The gateway of the source lists its versions, and its packages, from the
+	// persistent cache, without reaching out to the source.
+	bc, err = newBoltCache(cachedir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	superv := newSupervisor(context.Background())
+	sg := newSourceGateway(unreachableMaybeSource{}, superv, cachedir, cachedir, newSourceLocker(sourceLocksDir(cachedir)), nil, bc)
+	got, err := sg.listVersions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error listing cached versions: %s", err)
+	}
+	if len(got) != len(versions) {
+		t.Errorf("unexpected versions:\n\t(GOT): %v\n\t(WNT): %v", got, versions)
+	}
+	gotTree, err := sg.listPackages(context.Background(), "example.com/unreachable", NewVersion("v1.0.0"))
+	if err != nil {
+		t.Fatalf("unexpected error listing cached packages: %s", err)
+	}
+	comparePackageTree(t, ptree, gotTree)
+	if _, ok := gotTree.Packages["example.com/unreachable/docs"].Err.(*build.NoGoError); !ok {
+		t.Errorf("expected a *build.NoGoError, got %T", gotTree.Packages["example.com/unreachable/docs"].Err)
+	}
+
+	// Age the version list past the cache age.
+	err = bc.db.Update(func(tx *bolt.Tx) error {
+		old := time.Now().Add(-2 * time.Hour).Unix()
+		return tx.Bucket([]byte(unreachableMaybeSource{}.getURL())).Put(cacheKeyVersionsTime, []byte(strconv.FormatInt(old, 10)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg = newSourceGateway(unreachableMaybeSource{}, superv, cachedir, cachedir, newSourceLocker(sourceLocksDir(cachedir)), nil, bc)
+	if _, err = sg.listVersions(context.Background()); err == nil {
+		t.Error("expected a stale version list to be listed again from the source")
+	}
+	if _, ok := sg.cache.getPackageTree(rev); !ok {
+		t.Error("expected package trees to never go stale")
+	}
+
+	if err = bc.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltCacheRefreshVersionLists(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "boltcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	const rev Revision = "278a227dfc3d595a33a77ff3f841fd8ca1bc8cd0"
+	bc, err := newBoltCache(cachedir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := bc.newSingleSourceCache(unreachableMaybeSource{}.getURL())
+	c.storeVersionMap([]PairedVersion{NewVersion("v1.0.0").Pair(rev)}, true)
+	c.setPackageTree(rev, pkgtree.PackageTree{ImportRoot: "example.com/unreachable"})
+	if err = bc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewSourceManager(cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+	if err = sm.UsePersistentCache(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	sm.RefreshVersionLists()
+
+	// The version list is fresh, but is listed again from the source all the
+	// same, while package trees are still served from the cache.
+	sg := newSourceGateway(unreachableMaybeSource{}, sm.suprvsr, cachedir, cachedir, newSourceLocker(sourceLocksDir(cachedir)), nil, sm.srcCoord.disk)
+	if _, err = sg.listVersions(context.Background()); err == nil {
+		t.Error("expected the version list to be listed again from the source")
+	}
+	if _, ok := sg.cache.getPackageTree(rev); !ok {
+		t.Error("expected package trees to still be served from the cache")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+// multiCache is a singleSourceCache which stores into both a memory cache and
+// a persistent one, and which looks in the persistent one when the memory
+// one misses, keeping what it finds there in memory.
+type multiCache struct {
+	mem, disk singleSourceCache
+}
+
+func (c *multiCache) setManifestAndLock(r Revision, pai ProjectAnalyzerInfo, m Manifest, l Lock) {
+	c.mem.setManifestAndLock(r, pai, m, l)
+	c.disk.setManifestAndLock(r, pai, m, l)
+}
+
+func (c *multiCache) getManifestAndLock(r Revision, pai ProjectAnalyzerInfo) (Manifest, Lock, bool) {
+	m, l, ok := c.mem.getManifestAndLock(r, pai)
+	if ok {
+		return m, l, true
+	}
+
+	m, l, ok = c.disk.getManifestAndLock(r, pai)
+	if ok {
+		c.mem.setManifestAndLock(r, pai, m, l)
+		return m, l, true
+	}
+
+	return nil, nil, false
+}
+
+func (c *multiCache) setPackageTree(r Revision, ptree pkgtree.PackageTree) {
+	c.mem.setPackageTree(r, ptree)
+	c.disk.setPackageTree(r, ptree)
+}
+
+func (c *multiCache) getPackageTree(r Revision) (pkgtree.PackageTree, bool) {
+	ptree, ok := c.mem.getPackageTree(r)
+	if ok {
+		return ptree, true
+	}
+
+	ptree, ok = c.disk.getPackageTree(r)
+	if ok {
+		c.mem.setPackageTree(r, ptree)
+		return ptree, true
+	}
+
+	return pkgtree.PackageTree{}, false
+}
+
+func (c *multiCache) markRevisionExists(r Revision) {
+	c.mem.markRevisionExists(r)
+	c.disk.markRevisionExists(r)
+}
+
+func (c *multiCache) storeVersionMap(versionList []PairedVersion, flush bool) {
+	c.mem.storeVersionMap(versionList, flush)
+	c.disk.storeVersionMap(versionList, flush)
+}
+
+func (c *multiCache) getVersionsFor(rev Revision) ([]UnpairedVersion, bool) {
+	uvs, ok := c.mem.getVersionsFor(rev)
+	if ok {
+		return uvs, true
+	}
+
+	return c.disk.getVersionsFor(rev)
+}
+
+func (c *multiCache) getAllVersions() []PairedVersion {
+	pvs := c.mem.getAllVersions()
+	if len(pvs) > 0 {
+		return pvs
+	}
+
+	pvs = c.disk.getAllVersions()
+	if len(pvs) > 0 {
+		c.mem.storeVersionMap(pvs, true)
+	}
+	return pvs
+}
+
+func (c *multiCache) getRevisionFor(uv UnpairedVersion) (Revision, bool) {
+	rev, ok := c.mem.getRevisionFor(uv)
+	if ok {
+		return rev, true
+	}
+
+	return c.disk.getRevisionFor(uv)
+}
+
+func (c *multiCache) toRevision(v Version) (Revision, bool) {
+	rev, ok := c.mem.toRevision(v)
+	if ok {
+		return rev, true
+	}
+
+	return c.disk.toRevision(v)
+}
+
+func (c *multiCache) toUnpaired(v Version) (UnpairedVersion, bool) {
+	uv, ok := c.mem.toUnpaired(v)
+	if ok {
+		return uv, true
+	}
+
+	return c.disk.toUnpaired(v)
+}
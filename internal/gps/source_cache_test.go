@@ -5,8 +5,12 @@
 package gps
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/golang/dep/internal/gps/pkgtree"
 	"github.com/pkg/errors"
@@ -18,12 +22,44 @@ var testAnalyzerInfo = ProjectAnalyzerInfo{
 }
 
 func TestSingleSourceCache(t *testing.T) {
+	t.Run("mem", func(t *testing.T) {
+		testSingleSourceCache(t, func() singleSourceCache { return newMemoryCache() })
+	})
+
+	cachedir, err := ioutil.TempDir("", "singlesourcecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+	bc, err := newBoltCache(cachedir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.close()
+
+	// Each subtest gets a source of its own in the database.
+	var n int
+	newDiskCache := func() singleSourceCache {
+		n++
+		return bc.newSingleSourceCache(fmt.Sprintf("https://example.com/test%d", n))
+	}
+	t.Run("bolt", func(t *testing.T) {
+		testSingleSourceCache(t, newDiskCache)
+	})
+	t.Run("multi", func(t *testing.T) {
+		testSingleSourceCache(t, func() singleSourceCache {
+			return &multiCache{mem: newMemoryCache(), disk: newDiskCache()}
+		})
+	})
+}
+
+func testSingleSourceCache(t *testing.T, newCache func() singleSourceCache) {
 	const root = "example.com/test"
 
 	t.Run("info", func(t *testing.T) {
 		const rev Revision = "revision"
 
-		c := newMemoryCache()
+		c := newCache()
 
 		var m Manifest = &cachedManifest{
 			constraints: ProjectConstraints{
@@ -109,7 +145,7 @@ func TestSingleSourceCache(t *testing.T) {
 	})
 
 	t.Run("pkgTree", func(t *testing.T) {
-		c := newMemoryCache()
+		c := newCache()
 
 		const rev Revision = "rev_adsfjkl"
 
@@ -171,7 +207,7 @@ func TestSingleSourceCache(t *testing.T) {
 	})
 
 	t.Run("versions", func(t *testing.T) {
-		c := newMemoryCache()
+		c := newCache()
 
 		const rev1, rev2 = "rev1", "rev2"
 		const br, ver = "branch_name", "2.10"
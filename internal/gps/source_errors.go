@@ -5,6 +5,7 @@
 package gps
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/Masterminds/vcs"
@@ -23,3 +24,48 @@ func unwrapVcsErr(err error) error {
 		return err
 	}
 }
+
+// offlineError is returned in offline mode by the operations which would have
+// contacted url.
+type offlineError struct {
+	url string
+}
+
+func (e offlineError) Error() string {
+	return fmt.Sprintf("offline mode forbids contacting %s", e.url)
+}
+
+// gitAuthFailure indicates that git was denied access to a remote, and that no
+// credentials for it could be had from the configured credential helpers.
+type gitAuthFailure struct {
+	remote string
+	out    string
+}
+
+func (e gitAuthFailure) Error() string {
+	return fmt.Sprintf("authentication failed for %s; git is run without prompting for credentials, so they must be configured for its host, or come from a credential helper (see git help credentials) or, for SSH remotes, from ssh-agent:\n%s",
+		e.remote, e.out)
+}
+
+// gitAuthFailureMarkers are the messages git and the common hosting sites
+// print when credentials are missing or rejected.
+var gitAuthFailureMarkers = [][]byte{
+	[]byte("terminal prompts disabled"),
+	[]byte("could not read Username"),
+	[]byte("could not read Password"),
+	[]byte("Authentication failed"),
+	[]byte("Permission denied (publickey"),
+	[]byte("HTTP Basic: Access denied"),
+	[]byte("Invalid username or password"),
+}
+
+// isGitAuthFailure reports whether the output of a failed git command shows
+// that it was denied access to the remote.
+func isGitAuthFailure(out []byte) bool {
+	for _, m := range gitAuthFailureMarkers {
+		if bytes.Contains(out, m) {
+			return true
+		}
+	}
+	return false
+}
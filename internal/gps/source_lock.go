@@ -0,0 +1,532 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/nightlyone/lockfile"
+)
+
+// sourceLocker hands out the locks guarding the local copies of sources in
+// the cache directory, so that several processes can share the cache as long
+// as they don't work on the same sources at the same time.
+//
+// Each lock is a lock file, owned by a process, paired with a semaphore to
+// serialize the goroutines of that process. Lock files left behind by
+// processes which died are taken over.
+//
+// Lock files on network filesystems, which may not support the hard links
+// regular lock files rely on, and be shared by several machines, record the
+// host of their owner as well. They are created exclusively instead, and only
+// taken over from processes of the same host; see checkFilesystem.
+//
+// A call never holds the lock of a source while waiting for the lock of
+// another: each call works on a single source, and git submodules are kept
+// within the local copy of their parent. Processes therefore can't deadlock
+// waiting on each other.
+type sourceLocker struct {
+	dir    string // where the lock files are kept
+	shared bool   // whether dir is on a network filesystem
+	mu     sync.Mutex
+	sems   map[string]chan struct{} // guarded by mu
+
+	// timeout is how long to wait for a lock before giving up, or zero to
+	// wait for as long as it takes.
+	timeout time.Duration
+	// progress is where the waits for locks are reported, if not os.Stderr.
+	progress *log.Logger
+}
+
+func newSourceLocker(dir string) *sourceLocker {
+	// Lock files need absolute paths.
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return &sourceLocker{
+		dir:  dir,
+		sems: make(map[string]chan struct{}),
+	}
+}
+
+// checkFilesystem creates the directory of the locker, and checks whether it
+// is on a network filesystem, in which case host lock files are used. These
+// rely on exclusive file creation, which old versions of NFS don't guarantee,
+// so an error is returned if it doesn't hold.
+func (l *sourceLocker) checkFilesystem() error {
+	if err := os.MkdirAll(l.dir, 0777); err != nil {
+		return err
+	}
+	shared, err := fs.IsNetworkFilesystem(l.dir)
+	if err != nil {
+		return err
+	}
+	if !shared {
+		return nil
+	}
+
+	probe, err := ioutil.TempFile(l.dir, "probe.")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	defer os.Remove(probe.Name())
+	f, err := os.OpenFile(probe.Name(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if !os.IsExist(err) {
+		if err == nil {
+			f.Close()
+		}
+		return fmt.Errorf("%s is on a network filesystem which doesn't support exclusive file creation, so the sources kept there can't be locked", l.dir)
+	}
+
+	l.shared = true
+	return nil
+}
+
+// The delay after which the processes waiting for a lock tell so, and the
+// interval at which they keep telling.
+const (
+	lockProgressDelay    = time.Second
+	lockProgressInterval = 15 * time.Second
+)
+
+// lock takes the lock on the local copy of a source at path, waiting until
+// no other process or goroutine holds it, until ctx is canceled, or until the
+// timeout of the locker, if any, expires. It returns a func releasing the
+// lock.
+func (l *sourceLocker) lock(ctx context.Context, path string) (func(), error) {
+	name := filepath.Join(l.dir, filepath.Base(path)+".lock")
+
+	var expired <-chan time.Time
+	if l.timeout > 0 {
+		t := time.NewTimer(l.timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	l.mu.Lock()
+	sem, has := l.sems[name]
+	if !has {
+		sem = make(chan struct{}, 1)
+		l.sems[name] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		// Another goroutine of this process holds it.
+		return nil, LockTimeoutError{Path: name, Holder: os.Getpid(), Timeout: l.timeout}
+	}
+
+	unlock, err := l.lockFile(ctx, name, expired)
+	if err != nil {
+		<-sem
+		return nil, err
+	}
+	return func() {
+		unlock()
+		<-sem
+	}, nil
+}
+
+// lockFile takes the lock file at name, or the host lock file if the locker
+// is shared, retrying for as long as it's held by another process, until ctx
+// is canceled or expired fires. It returns a func releasing the lock file.
+func (l *sourceLocker) lockFile(ctx context.Context, name string, expired <-chan time.Time) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return nil, err
+	}
+	lf, err := lockfile.New(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create lock %s: %s", name, err)
+	}
+	try, unlock := lf.TryLock, func() { lf.Unlock() }
+	if l.shared {
+		try, unlock = func() error { return tryHostLockFile(name) }, func() { os.Remove(name) }
+	}
+
+	// Most calls are short, so start by retrying quickly, and slow down as
+	// the wait goes on.
+	start := time.Now()
+	var waiting bool
+	var reported time.Time
+	wait := 10 * time.Millisecond
+	for err = try(); err != nil; err = try() {
+		if _, ok := err.(interface {
+			Temporary() bool
+		}); !ok {
+			return nil, fmt.Errorf("unable to lock %s: %s", name, err)
+		}
+
+		// Lock files whose owner died are taken over by lockfile, unless its
+		// pid went to another process since.
+		if !l.shared && pidReused(name) {
+			checked, err := takeOverStaleLock(name, pidReused)
+			if err != nil {
+				return nil, fmt.Errorf("unable to take over lock %s: %s", name, err)
+			}
+			if checked {
+				continue
+			}
+		}
+		pid, _, _ := lockOwner(name)
+
+		if !waiting {
+			// Let CacheLocks tell who's waiting for whom.
+			wf := waitFileName(name, os.Getpid())
+			if werr := ioutil.WriteFile(wf, []byte(lockOwnerLine(l.shared)), 0666); werr == nil {
+				defer os.Remove(wf)
+			}
+			waiting = true
+		}
+		if waited := time.Since(start); waited >= lockProgressDelay && time.Since(reported) >= lockProgressInterval {
+			if reported.IsZero() {
+				l.logf("waiting for the lock on %s, held by process %d", lockSourceName(name), pid)
+			} else {
+				l.logf("still waiting for the lock on %s, held by process %d, after %s", lockSourceName(name), pid, waited/time.Second*time.Second)
+			}
+			reported = time.Now()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, LockTimeoutError{Path: name, Holder: pid, Timeout: l.timeout}
+		}
+		if wait *= 2; wait > time.Second {
+			wait = time.Second
+		}
+	}
+	return unlock, nil
+}
+
+// logf reports the progress of a wait for a lock.
+func (l *sourceLocker) logf(format string, args ...interface{}) {
+	if l.progress != nil {
+		l.progress.Printf(format, args...)
+	} else {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// lockSourceName returns the name of the source whose lock file is at name.
+func lockSourceName(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".lock")
+}
+
+// LockTimeoutError is returned when the lock on the local copy of a source
+// isn't taken within the timeout given to UseLockTimeout.
+type LockTimeoutError struct {
+	// Path is the lock file, and Holder the process holding it when the
+	// timeout expired, or 0 if it's unknown.
+	Path    string
+	Holder  int
+	Timeout time.Duration
+}
+
+func (e LockTimeoutError) Error() string {
+	holder := "another process"
+	if e.Holder != 0 {
+		holder = fmt.Sprintf("process %d", e.Holder)
+	}
+	return fmt.Sprintf("timed out after %s waiting for the lock on %s, held by %s; dep cache locks lists the locks of the cache", e.Timeout, lockSourceName(e.Path), holder)
+}
+
+// tryHostLockFile takes the host lock file at name, by creating it. A host
+// lock file left behind by a process of this host which died is removed, so
+// that the next try takes it over; those of other hosts can't be told stale.
+func tryHostLockFile(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err == nil {
+		_, err = f.WriteString(lockOwnerLine(true))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(name)
+		}
+		return err
+	}
+	if !os.IsExist(err) {
+		return err
+	}
+
+	if hostLockStale(name) {
+		if _, err := takeOverStaleLock(name, hostLockStale); err != nil {
+			return err
+		}
+	}
+	return lockfile.ErrBusy
+}
+
+// hostLockStale reports whether the host lock file at path was left behind by
+// a process of this host which died.
+func hostLockStale(path string) bool {
+	pid, _, alive := lockOwner(path)
+	if alive {
+		return false
+	}
+	// A lock file without a pid may not have been written to yet.
+	fi, err := os.Stat(path)
+	return err == nil && (pid != 0 || time.Since(fi.ModTime()) >= time.Minute)
+}
+
+// takeOverStaleLock removes the lock file at name, which stale reported as
+// left behind. Several waiters may find it stale at once, and one of them take
+// the lock before another removes it, so takeovers are serialized by a breaker
+// file next to it, created exclusively, and the lock file is removed only if
+// it's still stale once the breaker is held. It reports whether the lock file
+// was checked under the breaker, rather than the breaker being held by another
+// waiter.
+//
+// Breakers are only held for the time of a check, so one left behind by a
+// process which died, or older than a minute, is removed for the next try.
+func takeOverStaleLock(name string, stale func(path string) bool) (bool, error) {
+	breaker := name + ".break"
+	f, err := os.OpenFile(breaker, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		if !os.IsExist(err) {
+			return false, err
+		}
+		if breakerStale(breaker) {
+			if err := os.Remove(breaker); err != nil && !os.IsNotExist(err) {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	defer os.Remove(breaker)
+	// Record the host as well, as for host lock files, so that waiters of
+	// other hosts don't take the breaker for stale.
+	_, err = f.WriteString(lockOwnerLine(true))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if stale(name) {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// breakerStale reports whether the breaker file at path was left behind by a
+// process which died, or for longer than a takeover could take.
+func breakerStale(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if time.Since(fi.ModTime()) >= time.Minute {
+		return true
+	}
+	// A breaker without a pid may not have been written to yet.
+	pid, _, alive := lockOwner(path)
+	return pid != 0 && !alive
+}
+
+// lockOwnerLine returns the contents of the lock and wait files of the
+// process: its pid, followed by its host for host lock files.
+func lockOwnerLine(shared bool) string {
+	if shared {
+		return fmt.Sprintf("%d\n%s\n", os.Getpid(), hostname())
+	}
+	return fmt.Sprintf("%d\n", os.Getpid())
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+// sourceLocksDir returns the directory where the locks of the sources of the
+// cache directory are kept.
+func sourceLocksDir(cachedir string) string {
+	return filepath.Join(cachedir, "locks")
+}
+
+// waitFileName returns the name of the file recording that the process pid
+// waits for the lock file at name.
+func waitFileName(name string, pid int) string {
+	return fmt.Sprintf("%s.wait.%d", strings.TrimSuffix(name, ".lock"), pid)
+}
+
+// CacheLock describes the lock on the local copy of a source in a cache
+// directory, as reported by CacheLocks.
+type CacheLock struct {
+	// Name is the name of the local copy of the source in the cache
+	// directory.
+	Name string
+	// Holder is the process holding the lock, or 0 if none does, and Since
+	// the time at which it took it.
+	Holder int
+	Since  time.Time
+	// Host is the host of the holder, if it isn't this one. Only the locks of
+	// cache directories on network filesystems may be held by other hosts.
+	Host string
+	// Stale is set if the holder died without releasing the lock. The next
+	// process needing the source takes it over. Whether the holder is alive
+	// can't be told when it runs on another host.
+	Stale bool
+	// Waiters are the processes waiting for the lock.
+	Waiters []int
+}
+
+// CacheLocks returns the locks on the sources of the cache directory which
+// are held or waited for, sorted by name. Waiters which died are left out.
+func CacheLocks(cachedir string) ([]CacheLock, error) {
+	dir, err := filepath.Abs(sourceLocksDir(cachedir))
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make(map[string]*CacheLock)
+	get := func(name string) *CacheLock {
+		cl, has := locks[name]
+		if !has {
+			cl = &CacheLock{Name: name}
+			locks[name] = cl
+		}
+		return cl
+	}
+	for _, fi := range fis {
+		path := filepath.Join(dir, fi.Name())
+		switch {
+		case strings.HasSuffix(fi.Name(), ".lock"):
+			pid, host, alive := lockOwner(path)
+			if pid == 0 && !alive {
+				// Released in the meantime.
+				continue
+			}
+			cl := get(strings.TrimSuffix(fi.Name(), ".lock"))
+			cl.Holder, cl.Since, cl.Host, cl.Stale = pid, fi.ModTime(), host, !alive
+		case strings.Contains(fi.Name(), ".wait."):
+			if pid, _, alive := lockOwner(path); alive {
+				cl := get(fi.Name()[:strings.LastIndex(fi.Name(), ".wait.")])
+				cl.Waiters = append(cl.Waiters, pid)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(locks))
+	for name := range locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cls := make([]CacheLock, 0, len(names))
+	for _, name := range names {
+		sort.Ints(locks[name].Waiters)
+		cls = append(cls, *locks[name])
+	}
+	return cls, nil
+}
+
+// RemoveStaleCacheLocks removes the locks of the cache directory left behind
+// by processes which died, along with the records of the processes which died
+// waiting for them. It returns the names of the sources whose locks were
+// removed.
+func RemoveStaleCacheLocks(cachedir string) ([]string, error) {
+	dir, err := filepath.Abs(sourceLocksDir(cachedir))
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, fi := range fis {
+		path := filepath.Join(dir, fi.Name())
+		isLock := strings.HasSuffix(fi.Name(), ".lock")
+		if !isLock && !strings.Contains(fi.Name(), ".wait.") {
+			continue
+		}
+		if _, _, alive := lockOwner(path); alive {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		if isLock {
+			removed = append(removed, strings.TrimSuffix(fi.Name(), ".lock"))
+		}
+	}
+	return removed, nil
+}
+
+// lockOwner returns the process recorded in the lock or wait file at path, if
+// any, along with its host if it isn't this one, and whether it's still
+// running. Processes of other hosts are assumed to be.
+func lockOwner(path string) (pid int, host string, alive bool) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, "", false
+	}
+	lines := strings.Split(string(b), "\n")
+	if len(lines) > 2 && lines[1] != "" && lines[1] != hostname() {
+		pid, _ = strconv.Atoi(lines[0])
+		return pid, lines[1], true
+	}
+	fmt.Sscanln(string(b), &pid)
+	_, err = lockfile.Lockfile(path).GetOwner()
+	return pid, "", err == nil && !pidReused(path)
+}
+
+// pidReused reports whether the process recorded in the lock or wait file at
+// path is running, but started after the file was written: it's not the one
+// which wrote it, but one its pid was reused for after it died, as is common
+// in containers.
+func pidReused(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var pid int
+	if _, err := fmt.Sscanln(string(b), &pid); err != nil {
+		return false
+	}
+	started, ok := processStartTime(pid)
+	// The start time is derived from the boot time, which the adjustments of
+	// the clock since skew, so leave a wide margin.
+	return ok && started.After(fi.ModTime().Add(time.Minute))
+}
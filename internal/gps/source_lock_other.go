@@ -0,0 +1,15 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package gps
+
+import "time"
+
+// processStartTime returns the time at which the process pid started, which
+// is only known on Linux.
+func processStartTime(pid int) (time.Time, bool) {
+	return time.Time{}, false
+}
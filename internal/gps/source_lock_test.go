@@ -0,0 +1,508 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSourceLockerContention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newSourceLocker(filepath.Join(dir, "locks"))
+	sources := []string{
+		filepath.Join(dir, "sources", "https---github.com-foo-bar"),
+		filepath.Join(dir, "sources", "https---github.com-foo-baz"),
+	}
+
+	// Each goroutine increments counters kept on disk, which loses updates
+	// unless the locks serialize the goroutines working on the same source.
+	const workers, rounds = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				src := sources[(i+j)%len(sources)]
+				unlock, err := l.lock(context.Background(), src)
+				if err != nil {
+					errs <- err
+					return
+				}
+				errs <- incrementCounter(src + ".count")
+				unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	total := 0
+	for _, src := range sources {
+		b, err := ioutil.ReadFile(src + ".count")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := strconv.Atoi(string(b))
+		total += n
+	}
+	if total != workers*rounds {
+		t.Errorf("expected %d increments, got %d", workers*rounds, total)
+	}
+}
+
+func incrementCounter(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	n, _ := strconv.Atoi(string(b))
+	time.Sleep(time.Millisecond)
+	return ioutil.WriteFile(path, []byte(strconv.Itoa(n+1)), 0666)
+}
+
+func TestSourceLockerOtherProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newSourceLocker(dir)
+	src := filepath.Join(dir, "sources", "https---github.com-foo-bar")
+	lockPath := filepath.Join(dir, "https---github.com-foo-bar.lock")
+
+	// The lock of a source held by a live process is waited for.
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := l.lock(ctx, src); err != context.DeadlineExceeded {
+		t.Fatalf("expected to wait for the lock held by another process, got %v", err)
+	}
+
+	// That of a process which died without releasing it is taken over.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := l.lock(context.Background(), src)
+	if err != nil {
+		t.Fatalf("expected to take over the lock of a dead process, got %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be removed once released, got %v", err)
+	}
+}
+
+func TestSourceLockerTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newSourceLocker(dir)
+	l.timeout = 100 * time.Millisecond
+	foo := filepath.Join(dir, "sources", "https---github.com-foo-foo")
+	bar := filepath.Join(dir, "sources", "https---github.com-foo-bar")
+
+	// The lock held by another goroutine is waited for until the timeout.
+	unlock, err := l.lock(context.Background(), foo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.lock(context.Background(), foo)
+	if terr, ok := err.(LockTimeoutError); !ok || terr.Holder != os.Getpid() {
+		t.Errorf("expected a timeout waiting for this process, got %v", err)
+	}
+	unlock()
+
+	// So is that held by another process.
+	lockPath := filepath.Join(dir, "https---github.com-foo-bar.lock")
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.lock(context.Background(), bar)
+	terr, ok := err.(LockTimeoutError)
+	if !ok || terr.Holder != os.Getppid() {
+		t.Fatalf("expected a timeout waiting for the parent process, got %v", err)
+	}
+	want := fmt.Sprintf("timed out after 100ms waiting for the lock on https---github.com-foo-bar, held by process %d; dep cache locks lists the locks of the cache", os.Getppid())
+	if terr.Error() != want {
+		t.Errorf("unexpected error:\n\t(GOT): %s\n\t(WNT): %s", terr, want)
+	}
+}
+
+func TestSourceLockerReusedPid(t *testing.T) {
+	if _, ok := processStartTime(os.Getpid()); !ok {
+		t.Skip("the start times of processes can't be told on this system")
+	}
+
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newSourceLocker(dir)
+	src := filepath.Join(dir, "sources", "https---github.com-foo-bar")
+	lockPath := filepath.Join(dir, "https---github.com-foo-bar.lock")
+
+	// A lock file written long before its live owner started was left by a
+	// process which died, and whose pid went to another one since.
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getppid())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-24 * 365 * time.Hour)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, alive := lockOwner(lockPath); alive {
+		t.Error("expected the owner of the lock file not to be alive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unlock, err := l.lock(ctx, src)
+	if err != nil {
+		t.Fatalf("expected to take over the lock of a reused pid, got %v", err)
+	}
+	unlock()
+}
+
+func TestSourceLockerReusedPidContention(t *testing.T) {
+	if _, ok := processStartTime(os.Getpid()); !ok {
+		t.Skip("the start times of processes can't be told on this system")
+	}
+	raceForStaleLock(t, false, fmt.Sprintf("%d\n", os.Getpid()))
+}
+
+func TestSourceLockerSharedContention(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	raceForStaleLock(t, true, fmt.Sprintf("%d\n%s\n", cmd.Process.Pid, hostname()))
+}
+
+func TestTakeOverStaleLockBreaker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lockPath := filepath.Join(dir, "https---github.com-foo-bar.lock")
+	breaker := lockPath + ".break"
+	if err := ioutil.WriteFile(lockPath, []byte("1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	stale := func(string) bool { return true }
+
+	// While another waiter holds the breaker, the lock file is left alone.
+	if err := ioutil.WriteFile(breaker, []byte(lockOwnerLine(true)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	checked, err := takeOverStaleLock(lockPath, stale)
+	if err != nil || checked {
+		t.Fatalf("expected the takeover to wait for the breaker, got %t, %v", checked, err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("expected the lock file to be left alone, got %v", err)
+	}
+	if _, err := os.Stat(breaker); err != nil {
+		t.Fatalf("expected a live breaker to be left alone, got %v", err)
+	}
+
+	// One left behind by a process which died is removed for the next try.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(breaker, []byte(fmt.Sprintf("%d\n%s\n", cmd.Process.Pid, hostname())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if checked, err := takeOverStaleLock(lockPath, stale); err != nil || checked {
+		t.Fatalf("expected the takeover to wait for the breaker, got %t, %v", checked, err)
+	}
+	if _, err := os.Stat(breaker); !os.IsNotExist(err) {
+		t.Fatalf("expected the stale breaker to be removed, got %v", err)
+	}
+
+	// A lock file which isn't stale once the breaker is held is kept.
+	checked, err = takeOverStaleLock(lockPath, func(string) bool { return false })
+	if err != nil || !checked {
+		t.Fatalf("expected the lock file to be checked, got %t, %v", checked, err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("expected the live lock file to be kept, got %v", err)
+	}
+
+	checked, err = takeOverStaleLock(lockPath, stale)
+	if err != nil || !checked {
+		t.Fatalf("expected the lock file to be checked, got %t, %v", checked, err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("expected the stale lock file to be removed, got %v", err)
+	}
+	if _, err := os.Stat(breaker); !os.IsNotExist(err) {
+		t.Errorf("expected the breaker to be released, got %v", err)
+	}
+}
+
+// raceForStaleLock has processes race to take over the same stale lock file,
+// written with the given contents long ago, and checks that they still hold
+// the lock one at a time.
+func raceForStaleLock(t *testing.T, shared bool, stale string) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for round := 0; round < 5; round++ {
+		lockPath := filepath.Join(dir, "https---github.com-foo-bar.lock")
+		if err := ioutil.WriteFile(lockPath, []byte(stale), 0666); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-24 * 365 * time.Hour)
+		if err := os.Chtimes(lockPath, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		logPath := filepath.Join(dir, "log")
+		os.Remove(logPath)
+		// Have the processes start trying at the same time.
+		start := time.Now().Add(500 * time.Millisecond).UnixNano()
+		var cmds []*exec.Cmd
+		for i := 0; i < 4; i++ {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestSourceLockerHelperProcess$")
+			cmd.Env = append(os.Environ(), "DEP_TEST_LOCK_DIR="+dir, "DEP_TEST_LOCK_LOG="+logPath,
+				fmt.Sprintf("DEP_TEST_LOCK_START=%d", start), fmt.Sprintf("DEP_TEST_LOCK_SHARED=%t", shared))
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			cmds = append(cmds, cmd)
+		}
+		for _, cmd := range cmds {
+			if err := cmd.Wait(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		b, err := ioutil.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Fields(string(b))
+		if len(lines) != 2*len(cmds) {
+			t.Fatalf("expected each process to take the lock once, got %q", lines)
+		}
+		for i := 0; i < len(lines); i += 2 {
+			if lines[i] != "+"+lines[i+1][1:] || lines[i+1][0] != '-' {
+				t.Fatalf("round %d: expected the processes to hold the lock one at a time, got %q", round, lines)
+			}
+		}
+	}
+}
+
+// TestSourceLockerHelperProcess isn't a test, but a process taking the lock of
+// a source in a test directory, for raceForStaleLock.
+func TestSourceLockerHelperProcess(t *testing.T) {
+	dir := os.Getenv("DEP_TEST_LOCK_DIR")
+	if dir == "" {
+		return
+	}
+	start, err := strconv.ParseInt(os.Getenv("DEP_TEST_LOCK_START"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Unix(0, start).Sub(time.Now()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	l := newSourceLocker(dir)
+	l.shared = os.Getenv("DEP_TEST_LOCK_SHARED") == "true"
+	unlock, err := l.lock(ctx, filepath.Join(dir, "sources", "https---github.com-foo-bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(os.Getenv("DEP_TEST_LOCK_LOG"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "+%d\n", os.Getpid())
+	time.Sleep(20 * time.Millisecond)
+	fmt.Fprintf(f, "-%d\n", os.Getpid())
+}
+
+func TestSourceLockerShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Lock files behave as on a network filesystem.
+	l := newSourceLocker(sourceLocksDir(dir))
+	l.shared = true
+	src := filepath.Join(dir, "sources", "https---github.com-foo-bar")
+	lockPath := filepath.Join(sourceLocksDir(dir), "https---github.com-foo-bar.lock")
+	if err := os.MkdirAll(sourceLocksDir(dir), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dead := cmd.Process.Pid
+
+	// Whether a process of another host is alive can't be told, so its lock
+	// is waited for.
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\nother.example.com\n", dead)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := l.lock(ctx, src); err != context.DeadlineExceeded {
+		t.Fatalf("expected to wait for the lock held by another host, got %v", err)
+	}
+	locks, err := CacheLocks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0].Holder != dead || locks[0].Host != "other.example.com" || locks[0].Stale {
+		t.Errorf("expected the lock to be reported as held by another host, got %+v", locks)
+	}
+
+	// That of a process of this host which died is taken over.
+	if err := ioutil.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n%s\n", dead, hostname())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := l.lock(context.Background(), src)
+	if err != nil {
+		t.Fatalf("expected to take over the lock of a dead process, got %v", err)
+	}
+	b, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%d\n%s\n", os.Getpid(), hostname()); string(b) != want {
+		t.Errorf("expected the lock file to record the pid and host of the process, got %q", b)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be removed once released, got %v", err)
+	}
+}
+
+func TestCacheLocks(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "srclock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	l := newSourceLocker(sourceLocksDir(cachedir))
+	held := filepath.Join(cachedir, "sources", "held")
+	busy := filepath.Join(cachedir, "sources", "busy")
+	stale := filepath.Join(sourceLocksDir(cachedir), "stale.lock")
+
+	unlock, err := l.lock(context.Background(), held)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// Hold busy on behalf of another live process, and wait for it.
+	if err := ioutil.WriteFile(filepath.Join(sourceLocksDir(cachedir), "busy.lock"), []byte(fmt.Sprintf("%d\n", os.Getppid())), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.lock(ctx, busy)
+		close(done)
+	}()
+
+	// Leave stale behind a process which died.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(stale, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var locks []CacheLock
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if locks, err = CacheLocks(cachedir); err != nil {
+			t.Fatal(err)
+		}
+		if len(locks) == 3 && len(locks[0].Waiters) == 1 {
+			break
+		}
+	}
+	cancel()
+	<-done
+
+	want := []CacheLock{
+		{Name: "busy", Holder: os.Getppid(), Waiters: []int{os.Getpid()}},
+		{Name: "held", Holder: os.Getpid()},
+		{Name: "stale", Holder: cmd.Process.Pid, Stale: true},
+	}
+	if len(locks) != len(want) {
+		t.Fatalf("expected %d locks, got %+v", len(want), locks)
+	}
+	for i, cl := range locks {
+		if cl.Name != want[i].Name || cl.Holder != want[i].Holder || cl.Stale != want[i].Stale || fmt.Sprint(cl.Waiters) != fmt.Sprint(want[i].Waiters) {
+			t.Errorf("unexpected lock:\n\t(GOT): %+v\n\t(WNT): %+v", cl, want[i])
+		}
+	}
+
+	removed, err := RemoveStaleCacheLocks(cachedir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "stale" {
+		t.Errorf("expected only the stale lock to be removed, got %v", removed)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the stale lock file to be removed, got %v", err)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,7 +21,6 @@ import (
 	"time"
 
 	"github.com/golang/dep/internal/gps/pkgtree"
-	"github.com/nightlyone/lockfile"
 	"github.com/pkg/errors"
 	"github.com/sdboyer/constext"
 )
@@ -48,6 +49,11 @@ type SourceManager interface {
 	// TODO convert to []PairedVersion
 	ListVersions(ProjectIdentifier) ([]PairedVersion, error)
 
+	// PairRef fetches a ref of the given ProjectIdentifier's source which
+	// isn't listed among its versions, as refs/pull/123/head, and returns it
+	// as a branch paired with the revision it points to.
+	PairRef(ProjectIdentifier, string) (PairedVersion, error)
+
 	// RevisionPresentIn indicates whether the provided Version is present in
 	// the given repository.
 	RevisionPresentIn(ProjectIdentifier, Revision) (bool, error)
@@ -80,6 +86,21 @@ type SourceManager interface {
 	// InferConstraint tries to puzzle out what kind of version is given in a string -
 	// semver, a revision, or as a fallback, a plain tag
 	InferConstraint(s string, pi ProjectIdentifier) (Constraint, error)
+
+	// VersionInfo returns descriptive metadata about the provided Version of
+	// a source, such as the date it was committed and any annotation attached
+	// to its tag.
+	VersionInfo(ProjectIdentifier, Version) (VersionInfo, error)
+
+	// CommitLog returns the commits of a source which are reachable from the
+	// second version but not from the first, newest first. Only the revision,
+	// author, date and message of each commit are set.
+	CommitLog(id ProjectIdentifier, from, to Version) ([]VersionInfo, error)
+
+	// RevisionAsOf returns the revision a version of a source, such as a
+	// branch, was at the given time: the last commit of its history made no
+	// later than it, or "" if there is none.
+	RevisionAsOf(id ProjectIdentifier, v Version, t time.Time) (Revision, error)
 }
 
 // A ProjectAnalyzer is responsible for analyzing a given path for Manifest and
@@ -115,18 +136,27 @@ func (p ProjectAnalyzerInfo) String() string {
 // There's no (planned) reason why it would need to be reimplemented by other
 // tools; control via dependency injection is intended to be sufficient.
 type SourceMgr struct {
-	cachedir    string                // path to root of cache dir
-	lf          *lockfile.Lockfile    // handle for the sm lock file on disk
-	suprvsr     *supervisor           // subsystem that supervises running calls/io
-	cancelAll   context.CancelFunc    // cancel func to kill all running work
-	deduceCoord *deductionCoordinator // subsystem that manages import path deduction
-	srcCoord    *sourceCoordinator    // subsystem that manages sources
-	sigmut      sync.Mutex            // mutex protecting signal handling setup/teardown
-	qch         chan struct{}         // quit chan for signal handler
-	relonce     sync.Once             // once-er to ensure we only release once
-	releasing   int32                 // flag indicating release of sm has begun
+	cachedir      string                // path to root of cache dir
+	absCachedir   string                // absolute path to root of cache dir
+	absSourcesdir string                // absolute path to root of sources dir, if not the cache dir
+	suprvsr       *supervisor           // subsystem that supervises running calls/io
+	cancelAll     context.CancelFunc    // cancel func to kill all running work
+	deduceCoord   *deductionCoordinator // subsystem that manages import path deduction
+	srcCoord      *sourceCoordinator    // subsystem that manages sources
+	sigmut        sync.Mutex            // mutex protecting signal handling setup/teardown
+	qch           chan struct{}         // quit chan for signal handler
+	relonce       sync.Once             // once-er to ensure we only release once
+	releasing     int32                 // flag indicating release of sm has begun
+	daemon        *daemonClient         // daemon serving the operations, if any
 }
 
+// activeCacheDirs are the cache directories used by the SourceMgrs of the
+// process which weren't released yet.
+var activeCacheDirs = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
 type smIsReleased struct{}
 
 func (smIsReleased) Error() string {
@@ -154,86 +184,249 @@ func NewSourceManager(cachedir string) (*SourceMgr, error) {
 		return nil, err
 	}
 
-	// Fix for #820
-	//
-	// Consult https://godoc.org/github.com/nightlyone/lockfile for the lockfile
-	// behaviour. It's magic. It deals with stale processes, and if there is
-	// a process keeping the lock busy, it will pass back a temporary error that
-	// we can spin on.
-
-	glpath := filepath.Join(cachedir, "sm.lock")
-	lockfile, err := lockfile.New(glpath)
+	abs, err := filepath.Abs(cachedir)
 	if err != nil {
+		return nil, err
+	}
+	ctx, cf := context.WithCancel(context.TODO())
+	superv := newSupervisor(ctx)
+	deducer := newDeductionCoordinator(superv)
+	srcCoord := newSourceCoordinator(superv, deducer, cachedir)
+
+	// Sources are locked one by one as they're worked on, so that several
+	// processes can share the cache directory; see sourceLocker. Within a
+	// process though, the in-memory state of the SourceMgr would go stale if
+	// another one changed the cache behind its back.
+	if err := srcCoord.locker.checkFilesystem(); err != nil {
+		cf()
+		return nil, err
+	}
+	activeCacheDirs.Lock()
+	defer activeCacheDirs.Unlock()
+	if activeCacheDirs.m[abs] {
+		cf()
 		return nil, CouldNotCreateLockError{
-			Path: glpath,
-			Err:  fmt.Errorf("unable to create lock %s: %s", glpath, err.Error()),
+			Path: abs,
+			Err:  fmt.Errorf("cache directory %s already in use by this process", abs),
 		}
 	}
+	activeCacheDirs.m[abs] = true
 
-	process, err := lockfile.GetOwner()
-	if err == nil {
-		// If we didn't get an error, then the lockfile exists already. We should
-		// check to see if it's us already:
-		if process.Pid == os.Getpid() {
-			return nil, CouldNotCreateLockError{
-				Path: glpath,
-				Err:  fmt.Errorf("lockfile %s already locked by this process", glpath),
+	sm := &SourceMgr{
+		cachedir:    cachedir,
+		absCachedir: abs,
+		suprvsr:     superv,
+		cancelAll:   cf,
+		deduceCoord: deducer,
+		srcCoord:    srcCoord,
+		qch:         make(chan struct{}),
+	}
+
+	return sm, nil
+}
+
+// UseSourcesDir makes the SourceMgr keep the local copies of sources, along
+// with their locks, in dir rather than in its cache directory, as when they
+// are kept on a network filesystem shared by several machines. Sources
+// fetched from a cache server are still kept in the cache directory. It must
+// be called before any other method.
+//
+// An error is returned if dir is on a network filesystem whose sources can't
+// be locked, or if another SourceMgr of the process already uses it.
+func (sm *SourceMgr) UseSourcesDir(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "sources"), 0777); err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	locker := newSourceLocker(sourceLocksDir(dir))
+	if err := locker.checkFilesystem(); err != nil {
+		return err
+	}
+
+	if abs != sm.absCachedir {
+		activeCacheDirs.Lock()
+		defer activeCacheDirs.Unlock()
+		if activeCacheDirs.m[abs] {
+			return CouldNotCreateLockError{
+				Path: abs,
+				Err:  fmt.Errorf("sources directory %s already in use by this process", abs),
 			}
 		}
+		activeCacheDirs.m[abs] = true
+		sm.absSourcesdir = abs
+	}
 
-		// There is a lockfile, but it's owned by someone else. We'll try to lock
-		// it anyway.
+	locker.timeout, locker.progress = sm.srcCoord.locker.timeout, sm.srcCoord.locker.progress
+	sm.srcCoord.sourcesdir = dir
+	sm.srcCoord.locker = locker
+	return nil
+}
+
+// UseLockTimeout makes the SourceMgr give up on the lock of a source held by
+// another process, or another goroutine, with a LockTimeoutError once it has
+// waited for it for the given duration, rather than waiting for as long as it
+// takes. It must be called before any other method.
+func (sm *SourceMgr) UseLockTimeout(timeout time.Duration) {
+	sm.srcCoord.locker.timeout = timeout
+}
+
+// UseLockProgress makes the SourceMgr report the waits for the locks of the
+// sources held by other processes to logger, rather than to os.Stderr. It
+// must be called before any other method.
+func (sm *SourceMgr) UseLockProgress(logger *log.Logger) {
+	sm.srcCoord.locker.progress = logger
+}
+
+// UseCacheServer makes the SourceMgr fetch version lists and source trees from
+// the cache server at the given URL, as served by NewCacheServer, rather than
+// from the upstream sources. The upstream sources are still used when the
+// cache server fails. It must be called before any other method.
+func (sm *SourceMgr) UseCacheServer(rawurl string) error {
+	c, err := newCacheServerClient(rawurl)
+	if err != nil {
+		return err
 	}
+	sm.srcCoord.remote = c
+	return nil
+}
 
-	// If it's a TemporaryError, we retry every second. Otherwise, we fail
-	// permanently.
-	//
-	// TODO: #534 needs to be implemented to provide a better way to log warnings,
-	// but until then we will just use stderr.
-
-	// Implicit Time of 0.
-	var lasttime time.Time
-	err = lockfile.TryLock()
-	for err != nil {
-		nowtime := time.Now()
-		duration := nowtime.Sub(lasttime)
-
-		// The first time this is evaluated, duration will be very large as lasttime is 0.
-		// Unless time travel is invented and someone travels back to the year 1, we should
-		// be ok.
-		if duration > 15*time.Second {
-			fmt.Fprintf(os.Stderr, "waiting for lockfile %s: %s\n", glpath, err.Error())
-			lasttime = nowtime
-		}
+// UseDaemon makes the SourceMgr delegate its operations to the daemon serving
+// NewSourceManagerDaemon on the unix socket at the given path, so that the
+// sources, locks and deductions of the daemon are shared with the other
+// processes using it. The other options of the SourceMgr are then ignored, the
+// daemon using its own, which must match the given settings. It must be called
+// before any other method.
+//
+// An error is returned if the daemon doesn't answer, and a *DaemonSettingsError
+// if it serves sources with other settings.
+func (sm *SourceMgr) UseDaemon(socket string, settings DaemonSettings) error {
+	c := newDaemonClient(socket)
+	if err := c.ping(settings); err != nil {
+		return err
+	}
+	sm.daemon = c
+	return nil
+}
 
-		if _, ok := err.(interface {
-			Temporary() bool
-		}); ok {
-			time.Sleep(time.Second * 1)
-		} else {
-			return nil, CouldNotCreateLockError{
-				Path: glpath,
-				Err:  fmt.Errorf("unable to lock %s: %s", glpath, err.Error()),
-			}
-		}
-		err = lockfile.TryLock()
+// UseCredentials makes the SourceMgr authenticate to the hosts of the given
+// credentials: git uses them over HTTPS and SSH, and they are sent along with
+// the HTTP requests for the go-get metadata of import paths and to module
+// proxies. It must be called before any other method.
+//
+// The requests refused for lack of valid credentials fail with an error telling
+// so, distinct from that of a missing source.
+func (sm *SourceMgr) UseCredentials(creds []Credential) error {
+	c, err := newCredentials(creds)
+	if err != nil {
+		return err
 	}
+	sm.suprvsr.creds = c
+	return nil
+}
 
-	ctx, cf := context.WithCancel(context.TODO())
-	superv := newSupervisor(ctx)
-	deducer := newDeductionCoordinator(superv)
+// UseProtocolPolicies restricts the protocols the sources are fetched over to
+// those the policies allow for them. The URLs deduced for the sources over
+// other protocols are rewritten to the allowed ones, and git is made to
+// rewrite those of the submodules the same way. It must be called before any
+// other method.
+func (sm *SourceMgr) UseProtocolPolicies(pp []ProtocolPolicy) error {
+	p, err := newProtocolPolicies(pp)
+	if err != nil {
+		return err
+	}
+	sm.srcCoord.protocols = p
+	sm.suprvsr.protocols = p
+	return nil
+}
 
-	sm := &SourceMgr{
-		cachedir:    cachedir,
-		lf:          &lockfile,
-		suprvsr:     superv,
-		cancelAll:   cf,
-		deduceCoord: deducer,
-		srcCoord:    newSourceCoordinator(superv, deducer, cachedir),
-		qch:         make(chan struct{}),
+// UseShallowClones makes the SourceMgr clone the git sources missing from its
+// cache shallowly, with only the tips of their branches. The commits other
+// operations need are then fetched on demand, along with the whole history of
+// the source for commit logs. The sources already in the cache are left as
+// they are. It must be called before any other method.
+func (sm *SourceMgr) UseShallowClones() {
+	sm.srcCoord.shallow = true
+}
+
+// UseOffline forbids all network access: sources are only served from their
+// copies in the cache directory, and their version lists from the persistent
+// cache, if any. The operations which would need the network fail instead,
+// and the URLs they would have contacted are reported by OfflineDenied.
+//
+// It must be called before any use of the SourceMgr.
+func (sm *SourceMgr) UseOffline() {
+	sm.suprvsr.offline = true
+}
+
+// OfflineDenied returns the URLs, sorted, which the SourceMgr would have
+// contacted so far if it weren't offline.
+func (sm *SourceMgr) OfflineDenied() []string {
+	sup := sm.suprvsr
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	urls := make([]string, 0, len(sup.denied))
+	for u := range sup.denied {
+		urls = append(urls, u)
 	}
+	sort.Strings(urls)
+	return urls
+}
 
-	return sm, nil
+// UseModuleProxy makes the SourceMgr fetch the version lists and source trees
+// of import paths from the Go module proxies of the given comma-separated list,
+// as set in GOPROXY, rather than from the upstream sources. The proxies are
+// tried in order, and the upstream sources are only used when the list ends
+// with "direct", once all the proxies failed. Only the tagged versions of the
+// projects, and the revisions the proxies resolve to pseudo-versions, are
+// available from proxies. It must be called before any other method.
+func (sm *SourceMgr) UseModuleProxy(list string) error {
+	proxies, direct, err := parseModuleProxies(list)
+	if err != nil {
+		return err
+	}
+	sm.srcCoord.proxies = proxies
+	sm.srcCoord.direct = direct
+	return nil
+}
+
+// UsePersistentCache makes the SourceMgr keep the version lists, package trees,
+// manifests and locks of the sources in a database of its cache directory, so
+// that later SourceMgrs needn't reach out to the sources again. Version lists
+// are considered fresh for the given age; the rest never goes stale, being
+// keyed by revision. It must be called before any other method.
+//
+// The database is locked for as long as the SourceMgr is in use, and an error
+// is returned if another process holds it.
+func (sm *SourceMgr) UsePersistentCache(age time.Duration) error {
+	c, err := newBoltCache(sm.cachedir, age)
+	if err != nil {
+		return err
+	}
+	sm.srcCoord.disk = c
+	return nil
+}
+
+// RefreshVersionLists makes the SourceMgr list the versions of the sources
+// anew, however fresh their lists in the persistent cache are, and store the
+// new lists there, as an update wants the newest versions. It must be called
+// after UsePersistentCache, and before any other method.
+func (sm *SourceMgr) RefreshVersionLists() {
+	if sm.srcCoord.disk != nil {
+		// Lists are stored to the second, so those of this very second go
+		// stale too; the ones listed from now on are kept in memory anyway.
+		sm.srcCoord.disk.epoch = time.Now().Unix() + 1
+	}
+}
+
+// UseSourceOverrides makes the SourceMgr deduce the import paths matching the
+// prefix of one of the given overrides to the git repository of that override,
+// the longest prefix winning. It must be called before any other method.
+func (sm *SourceMgr) UseSourceOverrides(overrides []SourceOverride) error {
+	return sm.deduceCoord.setOverrides(overrides)
 }
 
 // UseDefaultSignalHandling sets up typical os.Interrupt signal handling for a
@@ -327,8 +520,8 @@ func (sm *SourceMgr) StopSignalHandling() {
 }
 
 // CouldNotCreateLockError describe failure modes in which creating a SourceMgr
-// did not succeed because there was an error while attempting to create the
-// on-disk lock file.
+// did not succeed because the cache directory could not be locked for it, as
+// when another SourceMgr of the process already uses it.
 type CouldNotCreateLockError struct {
 	Path string
 	Err  error
@@ -362,9 +555,18 @@ func (sm *SourceMgr) doRelease() {
 	sm.cancelAll()
 	sm.suprvsr.wait()
 
-	// Close the file handle for the lock file and remove it from disk
-	sm.lf.Unlock()
-	os.Remove(filepath.Join(sm.cachedir, "sm.lock"))
+	if sm.srcCoord.disk != nil {
+		sm.srcCoord.disk.close()
+	}
+	sm.suprvsr.creds.release()
+
+	// Let other SourceMgrs of the process use the cache directory.
+	activeCacheDirs.Lock()
+	delete(activeCacheDirs.m, sm.absCachedir)
+	if sm.absSourcesdir != "" {
+		delete(activeCacheDirs.m, sm.absSourcesdir)
+	}
+	activeCacheDirs.Unlock()
 
 	// Close the qch, if non-nil, so the signal handlers run out. This will
 	// also deregister the sig channel, if any has been set up.
@@ -381,6 +583,9 @@ func (sm *SourceMgr) GetManifestAndLock(id ProjectIdentifier, v Version, an Proj
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return nil, nil, smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.getManifestAndLock(id, v, an)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -396,6 +601,9 @@ func (sm *SourceMgr) ListPackages(id ProjectIdentifier, v Version) (pkgtree.Pack
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return pkgtree.PackageTree{}, smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.listPackages(id, v)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -421,6 +629,9 @@ func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error)
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return nil, smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.listVersions(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -431,12 +642,42 @@ func (sm *SourceMgr) ListVersions(id ProjectIdentifier) ([]PairedVersion, error)
 	return srcg.listVersions(context.TODO())
 }
 
+// PairRef fetches a ref of the given ProjectIdentifier's source which isn't
+// listed among its versions, and returns it as a branch paired with the
+// revision it points to. Only git sources support refs other than branches
+// and tags, such as the refs/pull/123/head of a GitHub pull request; see
+// IsRefName.
+//
+// Refs are fetched into the local copy of the source once per SourceMgr, so
+// that the revisions they point to can be checked out and exported.
+func (sm *SourceMgr) PairRef(id ProjectIdentifier, ref string) (PairedVersion, error) {
+	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
+		return nil, smIsReleased{}
+	}
+	if !IsRefName(ref) {
+		return nil, fmt.Errorf("%s is not the name of a ref outside refs/heads and refs/tags", ref)
+	}
+	if sm.daemon != nil {
+		return sm.daemon.pairRef(id, ref)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return srcg.pairRef(context.TODO(), ref)
+}
+
 // RevisionPresentIn indicates whether the provided Revision is present in the given
 // repository.
 func (sm *SourceMgr) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return false, smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.revisionPresentIn(id, r)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -453,6 +694,9 @@ func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return false, smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.sourceExists(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -471,6 +715,9 @@ func (sm *SourceMgr) SyncSourceFor(id ProjectIdentifier) error {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.syncSourceFor(id)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -486,6 +733,9 @@ func (sm *SourceMgr) ExportProject(id ProjectIdentifier, v Version, to string) e
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.exportProject(id, v, to)
+	}
 
 	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
 	if err != nil {
@@ -495,6 +745,67 @@ func (sm *SourceMgr) ExportProject(id ProjectIdentifier, v Version, to string) e
 	return srcg.exportVersionTo(context.TODO(), v, to)
 }
 
+// VersionInfo returns descriptive metadata about the provided Version of the
+// given ProjectIdentifier's source.
+//
+// The commit date of the underlying revision is always populated. If the
+// version is a tag and the underlying VCS supports annotated tags, the tag's
+// message and date are also reported.
+func (sm *SourceMgr) VersionInfo(id ProjectIdentifier, v Version) (VersionInfo, error) {
+	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
+		return VersionInfo{}, smIsReleased{}
+	}
+	if sm.daemon != nil {
+		return sm.daemon.versionInfo(id, v)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+
+	return srcg.versionInfo(context.TODO(), v)
+}
+
+// CommitLog returns the commits of the given ProjectIdentifier's source which
+// are reachable from the to version but not from the from version, newest
+// first. Only the revision, author, date and message of each commit are set.
+func (sm *SourceMgr) CommitLog(id ProjectIdentifier, from, to Version) ([]VersionInfo, error) {
+	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
+		return nil, smIsReleased{}
+	}
+	if sm.daemon != nil {
+		return sm.daemon.commitLog(id, from, to)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return srcg.commitLog(context.TODO(), from, to)
+}
+
+// RevisionAsOf returns the revision the provided Version of the given
+// ProjectIdentifier's source was at the given time: the last commit of its
+// history, along first parents, made no later than it, or "" if there is none.
+// Only git sources support it.
+func (sm *SourceMgr) RevisionAsOf(id ProjectIdentifier, v Version, t time.Time) (Revision, error) {
+	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
+		return "", smIsReleased{}
+	}
+	if sm.daemon != nil {
+		return sm.daemon.revisionAsOf(id, v, t)
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return "", err
+	}
+
+	return srcg.revisionAsOf(context.TODO(), v, t)
+}
+
 // DeduceProjectRoot takes an import path and deduces the corresponding
 // project/source root.
 //
@@ -506,6 +817,9 @@ func (sm *SourceMgr) DeduceProjectRoot(ip string) (ProjectRoot, error) {
 	if atomic.CompareAndSwapInt32(&sm.releasing, 1, 1) {
 		return "", smIsReleased{}
 	}
+	if sm.daemon != nil {
+		return sm.daemon.deduceProjectRoot(ip)
+	}
 
 	pd, err := sm.deduceCoord.deduceRootPath(context.TODO(), ip)
 	return ProjectRoot(pd.root), err
@@ -519,6 +833,15 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 		return Any(), nil
 	}
 
+	// Refs other than branches and tags aren't listed among the versions, so
+	// only check that they can be fetched.
+	if IsRefName(s) {
+		if _, err := sm.PairRef(pi, s); err != nil {
+			return nil, errors.Wrapf(err, "fetch %s of %s(%s)", s, pi.ProjectRoot, pi.Source)
+		}
+		return NewBranch(s), nil
+	}
+
 	slen := len(s)
 	if slen == 40 {
 		if _, err := hex.DecodeString(s); err == nil {
@@ -581,6 +904,163 @@ func (sm *SourceMgr) InferConstraint(s string, pi ProjectIdentifier) (Constraint
 	return nil, errors.Errorf("%s is not a valid version for the package %s(%s)", s, pi.ProjectRoot, pi.Source)
 }
 
+// VersionInfo holds descriptive metadata about a single version of a source.
+type VersionInfo struct {
+	// Revision is the underlying revision of the version.
+	Revision Revision
+	// Author is the author of the underlying revision.
+	Author string
+	// Date is the time at which the underlying revision was committed.
+	Date time.Time
+	// Message is the commit message of the underlying revision.
+	Message string
+	// TagDate is the time at which the tag was created. It is zero for
+	// anything other than annotated tags.
+	TagDate time.Time
+	// Annotation is the message attached to an annotated tag. It is empty for
+	// anything other than annotated tags.
+	Annotation string
+}
+
+// ReleaseDate returns the best known date at which the version was published:
+// the tag date for annotated tags, and the commit date otherwise.
+func (vi VersionInfo) ReleaseDate() time.Time {
+	if !vi.TagDate.IsZero() {
+		return vi.TagDate
+	}
+	return vi.Date
+}
+
+// SourceMgrStats sums up the work done by a SourceMgr, for diagnostics.
+type SourceMgrStats struct {
+	// Deduction covers the HTTP requests made to deduce the roots of import
+	// paths.
+	Deduction CallStats
+	// ListVersions covers listing the versions of sources upstream.
+	ListVersions CallStats
+	// Fetch covers cloning and updating sources, and fetching them from a
+	// cache server.
+	Fetch CallStats
+	// Analysis covers reading the manifests, locks, packages and version
+	// information of sources.
+	Analysis CallStats
+	// Export covers writing out the trees of sources, as for vendor/.
+	Export CallStats
+
+	// Fetched is the number of sources which had to be fetched, and
+	// FetchedBytes their size on disk. CacheHits is the number of sources
+	// found in the cache directory instead.
+	Fetched, CacheHits int
+	FetchedBytes       int64
+
+	// Network is the usage of the network, keyed by the URL of the source,
+	// deduction endpoint or cache server contacted.
+	Network map[string]NetworkUsage
+}
+
+// NetworkUsage sums up the contacts made with an upstream URL.
+type NetworkUsage struct {
+	// Contacts is the number of times the URL was contacted, and Bytes the
+	// number of bytes known to have been downloaded from it. Bytes is an
+	// estimate for VCS sources, which are measured by the growth of their
+	// local copy.
+	Contacts int
+	Bytes    int64
+}
+
+// CallStats sums up calls of a given kind. Concurrent calls on the same
+// source are timed as one.
+type CallStats struct {
+	Count int
+	Time  time.Duration
+}
+
+func (cs *CallStats) add(dc durCount) {
+	cs.Count += dc.count
+	cs.Time += dc.dur
+}
+
+// Stats returns the work done by the SourceMgr so far.
+func (sm *SourceMgr) Stats() SourceMgrStats {
+	sup := sm.suprvsr
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	st := SourceMgrStats{
+		Fetched:      sup.fetched,
+		CacheHits:    sup.cacheHits,
+		FetchedBytes: sup.fetchedBytes,
+		Network:      make(map[string]NetworkUsage, len(sup.network)),
+	}
+	for u, nu := range sup.network {
+		st.Network[u] = nu
+	}
+	for typ, dc := range sup.ran {
+		switch typ {
+		case ctHTTPMetadata:
+			st.Deduction.add(dc)
+		case ctListVersions, ctSourcePing:
+			st.ListVersions.add(dc)
+		case ctSourceInit, ctSourceFetch, ctCacheServer:
+			st.Fetch.add(dc)
+		case ctGetManifestAndLock, ctListPackages, ctCheckoutVersion, ctVersionInfo, ctCommitLog:
+			st.Analysis.add(dc)
+		case ctExportTree:
+			st.Export.add(dc)
+		}
+	}
+	return st
+}
+
+// RunningCall is a call a SourceMgr is running on a source.
+type RunningCall struct {
+	// Kind is what the call does: deduce, check, clone, fetch, list, download,
+	// analyze or export.
+	Kind string
+	// Name is what the call is on: the URL of a source, along with the
+	// revision or version worked on for some kinds of calls, or the import
+	// path whose source is deduced.
+	Name string
+	// Started is when the first of the identical calls running started, and
+	// Count their number.
+	Started time.Time
+	Count   int
+}
+
+// Running returns the calls the SourceMgr is running, from the longest
+// running one on. The calls delegated to a dep daemon aren't included.
+func (sm *SourceMgr) Running() []RunningCall {
+	sup := sm.suprvsr
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	calls := make([]RunningCall, 0, len(sup.running))
+	for ci, tc := range sup.running {
+		calls = append(calls, RunningCall{
+			Kind:    ci.typ.kind(),
+			Name:    ci.name,
+			Started: tc.start,
+			Count:   tc.count,
+		})
+	}
+	sort.Sort(byStart(calls))
+	return calls
+}
+
+type byStart []RunningCall
+
+func (s byStart) Len() int      { return len(s) }
+func (s byStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool {
+	if !s[i].Started.Equal(s[j].Started) {
+		return s[i].Started.Before(s[j].Started)
+	}
+	if s[i].Kind != s[j].Kind {
+		return s[i].Kind < s[j].Kind
+	}
+	return s[i].Name < s[j].Name
+}
+
 type timeCount struct {
 	count int
 	start time.Time
@@ -598,6 +1078,21 @@ type supervisor struct {
 	cond       sync.Cond  // Wraps mu so callers can wait until all calls end
 	running    map[callInfo]timeCount
 	ran        map[callType]durCount
+
+	// Counters of the sources needed locally, reported by SourceMgr.Stats().
+	fetched, cacheHits int
+	fetchedBytes       int64
+	network            map[string]NetworkUsage
+
+	// offline forbids all network access; denied holds the URLs which would
+	// have been contacted otherwise.
+	offline bool
+	denied  map[string]bool
+
+	// creds are passed down to the calls in their context, if set.
+	creds *credentials
+	// protocols are passed down to the calls in their context, if set.
+	protocols *protocolPolicies
 }
 
 func newSupervisor(ctx context.Context) *supervisor {
@@ -607,6 +1102,7 @@ func newSupervisor(ctx context.Context) *supervisor {
 		cancelFunc: cf,
 		running:    make(map[callInfo]timeCount),
 		ran:        make(map[callType]durCount),
+		network:    make(map[string]NetworkUsage),
 	}
 
 	supv.cond = sync.Cond{L: &supv.mu}
@@ -628,6 +1124,12 @@ func (sup *supervisor) do(inctx context.Context, name string, typ callType, f fu
 	}
 
 	cctx, cancelFunc := constext.Cons(inctx, octx)
+	if sup.creds != nil {
+		cctx = withCredentials(cctx, sup.creds)
+	}
+	if sup.protocols != nil {
+		cctx = withProtocolPolicies(cctx, sup.protocols)
+	}
 	err = f(cctx)
 	sup.done(ci)
 	cancelFunc()
@@ -694,6 +1196,47 @@ func (sup *supervisor) done(ci callInfo) {
 	sup.mu.Unlock()
 }
 
+// recordSource records that a source was needed locally, and whether it had
+// to be fetched, taking up the given number of bytes, or was already cached.
+func (sup *supervisor) recordSource(fetched bool, bytes int64) {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	if fetched {
+		sup.fetched++
+		sup.fetchedBytes += bytes
+	} else {
+		sup.cacheHits++
+	}
+}
+
+// recordNetwork records a contact with the given upstream URL, which
+// downloaded the given number of bytes.
+func (sup *supervisor) recordNetwork(url string, bytes int64) {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	nu := sup.network[url]
+	nu.Contacts++
+	if bytes > 0 {
+		nu.Bytes += bytes
+	}
+	sup.network[url] = nu
+}
+
+// denyNetwork records that url would have been contacted, were the network
+// not forbidden, and returns the error for it.
+func (sup *supervisor) denyNetwork(url string) error {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	if sup.denied == nil {
+		sup.denied = make(map[string]bool)
+	}
+	sup.denied[url] = true
+	return offlineError{url: url}
+}
+
 // wait until all active calls have terminated.
 //
 // Assumes something else has already canceled the supervisor via its context.
@@ -717,8 +1260,33 @@ const (
 	ctSourceFetch
 	ctCheckoutVersion
 	ctExportTree
+	ctVersionInfo
+	ctCacheServer
+	ctCommitLog
 )
 
+// kind returns the kind of the calls of type ct, as reported by
+// SourceMgr.Running.
+func (ct callType) kind() string {
+	switch ct {
+	case ctHTTPMetadata:
+		return "deduce"
+	case ctSourcePing:
+		return "check"
+	case ctSourceInit:
+		return "clone"
+	case ctSourceFetch:
+		return "fetch"
+	case ctListVersions:
+		return "list"
+	case ctCacheServer:
+		return "download"
+	case ctExportTree:
+		return "export"
+	}
+	return "analyze"
+}
+
 // callInfo provides metadata about an ongoing call.
 type callInfo struct {
 	name string
@@ -0,0 +1,44 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "fmt"
+
+// Strategy is the order in which the solver tries the versions of a project
+// allowed by the constraints on it.
+type Strategy int
+
+const (
+	// PreferNewest tries the newest versions first. It is the default.
+	PreferNewest Strategy = iota
+
+	// PreferMinimal tries the oldest versions first, so that each project is
+	// solved to the lowest version satisfying all the constraints on it. This
+	// is useful to check that the lower bounds of the constraints are honest,
+	// or to reproduce bugs against them.
+	PreferMinimal
+)
+
+// ParseStrategy returns the Strategy of the given name: prefer-newest or
+// prefer-minimal.
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "prefer-newest":
+		return PreferNewest, nil
+	case "prefer-minimal":
+		return PreferMinimal, nil
+	}
+	return PreferNewest, fmt.Errorf("unknown solver strategy %q, expected prefer-newest or prefer-minimal", name)
+}
+
+func (s Strategy) String() string {
+	switch s {
+	case PreferNewest:
+		return "prefer-newest"
+	case PreferMinimal:
+		return "prefer-minimal"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
@@ -0,0 +1,23 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "testing"
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range []Strategy{PreferNewest, PreferMinimal} {
+		got, err := ParseStrategy(s.String())
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", s, err)
+		}
+		if got != s {
+			t.Errorf("expected %s to parse to itself, got %s", s, got)
+		}
+	}
+
+	if _, err := ParseStrategy("prefer-oldest"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
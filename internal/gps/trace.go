@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/dep/internal/gps/pkgtree"
 )
@@ -22,6 +23,7 @@ const (
 )
 
 func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
+	s.traceEvent(traceEventFor(TraceRevisit, bmi.id, bmi.pl), len(s.vqs)+1)
 	if s.tl == nil {
 		return
 	}
@@ -31,6 +33,14 @@ func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
 }
 
 func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bool, offset int) {
+	if s.te != nil {
+		ev := traceEventFor(TraceAttempt, bmi.id, bmi.pl)
+		if cont {
+			ev.Event = TraceContinue
+		}
+		ev.Versions = len(q.pi)
+		s.traceEvent(ev, len(s.vqs)+offset)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -59,6 +69,12 @@ func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bo
 // traceStartBacktrack is called with the bmi that first failed, thus initiating
 // backtracking
 func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly bool) {
+	if s.te != nil {
+		ev := traceEventFor(TraceBacktrackStart, bmi.id, bmi.pl)
+		ev.PackagesOnly = pkgonly
+		ev.Error = err.Error()
+		s.traceEvent(ev, len(s.sel.projects))
+	}
 	if s.tl == nil {
 		return
 	}
@@ -77,6 +93,11 @@ func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly b
 // traceBacktrack is called when a package or project is poppped off during
 // backtracking
 func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
+	if s.te != nil {
+		ev := traceEventFor(TraceBacktrack, bmi.id, bmi.pl)
+		ev.PackagesOnly = pkgonly
+		s.traceEvent(ev, len(s.sel.projects))
+	}
 	if s.tl == nil {
 		return
 	}
@@ -94,6 +115,15 @@ func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
 
 // Called just once after solving has finished, whether success or not
 func (s *solver) traceFinish(sol solution, err error) {
+	if s.te != nil {
+		ev := TraceEvent{Event: TraceFinish, Segments: s.mtr.times}
+		if err == nil {
+			ev.Projects = len(sol.Projects())
+		} else {
+			ev.Error = err.Error()
+		}
+		s.traceEvent(ev, len(s.sel.projects))
+	}
 	if s.tl == nil {
 		return
 	}
@@ -111,6 +141,7 @@ func (s *solver) traceFinish(sol solution, err error) {
 
 // traceSelectRoot is called just once, when the root project is selected
 func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep) {
+	s.traceEvent(TraceEvent{Event: TraceRoot, Project: string(s.rd.rpt.ImportRoot)}, 0)
 	if s.tl == nil {
 		return
 	}
@@ -134,6 +165,12 @@ func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep)
 
 // traceSelect is called when an atom is successfully selected
 func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
+	if s.te != nil {
+		ev := traceEventFor(TraceSelect, awp.a.id, awp.pl)
+		ev.Version = awp.a.v.String()
+		ev.PackagesOnly = pkgonly
+		s.traceEvent(ev, len(s.sel.projects)-1)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -149,6 +186,34 @@ func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceTry is called when a version of a project is tried
+func (s *solver) traceTry(id ProjectIdentifier, v Version) {
+	if s.te != nil {
+		ev := traceEventFor(TraceTry, id, nil)
+		ev.Version = v.String()
+		s.traceEvent(ev, len(s.sel.projects))
+	}
+	s.traceInfo("try %s@%s", id.errString(), v)
+}
+
+// traceCheck is called when the constraint checks on an atom are done, with
+// the name of the check which failed, if any
+func (s *solver) traceCheck(awp atomWithPackages, pkgonly bool, step string, took time.Duration, err error) {
+	if s.te == nil {
+		return
+	}
+
+	ev := traceEventFor(TraceCheck, awp.a.id, awp.pl)
+	ev.Version = awp.a.v.String()
+	ev.PackagesOnly = pkgonly
+	ev.Took = took
+	if err != nil {
+		ev.Check = step
+		ev.Error = err.Error()
+	}
+	s.traceEvent(ev, len(s.sel.projects))
+}
+
 func (s *solver) traceInfo(args ...interface{}) {
 	if s.tl == nil {
 		return
@@ -0,0 +1,97 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "time"
+
+// The kinds of TraceEvent.
+const (
+	// TraceRoot is the selection of the root project, which starts the solve.
+	TraceRoot = "root"
+	// TraceAttempt is the start of the search for a version of a project, and
+	// TraceContinue its resumption while backtracking.
+	TraceAttempt  = "attempt"
+	TraceContinue = "continue"
+	// TraceRevisit is the attempt to add packages to a selected project.
+	TraceRevisit = "revisit"
+	// TraceTry is the attempt of a version of a project.
+	TraceTry = "try"
+	// TraceCheck is a run of the constraint checks on a version of a project,
+	// which failed if the event has an Error.
+	TraceCheck = "check"
+	// TraceSelect is the selection of a version of a project, or of more of
+	// its packages.
+	TraceSelect = "select"
+	// TraceBacktrackStart is the failure which starts a backtrack, and
+	// TraceBacktrack the unselection of a project, or of some of its
+	// packages, while backtracking.
+	TraceBacktrackStart = "backtrack-start"
+	TraceBacktrack      = "backtrack"
+	// TraceFinish is the end of the solve, which failed if the event has an
+	// Error.
+	TraceFinish = "finish"
+)
+
+// TraceEvent is an event of a solve, as written to the TraceEvents of the
+// SolveParameters, one JSON object per line. Durations are in nanoseconds.
+type TraceEvent struct {
+	Event string `json:"event"`
+	// Elapsed is the time since the start of the solve.
+	Elapsed time.Duration `json:"elapsed"`
+	// Depth is the number of projects selected, or being, at the time of the
+	// event, as shown in the text trace.
+	Depth int `json:"depth"`
+	// Attempts is the number of attempts made so far, as counted by
+	// Solution.Attempts.
+	Attempts int `json:"attempts"`
+
+	Project string `json:"project,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Packages are the packages of the project concerned by the event.
+	Packages []string `json:"packages,omitempty"`
+	// PackagesOnly tells events concerning only packages of a project already
+	// selected.
+	PackagesOnly bool `json:"packages_only,omitempty"`
+	// Versions is the number of versions left to try, for TraceAttempt and
+	// TraceContinue events.
+	Versions int `json:"versions,omitempty"`
+	// Projects is the number of projects of the solution, for TraceFinish
+	// events.
+	Projects int `json:"projects,omitempty"`
+
+	// Took is the time spent on the constraint checks, for TraceCheck events.
+	Took time.Duration `json:"took,omitempty"`
+	// Check names the constraint check which failed, for TraceCheck events.
+	Check string `json:"check,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	// Segments is the time spent in each segment of the solver, for
+	// TraceFinish events, as dumped after the text trace.
+	Segments map[string]time.Duration `json:"segments,omitempty"`
+}
+
+// traceEvent writes ev to the trace events, if they are wanted, along with the
+// state of the solve.
+func (s *solver) traceEvent(ev TraceEvent, depth int) {
+	if s.te == nil {
+		return
+	}
+
+	ev.Elapsed = time.Since(s.start)
+	ev.Depth = depth
+	ev.Attempts = s.attempts
+	// The trace is a best effort, and mustn't fail the solve.
+	s.te.Encode(ev)
+}
+
+// traceEventFor returns an event concerning the project identified by id.
+func traceEventFor(event string, id ProjectIdentifier, pl []string) TraceEvent {
+	ev := TraceEvent{Event: event, Project: string(id.ProjectRoot), Packages: pl}
+	if id.Source != "" && id.Source != string(id.ProjectRoot) {
+		ev.Source = id.Source
+	}
+	return ev
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestTraceEvents(t *testing.T) {
+	fix := basicFixtures["backjump past failed package on disjoint constraint"]
+	var buf bytes.Buffer
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		Lock:            dummyLock{},
+		ProjectAnalyzer: naiveAnalyzer{},
+		TraceEvents:     &buf,
+	}
+	soln, err := fixSolve(params, newdepspecSM(fix.ds, nil), t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []TraceEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var ev TraceEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) < 2 || events[0].Event != TraceRoot || events[len(events)-1].Event != TraceFinish {
+		t.Fatalf("expected the events to go from root to finish, got %+v", events)
+	}
+
+	// a 2.0.0 is tried first, and rejected as its constraint on foo allows
+	// none of its versions.
+	var rejected, backtracked bool
+	for i, ev := range events {
+		if i > 0 && ev.Elapsed < events[i-1].Elapsed {
+			t.Errorf("event %d went back in time: %+v", i, ev)
+		}
+		switch ev.Event {
+		case TraceCheck:
+			if ev.Project == "foo" && ev.Check == "atom-allowable" && ev.Error != "" {
+				rejected = true
+			}
+		case TraceBacktrack:
+			if ev.Project == "a" {
+				backtracked = true
+			}
+		}
+	}
+	if !rejected || !backtracked {
+		t.Errorf("expected foo to be rejected and a to be backtracked over, got %+v", events)
+	}
+
+	finish := events[len(events)-1]
+	if finish.Error != "" || finish.Projects != 2 || finish.Attempts != soln.Attempts() || len(finish.Segments) == 0 {
+		t.Errorf("unexpected finish event %+v", finish)
+	}
+}
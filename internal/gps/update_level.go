@@ -0,0 +1,78 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// UpdateLevel limits how far the projects locked to a semantic version may be
+// updated, whatever their constraints allow.
+type UpdateLevel int
+
+const (
+	// UpdateAny leaves updates to the constraints alone. It is the default.
+	UpdateAny UpdateLevel = iota
+
+	// UpdateMinor only allows the versions of the locked major version, no
+	// lower than the locked one.
+	UpdateMinor
+
+	// UpdatePatch only allows the versions of the locked minor version, no
+	// lower than the locked one.
+	UpdatePatch
+)
+
+func (l UpdateLevel) String() string {
+	switch l {
+	case UpdateAny:
+		return "any"
+	case UpdateMinor:
+		return "minor"
+	case UpdatePatch:
+		return "patch"
+	}
+	return "unknown"
+}
+
+// withinUpdateLevel filters vl down to the versions the locked version of the
+// project may be updated to at the update level of the solve. The versions of
+// projects which aren't locked to a semantic version are left alone, and the
+// locked version is always kept.
+func (b *bridge) withinUpdateLevel(id ProjectIdentifier, vl []PairedVersion) []PairedVersion {
+	lp, has := b.s.rd.rlm[id.ProjectRoot]
+	if !has {
+		return vl
+	}
+	lockedPair, ok := lp.Version().(PairedVersion)
+	if !ok {
+		return vl
+	}
+	lsv, ok := lockedPair.Unpair().(semVersion)
+	if !ok {
+		return vl
+	}
+
+	b.s.mtr.push("b-within-update-level")
+	defer b.s.mtr.pop()
+
+	within := make([]PairedVersion, 0, len(vl)+1)
+	seen := false
+	for _, v := range vl {
+		if v.Type() == lockedPair.Type() && v.String() == lockedPair.String() {
+			within = append(within, v)
+			seen = true
+			continue
+		}
+		sv, ok := v.Unpair().(semVersion)
+		if !ok || sv.sv.LessThan(lsv.sv) || sv.sv.Major() != lsv.sv.Major() {
+			continue
+		}
+		if b.s.rd.updateLevel == UpdatePatch && sv.sv.Minor() != lsv.sv.Minor() {
+			continue
+		}
+		within = append(within, v)
+	}
+	if !seen {
+		within = append(within, lockedPair)
+	}
+	return within
+}
@@ -44,7 +44,7 @@ func getVCSRepo(s vcs.Type, ustr, path string) (r ctxRepo, err error) {
 	case vcs.Git:
 		var repo *vcs.GitRepo
 		repo, err = vcs.NewGitRepo(ustr, path)
-		r = &gitRepo{repo}
+		r = &gitRepo{GitRepo: repo}
 	case vcs.Bzr:
 		var repo *vcs.BzrRepo
 		repo, err = vcs.NewBzrRepo(ustr, path)
@@ -63,6 +63,9 @@ func getVCSRepo(s vcs.Type, ustr, path string) (r ctxRepo, err error) {
 
 type gitRepo struct {
 	*vcs.GitRepo
+	// shallow makes get clone only the tips of the branches of the repository,
+	// the rest of its history being fetched on demand.
+	shallow bool
 }
 
 func newVcsRemoteErrorOr(msg string, err error, out string) error {
@@ -80,8 +83,15 @@ func newVcsLocalErrorOr(msg string, err error, out string) error {
 }
 
 func (r *gitRepo) get(ctx context.Context) error {
-	out, err := runFromCwd(ctx, expensiveCmdTimeout, "git", "clone", "--recursive", "-v", "--progress", r.Remote(), r.LocalPath())
+	args := []string{"clone", "--recursive", "-v", "--progress"}
+	if r.shallow {
+		args = append(args, "--depth=1", "--no-single-branch")
+	}
+	out, err := runFromCwd(ctx, expensiveCmdTimeout, "git", append(args, r.Remote(), r.LocalPath())...)
 	if err != nil {
+		if isGitAuthFailure(out) {
+			return gitAuthFailure{remote: r.Remote(), out: string(out)}
+		}
 		return newVcsRemoteErrorOr("unable to get repository", err, string(out))
 	}
 
@@ -89,16 +99,67 @@ func (r *gitRepo) get(ctx context.Context) error {
 }
 
 func (r *gitRepo) fetch(ctx context.Context) error {
-	// Perform a fetch to make sure everything is up to date.
-	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", "fetch", "--tags", "--prune", r.RemoteLocation)
+	// Perform a fetch to make sure everything is up to date. A shallow clone
+	// is kept shallow, only getting the new tips.
+	args := []string{"fetch", "--tags", "--prune"}
+	if r.isShallow() {
+		args = append(args, "--depth=1")
+	}
+	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", append(args, r.RemoteLocation)...)
 	if err != nil {
+		if isGitAuthFailure(out) {
+			return gitAuthFailure{remote: r.Remote(), out: string(out)}
+		}
 		return newVcsRemoteErrorOr("unable to update repository", err, string(out))
 	}
 	return nil
 }
 
+// isShallow checks whether the local copy is a shallow clone, whose history is
+// only partly there.
+func (r *gitRepo) isShallow() bool {
+	_, err := os.Stat(filepath.Join(r.LocalPath(), ".git", "shallow"))
+	return err == nil
+}
+
+// ensureCommit makes sure the commit rev is in a shallow clone, fetching it
+// alone if it's missing, or the whole history if the remote doesn't allow
+// fetching commits by hash. Full clones are left alone.
+func (r *gitRepo) ensureCommit(ctx context.Context, rev string) error {
+	if !r.isShallow() {
+		return nil
+	}
+	if _, err := runFromRepoDir(ctx, r, defaultCmdTimeout, "git", "cat-file", "-e", "--", rev+"^{commit}"); err == nil {
+		return nil
+	}
+
+	if _, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", "fetch", "--depth=1", "--", r.RemoteLocation, rev); err == nil {
+		return nil
+	}
+	return r.unshallow(ctx)
+}
+
+// unshallow fetches the whole history of a shallow clone, turning it into a
+// full one.
+func (r *gitRepo) unshallow(ctx context.Context) error {
+	if !r.isShallow() {
+		return nil
+	}
+	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", "fetch", "--unshallow", "--tags", r.RemoteLocation)
+	if err != nil {
+		if isGitAuthFailure(out) {
+			return gitAuthFailure{remote: r.Remote(), out: string(out)}
+		}
+		return newVcsRemoteErrorOr("unable to fetch the history of the repository", err, string(out))
+	}
+	return nil
+}
+
 func (r *gitRepo) updateVersion(ctx context.Context, v string) error {
-	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", "checkout", v)
+	if err := r.ensureCommit(ctx, v); err != nil {
+		return err
+	}
+	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "git", "checkout", v, "--")
 	if err != nil {
 		return newVcsLocalErrorOr("Unable to update checked out version", err, string(out))
 	}
@@ -190,7 +251,7 @@ func (r *hgRepo) fetch(ctx context.Context) error {
 }
 
 func (r *hgRepo) updateVersion(ctx context.Context, version string) error {
-	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "hg", "update", version)
+	out, err := runFromRepoDir(ctx, r, expensiveCmdTimeout, "hg", "update", "-r", version)
 	if err != nil {
 		return newVcsRemoteErrorOr("unable to update checked out version", err, string(out))
 	}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,6 +41,10 @@ func (bs *baseVCSSource) upstreamURL() string {
 	return bs.repo.Remote()
 }
 
+func (bs *baseVCSSource) localPath() string {
+	return bs.repo.LocalPath()
+}
+
 func (bs *baseVCSSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
 	err := bs.repo.updateVersion(ctx, r.String())
 	if err != nil {
@@ -110,10 +115,139 @@ func (bs *baseVCSSource) exportRevisionTo(ctx context.Context, r Revision, to st
 	return fs.CopyDir(bs.repo.LocalPath(), to)
 }
 
+func (bs *baseVCSSource) versionInfo(ctx context.Context, v UnpairedVersion, r Revision) (VersionInfo, error) {
+	ci, err := bs.repo.CommitInfo(r.String())
+	if err != nil {
+		return VersionInfo{}, unwrapVcsErr(err)
+	}
+
+	return VersionInfo{
+		Revision: r,
+		Author:   ci.Author,
+		Date:     ci.Date,
+		Message:  ci.Message,
+	}, nil
+}
+
+func (bs *baseVCSSource) commitLog(ctx context.Context, from, to Revision) ([]VersionInfo, error) {
+	return nil, fmt.Errorf("commit logs are not supported for %s repositories", bs.sourceType())
+}
+
+func (bs *baseVCSSource) revisionAsOf(ctx context.Context, r Revision, t time.Time) (Revision, error) {
+	return "", fmt.Errorf("past revisions of branches are not supported for %s repositories", bs.sourceType())
+}
+
+func (bs *baseVCSSource) fetchRef(ctx context.Context, ref string) (Revision, error) {
+	return "", fmt.Errorf("refs such as %s are not supported for %s repositories", ref, bs.sourceType())
+}
+
 // gitSource is a generic git repository implementation that should work with
 // all standard git remotes.
 type gitSource struct {
 	baseVCSSource
+	// refs holds the metadata of the refs of the local copy, as loaded at
+	// once by versionInfo, until the copy is updated.
+	refs *gitRefInfos
+}
+
+// gitRefInfos is the metadata of the refs of a git repository: the commits
+// they point to, and the tags.
+type gitRefInfos struct {
+	commits map[Revision]VersionInfo
+	// tags holds the commit each tag points to, along with the date and
+	// annotation of the annotated ones.
+	tags map[string]VersionInfo
+}
+
+// gitRefFormat is the format of for-each-ref listing the metadata of the refs,
+// NUL-separated, those of the commit an annotated tag points to starred. A NUL
+// ends each ref, as messages hold newlines but never NULs.
+const gitRefFormat = "%(refname)%00%(objecttype)%00%(objectname)%00%(authorname) %(authoremail)%00%(committerdate:raw)%00%(contents)%00" +
+	"%(*objecttype)%00%(*objectname)%00%(*authorname) %(*authoremail)%00%(*committerdate:raw)%00%(*contents)%00%(taggerdate:raw)%00"
+
+// loadRefInfos lists the metadata of all the refs of the local copy of s with
+// a single git command, rather than one per version.
+func (s *gitSource) loadRefInfos(ctx context.Context) (*gitRefInfos, error) {
+	out, err := runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "for-each-ref", "--format="+gitRefFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", out, err)
+	}
+
+	refs := &gitRefInfos{commits: make(map[Revision]VersionInfo), tags: make(map[string]VersionInfo)}
+	for _, rec := range strings.Split(string(out), "\x00\n") {
+		f := strings.Split(rec, "\x00")
+		if len(f) != 12 {
+			continue
+		}
+		name, typ := f[0], f[1]
+		commit := f[2:6]
+		if typ == "tag" {
+			commit = f[7:11]
+			if f[6] != "commit" {
+				continue
+			}
+		} else if typ != "commit" {
+			continue
+		}
+
+		// The commits missing from a shallow clone have no date.
+		rev := Revision(commit[0])
+		if date := rawGitDate(commit[2]); !date.IsZero() {
+			refs.commits[rev] = VersionInfo{
+				Revision: rev,
+				Author:   commit[1],
+				Date:     date,
+				Message:  strings.TrimSpace(commit[3]),
+			}
+		}
+		if strings.HasPrefix(name, "refs/tags/") {
+			ti := VersionInfo{Revision: rev}
+			if typ == "tag" {
+				ti.TagDate = rawGitDate(f[11])
+				ti.Annotation = strings.TrimSpace(f[5])
+			}
+			refs.tags[strings.TrimPrefix(name, "refs/tags/")] = ti
+		}
+	}
+	return refs, nil
+}
+
+// rawGitDate parses a date as git formats it raw, "<unix seconds> <tz
+// offset>", returning the zero time if it's malformed.
+func rawGitDate(s string) time.Time {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return time.Time{}
+	}
+	return parseUnixTimestamp(f[0])
+}
+
+func (s *gitSource) updateLocal(ctx context.Context) error {
+	s.refs = nil
+	return s.baseVCSSource.updateLocal(ctx)
+}
+
+// useShallowClone makes the local copy of s a shallow clone, if it's not been
+// cloned yet.
+func (s *gitSource) useShallowClone() {
+	if r, ok := s.repo.(*gitRepo); ok {
+		r.shallow = true
+	}
+}
+
+// ensureCommit makes sure rev is in the local copy of s, if it's a shallow
+// clone.
+func (s *gitSource) ensureCommit(ctx context.Context, rev Revision) error {
+	if r, ok := s.repo.(*gitRepo); ok {
+		return unwrapVcsErr(r.ensureCommit(ctx, rev.String()))
+	}
+	return nil
+}
+
+func (s *gitSource) revisionPresentIn(rev Revision) (bool, error) {
+	// Commits missing from a shallow clone may well be upstream.
+	s.ensureCommit(context.TODO(), rev)
+	return s.baseVCSSource.revisionPresentIn(rev)
 }
 
 func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
@@ -123,6 +257,15 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 		return err
 	}
 
+	if err := s.ensureCommit(ctx, rev); err != nil {
+		return err
+	}
+
+	gitDir := filepath.Join(r.LocalPath(), ".git")
+	if err := checkGitTreeExportable(ctx, gitDir, rev, to); err != nil {
+		return err
+	}
+
 	// Back up original index
 	idx, bak := filepath.Join(r.LocalPath(), ".git", "index"), filepath.Join(r.LocalPath(), ".git", "origindex")
 	err := fs.RenameWithFallback(idx, bak)
@@ -133,7 +276,7 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	// could have an err here...but it's hard to imagine how?
 	defer fs.RenameWithFallback(bak, idx)
 
-	out, err := runFromRepoDir(ctx, r, defaultCmdTimeout, "git", "read-tree", rev.String())
+	out, err := runFromRepoDir(ctx, r, defaultCmdTimeout, "git", "read-tree", "--", rev.String())
 	if err != nil {
 		return fmt.Errorf("%s: %s", out, err)
 	}
@@ -149,12 +292,169 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	// though we have a bunch of housekeeping to do to set up, then tear
 	// down, the sparse checkout controls, as well as restore the original
 	// index and HEAD.
-	out, err = runFromRepoDir(ctx, r, defaultCmdTimeout, "git", "checkout-index", "-a", "--prefix="+to)
+	//
+	// core.longpaths lets git for Windows write paths longer than MAX_PATH,
+	// which deeply nested vendor directories need; other platforms ignore it.
+	out, err = runFromRepoDir(ctx, r, defaultCmdTimeout, "git", "-c", "core.longpaths=true", "checkout-index", "-a", "--prefix="+to)
 	if err != nil {
 		return fmt.Errorf("%s: %s", out, err)
 	}
 
-	return nil
+	// checkout-index leaves the directories of submodules empty, so they're
+	// exported separately, at the revisions recorded in rev.
+	return exportGitSubmodules(ctx, gitDir, r.Remote(), gitDir, rev, to)
+}
+
+func (s *gitSource) commitLog(ctx context.Context, from, to Revision) ([]VersionInfo, error) {
+	// The commits in between are only all there in a full clone.
+	if r, ok := s.repo.(*gitRepo); ok {
+		if err := r.unshallow(ctx); err != nil {
+			return nil, unwrapVcsErr(err)
+		}
+	}
+
+	out, err := runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "log", "-z", "--format=%H%x00%an <%ae>%x00%ct%x00%B", from.String()+".."+to.String(), "--")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", out, err)
+	}
+	return parseCommitLog(out)
+}
+
+// revisionAsOf returns the last commit of the history of r, along its first
+// parents, which was committed no later than t, or "" if there is none.
+func (s *gitSource) revisionAsOf(ctx context.Context, r Revision, t time.Time) (Revision, error) {
+	// The past commits are only all there in a full clone.
+	if gr, ok := s.repo.(*gitRepo); ok {
+		if err := gr.unshallow(ctx); err != nil {
+			return "", unwrapVcsErr(err)
+		}
+	}
+
+	out, err := runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "rev-list", "-1", "--first-parent", fmt.Sprintf("--before=%d", t.Unix()), r.String(), "--")
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", out, err)
+	}
+	return Revision(strings.TrimSpace(string(out))), nil
+}
+
+// fetchRef fetches a ref which isn't among the branches and tags fetched by
+// default, under the same name, and returns the commit it points to.
+func (s *gitSource) fetchRef(ctx context.Context, ref string) (Revision, error) {
+	args := []string{"fetch", "--no-tags"}
+	if r, ok := s.repo.(*gitRepo); ok && r.isShallow() {
+		args = append(args, "--depth=1")
+	}
+	out, err := runFromRepoDir(ctx, s.repo, expensiveCmdTimeout, "git", append(args, s.repo.Remote(), "+"+ref+":"+ref)...)
+	if err != nil {
+		if isGitAuthFailure(out) {
+			return "", gitAuthFailure{remote: s.repo.Remote(), out: string(out)}
+		}
+		return "", fmt.Errorf("unable to fetch %s: %s", ref, commandError(out, err))
+	}
+
+	out, err = runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%s does not point to a commit", ref)
+	}
+	return Revision(strings.TrimSpace(string(out))), nil
+}
+
+// parseCommitLog parses the output of a log of commits, each made of their
+// revision, author, unix timestamp and message, separated by NUL bytes, and
+// themselves separated by NUL bytes.
+func parseCommitLog(out []byte) ([]VersionInfo, error) {
+	fields := strings.Split(strings.TrimRight(string(out), "\x00\n"), "\x00")
+	if len(fields) == 1 && fields[0] == "" {
+		return nil, nil
+	}
+	if len(fields)%4 != 0 {
+		return nil, fmt.Errorf("unexpected output from log: %q", out)
+	}
+
+	log := make([]VersionInfo, 0, len(fields)/4)
+	for i := 0; i < len(fields); i += 4 {
+		log = append(log, VersionInfo{
+			Revision: Revision(strings.TrimSpace(fields[i])),
+			Author:   fields[i+1],
+			Date:     parseUnixTimestamp(fields[i+2]),
+			Message:  strings.TrimSpace(fields[i+3]),
+		})
+	}
+	return log, nil
+}
+
+// versionInfo returns the metadata of the version v at r, from those of the
+// refs of the local copy, loaded at once for all the versions. Those the refs
+// don't tell, such as of revisions no ref points to, are looked up alone.
+func (s *gitSource) versionInfo(ctx context.Context, v UnpairedVersion, r Revision) (VersionInfo, error) {
+	if err := s.ensureCommit(ctx, r); err != nil {
+		return VersionInfo{}, err
+	}
+
+	if s.refs == nil {
+		refs, err := s.loadRefInfos(ctx)
+		if err != nil {
+			return VersionInfo{}, err
+		}
+		s.refs = refs
+	}
+	if vi, has := s.refs.commits[r]; has {
+		if v == nil || v.Type() == IsBranch {
+			return vi, nil
+		}
+		if ti, tagged := s.refs.tags[v.String()]; tagged && ti.Revision == r {
+			vi.TagDate, vi.Annotation = ti.TagDate, ti.Annotation
+			return vi, nil
+		}
+	}
+
+	out, err := runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "log", "-1", "--format=%an <%ae>%x00%ct%x00%B", r.String(), "--")
+	if err != nil {
+		return VersionInfo{}, fmt.Errorf("%s: %s", out, err)
+	}
+
+	parts := strings.SplitN(string(out), "\x00", 3)
+	if len(parts) != 3 {
+		return VersionInfo{}, fmt.Errorf("unexpected output from git log for %s: %q", r, out)
+	}
+
+	vi := VersionInfo{
+		Revision: r,
+		Author:   parts[0],
+		Date:     parseUnixTimestamp(parts[1]),
+		Message:  strings.TrimSpace(parts[2]),
+	}
+
+	// Only tags can carry annotations.
+	if v == nil || v.Type() == IsBranch {
+		return vi, nil
+	}
+
+	out, err = runFromRepoDir(ctx, s.repo, defaultCmdTimeout, "git", "for-each-ref", "--format=%(objecttype)%00%(taggerdate:raw)%00%(contents)", "refs/tags/"+v.String())
+	if err != nil {
+		return VersionInfo{}, fmt.Errorf("%s: %s", out, err)
+	}
+
+	parts = strings.SplitN(string(out), "\x00", 3)
+	if len(parts) == 3 && parts[0] == "tag" {
+		// taggerdate:raw is "<unix seconds> <tz offset>"
+		if f := strings.Fields(parts[1]); len(f) > 0 {
+			vi.TagDate = parseUnixTimestamp(f[0])
+		}
+		vi.Annotation = strings.TrimSpace(parts[2])
+	}
+
+	return vi, nil
+}
+
+// parseUnixTimestamp converts a string holding seconds since the epoch into a
+// time.Time, returning the zero time if the string is malformed.
+func parseUnixTimestamp(s string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
 }
 
 func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, err error) {
@@ -163,10 +463,13 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 	var out []byte
 	c := newMonitoredCmd(exec.Command("git", "ls-remote", r.Remote()), 30*time.Second)
 	// Ensure no prompting for PWs
-	c.cmd.Env = mergeEnvLists([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ())
+	setCmdEnv(ctx, c.cmd)
 	out, err = c.combinedOutput(ctx)
 
 	if err != nil {
+		if isGitAuthFailure(out) {
+			return nil, gitAuthFailure{remote: r.Remote(), out: string(out)}
+		}
 		return nil, err
 	}
 
@@ -209,11 +512,14 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 	vlist = make([]PairedVersion, len(all)-1) // less 1, because always ignore HEAD
 	for _, pair := range all {
 		var v PairedVersion
-		if string(pair[46:51]) == "heads" {
+		// Refs other than branches and tags, such as those of pull requests,
+		// are left out; see IsRefName.
+		ref := string(pair[41:])
+		if strings.HasPrefix(ref, "refs/heads/") {
 			rev := Revision(pair[:40])
 
 			isdef := rev == headrev
-			n := string(pair[52:])
+			n := strings.TrimPrefix(ref, "refs/heads/")
 			if isdef {
 				if onedef {
 					multidef = true
@@ -230,8 +536,8 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 
 			vlist[uniq] = v
 			uniq++
-		} else if string(pair[46:50]) == "tags" {
-			vstr := string(pair[51:])
+		} else if strings.HasPrefix(ref, "refs/tags/") {
+			vstr := strings.TrimPrefix(ref, "refs/tags/")
 			if strings.HasSuffix(vstr, "^{}") {
 				// If the suffix is there, then we *know* this is the rev of
 				// the underlying commit object that we actually want
@@ -413,14 +719,18 @@ type hgSource struct {
 }
 
 func (s *hgSource) exportRevisionTo(ctx context.Context, rev Revision, to string) error {
-	// TODO: use hg instead of the generic approach in
-	// baseVCSSource.exportRevisionTo to make it faster.
-	if err := s.baseVCSSource.exportRevisionTo(ctx, rev, to); err != nil {
+	// Only make the parent dir, as hg archive creates the target itself.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
 		return err
 	}
 
-	if err := os.RemoveAll(filepath.Join(to, ".hg")); err != nil {
-		return err
+	// hg archive leaves the .hg metadata out, and with -S, exports the
+	// subrepositories at the revisions recorded in rev, which may be hg, git
+	// or svn repositories themselves. ui.archivemeta keeps it from adding a
+	// .hg_archival.txt file.
+	out, err := runFromRepoDir(ctx, s.repo, expensiveCmdTimeout, "hg", "--config", "ui.archivemeta=false", "archive", "-S", "-t", "files", "-r", rev.String(), to)
+	if err != nil {
+		return fmt.Errorf("%s: %s", out, err)
 	}
 
 	return nil
@@ -15,7 +15,9 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/Masterminds/vcs"
 	"github.com/golang/dep/internal/test"
 )
 
@@ -632,6 +634,505 @@ func Test_hgSource_exportRevisionTo_removeVcsFiles(t *testing.T) {
 	}
 }
 
+func TestHgSourceExportSubrepos(t *testing.T) {
+	// This test is slow, so skip it on -short
+	if testing.Short() {
+		t.Skip("Skipping hg subrepo export test in short mode")
+	}
+	requiresBins(t, "hg")
+
+	tmp, err := ioutil.TempDir("", "gpshgrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	hg := func(dir string, args ...string) {
+		args = append([]string{"--config", "ui.username=gps <gps@example.com>"}, args...)
+		cmd := exec.Command("hg", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("hg %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub := filepath.Join(tmp, "sub")
+	hg(tmp, "init", sub)
+	write(filepath.Join(sub, "sub.go"), "package sub\n")
+	hg(sub, "commit", "-A", "-m", "sub")
+
+	upstream := filepath.Join(tmp, "upstream")
+	hg(tmp, "init", upstream)
+	write(filepath.Join(upstream, "main.go"), "package main\n")
+	hg(upstream, "clone", sub, filepath.Join(upstream, "third_party", "sub"))
+	write(filepath.Join(upstream, ".hgsub"), "third_party/sub = "+sub+"\n")
+	hg(upstream, "commit", "-A", "-m", "initial commit")
+
+	// Move the subrepo past the recorded revision; the export must stick to
+	// the recorded one.
+	write(filepath.Join(sub, "later.go"), "package sub\n")
+	hg(sub, "commit", "-A", "-m", "later")
+
+	r, err := newCtxRepo(vcs.Hg, upstream, filepath.Join(tmp, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &hgSource{baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rev Revision
+	for _, v := range vlist {
+		if v.String() == "default" {
+			rev = v.Revision()
+		}
+	}
+	if rev == "" {
+		t.Fatalf("expected a default branch, got %v", vlist)
+	}
+
+	to := filepath.Join(tmp, "export")
+	if err := src.exportRevisionTo(ctx, rev, to); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"main.go", "third_party/sub/sub.go"} {
+		if _, err := os.Stat(filepath.Join(to, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s to be exported: %s", f, err)
+		}
+	}
+	for _, f := range []string{".hg", ".hg_archival.txt", "third_party/sub/.hg", "third_party/sub/later.go"} {
+		if _, err := os.Stat(filepath.Join(to, filepath.FromSlash(f))); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be exported", f)
+		}
+	}
+}
+
+func TestGitSourceVersionInfo(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+
+	git("commit", "--allow-empty", "-m", "initial commit")
+	git("tag", "-a", "v1.0.0", "-m", "First release\n\nLots of goodies.")
+	git("commit", "--allow-empty", "-m", "second commit")
+	git("tag", "v1.1.0")
+	rev := Revision(strings.TrimSpace(git("rev-parse", "v1.0.0^{commit}")))
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	vi, err := src.versionInfo(ctx, NewVersion("v1.0.0"), rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vi.Revision != rev {
+		t.Errorf("expected revision %s, got %s", rev, vi.Revision)
+	}
+	if vi.Message != "initial commit" {
+		t.Errorf("unexpected commit message %q", vi.Message)
+	}
+	if vi.Annotation != "First release\n\nLots of goodies." {
+		t.Errorf("unexpected tag annotation %q", vi.Annotation)
+	}
+	if vi.Date.IsZero() || vi.TagDate.IsZero() {
+		t.Errorf("expected both commit and tag dates to be set, got %v and %v", vi.Date, vi.TagDate)
+	}
+
+	// Lightweight tags carry no annotation of their own.
+	rev = Revision(strings.TrimSpace(git("rev-parse", "v1.1.0")))
+	vi, err = src.versionInfo(ctx, NewVersion("v1.1.0"), rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vi.Annotation != "" || !vi.TagDate.IsZero() {
+		t.Errorf("expected no annotation for lightweight tag, got %q (%v)", vi.Annotation, vi.TagDate)
+	}
+	if !vi.ReleaseDate().Equal(vi.Date) {
+		t.Errorf("expected release date of lightweight tag to be its commit date")
+	}
+
+	// The metadata of all the refs is listed at once, and served from there
+	// until the local copy is updated.
+	if src.refs == nil || len(src.refs.tags) != 2 || src.refs.tags["v1.0.0"].Annotation != "First release\n\nLots of goodies." {
+		t.Fatalf("expected the refs to be listed at once, got %+v", src.refs)
+	}
+	if _, has := src.refs.commits[rev]; !has {
+		t.Errorf("expected the commit of v1.1.0 to be listed, got %+v", src.refs.commits)
+	}
+	if err := src.updateLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if src.refs != nil {
+		t.Error("expected the refs to be listed anew once the local copy is updated")
+	}
+}
+
+func TestGitSourceCommitLog(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+
+	git("commit", "--allow-empty", "-m", "initial commit")
+	git("tag", "v1.0.0")
+	git("commit", "--allow-empty", "-m", "fix a bug\n\nIt was nasty.")
+	git("commit", "--allow-empty", "-m", "add a feature")
+	git("tag", "v1.1.0")
+	from := Revision(strings.TrimSpace(git("rev-parse", "v1.0.0")))
+	to := Revision(strings.TrimSpace(git("rev-parse", "v1.1.0")))
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := src.commitLog(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 {
+		t.Fatalf("expected 2 commits, got %d: %+v", len(log), log)
+	}
+	if log[0].Revision != to || log[0].Message != "add a feature" {
+		t.Errorf("unexpected newest commit %+v", log[0])
+	}
+	if log[1].Message != "fix a bug\n\nIt was nasty." || log[1].Author != "gps <gps@example.com>" || log[1].Date.IsZero() {
+		t.Errorf("unexpected oldest commit %+v", log[1])
+	}
+
+	log, err = src.commitLog(ctx, to, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 0 {
+		t.Errorf("expected no commits between a revision and itself, got %+v", log)
+	}
+}
+
+func TestGitSourceRevisionAsOf(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+
+	day := func(d int) time.Time {
+		return time.Date(2017, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+	commit := func(d int, args ...string) Revision {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=gps", "-c", "user.email=gps@example.com"}, args...)...)
+		cmd.Dir = upstream
+		date := day(d).Format(time.RFC3339)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+	}
+
+	// The commit of the side branch is older than the as-of time, but only
+	// made it to master after it.
+	first := commit(1, "commit", "--allow-empty", "-m", "first")
+	git("checkout", "-q", "-b", "side")
+	commit(2, "commit", "--allow-empty", "-m", "side")
+	git("checkout", "-q", "master")
+	commit(3, "commit", "--allow-empty", "-m", "second")
+	head := commit(4, "merge", "--no-ff", "-m", "merge side", "side")
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for asOf, want := range map[time.Time]Revision{
+		day(5):                 head,
+		day(2).Add(time.Hour):  first,
+		day(1).Add(-time.Hour): "",
+	} {
+		got, err := src.revisionAsOf(ctx, head, asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected master to be at %q as of %s, got %q", want, asOf, got)
+		}
+	}
+}
+
+func TestGitSourceFetchRef(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+
+	git("commit", "--allow-empty", "-m", "initial commit")
+	// A pull request, whose head is on no branch.
+	if err := ioutil.WriteFile(filepath.Join(upstream, "fix.go"), []byte("package fix\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("checkout", "-q", "-b", "fix")
+	git("add", ".")
+	git("commit", "-m", "fix a bug")
+	want := Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+	git("update-ref", "refs/pull/1/head", "HEAD")
+	git("checkout", "-q", "master")
+	git("branch", "-D", "fix")
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vlist {
+		if v.Revision() == want {
+			t.Errorf("expected the pull request to be left out of the versions, got %s", v)
+		}
+	}
+
+	got, err := src.fetchRef(ctx, "refs/pull/1/head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("expected refs/pull/1/head to point to %s, got %s", want, got)
+	}
+	to := filepath.Join(cpath, "export")
+	if err := src.exportRevisionTo(ctx, got, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "fix.go")); err != nil {
+		t.Errorf("expected the pull request to be exported: %s", err)
+	}
+
+	if _, err := src.fetchRef(ctx, "refs/pull/2/head"); err == nil {
+		t.Error("expected an error fetching a ref which doesn't exist")
+	}
+}
+
+func TestGitSourceShallowClone(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+
+	if err := ioutil.WriteFile(filepath.Join(upstream, "old.go"), []byte("package old\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-m", "initial commit")
+	old := Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+	git("rm", "-q", "old.go")
+	git("commit", "-m", "remove old.go")
+	git("commit", "--allow-empty", "-m", "add a feature")
+	tip := Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	// Local clones ignore --depth, unlike those from file:// URLs.
+	r, err := newCtxRepo(vcs.Git, "file://"+filepath.ToSlash(upstream), filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+	src.useShallowClone()
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	gr := r.(*gitRepo)
+	if !gr.isShallow() {
+		t.Fatal("expected a shallow clone")
+	}
+	if _, err := gr.RunFromDir("git", "cat-file", "-e", string(old)+"^{commit}"); err == nil {
+		t.Fatalf("expected %s to be missing from the shallow clone", old)
+	}
+
+	// Older commits are fetched when needed.
+	present, err := src.revisionPresentIn(old)
+	if err != nil || !present {
+		t.Fatalf("expected %s to be fetched, got %t (%v)", old, present, err)
+	}
+	to := filepath.Join(cpath, "export")
+	if err := src.exportRevisionTo(ctx, old, to); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "old.go")); err != nil {
+		t.Errorf("expected old.go to be exported: %s", err)
+	}
+
+	// Commit logs need the whole history.
+	log, err := src.commitLog(ctx, old, tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 {
+		t.Errorf("expected 2 commits, got %+v", log)
+	}
+	if gr.isShallow() {
+		t.Error("expected the clone to be unshallowed for the commit log")
+	}
+}
+
+func TestSourceGatewayOffline(t *testing.T) {
+	requiresBins(t, "git")
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+	if err := ioutil.WriteFile(filepath.Join(upstream, "foo.go"), []byte("package foo\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-m", "initial commit")
+	git("tag", "v1.0.0")
+	v := NewVersion("v1.0.0").Pair(Revision(strings.TrimSpace(git("rev-parse", "HEAD"))))
+
+	cachedir, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	u, err := url.Parse("file://" + filepath.ToSlash(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := maybeGitSource{url: u}
+	ctx := context.Background()
+	newGateway := func(offline bool) (*sourceGateway, *supervisor) {
+		superv := newSupervisor(ctx)
+		superv.offline = offline
+		return newSourceGateway(mb, superv, cachedir, cachedir, newSourceLocker(sourceLocksDir(cachedir)), nil, nil), superv
+	}
+
+	// Sources missing from the cache can't be fetched.
+	sg, superv := newGateway(true)
+	if _, err = sg.listVersions(ctx); err == nil {
+		t.Fatal("expected an error listing the versions of an uncached source offline")
+	}
+	if _, ok := err.(offlineError); !ok {
+		t.Errorf("expected an offlineError, got %T: %s", err, err)
+	}
+	if !superv.denied[u.String()] {
+		t.Errorf("expected %s to be recorded as denied, got %v", u, superv.denied)
+	}
+
+	sg, _ = newGateway(false)
+	if err = sg.syncLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached sources serve the locked revisions, but not their version lists
+	// without a persistent cache.
+	sg, superv = newGateway(true)
+	to := filepath.Join(cachedir, "export")
+	if err = sg.exportVersionTo(ctx, v, to); err != nil {
+		t.Fatalf("unexpected error exporting a cached source offline: %s", err)
+	}
+	if _, err = os.Stat(filepath.Join(to, "foo.go")); err != nil {
+		t.Error(err)
+	}
+	if len(superv.denied) != 0 {
+		t.Errorf("expected no network access to be denied, got %v", superv.denied)
+	}
+	if _, err = sg.listVersions(ctx); err == nil {
+		t.Error("expected an error listing versions offline without a persistent cache")
+	}
+}
+
+// newLocalGitRepo initializes a git repository in a new temporary directory,
+// returning its path and a func to run git commands within it.
+func newLocalGitRepo(t *testing.T) (string, func(args ...string) string) {
+	dir, err := ioutil.TempDir("", "gpsgitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=gps", "-c", "user.email=gps@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+
+	return dir, git
+}
+
 // Fail a test if the specified binaries aren't installed.
 func requiresBins(t *testing.T, bins ...string) {
 	for _, b := range bins {
@@ -641,3 +1142,192 @@ func requiresBins(t *testing.T, bins ...string) {
 		}
 	}
 }
+
+func TestGitSourceExportSubmodules(t *testing.T) {
+	requiresBins(t, "git")
+
+	// Recent versions of git refuse to clone submodules from local paths
+	// unless told otherwise.
+	for k, v := range map[string]string{
+		"GIT_CONFIG_COUNT":   "1",
+		"GIT_CONFIG_KEY_0":   "protocol.file.allow",
+		"GIT_CONFIG_VALUE_0": "always",
+	} {
+		if old, has := os.LookupEnv(k); has {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Setenv(k, v)
+	}
+
+	// nested is a submodule of sub, which is a submodule of upstream.
+	nested, gitNested := newLocalGitRepo(t)
+	defer os.RemoveAll(nested)
+	if err := ioutil.WriteFile(filepath.Join(nested, "nested.go"), []byte("package nested\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gitNested("add", ".")
+	gitNested("commit", "-m", "nested")
+
+	sub, gitSub := newLocalGitRepo(t)
+	defer os.RemoveAll(sub)
+	if err := ioutil.WriteFile(filepath.Join(sub, "sub.go"), []byte("package sub\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gitSub("add", ".")
+	gitSub("submodule", "add", nested, "nested")
+	// Absolute local paths are refused in .gitmodules, so the submodules are
+	// set relative to their parent, as the repositories are siblings.
+	gitSub("config", "-f", ".gitmodules", "submodule.nested.url", "../"+filepath.Base(nested))
+	gitSub("add", ".gitmodules")
+	gitSub("commit", "-m", "first")
+	subRev := strings.TrimSpace(gitSub("rev-parse", "HEAD"))
+
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+	if err := ioutil.WriteFile(filepath.Join(upstream, "main.go"), []byte("package main\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("submodule", "add", sub, "third_party/sub")
+	git("config", "-f", ".gitmodules", "submodule.third_party/sub.url", "../"+filepath.Base(sub))
+	git("add", ".gitmodules")
+	git("commit", "-m", "initial commit")
+	rev := Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+
+	// Move the submodule past the recorded revision; the export must stick
+	// to the recorded one.
+	if err := ioutil.WriteFile(filepath.Join(sub, "later.go"), []byte("package sub\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gitSub("add", ".")
+	gitSub("commit", "-m", "second")
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	to := filepath.Join(cpath, "export")
+	if err := src.exportRevisionTo(ctx, rev, to); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"main.go", "third_party/sub/sub.go", "third_party/sub/nested/nested.go"} {
+		if _, err := os.Stat(filepath.Join(to, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s to be exported: %s", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(to, "third_party", "sub", "later.go")); !os.IsNotExist(err) {
+		t.Errorf("expected submodule to be exported at %s, but found later.go", subRev)
+	}
+}
+
+func TestGitSourceExportMaliciousSubmodule(t *testing.T) {
+	requiresBins(t, "git")
+
+	sub, gitSub := newLocalGitRepo(t)
+	defer os.RemoveAll(sub)
+	if err := ioutil.WriteFile(filepath.Join(sub, "sub.go"), []byte("package sub\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gitSub("add", ".")
+	gitSub("commit", "-m", "sub")
+	subRev := strings.TrimSpace(gitSub("rev-parse", "HEAD"))
+
+	cpath, err := ioutil.TempDir("", "smcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cpath)
+	pwned := filepath.Join(cpath, "pwned")
+
+	// The gitlink is recorded directly, with a .gitmodules file pointing it
+	// at a URL git would take for an option.
+	upstream, git := newLocalGitRepo(t)
+	defer os.RemoveAll(upstream)
+	gitmodules := "[submodule \"evil\"]\n\tpath = evil\n\turl = --upload-pack=touch " + pwned + "\n"
+	if err := ioutil.WriteFile(filepath.Join(upstream, ".gitmodules"), []byte(gitmodules), 0666); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".gitmodules")
+	git("update-index", "--add", "--cacheinfo", "160000,"+subRev+",evil")
+	git("commit", "-m", "evil")
+	rev := Revision(strings.TrimSpace(git("rev-parse", "HEAD")))
+	// The head is left clean, for the recursive clone of the source not to
+	// trip over the submodule; only the export of rev reads it.
+	git("rm", "-q", "--cached", "evil")
+	git("rm", "-q", ".gitmodules")
+	git("commit", "-m", "clean")
+
+	r, err := newCtxRepo(vcs.Git, upstream, filepath.Join(cpath, "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &gitSource{baseVCSSource: baseVCSSource{repo: r}}
+
+	ctx := context.Background()
+	if err := src.initLocal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err = src.exportRevisionTo(ctx, rev, filepath.Join(cpath, "export"))
+	if err == nil || !strings.Contains(err.Error(), "invalid submodule URL") {
+		t.Errorf("expected the submodule URL to be refused, got %v", err)
+	}
+	if _, err := os.Stat(pwned); !os.IsNotExist(err) {
+		t.Error("expected the command in the submodule URL not to run")
+	}
+}
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	cases := []struct {
+		base, rel, want string
+		err             bool
+	}{
+		{"https://github.com/foo/bar", "https://github.com/baz/qux", "https://github.com/baz/qux", false},
+		{"https://github.com/foo/bar", "../qux", "https://github.com/foo/qux", false},
+		{"https://github.com/foo/bar.git", "../../baz/qux.git", "https://github.com/baz/qux.git", false},
+		{"https://github.com/foo/bar", "./qux", "https://github.com/foo/bar/qux", false},
+		{"git@github.com:foo/bar", "../qux", "git@github.com:foo/qux", false},
+		{"/src/foo/bar", "../qux", "/src/foo/qux", false},
+		{"git@github.com:foo", "../../qux", "", true},
+		{"https://github.com/foo/bar", "ssh://git@github.com/baz/qux", "ssh://git@github.com/baz/qux", false},
+		{"https://github.com/foo/bar", "github.com:baz/qux", "github.com:baz/qux", false},
+		{"https://github.com/foo/bar", "--upload-pack=touch /tmp/pwned", "", true},
+		{"https://github.com/foo/bar", "-u./payload", "", true},
+		{"https://github.com/foo/bar", "ext::sh -c touch% /tmp/pwned", "", true},
+		{"https://github.com/foo/bar", "file:///etc", "", true},
+		{"https://github.com/foo/bar", "/src/baz/qux", "", true},
+		{"https://github.com/foo/bar", "C:/src/qux", "", true},
+		{"https://github.com/foo/bar", "https:///qux", "", true},
+	}
+
+	for _, c := range cases {
+		got, err := resolveSubmoduleURL(c.base, c.rel)
+		if c.err {
+			if err == nil {
+				t.Errorf("resolveSubmoduleURL(%q, %q): expected an error, got %q", c.base, c.rel, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveSubmoduleURL(%q, %q): unexpected error: %s", c.base, c.rel, err)
+		} else if got != c.want {
+			t.Errorf("resolveSubmoduleURL(%q, %q) = %q, want %q", c.base, c.rel, got, c.want)
+		}
+	}
+}
@@ -7,6 +7,8 @@ package gps
 import (
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/Masterminds/semver"
 )
@@ -93,6 +95,16 @@ func NewBranch(body string) UnpairedVersion {
 	}
 }
 
+// IsRefName reports whether a branch name is, instead, the full name of
+// another ref of a git source, outside refs/heads and refs/tags, such as the
+// refs/pull/123/head of a GitHub pull request or the refs/changes/45/12345/6
+// of a Gerrit change. These refs aren't listed among the versions of sources,
+// as there may be thousands of them, but are fetched when a constraint asks
+// for them.
+func IsRefName(name string) bool {
+	return strings.HasPrefix(name, "refs/") && !strings.HasPrefix(name, "refs/heads/") && !strings.HasPrefix(name, "refs/tags/")
+}
+
 func newDefaultBranch(body string) UnpairedVersion {
 	return branchVersion{
 		name:      body,
@@ -674,6 +686,11 @@ func SortPairedForUpgrade(vl []PairedVersion) {
 	sort.Sort(pvupgradeVersionSorter(vl))
 }
 
+// UpgradeLess reports whether l sorts before r in the order of SortForUpgrade.
+func UpgradeLess(l, r Version) bool {
+	return vLess(l, r, false)
+}
+
 // SortForDowngrade sorts a slice of []Version in roughly ascending order, so
 // that presumably older versions are visited first.
 //
@@ -808,10 +825,68 @@ func vLess(l, r Version, down bool) bool {
 		return lpre
 	}
 
-	if down {
-		return lsv.LessThan(rsv)
+	if c := lsv.Compare(rsv); c != 0 {
+		if down {
+			return c < 0
+		}
+		return c > 0
+	}
+
+	// Semver ignores build metadata, but the versions differing only by it, or
+	// by the form of their tag, as v1.0.0 and 1.0.0, are ordered anyway so
+	// that sorting is deterministic: the one without metadata first, then by
+	// metadata as for pre-releases, then by tag.
+	lmeta, rmeta := lsv.Metadata(), rsv.Metadata()
+	if lmeta != rmeta {
+		if lmeta == "" || rmeta == "" {
+			return lmeta == ""
+		}
+		if c := compareBuildMetadata(lmeta, rmeta); c != 0 {
+			if down {
+				return c < 0
+			}
+			return c > 0
+		}
+	}
+	return l.String() < r.String()
+}
+
+// compareBuildMetadata compares the build metadata of two versions as semver
+// compares pre-releases: identifier by identifier, numerically if both are
+// numbers, which are lower than the others, and lexically otherwise. It returns
+// -1, 0 or 1.
+func compareBuildMetadata(l, r string) int {
+	lids, rids := strings.Split(l, "."), strings.Split(r, ".")
+	for i := 0; i < len(lids) && i < len(rids); i++ {
+		lid, rid := lids[i], rids[i]
+		if lid == rid {
+			continue
+		}
+		lnum, lerr := strconv.ParseUint(lid, 10, 64)
+		rnum, rerr := strconv.ParseUint(rid, 10, 64)
+		switch {
+		case lerr == nil && rerr == nil:
+			if lnum < rnum {
+				return -1
+			}
+			return 1
+		case lerr == nil:
+			return -1
+		case rerr == nil:
+			return 1
+		case lid < rid:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(lids) < len(rids):
+		return -1
+	case len(lids) > len(rids):
+		return 1
 	}
-	return lsv.GreaterThan(rsv)
+	return 0
 }
 
 func hidePair(pvl []PairedVersion) []Version {
@@ -4,7 +4,10 @@
 
 package gps
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestVersionSorts(t *testing.T) {
 	rev := Revision("flooboofoobooo")
@@ -187,3 +190,56 @@ func TestVersionSorts(t *testing.T) {
 		t.Errorf("Up-then-downgrade sort positions with wrong versions: %v", wrong)
 	}
 }
+
+func TestVersionSortsBuildMetadata(t *testing.T) {
+	start := []Version{
+		NewVersion("v1.0.0+build.10"),
+		NewVersion("v1.0.0+build.2"),
+		NewVersion("1.0.0"),
+		NewVersion("v1.0.0+build.2.x"),
+		NewVersion("v1.1.0"),
+		NewVersion("v1.0.0"),
+		NewVersion("v1.0.0+abc"),
+	}
+
+	eup := []string{"v1.1.0", "1.0.0", "v1.0.0", "v1.0.0+build.10", "v1.0.0+build.2.x", "v1.0.0+build.2", "v1.0.0+abc"}
+	edown := []string{"1.0.0", "v1.0.0", "v1.0.0+abc", "v1.0.0+build.2", "v1.0.0+build.2.x", "v1.0.0+build.10", "v1.1.0"}
+
+	// Whatever the order they're listed in, the result is the same.
+	for i := range start {
+		vl := append(append([]Version{}, start[i:]...), start[:i]...)
+		SortForUpgrade(vl)
+		if got := versionStrings(vl); !reflect.DeepEqual(got, eup) {
+			t.Errorf("unexpected upgrade sort:\n\t(GOT): %s\n\t(WNT): %s", got, eup)
+		}
+		SortForDowngrade(vl)
+		if got := versionStrings(vl); !reflect.DeepEqual(got, edown) {
+			t.Errorf("unexpected downgrade sort:\n\t(GOT): %s\n\t(WNT): %s", got, edown)
+		}
+	}
+}
+
+func versionStrings(vl []Version) []string {
+	s := make([]string, len(vl))
+	for i, v := range vl {
+		s[i] = v.String()
+	}
+	return s
+}
+
+func TestIsRefName(t *testing.T) {
+	cases := map[string]bool{
+		"master":                  false,
+		"feature/refs":            false,
+		"refs/heads/master":       false,
+		"refs/tags/v1.0.0":        false,
+		"refs/pull/123/head":      true,
+		"refs/changes/45/12345/6": true,
+		"refs/notes/commits":      true,
+	}
+	for name, want := range cases {
+		if got := IsRefName(name); got != want {
+			t.Errorf("IsRefName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
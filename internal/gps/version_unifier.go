@@ -8,6 +8,10 @@ package gps
 type versionUnifier struct {
 	b   sourceBridge
 	mtr *metrics
+
+	// prereleases tells which projects the semver ranges allow the
+	// pre-releases of.
+	prereleases PrereleasePolicies
 }
 
 // pairVersion takes an UnpairedVersion and attempts to pair it with an
@@ -80,7 +84,10 @@ func (vu versionUnifier) matches(id ProjectIdentifier, c Constraint, v Version)
 	}
 
 	vu.mtr.pop()
-	return uc.Matches(vtu)
+	if uc.Matches(vtu) {
+		return true
+	}
+	return vu.prereleases.For(id.ProjectRoot) == PrereleaseAllow && prereleaseMatches(c, v)
 }
 
 // matchesAny is the authoritative version of Constraint.MatchesAny.
@@ -101,6 +101,10 @@ func TestTypeUnionIntersect(t *testing.T) {
 	}
 }
 
+func (lb lvFixBridge) pairRef(ProjectIdentifier, string) (PairedVersion, error) {
+	panic("not implemented")
+}
+
 func (lb lvFixBridge) SourceExists(ProjectIdentifier) (bool, error) {
 	panic("not implemented")
 }
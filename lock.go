@@ -22,6 +22,21 @@ const LockName = "Gopkg.lock"
 type Lock struct {
 	SolveMeta SolveMeta
 	P         []gps.LockedProject
+
+	// Dev holds the roots of the projects in P which are only needed to build
+	// the root project's tests. When the manifest sets exclude-test-deps, they
+	// are only written to vendor/ on request.
+	Dev map[gps.ProjectRoot]bool
+
+	// Digests holds the digests of the directories of the projects in P, as
+	// written to vendor/ by dep ensure, against which dep check verifies
+	// vendor/. They are only recorded when the manifest sets vendor-checksums.
+	Digests map[gps.ProjectRoot][]byte
+
+	// FormatVersion is the version of the format the lock file declares, or
+	// zero if it declares none. It's carried over to the locks written in
+	// place of this one, until dep migrate sets it to CurrentLockFormat.
+	FormatVersion int
 }
 
 // SolveMeta holds solver meta data.
@@ -31,11 +46,22 @@ type SolveMeta struct {
 	AnalyzerVersion int
 	SolverName      string
 	SolverVersion   int
+
+	// Generation counts the changes made to the lock: it's bumped each time
+	// the lock is written anew. Of two locks descending from the same one, as
+	// those of branches, the one with the lower generation lacks changes made
+	// to the other, so merging it over the other would silently revert them.
+	Generation int
+
+	// GoVersion is the release of Go the projects were solved for, as the
+	// go-version of the manifest requires, if any.
+	GoVersion string
 }
 
 type rawLock struct {
-	SolveMeta solveMeta          `toml:"solve-meta"`
-	Projects  []rawLockedProject `toml:"projects"`
+	FormatVersion int                `toml:"format-version,omitempty"`
+	SolveMeta     solveMeta          `toml:"solve-meta"`
+	Projects      []rawLockedProject `toml:"projects"`
 }
 
 type solveMeta struct {
@@ -44,6 +70,8 @@ type solveMeta struct {
 	AnalyzerVersion int    `toml:"analyzer-version"`
 	SolverName      string `toml:"solver-name"`
 	SolverVersion   int    `toml:"solver-version"`
+	Generation      int    `toml:"generation,omitempty"`
+	GoVersion       string `toml:"go-version,omitempty"`
 }
 
 type rawLockedProject struct {
@@ -53,6 +81,13 @@ type rawLockedProject struct {
 	Version  string   `toml:"version,omitempty"`
 	Source   string   `toml:"source,omitempty"`
 	Packages []string `toml:"packages"`
+	Dev      bool     `toml:"dev,omitempty"`
+	Digest   string   `toml:"digest,omitempty"`
+}
+
+// ReadLock reads a lock in the format of Gopkg.lock from r.
+func ReadLock(r io.Reader) (*Lock, error) {
+	return readLock(r)
 }
 
 func readLock(r io.Reader) (*Lock, error) {
@@ -62,8 +97,13 @@ func readLock(r io.Reader) (*Lock, error) {
 		return nil, errors.Wrap(err, "Unable to read byte stream")
 	}
 
+	data, _, _, err := migrateFormat(buf.Bytes(), CurrentLockFormat, lockMigrations)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to migrate the lock to the current format")
+	}
+
 	raw := rawLock{}
-	err = toml.Unmarshal(buf.Bytes(), &raw)
+	err = toml.Unmarshal(data, &raw)
 	if err != nil {
 		return nil, errors.Wrap(err, "Unable to parse the lock as TOML")
 	}
@@ -74,7 +114,8 @@ func readLock(r io.Reader) (*Lock, error) {
 func fromRawLock(raw rawLock) (*Lock, error) {
 	var err error
 	l := &Lock{
-		P: make([]gps.LockedProject, len(raw.Projects)),
+		P:             make([]gps.LockedProject, len(raw.Projects)),
+		FormatVersion: raw.FormatVersion,
 	}
 
 	l.SolveMeta.InputsDigest, err = hex.DecodeString(raw.SolveMeta.InputsDigest)
@@ -86,6 +127,8 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 	l.SolveMeta.AnalyzerVersion = raw.SolveMeta.AnalyzerVersion
 	l.SolveMeta.SolverName = raw.SolveMeta.SolverName
 	l.SolveMeta.SolverVersion = raw.SolveMeta.SolverVersion
+	l.SolveMeta.Generation = raw.SolveMeta.Generation
+	l.SolveMeta.GoVersion = raw.SolveMeta.GoVersion
 
 	for i, ld := range raw.Projects {
 		r := gps.Revision(ld.Revision)
@@ -107,6 +150,24 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 			Source:      ld.Source,
 		}
 		l.P[i] = gps.NewLockedProject(id, v, ld.Packages)
+
+		if ld.Dev {
+			if l.Dev == nil {
+				l.Dev = make(map[gps.ProjectRoot]bool)
+			}
+			l.Dev[id.ProjectRoot] = true
+		}
+
+		if ld.Digest != "" {
+			digest, err := hex.DecodeString(ld.Digest)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid digest for %s", ld.Name)
+			}
+			if l.Digests == nil {
+				l.Digests = make(map[gps.ProjectRoot][]byte)
+			}
+			l.Digests[id.ProjectRoot] = digest
+		}
 	}
 
 	return l, nil
@@ -139,12 +200,15 @@ func (l *Lock) HasProjectWithRoot(root gps.ProjectRoot) bool {
 // toRaw converts the manifest into a representation suitable to write to the lock file
 func (l *Lock) toRaw() rawLock {
 	raw := rawLock{
+		FormatVersion: l.FormatVersion,
 		SolveMeta: solveMeta{
 			InputsDigest:    hex.EncodeToString(l.SolveMeta.InputsDigest),
 			AnalyzerName:    l.SolveMeta.AnalyzerName,
 			AnalyzerVersion: l.SolveMeta.AnalyzerVersion,
 			SolverName:      l.SolveMeta.SolverName,
 			SolverVersion:   l.SolveMeta.SolverVersion,
+			Generation:      l.SolveMeta.Generation,
+			GoVersion:       l.SolveMeta.GoVersion,
 		},
 		Projects: make([]rawLockedProject, len(l.P)),
 	}
@@ -157,6 +221,8 @@ func (l *Lock) toRaw() rawLock {
 			Name:     string(id.ProjectRoot),
 			Source:   id.Source,
 			Packages: lp.Packages(),
+			Dev:      l.Dev[id.ProjectRoot],
+			Digest:   hex.EncodeToString(l.Digests[id.ProjectRoot]),
 		}
 
 		v := lp.Version()
@@ -189,6 +255,7 @@ func LockFromSolution(in gps.Solution) *Lock {
 			AnalyzerVersion: in.AnalyzerVersion(),
 			SolverName:      in.SolverName(),
 			SolverVersion:   in.SolverVersion(),
+			GoVersion:       in.GoVersion(),
 		},
 		P: make([]gps.LockedProject, len(p)),
 	}
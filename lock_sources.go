@@ -0,0 +1,113 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// LockSources records the local copies of the sources of the projects of a
+// lock, as of a run of dep which wrote or checked it.
+type LockSources struct {
+	Time time.Time
+	// Lock is the absolute path of the lock.
+	Lock string
+	// Sources holds the names of the local copies in the sources directory,
+	// keyed by project root.
+	Sources map[gps.ProjectRoot]string
+}
+
+// lockSourcesPath returns the path of the history of the sources of locks. It
+// is kept in the sources directory, along with the local copies it names.
+func (c *Ctx) lockSourcesPath() string {
+	return filepath.Join(c.SourcesPath(), "lock-sources.json")
+}
+
+// RecordLockSources appends the local copies of the sources of the projects of
+// l, the lock at path, to the history of the sources of locks. names holds
+// those known to this run, keyed by project root; the others are carried over
+// from the last record of the lock, if any.
+func (c *Ctx) RecordLockSources(path string, l *Lock, names map[gps.ProjectRoot]string) error {
+	if l == nil || len(l.P) == 0 {
+		return nil
+	}
+
+	var last map[gps.ProjectRoot]string
+	history, err := c.LockSourcesHistory(time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, ls := range history {
+		if ls.Lock == path {
+			last = ls.Sources
+		}
+	}
+
+	ls := LockSources{Time: time.Now(), Lock: path, Sources: make(map[gps.ProjectRoot]string)}
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		if name, has := names[pr]; has {
+			ls.Sources[pr] = name
+		} else if name, has := last[pr]; has {
+			ls.Sources[pr] = name
+		}
+	}
+	if len(ls.Sources) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(ls)
+	if err != nil {
+		return errors.Wrap(err, "failed to record the sources of the lock")
+	}
+	p := c.lockSourcesPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return errors.Wrap(err, "failed to record the sources of the lock")
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to record the sources of the lock")
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to record the sources of the lock")
+	}
+	return errors.Wrap(f.Close(), "failed to record the sources of the lock")
+}
+
+// LockSourcesHistory returns the records of the history of the sources of
+// locks made at or after since, oldest first.
+func (c *Ctx) LockSourcesHistory(since time.Time) ([]LockSources, error) {
+	f, err := os.Open(c.lockSourcesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the sources of locks")
+	}
+	defer f.Close()
+
+	var history []LockSources
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var ls LockSources
+		if err := json.Unmarshal(sc.Bytes(), &ls); err != nil {
+			// Skip the partial lines left behind by interrupted runs.
+			continue
+		}
+		if !ls.Time.Before(since) {
+			history = append(history, ls)
+		}
+	}
+	return history, errors.Wrap(sc.Err(), "failed to read the sources of locks")
+}
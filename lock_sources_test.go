@@ -0,0 +1,57 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLockSources(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("gopath")
+	ctx := &Ctx{GOPATH: h.Path("gopath")}
+
+	history, err := ctx.LockSourcesHistory(time.Time{})
+	h.Must(err)
+	if history != nil {
+		t.Fatalf("expected no history, got %v", history)
+	}
+
+	l := &Lock{P: []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.Revision("aaa"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.Revision("bbb"), []string{"."}),
+	}}
+	h.Must(ctx.RecordLockSources("/a/Gopkg.lock", l, map[gps.ProjectRoot]string{
+		"github.com/foo/bar": "https---github.com-foo-bar",
+		"github.com/foo/baz": "https---github.com-foo-baz",
+	}))
+	// The sources of the projects this run didn't set up are carried over,
+	// and those of projects gone from the lock are dropped.
+	l.P = l.P[1:]
+	h.Must(ctx.RecordLockSources("/a/Gopkg.lock", l, nil))
+	// Nothing is recorded without any source.
+	h.Must(ctx.RecordLockSources("/b/Gopkg.lock", l, nil))
+
+	history, err = ctx.LockSourcesHistory(time.Time{})
+	h.Must(err)
+	if len(history) != 2 {
+		t.Fatalf("expected 2 records, got %v", history)
+	}
+	want := map[gps.ProjectRoot]string{"github.com/foo/baz": "https---github.com-foo-baz"}
+	if history[1].Lock != "/a/Gopkg.lock" || !reflect.DeepEqual(history[1].Sources, want) {
+		t.Errorf("unexpected record:\n\t(GOT): %v\n\t(WNT): %v", history[1].Sources, want)
+	}
+
+	if history, err = ctx.LockSourcesHistory(time.Now().Add(time.Hour)); err != nil || len(history) != 0 {
+		t.Errorf("expected no recent records, got %v (%v)", history, err)
+	}
+}
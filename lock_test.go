@@ -5,6 +5,7 @@
 package dep
 
 import (
+	"bytes"
 	"encoding/hex"
 	"reflect"
 	"strings"
@@ -56,6 +57,7 @@ func TestReadLock(t *testing.T) {
 	want = &Lock{
 		SolveMeta: SolveMeta{
 			InputsDigest: b,
+			Generation:   3,
 		},
 		P: []gps.LockedProject{
 			gps.NewLockedProject(
@@ -112,6 +114,7 @@ func TestWriteLock(t *testing.T) {
 	l = &Lock{
 		SolveMeta: SolveMeta{
 			InputsDigest: memo,
+			Generation:   3,
 		},
 		P: []gps.LockedProject{
 			gps.NewLockedProject(
@@ -138,6 +141,110 @@ func TestWriteLock(t *testing.T) {
 	}
 }
 
+func TestLockDevProjects(t *testing.T) {
+	memo, _ := hex.DecodeString("2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e")
+	l := &Lock{
+		SolveMeta: SolveMeta{
+			InputsDigest: memo,
+		},
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/golang/dep")},
+				gps.NewVersion("0.12.2").Pair(gps.Revision("d05d5aca9f895d19e9265839bffeadd74a2d2ecb")),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/stretchr/testify")},
+				gps.NewVersion("v1.1.4").Pair(gps.Revision("69483b4bd14f5845b5a1e55bca19e954e827f1d0")),
+				[]string{"assert"},
+			),
+		},
+		Dev: map[gps.ProjectRoot]bool{"github.com/stretchr/testify": true},
+	}
+
+	b, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling valid lock to TOML: %q", err)
+	}
+	if strings.Count(string(b), "dev = true") != 1 {
+		t.Fatalf("Expected exactly one project to be marked as dev, got:\n%s", b)
+	}
+
+	got, err := readLock(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read Lock correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.Dev, l.Dev) {
+		t.Errorf("Dev projects did not survive a round trip:\n\t(GOT): %v\n\t(WNT): %v", got.Dev, l.Dev)
+	}
+}
+
+func TestLockDigests(t *testing.T) {
+	digest, _ := hex.DecodeString("2252a285ab27944a4d7adcba8dbd03980f59ba652f12db39fa93b927c345593e")
+	l := &Lock{
+		P: []gps.LockedProject{
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/golang/dep")},
+				gps.NewVersion("0.12.2").Pair(gps.Revision("d05d5aca9f895d19e9265839bffeadd74a2d2ecb")),
+				[]string{"."},
+			),
+			gps.NewLockedProject(
+				gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot("github.com/stretchr/testify")},
+				gps.NewVersion("v1.1.4").Pair(gps.Revision("69483b4bd14f5845b5a1e55bca19e954e827f1d0")),
+				[]string{"assert"},
+			),
+		},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/golang/dep": digest},
+	}
+
+	b, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling valid lock to TOML: %q", err)
+	}
+	if strings.Count(string(b), "\n  digest = ") != 1 {
+		t.Fatalf("Expected exactly one project to have a digest, got:\n%s", b)
+	}
+
+	got, err := readLock(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read Lock correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.Digests, l.Digests) {
+		t.Errorf("Digests did not survive a round trip:\n\t(GOT): %v\n\t(WNT): %v", got.Digests, l.Digests)
+	}
+
+	bad := strings.Replace(string(b), "2252a285", "nothex!!", 1)
+	if _, err = readLock(strings.NewReader(bad)); err == nil {
+		t.Error("Expected an error for an invalid digest")
+	}
+}
+
+func TestLockGoVersion(t *testing.T) {
+	l := &Lock{SolveMeta: SolveMeta{GoVersion: "1.9"}}
+	b, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling valid lock to TOML: %q", err)
+	}
+	if !strings.Contains(string(b), `go-version = "1.9"`) {
+		t.Fatalf("Expected go-version in the solve-meta, got:\n%s", b)
+	}
+	got, err := readLock(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read Lock correctly, but got err %q", err)
+	}
+	if got.SolveMeta.GoVersion != "1.9" {
+		t.Errorf("go-version did not survive a round trip: %q", got.SolveMeta.GoVersion)
+	}
+
+	b, err = (&Lock{}).MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling valid lock to TOML: %q", err)
+	}
+	if strings.Contains(string(b), "go-version") {
+		t.Errorf("Expected no go-version without one, got:\n%s", b)
+	}
+}
+
 func TestReadLockErrors(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -8,11 +8,15 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 )
@@ -22,10 +26,27 @@ const ManifestName = "Gopkg.toml"
 
 // Errors
 var (
-	errInvalidConstraint = errors.New("\"constraint\" must be a TOML array of tables")
-	errInvalidOverride   = errors.New("\"override\" must be a TOML array of tables")
-	errInvalidRequired   = errors.New("\"required\" must be a TOML list of strings")
-	errInvalidIgnored    = errors.New("\"ignored\" must be a TOML list of strings")
+	errInvalidConstraint       = errors.New("\"constraint\" must be a TOML array of tables")
+	errInvalidOverride         = errors.New("\"override\" must be a TOML array of tables")
+	errInvalidRequired         = errors.New("\"required\" must be a TOML list of strings")
+	errInvalidIgnored          = errors.New("\"ignored\" must be a TOML list of strings")
+	errInvalidExcludeTestDeps  = errors.New("\"exclude-test-deps\" must be a boolean")
+	errInvalidVendorChecksums  = errors.New("\"vendor-checksums\" must be a boolean")
+	errInvalidKeepNestedVendor = errors.New("\"keep-nested-vendor\" must be a boolean")
+	errInvalidNestedVendor     = errors.New("\"nested-vendor\" must be one of \"strip\", \"keep\" or \"hint\"")
+	errInvalidSparseVendor     = errors.New("\"sparse-vendor\" must be a boolean")
+	errInvalidToolBin          = errors.New("\"tool-bin\" must be a string")
+	errInvalidVendorDir        = errors.New("\"vendor-dir\" must be a relative path to a directory within the project, other than its root")
+	errInvalidReleaseCoolDown  = errors.New("\"release-cool-down-days\" must be a non-negative integer")
+	errInvalidPrune            = errors.New("\"prune\" must be a TOML table")
+	errInvalidPruneProject     = errors.New("\"prune.project\" must be a TOML array of tables")
+	errInvalidSourceOverride   = errors.New("\"source-override\" must be a TOML array of tables")
+	errInvalidModuleProxy      = errors.New("\"module-proxy\" must be a string")
+	errInvalidWorkspace        = errors.New("\"workspace\" must be a TOML list of strings")
+	errInvalidPrereleases      = errors.New("\"prereleases\" must be a string")
+	errInvalidGoVersion        = errors.New("\"go-version\" must be a string")
+	errInvalidHooks            = errors.New("\"hooks\" must be a TOML table")
+	errInvalidPatch            = errors.New("\"patch\" must be a TOML array of tables")
 )
 
 // Manifest holds manifest file data and implements gps.RootManifest.
@@ -34,21 +55,177 @@ type Manifest struct {
 	Ovr         gps.ProjectConstraints
 	Ignored     []string
 	Required    []string
+
+	// ExcludeTestDeps indicates that the projects which are only reachable
+	// through the test imports of the root project are to be left out of
+	// vendor/, unless `dep ensure -dev` is used. They are still solved for,
+	// and recorded in the lock as dev projects.
+	ExcludeTestDeps bool
+
+	// VendorChecksums indicates that the checksum of every file written to
+	// vendor/ is to be recorded in vendor/.dep-checksums, so that `dep check`
+	// can tell which files were tampered with.
+	VendorChecksums bool
+
+	// KeepNestedVendor indicates that the vendor/ directories of the
+	// dependencies are to be kept when writing vendor/, instead of being
+	// stripped. It predates NestedVendor, and is the same as setting it to
+	// NestedVendorKeep.
+	KeepNestedVendor bool
+
+	// NestedVendor is how the vendor/ directories of the dependencies are
+	// handled: one of NestedVendorStrip, NestedVendorKeep and
+	// NestedVendorHint, or "" for the default; see NestedVendorPolicy.
+	NestedVendor string
+
+	// SparseVendor indicates that only the directories of the packages of the
+	// dependencies which are actually imported, with their legal files, are
+	// to be kept when writing vendor/, instead of whole projects.
+	SparseVendor bool
+
+	// ToolBin is the directory, relative to the root of the project, in which
+	// `dep tool install` installs the required tools. It defaults to bin.
+	ToolBin string
+
+	// VendorDir is the directory, relative to the root of the project and
+	// within it, to which dependencies are written instead of vendor/. The
+	// project's imports only resolve against it if something, such as a
+	// symlink or the build system, maps it back to vendor/.
+	VendorDir string
+
+	// ReleaseCoolDownDays is the number of days versions must have been
+	// released for before dep picks them, other than those already in the
+	// lock. Zero means no cool-down.
+	ReleaseCoolDownDays int
+
+	// PruneOptions tells which files to prune from the projects written to
+	// vendor/, by default and for some projects in particular. SparseVendor
+	// adds gps.PruneUnusedPackages to the defaults.
+	PruneOptions gps.CascadingPruneOptions
+
+	// SourceOverrides redirect the import paths under a prefix to another git
+	// repository, such as a mirror, before the network is used to deduce
+	// their source.
+	SourceOverrides []gps.SourceOverride
+
+	// ModuleProxy is the comma-separated list of the Go module proxies to
+	// fetch the sources from rather than their repositories, as in GOPROXY,
+	// ending with "direct" to fall back to the repositories. DEPMODULEPROXY
+	// takes precedence over it.
+	ModuleProxy string
+
+	// Workspace lists the slash-separated paths, relative to the root of the
+	// project, of the other projects sharing its lock and vendor/. Their
+	// manifests are merged with this one when solving.
+	Workspace []string
+
+	// Prereleases tells whether the pre-releases of the semver versions are
+	// candidates, by default and for the projects whose constraint or
+	// override sets its own policy.
+	Prereleases gps.PrereleasePolicies
+
+	// UpstreamVersions holds the projects fetched from a fork, as the source
+	// of their constraint or override tells, whose versions are listed from
+	// their upstream instead, as upstream-version-source sets.
+	UpstreamVersions map[gps.ProjectRoot]bool
+
+	// GoVersion is the earliest release of Go the project builds with, such
+	// as 1.9, if it needs a recent one. dep ensure checks that the go command
+	// is no older, only considers the versions of the dependencies whose own
+	// go-version is no later, and records it in the lock.
+	GoVersion string
+
+	// Hooks are the commands dep ensure runs before solving and after
+	// writing, as the hooks table sets.
+	Hooks Hooks
+
+	// Patches holds the unified diff files applied to projects once they're
+	// written to vendor/, in order, keyed by project root. The paths of the
+	// files are relative to the root of the project.
+	Patches map[gps.ProjectRoot][]string
+
+	// FormatVersion is the version of the format the manifest file declares,
+	// or zero if it declares none. It's written back as is, so that the file
+	// stays readable by the versions of dep it was, until dep migrate sets it
+	// to CurrentManifestFormat.
+	FormatVersion int
 }
 
 type rawManifest struct {
-	Constraints []rawProject `toml:"constraint,omitempty"`
-	Overrides   []rawProject `toml:"override,omitempty"`
-	Ignored     []string     `toml:"ignored,omitempty"`
-	Required    []string     `toml:"required,omitempty"`
+	FormatVersion    int                 `toml:"format-version,omitempty"`
+	Constraints      []rawProject        `toml:"constraint,omitempty"`
+	Overrides        []rawProject        `toml:"override,omitempty"`
+	Ignored          []string            `toml:"ignored,omitempty"`
+	Required         []string            `toml:"required,omitempty"`
+	ExcludeTestDeps  bool                `toml:"exclude-test-deps,omitempty"`
+	VendorChecksums  bool                `toml:"vendor-checksums,omitempty"`
+	KeepNestedVendor bool                `toml:"keep-nested-vendor,omitempty"`
+	NestedVendor     string              `toml:"nested-vendor,omitempty"`
+	SparseVendor     bool                `toml:"sparse-vendor,omitempty"`
+	ToolBin          string              `toml:"tool-bin,omitempty"`
+	VendorDir        string              `toml:"vendor-dir,omitempty"`
+	ReleaseCoolDown  int                 `toml:"release-cool-down-days,omitempty"`
+	Prune            *rawPruneOptions    `toml:"prune,omitempty"`
+	SourceOverrides  []rawSourceOverride `toml:"source-override,omitempty"`
+	ModuleProxy      string              `toml:"module-proxy,omitempty"`
+	Workspace        []string            `toml:"workspace,omitempty"`
+	Prereleases      string              `toml:"prereleases,omitempty"`
+	GoVersion        string              `toml:"go-version,omitempty"`
+	Hooks            *rawHooks           `toml:"hooks,omitempty"`
+	Patches          []rawPatch          `toml:"patch,omitempty"`
+}
+
+type rawPatch struct {
+	Name  string   `toml:"name"`
+	Files []string `toml:"files"`
+}
+
+type rawHooks struct {
+	PreSolve  []string `toml:"pre-solve,omitempty"`
+	PostWrite []string `toml:"post-write,omitempty"`
+}
+
+// Hooks are the shell commands dep ensure runs from the root of the project at
+// points of its run. Only the hooks of the root project are run, never those
+// of its dependencies.
+type Hooks struct {
+	// PreSolve are run before solving, as to generate code whose imports
+	// are to be solved for.
+	PreSolve []string
+	// PostWrite are run after writing Gopkg.lock and vendor/, as to patch the
+	// vendored sources or to regenerate build files.
+	PostWrite []string
+}
+
+type rawSourceOverride struct {
+	Prefix string `toml:"prefix"`
+	URL    string `toml:"url"`
+}
+
+type rawPruneOptions struct {
+	UnusedPackages bool                     `toml:"unused-packages,omitempty"`
+	NonGo          bool                     `toml:"non-go,omitempty"`
+	GoTests        bool                     `toml:"go-tests,omitempty"`
+	Projects       []rawPruneProjectOptions `toml:"project,omitempty"`
+}
+
+// rawPruneProjectOptions overrides the options of rawPruneOptions which are
+// set for a project.
+type rawPruneProjectOptions struct {
+	Name           string `toml:"name"`
+	UnusedPackages *bool  `toml:"unused-packages,omitempty"`
+	NonGo          *bool  `toml:"non-go,omitempty"`
+	GoTests        *bool  `toml:"go-tests,omitempty"`
 }
 
 type rawProject struct {
-	Name     string `toml:"name"`
-	Branch   string `toml:"branch,omitempty"`
-	Revision string `toml:"revision,omitempty"`
-	Version  string `toml:"version,omitempty"`
-	Source   string `toml:"source,omitempty"`
+	Name                  string `toml:"name"`
+	Branch                string `toml:"branch,omitempty"`
+	Revision              string `toml:"revision,omitempty"`
+	Version               string `toml:"version,omitempty"`
+	Source                string `toml:"source,omitempty"`
+	UpstreamVersionSource bool   `toml:"upstream-version-source,omitempty"`
+	Prereleases           string `toml:"prereleases,omitempty"`
 }
 
 func validateManifest(s string) ([]error, error) {
@@ -90,6 +267,14 @@ func validateManifest(s string) ([]error, error) {
 							switch key {
 							case "name", "branch", "version", "source":
 								// valid key
+							case "prereleases":
+								if _, ok := value.(string); !ok {
+									return warns, errors.Errorf("\"prereleases\" in %q must be a string", prop)
+								}
+							case "upstream-version-source":
+								if _, ok := value.(bool); !ok {
+									return warns, errors.Errorf("\"upstream-version-source\" in %q must be a boolean", prop)
+								}
 							case "revision":
 								if valueStr, ok := value.(string); ok {
 									if abbrevRevHash.MatchString(valueStr) {
@@ -120,7 +305,7 @@ func validateManifest(s string) ([]error, error) {
 					return warns, errInvalidOverride
 				}
 			}
-		case "ignored", "required":
+		case "ignored", "required", "workspace":
 			valid := true
 			if rawList, ok := val.([]interface{}); ok {
 				// Check element type of the array. TOML doesn't let mixing of types in
@@ -139,6 +324,134 @@ func validateManifest(s string) ([]error, error) {
 				if prop == "required" {
 					return warns, errInvalidRequired
 				}
+				if prop == "workspace" {
+					return warns, errInvalidWorkspace
+				}
+			}
+		case "exclude-test-deps":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidExcludeTestDeps
+			}
+		case "vendor-checksums":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidVendorChecksums
+			}
+		case "keep-nested-vendor":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidKeepNestedVendor
+			}
+		case "nested-vendor":
+			if policy, ok := val.(string); !ok || !validNestedVendorPolicy(policy) {
+				return warns, errInvalidNestedVendor
+			}
+		case "sparse-vendor":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidSparseVendor
+			}
+		case "tool-bin":
+			if _, ok := val.(string); !ok {
+				return warns, errInvalidToolBin
+			}
+		case "vendor-dir":
+			if dir, ok := val.(string); !ok || !validVendorDir(dir) {
+				return warns, errInvalidVendorDir
+			}
+		case "module-proxy":
+			if _, ok := val.(string); !ok {
+				return warns, errInvalidModuleProxy
+			}
+		case "prereleases":
+			if _, ok := val.(string); !ok {
+				return warns, errInvalidPrereleases
+			}
+		case "go-version":
+			if _, ok := val.(string); !ok {
+				return warns, errInvalidGoVersion
+			}
+		case formatVersionKey:
+			// Checked when migrating the manifest.
+		case "release-cool-down-days":
+			if days, ok := val.(int64); !ok || days < 0 {
+				return warns, errInvalidReleaseCoolDown
+			}
+		case "hooks":
+			hooks, ok := val.(map[string]interface{})
+			if !ok {
+				return warns, errInvalidHooks
+			}
+			for key, value := range hooks {
+				switch key {
+				case "pre-solve", "post-write":
+					cmds, ok := value.([]interface{})
+					if !ok {
+						return warns, errors.Errorf("%q in \"hooks\" must be a TOML list of strings", key)
+					}
+					for _, c := range cmds {
+						if s, ok := c.(string); !ok || strings.TrimSpace(s) == "" {
+							return warns, errors.Errorf("%q in \"hooks\" must be a TOML list of non-empty strings", key)
+						}
+					}
+				default:
+					warns = append(warns, fmt.Errorf("Invalid key %q in \"hooks\"", key))
+				}
+			}
+		case "prune":
+			pwarns, err := validatePruneOptions(val)
+			warns = append(warns, pwarns...)
+			if err != nil {
+				return warns, err
+			}
+		case "source-override":
+			overrides, ok := val.([]interface{})
+			if !ok {
+				return warns, errInvalidSourceOverride
+			}
+			for _, o := range overrides {
+				override, ok := o.(map[string]interface{})
+				if !ok {
+					return warns, errInvalidSourceOverride
+				}
+				for key, value := range override {
+					switch key {
+					case "prefix", "url":
+						if _, ok := value.(string); !ok {
+							return warns, errors.Errorf("%q in \"source-override\" must be a string", key)
+						}
+					default:
+						warns = append(warns, fmt.Errorf("Invalid key %q in \"source-override\"", key))
+					}
+				}
+			}
+		case "patch":
+			patches, ok := val.([]interface{})
+			if !ok {
+				return warns, errInvalidPatch
+			}
+			for _, p := range patches {
+				patch, ok := p.(map[string]interface{})
+				if !ok {
+					return warns, errInvalidPatch
+				}
+				for key, value := range patch {
+					switch key {
+					case "name":
+						if _, ok := value.(string); !ok {
+							return warns, errors.New("\"name\" in \"patch\" must be a string")
+						}
+					case "files":
+						files, ok := value.([]interface{})
+						if !ok {
+							return warns, errors.New("\"files\" in \"patch\" must be a TOML list of strings")
+						}
+						for _, f := range files {
+							if _, ok := f.(string); !ok {
+								return warns, errors.New("\"files\" in \"patch\" must be a TOML list of strings")
+							}
+						}
+					default:
+						warns = append(warns, fmt.Errorf("Invalid key %q in \"patch\"", key))
+					}
+				}
 			}
 		default:
 			warns = append(warns, fmt.Errorf("Unknown field in manifest: %v", prop))
@@ -148,7 +461,61 @@ func validateManifest(s string) ([]error, error) {
 	return warns, nil
 }
 
+// validatePruneOptions validates the prune table of a manifest.
+func validatePruneOptions(val interface{}) ([]error, error) {
+	var warns []error
+	prune, ok := val.(map[string]interface{})
+	if !ok {
+		return warns, errInvalidPrune
+	}
+
+	for key, value := range prune {
+		switch key {
+		case "unused-packages", "non-go", "go-tests":
+			if _, ok := value.(bool); !ok {
+				return warns, errors.Errorf("%q in \"prune\" must be a boolean", key)
+			}
+		case "project":
+			projects, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidPruneProject
+			}
+			for _, p := range projects {
+				project, ok := p.(map[string]interface{})
+				if !ok {
+					return warns, errInvalidPruneProject
+				}
+				for pkey, pvalue := range project {
+					switch pkey {
+					case "name":
+						if _, ok := pvalue.(string); !ok {
+							return warns, errors.New("\"name\" in \"prune.project\" must be a string")
+						}
+					case "unused-packages", "non-go", "go-tests":
+						if _, ok := pvalue.(bool); !ok {
+							return warns, errors.Errorf("%q in \"prune.project\" must be a boolean", pkey)
+						}
+					default:
+						warns = append(warns, fmt.Errorf("Invalid key %q in \"prune.project\"", pkey))
+					}
+				}
+			}
+		default:
+			warns = append(warns, fmt.Errorf("Invalid key %q in \"prune\"", key))
+		}
+	}
+
+	return warns, nil
+}
+
 // readManifest returns a Manifest read from r and a slice of validation warnings.
+// ReadManifest reads a manifest in the format of Gopkg.toml from r. The
+// returned warnings tell about the unknown fields and the dubious values
+// found in it, which don't prevent it from being read.
+func ReadManifest(r io.Reader) (*Manifest, []error, error) {
+	return readManifest(r)
+}
+
 func readManifest(r io.Reader) (*Manifest, []error, error) {
 	buf := &bytes.Buffer{}
 	_, err := buf.ReadFrom(r)
@@ -156,13 +523,23 @@ func readManifest(r io.Reader) (*Manifest, []error, error) {
 		return nil, nil, errors.Wrap(err, "Unable to read byte stream")
 	}
 
-	warns, err := validateManifest(buf.String())
+	data, _, changes, err := migrateFormat(buf.Bytes(), CurrentManifestFormat, manifestMigrations)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to migrate the manifest to the current format")
+	}
+	var warns []error
+	for _, c := range changes {
+		warns = append(warns, fmt.Errorf("the manifest is in an older format, which was migrated: %s; run dep migrate to rewrite it", c))
+	}
+
+	vwarns, err := validateManifest(string(data))
+	warns = append(warns, vwarns...)
 	if err != nil {
 		return nil, warns, errors.Wrap(err, "Manifest validation failed")
 	}
 
 	raw := rawManifest{}
-	err = toml.Unmarshal(buf.Bytes(), &raw)
+	err = toml.Unmarshal(data, &raw)
 	if err != nil {
 		return nil, warns, errors.Wrap(err, "Unable to parse the manifest as TOML")
 	}
@@ -177,6 +554,36 @@ func fromRawManifest(raw rawManifest) (*Manifest, error) {
 		Ovr:         make(gps.ProjectConstraints, len(raw.Overrides)),
 		Ignored:     raw.Ignored,
 		Required:    raw.Required,
+
+		ExcludeTestDeps:  raw.ExcludeTestDeps,
+		VendorChecksums:  raw.VendorChecksums,
+		KeepNestedVendor: raw.KeepNestedVendor,
+		NestedVendor:     raw.NestedVendor,
+		SparseVendor:     raw.SparseVendor,
+		ToolBin:          raw.ToolBin,
+		VendorDir:        raw.VendorDir,
+
+		ReleaseCoolDownDays: raw.ReleaseCoolDown,
+		ModuleProxy:         raw.ModuleProxy,
+		Workspace:           raw.Workspace,
+		GoVersion:           raw.GoVersion,
+		FormatVersion:       raw.FormatVersion,
+	}
+
+	var err error
+	if m.Prereleases.Default, err = gps.ParsePrereleasePolicy(raw.Prereleases); err != nil {
+		return nil, err
+	}
+	if !validNestedVendorPolicy(m.NestedVendor) {
+		return nil, errInvalidNestedVendor
+	}
+	if m.KeepNestedVendor && m.NestedVendor != "" && m.NestedVendor != NestedVendorKeep {
+		return nil, errors.Errorf("\"keep-nested-vendor\" conflicts with \"nested-vendor\" = %q; drop it", m.NestedVendor)
+	}
+	if m.GoVersion != "" {
+		if _, err := gps.ParseGoVersion(m.GoVersion); err != nil {
+			return nil, err
+		}
 	}
 
 	for i := 0; i < len(raw.Constraints); i++ {
@@ -188,6 +595,12 @@ func fromRawManifest(raw rawManifest) (*Manifest, error) {
 			return nil, errors.Errorf("multiple dependencies specified for %s, can only specify one", name)
 		}
 		m.Constraints[name] = prj
+		if err := m.setPrereleasePolicy(name, raw.Constraints[i].Prereleases); err != nil {
+			return nil, err
+		}
+		if err := m.setUpstreamVersions(name, raw.Constraints[i]); err != nil {
+			return nil, err
+		}
 	}
 
 	for i := 0; i < len(raw.Overrides); i++ {
@@ -199,11 +612,169 @@ func fromRawManifest(raw rawManifest) (*Manifest, error) {
 			return nil, errors.Errorf("multiple overrides specified for %s, can only specify one", name)
 		}
 		m.Ovr[name] = prj
+		if err := m.setPrereleasePolicy(name, raw.Overrides[i].Prereleases); err != nil {
+			return nil, err
+		}
+		if err := m.setUpstreamVersions(name, raw.Overrides[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if raw.Prune != nil {
+		if m.PruneOptions, err = fromRawPruneOptions(*raw.Prune); err != nil {
+			return nil, err
+		}
+	}
+
+	if raw.Hooks != nil {
+		m.Hooks = Hooks{PreSolve: raw.Hooks.PreSolve, PostWrite: raw.Hooks.PostWrite}
+	}
+
+	for _, p := range raw.Patches {
+		if p.Name == "" || len(p.Files) == 0 {
+			return nil, errors.New("patches require both a name and files")
+		}
+		pr := gps.ProjectRoot(p.Name)
+		if _, has := m.Patches[pr]; has {
+			return nil, errors.Errorf("multiple patches for %s", pr)
+		}
+		for _, f := range p.Files {
+			if f == "" || filepath.IsAbs(filepath.FromSlash(f)) {
+				return nil, errors.Errorf("invalid patch file %q for %s, it must be relative to the root of the project", f, pr)
+			}
+		}
+		if m.Patches == nil {
+			m.Patches = make(map[gps.ProjectRoot][]string)
+		}
+		m.Patches[pr] = p.Files
+	}
+
+	for _, o := range raw.SourceOverrides {
+		if o.Prefix == "" || o.URL == "" {
+			return nil, errors.New("source overrides require both a prefix and a url")
+		}
+		m.SourceOverrides = append(m.SourceOverrides, gps.SourceOverride{Prefix: o.Prefix, URL: o.URL})
+	}
+
+	for _, ig := range raw.Ignored {
+		if pkgtree.IsIgnorePattern(ig) {
+			if err := pkgtree.ValidateIgnorePattern(ig); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	members := make(map[string]bool, len(raw.Workspace))
+	for _, dir := range raw.Workspace {
+		if dir == "" || dir != path.Clean(dir) || path.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") || strings.Contains(dir, "\\") {
+			return nil, errors.Errorf("invalid workspace member %q, it must be the slash-separated path of a directory below the project root", dir)
+		}
+		if dir == "vendor" || strings.HasPrefix(dir, "vendor/") {
+			return nil, errors.Errorf("invalid workspace member %q, it can't be in vendor/", dir)
+		}
+		if members[dir] {
+			return nil, errors.Errorf("workspace member %s is listed more than once", dir)
+		}
+		members[dir] = true
 	}
 
 	return m, nil
 }
 
+// setPrereleasePolicy sets the pre-release policy of the project at pr to the
+// one of the given name, if any. The constraint and the override on a project
+// can't set different ones.
+func (m *Manifest) setPrereleasePolicy(pr gps.ProjectRoot, name string) error {
+	if name == "" {
+		return nil
+	}
+	p, err := gps.ParsePrereleasePolicy(name)
+	if err != nil {
+		return errors.Wrapf(err, "invalid constraint on %s", pr)
+	}
+	if prev, has := m.Prereleases.Projects[pr]; has && prev != p {
+		return errors.Errorf("multiple pre-release policies specified for %s, can only specify one", pr)
+	}
+	if m.Prereleases.Projects == nil {
+		m.Prereleases.Projects = make(map[gps.ProjectRoot]gps.PrereleasePolicy)
+	}
+	m.Prereleases.Projects[pr] = p
+	return nil
+}
+
+// setUpstreamVersions records that the versions of the project at pr are
+// listed from its upstream, if raw says so. Only the projects fetched from
+// another source, such as a fork, have an upstream to list them from.
+func (m *Manifest) setUpstreamVersions(pr gps.ProjectRoot, raw rawProject) error {
+	if !raw.UpstreamVersionSource {
+		return nil
+	}
+	if raw.Source == "" {
+		return errors.Errorf("upstream-version-source is set for %s, but it has no source to fetch it from instead of its upstream", pr)
+	}
+	if m.UpstreamVersions == nil {
+		m.UpstreamVersions = make(map[gps.ProjectRoot]bool)
+	}
+	m.UpstreamVersions[pr] = true
+	return nil
+}
+
+func fromRawPruneOptions(raw rawPruneOptions) (gps.CascadingPruneOptions, error) {
+	o := gps.CascadingPruneOptions{
+		DefaultOptions: pruneOptions(raw.UnusedPackages, raw.NonGo, raw.GoTests),
+	}
+
+	for _, rp := range raw.Projects {
+		if rp.Name == "" {
+			return o, errors.New("a project of \"prune.project\" has no name")
+		}
+		pr := gps.ProjectRoot(rp.Name)
+		if _, exists := o.PerProjectOptions[pr]; exists {
+			return o, errors.Errorf("multiple prune options specified for %s, can only specify one", pr)
+		}
+
+		var ps gps.PruneOptionSet
+		for _, opt := range []struct {
+			value  *bool
+			option gps.PruneOptions
+		}{
+			{rp.UnusedPackages, gps.PruneUnusedPackages},
+			{rp.NonGo, gps.PruneNonGoFiles},
+			{rp.GoTests, gps.PruneGoTestFiles},
+		} {
+			if opt.value == nil {
+				continue
+			}
+			ps.Mask |= opt.option
+			if *opt.value {
+				ps.Options |= opt.option
+			}
+		}
+
+		if o.PerProjectOptions == nil {
+			o.PerProjectOptions = make(map[gps.ProjectRoot]gps.PruneOptionSet)
+		}
+		o.PerProjectOptions[pr] = ps
+	}
+
+	return o, nil
+}
+
+// pruneOptions returns the set of the given prune options.
+func pruneOptions(unusedPackages, nonGo, goTests bool) gps.PruneOptions {
+	var o gps.PruneOptions
+	if unusedPackages {
+		o |= gps.PruneUnusedPackages
+	}
+	if nonGo {
+		o |= gps.PruneNonGoFiles
+	}
+	if goTests {
+		o |= gps.PruneGoTestFiles
+	}
+	return o
+}
+
 // toProject interprets the string representations of project information held in
 // a rawProject, converting them into a proper gps.ProjectProperties. An
 // error is returned if the rawProject contains some invalid combination -
@@ -245,20 +816,104 @@ func (m *Manifest) toRaw() rawManifest {
 		Overrides:   make([]rawProject, 0, len(m.Ovr)),
 		Ignored:     m.Ignored,
 		Required:    m.Required,
+
+		ExcludeTestDeps:  m.ExcludeTestDeps,
+		VendorChecksums:  m.VendorChecksums,
+		KeepNestedVendor: m.KeepNestedVendor,
+		NestedVendor:     m.NestedVendor,
+		SparseVendor:     m.SparseVendor,
+		ToolBin:          m.ToolBin,
+		VendorDir:        m.VendorDir,
+		ReleaseCoolDown:  m.ReleaseCoolDownDays,
+		ModuleProxy:      m.ModuleProxy,
+		Workspace:        m.Workspace,
+		GoVersion:        m.GoVersion,
+		FormatVersion:    m.FormatVersion,
 	}
-	for n, prj := range m.Constraints {
-		raw.Constraints = append(raw.Constraints, toRawProject(n, prj))
+	if m.Prereleases.Default != gps.PrereleaseConstraint {
+		raw.Prereleases = m.Prereleases.Default.String()
 	}
-	sort.Sort(sortedRawProjects(raw.Constraints))
 
+	for n, prj := range m.Constraints {
+		raw.Constraints = append(raw.Constraints, m.toRawProjectWithPrereleases(n, prj))
+	}
 	for n, prj := range m.Ovr {
-		raw.Overrides = append(raw.Overrides, toRawProject(n, prj))
+		raw.Overrides = append(raw.Overrides, m.toRawProjectWithPrereleases(n, prj))
+	}
+	// The policies of the projects without a constraint nor an override
+	// need a constraint to be written in, which allows any version.
+	for n := range m.Prereleases.Projects {
+		_, hasConstraint := m.Constraints[n]
+		_, hasOverride := m.Ovr[n]
+		if !hasConstraint && !hasOverride {
+			raw.Constraints = append(raw.Constraints, m.toRawProjectWithPrereleases(n, gps.ProjectProperties{Constraint: gps.Any()}))
+		}
 	}
+	sort.Sort(sortedRawProjects(raw.Constraints))
 	sort.Sort(sortedRawProjects(raw.Overrides))
 
+	raw.Prune = toRawPruneOptions(m.PruneOptions)
+
+	if len(m.Hooks.PreSolve) > 0 || len(m.Hooks.PostWrite) > 0 {
+		raw.Hooks = &rawHooks{PreSolve: m.Hooks.PreSolve, PostWrite: m.Hooks.PostWrite}
+	}
+
+	for _, o := range m.SourceOverrides {
+		raw.SourceOverrides = append(raw.SourceOverrides, rawSourceOverride{Prefix: o.Prefix, URL: o.URL})
+	}
+
+	roots := make([]gps.ProjectRoot, 0, len(m.Patches))
+	for pr := range m.Patches {
+		roots = append(roots, pr)
+	}
+	sort.Sort(sortedProjectRoots(roots))
+	for _, pr := range roots {
+		raw.Patches = append(raw.Patches, rawPatch{Name: string(pr), Files: m.Patches[pr]})
+	}
+
 	return raw
 }
 
+// toRawPruneOptions converts prune options into their representation in the
+// manifest file, or nil if none are set.
+func toRawPruneOptions(o gps.CascadingPruneOptions) *rawPruneOptions {
+	if o.DefaultOptions == 0 && len(o.PerProjectOptions) == 0 {
+		return nil
+	}
+
+	raw := &rawPruneOptions{
+		UnusedPackages: o.DefaultOptions&gps.PruneUnusedPackages != 0,
+		NonGo:          o.DefaultOptions&gps.PruneNonGoFiles != 0,
+		GoTests:        o.DefaultOptions&gps.PruneGoTestFiles != 0,
+	}
+	for pr, ps := range o.PerProjectOptions {
+		rp := rawPruneProjectOptions{Name: string(pr)}
+		for _, opt := range []struct {
+			value  **bool
+			option gps.PruneOptions
+		}{
+			{&rp.UnusedPackages, gps.PruneUnusedPackages},
+			{&rp.NonGo, gps.PruneNonGoFiles},
+			{&rp.GoTests, gps.PruneGoTestFiles},
+		} {
+			if ps.Mask&opt.option != 0 {
+				set := ps.Options&opt.option != 0
+				*opt.value = &set
+			}
+		}
+		raw.Projects = append(raw.Projects, rp)
+	}
+	sort.Sort(sortedRawPruneProjects(raw.Projects))
+
+	return raw
+}
+
+type sortedRawPruneProjects []rawPruneProjectOptions
+
+func (s sortedRawPruneProjects) Len() int           { return len(s) }
+func (s sortedRawPruneProjects) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortedRawPruneProjects) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
 type sortedRawProjects []rawProject
 
 func (s sortedRawProjects) Len() int      { return len(s) }
@@ -283,6 +938,18 @@ func (m *Manifest) MarshalTOML() ([]byte, error) {
 	return result, errors.Wrap(err, "Unable to marshal the lock to a TOML string")
 }
 
+// toRawProjectWithPrereleases is toRawProject, along with the pre-release
+// policy of the project, if it has its own, and whether its versions are
+// listed from its upstream.
+func (m *Manifest) toRawProjectWithPrereleases(name gps.ProjectRoot, project gps.ProjectProperties) rawProject {
+	raw := toRawProject(name, project)
+	if p, has := m.Prereleases.Projects[name]; has {
+		raw.Prereleases = p.String()
+	}
+	raw.UpstreamVersionSource = m.UpstreamVersions[name] && raw.Source != ""
+	return raw
+}
+
 func toRawProject(name gps.ProjectRoot, project gps.ProjectProperties) rawProject {
 	raw := rawProject{
 		Name:   string(name),
@@ -323,7 +990,8 @@ func (m *Manifest) Overrides() gps.ProjectConstraints {
 	return m.Ovr
 }
 
-// IgnoredPackages returns a set of import paths to ignore.
+// IgnoredPackages returns a set of import paths to ignore, and patterns of
+// them as described on pkgtree.IgnoreRules.
 func (m *Manifest) IgnoredPackages() map[string]bool {
 	if len(m.Ignored) == 0 {
 		return nil
@@ -350,6 +1018,16 @@ func (m *Manifest) HasConstraintsOn(root gps.ProjectRoot) bool {
 	return false
 }
 
+// VendorPruneOptions returns the prune options of the projects written to
+// vendor/, with those of SparseVendor.
+func (m *Manifest) VendorPruneOptions() gps.CascadingPruneOptions {
+	o := m.PruneOptions
+	if m.SparseVendor {
+		o.DefaultOptions |= gps.PruneUnusedPackages
+	}
+	return o
+}
+
 // RequiredPackages returns a set of import paths to require.
 func (m *Manifest) RequiredPackages() map[string]bool {
 	if len(m.Required) == 0 {
@@ -363,3 +1041,20 @@ func (m *Manifest) RequiredPackages() map[string]bool {
 
 	return mp
 }
+
+// RequiredGoVersion returns the earliest release of Go the project builds
+// with, or "" if any does.
+func (m *Manifest) RequiredGoVersion() string {
+	return m.GoVersion
+}
+
+// validVendorDir reports whether dir is a relative path to a directory within
+// the project other than its root, as replacing the vendor tree at any other
+// would delete the project, or whatever lies out of it.
+func validVendorDir(dir string) bool {
+	if dir == "" || filepath.IsAbs(dir) || path.IsAbs(dir) || filepath.VolumeName(dir) != "" {
+		return false
+	}
+	clean := path.Clean(filepath.ToSlash(dir))
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
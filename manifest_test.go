@@ -5,10 +5,12 @@
 package dep
 
 import (
+	"bytes"
 	"errors"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/dep/internal/gps"
 	"github.com/golang/dep/internal/test"
@@ -96,6 +98,446 @@ func TestWriteManifest(t *testing.T) {
 	}
 }
 
+func TestManifestExcludeTestDeps(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader("exclude-test-deps = true\n"))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+	if !m.ExcludeTestDeps {
+		t.Fatal("Expected exclude-test-deps to be read from the manifest")
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), "exclude-test-deps = true") {
+		t.Errorf("Expected exclude-test-deps to be written back, got:\n%s", got)
+	}
+
+	m.ExcludeTestDeps = false
+	got, err = m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	if strings.Contains(string(got), "exclude-test-deps") {
+		t.Errorf("Expected exclude-test-deps to be omitted when false, got:\n%s", got)
+	}
+}
+
+func TestManifestReleaseCoolDown(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader("release-cool-down-days = 7\n"))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+	if m.ReleaseCoolDownDays != 7 {
+		t.Fatalf("Expected a cool-down of 7 days, got %d", m.ReleaseCoolDownDays)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), "release-cool-down-days = 7") {
+		t.Errorf("Expected release-cool-down-days to be written back, got:\n%s", got)
+	}
+
+	p := &Project{Manifest: m}
+	before := p.MakeParams().ReleasedBefore
+	if d := time.Since(before); d < 7*24*time.Hour || d > 8*24*time.Hour {
+		t.Errorf("Expected the solve to only consider versions released 7 days ago, got %s", before)
+	}
+}
+
+func TestManifestVendorChecksums(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader("vendor-checksums = true\n"))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+	if !m.VendorChecksums {
+		t.Fatal("Expected vendor-checksums to be read from the manifest")
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	if !strings.Contains(string(got), "vendor-checksums = true") {
+		t.Errorf("Expected vendor-checksums to be written back, got:\n%s", got)
+	}
+}
+
+func TestManifestNestedVendor(t *testing.T) {
+	cases := map[string]string{
+		"":                            NestedVendorStrip,
+		"keep-nested-vendor = true\n": NestedVendorKeep,
+		"nested-vendor = \"hint\"\n":  NestedVendorHint,
+		"nested-vendor = \"strip\"\n": NestedVendorStrip,
+		"keep-nested-vendor = true\nnested-vendor = \"keep\"\n": NestedVendorKeep,
+	}
+	for in, want := range cases {
+		m, _, err := readManifest(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("%q: %s", in, err)
+		}
+		if got := m.NestedVendorPolicy(); got != want {
+			t.Errorf("%q: expected the %q policy, got %q", in, want, got)
+		}
+	}
+
+	if _, _, err := readManifest(strings.NewReader("keep-nested-vendor = true\nnested-vendor = \"hint\"\n")); err == nil {
+		t.Error("expected keep-nested-vendor to conflict with another policy")
+	}
+
+	m := &Manifest{NestedVendor: NestedVendorHint}
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `nested-vendor = "hint"`) {
+		t.Errorf("Expected nested-vendor to be written back, got:\n%s", got)
+	}
+}
+
+func TestManifestHooks(t *testing.T) {
+	in := `[hooks]
+  pre-solve = ["go generate ./..."]
+  post-write = ["./patch.sh", "make build-files"]
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hooks{PreSolve: []string{"go generate ./..."}, PostWrite: []string{"./patch.sh", "make build-files"}}
+	if !reflect.DeepEqual(m.Hooks, want) {
+		t.Errorf("unexpected hooks:\n\t(GOT): %v\n\t(WNT): %v", m.Hooks, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, _, err := readManifest(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m2.Hooks, want) {
+		t.Errorf("expected the hooks to be written back, got:\n%s", got)
+	}
+
+	invalid := []string{
+		"hooks = \"make\"\n",
+		"[hooks]\n  pre-solve = \"make\"\n",
+		"[hooks]\n  post-write = [\"\"]\n",
+		"[hooks]\n  post-write = [1]\n",
+	}
+	for _, in := range invalid {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+
+	_, warns, err := readManifest(strings.NewReader("[hooks]\n  post-solve = [\"make\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warns) != 1 {
+		t.Errorf("expected a warning about the unknown hook, got %v", warns)
+	}
+}
+
+func TestManifestPatches(t *testing.T) {
+	in := `[[patch]]
+  name = "github.com/foo/bar"
+  files = ["patches/bar-1.diff", "patches/bar-2.diff"]
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[gps.ProjectRoot][]string{"github.com/foo/bar": {"patches/bar-1.diff", "patches/bar-2.diff"}}
+	if !reflect.DeepEqual(m.Patches, want) {
+		t.Errorf("unexpected patches:\n\t(GOT): %v\n\t(WNT): %v", m.Patches, want)
+	}
+
+	got, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, _, err := readManifest(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m2.Patches, want) {
+		t.Errorf("expected the patches to be written back, got:\n%s", got)
+	}
+
+	invalid := []string{
+		"patch = \"bar.diff\"\n",
+		"[[patch]]\n  name = \"github.com/foo/bar\"\n",
+		"[[patch]]\n  name = \"github.com/foo/bar\"\n  files = \"bar.diff\"\n",
+		"[[patch]]\n  name = \"github.com/foo/bar\"\n  files = [\"/tmp/bar.diff\"]\n",
+		"[[patch]]\n  name = \"github.com/foo/bar\"\n  files = [\"a.diff\"]\n[[patch]]\n  name = \"github.com/foo/bar\"\n  files = [\"b.diff\"]\n",
+	}
+	for _, in := range invalid {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestManifestPruneOptions(t *testing.T) {
+	in := `sparse-vendor = true
+
+[prune]
+  go-tests = true
+
+  [[prune.project]]
+    name = "github.com/foo/bar"
+    go-tests = false
+    non-go = true
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+
+	want := gps.CascadingPruneOptions{
+		DefaultOptions: gps.PruneGoTestFiles,
+		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{
+			"github.com/foo/bar": {Mask: gps.PruneGoTestFiles | gps.PruneNonGoFiles, Options: gps.PruneNonGoFiles},
+		},
+	}
+	if !reflect.DeepEqual(m.PruneOptions, want) {
+		t.Fatalf("Unexpected prune options:\n\t(GOT): %+v\n\t(WNT): %+v", m.PruneOptions, want)
+	}
+	if got := m.VendorPruneOptions().PruneOptionsFor("github.com/foo/bar"); got != gps.PruneUnusedPackages|gps.PruneNonGoFiles {
+		t.Errorf("Expected sparse-vendor to prune the unused packages of github.com/foo/bar too, got options %b", got)
+	}
+
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	got, _, err := readManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read the written manifest correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.PruneOptions, want) {
+		t.Errorf("Prune options did not survive a round trip:\n\t(GOT): %+v\n\t(WNT): %+v\n%s", got.PruneOptions, want, b)
+	}
+
+	dup := in + "\n  [[prune.project]]\n    name = \"github.com/foo/bar\"\n"
+	if _, _, err = readManifest(strings.NewReader(dup)); err == nil {
+		t.Error("Expected an error for multiple prune options of the same project")
+	}
+}
+
+func TestManifestSourceOverrides(t *testing.T) {
+	in := `[[source-override]]
+  prefix = "github.com"
+  url = "https://mirror.example.com/github"
+
+[[source-override]]
+  prefix = "golang.org/x/net"
+  url = "git@example.com:forks/net"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+
+	want := []gps.SourceOverride{
+		{Prefix: "github.com", URL: "https://mirror.example.com/github"},
+		{Prefix: "golang.org/x/net", URL: "git@example.com:forks/net"},
+	}
+	if !reflect.DeepEqual(m.SourceOverrides, want) {
+		t.Fatalf("Unexpected source overrides:\n\t(GOT): %+v\n\t(WNT): %+v", m.SourceOverrides, want)
+	}
+
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	got, _, err := readManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read the written manifest correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.SourceOverrides, want) {
+		t.Errorf("Source overrides did not survive a round trip:\n\t(GOT): %+v\n\t(WNT): %+v\n%s", got.SourceOverrides, want, b)
+	}
+
+	if _, _, err = readManifest(strings.NewReader("[[source-override]]\n  prefix = \"github.com\"\n")); err == nil {
+		t.Error("Expected an error for a source override without url")
+	}
+}
+
+func TestManifestPrereleases(t *testing.T) {
+	in := `prereleases = "never"
+
+[[constraint]]
+  name = "github.com/foo/bar"
+  version = "^1.2.0"
+  prereleases = "allow"
+
+[[override]]
+  name = "github.com/foo/baz"
+  prereleases = "constraint"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+
+	want := gps.PrereleasePolicies{
+		Default: gps.PrereleaseNever,
+		Projects: map[gps.ProjectRoot]gps.PrereleasePolicy{
+			"github.com/foo/bar": gps.PrereleaseAllow,
+			"github.com/foo/baz": gps.PrereleaseConstraint,
+		},
+	}
+	if !reflect.DeepEqual(m.Prereleases, want) {
+		t.Fatalf("Unexpected pre-release policies:\n\t(GOT): %+v\n\t(WNT): %+v", m.Prereleases, want)
+	}
+	if got := (&Project{Manifest: m}).MakeParams().Prereleases; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the pre-release policies to be passed to the solver, got %+v", got)
+	}
+
+	// A policy without a constraint is written back in one allowing any
+	// version.
+	m.Prereleases.Projects["github.com/foo/qux"] = gps.PrereleaseAllow
+	want.Projects["github.com/foo/qux"] = gps.PrereleaseAllow
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	got, _, err := readManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read the written manifest correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.Prereleases, want) {
+		t.Errorf("Pre-release policies did not survive a round trip:\n\t(GOT): %+v\n\t(WNT): %+v\n%s", got.Prereleases, want, b)
+	}
+	if pp := got.Constraints["github.com/foo/qux"]; !gps.IsAny(pp.Constraint) {
+		t.Errorf("Expected github.com/foo/qux to allow any version, got %v", pp.Constraint)
+	}
+
+	for name, in := range map[string]string{
+		"unknown default":    `prereleases = "always"`,
+		"unknown in project": "[[constraint]]\n  name = \"github.com/foo/bar\"\n  prereleases = \"sometimes\"\n",
+		"conflicting": "[[constraint]]\n  name = \"github.com/foo/bar\"\n  prereleases = \"allow\"\n" +
+			"[[override]]\n  name = \"github.com/foo/bar\"\n  prereleases = \"never\"\n",
+		"not a string": "prereleases = true",
+	} {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestManifestUpstreamVersions(t *testing.T) {
+	in := `[[constraint]]
+  name = "github.com/foo/bar"
+  source = "https://github.com/fork/bar.git"
+  version = "^1.2.0"
+  upstream-version-source = true
+
+[[constraint]]
+  name = "github.com/foo/baz"
+  source = "https://github.com/fork/baz.git"
+`
+	m, _, err := readManifest(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+	want := map[gps.ProjectRoot]bool{"github.com/foo/bar": true}
+	if !reflect.DeepEqual(m.UpstreamVersions, want) {
+		t.Fatalf("Unexpected projects listing upstream versions: %v", m.UpstreamVersions)
+	}
+	if got := (&Project{Manifest: m}).MakeParams().UpstreamVersions; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the projects listing upstream versions to be passed to the solver, got %v", got)
+	}
+
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	got, _, err := readManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read the written manifest correctly, but got err %q", err)
+	}
+	if !reflect.DeepEqual(got.UpstreamVersions, want) {
+		t.Errorf("Projects listing upstream versions did not survive a round trip: %v\n%s", got.UpstreamVersions, b)
+	}
+
+	for name, in := range map[string]string{
+		"without a source": "[[constraint]]\n  name = \"github.com/foo/bar\"\n  upstream-version-source = true\n",
+		"not a boolean":    "[[constraint]]\n  name = \"github.com/foo/bar\"\n  source = \"https://github.com/fork/bar.git\"\n  upstream-version-source = \"yes\"\n",
+	} {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestManifestGoVersion(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`go-version = "1.9"` + "\n"))
+	if err != nil {
+		t.Fatalf("Should have read manifest correctly, but got err %q", err)
+	}
+	if m.GoVersion != "1.9" || m.RequiredGoVersion() != "1.9" {
+		t.Fatalf("Expected go-version 1.9, got %q", m.GoVersion)
+	}
+	if got := (&Project{Manifest: m}).MakeParams().GoVersion; got != "1.9" {
+		t.Errorf("Expected go-version to be passed to the solver, got %q", got)
+	}
+
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatalf("Error while marshaling manifest to TOML: %q", err)
+	}
+	got, _, err := readManifest(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Should have read the written manifest correctly, but got err %q", err)
+	}
+	if got.GoVersion != "1.9" {
+		t.Errorf("go-version did not survive a round trip: %q\n%s", got.GoVersion, b)
+	}
+
+	for name, in := range map[string]string{
+		"not a string":  "go-version = 1.9",
+		"not a release": `go-version = "latest"`,
+	} {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestProjectCheckGoVersion(t *testing.T) {
+	p := &Project{
+		ManifestName: ManifestName,
+		Manifest:     &Manifest{GoVersion: "1.9"},
+		Members:      []WorkspaceMember{{Manifest: &Manifest{GoVersion: "1.10"}}},
+	}
+	for toolchain, ok := range map[string]bool{
+		"go1.10.2":            true,
+		"go1.10beta1":         true,
+		"go1.9.7":             false,
+		"devel +5fd2bd4 Thu ": true,
+	} {
+		err := p.checkGoVersion(func() string { return toolchain })
+		if ok != (err == nil) {
+			t.Errorf("%s: unexpected error %v", toolchain, err)
+		}
+	}
+
+	p.Manifest.GoVersion, p.Members = "", nil
+	if err := p.checkGoVersion(func() string { t.Fatal("unexpected toolchain lookup"); return "" }); err != nil {
+		t.Errorf("expected no requirement to pass, got %v", err)
+	}
+}
+
 func TestReadManifestErrors(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -122,6 +564,20 @@ func TestReadManifestErrors(t *testing.T) {
 	}
 }
 
+func TestReadManifestIgnorePatterns(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`ignored = ["github.com/foo/bar/**", "!github.com/foo/bar/keep"]`))
+	if err != nil {
+		t.Fatalf("unexpected error reading ignore patterns: %s", err)
+	}
+	if ig := m.IgnoredPackages(); !ig["github.com/foo/bar/**"] || !ig["!github.com/foo/bar/keep"] {
+		t.Errorf("unexpected ignored packages: %v", ig)
+	}
+
+	if _, _, err := readManifest(strings.NewReader(`ignored = ["github.com/foo/bar**"]`)); err == nil {
+		t.Error("expected an error for a malformed ignore pattern")
+	}
+}
+
 func TestValidateManifest(t *testing.T) {
 	cases := []struct {
 		tomlString string
@@ -171,6 +627,144 @@ func TestValidateManifest(t *testing.T) {
 			wantWarn:  []error{},
 			wantError: nil,
 		},
+		{
+			tomlString: `
+			exclude-test-deps = true
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			tomlString: `
+			exclude-test-deps = "yes"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidExcludeTestDeps,
+		},
+		{
+			tomlString: `
+			vendor-checksums = 1
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorChecksums,
+		},
+		{
+			tomlString: `
+			keep-nested-vendor = "no"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidKeepNestedVendor,
+		},
+		{
+			tomlString: `
+			nested-vendor = "flatten"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidNestedVendor,
+		},
+		{
+			tomlString: `
+			sparse-vendor = "yes"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidSparseVendor,
+		},
+		{
+			tomlString: `
+			prune = true
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidPrune,
+		},
+		{
+			tomlString: `
+			[prune]
+			  go-tests = true
+			  [[prune.project]]
+			    name = "github.com/foo/bar"
+			    go-tests = false
+			    keep = true
+			`,
+			wantWarn: []error{
+				errors.New("Invalid key \"keep\" in \"prune.project\""),
+			},
+			wantError: nil,
+		},
+		{
+			tomlString: `
+			source-override = "github.com"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidSourceOverride,
+		},
+		{
+			tomlString: `
+			[[source-override]]
+			  prefix = "github.com/foo"
+			  url = "https://mirror.example.com/foo"
+			  vcs = "git"
+			`,
+			wantWarn: []error{
+				errors.New("Invalid key \"vcs\" in \"source-override\""),
+			},
+			wantError: nil,
+		},
+		{
+			tomlString: `
+			workspace = "services/api"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidWorkspace,
+		},
+		{
+			tomlString: `
+			module-proxy = ["https://proxy.example.com"]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidModuleProxy,
+		},
+		{
+			tomlString: `
+			tool-bin = true
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidToolBin,
+		},
+		{
+			tomlString: `
+			vendor-dir = ""
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			tomlString: `
+			vendor-dir = "."
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			tomlString: `
+			vendor-dir = "deps/../.."
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			tomlString: `
+			vendor-dir = "/tmp/deps"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidVendorDir,
+		},
+		{
+			tomlString: `
+			release-cool-down-days = -1
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidReleaseCoolDown,
+		},
 		{
 			tomlString: `
 			ignored = "foo"
@@ -0,0 +1,250 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// The policies for the vendor/ directories of the dependencies, as set by the
+// nested-vendor key of the manifest.
+const (
+	// NestedVendorStrip strips them when writing vendor/, which is the
+	// default: the dependencies are built against the revisions of the
+	// projects locked by the root project.
+	NestedVendorStrip = "strip"
+	// NestedVendorKeep keeps them, for the dependencies which only build with
+	// the copies of the projects they vendor.
+	NestedVendorKeep = "keep"
+	// NestedVendorHint strips them, but prefers the revisions they hold when
+	// solving, as recorded by the Gopkg.lock of the dependencies, over the
+	// latest versions. Like the revisions of the root lock, these are only
+	// preferred: versions allowed by the constraints are still picked when they
+	// aren't.
+	NestedVendorHint = "hint"
+)
+
+func validNestedVendorPolicy(policy string) bool {
+	switch policy {
+	case "", NestedVendorStrip, NestedVendorKeep, NestedVendorHint:
+		return true
+	}
+	return false
+}
+
+// NestedVendorPolicy returns how the vendor/ directories of the dependencies
+// are to be handled: NestedVendor if set, NestedVendorKeep if KeepNestedVendor
+// is, and NestedVendorStrip otherwise.
+func (m *Manifest) NestedVendorPolicy() string {
+	switch {
+	case m.NestedVendor != "":
+		return m.NestedVendor
+	case m.KeepNestedVendor:
+		return NestedVendorKeep
+	default:
+		return NestedVendorStrip
+	}
+}
+
+// NestedVendorConflict is a project which a dependency carries in its own
+// vendor/ directory, while the root project locks it to another revision.
+//
+// Unless the nested vendor/ is stripped, the packages of the dependency import
+// their own copy of the project, which shadows the one in the root vendor/:
+// the types of both copies are distinct, and can't be passed from one to the
+// other. When it is stripped, which is the default, the dependency is built
+// against the revision locked by the root project instead of the one it was
+// developed against.
+type NestedVendorConflict struct {
+	// Project is the root of the dependency with the nested vendor/.
+	Project gps.ProjectRoot
+	// Vendored is the root of the project found in the nested vendor/.
+	Vendored gps.ProjectRoot
+	// Packages holds the sorted import paths of the packages of Vendored found
+	// in the nested vendor/.
+	Packages []string
+	// Revision is the revision of Vendored recorded in the Gopkg.lock of
+	// Project, or "" if it can't be told.
+	Revision gps.Revision
+	// Locked is the version of Vendored locked by the root project.
+	Locked gps.Version
+}
+
+// FindNestedVendorConflicts looks for the projects vendored by the projects of
+// the lock at another revision than the one the lock records for them.
+// vendorDir must hold the projects of the lock, as written out with their
+// nested vendor/ directories.
+//
+// Only the vendor/ directories at the root of the projects are considered, and
+// the revisions they hold are only known for the dependencies with a
+// Gopkg.lock; the others are reported as conflicting.
+func FindNestedVendorConflicts(vendorDir string, l gps.Lock) ([]NestedVendorConflict, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	lps := l.Projects()
+	locked := make(map[gps.ProjectRoot]gps.LockedProject, len(lps))
+	roots := make([]string, 0, len(lps))
+	for _, lp := range lps {
+		locked[lp.Ident().ProjectRoot] = lp
+		roots = append(roots, string(lp.Ident().ProjectRoot))
+	}
+	sort.Strings(roots)
+
+	var conflicts []NestedVendorConflict
+	for _, root := range roots {
+		projectDir := filepath.Join(vendorDir, filepath.FromSlash(root))
+		nested := filepath.Join(projectDir, "vendor")
+		if fi, err := os.Stat(nested); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		pkgs, err := listVendoredPackages(nested)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the vendor directory of %s", root)
+		}
+
+		// Group the vendored packages by the projects locked by the root
+		// project. The others aren't imported, and are of no consequence.
+		byRoot := make(map[gps.ProjectRoot][]string)
+		for _, pkg := range pkgs {
+			if pr := projectRootFor(roots, pkg); pr != "" && pr != root {
+				byRoot[gps.ProjectRoot(pr)] = append(byRoot[gps.ProjectRoot(pr)], pkg)
+			}
+		}
+		if len(byRoot) == 0 {
+			continue
+		}
+
+		nestedLock, err := readNestedLock(projectDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the lock of %s", root)
+		}
+
+		for _, r := range roots {
+			pr := gps.ProjectRoot(r)
+			if _, has := byRoot[pr]; !has {
+				continue
+			}
+			c := NestedVendorConflict{
+				Project:  gps.ProjectRoot(root),
+				Vendored: pr,
+				Packages: byRoot[pr],
+				Locked:   locked[pr].Version(),
+			}
+			if nestedLock != nil {
+				for _, nlp := range nestedLock.Projects() {
+					if nlp.Ident().ProjectRoot == pr {
+						c.Revision = lockedRevision(nlp)
+					}
+				}
+			}
+			if c.Revision != "" && c.Revision == lockedRevision(locked[pr]) {
+				continue
+			}
+			conflicts = append(conflicts, c)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// listVendoredPackages returns the sorted import paths of the packages with Go
+// files beneath the vendor directory dir.
+func listVendoredPackages(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			seen[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]string, 0, len(seen))
+	for pkg := range seen {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// projectRootFor returns the longest of the sorted roots containing the
+// package pkg, or "" if none does.
+func projectRootFor(roots []string, pkg string) string {
+	var best string
+	for _, root := range roots {
+		if (pkg == root || strings.HasPrefix(pkg, root+"/")) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// nestedVendorHints returns the projects of the Gopkg.lock of the dependency
+// in dir which it carries in its vendor/ directory, or nil if there are none.
+func nestedVendorHints(dir string) (*Lock, error) {
+	nestedLock, err := readNestedLock(dir)
+	if err != nil || nestedLock == nil {
+		return nil, err
+	}
+
+	hints := &Lock{}
+	for _, lp := range nestedLock.P {
+		vendored := filepath.Join(dir, "vendor", filepath.FromSlash(string(lp.Ident().ProjectRoot)))
+		if fi, err := os.Stat(vendored); err == nil && fi.IsDir() {
+			hints.P = append(hints.P, lp)
+		}
+	}
+	if len(hints.P) == 0 {
+		return nil, nil
+	}
+	return hints, nil
+}
+
+// readNestedLock reads the Gopkg.lock of the dependency in dir, if it has
+// one.
+func readNestedLock(dir string) (*Lock, error) {
+	f, err := os.Open(filepath.Join(dir, LockName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLock(f)
+}
+
+// lockedRevision returns the revision a project is locked to, or "" if there
+// is none.
+func lockedRevision(lp gps.LockedProject) gps.Revision {
+	switch v := lp.Version().(type) {
+	case gps.PairedVersion:
+		return v.Revision()
+	case gps.Revision:
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestFindNestedVendorConflicts(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	lp := func(root, rev string) gps.LockedProject {
+		return gps.NewLockedProject(
+			gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)},
+			gps.NewVersion("v1.0.0").Pair(gps.Revision(rev)),
+			[]string{"."},
+		)
+	}
+	l := gps.SimpleLock{
+		lp("github.com/a/app", "aaa"),
+		lp("github.com/b/lib", "bbb"),
+		lp("github.com/c/log", "ccc"),
+		lp("github.com/d/util", "ddd"),
+	}
+
+	// github.com/a/app vendors lib at another revision, according to its lock,
+	// and log at the same one.
+	h.TempFile("vendor/github.com/a/app/app.go", "package app\n")
+	h.TempFile("vendor/github.com/a/app/Gopkg.lock", `
+[[projects]]
+  name = "github.com/b/lib"
+  packages = ["."]
+  revision = "old"
+
+[[projects]]
+  name = "github.com/c/log"
+  packages = ["."]
+  revision = "ccc"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  inputs-digest = ""
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`)
+	h.TempFile("vendor/github.com/a/app/vendor/github.com/b/lib/lib.go", "package lib\n")
+	h.TempFile("vendor/github.com/a/app/vendor/github.com/b/lib/sub/sub.go", "package sub\n")
+	h.TempFile("vendor/github.com/a/app/vendor/github.com/b/lib/sub/sub_test.go", "package sub\n")
+	h.TempFile("vendor/github.com/a/app/vendor/github.com/c/log/log.go", "package log\n")
+	h.TempFile("vendor/github.com/a/app/vendor/github.com/x/unused/unused.go", "package unused\n")
+
+	// github.com/d/util vendors lib without a lock telling at which revision.
+	h.TempFile("vendor/github.com/d/util/vendor/github.com/b/lib/lib.go", "package lib\n")
+
+	got, err := FindNestedVendorConflicts(h.Path("vendor"), l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []NestedVendorConflict{
+		{
+			Project:  "github.com/a/app",
+			Vendored: "github.com/b/lib",
+			Packages: []string{"github.com/b/lib", "github.com/b/lib/sub"},
+			Revision: "old",
+			Locked:   l[1].Version(),
+		},
+		{
+			Project:  "github.com/d/util",
+			Vendored: "github.com/b/lib",
+			Packages: []string{"github.com/b/lib"},
+			Locked:   l[1].Version(),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected conflicts:\n\t(GOT): %+v\n\t(WNT): %+v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// NetworkRun is the network usage of a run of dep, as recorded in the network
+// history.
+type NetworkRun struct {
+	Time    time.Time
+	Command string
+	// Project is the import root of the project the command ran on.
+	Project string
+	// Sources is the usage of the network, keyed by the URL contacted.
+	Sources map[string]gps.NetworkUsage
+}
+
+// networkHistoryPath returns the path of the network history. It is kept in
+// the cache directory, as it is shared by all the projects using it.
+func (c *Ctx) networkHistoryPath() string {
+	return filepath.Join(c.CachePath(), "network-history.json")
+}
+
+// RecordNetworkUsage appends the network usage of run to the network history.
+// Nothing is recorded for runs which didn't use the network.
+func (c *Ctx) RecordNetworkUsage(run NetworkRun) error {
+	if len(run.Sources) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(run)
+	if err != nil {
+		return errors.Wrap(err, "failed to record network usage")
+	}
+
+	p := c.networkHistoryPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return errors.Wrap(err, "failed to record network usage")
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to record network usage")
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to record network usage")
+	}
+	return errors.Wrap(f.Close(), "failed to record network usage")
+}
+
+// NetworkHistory returns the runs of the network history which happened at or
+// after since, oldest first.
+func (c *Ctx) NetworkHistory(since time.Time) ([]NetworkRun, error) {
+	f, err := os.Open(c.networkHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read network history")
+	}
+	defer f.Close()
+
+	var runs []NetworkRun
+	sc := bufio.NewScanner(f)
+	// Runs touching many sources make for long lines.
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		var run NetworkRun
+		if err := json.Unmarshal(sc.Bytes(), &run); err != nil {
+			// A run interrupted while recording leaves a partial line behind,
+			// which shouldn't make the rest of the history unreadable.
+			continue
+		}
+		if !run.Time.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, errors.Wrap(sc.Err(), "failed to read network history")
+}
+
+// ClearNetworkHistory removes the network history.
+func (c *Ctx) ClearNetworkHistory() error {
+	err := os.Remove(c.networkHistoryPath())
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to clear network history")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestNetworkHistory(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("gopath")
+	ctx := &Ctx{GOPATH: h.Path("gopath")}
+
+	runs, err := ctx.NetworkHistory(time.Time{})
+	h.Must(err)
+	if runs != nil {
+		t.Fatalf("expected no history, got %v", runs)
+	}
+
+	old := NetworkRun{
+		Time:    time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		Command: "ensure",
+		Project: "github.com/foo/bar",
+		Sources: map[string]gps.NetworkUsage{
+			"https://github.com/foo/baz": {Contacts: 2, Bytes: 1024},
+		},
+	}
+	recent := NetworkRun{
+		Time:    time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC),
+		Command: "status",
+		Project: "github.com/foo/bar",
+		Sources: map[string]gps.NetworkUsage{
+			"https://github.com/foo/baz": {Contacts: 1},
+		},
+	}
+	h.Must(ctx.RecordNetworkUsage(old))
+	h.Must(ctx.RecordNetworkUsage(recent))
+	// Runs which didn't use the network aren't recorded.
+	h.Must(ctx.RecordNetworkUsage(NetworkRun{Time: time.Now(), Command: "status"}))
+
+	if runs, err = ctx.NetworkHistory(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runs, []NetworkRun{old, recent}) {
+		t.Errorf("unexpected history:\n\t(GOT): %v\n\t(WNT): %v", runs, []NetworkRun{old, recent})
+	}
+	if runs, err = ctx.NetworkHistory(recent.Time); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runs, []NetworkRun{recent}) {
+		t.Errorf("expected only the recent run, got %v", runs)
+	}
+
+	h.Must(ctx.ClearNetworkHistory())
+	if runs, _ = ctx.NetworkHistory(time.Time{}); runs != nil {
+		t.Errorf("expected the history to be cleared, got %v", runs)
+	}
+}
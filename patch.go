@@ -0,0 +1,321 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// filePatch is the change a unified diff makes to a file.
+type filePatch struct {
+	// oldPath and newPath are the slash-separated paths of the file before
+	// and after the change, relative to the root of the project, or empty
+	// for /dev/null, as when the file is created or deleted.
+	oldPath, newPath string
+	hunks            []patchHunk
+}
+
+// patchHunk is a hunk of a unified diff.
+type patchHunk struct {
+	// oldStart is the line of the file the hunk starts at, counted from 1, or
+	// the line after which it inserts its lines when it removes none.
+	oldStart int
+	// old and new are the lines of the hunk before and after the change,
+	// with their line ending, if any.
+	old, new []string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch parses the unified diff in b, as written by diff -u or git diff,
+// into the changes it makes to each file. The paths of the files have their
+// first component stripped, as patch -p1 does.
+func parsePatch(b []byte) ([]filePatch, error) {
+	lines := strings.SplitAfter(string(b), "\n")
+	var fps []filePatch
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath, err := patchPath(line[4:])
+			if err != nil {
+				return nil, err
+			}
+			newPath, err := patchPath(lines[i+1][4:])
+			if err != nil {
+				return nil, err
+			}
+			if oldPath == "" && newPath == "" {
+				return nil, errors.Errorf("line %d: no file to patch", i+1)
+			}
+			fps = append(fps, filePatch{oldPath: oldPath, newPath: newPath})
+			i += 2
+		case strings.HasPrefix(line, "@@ "):
+			if len(fps) == 0 {
+				return nil, errors.Errorf("line %d: hunk outside of a file", i+1)
+			}
+			h, n, err := parseHunk(lines[i:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", i+1)
+			}
+			fp := &fps[len(fps)-1]
+			fp.hunks = append(fp.hunks, h)
+			i += n
+		default:
+			// Anything else, such as the headers git writes, is left alone.
+			i++
+		}
+	}
+	return fps, nil
+}
+
+// patchPath returns the path of a file as given by the header of a unified
+// diff, stripped of its first component, or "" for /dev/null.
+func patchPath(s string) (string, error) {
+	s = strings.TrimRight(s, "\r\n")
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if s == "/dev/null" {
+		return "", nil
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return "", errors.Errorf("invalid path %q, it must start with a directory such as a/", s)
+	}
+	p := path.Clean(s[i+1:])
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.Errorf("invalid path %q, it must lie within the project", s)
+	}
+	return p, nil
+}
+
+// parseHunk parses the hunk at the start of lines, returning the number of
+// lines it spans.
+func parseHunk(lines []string) (patchHunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(lines[0])
+	if m == nil {
+		return patchHunk{}, 0, errors.Errorf("invalid hunk header %q", strings.TrimSpace(lines[0]))
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	h := patchHunk{}
+	h.oldStart, _ = strconv.Atoi(m[1])
+	oldCount, newCount := count(m[2]), count(m[4])
+
+	// noEOL strips the line ending of the lines last added, as the marker of
+	// a missing newline at the end of the file asks.
+	var last []*string
+	noEOL := func() {
+		for _, l := range last {
+			*l = strings.TrimSuffix(*l, "\n")
+		}
+	}
+
+	i := 1
+	for ; oldCount > 0 || newCount > 0; i++ {
+		if i >= len(lines) || lines[i] == "" {
+			return patchHunk{}, 0, errors.New("truncated hunk")
+		}
+		line := lines[i]
+		// Some editors strip the space starting the empty lines of context.
+		if line == "\n" {
+			line = " \n"
+		}
+		switch line[0] {
+		case ' ':
+			h.old = append(h.old, line[1:])
+			h.new = append(h.new, line[1:])
+			last = []*string{&h.old[len(h.old)-1], &h.new[len(h.new)-1]}
+			oldCount--
+			newCount--
+		case '-':
+			h.old = append(h.old, line[1:])
+			last = []*string{&h.old[len(h.old)-1]}
+			oldCount--
+		case '+':
+			h.new = append(h.new, line[1:])
+			last = []*string{&h.new[len(h.new)-1]}
+			newCount--
+		case '\\':
+			noEOL()
+		default:
+			return patchHunk{}, 0, errors.Errorf("invalid line %q in hunk", strings.TrimSpace(line))
+		}
+		if oldCount < 0 || newCount < 0 {
+			return patchHunk{}, 0, errors.New("hunk longer than its header tells")
+		}
+	}
+	for ; i < len(lines) && strings.HasPrefix(lines[i], "\\"); i++ {
+		noEOL()
+	}
+	return h, i, nil
+}
+
+// apply makes the change of fp to the files under dir. The hunks must match
+// the file exactly, at the lines they tell, for the result not to depend on
+// anything but the file and the patch.
+func (fp filePatch) apply(dir string) error {
+	var lines []string
+	mode := os.FileMode(0666)
+	if fp.oldPath != "" {
+		fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(fp.oldPath)))
+		if err != nil {
+			return err
+		}
+		mode = fi.Mode().Perm()
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(fp.oldPath)))
+		if err != nil {
+			return err
+		}
+		lines = strings.SplitAfter(string(b), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+	} else if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(fp.newPath))); err == nil {
+		return errors.Errorf("%s already exists", fp.newPath)
+	}
+
+	var out []string
+	pos := 0
+	for _, h := range fp.hunks {
+		start := h.oldStart - 1
+		if len(h.old) == 0 {
+			start = h.oldStart
+		}
+		if start < pos || start+len(h.old) > len(lines) || !equalLines(lines[start:start+len(h.old)], h.old) {
+			return errors.Errorf("hunk at line %d of %s doesn't match", h.oldStart, fp.name())
+		}
+		out = append(out, lines[pos:start]...)
+		out = append(out, h.new...)
+		pos = start + len(h.old)
+	}
+	out = append(out, lines[pos:]...)
+
+	if fp.newPath == "" {
+		if len(out) > 0 {
+			return errors.Errorf("%s isn't empty once patched, but is to be deleted", fp.oldPath)
+		}
+		return os.Remove(filepath.Join(dir, filepath.FromSlash(fp.oldPath)))
+	}
+	to := filepath.Join(dir, filepath.FromSlash(fp.newPath))
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(to, []byte(strings.Join(out, "")), mode); err != nil {
+		return err
+	}
+	if fp.oldPath != "" && fp.oldPath != fp.newPath {
+		return os.Remove(filepath.Join(dir, filepath.FromSlash(fp.oldPath)))
+	}
+	return nil
+}
+
+// name returns the path of the file fp changes, for errors.
+func (fp filePatch) name() string {
+	if fp.newPath != "" {
+		return fp.newPath
+	}
+	return fp.oldPath
+}
+
+func equalLines(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyPatches applies the unified diff files at the given paths, in order,
+// to the project in dir, as patch -p1 would. Unlike patch, a hunk applies only
+// at the very lines it tells, without fuzz, so that the result is the same
+// wherever it's applied, or fails.
+func ApplyPatches(dir string, files []string) error {
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return errors.Wrap(err, "failed to read the patch")
+		}
+		fps, err := parsePatch(b)
+		if err != nil {
+			return errors.Wrapf(err, "invalid patch %s", f)
+		}
+		if len(fps) == 0 {
+			return errors.Errorf("invalid patch %s, it changes no file", f)
+		}
+		for _, fp := range fps {
+			if err := fp.apply(dir); err != nil {
+				return errors.Wrapf(err, "failed to apply %s", f)
+			}
+		}
+	}
+	return nil
+}
+
+// applyVendorPatches applies the patches of the projects of l to their copy in
+// the vendor tree at vendorDir. The paths of the patch files are relative to
+// root.
+func applyVendorPatches(vendorDir, root string, l gps.Lock, patches map[gps.ProjectRoot][]string, logger *log.Logger) error {
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		files := patches[pr]
+		if len(files) == 0 {
+			continue
+		}
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = filepath.Join(root, filepath.FromSlash(f))
+		}
+		if logger != nil {
+			logger.Printf("Patching %s with %s", pr, strings.Join(files, ", "))
+		}
+		if err := ApplyPatches(filepath.Join(vendorDir, filepath.FromSlash(string(pr))), paths); err != nil {
+			return errors.Wrapf(err, "failed to patch %s", pr)
+		}
+	}
+	return nil
+}
+
+// PatchDigests returns the digests of the patches of the manifest, keyed by
+// project root, for the inputs digest of the lock to change along with them.
+// The paths of the patch files are relative to root. Those which can't be
+// read are left out of the digests, and fail to apply when vendor/ is written.
+func (m *Manifest) PatchDigests(root string) map[gps.ProjectRoot][]byte {
+	if len(m.Patches) == 0 {
+		return nil
+	}
+	digests := make(map[gps.ProjectRoot][]byte, len(m.Patches))
+	for pr, files := range m.Patches {
+		h := sha256.New()
+		for _, f := range files {
+			h.Write([]byte(f))
+			h.Write([]byte{0})
+			if b, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(f))); err == nil {
+				h.Write(b)
+			}
+			h.Write([]byte{0})
+		}
+		digests[pr] = h.Sum(nil)
+	}
+	return digests
+}
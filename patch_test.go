@@ -0,0 +1,167 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+const testPatch = `diff --git a/foo.go b/foo.go
+index 1111111..2222222 100644
+--- a/foo.go
++++ b/foo.go
+@@ -1,4 +1,4 @@
+ package foo
+
+-const A = 1
++const A = 2
+ const B = 1
+@@ -7,2 +7,3 @@ func F() {
+ 	return
+ }
++// Patched.
+--- /dev/null
++++ b/sub/new.go
+@@ -0,0 +1,2 @@
++package sub
++const C = 3
+\ No newline at end of file
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package foo
+`
+
+func TestApplyPatches(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("project/foo.go", "package foo\n\nconst A = 1\nconst B = 1\n\nfunc F() {\n\treturn\n}\n")
+	h.TempFile("project/gone.go", "package foo\n")
+	h.TempFile("fix.diff", testPatch)
+
+	if err := ApplyPatches(h.Path("project"), []string{h.Path("fix.diff")}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"foo.go":     "package foo\n\nconst A = 2\nconst B = 1\n\nfunc F() {\n\treturn\n}\n// Patched.\n",
+		"sub/new.go": "package sub\nconst C = 3",
+	}
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(h.Path("project"), filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("unexpected content of %s:\n\t(GOT): %q\n\t(WNT): %q", name, b, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(h.Path("project"), "gone.go")); !os.IsNotExist(err) {
+		t.Errorf("expected gone.go to be deleted, got %v", err)
+	}
+
+	// Once applied, the patch no longer matches.
+	if err := ApplyPatches(h.Path("project"), []string{h.Path("fix.diff")}); err == nil {
+		t.Error("expected the patch not to apply twice")
+	}
+}
+
+func TestApplyPatchesNoFuzz(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	// The lines of the hunk are there, but one line further down.
+	h.TempFile("project/foo.go", "// Moved.\npackage foo\n\nconst A = 1\nconst B = 1\n")
+	h.TempFile("fix.diff", "--- a/foo.go\n+++ b/foo.go\n@@ -1,4 +1,4 @@\n package foo\n \n-const A = 1\n+const A = 2\n const B = 1\n")
+
+	err := ApplyPatches(h.Path("project"), []string{h.Path("fix.diff")})
+	if err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("expected the hunk not to match, got %v", err)
+	}
+}
+
+func TestParsePatchInvalid(t *testing.T) {
+	cases := map[string]string{
+		"hunk outside of a file":  "@@ -1 +1 @@\n-a\n+b\n",
+		"truncated hunk":          "--- a/foo\n+++ b/foo\n@@ -1,2 +1,2 @@\n-a\n+b\n",
+		"path out of the project": "--- a/../foo\n+++ b/../foo\n@@ -1 +1 @@\n-a\n+b\n",
+		"invalid line":            "--- a/foo\n+++ b/foo\n@@ -1 +1 @@\n*a\n+b\n",
+	}
+	for name, patch := range cases {
+		if _, err := parsePatch([]byte(patch)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestApplyVendorPatches(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("root/patches/bar.diff", "--- a/bar.go\n+++ b/bar.go\n@@ -1 +1 @@\n-package bar\n+package bar // patched\n")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar\n")
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz\n")
+
+	l := gps.SimpleLock{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}, gps.NewVersion("v1.0.0").Pair("abc"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/foo/baz"}, gps.NewVersion("v1.0.0").Pair("def"), []string{"."}),
+	}
+	patches := map[gps.ProjectRoot][]string{
+		"github.com/foo/bar": {"patches/bar.diff"},
+		// Projects missing from the lock are left alone.
+		"github.com/foo/qux": {"patches/qux.diff"},
+	}
+
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	if err := applyVendorPatches(h.Path("vendor"), h.Path("root"), l, patches, logger); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"github.com/foo/bar/bar.go": "package bar // patched\n",
+		"github.com/foo/baz/baz.go": "package baz\n",
+	}
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(h.Path("vendor"), filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("unexpected content of %s:\n\t(GOT): %q\n\t(WNT): %q", name, b, content)
+		}
+	}
+	if !strings.Contains(buf.String(), "Patching github.com/foo/bar with patches/bar.diff") {
+		t.Errorf("expected the patch to be logged, got %q", buf.String())
+	}
+}
+
+func TestManifestPatchDigests(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("patches/bar.diff", "one")
+	m := &Manifest{Patches: map[gps.ProjectRoot][]string{"github.com/foo/bar": {"patches/bar.diff"}}}
+	before := m.PatchDigests(h.Path("."))["github.com/foo/bar"]
+
+	h.TempFile("patches/bar.diff", "two")
+	after := m.PatchDigests(h.Path("."))["github.com/foo/bar"]
+	if len(before) == 0 || bytes.Equal(before, after) {
+		t.Error("expected the digest to change along with the patch")
+	}
+
+	if (&Manifest{}).PatchDigests(h.Path(".")) != nil {
+		t.Error("expected no digests without patches")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// Plan records the actions a SafeWriter would perform, so that they can be
+// reviewed, then performed later on exactly as they were planned.
+type Plan struct {
+	// ManifestDigest and LockDigest are the SHA-256 digests of the manifest
+	// and lock files the plan was made from, empty if they didn't exist. A
+	// plan only applies to the same files.
+	ManifestDigest string
+	LockDigest     string
+	// ManifestName and LockName are the names of the manifest and lock files,
+	// if not Gopkg.toml and Gopkg.lock.
+	ManifestName string `json:",omitempty"`
+	LockName     string `json:",omitempty"`
+
+	// Manifest is the manifest to write, if any.
+	Manifest string `json:",omitempty"`
+	// ManifestAppend is appended to the manifest file once everything else
+	// is written, as dep ensure -add does with the constraints it adds.
+	ManifestAppend string `json:",omitempty"`
+
+	// Lock is the new lock, which is written if WriteLock is set, and from
+	// which vendor/ is written if WriteVendor is set.
+	Lock      string `json:",omitempty"`
+	WriteLock bool
+	// LockChanges describes the changes to the old lock, for review.
+	LockChanges string `json:",omitempty"`
+
+	WriteVendor bool
+	// Vendor lists the projects to write to vendor/, for review.
+	Vendor           []string          `json:",omitempty"`
+	VendorExclude    []gps.ProjectRoot `json:",omitempty"`
+	VendorChecksums  bool
+	KeepNestedVendor bool
+	Prune            gps.CascadingPruneOptions
+	// Patches holds the patch files applied to the projects of vendor/.
+	Patches map[gps.ProjectRoot][]string `json:",omitempty"`
+}
+
+// Plan returns the plan of the actions sw would perform in root.
+func (sw *SafeWriter) Plan(root string) (*Plan, error) {
+	var err error
+	plan := &Plan{
+		WriteLock:        sw.writeLock,
+		WriteVendor:      sw.writeVendor,
+		VendorChecksums:  sw.vendorChecksums,
+		KeepNestedVendor: sw.keepNestedVendor,
+		Prune:            sw.pruneOptions,
+		Patches:          sw.patches,
+		ManifestName:     sw.manifestName,
+		LockName:         sw.lockName,
+	}
+	if plan.ManifestDigest, err = fileDigest(filepath.Join(root, fileName(sw.manifestName, ManifestName))); err != nil {
+		return nil, err
+	}
+	if plan.LockDigest, err = fileDigest(filepath.Join(root, fileName(sw.lockName, LockName))); err != nil {
+		return nil, err
+	}
+
+	if sw.Manifest != nil {
+		m, err := sw.Manifest.MarshalTOML()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal the manifest")
+		}
+		plan.Manifest = string(m)
+	}
+
+	if sw.lock != nil {
+		l, err := sw.lock.MarshalTOML()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal the lock")
+		}
+		plan.Lock = string(l)
+	}
+	if sw.writeLock && sw.lockDiff != nil {
+		if plan.LockChanges, err = formatLockDiff(*sw.lockDiff); err != nil {
+			return nil, errors.Wrap(err, "failed to format the lock diff")
+		}
+	}
+
+	if sw.writeVendor {
+		for _, lp := range sw.vendorLock().Projects() {
+			plan.Vendor = append(plan.Vendor, lp.String())
+		}
+		for pr := range sw.vendorExclude {
+			plan.VendorExclude = append(plan.VendorExclude, pr)
+		}
+		sort.Sort(sortedProjectRoots(plan.VendorExclude))
+	}
+
+	return plan, nil
+}
+
+// NewSafeWriterFromPlan sets up a SafeWriter to perform the actions of plan
+// in root. It fails if the manifest or lock file of root changed since the
+// plan was made.
+func NewSafeWriterFromPlan(root string, plan *Plan) (*SafeWriter, error) {
+	mname, lname := fileName(plan.ManifestName, ManifestName), fileName(plan.LockName, LockName)
+	digest, err := fileDigest(filepath.Join(root, mname))
+	if err != nil {
+		return nil, err
+	}
+	if digest != plan.ManifestDigest {
+		return nil, errors.Errorf("%s changed since the plan was made", mname)
+	}
+	if digest, err = fileDigest(filepath.Join(root, lname)); err != nil {
+		return nil, err
+	}
+	if digest != plan.LockDigest {
+		return nil, errors.Errorf("%s changed since the plan was made", lname)
+	}
+
+	sw := &SafeWriter{
+		writeLock:        plan.WriteLock,
+		writeVendor:      plan.WriteVendor,
+		vendorChecksums:  plan.VendorChecksums,
+		keepNestedVendor: plan.KeepNestedVendor,
+		pruneOptions:     plan.Prune,
+		patches:          plan.Patches,
+		manifestName:     plan.ManifestName,
+		lockName:         plan.LockName,
+	}
+	if plan.Manifest != "" {
+		if sw.Manifest, _, err = readManifest(strings.NewReader(plan.Manifest)); err != nil {
+			return nil, errors.Wrap(err, "failed to read the manifest of the plan")
+		}
+	}
+	if plan.Lock != "" {
+		if sw.lock, err = readLock(strings.NewReader(plan.Lock)); err != nil {
+			return nil, errors.Wrap(err, "failed to read the lock of the plan")
+		}
+	}
+	if (sw.writeLock || sw.writeVendor) && sw.lock == nil {
+		return nil, errors.New("the plan has no lock to write")
+	}
+	if len(plan.VendorExclude) > 0 {
+		sw.vendorExclude = make(map[gps.ProjectRoot]bool, len(plan.VendorExclude))
+		for _, pr := range plan.VendorExclude {
+			sw.vendorExclude[pr] = true
+		}
+	}
+
+	return sw, nil
+}
+
+// WritePlan writes plan to w as JSON.
+func WritePlan(w io.Writer, plan *Plan) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the plan")
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// ReadPlan reads a plan written by WritePlan from r.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	plan := new(Plan)
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, errors.Wrap(err, "failed to read the plan")
+	}
+	return plan, nil
+}
+
+// fileDigest returns the hex-encoded SHA-256 digest of the file, or an empty
+// string if it doesn't exist.
+func fileDigest(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", path)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type sortedProjectRoots []gps.ProjectRoot
+
+func (s sortedProjectRoots) Len() int           { return len(s) }
+func (s sortedProjectRoots) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortedProjectRoots) Less(i, j int) bool { return s[i] < s[j] }
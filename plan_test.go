@@ -0,0 +1,82 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSafeWriterPlan(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	pc := NewTestProjectContext(h, safeWriterProject)
+	defer pc.Release()
+	pc.CopyFile(LockName, "txn_writer/original_lock.toml")
+	pc.Load()
+
+	ulf := h.GetTestFile("txn_writer/updated_lock.toml")
+	defer ulf.Close()
+	updatedLock, err := readLock(ulf)
+	h.Must(err)
+
+	sw, err := NewSafeWriter(nil, pc.Project.Lock, updatedLock, VendorOnChanged)
+	h.Must(err)
+	excluded := updatedLock.Projects()[0].Ident().ProjectRoot
+	sw.ExcludeFromVendor(map[gps.ProjectRoot]bool{excluded: true})
+	sw.RecordVendorChecksums()
+	sw.PruneVendor(gps.CascadingPruneOptions{
+		DefaultOptions:    gps.PruneUnusedPackages,
+		PerProjectOptions: map[gps.ProjectRoot]gps.PruneOptionSet{excluded: {Mask: gps.PruneGoTestFiles, Options: gps.PruneGoTestFiles}},
+	})
+
+	plan, err := sw.Plan(pc.Project.AbsRoot)
+	h.Must(err)
+	if plan.ManifestDigest != "" || plan.LockDigest == "" {
+		t.Errorf("expected only the digest of the lock, got %q and %q", plan.ManifestDigest, plan.LockDigest)
+	}
+	if !plan.WriteLock || !plan.WriteVendor || !plan.VendorChecksums || plan.Prune.DefaultOptions != gps.PruneUnusedPackages {
+		t.Errorf("expected the plan to write the lock and a sparse vendor/ with checksums, got %+v", plan)
+	}
+	if plan.LockChanges == "" {
+		t.Error("expected the plan to describe the changes to the lock")
+	}
+	if len(plan.Vendor) != len(updatedLock.Projects())-1 {
+		t.Errorf("expected the plan to vendor all the projects but %s, got %v", excluded, plan.Vendor)
+	}
+
+	var buf bytes.Buffer
+	h.Must(WritePlan(&buf, plan))
+	read, err := ReadPlan(&buf)
+	h.Must(err)
+	if !reflect.DeepEqual(read, plan) {
+		t.Fatalf("unexpected plan after a round trip:\n\t(GOT): %+v\n\t(WNT): %+v", read, plan)
+	}
+
+	applied, err := NewSafeWriterFromPlan(pc.Project.AbsRoot, read)
+	h.Must(err)
+	if !applied.writeLock || !applied.writeVendor || !applied.vendorChecksums || applied.keepNestedVendor || !reflect.DeepEqual(applied.pruneOptions, sw.pruneOptions) {
+		t.Errorf("unexpected actions from the plan: %+v", applied)
+	}
+	if !reflect.DeepEqual(applied.vendorExclude, sw.vendorExclude) {
+		t.Errorf("expected %s to be excluded from vendor/, got %v", excluded, applied.vendorExclude)
+	}
+	if gps.DiffLocks(applied.lock, updatedLock) != nil {
+		t.Error("expected the lock of the plan to match the updated lock")
+	}
+
+	// Once the lock changes, the plan no longer applies.
+	h.Must(ioutil.WriteFile(filepath.Join(pc.Project.AbsRoot, LockName), []byte(plan.Lock), 0666))
+	if _, err := NewSafeWriterFromPlan(pc.Project.AbsRoot, read); err == nil {
+		t.Error("expected an error applying a plan to a changed lock")
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/golang/dep/internal/fs"
 	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -19,10 +22,10 @@ var (
 )
 
 // findProjectRoot searches from the starting directory upwards looking for a
-// manifest file until we get to the root of the filesystem.
-func findProjectRoot(from string) (string, error) {
+// manifest file of the given name until we get to the root of the filesystem.
+func findProjectRoot(from, name string) (string, error) {
 	for {
-		mp := filepath.Join(from, ManifestName)
+		mp := filepath.Join(from, name)
 
 		_, err := os.Stat(mp)
 		if err == nil {
@@ -35,6 +38,9 @@ func findProjectRoot(from string) (string, error) {
 
 		parent := filepath.Dir(from)
 		if parent == from {
+			if name != ManifestName {
+				return "", fmt.Errorf("could not find project %s, use dep init to initiate a manifest", name)
+			}
 			return "", errProjectNotFound
 		}
 		from = parent
@@ -52,6 +58,84 @@ type Project struct {
 	ImportRoot gps.ProjectRoot
 	Manifest   *Manifest
 	Lock       *Lock // Optional
+	// ManifestName and LockName are the names of the manifest and lock files
+	// of the project, if not Gopkg.toml and Gopkg.lock.
+	ManifestName, LockName string
+	// Members are the other projects of the workspace the project is the root
+	// of, if its manifest lists any.
+	Members []WorkspaceMember
+}
+
+// ManifestPath returns the path to the manifest file of the project.
+func (p *Project) ManifestPath() string {
+	return filepath.Join(p.AbsRoot, fileName(p.ManifestName, ManifestName))
+}
+
+// LockPath returns the path to the lock file of the project.
+func (p *Project) LockPath() string {
+	return filepath.Join(p.AbsRoot, fileName(p.LockName, LockName))
+}
+
+// VendorDir returns the path to the directory the dependencies of the project
+// are written to: vendor/, unless its manifest sets vendor-dir.
+func (p *Project) VendorDir() string {
+	if p.Manifest == nil || p.Manifest.VendorDir == "" {
+		return filepath.Join(p.AbsRoot, "vendor")
+	}
+	return filepath.Join(p.AbsRoot, filepath.FromSlash(p.Manifest.VendorDir))
+}
+
+// CheckVendorDir returns an error if the vendor tree of the project, once its
+// symlinks are resolved, contains the project itself or its manifest or lock,
+// which replacing or pruning the tree would delete.
+func (p *Project) CheckVendorDir() error {
+	return checkVendorPath(p.VendorDir(), p.AbsRoot, fileName(p.ManifestName, ManifestName), fileName(p.LockName, LockName))
+}
+
+// checkVendorPath returns an error if the vendor tree at vpath, once its
+// symlinks are resolved, contains root or any of the named files beneath it.
+func checkVendorPath(vpath, root string, names ...string) error {
+	vreal := resolvePath(vpath)
+	protected := []string{root}
+	for _, name := range names {
+		protected = append(protected, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	for _, f := range protected {
+		rel, err := filepath.Rel(vreal, resolvePath(f))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return errors.Errorf("refusing to use %s as the vendor tree, as it contains %s", vpath, f)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path p points to, its symlinks resolved as
+// far as it exists.
+func resolvePath(p string) string {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	var rest []string
+	for {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(append([]string{p}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// fileName returns name, or def if name is empty.
+func fileName(name, def string) string {
+	if name != "" {
+		return name
+	}
+	return def
 }
 
 // SetRoot sets the project AbsRoot and ResolvedAbsRoot. If root is a not symlink, ResolvedAbsRoot will be set to root.
@@ -65,16 +149,32 @@ func (p *Project) SetRoot(root string) error {
 	return nil
 }
 
+// Analyzer returns the analyzer of the dependencies of the project, which lifts
+// the revisions they vendor into the solve if the manifest says so.
+func (p *Project) Analyzer() Analyzer {
+	return Analyzer{HintNestedVendor: p.Manifest != nil && p.Manifest.NestedVendorPolicy() == NestedVendorHint}
+}
+
 // MakeParams is a simple helper to create a gps.SolveParameters without setting
 // any nils incorrectly.
 func (p *Project) MakeParams() gps.SolveParameters {
 	params := gps.SolveParameters{
 		RootDir:         p.AbsRoot,
-		ProjectAnalyzer: Analyzer{},
+		ProjectAnalyzer: p.Analyzer(),
 	}
 
 	if p.Manifest != nil {
-		params.Manifest = p.Manifest
+		params.Manifest = p.WithMembers(p.Manifest)
+		params.VendorDir = p.Manifest.VendorDir
+		params.Prereleases = p.Manifest.Prereleases
+		params.UpstreamVersions = p.Manifest.UpstreamVersions
+		params.PatchDigests = p.Manifest.PatchDigests(p.AbsRoot)
+		if gm, ok := params.Manifest.(gps.GoVersionManifest); ok {
+			params.GoVersion = gm.RequiredGoVersion()
+		}
+		if days := p.Manifest.ReleaseCoolDownDays; days > 0 {
+			params.ReleasedBefore = time.Now().AddDate(0, 0, -days)
+		}
 	}
 
 	if p.Lock != nil {
@@ -21,21 +21,21 @@ func TestFindRoot(t *testing.T) {
 	}
 
 	want := filepath.Join(wd, "testdata", "rootfind")
-	got1, err := findProjectRoot(want)
+	got1, err := findProjectRoot(want, ManifestName)
 	if err != nil {
 		t.Errorf("Unexpected error while finding root: %s", err)
 	} else if want != got1 {
 		t.Errorf("findProjectRoot directly on root dir should have found %s, got %s", want, got1)
 	}
 
-	got2, err := findProjectRoot(filepath.Join(want, "subdir"))
+	got2, err := findProjectRoot(filepath.Join(want, "subdir"), ManifestName)
 	if err != nil {
 		t.Errorf("Unexpected error while finding root: %s", err)
 	} else if want != got2 {
 		t.Errorf("findProjectRoot on subdir should have found %s, got %s", want, got2)
 	}
 
-	got3, err := findProjectRoot(filepath.Join(want, "nonexistent"))
+	got3, err := findProjectRoot(filepath.Join(want, "nonexistent"), ManifestName)
 	if err != nil {
 		t.Errorf("Unexpected error while finding root: %s", err)
 	} else if want != got3 {
@@ -43,7 +43,7 @@ func TestFindRoot(t *testing.T) {
 	}
 
 	root := "/"
-	p, err := findProjectRoot(root)
+	p, err := findProjectRoot(root, ManifestName)
 	if p != "" {
 		t.Errorf("findProjectRoot with path %s returned non empty string: %s", root, p)
 	}
@@ -54,7 +54,7 @@ func TestFindRoot(t *testing.T) {
 	// The following test does not work on windows because syscall.Stat does not
 	// return a "not a directory" error.
 	if runtime.GOOS != "windows" {
-		got4, err := findProjectRoot(filepath.Join(want, ManifestName))
+		got4, err := findProjectRoot(filepath.Join(want, ManifestName), ManifestName)
 		if err == nil {
 			t.Errorf("Should have err'd when trying subdir of file, but returned %s", got4)
 		}
@@ -80,6 +80,54 @@ func TestProjectMakeParams(t *testing.T) {
 	}
 }
 
+func TestProjectVendorDir(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "root")
+	cases := []struct {
+		dir, want string
+	}{
+		{"", filepath.Join(root, "vendor")},
+		{"third_party/go", filepath.Join(root, "third_party", "go")},
+	}
+	for _, c := range cases {
+		p := Project{AbsRoot: root, Manifest: &Manifest{VendorDir: c.dir}}
+		if got := p.VendorDir(); got != c.want {
+			t.Errorf("VendorDir() with vendor-dir %q = %q, want %q", c.dir, got, c.want)
+		}
+	}
+}
+
+func TestProjectCheckVendorDir(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile("root/Gopkg.toml", "")
+	h.TempFile("root/deps/Gopkg.lock", "")
+	root := h.Path("root")
+
+	p := &Project{AbsRoot: root, Manifest: &Manifest{VendorDir: "third_party/go"}}
+	if err := p.CheckVendorDir(); err != nil {
+		t.Errorf("unexpected error for a vendor-dir within the project: %s", err)
+	}
+
+	// The lock lies within the vendor tree.
+	p.LockName = "deps/Gopkg.lock"
+	p.Manifest.VendorDir = "deps"
+	if err := p.CheckVendorDir(); err == nil {
+		t.Error("expected an error for a vendor tree containing the lock")
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	// The vendor symlink points to the parent of the project.
+	h.Must(os.Symlink(h.Path("."), filepath.Join(root, "vendor")))
+	p = &Project{AbsRoot: root, Manifest: &Manifest{}}
+	if err := p.CheckVendorDir(); err == nil {
+		t.Error("expected an error for a vendor symlink to the parent of the project")
+	}
+}
+
 func TestBackupVendor(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -0,0 +1,170 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/paths"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// TestOnlyProjects returns the roots of the projects in the lock which are only
+// reachable through the test imports of the root project's packages, as
+// opposed to being needed to build them. Required packages from the manifest
+// count as needed to build the root project.
+//
+// Reachability is transitive: a project imported only by a test-only project is
+// test-only as well. Only the root project's tests are taken into account;
+// the tests of dependencies are never built from vendor/.
+func TestOnlyProjects(ptree pkgtree.PackageTree, m *Manifest, l *Lock, sm gps.SourceManager) (map[gps.ProjectRoot]bool, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	var ignored map[string]bool
+	var required []string
+	if m != nil {
+		ignored = m.IgnoredPackages()
+		required = m.Required
+	}
+
+	rm, _ := ptree.ToReachMap(true, false, false, ignored)
+	prod := append(rm.FlattenFn(paths.IsStandardImportPath), required...)
+
+	rmt, _ := ptree.ToReachMap(true, true, false, ignored)
+	withTests := append(rmt.FlattenFn(paths.IsStandardImportPath), required...)
+
+	r := &lockReacher{
+		lock:    l,
+		sm:      sm,
+		ignored: pkgtree.NewIgnoreRules(ignored),
+		ptrees:  make(map[gps.ProjectRoot]pkgtree.PackageTree),
+	}
+
+	needed, err := r.reach(prod)
+	if err != nil {
+		return nil, err
+	}
+	reached, err := r.reach(withTests)
+	if err != nil {
+		return nil, err
+	}
+
+	testOnly := make(map[gps.ProjectRoot]bool)
+	for pr := range reached {
+		if !needed[pr] {
+			testOnly[pr] = true
+		}
+	}
+	return testOnly, nil
+}
+
+// lockReacher walks the package-level import graph of the projects in a lock.
+type lockReacher struct {
+	lock    *Lock
+	sm      gps.SourceManager
+	ignored *pkgtree.IgnoreRules
+	ptrees  map[gps.ProjectRoot]pkgtree.PackageTree
+}
+
+// projectFor returns the locked project providing the package at path.
+func (r *lockReacher) projectFor(path string) (gps.LockedProject, bool) {
+	var found gps.LockedProject
+	var ok bool
+	for _, lp := range r.lock.P {
+		root := string(lp.Ident().ProjectRoot)
+		if path != root && !strings.HasPrefix(path, root+"/") {
+			continue
+		}
+		// Prefer the longest matching root, in case of nested projects.
+		if !ok || len(root) > len(found.Ident().ProjectRoot) {
+			found, ok = lp, true
+		}
+	}
+	return found, ok
+}
+
+// reach returns the roots of the locked projects that are transitively
+// imported by the given packages.
+func (r *lockReacher) reach(imports []string) (map[gps.ProjectRoot]bool, error) {
+	reached := make(map[gps.ProjectRoot]bool)
+	seen := make(map[string]bool)
+
+	queue := append([]string(nil), imports...)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if seen[path] || r.ignored.IsIgnored(path) || paths.IsStandardImportPath(path) {
+			continue
+		}
+		seen[path] = true
+
+		lp, ok := r.projectFor(path)
+		if !ok {
+			// Not provided by the lock; nothing to vendor, hence nothing to
+			// classify.
+			continue
+		}
+		pr := lp.Ident().ProjectRoot
+		reached[pr] = true
+
+		ptree, has := r.ptrees[pr]
+		if !has {
+			var err error
+			ptree, err = r.sm.ListPackages(lp.Ident(), lp.Version())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list packages of %s", pr)
+			}
+			r.ptrees[pr] = ptree
+		}
+
+		if poe, has := ptree.Packages[path]; has && poe.Err == nil {
+			queue = append(queue, poe.P.Imports...)
+		}
+	}
+
+	return reached, nil
+}
+
+// UnusedProjects returns the roots of the projects in the lock which aren't
+// reachable at all from the root project's packages, their tests, or the
+// required packages from the manifest.
+func UnusedProjects(ptree pkgtree.PackageTree, m *Manifest, l *Lock, sm gps.SourceManager) (map[gps.ProjectRoot]bool, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	var ignored map[string]bool
+	var required []string
+	if m != nil {
+		ignored = m.IgnoredPackages()
+		required = m.Required
+	}
+
+	rm, _ := ptree.ToReachMap(true, true, false, ignored)
+	imports := append(rm.FlattenFn(paths.IsStandardImportPath), required...)
+
+	r := &lockReacher{
+		lock:    l,
+		sm:      sm,
+		ignored: pkgtree.NewIgnoreRules(ignored),
+		ptrees:  make(map[gps.ProjectRoot]pkgtree.PackageTree),
+	}
+	reached, err := r.reach(imports)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := make(map[gps.ProjectRoot]bool)
+	for _, lp := range l.P {
+		if pr := lp.Ident().ProjectRoot; !reached[pr] {
+			unused[pr] = true
+		}
+	}
+	return unused, nil
+}
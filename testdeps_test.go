@@ -0,0 +1,140 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+)
+
+// ptreeSM serves fixed package trees, keyed by project root. All the other
+// SourceManager methods are left unimplemented.
+type ptreeSM struct {
+	gps.SourceManager
+	ptrees map[gps.ProjectRoot]pkgtree.PackageTree
+}
+
+func (sm ptreeSM) ListPackages(id gps.ProjectIdentifier, v gps.Version) (pkgtree.PackageTree, error) {
+	return sm.ptrees[id.ProjectRoot], nil
+}
+
+func mkPackageTree(root string, pkgs ...pkgtree.Package) pkgtree.PackageTree {
+	ptree := pkgtree.PackageTree{
+		ImportRoot: root,
+		Packages:   make(map[string]pkgtree.PackageOrErr),
+	}
+	for _, p := range pkgs {
+		ptree.Packages[p.ImportPath] = pkgtree.PackageOrErr{P: p}
+	}
+	return ptree
+}
+
+func TestTestOnlyProjects(t *testing.T) {
+	root := mkPackageTree("example.com/root",
+		pkgtree.Package{
+			Name:        "root",
+			ImportPath:  "example.com/root",
+			Imports:     []string{"fmt", "github.com/prod/lib"},
+			TestImports: []string{"testing", "github.com/test/assert", "github.com/prod/lib/sub"},
+		},
+	)
+
+	sm := ptreeSM{ptrees: map[gps.ProjectRoot]pkgtree.PackageTree{
+		"github.com/prod/lib": mkPackageTree("github.com/prod/lib",
+			pkgtree.Package{Name: "lib", ImportPath: "github.com/prod/lib", Imports: []string{"github.com/prod/util"}},
+			pkgtree.Package{Name: "sub", ImportPath: "github.com/prod/lib/sub", Imports: []string{"github.com/test/diff"}},
+		),
+		"github.com/prod/util": mkPackageTree("github.com/prod/util",
+			pkgtree.Package{Name: "util", ImportPath: "github.com/prod/util"},
+		),
+		"github.com/test/assert": mkPackageTree("github.com/test/assert",
+			pkgtree.Package{Name: "assert", ImportPath: "github.com/test/assert", Imports: []string{"github.com/test/diff", "github.com/prod/util"}},
+		),
+		"github.com/test/diff": mkPackageTree("github.com/test/diff",
+			pkgtree.Package{Name: "diff", ImportPath: "github.com/test/diff"},
+		),
+		"github.com/req/tool": mkPackageTree("github.com/req/tool",
+			pkgtree.Package{Name: "main", ImportPath: "github.com/req/tool"},
+		),
+	}}
+
+	var lps []gps.LockedProject
+	for pr := range sm.ptrees {
+		lps = append(lps, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0"), nil))
+	}
+	l := &Lock{P: lps}
+	m := &Manifest{Required: []string{"github.com/req/tool"}}
+
+	got, err := TestOnlyProjects(root, m, l, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[gps.ProjectRoot]bool{
+		"github.com/test/assert": true,
+		"github.com/test/diff":   true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected test-only projects:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
+
+func TestUnusedProjects(t *testing.T) {
+	root := mkPackageTree("example.com/root",
+		pkgtree.Package{
+			Name:        "root",
+			ImportPath:  "example.com/root",
+			Imports:     []string{"fmt", "github.com/prod/lib", "github.com/ignored/pkg"},
+			TestImports: []string{"testing", "github.com/test/assert"},
+		},
+	)
+
+	sm := ptreeSM{ptrees: map[gps.ProjectRoot]pkgtree.PackageTree{
+		"github.com/prod/lib": mkPackageTree("github.com/prod/lib",
+			pkgtree.Package{Name: "lib", ImportPath: "github.com/prod/lib", Imports: []string{"github.com/prod/util"}},
+		),
+		"github.com/prod/util": mkPackageTree("github.com/prod/util",
+			pkgtree.Package{Name: "util", ImportPath: "github.com/prod/util"},
+		),
+		"github.com/test/assert": mkPackageTree("github.com/test/assert",
+			pkgtree.Package{Name: "assert", ImportPath: "github.com/test/assert"},
+		),
+		"github.com/req/tool": mkPackageTree("github.com/req/tool",
+			pkgtree.Package{Name: "main", ImportPath: "github.com/req/tool"},
+		),
+		"github.com/ignored/pkg": mkPackageTree("github.com/ignored/pkg",
+			pkgtree.Package{Name: "pkg", ImportPath: "github.com/ignored/pkg"},
+		),
+		"github.com/old/dep": mkPackageTree("github.com/old/dep",
+			pkgtree.Package{Name: "dep", ImportPath: "github.com/old/dep"},
+		),
+	}}
+
+	var lps []gps.LockedProject
+	for pr := range sm.ptrees {
+		lps = append(lps, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0"), nil))
+	}
+	l := &Lock{P: lps}
+	m := &Manifest{
+		Required: []string{"github.com/req/tool"},
+		Ignored:  []string{"github.com/ignored/pkg"},
+	}
+
+	got, err := UnusedProjects(root, m, l, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[gps.ProjectRoot]bool{
+		"github.com/ignored/pkg": true,
+		"github.com/old/dep":     true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected unused projects:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/golang/dep/internal/fs"
 	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 )
@@ -62,16 +64,51 @@ type SafeWriter struct {
 	lockDiff    *gps.LockDiff
 	writeVendor bool
 	writeLock   bool
+
+	// vendorExclude holds the projects to leave out of the vendor tree.
+	vendorExclude map[gps.ProjectRoot]bool
+	// vendorChecksums indicates whether to record the checksums of the files
+	// in the vendor tree.
+	vendorChecksums bool
+	// keepNestedVendor indicates whether to keep the vendor directories of the
+	// projects in the vendor tree.
+	keepNestedVendor bool
+	// pruneOptions tells which files to prune from the projects in the vendor
+	// tree.
+	pruneOptions gps.CascadingPruneOptions
+	// nestedVendorConflicts holds the conflicts found while writing the vendor
+	// tree.
+	nestedVendorConflicts []NestedVendorConflict
+	// manifestName and lockName are the names of the manifest and lock files,
+	// if not ManifestName and LockName.
+	manifestName, lockName string
+	// vendorDir is the directory the vendor tree is written to, if not vendor
+	// beneath the root. A relative path is relative to the root.
+	vendorDir string
+	// workers is the number of projects to write to the vendor tree at once,
+	// if not the number of CPUs.
+	workers int
+	// reuseVendor indicates whether to keep the projects of the existing
+	// vendor tree which still match their digest in the lock.
+	reuseVendor bool
+	// verifyDigests indicates whether to check the projects written to the
+	// vendor tree against their digest in the lock.
+	verifyDigests bool
+	// patches holds the patch files applied to the projects written to the
+	// vendor tree, keyed by project root, relative to the root.
+	patches map[gps.ProjectRoot][]string
 }
 
 // NewSafeWriter sets up a SafeWriter to write a set of manifest, lock, and
 // vendor tree.
 //
 // - If manifest is provided, it will be written to the standard manifest file
-// name beneath root.
+// name beneath root, or that given to UseFileNames.
 //
 // - If newLock is provided, it will be written to the standard lock file
-// name beneath root.
+// name beneath root, or that given to UseFileNames, with its generation bumped
+// past that of oldLock and in its format version, if newer. A newer format
+// version alone gets newLock written.
 //
 // - If vendor is VendorAlways, or is VendorOnChanged and the locks are different,
 // the vendor directory will be written beneath root based on newLock.
@@ -91,18 +128,40 @@ func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBeha
 		}
 
 		sw.lockDiff = gps.DiffLocks(oldLock, newLock)
-		if sw.lockDiff != nil {
+		if sw.lockDiff != nil || !devEqual(oldLock.Dev, newLock.Dev) || newLock.FormatVersion > oldLock.FormatVersion {
 			sw.writeLock = true
 		}
 	} else if newLock != nil {
 		sw.writeLock = true
 	}
 
+	if sw.writeLock {
+		// Each change bumps the generation of the lock, without touching the
+		// caller's.
+		nl := *newLock
+		if oldLock != nil && oldLock.SolveMeta.Generation > nl.SolveMeta.Generation {
+			nl.SolveMeta.Generation = oldLock.SolveMeta.Generation
+		}
+		nl.SolveMeta.Generation++
+		if oldLock != nil && oldLock.FormatVersion > nl.FormatVersion {
+			nl.FormatVersion = oldLock.FormatVersion
+		}
+		// The digests of the projects locked as before still tell how they
+		// were written to vendor/; those of the others are recorded anew if
+		// vendor/ is written.
+		if nl.Digests == nil && oldLock != nil {
+			nl.Digests = unchangedDigests(oldLock, newLock)
+		}
+		sw.lock = &nl
+	}
+
 	switch vendor {
 	case VendorAlways:
 		sw.writeVendor = true
 	case VendorOnChanged:
-		sw.writeVendor = sw.lockDiff != nil || (newLock != nil && oldLock == nil)
+		// A change in the dev projects also changes what goes into vendor/, so
+		// it counts just as much as a change in the lock.
+		sw.writeVendor = sw.writeLock
 	}
 
 	if sw.writeVendor && newLock == nil {
@@ -112,11 +171,246 @@ func NewSafeWriter(manifest *Manifest, oldLock, newLock *Lock, vendor VendorBeha
 	return sw, nil
 }
 
+// ExcludeFromVendor configures the SafeWriter to leave the given projects out
+// of the vendor tree it writes. They are still recorded in the lock.
+func (sw *SafeWriter) ExcludeFromVendor(roots map[gps.ProjectRoot]bool) {
+	sw.vendorExclude = roots
+}
+
+// RecordVendorChecksums configures the SafeWriter to record the checksum of
+// every file of the vendor tree it writes, in vendor/.dep-checksums, and the
+// digest of every project of it, in the lock.
+func (sw *SafeWriter) RecordVendorChecksums() {
+	sw.vendorChecksums = true
+}
+
+// KeepNestedVendor configures the SafeWriter to keep the vendor directories
+// of the projects in the vendor tree it writes, instead of stripping them.
+func (sw *SafeWriter) KeepNestedVendor() {
+	sw.keepNestedVendor = true
+}
+
+// PruneVendor configures the SafeWriter to prune the projects of the vendor
+// tree it writes according to the given options.
+func (sw *SafeWriter) PruneVendor(o gps.CascadingPruneOptions) {
+	sw.pruneOptions = o
+}
+
+// ApplyPatches configures the SafeWriter to apply the given unified diff files
+// to the projects of the vendor tree it writes, once exported, keyed by project
+// root. The paths of the files are relative to the root given to Write. The
+// patched projects are always written anew, since their patches may have
+// changed.
+func (sw *SafeWriter) ApplyPatches(patches map[gps.ProjectRoot][]string) {
+	sw.patches = patches
+}
+
+// ReuseUnchangedVendor configures the SafeWriter to keep the projects of the
+// existing vendor tree whose directory still matches their digest in the lock,
+// rather than writing them anew, so that only the other projects are exported
+// from the SourceManager. Digests are only in the lock when vendor checksums
+// are recorded; see RecordVendorChecksums.
+func (sw *SafeWriter) ReuseUnchangedVendor() {
+	sw.reuseVendor = true
+}
+
+// VerifyDigests configures the SafeWriter to check the projects it writes to
+// the vendor tree against their digest in the lock, for those locked as they
+// were when it was recorded, and to fail without writing anything if any of
+// them differs: their source no longer holds what it held at the locked
+// revision, as happens when its history is rewritten.
+func (sw *SafeWriter) VerifyDigests() {
+	sw.verifyDigests = true
+}
+
+// ConfigureVendor configures how the SafeWriter writes the vendor tree, as dep
+// ensure does for the project of manifest m and new lock l: the dev projects of
+// l are left out unless dev is set, and m tells whether to record the checksums
+// of the vendored files, to keep nested vendor directories, which files to
+// prune from each project, and which patches to apply to them. If verify is
+// set, the projects are checked against their digest in the lock; see
+// VerifyDigests.
+func (sw *SafeWriter) ConfigureVendor(m *Manifest, l *Lock, dev, verify bool) {
+	if !dev && l != nil {
+		sw.ExcludeFromVendor(l.Dev)
+	}
+	if m != nil {
+		if m.VendorChecksums {
+			sw.RecordVendorChecksums()
+		}
+		if m.NestedVendorPolicy() == NestedVendorKeep {
+			sw.KeepNestedVendor()
+		}
+		sw.PruneVendor(m.VendorPruneOptions())
+		sw.ApplyPatches(m.Patches)
+	}
+	if verify {
+		sw.VerifyDigests()
+	}
+}
+
+// UseFileNames configures the SafeWriter to write the manifest and lock to
+// files of the given names beneath root, instead of Gopkg.toml and Gopkg.lock.
+// Empty names leave the standard ones.
+func (sw *SafeWriter) UseFileNames(manifest, lock string) {
+	sw.manifestName, sw.lockName = manifest, lock
+}
+
+// UseVendorDir configures the SafeWriter to write the vendor tree to dir
+// instead of vendor beneath root, as set by vendor-dir in the manifest. The dir
+// is relative to root, and must lie within it; an empty one leaves the
+// default.
+func (sw *SafeWriter) UseVendorDir(dir string) {
+	sw.vendorDir = dir
+}
+
+// vendorPath returns the path of the vendor tree written beneath root. When
+// it's a symlink, as for a vendor tree kept in a cache, the directory it
+// points to is replaced instead, keeping the link in place. It refuses a tree
+// containing the project, or its manifest or lock, as replacing it would
+// delete them.
+func (sw *SafeWriter) vendorPath(root string) (string, error) {
+	vpath := filepath.Join(root, "vendor")
+	if sw.vendorDir != "" {
+		if !validVendorDir(sw.vendorDir) {
+			return "", errInvalidVendorDir
+		}
+		vpath = filepath.Join(root, filepath.FromSlash(sw.vendorDir))
+	}
+	if err := checkVendorPath(vpath, root, fileName(sw.manifestName, ManifestName), fileName(sw.lockName, LockName)); err != nil {
+		return "", err
+	}
+
+	fi, err := os.Lstat(vpath)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return vpath, nil
+	}
+	target, err := filepath.EvalSymlinks(vpath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve the vendor symlink %s", vpath)
+	}
+	return target, nil
+}
+
+// ExportWorkers configures the SafeWriter to write the projects of the vendor
+// tree n at a time, instead of as many as there are CPUs. A non-positive n
+// leaves the default.
+func (sw *SafeWriter) ExportWorkers(n int) {
+	sw.workers = n
+}
+
+// NestedVendorConflicts returns the projects vendored by the projects of the
+// vendor tree at another revision than the lock's, as found by the last Write.
+func (sw *SafeWriter) NestedVendorConflicts() []NestedVendorConflict {
+	return sw.nestedVendorConflicts
+}
+
+// vendorLock returns the lock from which the vendor tree is written.
+func (sw *SafeWriter) vendorLock() gps.Lock {
+	return lockWithout(sw.lock, sw.vendorExclude)
+}
+
+// lockWithout returns the projects of l but the given ones.
+func lockWithout(l gps.Lock, roots map[gps.ProjectRoot]bool) gps.Lock {
+	if len(roots) == 0 {
+		return l
+	}
+
+	var wl gps.SimpleLock
+	for _, lp := range l.Projects() {
+		if !roots[lp.Ident().ProjectRoot] {
+			wl = append(wl, lp)
+		}
+	}
+	return wl
+}
+
+// unchangedVendorProjects returns the projects of l whose directory beneath
+// vendorDir matches their digest. Projects nested in one another are left
+// out, as their directories can't be moved apart.
+func unchangedVendorProjects(vendorDir string, l gps.Lock, digests map[gps.ProjectRoot][]byte) (map[gps.ProjectRoot]bool, error) {
+	lps := l.Projects()
+	unchanged := make(map[gps.ProjectRoot]bool)
+	for _, lp := range lps {
+		pr := lp.Ident().ProjectRoot
+		want, has := digests[pr]
+		if !has || nestsWithOther(pr, lps) {
+			continue
+		}
+
+		dir := filepath.Join(vendorDir, filepath.FromSlash(string(pr)))
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			continue
+		}
+		got, err := pkgtree.DigestFromDirectory(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute the digest of %s", pr)
+		}
+		if bytes.Equal(got, want) {
+			unchanged[pr] = true
+		}
+	}
+	return unchanged, nil
+}
+
+// nestsWithOther checks whether the root of another of the given projects
+// lies beneath pr, or pr beneath it.
+func nestsWithOther(pr gps.ProjectRoot, lps []gps.LockedProject) bool {
+	for _, lp := range lps {
+		other := lp.Ident().ProjectRoot
+		if strings.HasPrefix(string(other), string(pr)+"/") || strings.HasPrefix(string(pr), string(other)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// unchangedDigests returns the digests of oldLock of the projects locked
+// alike in newLock.
+func unchangedDigests(oldLock, newLock *Lock) map[gps.ProjectRoot][]byte {
+	var digests map[gps.ProjectRoot][]byte
+	for _, olp := range oldLock.P {
+		pr := olp.Ident().ProjectRoot
+		digest, has := oldLock.Digests[pr]
+		if !has {
+			continue
+		}
+		for _, nlp := range newLock.P {
+			if nlp.Eq(olp) {
+				if digests == nil {
+					digests = make(map[gps.ProjectRoot][]byte)
+				}
+				digests[pr] = digest
+				break
+			}
+		}
+	}
+	return digests
+}
+
+// devEqual checks whether two sets of dev projects are the same.
+func devEqual(a, b map[gps.ProjectRoot]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for pr := range a {
+		if !b[pr] {
+			return false
+		}
+	}
+	return true
+}
+
 // HasLock checks if a Lock is present in the SafeWriter
 func (sw *SafeWriter) HasLock() bool {
 	return sw.lock != nil
 }
 
+// HasVendor checks if the SafeWriter writes vendor/
+func (sw *SafeWriter) HasVendor() bool {
+	return sw.writeVendor
+}
+
 // HasManifest checks if a Manifest is present in the SafeWriter
 func (sw *SafeWriter) HasManifest() bool {
 	return sw.Manifest != nil
@@ -272,9 +566,13 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		return nil
 	}
 
-	mpath := filepath.Join(root, ManifestName)
-	lpath := filepath.Join(root, LockName)
-	vpath := filepath.Join(root, "vendor")
+	mname, lname := fileName(sw.manifestName, ManifestName), fileName(sw.lockName, LockName)
+	mpath := filepath.Join(root, mname)
+	lpath := filepath.Join(root, lname)
+	vpath, err := sw.vendorPath(root)
+	if err != nil {
+		return err
+	}
 
 	td, err := ioutil.TempDir(os.TempDir(), "dep")
 	if err != nil {
@@ -296,26 +594,123 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 			initOutput = exampleTOML
 		}
 
-		if err = ioutil.WriteFile(filepath.Join(td, ManifestName), append(initOutput, tb...), 0666); err != nil {
+		if err = ioutil.WriteFile(filepath.Join(td, mname), append(initOutput, tb...), 0666); err != nil {
 			return errors.Wrap(err, "failed to write manifest file to temp dir")
 		}
 	}
 
-	if sw.writeLock {
-		l, err := sw.lock.MarshalTOML()
+	// The unchanged projects of vendor/ are moved into the new vendor tree as
+	// they are. They are moved back unless the new tree makes it into place.
+	var unchanged []gps.ProjectRoot
+	vendorCommitted := false
+	defer func() {
+		if vendorCommitted {
+			return
+		}
+		for _, pr := range unchanged {
+			to := filepath.Join(vpath, filepath.FromSlash(string(pr)))
+			if err := os.MkdirAll(filepath.Dir(to), 0777); err == nil {
+				fs.RenameWithFallback(filepath.Join(td, "vendor", filepath.FromSlash(string(pr))), to)
+			}
+		}
+	}()
+
+	if sw.writeVendor {
+		vl := sw.vendorLock()
+		wl := vl
+		var reuse map[gps.ProjectRoot]bool
+		if sw.reuseVendor && len(sw.lock.Digests) > 0 {
+			if reuse, err = unchangedVendorProjects(vpath, vl, sw.lock.Digests); err != nil {
+				return err
+			}
+			for pr := range sw.patches {
+				delete(reuse, pr)
+			}
+			wl = lockWithout(vl, reuse)
+		}
+
+		err = gps.WriteDepTreeParallel(filepath.Join(td, "vendor"), wl, sm, false, sw.workers, logger)
 		if err != nil {
-			return errors.Wrap(err, "failed to marshal lock to TOML")
+			return errors.Wrap(err, "error while writing out vendor tree")
+		}
+		if err = applyVendorPatches(filepath.Join(td, "vendor"), root, wl, sw.patches, logger); err != nil {
+			return err
 		}
 
-		if err = ioutil.WriteFile(filepath.Join(td, LockName), append(lockFileComment, l...), 0666); err != nil {
-			return errors.Wrap(err, "failed to write lock file to temp dir")
+		sw.nestedVendorConflicts, err = FindNestedVendorConflicts(filepath.Join(td, "vendor"), wl)
+		if err != nil {
+			return err
+		}
+		if err = gps.PruneDepTree(filepath.Join(td, "vendor"), wl, sw.pruneOptions); err != nil {
+			return errors.Wrap(err, "error while pruning vendor tree")
+		}
+
+		for _, lp := range vl.Projects() {
+			pr := lp.Ident().ProjectRoot
+			if !reuse[pr] {
+				continue
+			}
+			to := filepath.Join(td, "vendor", filepath.FromSlash(string(pr)))
+			if err = os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+				return err
+			}
+			if err = fs.RenameWithFallback(filepath.Join(vpath, filepath.FromSlash(string(pr))), to); err != nil {
+				return errors.Wrapf(err, "failed to keep %s from the vendor tree", pr)
+			}
+			unchanged = append(unchanged, pr)
+			if logger != nil {
+				logger.Printf("Keeping %s, unchanged in vendor/", pr)
+			}
+		}
+
+		// Nested vendor directories aren't part of the digests, so they are
+		// stripped from the unchanged projects too.
+		if !sw.keepNestedVendor {
+			if err = gps.StripNestedVendor(filepath.Join(td, "vendor"), vl); err != nil {
+				return errors.Wrap(err, "error while stripping nested vendor directories")
+			}
+		}
+
+		var digests map[gps.ProjectRoot][]byte
+		if sw.vendorChecksums || sw.verifyDigests {
+			if digests, err = vendorDigests(filepath.Join(td, "vendor"), wl); err != nil {
+				return err
+			}
+			if sw.verifyDigests {
+				if err = verifyDigests(digests, sw.lock.Digests); err != nil {
+					return err
+				}
+			}
+			for pr := range reuse {
+				digests[pr] = sw.lock.Digests[pr]
+			}
+		}
+		if sw.vendorChecksums {
+			if err = WriteVendorChecksums(filepath.Join(td, "vendor")); err != nil {
+				return err
+			}
+		} else {
+			digests = nil
+		}
+
+		// The lock is written anew whenever the digests of vendor/ change,
+		// even if nothing else in it did.
+		if !digestsEqual(digests, sw.lock.Digests) {
+			nl := *sw.lock
+			nl.Digests = digests
+			sw.lock = &nl
+			sw.writeLock = true
 		}
 	}
 
-	if sw.writeVendor {
-		err = gps.WriteDepTree(filepath.Join(td, "vendor"), sw.lock, sm, true, logger)
+	if sw.writeLock {
+		l, err := sw.lock.MarshalTOML()
 		if err != nil {
-			return errors.Wrap(err, "error while writing out vendor tree")
+			return errors.Wrap(err, "failed to marshal lock to TOML")
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(td, lname), append(lockFileComment, l...), 0666); err != nil {
+			return errors.Wrap(err, "failed to write lock file to temp dir")
 		}
 	}
 
@@ -339,7 +734,7 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 	if sw.HasManifest() {
 		if _, err := os.Stat(mpath); err == nil {
 			// Move out the old one.
-			tmploc := filepath.Join(td, ManifestName+".orig")
+			tmploc := filepath.Join(td, mname+".orig")
 			failerr = fs.RenameWithFallback(mpath, tmploc)
 			if failerr != nil {
 				goto fail
@@ -348,7 +743,7 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		}
 
 		// Move in the new one.
-		failerr = fs.RenameWithFallback(filepath.Join(td, ManifestName), mpath)
+		failerr = fs.RenameWithFallback(filepath.Join(td, mname), mpath)
 		if failerr != nil {
 			goto fail
 		}
@@ -357,7 +752,7 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 	if sw.writeLock {
 		if _, err := os.Stat(lpath); err == nil {
 			// Move out the old one.
-			tmploc := filepath.Join(td, LockName+".orig")
+			tmploc := filepath.Join(td, lname+".orig")
 
 			failerr = fs.RenameWithFallback(lpath, tmploc)
 			if failerr != nil {
@@ -367,7 +762,7 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 		}
 
 		// Move in the new one.
-		failerr = fs.RenameWithFallback(filepath.Join(td, LockName), lpath)
+		failerr = fs.RenameWithFallback(filepath.Join(td, lname), lpath)
 		if failerr != nil {
 			goto fail
 		}
@@ -392,11 +787,16 @@ func (sw *SafeWriter) Write(root string, sm gps.SourceManager, examples bool, lo
 			restore = append(restore, pathpair{from: vendorbak, to: vpath})
 		}
 
-		// Move in the new one.
+		// Move in the new one, creating the parents of a vendor-dir.
+		failerr = os.MkdirAll(filepath.Dir(vpath), 0777)
+		if failerr != nil {
+			goto fail
+		}
 		failerr = fs.RenameWithFallback(filepath.Join(td, "vendor"), vpath)
 		if failerr != nil {
 			goto fail
 		}
+		vendorCommitted = true
 	}
 
 	// Renames all went smoothly. The deferred os.RemoveAll will get the temp
@@ -420,7 +820,7 @@ fail:
 // PrintPreparedActions logs the actions a call to Write would perform.
 func (sw *SafeWriter) PrintPreparedActions(output *log.Logger) error {
 	if sw.HasManifest() {
-		output.Printf("Would have written the following %s:\n", ManifestName)
+		output.Printf("Would have written the following %s:\n", fileName(sw.manifestName, ManifestName))
 		m, err := sw.Manifest.MarshalTOML()
 		if err != nil {
 			return errors.Wrap(err, "ensure DryRun cannot serialize manifest")
@@ -430,14 +830,14 @@ func (sw *SafeWriter) PrintPreparedActions(output *log.Logger) error {
 
 	if sw.writeLock {
 		if sw.lockDiff == nil {
-			output.Printf("Would have written the following %s:\n", LockName)
+			output.Printf("Would have written the following %s:\n", fileName(sw.lockName, LockName))
 			l, err := sw.lock.MarshalTOML()
 			if err != nil {
 				return errors.Wrap(err, "ensure DryRun cannot serialize lock")
 			}
 			output.Println(string(l))
 		} else {
-			output.Printf("Would have written the following changes to %s:\n", LockName)
+			output.Printf("Would have written the following changes to %s:\n", fileName(sw.lockName, LockName))
 			diff, err := formatLockDiff(*sw.lockDiff)
 			if err != nil {
 				return errors.Wrap(err, "ensure DryRun cannot serialize the lock diff")
@@ -448,7 +848,7 @@ func (sw *SafeWriter) PrintPreparedActions(output *log.Logger) error {
 
 	if sw.writeVendor {
 		output.Println("Would have written the following projects to the vendor directory:")
-		for _, project := range sw.lock.Projects() {
+		for _, project := range sw.vendorLock().Projects() {
 			output.Println(project)
 		}
 	}
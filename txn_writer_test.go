@@ -8,9 +8,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/golang/dep/internal/gps"
 	"github.com/golang/dep/internal/test"
 	"github.com/pkg/errors"
 )
@@ -19,6 +23,10 @@ const safeWriterProject = "safewritertest"
 const safeWriterGoldenManifest = "txn_writer/expected_manifest.toml"
 const safeWriterGoldenLock = "txn_writer/expected_lock.toml"
 
+// safeWriterGoldenWrittenLock is safeWriterGoldenLock once written anew, with
+// its generation bumped.
+const safeWriterGoldenWrittenLock = "txn_writer/expected_written_lock.toml"
+
 func TestSafeWriter_BadInput_MissingRoot(t *testing.T) {
 	h := test.NewHelper(t)
 	defer h.Cleanup()
@@ -292,7 +300,7 @@ func TestSafeWriter_ModifiedLock(t *testing.T) {
 	if err := pc.ManifestShouldNotExist(); err != nil {
 		t.Fatal(err)
 	}
-	if err := pc.LockShouldMatchGolden(safeWriterGoldenLock); err != nil {
+	if err := pc.LockShouldMatchGolden(safeWriterGoldenWrittenLock); err != nil {
 		t.Fatal(err)
 	}
 	if err := pc.VendorShouldExist(); err != nil {
@@ -342,7 +350,7 @@ func TestSafeWriter_ModifiedLockSkipVendor(t *testing.T) {
 	if err := pc.ManifestShouldNotExist(); err != nil {
 		t.Fatal(err)
 	}
-	if err := pc.LockShouldMatchGolden(safeWriterGoldenLock); err != nil {
+	if err := pc.LockShouldMatchGolden(safeWriterGoldenWrittenLock); err != nil {
 		t.Fatal(err)
 	}
 	if err := pc.VendorShouldNotExist(); err != nil {
@@ -388,7 +396,7 @@ func TestSafeWriter_ForceVendorWhenVendorAlreadyExists(t *testing.T) {
 	if err := pc.ManifestShouldNotExist(); err != nil {
 		t.Fatal(err)
 	}
-	if err := pc.LockShouldMatchGolden(safeWriterGoldenLock); err != nil {
+	if err := pc.LockShouldMatchGolden(safeWriterGoldenWrittenLock); err != nil {
 		t.Fatal(err)
 	}
 	if err := pc.VendorShouldExist(); err != nil {
@@ -438,7 +446,7 @@ func TestSafeWriter_NewLock(t *testing.T) {
 	if err := pc.ManifestShouldNotExist(); err != nil {
 		t.Fatal(err)
 	}
-	if err := pc.LockShouldMatchGolden(safeWriterGoldenLock); err != nil {
+	if err := pc.LockShouldMatchGolden(safeWriterGoldenWrittenLock); err != nil {
 		t.Fatal(err)
 	}
 	if err := pc.VendorShouldExist(); err != nil {
@@ -485,7 +493,7 @@ func TestSafeWriter_NewLockSkipVendor(t *testing.T) {
 	if err := pc.ManifestShouldNotExist(); err != nil {
 		t.Fatal(err)
 	}
-	if err := pc.LockShouldMatchGolden(safeWriterGoldenLock); err != nil {
+	if err := pc.LockShouldMatchGolden(safeWriterGoldenWrittenLock); err != nil {
 		t.Fatal(err)
 	}
 	if err := pc.VendorShouldNotExist(); err != nil {
@@ -526,6 +534,261 @@ func TestSafeWriter_DiffLocks(t *testing.T) {
 	}
 }
 
+func TestSafeWriter_ExcludeFromVendor(t *testing.T) {
+	mkLP := func(pr string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(pr)}, gps.NewVersion("v1.0.0"), nil)
+	}
+	l := &Lock{P: []gps.LockedProject{mkLP("github.com/a/a"), mkLP("github.com/b/b"), mkLP("github.com/c/c")}}
+
+	sw, err := NewSafeWriter(nil, nil, l, VendorAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(sw.vendorLock().Projects()); got != 3 {
+		t.Fatalf("Expected all 3 projects to be vendored, got %d", got)
+	}
+
+	sw.ExcludeFromVendor(map[gps.ProjectRoot]bool{"github.com/b/b": true})
+	vl := sw.vendorLock().Projects()
+	if len(vl) != 2 || vl[0].Ident().ProjectRoot != "github.com/a/a" || vl[1].Ident().ProjectRoot != "github.com/c/c" {
+		t.Fatalf("Expected github.com/b/b to be left out of vendor, got %v", vl)
+	}
+	if got := len(sw.lock.Projects()); got != 3 {
+		t.Fatalf("Expected the lock to still hold all 3 projects, got %d", got)
+	}
+}
+
+func TestSafeWriter_LockGeneration(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	ulf := h.GetTestFile("txn_writer/updated_lock.toml")
+	defer ulf.Close()
+	updatedLock, err := readLock(ulf)
+	h.Must(err)
+
+	oldLock := &Lock{SolveMeta: SolveMeta{Generation: 4}}
+	sw, err := NewSafeWriter(nil, oldLock, updatedLock, VendorNever)
+	h.Must(err)
+	if sw.lock.SolveMeta.Generation != 5 {
+		t.Errorf("expected the generation of the lock to be bumped to 5, got %d", sw.lock.SolveMeta.Generation)
+	}
+	if updatedLock.SolveMeta.Generation != 0 {
+		t.Errorf("expected the new lock to be left alone, got generation %d", updatedLock.SolveMeta.Generation)
+	}
+
+	// An unchanged lock isn't written, so keeps its generation.
+	sw, err = NewSafeWriter(nil, sw.lock, sw.lock, VendorNever)
+	h.Must(err)
+	if sw.writeLock || sw.lock.SolveMeta.Generation != 5 {
+		t.Errorf("expected the unchanged lock to keep generation 5, got %d", sw.lock.SolveMeta.Generation)
+	}
+}
+
+func TestSafeWriter_UseFileNames(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("root")
+	root := h.Path("root")
+	ulf := h.GetTestFile("txn_writer/updated_lock.toml")
+	defer ulf.Close()
+	updatedLock, err := readLock(ulf)
+	h.Must(err)
+
+	sw, err := NewSafeWriter(&Manifest{}, nil, updatedLock, VendorNever)
+	h.Must(err)
+	sw.UseFileNames("Gopkg.staging.toml", "Gopkg.staging.lock")
+	h.Must(sw.Write(root, nil, false, discardLogger))
+
+	for _, name := range []string{"Gopkg.staging.toml", "Gopkg.staging.lock"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("expected %s to be written: %s", name, err)
+		}
+	}
+	for _, name := range []string{ManifestName, LockName} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be written, got %v", name, err)
+		}
+	}
+}
+
+func TestSafeWriter_DevProjectsChanged(t *testing.T) {
+	lp := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("abc"), nil)
+	oldLock := &Lock{P: []gps.LockedProject{lp}}
+	newLock := &Lock{P: []gps.LockedProject{lp}, Dev: map[gps.ProjectRoot]bool{"github.com/a/a": true}}
+
+	sw, err := NewSafeWriter(nil, oldLock, newLock, VendorOnChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sw.writeLock {
+		t.Fatal("Expected that the writer should plan to write the lock when only dev projects changed")
+	}
+	if !sw.writeVendor {
+		t.Fatal("Expected that the writer should plan to write the vendor directory when only dev projects changed")
+	}
+
+	sw, err = NewSafeWriter(nil, newLock, newLock, VendorOnChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw.writeLock || sw.writeVendor {
+		t.Fatal("Did not expect the writer to plan any writes for identical locks")
+	}
+}
+
+func TestSafeWriter_UnchangedDigests(t *testing.T) {
+	a := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("abc"), nil)
+	b := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, gps.NewVersion("v1.0.0").Pair("def"), nil)
+	b2 := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, gps.NewVersion("v1.1.0").Pair("fed"), nil)
+	oldLock := &Lock{
+		P:       []gps.LockedProject{a, b},
+		Digests: map[gps.ProjectRoot][]byte{"github.com/a/a": []byte("a"), "github.com/b/b": []byte("b")},
+	}
+	newLock := &Lock{P: []gps.LockedProject{a, b2}}
+
+	sw, err := NewSafeWriter(nil, oldLock, newLock, VendorNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[gps.ProjectRoot][]byte{"github.com/a/a": []byte("a")}
+	if !reflect.DeepEqual(sw.lock.Digests, want) {
+		t.Errorf("Expected only the digests of unchanged projects to be kept:\n\t(GOT): %q\n\t(WNT): %q", sw.lock.Digests, want)
+	}
+	if newLock.Digests != nil {
+		t.Errorf("Expected the new lock to be left alone, got digests %q", newLock.Digests)
+	}
+}
+
+// exportTestSM exports a single file per project, recording the projects it
+// exported.
+type exportTestSM struct {
+	gps.SourceManager
+	mu       sync.Mutex
+	exported []gps.ProjectRoot
+}
+
+func (sm *exportTestSM) ExportProject(id gps.ProjectIdentifier, v gps.Version, to string) error {
+	sm.mu.Lock()
+	sm.exported = append(sm.exported, id.ProjectRoot)
+	sm.mu.Unlock()
+	if err := os.MkdirAll(to, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(to, "lib.go"), []byte("package lib // "+v.String()+"\n"), 0666)
+}
+
+func TestSafeWriter_ReuseUnchangedVendor(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("root")
+	root := h.Path("root")
+	a := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("abc"), nil)
+	b := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, gps.NewVersion("v1.0.0").Pair("def"), nil)
+	sm := &exportTestSM{}
+
+	sw, err := NewSafeWriter(nil, nil, &Lock{P: []gps.LockedProject{a, b}}, VendorAlways)
+	h.Must(err)
+	sw.RecordVendorChecksums()
+	h.Must(sw.Write(root, sm, false, discardLogger))
+	l := sw.lock
+	if len(l.Digests) != 2 {
+		t.Fatalf("expected the digests of both projects to be recorded, got %q", l.Digests)
+	}
+
+	// Change the file of b, and leave a junk directory around.
+	h.Must(ioutil.WriteFile(filepath.Join(root, "vendor", "github.com", "b", "b", "lib.go"), []byte("package lib // changed\n"), 0666))
+	h.Must(os.MkdirAll(filepath.Join(root, "vendor", "github.com", "c", "c"), 0777))
+
+	sm.exported = nil
+	sw, err = NewSafeWriter(nil, l, l, VendorAlways)
+	h.Must(err)
+	sw.RecordVendorChecksums()
+	sw.ReuseUnchangedVendor()
+	h.Must(sw.Write(root, sm, false, discardLogger))
+
+	if !reflect.DeepEqual(sm.exported, []gps.ProjectRoot{"github.com/b/b"}) {
+		t.Errorf("expected only github.com/b/b to be written again, got %v", sm.exported)
+	}
+	d, err := VerifyVendorDigests(filepath.Join(root, "vendor"), l)
+	h.Must(err)
+	if !d.Empty() {
+		t.Errorf("expected vendor/ to match the lock, got %+v", d)
+	}
+	if sw.writeLock {
+		t.Error("expected the lock not to be written again")
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor", VendorChecksumsName)); err != nil {
+		t.Errorf("expected the checksums to be written: %s", err)
+	}
+}
+
+func TestSafeWriter_UseVendorDir(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("root")
+	root := h.Path("root")
+	a := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("abc"), nil)
+	l := &Lock{P: []gps.LockedProject{a}}
+
+	sw, err := NewSafeWriter(nil, nil, l, VendorAlways)
+	h.Must(err)
+	sw.UseVendorDir("out/deps")
+	h.Must(sw.Write(root, &exportTestSM{}, false, discardLogger))
+
+	if _, err := os.Stat(filepath.Join(root, "out", "deps", "github.com", "a", "a", "lib.go")); err != nil {
+		t.Errorf("expected the vendor tree to be written to vendor-dir: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor")); !os.IsNotExist(err) {
+		t.Errorf("expected vendor/ not to be written, got %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	// A vendor symlink is kept, and the tree it points to written anew.
+	h.TempDir("cache")
+	cache := h.Path("cache")
+	h.Must(os.Symlink(cache, filepath.Join(root, "vendor")))
+	sw, err = NewSafeWriter(nil, nil, l, VendorAlways)
+	h.Must(err)
+	h.Must(sw.Write(root, &exportTestSM{}, false, discardLogger))
+
+	fi, err := os.Lstat(filepath.Join(root, "vendor"))
+	h.Must(err)
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Error("expected vendor/ to remain a symlink")
+	}
+	if _, err := os.Stat(filepath.Join(cache, "github.com", "a", "a", "lib.go")); err != nil {
+		t.Errorf("expected the vendor tree to be written through the symlink: %s", err)
+	}
+
+	// A vendor symlink to the project itself is refused, rather than the
+	// project replaced.
+	h.TempFile("root/main.go", "package main\n")
+	h.Must(os.Remove(filepath.Join(root, "vendor")))
+	h.Must(os.Symlink(root, filepath.Join(root, "vendor")))
+	sw, err = NewSafeWriter(nil, nil, l, VendorAlways)
+	h.Must(err)
+	if err = sw.Write(root, &exportTestSM{}, false, discardLogger); err == nil {
+		t.Error("expected a vendor symlink to the project to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(root, "main.go")); err != nil {
+		t.Errorf("expected the project to be left alone: %s", err)
+	}
+
+	sw, err = NewSafeWriter(nil, nil, l, VendorAlways)
+	h.Must(err)
+	sw.UseVendorDir("..")
+	if err = sw.Write(root, &exportTestSM{}, false, discardLogger); err == nil {
+		t.Error("expected a vendor-dir out of the project to be refused")
+	}
+}
+
 func TestHasDotGit(t *testing.T) {
 	// Create a tempdir with .git file
 	td, err := ioutil.TempDir(os.TempDir(), "dotGitFile")
@@ -591,3 +854,74 @@ func TestSafeWriter_VendorDotGitPreservedWithForceVendor(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSafeWriter_VerifyDigests(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("root")
+	root := h.Path("root")
+	a := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/a/a"}, gps.NewVersion("v1.0.0").Pair("abc"), nil)
+	b := gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/b/b"}, gps.NewVersion("v1.0.0").Pair("def"), nil)
+	sm := &exportTestSM{}
+
+	sw, err := NewSafeWriter(nil, nil, &Lock{P: []gps.LockedProject{a, b}}, VendorAlways)
+	h.Must(err)
+	sw.RecordVendorChecksums()
+	h.Must(sw.Write(root, sm, false, discardLogger))
+	l := sw.lock
+
+	// The same exports match the recorded digests.
+	sw, err = NewSafeWriter(nil, l, l, VendorAlways)
+	h.Must(err)
+	sw.VerifyDigests()
+	h.Must(sw.Write(root, sm, false, discardLogger))
+
+	// As if the source of b held something else at the locked revision.
+	rewritten := *l
+	rewritten.Digests = map[gps.ProjectRoot][]byte{
+		"github.com/a/a": l.Digests["github.com/a/a"],
+		"github.com/b/b": []byte("rewritten"),
+	}
+	h.Must(os.RemoveAll(filepath.Join(root, "vendor", "github.com", "a")))
+	sw, err = NewSafeWriter(nil, &rewritten, &rewritten, VendorAlways)
+	h.Must(err)
+	sw.RecordVendorChecksums()
+	sw.VerifyDigests()
+	err = sw.Write(root, sm, false, discardLogger)
+	if err == nil || !strings.Contains(err.Error(), "the contents of github.com/b/b no longer match their digest") {
+		t.Fatalf("expected an error about the digest of github.com/b/b, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor", "github.com", "a")); !os.IsNotExist(err) {
+		t.Errorf("expected vendor/ to be left as it was, got %v", err)
+	}
+}
+
+func TestSafeWriter_ConfigureVendor(t *testing.T) {
+	m := &Manifest{
+		VendorChecksums:  true,
+		KeepNestedVendor: true,
+		Patches:          map[gps.ProjectRoot][]string{"github.com/foo/bar": {"bar.diff"}},
+	}
+	l := &Lock{Dev: map[gps.ProjectRoot]bool{"github.com/foo/baz": true}}
+
+	sw := &SafeWriter{}
+	sw.ConfigureVendor(m, l, false, true)
+	if !reflect.DeepEqual(sw.vendorExclude, l.Dev) {
+		t.Errorf("expected the dev projects to be left out of vendor, got %v", sw.vendorExclude)
+	}
+	if !sw.vendorChecksums || !sw.keepNestedVendor || !sw.verifyDigests {
+		t.Errorf("expected the checksums, nested vendor and verify settings to be set, got %+v", sw)
+	}
+	if !reflect.DeepEqual(sw.patches, m.Patches) {
+		t.Errorf("expected the patches of the manifest, got %v", sw.patches)
+	}
+
+	// With dev, the dev projects are written too, and nothing set without a
+	// manifest.
+	sw = &SafeWriter{}
+	sw.ConfigureVendor(nil, l, true, false)
+	if sw.vendorExclude != nil || sw.vendorChecksums || sw.keepNestedVendor || sw.verifyDigests || sw.patches != nil {
+		t.Errorf("expected nothing to be configured, got %+v", sw)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// VendorChecksumsName is the name of the file, at the root of vendor/, which
+// records the checksums of all the other vendored files.
+const VendorChecksumsName = ".dep-checksums"
+
+var vendorChecksumsComment = []byte(`# This file is autogenerated by 'dep ensure', do not edit; it is verified by 'dep check'.
+`)
+
+// WriteVendorChecksums computes the checksum of every file beneath vendorDir
+// and records them in the VendorChecksumsName file at its root.
+func WriteVendorChecksums(vendorDir string) error {
+	sums, err := vendorChecksums(vendorDir)
+	if err != nil {
+		return err
+	}
+	return writeVendorChecksums(vendorDir, sums)
+}
+
+// ForgetVendorChecksums drops the recorded checksums of the files beneath the
+// given slash-separated directories, relative to vendorDir, for use once they
+// have been removed. It does nothing if no checksums are recorded.
+func ForgetVendorChecksums(vendorDir string, dirs []string) error {
+	sums, err := readVendorChecksums(filepath.Join(vendorDir, VendorChecksumsName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for p := range sums {
+		for _, dir := range dirs {
+			if strings.HasPrefix(p, dir+"/") {
+				delete(sums, p)
+				break
+			}
+		}
+	}
+	return writeVendorChecksums(vendorDir, sums)
+}
+
+func writeVendorChecksums(vendorDir string, sums map[string]string) error {
+	paths := make([]string, 0, len(sums))
+	for p := range sums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	buf.Write(vendorChecksumsComment)
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "%s  %s\n", sums[p], p)
+	}
+
+	err := ioutil.WriteFile(filepath.Join(vendorDir, VendorChecksumsName), buf.Bytes(), 0666)
+	return errors.Wrap(err, "failed to write vendor checksums")
+}
+
+// VendorChecksumDiff describes how the files beneath vendor/ differ from the
+// checksums recorded for them. All paths are slash-separated and relative to
+// vendor/.
+type VendorChecksumDiff struct {
+	// Modified holds the files whose checksum doesn't match the recorded one.
+	Modified []string
+	// Missing holds the files which have a recorded checksum, but don't exist.
+	Missing []string
+	// Added holds the files which exist, but have no recorded checksum.
+	Added []string
+}
+
+// Empty reports whether vendor/ matches the recorded checksums.
+func (d VendorChecksumDiff) Empty() bool {
+	return len(d.Modified) == 0 && len(d.Missing) == 0 && len(d.Added) == 0
+}
+
+// VerifyVendorChecksums compares the files beneath vendorDir with the
+// checksums recorded in its VendorChecksumsName file.
+func VerifyVendorChecksums(vendorDir string) (VendorChecksumDiff, error) {
+	var d VendorChecksumDiff
+
+	want, err := readVendorChecksums(filepath.Join(vendorDir, VendorChecksumsName))
+	if err != nil {
+		return d, err
+	}
+	got, err := vendorChecksums(vendorDir)
+	if err != nil {
+		return d, err
+	}
+
+	for p, sum := range want {
+		gsum, has := got[p]
+		switch {
+		case !has:
+			d.Missing = append(d.Missing, p)
+		case gsum != sum:
+			d.Modified = append(d.Modified, p)
+		}
+	}
+	for p := range got {
+		if _, has := want[p]; !has {
+			d.Added = append(d.Added, p)
+		}
+	}
+
+	sort.Strings(d.Modified)
+	sort.Strings(d.Missing)
+	sort.Strings(d.Added)
+	return d, nil
+}
+
+// vendorChecksums returns the hex-encoded checksum of every file beneath
+// vendorDir, keyed by slash-separated path relative to vendorDir. Symbolic
+// links are checksummed by their referent. The checksums file itself and the
+// vendor/.git directory, which is preserved across writes, are left out.
+func vendorChecksums(vendorDir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(vendorDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(vendorDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case rel == ".git" && info.IsDir():
+			return filepath.SkipDir
+		case rel == VendorChecksumsName, info.IsDir():
+			return nil
+		}
+
+		var sum []byte
+		switch mode := info.Mode(); {
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read link %s", p)
+			}
+			h := sha256.Sum256([]byte(filepath.ToSlash(target)))
+			sum = h[:]
+		case mode.IsRegular():
+			if sum, err = pkgtree.DigestFromFile(p); err != nil {
+				return errors.Wrapf(err, "failed to checksum %s", p)
+			}
+		default:
+			return nil
+		}
+
+		sums[rel] = hex.EncodeToString(sum)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to checksum vendor directory")
+	}
+	return sums, nil
+}
+
+// readVendorChecksums parses a checksums file written by WriteVendorChecksums.
+func readVendorChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sums := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			return nil, errors.Errorf("%s:%d: malformed checksum line", VendorChecksumsName, n)
+		}
+		sums[fields[1]] = fields[0]
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", VendorChecksumsName)
+	}
+	return sums, nil
+}
+
+// vendorDigests returns the digest of the directory of every project of l
+// beneath vendorDir.
+func vendorDigests(vendorDir string, l gps.Lock) (map[gps.ProjectRoot][]byte, error) {
+	digests := make(map[gps.ProjectRoot][]byte)
+	for _, lp := range l.Projects() {
+		pr := lp.Ident().ProjectRoot
+		digest, err := pkgtree.DigestFromDirectory(filepath.Join(vendorDir, filepath.FromSlash(string(pr))))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute the digest of %s", pr)
+		}
+		digests[pr] = digest
+	}
+	return digests, nil
+}
+
+// verifyDigests checks that the digests computed for projects match those
+// recorded for them, for the projects which have one recorded.
+func verifyDigests(got, recorded map[gps.ProjectRoot][]byte) error {
+	var mismatched []string
+	for pr, digest := range got {
+		if want, has := recorded[pr]; has && !bytes.Equal(digest, want) {
+			mismatched = append(mismatched, string(pr))
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	sort.Strings(mismatched)
+	return errors.Errorf("the contents of %s no longer match their digest in the lock; their source may have been rewritten upstream, or the prune options of vendor/ changed", strings.Join(mismatched, ", "))
+}
+
+// digestsEqual checks whether two sets of project digests are the same.
+func digestsEqual(a, b map[gps.ProjectRoot][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for pr, digest := range a {
+		if !bytes.Equal(digest, b[pr]) {
+			return false
+		}
+	}
+	return true
+}
+
+// VendorDigestDiff describes how the project directories beneath vendor/
+// differ from the digests recorded for them in the lock.
+type VendorDigestDiff struct {
+	// Drifted holds the projects whose directory doesn't match its digest.
+	Drifted []gps.ProjectRoot
+	// Missing holds the projects of the lock which aren't in vendor/.
+	Missing []gps.ProjectRoot
+	// Unrecorded holds the projects of the lock which are in vendor/, but
+	// have no digest.
+	Unrecorded []gps.ProjectRoot
+	// Extraneous holds the slash-separated directories and files beneath
+	// vendor/ which belong to no project of the lock.
+	Extraneous []string
+}
+
+// Empty reports whether vendor/ matches the digests of the lock.
+func (d VendorDigestDiff) Empty() bool {
+	return len(d.Drifted) == 0 && len(d.Missing) == 0 && len(d.Unrecorded) == 0 && len(d.Extraneous) == 0
+}
+
+// VerifyVendorDigests compares the project directories beneath vendorDir with
+// the digests recorded in l. The dev projects of l without a digest, which
+// were left out of vendor/, aren't expected to be found in it.
+func VerifyVendorDigests(vendorDir string, l *Lock) (VendorDigestDiff, error) {
+	var d VendorDigestDiff
+
+	want := make(map[string][]byte, len(l.P))
+	for _, lp := range l.P {
+		pr := lp.Ident().ProjectRoot
+		digest, has := l.Digests[pr]
+		if !has && l.Dev[pr] {
+			continue
+		}
+		want[string(pr)] = digest
+	}
+
+	status, err := pkgtree.VerifyDepTree(vendorDir, want)
+	if err != nil {
+		return d, errors.Wrap(err, "failed to verify vendor directory")
+	}
+
+	for p, st := range status {
+		switch st {
+		case pkgtree.DigestMismatchInLock:
+			d.Drifted = append(d.Drifted, gps.ProjectRoot(p))
+		case pkgtree.NotInTree:
+			d.Missing = append(d.Missing, gps.ProjectRoot(p))
+		case pkgtree.EmptyDigestInLock:
+			d.Unrecorded = append(d.Unrecorded, gps.ProjectRoot(p))
+		case pkgtree.NotInLock:
+			if p != VendorChecksumsName {
+				d.Extraneous = append(d.Extraneous, p)
+			}
+		}
+	}
+
+	sort.Sort(sortedProjectRoots(d.Drifted))
+	sort.Sort(sortedProjectRoots(d.Missing))
+	sort.Sort(sortedProjectRoots(d.Unrecorded))
+	sort.Strings(d.Extraneous)
+	return d, nil
+}
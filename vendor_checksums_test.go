@@ -0,0 +1,157 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/internal/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestVendorChecksums(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("vendor")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar\n")
+	h.TempFile("vendor/github.com/foo/bar/LICENSE", "MIT\n")
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz\n")
+	h.TempFile("vendor/.git/HEAD", "ref: refs/heads/master\n")
+	vendorDir := h.Path("vendor")
+
+	if err := WriteVendorChecksums(vendorDir); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := VerifyVendorChecksums(vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Fatalf("Expected freshly recorded checksums to match, got %+v", d)
+	}
+
+	// Line endings converted on checkout don't count as a modification.
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz\r\n")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar\n\nfunc init() { panic(\"pwned\") }\n")
+	h.TempFile("vendor/github.com/foo/bar/extra.go", "package bar\n")
+	if err = os.Remove(filepath.Join(vendorDir, "github.com", "foo", "bar", "LICENSE")); err != nil {
+		t.Fatal(err)
+	}
+	h.TempFile("vendor/.git/HEAD", "ref: refs/heads/other\n")
+
+	d, err = VerifyVendorChecksums(vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VendorChecksumDiff{
+		Modified: []string{"github.com/foo/bar/bar.go"},
+		Missing:  []string{"github.com/foo/bar/LICENSE"},
+		Added:    []string{"github.com/foo/bar/extra.go"},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Unexpected checksum diff:\n\t(GOT): %+v\n\t(WNT): %+v", d, want)
+	}
+
+	if err = os.RemoveAll(filepath.Join(vendorDir, "github.com", "foo", "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err = ForgetVendorChecksums(vendorDir, []string{"github.com/foo/bar"}); err != nil {
+		t.Fatal(err)
+	}
+	d, err = VerifyVendorChecksums(vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Errorf("Expected checksums of removed directories to be forgotten, got %+v", d)
+	}
+}
+
+func TestVerifyVendorChecksumsErrors(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("vendor")
+	vendorDir := h.Path("vendor")
+
+	if _, err := VerifyVendorChecksums(vendorDir); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error without a checksums file, got %v", err)
+	}
+	if err := ForgetVendorChecksums(vendorDir, []string{"github.com/foo/bar"}); err != nil {
+		t.Errorf("Expected forgetting checksums to be a no-op without a checksums file, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(vendorDir, VendorChecksumsName), []byte("# comment\nnot-a-checksum-line\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyVendorChecksums(vendorDir); err == nil {
+		t.Error("Expected an error for a malformed checksums file")
+	}
+}
+
+func TestVerifyVendorDigests(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("vendor")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar\n")
+	h.TempFile("vendor/github.com/foo/baz/baz.go", "package baz\n")
+	h.TempFile("vendor/github.com/foo/qux/qux.go", "package qux\n")
+	vendorDir := h.Path("vendor")
+
+	newProject := func(pr gps.ProjectRoot) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: pr}, gps.NewVersion("v1.0.0").Pair("abc"), []string{"."})
+	}
+	l := &Lock{
+		P: []gps.LockedProject{
+			newProject("github.com/foo/bar"),
+			newProject("github.com/foo/baz"),
+			newProject("github.com/foo/qux"),
+		},
+	}
+
+	var err error
+	if l.Digests, err = vendorDigests(vendorDir, l); err != nil {
+		t.Fatal(err)
+	}
+	h.Must(WriteVendorChecksums(vendorDir))
+
+	d, err := VerifyVendorDigests(vendorDir, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Fatalf("Expected freshly recorded digests to match, got %+v", d)
+	}
+
+	// Unvendored dev projects without a digest are fine.
+	l.P = append(l.P, newProject("github.com/foo/dev"), newProject("github.com/foo/gone"), newProject("github.com/foo/new"))
+	l.Dev = map[gps.ProjectRoot]bool{"github.com/foo/dev": true}
+	l.Digests["github.com/foo/gone"] = []byte("digest")
+	h.TempFile("vendor/github.com/foo/new/new.go", "package new\n")
+	h.TempFile("vendor/github.com/foo/bar/bar.go", "package bar\n\nfunc init() { panic(\"pwned\") }\n")
+	h.TempFile("vendor/github.com/foo/extra/extra.go", "package extra\n")
+	h.TempFile("vendor/golang.org/x/text/text.go", "package text\n")
+
+	d, err = VerifyVendorDigests(vendorDir, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VendorDigestDiff{
+		Drifted:    []gps.ProjectRoot{"github.com/foo/bar"},
+		Missing:    []gps.ProjectRoot{"github.com/foo/gone"},
+		Unrecorded: []gps.ProjectRoot{"github.com/foo/new"},
+		Extraneous: []string{"github.com/foo/extra", "golang.org"},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Unexpected digest diff:\n\t(GOT): %+v\n\t(WNT): %+v", d, want)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/internal/gps"
+	"github.com/pkg/errors"
+)
+
+// A WorkspaceMember is a project of a workspace other than its root. It has
+// its own manifest, which is merged with that of the root, but shares the lock
+// and vendor/ of the root.
+type WorkspaceMember struct {
+	// Dir is the slash-separated path of the member, relative to the root of
+	// the workspace.
+	Dir      string
+	Manifest *Manifest
+}
+
+// findWorkspaceRoot returns the root of the workspace root is a member of, if
+// any: the nearest directory above it holding a manifest of the given name
+// which lists it as a member. root is returned otherwise.
+func findWorkspaceRoot(root, name string) string {
+	for dir := filepath.Dir(root); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		m, _, err := readManifest(f)
+		f.Close()
+		if err != nil {
+			return root
+		}
+
+		rel, err := filepath.Rel(dir, root)
+		if err != nil {
+			return root
+		}
+		for _, member := range m.Workspace {
+			if member == filepath.ToSlash(rel) {
+				return dir
+			}
+		}
+		return root
+	}
+	return root
+}
+
+// loadWorkspaceMembers reads the manifests of the members of the workspace
+// rooted at root, as listed by its manifest m, and checks that they can be
+// merged. The manifests and locks of the members have the same names as those
+// of the root. The returned warnings are those of the manifests, and about the
+// constraints of the members superseded by those of the root.
+func loadWorkspaceMembers(root, manifestName, lockName string, m *Manifest) ([]WorkspaceMember, []error, error) {
+	var members []WorkspaceMember
+	var warns []error
+	for _, dir := range m.Workspace {
+		mp := filepath.Join(root, filepath.FromSlash(dir), manifestName)
+		f, err := os.Open(mp)
+		if err != nil {
+			return nil, warns, errors.Wrapf(err, "could not open the manifest of workspace member %s", dir)
+		}
+		mm, mwarns, err := readManifest(f)
+		f.Close()
+		for _, warn := range mwarns {
+			warns = append(warns, fmt.Errorf("%s: %v", path.Join(dir, manifestName), warn))
+		}
+		if err != nil {
+			return nil, warns, errors.Errorf("error while parsing %s: %s", mp, err)
+		}
+		if len(mm.Workspace) > 0 {
+			return nil, warns, errors.Errorf("workspace member %s can't have members of its own", dir)
+		}
+
+		if lockOK, _ := fs.IsRegular(filepath.Join(root, filepath.FromSlash(dir), lockName)); lockOK {
+			warns = append(warns, fmt.Errorf("%s is not used, the members of a workspace share %s", path.Join(dir, lockName), lockName))
+		}
+
+		members = append(members, WorkspaceMember{Dir: dir, Manifest: mm})
+	}
+
+	for _, kind := range []struct {
+		name string
+		of   func(*Manifest) gps.ProjectConstraints
+	}{
+		{"constraint", func(m *Manifest) gps.ProjectConstraints { return m.Constraints }},
+		{"override", func(m *Manifest) gps.ProjectConstraints { return m.Ovr }},
+	} {
+		// The first member declaring a rule on a project, for those the root
+		// doesn't declare one on.
+		declared := make(map[gps.ProjectRoot]WorkspaceMember)
+		for _, member := range members {
+			for pr, pp := range kind.of(member.Manifest) {
+				if rpp, has := kind.of(m)[pr]; has {
+					if !sameProperties(pp, rpp) {
+						warns = append(warns, fmt.Errorf("the %s on %s in %s is superseded by that of %s", kind.name, pr, path.Join(member.Dir, manifestName), manifestName))
+					}
+					continue
+				}
+				other, has := declared[pr]
+				if !has {
+					declared[pr] = member
+					continue
+				}
+				if !sameProperties(pp, kind.of(other.Manifest)[pr]) {
+					return nil, warns, errors.Errorf("conflicting %ss on %s in %s and %s; declare the %s in the %s of the workspace root instead",
+						kind.name, pr, path.Join(other.Dir, manifestName), path.Join(member.Dir, manifestName), kind.name, manifestName)
+				}
+			}
+		}
+	}
+
+	return members, warns, nil
+}
+
+// sameProperties checks whether a and b name the same source and constraint.
+func sameProperties(a, b gps.ProjectProperties) bool {
+	if a.Source != b.Source {
+		return false
+	}
+	if a.Constraint == nil || b.Constraint == nil {
+		return a.Constraint == b.Constraint
+	}
+	return a.Constraint.String() == b.Constraint.String()
+}
+
+// WithMembers returns m, the manifest of the root of the workspace of p,
+// merged with the manifests of the members of the workspace, as the solver
+// sees it. The constraints and overrides of m take precedence over those of
+// the members. m itself is returned if the workspace has no members.
+//
+// The merge happens whenever the returned manifest is queried, so that the
+// changes made to m afterwards are seen.
+func (p *Project) WithMembers(m *Manifest) gps.RootManifest {
+	if len(p.Members) == 0 {
+		return m
+	}
+	return workspaceManifest{root: m, members: p.Members}
+}
+
+// workspaceManifest is the manifest of the root of a workspace, merged with
+// those of its members.
+type workspaceManifest struct {
+	root    *Manifest
+	members []WorkspaceMember
+}
+
+func (m workspaceManifest) mergeConstraints(of func(*Manifest) gps.ProjectConstraints) gps.ProjectConstraints {
+	pc := make(gps.ProjectConstraints)
+	for pr, pp := range of(m.root) {
+		pc[pr] = pp
+	}
+	for _, member := range m.members {
+		for pr, pp := range of(member.Manifest) {
+			if _, has := pc[pr]; !has {
+				pc[pr] = pp
+			}
+		}
+	}
+	return pc
+}
+
+func (m workspaceManifest) mergePackages(of func(*Manifest) map[string]bool) map[string]bool {
+	pkgs := of(m.root)
+	for _, member := range m.members {
+		for pkg := range of(member.Manifest) {
+			if pkgs == nil {
+				pkgs = make(map[string]bool)
+			}
+			pkgs[pkg] = true
+		}
+	}
+	return pkgs
+}
+
+func (m workspaceManifest) DependencyConstraints() gps.ProjectConstraints {
+	return m.mergeConstraints((*Manifest).DependencyConstraints)
+}
+
+func (m workspaceManifest) Overrides() gps.ProjectConstraints {
+	return m.mergeConstraints((*Manifest).Overrides)
+}
+
+func (m workspaceManifest) IgnoredPackages() map[string]bool {
+	return m.mergePackages((*Manifest).IgnoredPackages)
+}
+
+func (m workspaceManifest) RequiredPackages() map[string]bool {
+	return m.mergePackages((*Manifest).RequiredPackages)
+}
+
+// RequiredGoVersion returns the latest release of Go the manifests require, as
+// the workspace builds with none earlier.
+func (m workspaceManifest) RequiredGoVersion() string {
+	req, latest := m.root.GoVersion, gps.GoVersion{}
+	if req != "" {
+		latest, _ = gps.ParseGoVersion(req)
+	}
+	for _, member := range m.members {
+		if member.Manifest.GoVersion == "" {
+			continue
+		}
+		if v, err := gps.ParseGoVersion(member.Manifest.GoVersion); err == nil && latest.Less(v) {
+			req, latest = member.Manifest.GoVersion, v
+		}
+	}
+	return req
+}
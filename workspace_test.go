@@ -0,0 +1,187 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestLoadProjectWorkspace(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile(filepath.Join("src", "mono", ManifestName), `workspace = ["services/api", "services/worker"]
+
+[[constraint]]
+  name = "github.com/pkg/errors"
+  version = "0.8.0"
+`)
+	h.TempFile(filepath.Join("src", "mono", "services", "api", ManifestName), `required = ["github.com/golang/lint/golint"]
+
+[[constraint]]
+  name = "github.com/pkg/errors"
+  version = "0.7.0"
+
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`)
+	h.TempDir(filepath.Join("src", "mono", "services", "api", "handlers"))
+	h.TempFile(filepath.Join("src", "mono", "services", "worker", ManifestName), `ignored = ["github.com/sdboyer/deptestdos"]
+
+[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+
+[[override]]
+  name = "golang.org/x/net"
+  branch = "master"
+`)
+	h.TempFile(filepath.Join("src", "mono", "services", "worker", LockName), "")
+
+	for _, wd := range []string{"mono", "mono/services/worker", "mono/services/api/handlers"} {
+		var stderr bytes.Buffer
+		ctx := &Ctx{Out: discardLogger, Err: log.New(&stderr, "", 0)}
+		if err := ctx.SetPaths(h.Path(filepath.Join("src", filepath.FromSlash(wd))), h.Path(".")); err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		p, err := ctx.LoadProject()
+		if err != nil {
+			t.Fatalf("%s: LoadProject failed: %+v", wd, err)
+		}
+		if p.ImportRoot != "mono" {
+			t.Errorf("%s: expected the workspace root to be loaded, got %s", wd, p.ImportRoot)
+		}
+		if len(p.Members) != 2 || p.Members[0].Dir != "services/api" || p.Members[1].Dir != "services/worker" {
+			t.Fatalf("%s: unexpected members %+v", wd, p.Members)
+		}
+		for _, warn := range []string{"is superseded by that of", "services/worker/Gopkg.lock is not used"} {
+			if !strings.Contains(stderr.String(), warn) {
+				t.Errorf("%s: expected a warning containing %q, got:\n%s", wd, warn, stderr.String())
+			}
+		}
+
+		// The manifest of the root is left as it is, to be written back.
+		if len(p.Manifest.Constraints) != 1 {
+			t.Errorf("%s: expected the manifest of the root to be unchanged, got %v", wd, p.Manifest.Constraints)
+		}
+
+		m := p.MakeParams().Manifest
+		deps := m.DependencyConstraints()
+		if len(deps) != 2 || deps["github.com/pkg/errors"].Constraint.String() != "^0.8.0" || deps["github.com/sdboyer/deptest"].Constraint.String() != "^1.0.0" {
+			t.Errorf("%s: unexpected merged constraints %v", wd, deps)
+		}
+		if ovr := m.Overrides(); len(ovr) != 1 || ovr["golang.org/x/net"].Constraint.String() != "master" {
+			t.Errorf("%s: unexpected merged overrides %v", wd, ovr)
+		}
+		if got, want := m.RequiredPackages(), map[string]bool{"github.com/golang/lint/golint": true}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: unexpected merged required packages %v", wd, got)
+		}
+		if got, want := m.IgnoredPackages(), map[string]bool{"github.com/sdboyer/deptestdos": true}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: unexpected merged ignored packages %v", wd, got)
+		}
+
+		// Changes to the manifest of the root are seen when solving.
+		delete(p.Manifest.Constraints, "github.com/pkg/errors")
+		if deps := m.DependencyConstraints(); deps["github.com/pkg/errors"].Constraint.String() != "^0.7.0" {
+			t.Errorf("%s: expected the constraint of services/api to apply, got %v", wd, deps)
+		}
+	}
+}
+
+func TestLoadProjectWorkspaceManifestName(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	// The members of a workspace whose manifest has another name are read
+	// from manifests of that name.
+	h.TempFile(filepath.Join("src", "mono", "deps.toml"), `workspace = ["api"]`)
+	h.TempFile(filepath.Join("src", "mono", "api", "deps.toml"), `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`)
+	h.TempFile(filepath.Join("src", "mono", "api", ManifestName), `[[constraint]]
+  name = "github.com/pkg/errors"
+  version = "0.8.0"
+`)
+	h.TempFile(filepath.Join("src", "mono", "api", "deps.lock"), "")
+
+	var stderr bytes.Buffer
+	ctx := &Ctx{Out: discardLogger, Err: log.New(&stderr, "", 0), ManifestName: "deps.toml"}
+	if err := ctx.SetPaths(h.Path(filepath.Join("src", "mono", "api")), h.Path(".")); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	p, err := ctx.LoadProject()
+	if err != nil {
+		t.Fatalf("LoadProject failed: %+v", err)
+	}
+	if len(p.Members) != 1 || p.Members[0].Dir != "api" {
+		t.Fatalf("unexpected members %+v", p.Members)
+	}
+	if deps := p.Members[0].Manifest.Constraints; len(deps) != 1 || deps["github.com/sdboyer/deptest"].Constraint == nil {
+		t.Errorf("expected the member to be read from deps.toml, got %v", deps)
+	}
+	if want := "api/deps.lock is not used, the members of a workspace share deps.lock"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("expected a warning containing %q, got:\n%s", want, stderr.String())
+	}
+}
+
+func TestLoadProjectWorkspaceConflict(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempFile(filepath.Join("src", "mono", ManifestName), `workspace = ["api", "worker"]`)
+	h.TempFile(filepath.Join("src", "mono", "api", ManifestName), `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  version = "1.0.0"
+`)
+	h.TempFile(filepath.Join("src", "mono", "worker", ManifestName), `[[constraint]]
+  name = "github.com/sdboyer/deptest"
+  branch = "master"
+`)
+	// Not a member, so loaded as a project of its own.
+	h.TempFile(filepath.Join("src", "mono", "tools", ManifestName), "")
+
+	ctx := &Ctx{Out: discardLogger, Err: discardLogger}
+	if err := ctx.SetPaths(h.Path(filepath.Join("src", "mono", "api")), h.Path(".")); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err := ctx.LoadProject()
+	if err == nil || !strings.Contains(err.Error(), "conflicting constraints on github.com/sdboyer/deptest in api/Gopkg.toml and worker/Gopkg.toml") {
+		t.Errorf("expected an error about the conflicting constraints, got %v", err)
+	}
+
+	ctx.WorkingDir = h.Path(filepath.Join("src", "mono", "tools"))
+	p, err := ctx.LoadProject()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if p.ImportRoot != "mono/tools" || len(p.Members) != 0 {
+		t.Errorf("expected mono/tools to be loaded on its own, got %s with members %+v", p.ImportRoot, p.Members)
+	}
+}
+
+func TestReadManifestWorkspaceErrors(t *testing.T) {
+	for _, in := range []string{
+		`workspace = ["../other"]`,
+		`workspace = ["/abs"]`,
+		`workspace = ["."]`,
+		`workspace = ["api/"]`,
+		`workspace = ["vendor/foo"]`,
+		`workspace = ["api", "api"]`,
+	} {
+		if _, _, err := readManifest(strings.NewReader(in)); err == nil {
+			t.Errorf("expected an error reading %s", in)
+		}
+	}
+}